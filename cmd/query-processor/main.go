@@ -6,12 +6,14 @@ import (
 	"runtime"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/seanankenbruck/observability-ai/internal/auth"
 	"github.com/seanankenbruck/observability-ai/internal/config"
+	"github.com/seanankenbruck/observability-ai/internal/events"
 	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/metrics"
 	"github.com/seanankenbruck/observability-ai/internal/mimir"
+	"github.com/seanankenbruck/observability-ai/internal/notify"
 	"github.com/seanankenbruck/observability-ai/internal/observability"
 	"github.com/seanankenbruck/observability-ai/internal/processor"
 	"github.com/seanankenbruck/observability-ai/internal/semantic"
@@ -47,22 +49,36 @@ func main() {
 	if err != nil {
 		log.Fatal("Failed to initialize LLM client:", err)
 	}
+	llmClient.SetDefaultConfidence(cfg.Claude.DefaultConfidence)
+	llmClient.SetStructuredOutput(cfg.Claude.StructuredOutput)
+
+	// Probe the embedding dimension so the database schema can be checked against it
+	embeddingDimension := 0
+	if probe, err := llmClient.GetEmbedding(ctx, "embedding dimension probe"); err == nil {
+		embeddingDimension = len(probe)
+	} else {
+		log.Printf("Warning: could not determine embedding dimension, skipping schema check: %v", err)
+	}
 
 	// Initialize semantic mapper
 	semanticMapper, err := semantic.NewPostgresMapper(semantic.PostgresConfig{
-		Host:     cfg.Database.Host,
-		Port:     cfg.Database.Port,
-		Database: cfg.Database.Database,
-		Username: cfg.Database.Username,
-		Password: cfg.Database.Password,
-		SSLMode:  cfg.Database.SSLMode,
+		Host:               cfg.Database.Host,
+		Port:               cfg.Database.Port,
+		Database:           cfg.Database.Database,
+		Username:           cfg.Database.Username,
+		Password:           cfg.Database.Password,
+		SSLMode:            cfg.Database.SSLMode,
+		EmbeddingDimension: embeddingDimension,
+		DistanceMetric:     semantic.DistanceMetric(cfg.Database.DistanceMetric),
+		RecencyHalfLife:    cfg.Database.RecencyHalfLife,
 	})
 	if err != nil {
 		log.Fatal("Failed to initialize semantic mapper:", err)
 	}
+	defer semanticMapper.Close()
 
 	// Initialize Mimir client with backend type detection
-	mimirClient := mimir.NewClientWithBackend(
+	mimirClient, err := mimir.NewClientWithAuth(
 		cfg.Mimir.Endpoint,
 		mimir.AuthConfig{
 			Type:        cfg.Mimir.AuthType,
@@ -70,10 +86,37 @@ func main() {
 			Password:    cfg.Mimir.Password,
 			BearerToken: cfg.Mimir.BearerToken,
 			TenantID:    cfg.Mimir.TenantID,
+			TLSCertFile: cfg.Mimir.TLSCertFile,
+			TLSKeyFile:  cfg.Mimir.TLSKeyFile,
+			TLSCAFile:   cfg.Mimir.TLSCAFile,
+			TLSCertPEM:  cfg.Mimir.TLSCertPEM,
+			TLSKeyPEM:   cfg.Mimir.TLSKeyPEM,
+			TLSCAPEM:    cfg.Mimir.TLSCAPEM,
 		},
 		cfg.Mimir.Timeout,
 		mimir.BackendType(cfg.Mimir.BackendType),
 	)
+	if err != nil {
+		log.Fatal("Failed to initialize Mimir client:", err)
+	}
+	mimirClient.SetQueryTimeout(cfg.Query.Timeout)
+
+	// Correct the inferred types of metrics with unconventional names
+	metricTypes, err := metrics.NewTypeOverrides(cfg.Query.MetricTypeOverrides)
+	if err != nil {
+		log.Fatal("Invalid metric type overrides:", err)
+	}
+	mimirClient.SetTypeOverrides(metricTypes)
+
+	// Event bus for the admin event stream
+	eventBus := events.NewBus()
+
+	// Operator notifications, sent to a webhook when one is configured
+	var notifier notify.Notifier = notify.Nop{}
+	if cfg.Notify.WebhookURL != "" {
+		notifier = notify.NewWebhookNotifier(cfg.Notify.WebhookURL, cfg.Notify.WebhookTimeout)
+		log.Println("Webhook notifications enabled")
+	}
 
 	// Initialize discovery service
 	discoveryConfig := mimir.DiscoveryConfig{
@@ -82,9 +125,27 @@ func main() {
 		Namespaces:        cfg.Discovery.Namespaces,
 		ServiceLabelNames: cfg.Discovery.ServiceLabelNames,
 		ExcludeMetrics:    cfg.Discovery.ExcludeMetrics,
+		ExcludeNamespaces: cfg.Discovery.ExcludeNamespaces,
+		MaxRetries:        cfg.Discovery.MaxRetries,
+		RetryBaseDelay:    cfg.Discovery.RetryBaseDelay,
+		RetryMaxDelay:     cfg.Discovery.RetryMaxDelay,
+		FailureThreshold:  cfg.Discovery.FailureThreshold,
+		MaxLabelValues:    cfg.Discovery.MaxLabelValues,
+		CallTimeout:       cfg.Discovery.CallTimeout,
+		DefaultNamespace:  cfg.Discovery.DefaultNamespace,
+
+		ServiceExcludeMetrics: cfg.Discovery.ServiceExcludeMetrics,
+
+		ServiceSignalWeights: cfg.Discovery.ServiceSignalWeights,
+		MinServiceConfidence: cfg.Discovery.MinServiceConfidence,
+
+		NormalizeServiceNames: cfg.Discovery.NormalizeServiceNames,
+		ServiceNameSeparator:  cfg.Discovery.ServiceNameSeparator,
 	}
 
 	discoveryService := mimir.NewDiscoveryService(mimirClient, discoveryConfig, semanticMapper)
+	discoveryService.SetEventBus(eventBus)
+	discoveryService.SetNotifier(notifier)
 
 	// Start discovery in background
 	if discoveryConfig.Enabled {
@@ -106,14 +167,21 @@ func main() {
 		SessionExpiry:  cfg.Auth.SessionExpiry,
 		RateLimit:      cfg.Auth.RateLimit,
 		AllowAnonymous: cfg.Auth.AllowAnonymous,
+		RoleHierarchy:  cfg.Auth.RoleHierarchy,
+		JWTIssuer:      cfg.Auth.JWTIssuer,
+		JWTAudience:    cfg.Auth.JWTAudience,
 	}, sessionManager)
+	authManager.SetEventBus(eventBus)
+	authManager.SetNotifier(notifier)
 
 	// Start auth cleanup routine
 	go func() {
 		ticker := time.NewTicker(1 * time.Hour)
 		defer ticker.Stop()
 		for range ticker.C {
-			authManager.CleanupExpired()
+			if _, err := authManager.CleanupExpired(); err != nil {
+				log.Printf("Warning: Auth cleanup failed: %v", err)
+			}
 		}
 	}()
 
@@ -136,6 +204,9 @@ func main() {
 		return m.Alloc, m.Sys
 	}))
 
+	// Verify the database can store and search embeddings of the current model
+	healthChecker.Register("embedding_schema", observability.EmbeddingSchemaHealthCheck(semanticMapper.EmbeddingSchema))
+
 	// Register LLM health check
 	healthChecker.Register("llm_service", observability.LLMHealthCheck(func(ctx context.Context) error {
 		// Simple health check - try to generate a minimal embedding
@@ -148,31 +219,110 @@ func main() {
 		return mimirClient.TestConnection(ctx)
 	}))
 
+	// Register discovery health check
+	if discoveryConfig.Enabled {
+		healthChecker.Register("discovery", observability.DiscoveryHealthCheck(func() (int, int, string) {
+			status := discoveryService.Status()
+			return status.ConsecutiveFailures, status.FailureThreshold, status.LastError
+		}))
+		// Hold traffic until the first discovery cycle has populated the catalog
+		healthChecker.RegisterReadinessGate("initial_discovery", observability.InitialDiscoveryGate(func() time.Time {
+			return discoveryService.Status().LastSuccess
+		}))
+	}
+
 	// Create query processor
 	qp := processor.NewQueryProcessor(llmClient, semanticMapper, rdb)
 	qp.SetHealthChecker(healthChecker)
+	qp.SetRequestDescriber(mimirClient)
+	qp.SetMetadataFetcher(mimirClient)
+	qp.SetQueryExecutor(mimirClient)
+	qp.SetTrustedProxies(cfg.Server.TrustedProxies)
+	qp.SetMaintenanceMode(cfg.Server.MaintenanceMode, cfg.Server.MaintenanceMessage)
+	qp.SetEventBus(eventBus)
+	qp.SetDefaultNamespace(cfg.Discovery.DefaultNamespace)
+	tenantDescribers := make(map[string]processor.RequestDescriber)
+	for _, tenant := range cfg.Mimir.Tenants {
+		if tenant != cfg.Mimir.TenantID {
+			tenantDescribers[tenant] = mimirClient.ForTenant(tenant)
+		}
+	}
+	qp.SetTenantDescribers(cfg.Mimir.TenantID, tenantDescribers)
+	qp.SetSlowQueryThreshold(cfg.Query.SlowQueryThreshold)
+	qp.SetBatchTimeouts(cfg.Query.BatchQueryTimeout, cfg.Query.BatchTimeout)
+	qp.SetModels(cfg.Claude.Model, cfg.Claude.AllowedModels)
+	qp.SetTemperature(cfg.Claude.Temperature, cfg.Claude.MinTemperature, cfg.Claude.MaxTemperature)
+	qp.SetModelContextWindows(cfg.Claude.ModelContextWindows, cfg.Claude.DefaultContextWindow)
+	qp.SetSupportedLanguages(cfg.Query.SupportedLanguages)
+	qp.SetContextLimits(cfg.Query.MaxContextEntries, cfg.Query.MaxContextLength)
+	qp.SetContextLabelKeys(cfg.Query.ContextLabelKeys)
+	qp.SetMaxPromptServices(cfg.Query.MaxPromptServices)
+	qp.SetConfirmCostThreshold(cfg.Query.ConfirmCostThreshold)
+	qp.SetMaxInFlightQueries(cfg.Query.MaxInFlightQueries)
+	qp.SetQueryFingerprintRateLimit(cfg.Query.FingerprintRateLimit)
+	qp.SetMetricAliases(cfg.Query.MetricAliases)
+	qp.SetMetricTypeOverrides(metricTypes)
+	qp.SetResultUnitFormats(cfg.Query.ResultUnitFormats)
+	qp.SetDeprecatedMetrics(cfg.Query.DeprecatedMetrics, cfg.Query.DeprecatedMetricMode == "reject")
+	qp.SetMetricDisplayPrefixes(cfg.Query.MetricDisplayPrefixes)
+	if cfg.Query.PinnedExamplesFile != "" {
+		pinnedExamples, err := processor.LoadPinnedExamples(cfg.Query.PinnedExamplesFile)
+		if err != nil {
+			log.Fatal("Invalid pinned examples:", err)
+		}
+		qp.SetPinnedExamples(pinnedExamples)
+	}
+	qp.SetDuplicateServiceMode(cfg.Query.DuplicateServiceMode)
+	qp.SetPromptMetricHelp(cfg.Query.PromptMetricHelp)
+	if err := qp.SetQueryFilter(cfg.Query.QueryDenyPatterns, cfg.Query.QueryAllowPatterns); err != nil {
+		log.Fatal("Invalid query filter:", err)
+	}
+	if err := qp.SetPostProcessorOrder(cfg.Query.PostProcessorOrder); err != nil {
+		log.Fatal("Invalid post-processor order:", err)
+	}
+	qp.SetEvaluationSampling(cfg.Query.EvaluationSampleRate, cfg.Query.EvaluationSampleMaxPerHour)
+	qp.SetEmbeddingCache(cfg.Query.EmbeddingCache)
+	qp.SetRequireLabelMatchers(cfg.Query.RequireLabelMatchers)
+	qp.SetSubqueryLimits(cfg.Query.MaxSubqueryRange, cfg.Query.MinSubqueryStep, cfg.Query.MaxSubqueryDepth)
+	qp.SetAdminOnlyMetadata(cfg.Query.AdminOnlyMetadata)
+	qp.SetStrictJSON(cfg.Server.StrictJSON)
+	if location, err := time.LoadLocation(cfg.Query.Timezone); err == nil {
+		qp.SetTimezone(location)
+	}
+	if discoveryConfig.Enabled {
+		qp.SetDiscoveryPreviewer(discoveryService)
+	}
 
 	// Setup Gin router with authentication
 	router := qp.SetupRoutes(authManager)
 
 	// Add observability middleware
 	router.Use(observability.RecoveryMiddleware(logger))
-	router.Use(observability.RequestLoggingMiddleware(logger))
+	router.Use(observability.RequestLoggingMiddlewareWithConfig(logger, observability.RequestLogConfig{
+		SampleRate:   cfg.Server.RequestLogSampleRate,
+		RedactParams: cfg.Server.RequestLogRedactParams,
+	}))
 	router.Use(observability.MetricsMiddleware())
 
-	// Add metrics endpoint
-	router.GET("/metrics", func(c *gin.Context) {
-		metrics := observability.GetGlobalMetrics().GetAll()
-		c.JSON(200, gin.H{
-			"metrics":   metrics,
-			"timestamp": time.Now(),
-		})
-	})
+	// Add metrics endpoint (Prometheus text or JSON, negotiated via Accept)
+	router.GET("/metrics", observability.MetricsHandler(observability.GetGlobalMetrics()))
 
 	// Note: /health endpoint is registered in processor.SetupRoutes()
 
 	// Add auth handlers for login/logout/user management
 	authHandlers := auth.NewAuthHandlers(authManager)
+	authHandlers.SetStrictJSON(cfg.Server.StrictJSON)
+	if cfg.Auth.GitHubClientID != "" {
+		authHandlers.SetGitHubOAuth(auth.GitHubConfig{
+			ClientID:     cfg.Auth.GitHubClientID,
+			ClientSecret: cfg.Auth.GitHubClientSecret,
+			RedirectURL:  cfg.Auth.GitHubRedirectURL,
+			AllowedOrgs:  cfg.Auth.GitHubAllowedOrgs,
+			RoleMapping:  cfg.Auth.GitHubRoleMapping,
+			URL:          cfg.Auth.GitHubURL,
+			APIURL:       cfg.Auth.GitHubAPIURL,
+		})
+	}
 	authHandlers.SetupRoutes(router.Group("/api/v1"))
 
 	logger.Info(context.Background(), "Query processor starting", map[string]interface{}{
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/config"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/mimir"
+	"github.com/seanankenbruck/observability-ai/internal/observability"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+)
+
+// selftest verifies the query processor's full dependency chain using the
+// same configuration and health checks as the server, printing a pass/fail
+// table and exiting non-zero if any dependency is unusable. It is intended as
+// a readiness gate in CI/CD pipelines.
+func main() {
+	os.Exit(run(context.Background(), os.Stdout))
+}
+
+// run executes the self-test and returns the process exit code
+func run(ctx context.Context, out io.Writer) int {
+	fmt.Fprintln(out, "=== Observability AI Self-Test ===")
+
+	cfg, err := config.NewDefaultLoader().Load(ctx)
+	if err == nil {
+		err = cfg.ValidateWithContext()
+	}
+	if err != nil {
+		// Nothing else can be checked without a valid configuration
+		return report(ctx, out, []observability.SelfTestStep{observability.FailedStep("config", err)})
+	}
+
+	steps := []observability.SelfTestStep{{
+		Name: "config",
+		Check: func(ctx context.Context) *observability.HealthCheck {
+			return &observability.HealthCheck{
+				Status:  observability.HealthStatusHealthy,
+				Message: fmt.Sprintf("Configuration loaded and validated (mode %s)", cfg.Server.GinMode),
+			}
+		},
+	}}
+
+	// The LLM is set up first since the embedding schema check needs the
+	// model's embedding dimension
+	llmClient, llmErr := llm.NewClaudeClient(cfg.Claude.APIKey, cfg.Claude.Model)
+	embeddingDimension := 0
+	if llmErr == nil {
+		if probe, err := llmClient.GetEmbedding(ctx, "embedding dimension probe"); err == nil {
+			embeddingDimension = len(probe)
+		}
+	}
+
+	semanticMapper, err := semantic.NewPostgresMapper(semantic.PostgresConfig{
+		Host:               cfg.Database.Host,
+		Port:               cfg.Database.Port,
+		Database:           cfg.Database.Database,
+		Username:           cfg.Database.Username,
+		Password:           cfg.Database.Password,
+		SSLMode:            cfg.Database.SSLMode,
+		EmbeddingDimension: embeddingDimension,
+		DistanceMetric:     semantic.DistanceMetric(cfg.Database.DistanceMetric),
+		RecencyHalfLife:    cfg.Database.RecencyHalfLife,
+	})
+	if err != nil {
+		steps = append(steps, observability.FailedStep("database", err))
+	} else {
+		defer semanticMapper.Close()
+		steps = append(steps,
+			observability.SelfTestStep{Name: "database", Check: observability.DatabaseHealthCheck(semanticMapper.Ping)},
+			observability.SelfTestStep{Name: "embedding_schema", Check: observability.EmbeddingSchemaHealthCheck(semanticMapper.EmbeddingSchema)},
+		)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     cfg.Redis.Addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+	defer rdb.Close()
+	steps = append(steps, observability.SelfTestStep{
+		Name: "redis",
+		Check: observability.RedisHealthCheck(func(ctx context.Context) error {
+			return rdb.Ping(ctx).Err()
+		}),
+	})
+
+	if llmErr != nil {
+		steps = append(steps, observability.FailedStep("llm_service", llmErr))
+	} else {
+		// Embeddings are computed locally, so the check calls the API itself,
+		// and a deployment that cannot reach it fails
+		steps = append(steps, observability.RequiredStep("llm_service", observability.LLMHealthCheck(llmClient.Ping)))
+	}
+
+	mimirClient, err := mimir.NewClientWithAuth(
+		cfg.Mimir.Endpoint,
+		mimir.AuthConfig{
+			Type:        cfg.Mimir.AuthType,
+			Username:    cfg.Mimir.Username,
+			Password:    cfg.Mimir.Password,
+			BearerToken: cfg.Mimir.BearerToken,
+			TenantID:    cfg.Mimir.TenantID,
+			TLSCertFile: cfg.Mimir.TLSCertFile,
+			TLSKeyFile:  cfg.Mimir.TLSKeyFile,
+			TLSCAFile:   cfg.Mimir.TLSCAFile,
+			TLSCertPEM:  cfg.Mimir.TLSCertPEM,
+			TLSKeyPEM:   cfg.Mimir.TLSKeyPEM,
+			TLSCAPEM:    cfg.Mimir.TLSCAPEM,
+		},
+		cfg.Mimir.Timeout,
+		mimir.BackendType(cfg.Mimir.BackendType),
+	)
+	if err != nil {
+		steps = append(steps, observability.FailedStep("mimir", err))
+	} else {
+		steps = append(steps, observability.SelfTestStep{Name: "mimir", Check: observability.MimirHealthCheck(mimirClient.TestConnection)})
+	}
+
+	return report(ctx, out, steps)
+}
+
+// report runs the steps, prints the table and returns the exit code
+func report(ctx context.Context, out io.Writer, steps []observability.SelfTestStep) int {
+	result := observability.RunSelfTest(ctx, steps)
+	if err := result.WriteTable(out); err != nil {
+		return 1
+	}
+	return result.ExitCode()
+}
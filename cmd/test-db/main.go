@@ -315,6 +315,32 @@ func testQueryEmbeddings(ctx context.Context, mapper semantic.Mapper) error {
 		fmt.Printf("    - Similarity %.3f: %s\n", sq.Similarity, sq.Query)
 	}
 
+	// Test batched similarity search against individual lookups
+	var searchEmbeddings [][]float32
+	for _, tq := range testQueries {
+		searchEmbeddings = append(searchEmbeddings, tq.embedding)
+	}
+	batchResults, err := mapper.FindSimilarQueriesBatch(ctx, searchEmbeddings)
+	if err != nil {
+		return fmt.Errorf("failed to find similar queries in batch: %w", err)
+	}
+	for i, embedding := range searchEmbeddings {
+		individual, err := mapper.FindSimilarQueries(ctx, embedding)
+		if err != nil {
+			return fmt.Errorf("failed to find similar queries: %w", err)
+		}
+		if len(batchResults[i]) != len(individual) {
+			return fmt.Errorf("batch search returned %d similar queries for %q, individual search returned %d",
+				len(batchResults[i]), testQueries[i].query, len(individual))
+		}
+		for j := range individual {
+			if batchResults[i][j].ID != individual[j].ID {
+				return fmt.Errorf("batch search result %d for %q differs from individual search", j, testQueries[i].query)
+			}
+		}
+	}
+	fmt.Printf("  Batch search matched individual searches for %d embeddings\n", len(searchEmbeddings))
+
 	return nil
 }
 
@@ -0,0 +1,318 @@
+// internal/auth/github.go
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/events"
+)
+
+const (
+	defaultGitHubURL    = "https://github.com"
+	defaultGitHubAPIURL = "https://api.github.com"
+
+	// githubStateCookie holds the OAuth state between login and callback
+	githubStateCookie = "github_oauth_state"
+	githubStateMaxAge = 10 * 60 // seconds
+
+	// githubDefaultRole is granted to members of an allowed organization
+	// that no role mapping matches
+	githubDefaultRole = "user"
+)
+
+// GitHubConfig configures login with a GitHub OAuth app
+type GitHubConfig struct {
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback URL registered with the OAuth app, e.g.
+	// https://observability.example.com/api/v1/auth/github/callback
+	RedirectURL string
+	// AllowedOrgs are the organizations whose members may log in; users in
+	// none of them are rejected
+	AllowedOrgs []string
+	// RoleMapping maps "org/team" or "org" to the role granted to its
+	// members. Users matching no entry get the "user" role.
+	RoleMapping map[string]string
+	// URL and APIURL are the GitHub web and API base URLs, defaulting to
+	// github.com; set them for GitHub Enterprise Server
+	URL    string
+	APIURL string
+}
+
+// githubProvider logs users in with GitHub OAuth
+type githubProvider struct {
+	config GitHubConfig
+	client *http.Client
+}
+
+// githubIdentity is a GitHub user and their memberships
+type githubIdentity struct {
+	ID    string
+	Login string
+	Email string
+	Orgs  []string // Organization logins
+	Teams []string // "org/team-slug"
+}
+
+// SetGitHubOAuth enables login with GitHub at /auth/github/login. It must be
+// called before SetupRoutes.
+func (ah *AuthHandlers) SetGitHubOAuth(config GitHubConfig) {
+	if config.URL == "" {
+		config.URL = defaultGitHubURL
+	}
+	if config.APIURL == "" {
+		config.APIURL = defaultGitHubAPIURL
+	}
+	config.URL = strings.TrimSuffix(config.URL, "/")
+	config.APIURL = strings.TrimSuffix(config.APIURL, "/")
+
+	// Organization and team names are case-insensitive on GitHub
+	mapping := make(map[string]string, len(config.RoleMapping))
+	for group, role := range config.RoleMapping {
+		mapping[strings.ToLower(group)] = role
+	}
+	config.RoleMapping = mapping
+
+	ah.github = &githubProvider{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// GitHubLogin redirects to GitHub to authorize the OAuth app
+func (ah *AuthHandlers) GitHubLogin(c *gin.Context) {
+	state := generateRandomString(16)
+	c.SetCookie(githubStateCookie, state, githubStateMaxAge, "/", "", ah.github.secureCookies(), true)
+
+	params := url.Values{
+		"client_id":    {ah.github.config.ClientID},
+		"redirect_uri": {ah.github.config.RedirectURL},
+		"scope":        {"read:org"},
+		"state":        {state},
+	}
+	c.Redirect(http.StatusFound, ah.github.config.URL+"/login/oauth/authorize?"+params.Encode())
+}
+
+// GitHubCallback completes a GitHub login: it exchanges the code for a token,
+// checks the user's organization membership, maps their teams to roles and
+// creates a session
+func (ah *AuthHandlers) GitHubCallback(c *gin.Context) {
+	state, err := c.Cookie(githubStateCookie)
+	c.SetCookie(githubStateCookie, "", -1, "/", "", ah.github.secureCookies(), true)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		enhancedErr := errors.NewInvalidInputError("state", "the OAuth state does not match the login request")
+		c.JSON(http.StatusBadRequest, formatAuthErrorResponse(enhancedErr))
+		return
+	}
+	code := c.Query("code")
+	if code == "" {
+		enhancedErr := errors.NewInvalidInputError("code", "GitHub did not return an authorization code")
+		c.JSON(http.StatusBadRequest, formatAuthErrorResponse(enhancedErr))
+		return
+	}
+
+	identity, err := ah.github.identify(c.Request.Context(), code)
+	if err != nil {
+		ah.authManager.publishAuthEvent(events.TypeAuthFailure, "github", "", "identity lookup failed")
+		enhancedErr := errors.Wrap(err, errors.ErrCodeInvalidCredentials, "GitHub login failed").
+			WithDetails("The GitHub authorization could not be verified").
+			WithSuggestion("Please try logging in with GitHub again.")
+		c.JSON(http.StatusUnauthorized, formatAuthErrorResponse(enhancedErr))
+		return
+	}
+
+	if !ah.github.allowed(identity) {
+		ah.authManager.publishAuthEvent(events.TypeAuthFailure, "github", identity.Login, "not in an allowed organization")
+		enhancedErr := errors.New(errors.ErrCodeInsufficientPerms, "GitHub user is not a member of an allowed organization").
+			WithDetails(fmt.Sprintf("Login is limited to members of: %s", strings.Join(ah.github.config.AllowedOrgs, ", "))).
+			WithSuggestion("Ask an organization owner for access, or grant the OAuth app access to your organization.")
+		c.JSON(http.StatusForbidden, formatAuthErrorResponse(enhancedErr))
+		return
+	}
+
+	user, err := ah.authManager.LoginExternalUser("github", identity.ID, identity.Login, identity.Email, ah.github.roles(identity))
+	if err != nil {
+		ah.authManager.publishAuthEvent(events.TypeAuthFailure, "github", identity.Login, err.Error())
+		enhancedErr := errors.Wrap(err, errors.ErrCodeInvalidCredentials, "GitHub login failed")
+		c.JSON(http.StatusUnauthorized, formatAuthErrorResponse(enhancedErr))
+		return
+	}
+
+	sessionID, err := ah.authManager.CreateSession(user.ID)
+	if err != nil {
+		enhancedErr := errors.NewSessionCreationError(err)
+		c.JSON(http.StatusInternalServerError, formatAuthErrorResponse(enhancedErr))
+		return
+	}
+
+	// Set session cookie
+	c.SetCookie(
+		"session_id",
+		sessionID,
+		int(ah.authManager.config.SessionExpiry.Seconds()),
+		"/",
+		"",
+		ah.github.secureCookies(),
+		true, // httpOnly
+	)
+
+	ah.authManager.publishAuthEvent(events.TypeAuthSuccess, "github", user.Username, "")
+
+	// Back to the web UI, which is now logged in
+	c.Redirect(http.StatusFound, "/")
+}
+
+// secureCookies reports whether cookies are limited to HTTPS, which they are
+// when GitHub redirects back to an HTTPS callback
+func (gp *githubProvider) secureCookies() bool {
+	return strings.HasPrefix(strings.ToLower(gp.config.RedirectURL), "https://")
+}
+
+// allowed reports whether the user belongs to an allowed organization
+func (gp *githubProvider) allowed(identity *githubIdentity) bool {
+	for _, org := range identity.Orgs {
+		for _, allowed := range gp.config.AllowedOrgs {
+			if strings.EqualFold(org, allowed) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// roles returns the roles mapped to the user's teams and organizations,
+// sorted, or the default role when none is mapped
+func (gp *githubProvider) roles(identity *githubIdentity) []string {
+	seen := make(map[string]bool)
+	var roles []string
+	for _, group := range append(append([]string{}, identity.Teams...), identity.Orgs...) {
+		if role, ok := gp.config.RoleMapping[strings.ToLower(group)]; ok && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 {
+		return []string{githubDefaultRole}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// identify exchanges an authorization code for a token and looks up the
+// user with it. Only the first 100 organizations and teams are read.
+func (gp *githubProvider) identify(ctx context.Context, code string) (*githubIdentity, error) {
+	token, err := gp.exchangeCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Email string `json:"email"`
+	}
+	if err := gp.getJSON(ctx, token, "/user", &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 || user.Login == "" {
+		return nil, fmt.Errorf("GitHub returned no user")
+	}
+
+	var orgs []struct {
+		Login string `json:"login"`
+	}
+	if err := gp.getJSON(ctx, token, "/user/orgs?per_page=100", &orgs); err != nil {
+		return nil, err
+	}
+	var teams []struct {
+		Slug         string `json:"slug"`
+		Organization struct {
+			Login string `json:"login"`
+		} `json:"organization"`
+	}
+	if err := gp.getJSON(ctx, token, "/user/teams?per_page=100", &teams); err != nil {
+		return nil, err
+	}
+
+	identity := &githubIdentity{ID: strconv.FormatInt(user.ID, 10), Login: user.Login, Email: user.Email}
+	for _, org := range orgs {
+		identity.Orgs = append(identity.Orgs, org.Login)
+	}
+	for _, team := range teams {
+		identity.Teams = append(identity.Teams, team.Organization.Login+"/"+team.Slug)
+	}
+	return identity, nil
+}
+
+// exchangeCode exchanges an authorization code for an access token
+func (gp *githubProvider) exchangeCode(ctx context.Context, code string) (string, error) {
+	form := url.Values{
+		"client_id":     {gp.config.ClientID},
+		"client_secret": {gp.config.ClientSecret},
+		"code":          {code},
+		"redirect_uri":  {gp.config.RedirectURL},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, gp.config.URL+"/login/oauth/access_token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := gp.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if token.Error != "" {
+		return "", fmt.Errorf("code exchange failed: %s: %s", token.Error, token.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", fmt.Errorf("code exchange failed with status %d", resp.StatusCode)
+	}
+	return token.AccessToken, nil
+}
+
+// getJSON decodes the response of a GitHub API request made with the token
+func (gp *githubProvider) getJSON(ctx context.Context, token, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gp.config.APIURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := gp.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("GitHub API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GitHub API %s returned status %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode GitHub API response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+// internal/auth/github_test.go
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockGitHubUser is a user of the mock GitHub server
+type mockGitHubUser struct {
+	id    int64
+	login string
+	orgs  []string
+	teams []string // "org/team-slug"
+}
+
+// newMockGitHub starts a mock GitHub OAuth and API server. Each user logs in
+// with the authorization code "code-<login>".
+func newMockGitHub(t *testing.T, users ...mockGitHubUser) *httptest.Server {
+	byToken := make(map[string]mockGitHubUser)
+	for _, user := range users {
+		byToken["token-"+user.login] = user
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.FormValue("client_id") != "client-id" || r.FormValue("client_secret") != "client-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		login := strings.TrimPrefix(r.FormValue("code"), "code-")
+		if _, ok := byToken["token-"+login]; !ok {
+			json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code", "error_description": "The code is incorrect"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "token-" + login, "token_type": "bearer"})
+	})
+	authenticated := func(handler func(w http.ResponseWriter, user mockGitHubUser)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			user, ok := byToken[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			handler(w, user)
+		}
+	}
+	mux.HandleFunc("/api/user", authenticated(func(w http.ResponseWriter, user mockGitHubUser) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": user.id, "login": user.login, "email": user.login + "@example.com"})
+	}))
+	mux.HandleFunc("/api/user/orgs", authenticated(func(w http.ResponseWriter, user mockGitHubUser) {
+		orgs := []map[string]string{}
+		for _, org := range user.orgs {
+			orgs = append(orgs, map[string]string{"login": org})
+		}
+		json.NewEncoder(w).Encode(orgs)
+	}))
+	mux.HandleFunc("/api/user/teams", authenticated(func(w http.ResponseWriter, user mockGitHubUser) {
+		teams := []map[string]interface{}{}
+		for _, team := range user.teams {
+			org, slug, _ := strings.Cut(team, "/")
+			teams = append(teams, map[string]interface{}{"slug": slug, "organization": map[string]string{"login": org}})
+		}
+		json.NewEncoder(w).Encode(teams)
+	}))
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// setupGitHubRouter creates a test router with GitHub login enabled against
+// the mock server
+func setupGitHubRouter(am *AuthManager, server *httptest.Server) *gin.Engine {
+	handlers := NewAuthHandlers(am)
+	handlers.SetGitHubOAuth(GitHubConfig{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://localhost:8080/api/v1/auth/github/callback",
+		AllowedOrgs:  []string{"acme"},
+		RoleMapping:  map[string]string{"acme/SRE": "admin", "acme": "user"},
+		URL:          server.URL,
+		APIURL:       server.URL + "/api",
+	})
+	r := gin.New()
+	handlers.SetupRoutes(r.Group("/api/v1"))
+	return r
+}
+
+// githubLogin starts a GitHub login and completes its callback with the code,
+// returning the callback response
+func githubLogin(t *testing.T, r *gin.Engine, code string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/auth/github/login", nil))
+	require.Equal(t, http.StatusFound, w.Code)
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/login/oauth/authorize", location.Path)
+	assert.Equal(t, "client-id", location.Query().Get("client_id"))
+	assert.Equal(t, "read:org", location.Query().Get("scope"))
+	state := location.Query().Get("state")
+	require.NotEmpty(t, state)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/github/callback?code="+code+"&state="+state, nil)
+	for _, cookie := range w.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// sessionCookie returns the session ID set by a response, or "" if none is
+func sessionCookie(w *httptest.ResponseRecorder) string {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "session_id" && cookie.Value != "" {
+			// gin escapes cookie values
+			sessionID, _ := url.QueryUnescape(cookie.Value)
+			return sessionID
+		}
+	}
+	return ""
+}
+
+// TestGitHubLogin tests that members of an allowed organization are logged
+// in with the roles mapped to their teams, and are the same user on their
+// next login
+func TestGitHubLogin(t *testing.T) {
+	server := newMockGitHub(t,
+		mockGitHubUser{id: 1, login: "octo", orgs: []string{"acme"}, teams: []string{"acme/sre"}},
+		mockGitHubUser{id: 2, login: "mona", orgs: []string{"Acme", "other"}, teams: []string{"other/sre"}},
+		// Taken by a local user, so named admin@github
+		mockGitHubUser{id: 3, login: "admin", orgs: []string{"acme"}},
+	)
+	am := NewTestAuthManager(AuthConfig{JWTSecret: "test-secret"})
+	r := setupGitHubRouter(am, server)
+
+	w := githubLogin(t, r, "code-octo")
+	require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+	assert.Equal(t, "/", w.Header().Get("Location"))
+	sessionID := sessionCookie(w)
+	require.NotEmpty(t, sessionID)
+
+	user, err := am.ValidateSession(sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, "octo", user.Username)
+	assert.Equal(t, "octo@example.com", user.Email)
+	assert.Equal(t, []string{"admin", "user"}, user.Roles, "team and organization roles are combined")
+	assert.Equal(t, "1", user.Metadata["github_id"])
+
+	// The next login finds the same user
+	w = githubLogin(t, r, "code-octo")
+	require.Equal(t, http.StatusFound, w.Code)
+	again, err := am.ValidateSession(sessionCookie(w))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, again.ID)
+
+	// Teams of other organizations are not mapped
+	w = githubLogin(t, r, "code-mona")
+	require.Equal(t, http.StatusFound, w.Code)
+	mona, err := am.ValidateSession(sessionCookie(w))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user"}, mona.Roles)
+
+	// A GitHub login never takes over a local user with the same name
+	w = githubLogin(t, r, "code-admin")
+	require.Equal(t, http.StatusFound, w.Code)
+	githubAdmin, err := am.ValidateSession(sessionCookie(w))
+	require.NoError(t, err)
+	assert.Equal(t, "admin@github", githubAdmin.Username)
+	assert.NotContains(t, githubAdmin.Roles, "admin")
+}
+
+// TestGitHubLoginAcrossReplicas tests that a GitHub session created by one
+// replica is valid on another replica sharing its Redis, as the same user
+func TestGitHubLoginAcrossReplicas(t *testing.T) {
+	server := newMockGitHub(t, mockGitHubUser{id: 5, login: "octo", orgs: []string{"acme"}, teams: []string{"acme/sre"}})
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	replicaA := NewAuthManager(AuthConfig{JWTSecret: "test-secret"}, session.NewManager(rdb, time.Hour))
+	replicaB := NewAuthManager(AuthConfig{JWTSecret: "test-secret"}, session.NewManager(rdb, time.Hour))
+
+	w := githubLogin(t, setupGitHubRouter(replicaA, server), "code-octo")
+	require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+	sessionID := sessionCookie(w)
+	user, err := replicaA.ValidateSession(sessionID)
+	require.NoError(t, err)
+
+	restored, err := replicaB.ValidateSession(sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, restored.ID)
+	assert.Equal(t, "octo", restored.Username)
+	assert.Equal(t, []string{"admin", "user"}, restored.Roles)
+	assert.Equal(t, "5", restored.Metadata["github_id"])
+
+	// A later login handled by the second replica finds the same user
+	w = githubLogin(t, setupGitHubRouter(replicaB, server), "code-octo")
+	require.Equal(t, http.StatusFound, w.Code)
+	again, err := replicaB.ValidateSession(sessionCookie(w))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, again.ID)
+
+	// Sessions of local users are not rebuilt
+	local, err := replicaA.CreateUser("local", "local@example.com", []string{"user"})
+	require.NoError(t, err)
+	localSession, err := replicaA.CreateSession(local.ID)
+	require.NoError(t, err)
+	_, err = replicaB.ValidateSession(localSession)
+	assert.Error(t, err)
+}
+
+// TestGitHubCookiesSecure tests that cookies are limited to HTTPS when the
+// callback URL is served over HTTPS
+func TestGitHubCookiesSecure(t *testing.T) {
+	server := newMockGitHub(t, mockGitHubUser{id: 6, login: "mona", orgs: []string{"acme"}})
+	am := NewTestAuthManager(AuthConfig{JWTSecret: "test-secret"})
+	handlers := NewAuthHandlers(am)
+	handlers.SetGitHubOAuth(GitHubConfig{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "https://observability.example.com/api/v1/auth/github/callback",
+		AllowedOrgs:  []string{"acme"},
+		URL:          server.URL,
+		APIURL:       server.URL + "/api",
+	})
+	r := gin.New()
+	handlers.SetupRoutes(r.Group("/api/v1"))
+
+	w := githubLogin(t, r, "code-mona")
+	require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+	cookies := w.Result().Cookies()
+	require.NotEmpty(t, cookies)
+	for _, cookie := range cookies {
+		assert.True(t, cookie.Secure, cookie.Name)
+	}
+}
+
+// TestGitHubLoginRejected tests that users outside the allowed organizations,
+// invalid codes and mismatched states are rejected without a session
+func TestGitHubLoginRejected(t *testing.T) {
+	server := newMockGitHub(t, mockGitHubUser{id: 4, login: "stranger", orgs: []string{"other"}, teams: []string{"other/sre"}})
+	am := NewTestAuthManager(AuthConfig{JWTSecret: "test-secret"})
+	r := setupGitHubRouter(am, server)
+
+	w := githubLogin(t, r, "code-stranger")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), string(errors.ErrCodeInsufficientPerms))
+	assert.Empty(t, sessionCookie(w))
+	_, err := am.GetUserByUsername("stranger")
+	assert.Error(t, err, "rejected users are not created")
+
+	w = githubLogin(t, r, "code-unknown")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, sessionCookie(w))
+
+	// A callback without the state of a login request is rejected
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/github/callback?code=code-stranger&state=forged", nil)
+	req.AddCookie(&http.Cookie{Name: githubStateCookie, Value: "expected"})
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, sessionCookie(w))
+}
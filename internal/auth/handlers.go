@@ -2,18 +2,25 @@
 package auth
 
 import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/events"
 )
 
 // AuthHandlers provides HTTP handlers for authentication endpoints
 type AuthHandlers struct {
 	authManager *AuthManager
+	github      *githubProvider // nil when GitHub login is disabled
+	strictJSON  bool            // Reject request bodies with unknown fields
 }
 
 // NewAuthHandlers creates new auth handlers
@@ -23,6 +30,29 @@ func NewAuthHandlers(authManager *AuthManager) *AuthHandlers {
 	}
 }
 
+// SetStrictJSON rejects JSON request bodies with fields the endpoint does not
+// accept, such as misspelled ones, instead of ignoring them
+func (ah *AuthHandlers) SetStrictJSON(strict bool) {
+	ah.strictJSON = strict
+}
+
+// bindJSON decodes and validates the JSON request body into obj, rejecting
+// unknown fields in strict mode
+func (ah *AuthHandlers) bindJSON(c *gin.Context, obj interface{}) error {
+	if !ah.strictJSON {
+		return c.ShouldBindJSON(obj)
+	}
+	if c.Request == nil || c.Request.Body == nil {
+		return fmt.Errorf("invalid request")
+	}
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
+
 // SetupRoutes sets up authentication routes
 func (ah *AuthHandlers) SetupRoutes(r *gin.RouterGroup) {
 	// Auth endpoints
@@ -31,6 +61,10 @@ func (ah *AuthHandlers) SetupRoutes(r *gin.RouterGroup) {
 	r.POST("/auth/logout", ah.Logout)
 	r.GET("/auth/me", ah.authManager.Middleware(), ah.GetCurrentUser)
 	r.GET("/auth/status", ah.GetAuthStatus)
+	if ah.github != nil {
+		r.GET("/auth/github/login", ah.GitHubLogin)
+		r.GET("/auth/github/callback", ah.GitHubCallback)
+	}
 
 	// API key endpoints (require authentication)
 	r.GET("/api-keys", ah.authManager.Middleware(), ah.ListAPIKeys)
@@ -44,6 +78,7 @@ func (ah *AuthHandlers) SetupRoutes(r *gin.RouterGroup) {
 		admin.GET("/users", ah.ListUsers)
 		admin.POST("/users", ah.CreateUser)
 		admin.GET("/rate-limit-stats", ah.GetRateLimitStats)
+		admin.POST("/cleanup", ah.Cleanup)
 	}
 }
 
@@ -70,8 +105,8 @@ type RegisterRequest struct {
 // Register handles user registration
 func (ah *AuthHandlers) Register(c *gin.Context) {
 	var req RegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		enhancedErr := errors.NewInvalidInputError("request body", err.Error())
+	if err := ah.bindJSON(c, &req); err != nil {
+		enhancedErr := errors.NewRequestBodyError(err)
 		c.JSON(http.StatusBadRequest, formatAuthErrorResponse(enhancedErr))
 		return
 	}
@@ -117,8 +152,8 @@ func (ah *AuthHandlers) Register(c *gin.Context) {
 // Login handles user login
 func (ah *AuthHandlers) Login(c *gin.Context) {
 	var req LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		enhancedErr := errors.NewInvalidInputError("request body", err.Error())
+	if err := ah.bindJSON(c, &req); err != nil {
+		enhancedErr := errors.NewRequestBodyError(err)
 		c.JSON(http.StatusBadRequest, formatAuthErrorResponse(enhancedErr))
 		return
 	}
@@ -126,6 +161,7 @@ func (ah *AuthHandlers) Login(c *gin.Context) {
 	// Get user by username
 	user, err := ah.authManager.GetUserByUsername(req.Username)
 	if err != nil {
+		ah.authManager.publishAuthEvent(events.TypeAuthFailure, "login", req.Username, "unknown user")
 		enhancedErr := errors.NewInvalidCredentialsError()
 		c.JSON(http.StatusUnauthorized, formatAuthErrorResponse(enhancedErr))
 		return
@@ -133,6 +169,7 @@ func (ah *AuthHandlers) Login(c *gin.Context) {
 
 	// Validate password
 	if !ah.authManager.ValidatePassword(user, req.Password) {
+		ah.authManager.publishAuthEvent(events.TypeAuthFailure, "login", req.Username, "invalid password")
 		enhancedErr := errors.NewInvalidCredentialsError()
 		c.JSON(http.StatusUnauthorized, formatAuthErrorResponse(enhancedErr))
 		return
@@ -157,6 +194,8 @@ func (ah *AuthHandlers) Login(c *gin.Context) {
 		true,  // httpOnly
 	)
 
+	ah.authManager.publishAuthEvent(events.TypeAuthSuccess, "login", user.Username, "")
+
 	// Return response (no token exposed to frontend)
 	c.JSON(http.StatusOK, LoginResponse{
 		User:      user,
@@ -200,6 +239,7 @@ func (ah *AuthHandlers) GetAuthStatus(c *gin.Context) {
 		"rate_limit":             ah.authManager.config.RateLimit,
 		"jwt_expiry":             ah.authManager.config.JWTExpiry.String(),
 		"session_expiry":         ah.authManager.config.SessionExpiry.String(),
+		"github_login":           ah.github != nil,
 	}
 
 	// Check if user is authenticated
@@ -218,23 +258,32 @@ type CreateAPIKeyRequest struct {
 	Name        string   `json:"name" binding:"required"`
 	Permissions []string `json:"permissions"`
 	RateLimit   int      `json:"rate_limit"`
-	ExpiresIn   string   `json:"expires_in"` // e.g., "30d", "1y", "720h"
+	ExpiresIn   string   `json:"expires_in"`   // e.g., "30d", "1y", "720h"
+	MimirTenant string   `json:"mimir_tenant"` // Restricts the key to one Mimir tenant
 }
 
 // CreateAPIKeyResponse represents the response with a new API key
 type CreateAPIKeyResponse struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Key       string    `json:"key"` // Only shown once!
-	ExpiresAt time.Time `json:"expires_at"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Key         string    `json:"key,omitempty"` // Only shown once!
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	MimirTenant string    `json:"mimir_tenant,omitempty"`
+	Replayed    bool      `json:"replayed,omitempty"` // A retry returned the key created earlier
 }
 
-// CreateAPIKey creates a new API key for the current user
+// idempotencyKeyHeader carries a client-supplied key that makes API key
+// creation safe to retry
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// CreateAPIKey creates a new API key for the current user. Requests with an
+// Idempotency-Key header create at most one key per header value; retries
+// return the original key's metadata with 200 OK but not the key itself.
 func (ah *AuthHandlers) CreateAPIKey(c *gin.Context) {
 	var req CreateAPIKeyRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		enhancedErr := errors.NewInvalidInputError("request body", err.Error())
+	if err := ah.bindJSON(c, &req); err != nil {
+		enhancedErr := errors.NewRequestBodyError(err)
 		c.JSON(http.StatusBadRequest, formatAuthErrorResponse(enhancedErr))
 		return
 	}
@@ -256,6 +305,31 @@ func (ah *AuthHandlers) CreateAPIKey(c *gin.Context) {
 		return
 	}
 
+	// A key created with an API key could outlive or widen that key's grant,
+	// so only sessions, JWT tokens and admins may create keys
+	if AuthenticatedByAPIKey(c) {
+		if user, _ := GetCurrentUser(c); user == nil || !ah.authManager.hasRole(user, "admin") {
+			enhancedErr := errors.New(errors.ErrCodeInsufficientPerms, "API keys cannot create API keys").
+				WithDetails("Creating an API key requires a login session or the admin role").
+				WithSuggestion("Log in and create the key from your session.")
+			c.JSON(http.StatusForbidden, formatAuthErrorResponse(enhancedErr))
+			return
+		}
+	}
+
+	// A key created with a tenant-bound key inherits its binding
+	tenant := req.MimirTenant
+	if bound, ok := ah.authManager.BoundTenant(c); ok {
+		if tenant != "" && tenant != bound {
+			enhancedErr := errors.New(errors.ErrCodeInsufficientPerms, "API key is bound to another Mimir tenant").
+				WithDetails(fmt.Sprintf("Keys created with this API key are bound to tenant %q", bound)).
+				WithSuggestion("Omit mimir_tenant or use the tenant of your API key.")
+			c.JSON(http.StatusForbidden, formatAuthErrorResponse(enhancedErr))
+			return
+		}
+		tenant = bound
+	}
+
 	// Set default rate limit if not provided
 	rateLimit := req.RateLimit
 	if rateLimit == 0 {
@@ -263,13 +337,22 @@ func (ah *AuthHandlers) CreateAPIKey(c *gin.Context) {
 	}
 
 	// Create API key
-	apiKey, err := ah.authManager.CreateAPIKey(
+	apiKey, created, err := ah.authManager.CreateAPIKeyIdempotent(
 		userID,
+		c.GetHeader(idempotencyKeyHeader),
 		req.Name,
 		req.Permissions,
 		rateLimit,
 		expiresIn,
+		tenant,
 	)
+	if stderrors.Is(err, ErrIdempotencyKeyReused) {
+		enhancedErr := errors.Wrap(err, errors.ErrCodeInvalidInput, "Idempotency key already used").
+			WithDetails("An API key with a different name, permissions, rate limit, expiry or tenant was already created with this Idempotency-Key").
+			WithSuggestion("Use a new Idempotency-Key for each distinct API key.")
+		c.JSON(http.StatusConflict, formatAuthErrorResponse(enhancedErr))
+		return
+	}
 	if err != nil {
 		enhancedErr := errors.Wrap(err, errors.ErrCodeInvalidInput, "Failed to create API key").
 			WithDetails("Unable to create the API key with the provided parameters").
@@ -278,13 +361,27 @@ func (ah *AuthHandlers) CreateAPIKey(c *gin.Context) {
 		return
 	}
 
+	// A retry returns the original key, which was bound when it was created
+	if !created {
+		c.JSON(http.StatusOK, CreateAPIKeyResponse{
+			ID:          apiKey.ID,
+			Name:        apiKey.Name,
+			ExpiresAt:   apiKey.ExpiresAt,
+			CreatedAt:   apiKey.CreatedAt,
+			MimirTenant: apiKey.MimirTenant,
+			Replayed:    true,
+		})
+		return
+	}
+
 	// Return the key (only time it's shown in plaintext!)
 	c.JSON(http.StatusCreated, CreateAPIKeyResponse{
-		ID:        apiKey.ID,
-		Name:      apiKey.Name,
-		Key:       apiKey.Key, // Important: only shown once!
-		ExpiresAt: apiKey.ExpiresAt,
-		CreatedAt: apiKey.CreatedAt,
+		ID:          apiKey.ID,
+		Name:        apiKey.Name,
+		Key:         apiKey.Key, // Important: only shown once!
+		ExpiresAt:   apiKey.ExpiresAt,
+		CreatedAt:   apiKey.CreatedAt,
+		MimirTenant: apiKey.MimirTenant,
 	})
 }
 
@@ -309,11 +406,25 @@ func (ah *AuthHandlers) ListAPIKeys(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
 }
 
-// RevokeAPIKey revokes an API key
+// RevokeAPIKey revokes an API key. Users may only revoke their own keys (admins
+// may revoke any key); a key owned by someone else is reported as not found so
+// key IDs cannot be probed.
 func (ah *AuthHandlers) RevokeAPIKey(c *gin.Context) {
 	keyID := c.Param("id")
 
-	err := ah.authManager.RevokeAPIKey(keyID)
+	user, exists := GetCurrentUser(c)
+	if !exists {
+		enhancedErr := errors.NewNotAuthenticatedError()
+		c.JSON(http.StatusUnauthorized, formatAuthErrorResponse(enhancedErr))
+		return
+	}
+
+	var err error
+	if ah.authManager.hasRole(user, "admin") {
+		err = ah.authManager.RevokeAPIKey(keyID)
+	} else {
+		err = ah.authManager.RevokeUserAPIKey(user.ID, keyID)
+	}
 	if err != nil {
 		enhancedErr := errors.New(errors.ErrCodeInvalidInput, "Failed to revoke API key").
 			WithDetails("The specified API key could not be found or has already been revoked").
@@ -336,8 +447,8 @@ type CreateUserRequest struct {
 // CreateUser creates a new user (admin only)
 func (ah *AuthHandlers) CreateUser(c *gin.Context) {
 	var req CreateUserRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		enhancedErr := errors.NewInvalidInputError("request body", err.Error())
+	if err := ah.bindJSON(c, &req); err != nil {
+		enhancedErr := errors.NewRequestBodyError(err)
 		c.JSON(http.StatusBadRequest, formatAuthErrorResponse(enhancedErr))
 		return
 	}
@@ -372,6 +483,24 @@ func (ah *AuthHandlers) GetRateLimitStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
+// Cleanup immediately removes expired sessions and API keys (admin only)
+func (ah *AuthHandlers) Cleanup(c *gin.Context) {
+	result, err := ah.authManager.CleanupExpired()
+	if err != nil {
+		enhancedErr := errors.Wrap(err, errors.ErrCodeCacheWrite, "Failed to clean up expired sessions").
+			WithDetails("Expired API keys were removed, but session storage could not be fully cleaned").
+			WithSuggestion("Check Redis connectivity and try again.").
+			WithMetadata("retryable", true).
+			WithDependency(errors.DependencyCache)
+		response := formatAuthErrorResponse(enhancedErr)
+		response["removed"] = result
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
 // Helper functions
 
 // parseDuration parses duration strings like "30d", "1y", "720h"
@@ -6,11 +6,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/events"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -60,6 +62,7 @@ func TestSetupRoutes(t *testing.T) {
 		"GET /api/v1/admin/users",
 		"POST /api/v1/admin/users",
 		"GET /api/v1/admin/rate-limit-stats",
+		"POST /api/v1/admin/cleanup",
 	}
 
 	routeMap := make(map[string]bool)
@@ -194,6 +197,24 @@ func TestRegister(t *testing.T) {
 	}
 }
 
+// TestRegisterStrictJSON tests that a misspelled field is rejected by name
+// when unknown fields are disallowed
+func TestRegisterStrictJSON(t *testing.T) {
+	handlers := NewAuthHandlers(NewTestAuthManager(AuthConfig{JWTSecret: "test-secret"}))
+	handlers.SetStrictJSON(true)
+	r := gin.New()
+	handlers.SetupRoutes(r.Group("/api/v1"))
+
+	body := `{"username": "newuser", "email": "newuser@example.com", "pasword": "password123"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Field 'pasword' is not recognized")
+}
+
 // TestLogin tests user login with session cookie
 func TestLogin(t *testing.T) {
 	tests := []struct {
@@ -256,6 +277,27 @@ func TestLogin(t *testing.T) {
 				assert.Contains(t, response, "error")
 			},
 		},
+		{
+			name: "github user cannot log in with a password",
+			setupUser: func(am *AuthManager) *User {
+				user, _ := am.LoginExternalUser("github", "12345", "octocat", "octocat@example.com", []string{"admin"})
+				return user
+			},
+			requestBody: LoginRequest{
+				Username: "octocat",
+				Password: "x",
+			},
+			expectedStatus: http.StatusUnauthorized,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response map[string]interface{}
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				require.NoError(t, err)
+				assert.Contains(t, response, "error")
+				for _, cookie := range w.Result().Cookies() {
+					assert.NotEqual(t, "session_id", cookie.Name, "no session should be created")
+				}
+			},
+		},
 		{
 			name: "user not found",
 			setupUser: func(am *AuthManager) *User {
@@ -316,6 +358,35 @@ func TestLogin(t *testing.T) {
 	}
 }
 
+// TestLoginPublishesEvents tests that login outcomes are published to the event bus
+func TestLoginPublishesEvents(t *testing.T) {
+	am := NewTestAuthManager(AuthConfig{JWTSecret: "test-secret"})
+	bus := events.NewBus()
+	am.SetEventBus(bus)
+	sub := bus.Subscribe(events.TypeAuthSuccess, events.TypeAuthFailure)
+	defer bus.Unsubscribe(sub)
+
+	r := setupTestRouter(am)
+	_, err := am.CreateUserWithPassword("testuser", "test@example.com", "password123", []string{"user"})
+	require.NoError(t, err)
+
+	for _, password := range []string{"wrongpassword", "password123"} {
+		body, _ := json.Marshal(LoginRequest{Username: "testuser", Password: password})
+		req, _ := http.NewRequest("POST", "/api/v1/auth/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	failure := <-sub.Events()
+	assert.Equal(t, events.TypeAuthFailure, failure.Type)
+	assert.Equal(t, "testuser", failure.Data["username"])
+	assert.Equal(t, "invalid password", failure.Data["reason"])
+
+	success := <-sub.Events()
+	assert.Equal(t, events.TypeAuthSuccess, success.Type)
+	assert.Equal(t, "login", success.Data["method"])
+}
+
 // TestLogout tests user logout with session revocation
 func TestLogout(t *testing.T) {
 	tests := []struct {
@@ -536,6 +607,26 @@ func TestCreateAPIKeyHandler(t *testing.T) {
 				assert.False(t, response.ExpiresAt.IsZero())
 			},
 		},
+		{
+			name: "API key bound to a Mimir tenant",
+			requestBody: CreateAPIKeyRequest{
+				Name:        "tenant-key",
+				ExpiresIn:   "30d",
+				MimirTenant: "tenant-a",
+			},
+			authenticated:  true,
+			expectedStatus: http.StatusCreated,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response CreateAPIKeyResponse
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				require.NoError(t, err)
+				assert.Equal(t, "tenant-a", response.MimirTenant)
+
+				_, apiKey, err := am.ValidateAPIKey(response.Key)
+				require.NoError(t, err)
+				assert.Equal(t, "tenant-a", apiKey.MimirTenant)
+			},
+		},
 		{
 			name: "not authenticated",
 			requestBody: CreateAPIKeyRequest{
@@ -600,6 +691,94 @@ func TestCreateAPIKeyHandler(t *testing.T) {
 	}
 }
 
+// TestCreateAPIKeyHandlerIdempotency tests that a retried creation with the
+// same Idempotency-Key returns the original key without creating another
+func TestCreateAPIKeyHandlerIdempotency(t *testing.T) {
+	am := NewTestAuthManager(AuthConfig{JWTSecret: "test-secret"})
+	r := setupTestRouter(am)
+
+	user, _ := am.CreateUserWithPassword("testuser", "test@example.com", "password123", []string{"user"})
+	session, _ := am.CreateSession(user.ID)
+
+	create := func(name, idempotencyKey string) (*httptest.ResponseRecorder, CreateAPIKeyResponse) {
+		body, _ := json.Marshal(CreateAPIKeyRequest{Name: name, ExpiresIn: "30d", MimirTenant: "tenant-a"})
+		req, _ := http.NewRequest("POST", "/api/v1/api-keys", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: session})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var response CreateAPIKeyResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	w, first := create("provisioned-key", "provision-42")
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.NotEmpty(t, first.Key)
+	assert.False(t, first.Replayed)
+
+	w, retry := create("provisioned-key", "provision-42")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, first.ID, retry.ID)
+	assert.Empty(t, retry.Key)
+	assert.NotContains(t, w.Body.String(), `"key"`)
+	assert.True(t, retry.Replayed)
+	assert.Equal(t, "tenant-a", retry.MimirTenant)
+
+	keys, err := am.ListAPIKeys(user.ID)
+	require.NoError(t, err)
+	assert.Len(t, keys, 1, "a retry must not create a second key")
+
+	w, _ = create("different-key", "provision-42")
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+// TestCreateAPIKeyHandlerWithAPIKey tests that only admins may create keys
+// with an API key, and that keys derived from a tenant-bound key stay bound
+// to its tenant
+func TestCreateAPIKeyHandlerWithAPIKey(t *testing.T) {
+	am := NewTestAuthManager(AuthConfig{JWTSecret: "test-secret"})
+	r := setupTestRouter(am)
+
+	user, _ := am.CreateUser("testuser", "test@example.com", []string{"user"})
+	userKey, err := am.CreateAPIKey(user.ID, "user-key", []string{"read"}, 100, time.Hour)
+	require.NoError(t, err)
+	admin, _ := am.CreateUser("ops", "ops@example.com", []string{"admin"})
+	adminKey, _, err := am.CreateAPIKeyIdempotent(admin.ID, "", "admin-key", []string{"read"}, 100, time.Hour, "tenant-a")
+	require.NoError(t, err)
+
+	create := func(key, tenant string) (*httptest.ResponseRecorder, CreateAPIKeyResponse) {
+		body, _ := json.Marshal(CreateAPIKeyRequest{Name: "derived-" + tenant, ExpiresIn: "30d", MimirTenant: tenant})
+		req, _ := http.NewRequest("POST", "/api/v1/api-keys", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var response CreateAPIKeyResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	w, _ := create(userKey.Key, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	keys, _ := am.ListAPIKeys(user.ID)
+	assert.Len(t, keys, 1)
+
+	// Unbound requests inherit the admin key's tenant
+	w, derived := create(adminKey.Key, "")
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "tenant-a", derived.MimirTenant)
+	_, derivedKey, err := am.ValidateAPIKey(derived.Key)
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-a", derivedKey.MimirTenant)
+
+	w, _ = create(adminKey.Key, "tenant-b")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
 // TestListAPIKeysHandler tests listing API keys handler
 func TestListAPIKeysHandler(t *testing.T) {
 	am := NewTestAuthManager(AuthConfig{JWTSecret: "test-secret"})
@@ -673,10 +852,26 @@ func TestRevokeAPIKeyHandler(t *testing.T) {
 
 	apiKey, _ := am.CreateAPIKey(user.ID, "test-key", []string{"read"}, 100, 30*24*time.Hour)
 
+	otherUser, _ := am.CreateUserWithPassword("otheruser", "other@example.com", "password123", []string{"user"})
+	otherKey, _ := am.CreateAPIKey(otherUser.ID, "other-key", []string{"read"}, 100, 30*24*time.Hour)
+
+	adminUser, _ := am.CreateUserWithPassword("adminuser", "admin@example.com", "password123", []string{"admin", "user"})
+	adminSession, _ := am.CreateSession(adminUser.ID)
+	adminRevocableKey, _ := am.CreateAPIKey(otherUser.ID, "admin-revocable-key", []string{"read"}, 100, 30*24*time.Hour)
+
+	notFoundBody := func(t *testing.T, w *httptest.ResponseRecorder) string {
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Contains(t, response, "error")
+		return w.Body.String()
+	}
+	var nonexistentBody string
+
 	tests := []struct {
 		name           string
 		keyID          string
 		authenticated  bool
+		sessionID      string // Overrides the default user's session when set
 		expectedStatus int
 		checkResponse  func(*testing.T, *httptest.ResponseRecorder)
 	}{
@@ -704,10 +899,32 @@ func TestRevokeAPIKeyHandler(t *testing.T) {
 			authenticated:  true,
 			expectedStatus: http.StatusNotFound,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
-				var response map[string]interface{}
-				err := json.Unmarshal(w.Body.Bytes(), &response)
-				require.NoError(t, err)
-				assert.Contains(t, response, "error")
+				nonexistentBody = notFoundBody(t, w)
+			},
+		},
+		{
+			name:           "other user's API key reported as not found",
+			keyID:          otherKey.ID,
+			authenticated:  true,
+			expectedStatus: http.StatusNotFound,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				body := notFoundBody(t, w)
+				// Identical apart from the echoed key ID, so ownership is not revealed
+				assert.Equal(t, nonexistentBody, strings.Replace(body, otherKey.ID, "nonexistent", 1))
+
+				_, _, err := am.ValidateAPIKey(otherKey.Key)
+				assert.NoError(t, err, "other user's key must remain active")
+			},
+		},
+		{
+			name:           "admin revokes another user's API key",
+			keyID:          adminRevocableKey.ID,
+			authenticated:  true,
+			sessionID:      adminSession,
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				_, _, err := am.ValidateAPIKey(adminRevocableKey.Key)
+				assert.Error(t, err)
 			},
 		},
 	}
@@ -717,7 +934,11 @@ func TestRevokeAPIKeyHandler(t *testing.T) {
 			req, _ := http.NewRequest("DELETE", "/api/v1/api-keys/"+tt.keyID, nil)
 
 			if tt.authenticated {
-				req.AddCookie(&http.Cookie{Name: "session_id", Value: session})
+				sessionID := session
+				if tt.sessionID != "" {
+					sessionID = tt.sessionID
+				}
+				req.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
 			}
 
 			w := httptest.NewRecorder()
@@ -910,6 +1131,69 @@ func TestGetRateLimitStats(t *testing.T) {
 	}
 }
 
+// TestCleanupHandler tests on-demand cleanup of expired sessions and API keys
+func TestCleanupHandler(t *testing.T) {
+	am := NewTestAuthManager(AuthConfig{JWTSecret: "test-secret", SessionExpiry: time.Second})
+	r := setupTestRouter(am)
+
+	adminUser, err := am.CreateUserWithPassword("adminuser", "admin@example.com", "password123", []string{"admin", "user"})
+	require.NoError(t, err)
+	adminKey, err := am.CreateAPIKey(adminUser.ID, "admin-key", []string{"admin"}, 100, time.Hour)
+	require.NoError(t, err)
+
+	user, err := am.CreateUserWithPassword("regularuser", "regular@example.com", "password123", []string{"user"})
+	require.NoError(t, err)
+	_, err = am.CreateAPIKey(user.ID, "expired-key", []string{"read"}, 100, -time.Hour)
+	require.NoError(t, err)
+	_, err = am.CreateAPIKey(user.ID, "valid-key", []string{"read"}, 100, time.Hour)
+	require.NoError(t, err)
+	userKey, err := am.CreateAPIKey(user.ID, "user-key", []string{"read"}, 100, time.Hour)
+	require.NoError(t, err)
+
+	// Sessions whose expiry has passed but which are still stored
+	_, err = am.CreateSession(user.ID)
+	require.NoError(t, err)
+	_, err = am.CreateSession(adminUser.ID)
+	require.NoError(t, err)
+	time.Sleep(1100 * time.Millisecond)
+
+	cleanup := func(apiKey string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/admin/cleanup", nil)
+		req.Header.Set("X-API-Key", apiKey)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("regular user cannot run cleanup", func(t *testing.T) {
+		w := cleanup(userKey.Key)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("admin removes expired credentials", func(t *testing.T) {
+		w := cleanup(adminKey.Key)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var result CleanupResult
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		assert.Equal(t, 1, result.ExpiredAPIKeys)
+		assert.Equal(t, 2, result.ExpiredSessions)
+
+		keys, err := am.ListAPIKeys(user.ID)
+		require.NoError(t, err)
+		assert.Len(t, keys, 2)
+	})
+
+	t.Run("second run finds nothing", func(t *testing.T) {
+		w := cleanup(adminKey.Key)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var result CleanupResult
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		assert.Equal(t, CleanupResult{}, result)
+	})
+}
+
 // TestParseDuration tests the parseDuration helper function
 func TestParseDuration(t *testing.T) {
 	tests := []struct {
@@ -13,9 +13,21 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"github.com/seanankenbruck/observability-ai/internal/events"
+	"github.com/seanankenbruck/observability-ai/internal/notify"
 	"github.com/seanankenbruck/observability-ai/internal/session"
 )
 
+// Errors returned when managing API keys by ID
+var (
+	ErrAPIKeyNotFound = fmt.Errorf("API key not found")
+	ErrAPIKeyNotOwned = fmt.Errorf("API key belongs to another user")
+
+	// ErrIdempotencyKeyReused is returned when an idempotency key is reused to
+	// create an API key with a different name
+	ErrIdempotencyKeyReused = fmt.Errorf("idempotency key was already used for a different API key")
+)
+
 // User represents a user in the system
 type User struct {
 	ID           string            `json:"id"`
@@ -25,6 +37,7 @@ type User struct {
 	Roles        []string          `json:"roles"`
 	Metadata     map[string]string `json:"metadata,omitempty"`
 	Active       bool              `json:"active"`
+	Provider     string            `json:"provider,omitempty"` // External identity provider, e.g. "github"; empty for local users
 }
 
 // APIKey represents an API key for authentication
@@ -40,6 +53,7 @@ type APIKey struct {
 	CreatedAt   time.Time `json:"created_at"`
 	LastUsedAt  time.Time `json:"last_used_at"`
 	Active      bool      `json:"active"`
+	MimirTenant string    `json:"mimir_tenant,omitempty"` // Only this Mimir tenant may be queried, if set
 }
 
 // Session represents a user session
@@ -60,6 +74,13 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
+// externalUserNamespace derives the IDs of external users from their identity,
+// so every replica assigns the same ID to the same account
+var externalUserNamespace = uuid.MustParse("5f0c3b1e-6a2d-4c8e-9b7a-2d1e4f6a8c90")
+
+// defaultJWTIssuer is the iss claim used when no issuer is configured
+const defaultJWTIssuer = "observability-ai"
+
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
 	JWTSecret      string
@@ -67,6 +88,15 @@ type AuthConfig struct {
 	SessionExpiry  time.Duration
 	RateLimit      int
 	AllowAnonymous bool
+	// JWTIssuer is set as the iss claim of minted tokens and required on
+	// validation. JWTAudience, when set, is likewise set as the aud claim and
+	// required on validation.
+	JWTIssuer   string
+	JWTAudience string
+	// RoleHierarchy lists roles from most to least privileged (e.g. admin, user,
+	// anonymous). A role satisfies every role listed after it. When empty, roles
+	// must match exactly.
+	RoleHierarchy []string
 }
 
 // AuthManager handles authentication and user management
@@ -74,8 +104,12 @@ type AuthManager struct {
 	config         AuthConfig
 	users          map[string]*User        // userID -> User
 	apiKeys        map[string]*APIKey      // hashedKey -> APIKey
+	idempotentKeys map[string]string       // userID + idempotency key -> hashedKey
 	userByUsername map[string]*User        // username -> User
+	externalUsers  map[string]string       // provider + ":" + external ID -> userID
 	sessionManager *session.Manager        // Redis-based session manager
+	events         *events.Bus             // Receives auth success/failure events
+	notifier       notify.Notifier         // Told about API key creation and revocation
 	mu             sync.RWMutex
 }
 
@@ -94,13 +128,19 @@ func NewAuthManager(config AuthConfig, sessionManager *session.Manager) *AuthMan
 	if config.JWTSecret == "" {
 		config.JWTSecret = generateRandomString(32)
 	}
+	if config.JWTIssuer == "" {
+		config.JWTIssuer = defaultJWTIssuer
+	}
 
 	am := &AuthManager{
 		config:         config,
 		users:          make(map[string]*User),
 		apiKeys:        make(map[string]*APIKey),
+		idempotentKeys: make(map[string]string),
 		userByUsername: make(map[string]*User),
+		externalUsers:  make(map[string]string),
 		sessionManager: sessionManager,
+		notifier:       notify.Nop{},
 	}
 
 	// Create default admin user with fixed UUID for consistency across pods
@@ -112,6 +152,45 @@ func NewAuthManager(config AuthConfig, sessionManager *session.Manager) *AuthMan
 	return am
 }
 
+// SetEventBus publishes authentication successes and failures to the bus
+func (am *AuthManager) SetEventBus(bus *events.Bus) {
+	am.events = bus
+}
+
+// SetNotifier notifies about API keys being created and revoked
+func (am *AuthManager) SetNotifier(notifier notify.Notifier) {
+	am.notifier = notifier
+}
+
+// notifyAPIKey sends an API key notification. The key itself is never
+// included.
+func (am *AuthManager) notifyAPIKey(eventType notify.Type, message string, apiKey *APIKey) {
+	notify.Send(am.notifier, notify.Event{
+		Type:     eventType,
+		Severity: notify.SeverityInfo,
+		Message:  message,
+		Data: map[string]interface{}{
+			"key_id":   apiKey.ID,
+			"key_name": apiKey.Name,
+			"user_id":  apiKey.UserID,
+		},
+	})
+}
+
+// publishAuthEvent publishes an authentication outcome
+func (am *AuthManager) publishAuthEvent(eventType events.Type, method, username, reason string) {
+	data := map[string]interface{}{
+		"method": method,
+	}
+	if username != "" {
+		data["username"] = username
+	}
+	if reason != "" {
+		data["reason"] = reason
+	}
+	am.events.Publish(eventType, data)
+}
+
 // CreateUser creates a new user (without password - used for admin creation)
 func (am *AuthManager) CreateUser(username, email string, roles []string) (*User, error) {
 	return am.CreateUserWithPassword(username, email, "", roles)
@@ -153,8 +232,85 @@ func (am *AuthManager) CreateUserWithPassword(username, email, password string,
 	return user, nil
 }
 
-// ValidatePassword checks if the provided password matches the user's password hash
+// LoginExternalUser returns the user of an identity at an external provider,
+// such as a GitHub account, creating the user on first login. The roles
+// replace the user's roles on every login, following changes at the
+// provider. Users are never linked to an existing local user by username: if
+// the username is taken, the user is named username@provider.
+func (am *AuthManager) LoginExternalUser(provider, externalID, username, email string, roles []string) (*User, error) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	identity := provider + ":" + externalID
+	if userID, exists := am.externalUsers[identity]; exists {
+		if user, exists := am.users[userID]; exists {
+			if !user.Active {
+				return nil, fmt.Errorf("user is inactive")
+			}
+			user.Roles = roles
+			if email != "" {
+				user.Email = email
+			}
+			return user, nil
+		}
+	}
+
+	if _, exists := am.userByUsername[username]; exists {
+		username = username + "@" + provider
+		if _, exists := am.userByUsername[username]; exists {
+			return nil, fmt.Errorf("user already exists: %s", username)
+		}
+	}
+
+	user := &User{
+		ID:       uuid.NewSHA1(externalUserNamespace, []byte(identity)).String(),
+		Username: username,
+		Email:    email,
+		Roles:    roles,
+		Metadata: map[string]string{provider + "_id": externalID},
+		Active:   true,
+		Provider: provider,
+	}
+	am.users[user.ID] = user
+	am.userByUsername[username] = user
+	am.externalUsers[identity] = user.ID
+
+	return user, nil
+}
+
+// restoreExternalUser returns the external user of a session, rebuilding it
+// from the session if the login was handled by another replica
+func (am *AuthManager) restoreExternalUser(sess *session.Session) *User {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if user, exists := am.users[sess.UserID]; exists {
+		return user
+	}
+
+	user := &User{
+		ID:       sess.UserID,
+		Username: sess.Username,
+		Roles:    sess.Roles,
+		Metadata: map[string]string{sess.Provider + "_id": sess.ExternalID},
+		Active:   true,
+		Provider: sess.Provider,
+	}
+	am.users[user.ID] = user
+	if _, exists := am.userByUsername[user.Username]; !exists {
+		am.userByUsername[user.Username] = user
+	}
+	am.externalUsers[sess.Provider+":"+sess.ExternalID] = user.ID
+
+	return user
+}
+
+// ValidatePassword checks if the provided password matches the user's password hash.
+// Users of an external provider have no password and never match.
 func (am *AuthManager) ValidatePassword(user *User, password string) bool {
+	if user.Provider != "" {
+		return false
+	}
 	if user.PasswordHash == "" {
 		// No password set - for backward compatibility with admin user
 		return true
@@ -194,6 +350,62 @@ func (am *AuthManager) CreateAPIKey(userID, name string, permissions []string, r
 	am.mu.Lock()
 	defer am.mu.Unlock()
 
+	return am.createAPIKey(userID, name, permissions, rateLimit, expiresIn, "")
+}
+
+// CreateAPIKeyIdempotent creates an API key like CreateAPIKey, unless the user
+// already created one with the same idempotency key. A retry then returns the
+// original key without its plaintext, which is only revealed when the key is
+// created, and reports created as false. An empty idempotency key always
+// creates a new key. A non-empty tenant binds the key to that Mimir tenant as
+// it is created.
+func (am *AuthManager) CreateAPIKeyIdempotent(userID, idempotencyKey, name string, permissions []string, rateLimit int, expiresIn time.Duration, tenant string) (apiKey *APIKey, created bool, err error) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if idempotencyKey == "" {
+		apiKey, err := am.createAPIKey(userID, name, permissions, rateLimit, expiresIn, tenant)
+		return apiKey, err == nil, err
+	}
+
+	scopedKey := userID + "\x00" + idempotencyKey
+	if hashedKey, exists := am.idempotentKeys[scopedKey]; exists {
+		if original, exists := am.apiKeys[hashedKey]; exists {
+			if !sameAPIKeyRequest(original, name, permissions, rateLimit, expiresIn, tenant) {
+				return nil, false, ErrIdempotencyKeyReused
+			}
+			keyCopy := *original
+			keyCopy.Key = ""
+			return &keyCopy, false, nil
+		}
+	}
+
+	apiKey, err = am.createAPIKey(userID, name, permissions, rateLimit, expiresIn, tenant)
+	if err != nil {
+		return nil, false, err
+	}
+	am.idempotentKeys[scopedKey] = apiKey.HashedKey
+	return apiKey, true, nil
+}
+
+// sameAPIKeyRequest reports whether a replayed creation asks for the key that
+// was created
+func sameAPIKeyRequest(original *APIKey, name string, permissions []string, rateLimit int, expiresIn time.Duration, tenant string) bool {
+	if original.Name != name || original.RateLimit != rateLimit || original.MimirTenant != tenant ||
+		original.ExpiresAt.Sub(original.CreatedAt) != expiresIn || len(original.Permissions) != len(permissions) {
+		return false
+	}
+	for i, permission := range permissions {
+		if original.Permissions[i] != permission {
+			return false
+		}
+	}
+	return true
+}
+
+// createAPIKey creates an API key, bound to the tenant if one is given; the
+// caller must hold am.mu
+func (am *AuthManager) createAPIKey(userID, name string, permissions []string, rateLimit int, expiresIn time.Duration, tenant string) (*APIKey, error) {
 	// Verify user exists
 	if _, exists := am.users[userID]; !exists {
 		return nil, fmt.Errorf("user not found: %s", userID)
@@ -202,6 +414,7 @@ func (am *AuthManager) CreateAPIKey(userID, name string, permissions []string, r
 	// Generate API key
 	key := generateAPIKey()
 	hashedKey := hashAPIKey(key)
+	now := time.Now()
 
 	apiKey := &APIKey{
 		ID:          uuid.New().String(),
@@ -211,12 +424,14 @@ func (am *AuthManager) CreateAPIKey(userID, name string, permissions []string, r
 		UserID:      userID,
 		Permissions: permissions,
 		RateLimit:   rateLimit,
-		CreatedAt:   time.Now(),
-		ExpiresAt:   time.Now().Add(expiresIn),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(expiresIn),
 		Active:      true,
+		MimirTenant: tenant,
 	}
 
 	am.apiKeys[hashedKey] = apiKey
+	am.notifyAPIKey(notify.TypeAPIKeyCreated, fmt.Sprintf("API key %q created", name), apiKey)
 
 	return apiKey, nil
 }
@@ -268,10 +483,13 @@ func (am *AuthManager) CreateJWTToken(user *User) (string, error) {
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "observability-ai",
+			Issuer:    am.config.JWTIssuer,
 			Subject:   user.ID,
 		},
 	}
+	if am.config.JWTAudience != "" {
+		claims.Audience = jwt.ClaimStrings{am.config.JWTAudience}
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(am.config.JWTSecret))
@@ -284,13 +502,19 @@ func (am *AuthManager) CreateJWTToken(user *User) (string, error) {
 
 // ValidateJWTToken validates a JWT token and returns the claims
 func (am *AuthManager) ValidateJWTToken(tokenString string) (*Claims, error) {
+	// Tokens must carry the configured issuer, and audience if one is configured
+	options := []jwt.ParserOption{jwt.WithIssuer(am.config.JWTIssuer)}
+	if am.config.JWTAudience != "" {
+		options = append(options, jwt.WithAudience(am.config.JWTAudience))
+	}
+
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		// Verify signing method
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(am.config.JWTSecret), nil
-	})
+	}, options...)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -333,8 +557,14 @@ func (am *AuthManager) CreateSession(userID string) (string, error) {
 		return "", fmt.Errorf("failed to create token: %w", err)
 	}
 
-	// Create session in Redis
-	sessionID, err := am.sessionManager.Create(context.Background(), user.ID, user.Username, token, user.Roles)
+	// Create session in Redis; sessions of external users carry their
+	// identity so other replicas can rebuild them
+	var sessionID string
+	if user.Provider != "" {
+		sessionID, err = am.sessionManager.CreateExternal(context.Background(), user.ID, user.Username, token, user.Roles, user.Provider, user.Metadata[user.Provider+"_id"])
+	} else {
+		sessionID, err = am.sessionManager.Create(context.Background(), user.ID, user.Username, token, user.Roles)
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to create session: %w", err)
 	}
@@ -355,6 +585,9 @@ func (am *AuthManager) ValidateSession(sessionID string) (*User, error) {
 	user, exists := am.users[sess.UserID]
 	am.mu.RUnlock()
 
+	if !exists && sess.Provider != "" && sess.ExternalID != "" {
+		user, exists = am.restoreExternalUser(sess), true
+	}
 	if !exists {
 		return nil, fmt.Errorf("user not found for session")
 	}
@@ -381,6 +614,7 @@ func (am *AuthManager) RevokeAPIKey(keyID string) error {
 	for _, apiKey := range am.apiKeys {
 		if apiKey.ID == keyID {
 			apiKey.Active = false
+			am.notifyAPIKey(notify.TypeAPIKeyRevoked, fmt.Sprintf("API key %q revoked", apiKey.Name), apiKey)
 			return nil
 		}
 	}
@@ -388,24 +622,83 @@ func (am *AuthManager) RevokeAPIKey(keyID string) error {
 	return fmt.Errorf("API key not found: %s", keyID)
 }
 
+// RevokeUserAPIKey revokes an API key on behalf of a user. It returns
+// ErrAPIKeyNotFound if no key has the ID and ErrAPIKeyNotOwned if the key
+// belongs to a different user.
+func (am *AuthManager) RevokeUserAPIKey(userID, keyID string) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	for _, apiKey := range am.apiKeys {
+		if apiKey.ID == keyID {
+			if apiKey.UserID != userID {
+				return ErrAPIKeyNotOwned
+			}
+			apiKey.Active = false
+			am.notifyAPIKey(notify.TypeAPIKeyRevoked, fmt.Sprintf("API key %q revoked", apiKey.Name), apiKey)
+			return nil
+		}
+	}
+
+	return ErrAPIKeyNotFound
+}
+
+// BindAPIKeyTenant restricts an API key to querying the given Mimir tenant
+func (am *AuthManager) BindAPIKeyTenant(keyID, tenant string) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	for _, apiKey := range am.apiKeys {
+		if apiKey.ID == keyID {
+			apiKey.MimirTenant = tenant
+			return nil
+		}
+	}
+
+	return ErrAPIKeyNotFound
+}
+
 // RevokeSession revokes a session from Redis
 func (am *AuthManager) RevokeSession(sessionID string) error {
 	return am.sessionManager.Delete(context.Background(), sessionID)
 }
 
-// CleanupExpired removes expired API keys (sessions are auto-expired by Redis TTL)
-func (am *AuthManager) CleanupExpired() {
-	am.mu.Lock()
-	defer am.mu.Unlock()
+// CleanupResult reports what CleanupExpired removed
+type CleanupResult struct {
+	ExpiredAPIKeys  int `json:"expired_api_keys"`
+	ExpiredSessions int `json:"expired_sessions"`
+}
 
+// CleanupExpired removes expired API keys and any expired sessions still stored
+// in Redis (most sessions are auto-expired by Redis TTL). API keys are cleaned up
+// even if session cleanup fails; the result reflects what was removed.
+func (am *AuthManager) CleanupExpired() (*CleanupResult, error) {
+	result := &CleanupResult{}
 	now := time.Now()
 
 	// Cleanup expired API keys
+	am.mu.Lock()
 	for hash, apiKey := range am.apiKeys {
 		if now.After(apiKey.ExpiresAt) {
 			delete(am.apiKeys, hash)
+			result.ExpiredAPIKeys++
+		}
+	}
+	for scopedKey, hash := range am.idempotentKeys {
+		if _, exists := am.apiKeys[hash]; !exists {
+			delete(am.idempotentKeys, scopedKey)
 		}
 	}
+	am.mu.Unlock()
+
+	// Cleanup expired sessions
+	removed, err := am.sessionManager.CleanupExpired(context.Background())
+	result.ExpiredSessions = removed
+	if err != nil {
+		return result, fmt.Errorf("failed to clean up sessions: %w", err)
+	}
+
+	return result, nil
 }
 
 // ListAPIKeys returns all API keys for a user
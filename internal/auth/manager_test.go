@@ -2,9 +2,14 @@
 package auth
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/seanankenbruck/observability-ai/internal/notify"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -247,6 +252,74 @@ func TestCreateAPIKey(t *testing.T) {
 	}
 }
 
+// TestCreateAPIKeyIdempotent tests that retries with the same idempotency key
+// return the original key instead of creating another
+func TestCreateAPIKeyIdempotent(t *testing.T) {
+	am := NewTestAuthManager(AuthConfig{JWTSecret: "test-secret"})
+
+	user, err := am.CreateUser("provisioner", "provisioner@example.com", []string{"user"})
+	require.NoError(t, err)
+	other, err := am.CreateUser("other", "other@example.com", []string{"user"})
+	require.NoError(t, err)
+
+	first, created, err := am.CreateAPIKeyIdempotent(user.ID, "req-1", "ci-key", []string{"read"}, 100, time.Hour, "")
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.NotEmpty(t, first.Key)
+
+	retry, created, err := am.CreateAPIKeyIdempotent(user.ID, "req-1", "ci-key", []string{"read"}, 100, time.Hour, "")
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, first.ID, retry.ID)
+	assert.Empty(t, retry.Key, "a retry must not reveal the key again")
+
+	keys, err := am.ListAPIKeys(user.ID)
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+
+	// A replay must ask for the same key
+	for name, replay := range map[string]func() error{
+		"name": func() error {
+			_, _, err := am.CreateAPIKeyIdempotent(user.ID, "req-1", "another-key", []string{"read"}, 100, time.Hour, "")
+			return err
+		},
+		"permissions": func() error {
+			_, _, err := am.CreateAPIKeyIdempotent(user.ID, "req-1", "ci-key", []string{"read", "write"}, 100, time.Hour, "")
+			return err
+		},
+		"rate limit": func() error {
+			_, _, err := am.CreateAPIKeyIdempotent(user.ID, "req-1", "ci-key", []string{"read"}, 200, time.Hour, "")
+			return err
+		},
+		"expiry": func() error {
+			_, _, err := am.CreateAPIKeyIdempotent(user.ID, "req-1", "ci-key", []string{"read"}, 100, 2*time.Hour, "")
+			return err
+		},
+		"tenant": func() error {
+			_, _, err := am.CreateAPIKeyIdempotent(user.ID, "req-1", "ci-key", []string{"read"}, 100, time.Hour, "tenant-a")
+			return err
+		},
+	} {
+		assert.ErrorIs(t, replay(), ErrIdempotencyKeyReused, name)
+	}
+
+	// Idempotency keys are scoped to the user
+	otherKey, created, err := am.CreateAPIKeyIdempotent(other.ID, "req-1", "ci-key", []string{"read"}, 100, time.Hour, "")
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.NotEqual(t, first.ID, otherKey.ID)
+
+	// Without an idempotency key every request creates a key
+	for i := 0; i < 2; i++ {
+		_, created, err := am.CreateAPIKeyIdempotent(user.ID, "", "ci-key", []string{"read"}, 100, time.Hour, "")
+		require.NoError(t, err)
+		assert.True(t, created)
+	}
+	keys, err = am.ListAPIKeys(user.ID)
+	require.NoError(t, err)
+	assert.Len(t, keys, 3)
+}
+
 // TestValidateAPIKey tests API key validation
 func TestValidateAPIKey(t *testing.T) {
 	am := NewTestAuthManager(AuthConfig{JWTSecret: "test-secret"})
@@ -401,6 +474,110 @@ func TestValidateJWTToken(t *testing.T) {
 	}
 }
 
+// TestJWTIssuerAndAudience tests that tokens must carry the configured issuer and audience
+func TestJWTIssuerAndAudience(t *testing.T) {
+	am := NewTestAuthManager(AuthConfig{
+		JWTSecret:   "test-secret",
+		JWTExpiry:   1 * time.Hour,
+		JWTIssuer:   "https://observability.example.com",
+		JWTAudience: "api-gateway",
+	})
+
+	user, err := am.CreateUser("testuser", "test@example.com", []string{"user"})
+	require.NoError(t, err)
+
+	mint := func(issuer string, audience ...string) string {
+		claims := &Claims{
+			UserID:   user.ID,
+			Username: user.Username,
+			Roles:    user.Roles,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				Issuer:    issuer,
+				Audience:  audience,
+				Subject:   user.ID,
+			},
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+		require.NoError(t, err)
+		return token
+	}
+
+	t.Run("minted tokens carry issuer and audience", func(t *testing.T) {
+		token, err := am.CreateJWTToken(user)
+		require.NoError(t, err)
+
+		claims, err := am.ValidateJWTToken(token)
+		require.NoError(t, err)
+		assert.Equal(t, "https://observability.example.com", claims.Issuer)
+		assert.Equal(t, jwt.ClaimStrings{"api-gateway"}, claims.Audience)
+	})
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{
+			name:  "correct issuer and audience",
+			token: mint("https://observability.example.com", "api-gateway"),
+		},
+		{
+			name:  "audience among several",
+			token: mint("https://observability.example.com", "other-service", "api-gateway"),
+		},
+		{
+			name:    "wrong issuer",
+			token:   mint("https://attacker.example.com", "api-gateway"),
+			wantErr: true,
+		},
+		{
+			name:    "missing issuer",
+			token:   mint("", "api-gateway"),
+			wantErr: true,
+		},
+		{
+			name:    "wrong audience",
+			token:   mint("https://observability.example.com", "other-service"),
+			wantErr: true,
+		},
+		{
+			name:    "missing audience",
+			token:   mint("https://observability.example.com"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := am.ValidateJWTToken(tt.token)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("default issuer without audience", func(t *testing.T) {
+		defaultManager := NewTestAuthManager(AuthConfig{JWTSecret: "test-secret"})
+		defaultUser, err := defaultManager.CreateUser("other", "other@example.com", []string{"user"})
+		require.NoError(t, err)
+
+		token, err := defaultManager.CreateJWTToken(defaultUser)
+		require.NoError(t, err)
+		claims, err := defaultManager.ValidateJWTToken(token)
+		require.NoError(t, err)
+		assert.Equal(t, defaultJWTIssuer, claims.Issuer)
+		assert.Empty(t, claims.Audience)
+
+		// Tokens from a differently configured issuer are rejected
+		_, err = am.ValidateJWTToken(token)
+		assert.Error(t, err)
+	})
+}
+
 // TestCreateSession tests session creation
 func TestCreateSession(t *testing.T) {
 	am := NewTestAuthManager(AuthConfig{
@@ -537,6 +714,71 @@ func TestRevokeAPIKey(t *testing.T) {
 	assert.Contains(t, err.Error(), "not found")
 }
 
+// TestRevokeUserAPIKey tests that users can only revoke their own API keys
+func TestRevokeUserAPIKey(t *testing.T) {
+	am := NewTestAuthManager(AuthConfig{JWTSecret: "test-secret"})
+
+	owner, err := am.CreateUser("owner", "owner@example.com", []string{"user"})
+	require.NoError(t, err)
+	other, err := am.CreateUser("other", "other@example.com", []string{"user"})
+	require.NoError(t, err)
+
+	apiKey, err := am.CreateAPIKey(owner.ID, "test-key", []string{"read"}, 100, 30*24*time.Hour)
+	require.NoError(t, err)
+
+	err = am.RevokeUserAPIKey(other.ID, apiKey.ID)
+	assert.Equal(t, ErrAPIKeyNotOwned, err)
+	_, _, err = am.ValidateAPIKey(apiKey.Key)
+	require.NoError(t, err, "key should remain active")
+
+	err = am.RevokeUserAPIKey(owner.ID, "non-existent")
+	assert.Equal(t, ErrAPIKeyNotFound, err)
+
+	err = am.RevokeUserAPIKey(owner.ID, apiKey.ID)
+	require.NoError(t, err)
+	_, _, err = am.ValidateAPIKey(apiKey.Key)
+	require.Error(t, err)
+}
+
+// TestAPIKeyNotifications tests that a configured webhook is notified about
+// API keys being created and revoked, without the key itself
+func TestAPIKeyNotifications(t *testing.T) {
+	received := make(chan map[string]interface{}, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received <- payload
+	}))
+	defer server.Close()
+
+	am := NewTestAuthManager(AuthConfig{JWTSecret: "test-secret"})
+	am.SetNotifier(notify.NewWebhookNotifier(server.URL, time.Second))
+
+	user, err := am.CreateUser("testuser", "test@example.com", []string{"user"})
+	require.NoError(t, err)
+
+	next := func() map[string]interface{} {
+		select {
+		case payload := <-received:
+			return payload
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for notification")
+			return nil
+		}
+	}
+
+	apiKey, err := am.CreateAPIKey(user.ID, "ci-key", []string{"read"}, 100, time.Hour)
+	require.NoError(t, err)
+	created := next()
+	assert.Equal(t, "api_key_created", created["type"])
+	assert.Equal(t, map[string]interface{}{"key_id": apiKey.ID, "key_name": "ci-key", "user_id": user.ID}, created["data"])
+
+	require.NoError(t, am.RevokeAPIKey(apiKey.ID))
+	revoked := next()
+	assert.Equal(t, "api_key_revoked", revoked["type"])
+	assert.Equal(t, `API key "ci-key" revoked`, revoked["message"])
+}
+
 // TestRevokeSession tests session revocation
 func TestRevokeSession(t *testing.T) {
 	am := NewTestAuthManager(AuthConfig{JWTSecret: "test-secret"})
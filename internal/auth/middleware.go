@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/observability-ai/internal/events"
 )
 
 // Middleware returns a Gin middleware for authentication
@@ -38,6 +39,7 @@ func (am *AuthManager) Middleware() gin.HandlerFunc {
 				return
 			}
 
+			am.publishAuthEvent(events.TypeAuthFailure, "request", "", "no valid credentials")
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "authentication required",
 			})
@@ -70,13 +72,8 @@ func (am *AuthManager) RequireRole(requiredRoles ...string) gin.HandlerFunc {
 		// Check if user has any of the required roles
 		hasRole := false
 		for _, required := range requiredRoles {
-			for _, userRole := range user.Roles {
-				if userRole == required {
-					hasRole = true
-					break
-				}
-			}
-			if hasRole {
+			if am.hasRole(user, required) {
+				hasRole = true
 				break
 			}
 		}
@@ -93,6 +90,46 @@ func (am *AuthManager) RequireRole(requiredRoles ...string) gin.HandlerFunc {
 	}
 }
 
+// HasRole reports whether the authenticated caller holds the role, either
+// explicitly or through the role hierarchy
+func (am *AuthManager) HasRole(c *gin.Context, role string) bool {
+	user, exists := GetCurrentUser(c)
+	if !exists {
+		return false
+	}
+	return am.hasRole(user, role)
+}
+
+// hasRole reports whether the user holds the required role, either explicitly
+// or by inheriting it from a higher role in the configured hierarchy
+func (am *AuthManager) hasRole(user *User, required string) bool {
+	requiredRank := roleRank(am.config.RoleHierarchy, required)
+
+	for _, userRole := range user.Roles {
+		if userRole == required {
+			return true
+		}
+		if requiredRank < 0 {
+			continue
+		}
+		if rank := roleRank(am.config.RoleHierarchy, userRole); rank >= 0 && rank < requiredRank {
+			return true
+		}
+	}
+
+	return false
+}
+
+// roleRank returns the position of role in the hierarchy, or -1 if absent
+func roleRank(hierarchy []string, role string) int {
+	for i, r := range hierarchy {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
+
 // authenticateRequest tries multiple authentication methods
 func (am *AuthManager) authenticateRequest(c *gin.Context) (*User, error) {
 	// Try JWT authentication
@@ -154,11 +191,16 @@ func (am *AuthManager) authenticateAPIKey(c *gin.Context) (*User, error) {
 		return nil, http.ErrAbortHandler
 	}
 
-	user, _, err := am.ValidateAPIKey(apiKey)
+	user, key, err := am.ValidateAPIKey(apiKey)
 	if err != nil {
 		return nil, err
 	}
 
+	c.Set("api_key_id", key.ID)
+	if key.MimirTenant != "" {
+		c.Set("mimir_tenant", key.MimirTenant)
+	}
+
 	return user, nil
 }
 
@@ -250,6 +292,29 @@ func GetCurrentUser(c *gin.Context) (*User, bool) {
 	return user, ok
 }
 
+// AuthenticatedByAPIKey reports whether the request authenticated with an API
+// key rather than a session or JWT token
+func AuthenticatedByAPIKey(c *gin.Context) bool {
+	_, exists := c.Get("api_key_id")
+	return exists
+}
+
+// BoundTenant returns the Mimir tenant the request's API key is bound to
+func (am *AuthManager) BoundTenant(c *gin.Context) (string, bool) {
+	value, exists := c.Get("mimir_tenant")
+	if !exists {
+		return "", false
+	}
+
+	tenant, ok := value.(string)
+	return tenant, ok && tenant != ""
+}
+
+// CurrentUserID returns the ID of the request's authenticated user
+func (am *AuthManager) CurrentUserID(c *gin.Context) (string, bool) {
+	return GetCurrentUserID(c)
+}
+
 // GetCurrentUserID returns the current user ID from context
 func GetCurrentUserID(c *gin.Context) (string, bool) {
 	value, exists := c.Get("user_id")
@@ -2,6 +2,7 @@
 package auth
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -143,6 +144,60 @@ func TestMiddleware(t *testing.T) {
 	}
 }
 
+// TestBoundTenant tests that only requests using a tenant-bound API key report a binding
+func TestBoundTenant(t *testing.T) {
+	am := NewTestAuthManager(AuthConfig{JWTSecret: "test-secret", RateLimit: 100})
+
+	user, err := am.CreateUser("testuser", "test@example.com", []string{"user"})
+	require.NoError(t, err)
+	jwtToken, err := am.CreateJWTToken(user)
+	require.NoError(t, err)
+	boundKey, err := am.CreateAPIKey(user.ID, "bound-key", []string{"read"}, 100, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, am.BindAPIKeyTenant(boundKey.ID, "tenant-a"))
+	unboundKey, err := am.CreateAPIKey(user.ID, "unbound-key", []string{"read"}, 100, time.Hour)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, am.BindAPIKeyTenant("missing", "tenant-a"), ErrAPIKeyNotFound)
+
+	router := gin.New()
+	router.Use(am.Middleware())
+	router.GET("/api/v1/protected", func(c *gin.Context) {
+		tenant, bound := am.BoundTenant(c)
+		c.JSON(http.StatusOK, gin.H{"tenant": tenant, "bound": bound})
+	})
+
+	tests := []struct {
+		name           string
+		header         string
+		value          string
+		expectedTenant string
+		expectedBound  bool
+	}{
+		{name: "bound API key", header: "X-API-Key", value: boundKey.Key, expectedTenant: "tenant-a", expectedBound: true},
+		{name: "unbound API key", header: "X-API-Key", value: unboundKey.Key},
+		{name: "JWT token", header: "Authorization", value: "Bearer " + jwtToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/protected", nil)
+			req.Header.Set(tt.header, tt.value)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			var response struct {
+				Tenant string `json:"tenant"`
+				Bound  bool   `json:"bound"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedTenant, response.Tenant)
+			assert.Equal(t, tt.expectedBound, response.Bound)
+		})
+	}
+}
+
 // TestMiddlewareWithAnonymousAccess tests middleware with anonymous access enabled
 func TestMiddlewareWithAnonymousAccess(t *testing.T) {
 	am := NewTestAuthManager(AuthConfig{
@@ -658,6 +713,83 @@ func TestMultipleRolesAccess(t *testing.T) {
 	}
 }
 
+// TestRequireRoleWithHierarchy tests that higher roles inherit lower roles
+func TestRequireRoleWithHierarchy(t *testing.T) {
+	hierarchy := []string{"admin", "user", "anonymous"}
+
+	tests := []struct {
+		name           string
+		hierarchy      []string
+		userRoles      []string
+		requiredRole   string
+		expectedStatus int
+	}{
+		{
+			name:           "admin without user role passes user route",
+			hierarchy:      hierarchy,
+			userRoles:      []string{"admin"},
+			requiredRole:   "user",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "admin passes anonymous route",
+			hierarchy:      hierarchy,
+			userRoles:      []string{"admin"},
+			requiredRole:   "anonymous",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "user does not inherit admin",
+			hierarchy:      hierarchy,
+			userRoles:      []string{"user"},
+			requiredRole:   "admin",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "roles outside hierarchy require exact match",
+			hierarchy:      hierarchy,
+			userRoles:      []string{"admin"},
+			requiredRole:   "developer",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "no hierarchy requires exact match",
+			hierarchy:      nil,
+			userRoles:      []string{"admin"},
+			requiredRole:   "user",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			am := NewTestAuthManager(AuthConfig{
+				JWTSecret:     "test-secret",
+				RoleHierarchy: tt.hierarchy,
+			})
+
+			user, err := am.CreateUser("hierarchy-user", "hierarchy@example.com", tt.userRoles)
+			require.NoError(t, err)
+			token, err := am.CreateJWTToken(user)
+			require.NoError(t, err)
+
+			router := gin.New()
+			router.Use(am.Middleware())
+			router.GET("/api/v1/protected", am.RequireRole(tt.requiredRole), func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"status": "ok"})
+			})
+
+			req, _ := http.NewRequest("GET", "/api/v1/protected", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
 // TestRateLimiterStats tests rate limiter statistics
 func TestRateLimiterStats(t *testing.T) {
 	rl := NewRateLimiter()
@@ -8,6 +8,9 @@ import (
 	"time"
 )
 
+// DefaultNamespace is the namespace used when none is configured or detected
+const DefaultNamespace = "default"
+
 // Config holds all application configuration
 type Config struct {
 	// Database configuration
@@ -33,6 +36,9 @@ type Config struct {
 
 	// Query configuration
 	Query QueryConfig
+
+	// Notification configuration
+	Notify NotifyConfig
 }
 
 // DatabaseConfig holds PostgreSQL configuration
@@ -43,6 +49,13 @@ type DatabaseConfig struct {
 	Username string
 	Password string
 	SSLMode  string
+
+	// DistanceMetric compares query embeddings: cosine, l2 or inner_product
+	DistanceMetric string
+
+	// RecencyHalfLife ranks recently used queries higher in similarity
+	// searches; zero ranks by similarity alone
+	RecencyHalfLife time.Duration
 }
 
 // RedisConfig holds Redis configuration
@@ -54,8 +67,35 @@ type RedisConfig struct {
 
 // ClaudeConfig holds Claude API configuration
 type ClaudeConfig struct {
-	APIKey string
-	Model  string
+	APIKey        string
+	Model         string
+	AllowedModels []string // Models requests may select instead of Model
+
+	// DefaultConfidence is reported when the model does not self-report a
+	// usable confidence
+	DefaultConfidence float64
+
+	// Temperature is the sampling temperature of requests that do not select
+	// one; selected temperatures are clamped to MinTemperature-MaxTemperature
+	Temperature    float64
+	MinTemperature float64
+	MaxTemperature float64
+
+	// StructuredOutput asks Claude to answer in a JSON schema through tool use
+	// instead of free-form text
+	StructuredOutput bool
+
+	// ModelContextWindows maps model names or globs to their context window
+	// in tokens, which bounds the prompt size. Models matching none use
+	// DefaultContextWindow.
+	ModelContextWindows  map[string]int
+	DefaultContextWindow int
+}
+
+// NotifyConfig holds operator notification configuration
+type NotifyConfig struct {
+	WebhookURL     string        // Receives notifications as JSON POSTs; empty disables notifications
+	WebhookTimeout time.Duration // Timeout of each webhook delivery
 }
 
 // MimirConfig holds Mimir/Prometheus configuration
@@ -66,8 +106,18 @@ type MimirConfig struct {
 	Password    string
 	BearerToken string
 	TenantID    string
+	Tenants     []string // Additional tenants queries may select; API keys can be bound to one
 	Timeout     time.Duration
 	BackendType string // "auto", "mimir", "prometheus"
+
+	// mTLS client authentication; the *PEM fields hold inline certificate
+	// material (e.g. from a secret provider) and take precedence over files
+	TLSCertFile string
+	TLSKeyFile  string
+	TLSCAFile   string
+	TLSCertPEM  string
+	TLSKeyPEM   string
+	TLSCAPEM    string
 }
 
 // DiscoveryConfig holds service discovery configuration
@@ -77,6 +127,42 @@ type DiscoveryConfig struct {
 	Namespaces        []string
 	ServiceLabelNames []string
 	ExcludeMetrics    []string
+	ExcludeNamespaces []string
+	MaxRetries        int
+	RetryBaseDelay    time.Duration
+	RetryMaxDelay     time.Duration
+	FailureThreshold  int
+	MaxLabelValues    int
+	CallTimeout       time.Duration
+
+	// DefaultNamespace is used wherever a namespace is not supplied, such as
+	// services discovered without a namespace label. Defaults to
+	// DefaultNamespace, or the detected namespace with DetectDefaultNamespace.
+	DefaultNamespace string
+
+	// DetectDefaultNamespace defaults DefaultNamespace to the namespace the
+	// secret provider reports, such as the pod's namespace in Kubernetes
+	DetectDefaultNamespace bool
+
+	// ServiceExcludeMetrics maps service globs ("service" or
+	// "namespace/service") to metric exclude patterns applied only to those
+	// services
+	ServiceExcludeMetrics map[string][]string
+
+	// ServiceSignalWeights weights the signals combined to identify a
+	// metric's service: label names, or "metric_name" for the metric-name
+	// prefix. Empty keeps single-signal identification.
+	ServiceSignalWeights map[string]float64
+
+	// MinServiceConfidence is the confidence below which a multi-signal
+	// identification is logged for review
+	MinServiceConfidence float64
+
+	// NormalizeServiceNames collapses variants of a service name, e.g.
+	// "UserService" and "user_service", into one lowercase name joined by
+	// ServiceNameSeparator
+	NormalizeServiceNames bool
+	ServiceNameSeparator  string
 }
 
 // AuthConfig holds authentication and authorization configuration
@@ -86,12 +172,45 @@ type AuthConfig struct {
 	SessionExpiry  time.Duration
 	RateLimit      int
 	AllowAnonymous bool
+	RoleHierarchy  []string // Most to least privileged; empty requires exact role matches
+	JWTIssuer      string   // iss claim set on and required of JWTs
+	JWTAudience    string   // aud claim set on and required of JWTs; empty disables
+
+	// GitHub login, enabled when GitHubClientID is set. Members of
+	// GitHubAllowedOrgs may log in; GitHubRoleMapping maps "org/team" or
+	// "org" to the role granted to its members.
+	GitHubClientID     string
+	GitHubClientSecret string
+	GitHubRedirectURL  string
+	GitHubAllowedOrgs  []string
+	GitHubRoleMapping  map[string]string
+	GitHubURL          string // GitHub web URL; set for GitHub Enterprise Server
+	GitHubAPIURL       string // GitHub API URL; set for GitHub Enterprise Server
 }
 
 // ServerConfig holds HTTP server configuration
 type ServerConfig struct {
 	Port    string
 	GinMode string
+
+	// TrustedProxies are the CIDRs (or single IPs) of reverse proxies whose
+	// X-Forwarded-For header identifies the client. Empty trusts no proxy.
+	TrustedProxies []string
+
+	// MaintenanceMode rejects queries with MaintenanceMessage at startup; it
+	// can also be toggled at runtime through the admin API
+	MaintenanceMode    bool
+	MaintenanceMessage string
+
+	// RequestLogSampleRate logs 1 in N successful requests; failed requests
+	// are always logged
+	RequestLogSampleRate int
+	// RequestLogRedactParams are query string parameters redacted in request logs
+	RequestLogRedactParams []string
+
+	// StrictJSON rejects JSON request bodies with fields the endpoint does
+	// not accept, instead of ignoring them
+	StrictJSON bool
 }
 
 // QueryConfig holds query processing configuration
@@ -104,7 +223,81 @@ type QueryConfig struct {
 	MaxNestingDepth      int
 	MaxTimeRangeDays     int
 	EnableSafetyChecks   bool
+	RequireLabelMatchers bool // Reject queries selecting a metric without any label matcher
 	ForbiddenMetricNames []string
+	SlowQueryThreshold   time.Duration // Zero disables slow query logging
+	BatchQueryTimeout    time.Duration // Deadline of each query in a batch
+	BatchTimeout         time.Duration // Deadline of a whole batch, after which partial results are returned
+	MaxContextEntries    int           // Maximum entries in a request's context map
+	MaxContextLength     int           // Maximum length of each context key and value
+	ContextLabelKeys     []string      // Label names allowed as context keys; empty allows any unreserved key
+	ConfirmCostThreshold int           // Estimated cost above which queries need confirmation; zero disables
+	Timezone             string        // IANA timezone for absolute times in queries, e.g. "2pm"
+	MaxPromptServices    int           // Maximum services listed in the prompt catalog
+	MaxInFlightQueries   int           // Maximum queries processed concurrently; zero disables the limit
+	FingerprintRateLimit int           // Requests per minute of each distinct query across all clients; zero disables
+
+	// MetricAliases maps user-facing names such as "requests" to canonical
+	// metric names such as "http_requests_total"
+	MetricAliases map[string]string
+
+	// MetricTypeOverrides maps metric types to metric names or regexes whose
+	// type naming conventions get wrong, e.g. gauge: queue_depth_total
+	MetricTypeOverrides map[string][]string
+
+	// DeprecatedMetrics are regexes matching whole names of metrics that are
+	// left out of the prompt; generated queries selecting one are handled per
+	// DeprecatedMetricMode, "warn" or "reject"
+	DeprecatedMetrics    []string
+	DeprecatedMetricMode string
+
+	// MetricDisplayPrefixes are stripped from metric names in catalog
+	// responses, e.g. "namespace_app_"; queries keep the full names
+	MetricDisplayPrefixes []string
+
+	// ResultUnitFormats maps metric units to the format of result values,
+	// e.g. seconds: duration, on top of the defaults for the base units
+	ResultUnitFormats map[string]string
+
+	// PinnedExamplesFile is a YAML file of query and promql pairs included
+	// as examples in every prompt, ahead of similar past queries
+	PinnedExamplesFile string
+
+	// PromptMetricHelp includes the help text discovered for metrics,
+	// truncated, in the prompt catalog
+	PromptMetricHelp bool
+
+	// DuplicateServiceMode is how a targeted service name found in several
+	// namespaces is handled when the query names none: "clarify" or "all"
+	DuplicateServiceMode string
+
+	// PostProcessorOrder lists post-processors of generated queries to run
+	// first, in order; the others run after them in their default order
+	PostProcessorOrder []string
+
+	// QueryDenyPatterns reject natural language queries matching any of
+	// them; QueryAllowPatterns, when set, only permit queries matching one.
+	// Both are case-insensitive regular expressions.
+	QueryDenyPatterns  []string
+	QueryAllowPatterns []string
+
+	// SupportedLanguages lists the languages besides English, e.g. "de",
+	// that queries may request explanations in
+	SupportedLanguages []string
+
+	// Subquery limits; zero keeps the default
+	MaxSubqueryRange time.Duration // Longest subquery range, e.g. the 1h of [1h:1m]
+	MinSubqueryStep  time.Duration // Finest subquery resolution, e.g. the 1m of [1h:1m]
+	MaxSubqueryDepth int           // Deepest nesting of subqueries
+
+	EvaluationSampleRate       float64 // Fraction of generated queries stored for offline evaluation; zero disables
+	EvaluationSampleMaxPerHour int     // Maximum evaluation samples stored per hour
+
+	// EmbeddingCache reuses stored embeddings of identical (normalized) queries
+	EmbeddingCache bool
+
+	// AdminOnlyMetadata lists response metadata fields hidden from non-admin callers
+	AdminOnlyMetadata []string
 }
 
 // Loader handles loading configuration from various sources
@@ -147,6 +340,9 @@ func (l *Loader) Load(ctx context.Context) (*Config, error) {
 		Username: l.getString(ctx, "DB_USER", "obs_ai"),
 		Password: l.getString(ctx, "DB_PASSWORD", ""),
 		SSLMode:  l.getString(ctx, "DB_SSLMODE", "disable"),
+
+		DistanceMetric:  l.getString(ctx, "EMBEDDING_DISTANCE_METRIC", "cosine"),
+		RecencyHalfLife: l.getDuration(ctx, "SIMILARITY_RECENCY_HALF_LIFE", 0),
 	}
 
 	// Load Redis config
@@ -158,8 +354,18 @@ func (l *Loader) Load(ctx context.Context) (*Config, error) {
 
 	// Load Claude config
 	cfg.Claude = ClaudeConfig{
-		APIKey: l.getString(ctx, "CLAUDE_API_KEY", ""),
-		Model:  l.getString(ctx, "CLAUDE_MODEL", "claude-3-haiku-20240307"),
+		APIKey:        l.getString(ctx, "CLAUDE_API_KEY", ""),
+		Model:         l.getString(ctx, "CLAUDE_MODEL", "claude-3-haiku-20240307"),
+		AllowedModels: l.getSlice(ctx, "CLAUDE_ALLOWED_MODELS", []string{}),
+
+		DefaultConfidence: l.getFloat(ctx, "CLAUDE_DEFAULT_CONFIDENCE", 0.8),
+		Temperature:       l.getFloat(ctx, "CLAUDE_TEMPERATURE", 0.1),
+		MinTemperature:    l.getFloat(ctx, "CLAUDE_MIN_TEMPERATURE", 0),
+		MaxTemperature:    l.getFloat(ctx, "CLAUDE_MAX_TEMPERATURE", 1),
+		StructuredOutput:  l.getBool(ctx, "CLAUDE_STRUCTURED_OUTPUT", true),
+
+		ModelContextWindows:  l.getIntMap(ctx, "CLAUDE_MODEL_CONTEXT_WINDOWS", map[string]int{"claude-*": 200000}),
+		DefaultContextWindow: l.getInt(ctx, "CLAUDE_DEFAULT_CONTEXT_WINDOW", 16384),
 	}
 
 	// Load Mimir config
@@ -170,17 +376,47 @@ func (l *Loader) Load(ctx context.Context) (*Config, error) {
 		Password:    l.getString(ctx, "MIMIR_PASSWORD", ""),
 		BearerToken: l.getString(ctx, "MIMIR_BEARER_TOKEN", ""),
 		TenantID:    l.getString(ctx, "MIMIR_TENANT_ID", "demo"),
+		Tenants:     l.getSlice(ctx, "MIMIR_TENANTS", []string{}),
 		Timeout:     l.getDuration(ctx, "MIMIR_TIMEOUT", 30*time.Second),
 		BackendType: l.getString(ctx, "MIMIR_BACKEND_TYPE", "auto"),
+		TLSCertFile: l.getString(ctx, "MIMIR_TLS_CERT_FILE", ""),
+		TLSKeyFile:  l.getString(ctx, "MIMIR_TLS_KEY_FILE", ""),
+		TLSCAFile:   l.getString(ctx, "MIMIR_TLS_CA_FILE", ""),
+		TLSCertPEM:  l.getString(ctx, "MIMIR_TLS_CERT", ""),
+		TLSKeyPEM:   l.getString(ctx, "MIMIR_TLS_KEY", ""),
+		TLSCAPEM:    l.getString(ctx, "MIMIR_TLS_CA", ""),
 	}
 
 	// Load Discovery config
+	detectNamespace := l.getBool(ctx, "DEFAULT_NAMESPACE_AUTODETECT", false)
+	defaultNamespace := DefaultNamespace
+	if detectNamespace {
+		defaultNamespace = l.detectNamespace()
+	}
 	cfg.Discovery = DiscoveryConfig{
 		Enabled:           l.getBool(ctx, "DISCOVERY_ENABLED", true),
 		Interval:          l.getDuration(ctx, "DISCOVERY_INTERVAL", 5*time.Minute),
 		Namespaces:        l.getSlice(ctx, "DISCOVERY_NAMESPACES", []string{}),
 		ServiceLabelNames: l.getSlice(ctx, "SERVICE_LABEL_NAMES", []string{"service", "job", "app"}),
 		ExcludeMetrics:    l.getSlice(ctx, "EXCLUDE_METRICS", []string{"go_.*", "process_.*"}),
+		ExcludeNamespaces: l.getSlice(ctx, "DISCOVERY_EXCLUDE_NAMESPACES", []string{}),
+		MaxRetries:        l.getInt(ctx, "DISCOVERY_MAX_RETRIES", 3),
+		RetryBaseDelay:    l.getDuration(ctx, "DISCOVERY_RETRY_BASE_DELAY", 1*time.Second),
+		RetryMaxDelay:     l.getDuration(ctx, "DISCOVERY_RETRY_MAX_DELAY", 30*time.Second),
+		FailureThreshold:  l.getInt(ctx, "DISCOVERY_FAILURE_THRESHOLD", 3),
+		MaxLabelValues:    l.getInt(ctx, "DISCOVERY_MAX_LABEL_VALUES", 1000),
+		CallTimeout:       l.getDuration(ctx, "DISCOVERY_CALL_TIMEOUT", 10*time.Second),
+
+		DefaultNamespace:       l.getString(ctx, "DEFAULT_NAMESPACE", defaultNamespace),
+		DetectDefaultNamespace: detectNamespace,
+
+		ServiceExcludeMetrics: l.getPatternMap(ctx, "DISCOVERY_SERVICE_EXCLUDE_METRICS"),
+
+		ServiceSignalWeights: l.getFloatMap(ctx, "DISCOVERY_SERVICE_SIGNAL_WEIGHTS"),
+		MinServiceConfidence: l.getFloat(ctx, "DISCOVERY_MIN_SERVICE_CONFIDENCE", 0.5),
+
+		NormalizeServiceNames: l.getBool(ctx, "DISCOVERY_NORMALIZE_SERVICE_NAMES", false),
+		ServiceNameSeparator:  l.getString(ctx, "DISCOVERY_SERVICE_NAME_SEPARATOR", "-"),
 	}
 
 	// Load Auth config
@@ -190,12 +426,33 @@ func (l *Loader) Load(ctx context.Context) (*Config, error) {
 		SessionExpiry:  l.getDuration(ctx, "SESSION_EXPIRY", 7*24*time.Hour),
 		RateLimit:      l.getInt(ctx, "RATE_LIMIT", 100),
 		AllowAnonymous: l.getBool(ctx, "ALLOW_ANONYMOUS", false),
+		RoleHierarchy:  l.getSlice(ctx, "AUTH_ROLE_HIERARCHY", []string{}),
+		JWTIssuer:      l.getString(ctx, "JWT_ISSUER", "observability-ai"),
+		JWTAudience:    l.getString(ctx, "JWT_AUDIENCE", ""),
+
+		GitHubClientID:     l.getString(ctx, "GITHUB_CLIENT_ID", ""),
+		GitHubClientSecret: l.getString(ctx, "GITHUB_CLIENT_SECRET", ""),
+		GitHubRedirectURL:  l.getString(ctx, "GITHUB_REDIRECT_URL", ""),
+		GitHubAllowedOrgs:  l.getSlice(ctx, "GITHUB_ALLOWED_ORGS", []string{}),
+		GitHubRoleMapping:  l.getStringMap(ctx, "GITHUB_ROLE_MAPPING"),
+		GitHubURL:          l.getString(ctx, "GITHUB_URL", "https://github.com"),
+		GitHubAPIURL:       l.getString(ctx, "GITHUB_API_URL", "https://api.github.com"),
 	}
 
 	// Load Server config
 	cfg.Server = ServerConfig{
 		Port:    l.getString(ctx, "PORT", "8080"),
 		GinMode: l.getString(ctx, "GIN_MODE", "debug"),
+
+		TrustedProxies: l.getSlice(ctx, "TRUSTED_PROXIES", []string{}),
+
+		MaintenanceMode:    l.getBool(ctx, "MAINTENANCE_MODE", false),
+		MaintenanceMessage: l.getString(ctx, "MAINTENANCE_MESSAGE", ""),
+
+		RequestLogSampleRate:   l.getInt(ctx, "REQUEST_LOG_SAMPLE_RATE", 1),
+		RequestLogRedactParams: l.getSlice(ctx, "REQUEST_LOG_REDACT_PARAMS", []string{"q", "query", "token", "api_key", "access_token"}),
+
+		StrictJSON: l.getBool(ctx, "STRICT_JSON_REQUESTS", false),
 	}
 
 	// Load Query config
@@ -208,12 +465,72 @@ func (l *Loader) Load(ctx context.Context) (*Config, error) {
 		MaxNestingDepth:      l.getInt(ctx, "MAX_NESTING_DEPTH", 3),
 		MaxTimeRangeDays:     l.getInt(ctx, "MAX_TIME_RANGE_DAYS", 7),
 		EnableSafetyChecks:   l.getBool(ctx, "ENABLE_SAFETY_CHECKS", true),
+		RequireLabelMatchers: l.getBool(ctx, "SAFETY_REQUIRE_LABEL_MATCHERS", false),
 		ForbiddenMetricNames: l.getSlice(ctx, "FORBIDDEN_METRIC_NAMES", []string{".*_secret.*", ".*_password.*", ".*_token.*", ".*_key.*"}),
+		SlowQueryThreshold:   l.getDuration(ctx, "SLOW_QUERY_THRESHOLD", 5*time.Second),
+		BatchQueryTimeout:    l.getDuration(ctx, "BATCH_QUERY_TIMEOUT", 15*time.Second),
+		BatchTimeout:         l.getDuration(ctx, "BATCH_TIMEOUT", 30*time.Second),
+		MaxContextEntries:    l.getInt(ctx, "MAX_CONTEXT_ENTRIES", 20),
+		MaxContextLength:     l.getInt(ctx, "MAX_CONTEXT_LENGTH", 1024),
+		ContextLabelKeys:     l.getSlice(ctx, "CONTEXT_LABEL_KEYS", []string{}),
+		ConfirmCostThreshold: l.getInt(ctx, "CONFIRM_COST_THRESHOLD", 0),
+		Timezone:             l.getString(ctx, "QUERY_TIMEZONE", "UTC"),
+		MaxPromptServices:    l.getInt(ctx, "MAX_PROMPT_SERVICES", 50),
+		MaxInFlightQueries:   l.getInt(ctx, "MAX_INFLIGHT_QUERIES", 0),
+		FingerprintRateLimit: l.getInt(ctx, "QUERY_FINGERPRINT_RATE_LIMIT", 0),
+		MetricAliases:        l.getStringMap(ctx, "METRIC_ALIASES"),
+		MetricTypeOverrides:  l.getPatternMap(ctx, "METRIC_TYPE_OVERRIDES"),
+		DeprecatedMetrics:    l.getSlice(ctx, "DEPRECATED_METRICS", []string{}),
+		DeprecatedMetricMode: l.getString(ctx, "DEPRECATED_METRIC_MODE", "warn"),
+		SupportedLanguages:   l.getSlice(ctx, "SUPPORTED_LANGUAGES", []string{}),
+
+		MetricDisplayPrefixes: l.getSlice(ctx, "METRIC_DISPLAY_PREFIXES", []string{}),
+		ResultUnitFormats:     l.getStringMap(ctx, "RESULT_UNIT_FORMATS"),
+		PinnedExamplesFile:    l.getString(ctx, "PINNED_EXAMPLES_FILE", ""),
+		PromptMetricHelp:      l.getBool(ctx, "PROMPT_METRIC_HELP", false),
+		DuplicateServiceMode:  l.getString(ctx, "DUPLICATE_SERVICE_MODE", "clarify"),
+		PostProcessorOrder:    l.getSlice(ctx, "POST_PROCESSOR_ORDER", []string{}),
+		QueryDenyPatterns:     l.getSlice(ctx, "QUERY_DENY_PATTERNS", []string{}),
+		QueryAllowPatterns:    l.getSlice(ctx, "QUERY_ALLOW_PATTERNS", []string{}),
+
+		MaxSubqueryRange: l.getDuration(ctx, "SAFETY_MAX_SUBQUERY_RANGE", 24*time.Hour),
+		MinSubqueryStep:  l.getDuration(ctx, "SAFETY_MIN_SUBQUERY_STEP", time.Minute),
+		MaxSubqueryDepth: l.getInt(ctx, "SAFETY_MAX_SUBQUERY_DEPTH", 1),
+
+		EvaluationSampleRate:       l.getFloat(ctx, "EVALUATION_SAMPLE_RATE", 0),
+		EvaluationSampleMaxPerHour: l.getInt(ctx, "EVALUATION_SAMPLE_MAX_PER_HOUR", 100),
+
+		EmbeddingCache: l.getBool(ctx, "EMBEDDING_CACHE_ENABLED", false),
+
+		AdminOnlyMetadata: l.getSlice(ctx, "ADMIN_ONLY_METADATA_FIELDS", []string{"mimir_request", "telemetry", "stage_timings_ms", "confirmation_threshold"}),
+	}
+
+	// Load Notify config
+	cfg.Notify = NotifyConfig{
+		WebhookURL:     l.getString(ctx, "NOTIFY_WEBHOOK_URL", ""),
+		WebhookTimeout: l.getDuration(ctx, "NOTIFY_WEBHOOK_TIMEOUT", 5*time.Second),
 	}
 
 	return cfg, nil
 }
 
+// namespaceProvider is implemented by secret providers that know the
+// namespace the process runs in
+type namespaceProvider interface {
+	GetNamespace() string
+}
+
+// detectNamespace returns the namespace reported by the secret provider, or
+// DefaultNamespace if it reports none
+func (l *Loader) detectNamespace() string {
+	if np, ok := l.provider.(namespaceProvider); ok {
+		if namespace := np.GetNamespace(); namespace != "" {
+			return namespace
+		}
+	}
+	return DefaultNamespace
+}
+
 // Helper methods for retrieving and parsing configuration values
 
 func (l *Loader) getString(ctx context.Context, key, defaultValue string) string {
@@ -250,6 +567,19 @@ func (l *Loader) getInt(ctx context.Context, key string, defaultValue int) int {
 	return i
 }
 
+func (l *Loader) getFloat(ctx context.Context, key string, defaultValue float64) float64 {
+	value, err := l.provider.GetSecret(ctx, key)
+	if err != nil || value == "" {
+		return defaultValue
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return f
+}
+
 func (l *Loader) getDuration(ctx context.Context, key string, defaultValue time.Duration) time.Duration {
 	value, err := l.provider.GetSecret(ctx, key)
 	if err != nil || value == "" {
@@ -284,6 +614,89 @@ func (l *Loader) getSlice(ctx context.Context, key string, defaultValue []string
 	return result
 }
 
+// getStringMap parses "key=value,key=value", skipping entries without a key
+// or value
+func (l *Loader) getStringMap(ctx context.Context, key string) map[string]string {
+	value, err := l.provider.GetSecret(ctx, key)
+	if err != nil || value == "" {
+		return nil
+	}
+
+	result := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		name, mapped, found := strings.Cut(entry, "=")
+		name, mapped = strings.TrimSpace(name), strings.TrimSpace(mapped)
+		if found && name != "" && mapped != "" {
+			result[name] = mapped
+		}
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// getIntMap parses "key=integer,key=integer", skipping entries without a key
+// or whose value is not an integer
+func (l *Loader) getIntMap(ctx context.Context, key string, defaultValue map[string]int) map[string]int {
+	result := make(map[string]int)
+	for name, value := range l.getStringMap(ctx, key) {
+		if i, err := strconv.Atoi(value); err == nil {
+			result[name] = i
+		}
+	}
+
+	if len(result) == 0 {
+		return defaultValue
+	}
+	return result
+}
+
+// getFloatMap parses "key=number,key=number", skipping entries without a key
+// or whose value is not a number
+func (l *Loader) getFloatMap(ctx context.Context, key string) map[string]float64 {
+	result := make(map[string]float64)
+	for name, value := range l.getStringMap(ctx, key) {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			result[name] = f
+		}
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// getPatternMap parses "key=pattern,pattern;key=pattern" into patterns by
+// key, skipping entries without a key or patterns
+func (l *Loader) getPatternMap(ctx context.Context, key string) map[string][]string {
+	value, err := l.provider.GetSecret(ctx, key)
+	if err != nil || value == "" {
+		return nil
+	}
+
+	result := make(map[string][]string)
+	for _, entry := range strings.Split(value, ";") {
+		name, patterns, found := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			continue
+		}
+		for _, pattern := range strings.Split(patterns, ",") {
+			if trimmed := strings.TrimSpace(pattern); trimmed != "" {
+				result[name] = append(result[name], trimmed)
+			}
+		}
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
 // MustLoad loads configuration and panics on error
 // Useful for application startup
 func (l *Loader) MustLoad(ctx context.Context) *Config {
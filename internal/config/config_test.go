@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -651,3 +652,99 @@ func TestK8sProviderNamespaceDetection(t *testing.T) {
 		}
 	})
 }
+
+func TestDefaultNamespace(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("falls back to default outside kubernetes", func(t *testing.T) {
+		os.Unsetenv("DEFAULT_NAMESPACE")
+		cfg, err := NewLoader(NewEnvProvider()).Load(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Discovery.DefaultNamespace != DefaultNamespace {
+			t.Errorf("expected '%s', got '%s'", DefaultNamespace, cfg.Discovery.DefaultNamespace)
+		}
+	})
+
+	t.Run("does not detect namespace unless enabled", func(t *testing.T) {
+		os.Unsetenv("DEFAULT_NAMESPACE")
+		chain := NewChainProvider(NewK8sProvider(t.TempDir(), "observability"), NewEnvProvider())
+		cfg, err := NewLoader(chain).Load(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Discovery.DefaultNamespace != DefaultNamespace {
+			t.Errorf("expected '%s', got '%s'", DefaultNamespace, cfg.Discovery.DefaultNamespace)
+		}
+	})
+
+	t.Run("detects namespace from kubernetes provider", func(t *testing.T) {
+		os.Unsetenv("DEFAULT_NAMESPACE")
+		os.Setenv("DEFAULT_NAMESPACE_AUTODETECT", "true")
+		defer os.Unsetenv("DEFAULT_NAMESPACE_AUTODETECT")
+
+		chain := NewChainProvider(NewK8sProvider(t.TempDir(), "observability"), NewEnvProvider())
+		cfg, err := NewLoader(chain).Load(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Discovery.DefaultNamespace != "observability" {
+			t.Errorf("expected 'observability', got '%s'", cfg.Discovery.DefaultNamespace)
+		}
+	})
+
+	t.Run("configured namespace overrides detection", func(t *testing.T) {
+		os.Setenv("DEFAULT_NAMESPACE", "platform")
+		defer os.Unsetenv("DEFAULT_NAMESPACE")
+		os.Setenv("DEFAULT_NAMESPACE_AUTODETECT", "true")
+		defer os.Unsetenv("DEFAULT_NAMESPACE_AUTODETECT")
+
+		chain := NewChainProvider(NewK8sProvider(t.TempDir(), "observability"), NewEnvProvider())
+		cfg, err := NewLoader(chain).Load(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Discovery.DefaultNamespace != "platform" {
+			t.Errorf("expected 'platform', got '%s'", cfg.Discovery.DefaultNamespace)
+		}
+	})
+}
+
+func TestServiceExcludeMetrics(t *testing.T) {
+	ctx := context.Background()
+	os.Setenv("DISCOVERY_SERVICE_EXCLUDE_METRICS", "payments=grpc_client_.*, http_client_.* ; staging/*=debug_.*;=ignored;no-patterns=")
+	defer os.Unsetenv("DISCOVERY_SERVICE_EXCLUDE_METRICS")
+
+	cfg, err := NewLoader(NewEnvProvider()).Load(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string][]string{
+		"payments":  {"grpc_client_.*", "http_client_.*"},
+		"staging/*": {"debug_.*"},
+	}
+	if !reflect.DeepEqual(cfg.Discovery.ServiceExcludeMetrics, expected) {
+		t.Errorf("expected %v, got %v", expected, cfg.Discovery.ServiceExcludeMetrics)
+	}
+}
+
+func TestMetricAliases(t *testing.T) {
+	ctx := context.Background()
+	os.Setenv("METRIC_ALIASES", "requests=http_requests_total, request latency = http_request_duration_seconds_bucket,=ignored,empty=")
+	defer os.Unsetenv("METRIC_ALIASES")
+
+	cfg, err := NewLoader(NewEnvProvider()).Load(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"requests":        "http_requests_total",
+		"request latency": "http_request_duration_seconds_bucket",
+	}
+	if !reflect.DeepEqual(cfg.Query.MetricAliases, expected) {
+		t.Errorf("expected %v, got %v", expected, cfg.Query.MetricAliases)
+	}
+}
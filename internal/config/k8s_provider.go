@@ -3,6 +3,7 @@ package config
 import (
 	"context"
 	"os"
+	"strings"
 )
 
 // K8sProvider retrieves secrets from Kubernetes using in-cluster service account
@@ -24,9 +25,10 @@ func NewK8sProvider(secretsPath, namespace string) *K8sProvider {
 	if namespace == "" {
 		// Try to detect namespace from pod
 		if ns, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
-			namespace = string(ns)
-		} else {
-			namespace = "default"
+			namespace = strings.TrimSpace(string(ns))
+		}
+		if namespace == "" {
+			namespace = DefaultNamespace
 		}
 	}
 
@@ -66,3 +66,16 @@ func (c *ChainProvider) IsAvailable(ctx context.Context) bool {
 	}
 	return false
 }
+
+// GetNamespace returns the namespace of the first provider in the chain that
+// reports one, or "" if none does
+func (c *ChainProvider) GetNamespace() string {
+	for _, provider := range c.providers {
+		if np, ok := provider.(namespaceProvider); ok {
+			if namespace := np.GetNamespace(); namespace != "" {
+				return namespace
+			}
+		}
+	}
+	return ""
+}
@@ -2,9 +2,36 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/seanankenbruck/observability-ai/internal/metrics"
 )
 
+// labelNamePattern matches valid Prometheus label names
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// metricNamePrefixPattern matches strings that can start a metric name
+var metricNamePrefixPattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// postProcessors are the names of the built-in post-processors of generated
+// queries
+var postProcessors = map[string]bool{"aliases": true, "safety": true, "deprecated": true}
+
+// resultFormats are the formats result values can be rendered in
+var resultFormats = map[string]bool{"duration": true, "percent": true, "bytes": true, "number": true, "none": true}
+
+// durationUnits are the metric units that can be formatted as durations
+var durationUnits = map[string]bool{
+	"nanoseconds": true, "microseconds": true, "milliseconds": true, "seconds": true,
+	"minutes": true, "hours": true, "days": true,
+}
+
 // ValidationError represents a configuration validation error
 type ValidationError struct {
 	Field   string
@@ -61,6 +88,12 @@ func (c *Config) Validate() error {
 	// Validate Query config
 	errors = append(errors, c.validateQuery()...)
 
+	// Validate Discovery config
+	errors = append(errors, c.validateDiscovery()...)
+
+	// Validate Notify config
+	errors = append(errors, c.validateNotify()...)
+
 	if errors.HasErrors() {
 		return errors
 	}
@@ -99,6 +132,21 @@ func (c *Config) validateDatabase() []ValidationError {
 		})
 	}
 
+	// An empty distance metric selects cosine
+	if metric := c.Database.DistanceMetric; metric != "" && metric != "cosine" && metric != "l2" && metric != "inner_product" {
+		errors = append(errors, ValidationError{
+			Field:   "Database.DistanceMetric",
+			Message: fmt.Sprintf("invalid embedding distance metric: %s (must be 'cosine', 'l2', or 'inner_product')", metric),
+		})
+	}
+
+	if c.Database.RecencyHalfLife < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "Database.RecencyHalfLife",
+			Message: "similarity recency half-life must be non-negative",
+		})
+	}
+
 	return errors
 }
 
@@ -132,6 +180,51 @@ func (c *Config) validateClaude() []ValidationError {
 		})
 	}
 
+	if c.Claude.DefaultConfidence < 0 || c.Claude.DefaultConfidence > 1 {
+		errors = append(errors, ValidationError{
+			Field:   "Claude.DefaultConfidence",
+			Message: "default confidence must be between 0 and 1",
+		})
+	}
+
+	if c.Claude.MinTemperature < 0 || c.Claude.MaxTemperature > 1 || c.Claude.MinTemperature > c.Claude.MaxTemperature {
+		errors = append(errors, ValidationError{
+			Field:   "Claude.MaxTemperature",
+			Message: "temperature range must satisfy 0 <= min <= max <= 1",
+		})
+	} else if c.Claude.Temperature < c.Claude.MinTemperature || c.Claude.Temperature > c.Claude.MaxTemperature {
+		errors = append(errors, ValidationError{
+			Field:   "Claude.Temperature",
+			Message: fmt.Sprintf("temperature must be between %g and %g", c.Claude.MinTemperature, c.Claude.MaxTemperature),
+		})
+	}
+
+	models := make([]string, 0, len(c.Claude.ModelContextWindows))
+	for model := range c.Claude.ModelContextWindows {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	for _, model := range models {
+		if _, err := path.Match(model, ""); err != nil {
+			errors = append(errors, ValidationError{
+				Field:   "Claude.ModelContextWindows",
+				Message: fmt.Sprintf("invalid model glob %q: %v", model, err),
+			})
+		} else if c.Claude.ModelContextWindows[model] <= 0 {
+			errors = append(errors, ValidationError{
+				Field:   "Claude.ModelContextWindows",
+				Message: fmt.Sprintf("context window of %s must be positive", model),
+			})
+		}
+	}
+
+	if c.Claude.DefaultContextWindow < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "Claude.DefaultContextWindow",
+			Message: "default context window must be non-negative",
+		})
+	}
+
 	return errors
 }
 
@@ -161,12 +254,21 @@ func (c *Config) validateMimir() []ValidationError {
 				Message: "bearer auth requires a token",
 			})
 		}
+	case "tls":
+		hasCert := c.Mimir.TLSCertFile != "" || c.Mimir.TLSCertPEM != ""
+		hasKey := c.Mimir.TLSKeyFile != "" || c.Mimir.TLSKeyPEM != ""
+		if !hasCert || !hasKey {
+			errors = append(errors, ValidationError{
+				Field:   "Mimir.TLS",
+				Message: "tls auth requires both a client certificate and key",
+			})
+		}
 	case "none":
 		// No auth required
 	default:
 		errors = append(errors, ValidationError{
 			Field:   "Mimir.AuthType",
-			Message: fmt.Sprintf("invalid auth type: %s (must be 'none', 'basic', or 'bearer')", c.Mimir.AuthType),
+			Message: fmt.Sprintf("invalid auth type: %s (must be 'none', 'basic', 'bearer', or 'tls')", c.Mimir.AuthType),
 		})
 	}
 
@@ -204,6 +306,27 @@ func (c *Config) validateAuth() []ValidationError {
 		})
 	}
 
+	if c.Auth.GitHubClientID != "" {
+		if c.Auth.GitHubClientSecret == "" {
+			errors = append(errors, ValidationError{
+				Field:   "Auth.GitHubClientSecret",
+				Message: "GitHub client secret is required when GitHub login is enabled",
+			})
+		}
+		if c.Auth.GitHubRedirectURL == "" {
+			errors = append(errors, ValidationError{
+				Field:   "Auth.GitHubRedirectURL",
+				Message: "GitHub redirect URL is required when GitHub login is enabled",
+			})
+		}
+		if len(c.Auth.GitHubAllowedOrgs) == 0 {
+			errors = append(errors, ValidationError{
+				Field:   "Auth.GitHubAllowedOrgs",
+				Message: "at least one allowed organization is required when GitHub login is enabled",
+			})
+		}
+	}
+
 	return errors
 }
 
@@ -233,6 +356,80 @@ func (c *Config) validateServer() []ValidationError {
 		})
 	}
 
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errors = append(errors, ValidationError{
+				Field:   "Server.TrustedProxies",
+				Message: fmt.Sprintf("invalid trusted proxy: %s (must be an IP address or CIDR)", proxy),
+			})
+		}
+	}
+
+	if c.Server.RequestLogSampleRate < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "Server.RequestLogSampleRate",
+			Message: "request log sample rate must be non-negative",
+		})
+	}
+
+	return errors
+}
+
+func (c *Config) validateDiscovery() []ValidationError {
+	var errors []ValidationError
+
+	names := make([]string, 0, len(c.Discovery.ServiceSignalWeights))
+	for name := range c.Discovery.ServiceSignalWeights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if c.Discovery.ServiceSignalWeights[name] < 0 {
+			errors = append(errors, ValidationError{
+				Field:   "Discovery.ServiceSignalWeights",
+				Message: fmt.Sprintf("weight of signal %s must be non-negative", name),
+			})
+		}
+	}
+
+	if c.Discovery.MinServiceConfidence < 0 || c.Discovery.MinServiceConfidence > 1 {
+		errors = append(errors, ValidationError{
+			Field:   "Discovery.MinServiceConfidence",
+			Message: "minimum service confidence must be between 0 and 1",
+		})
+	}
+
+	switch c.Discovery.ServiceNameSeparator {
+	case "", "-", "_", ".":
+	default:
+		errors = append(errors, ValidationError{
+			Field:   "Discovery.ServiceNameSeparator",
+			Message: fmt.Sprintf("invalid service name separator %q (must be '-', '_', or '.')", c.Discovery.ServiceNameSeparator),
+		})
+	}
+
+	return errors
+}
+
+func (c *Config) validateNotify() []ValidationError {
+	var errors []ValidationError
+
+	if c.Notify.WebhookURL != "" {
+		if u, err := url.Parse(c.Notify.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errors = append(errors, ValidationError{
+				Field:   "Notify.WebhookURL",
+				Message: fmt.Sprintf("invalid webhook URL %q (must be an http or https URL)", c.Notify.WebhookURL),
+			})
+		}
+	}
+
+	if c.Notify.WebhookTimeout < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "Notify.WebhookTimeout",
+			Message: "webhook timeout must be non-negative",
+		})
+	}
+
 	return errors
 }
 
@@ -267,6 +464,218 @@ func (c *Config) validateQuery() []ValidationError {
 		})
 	}
 
+	if c.Query.SlowQueryThreshold < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "Query.SlowQueryThreshold",
+			Message: "slow query threshold must be non-negative",
+		})
+	}
+
+	if c.Query.BatchQueryTimeout < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "Query.BatchQueryTimeout",
+			Message: "batch query timeout must be non-negative",
+		})
+	}
+
+	if c.Query.BatchTimeout < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "Query.BatchTimeout",
+			Message: "batch timeout must be non-negative",
+		})
+	}
+
+	if c.Query.MaxContextEntries < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "Query.MaxContextEntries",
+			Message: "max context entries must be non-negative",
+		})
+	}
+
+	if c.Query.MaxContextLength < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "Query.MaxContextLength",
+			Message: "max context length must be non-negative",
+		})
+	}
+
+	for _, key := range c.Query.ContextLabelKeys {
+		switch {
+		case strings.HasPrefix(key, "__"):
+			errors = append(errors, ValidationError{
+				Field:   "Query.ContextLabelKeys",
+				Message: fmt.Sprintf("label %q is reserved", key),
+			})
+		case !labelNamePattern.MatchString(key):
+			errors = append(errors, ValidationError{
+				Field:   "Query.ContextLabelKeys",
+				Message: fmt.Sprintf("%q is not a valid label name", key),
+			})
+		}
+	}
+
+	if c.Query.MaxPromptServices < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "Query.MaxPromptServices",
+			Message: "max prompt services must be non-negative",
+		})
+	}
+
+	if c.Query.ConfirmCostThreshold < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "Query.ConfirmCostThreshold",
+			Message: "confirm cost threshold must be non-negative",
+		})
+	}
+
+	if c.Query.MaxInFlightQueries < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "Query.MaxInFlightQueries",
+			Message: "max in-flight queries must be non-negative",
+		})
+	}
+
+	if c.Query.FingerprintRateLimit < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "Query.FingerprintRateLimit",
+			Message: "query fingerprint rate limit must be non-negative",
+		})
+	}
+
+	if c.Query.MaxSubqueryRange < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "Query.MaxSubqueryRange",
+			Message: "max subquery range must be non-negative",
+		})
+	}
+
+	if c.Query.MinSubqueryStep < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "Query.MinSubqueryStep",
+			Message: "min subquery step must be non-negative",
+		})
+	}
+
+	if c.Query.MaxSubqueryDepth < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "Query.MaxSubqueryDepth",
+			Message: "max subquery depth must be non-negative",
+		})
+	}
+
+	if c.Query.EvaluationSampleRate < 0 || c.Query.EvaluationSampleRate > 1 {
+		errors = append(errors, ValidationError{
+			Field:   "Query.EvaluationSampleRate",
+			Message: "evaluation sample rate must be between 0 and 1",
+		})
+	}
+
+	if c.Query.EvaluationSampleMaxPerHour < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "Query.EvaluationSampleMaxPerHour",
+			Message: "evaluation sample cap must be non-negative",
+		})
+	}
+
+	if _, err := metrics.NewTypeOverrides(c.Query.MetricTypeOverrides); err != nil {
+		errors = append(errors, ValidationError{
+			Field:   "Query.MetricTypeOverrides",
+			Message: err.Error(),
+		})
+	}
+
+	for unit, format := range c.Query.ResultUnitFormats {
+		switch {
+		case !resultFormats[format]:
+			errors = append(errors, ValidationError{
+				Field:   "Query.ResultUnitFormats",
+				Message: fmt.Sprintf("unknown format %q for unit %q; valid formats: duration, percent, bytes, number, none", format, unit),
+			})
+		case format == "duration" && !durationUnits[unit]:
+			errors = append(errors, ValidationError{
+				Field:   "Query.ResultUnitFormats",
+				Message: fmt.Sprintf("unit %q is not a time unit and cannot be formatted as a duration", unit),
+			})
+		}
+	}
+
+	for _, pattern := range c.Query.DeprecatedMetrics {
+		if _, err := regexp.Compile("^(?:" + pattern + ")$"); err != nil {
+			errors = append(errors, ValidationError{
+				Field:   "Query.DeprecatedMetrics",
+				Message: fmt.Sprintf("invalid pattern %q: %v", pattern, err),
+			})
+		}
+	}
+
+	for _, pattern := range c.Query.QueryDenyPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errors = append(errors, ValidationError{
+				Field:   "Query.QueryDenyPatterns",
+				Message: fmt.Sprintf("invalid pattern %q: %v", pattern, err),
+			})
+		}
+	}
+	for _, pattern := range c.Query.QueryAllowPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errors = append(errors, ValidationError{
+				Field:   "Query.QueryAllowPatterns",
+				Message: fmt.Sprintf("invalid pattern %q: %v", pattern, err),
+			})
+		}
+	}
+
+	for _, prefix := range c.Query.MetricDisplayPrefixes {
+		if !metricNamePrefixPattern.MatchString(prefix) {
+			errors = append(errors, ValidationError{
+				Field:   "Query.MetricDisplayPrefixes",
+				Message: fmt.Sprintf("%q is not a metric name prefix", prefix),
+			})
+		}
+	}
+
+	switch c.Query.DeprecatedMetricMode {
+	case "", "warn", "reject":
+	default:
+		errors = append(errors, ValidationError{
+			Field:   "Query.DeprecatedMetricMode",
+			Message: fmt.Sprintf("unknown deprecated metric mode %q (must be warn or reject)", c.Query.DeprecatedMetricMode),
+		})
+	}
+
+	switch c.Query.DuplicateServiceMode {
+	case "", "clarify", "all":
+	default:
+		errors = append(errors, ValidationError{
+			Field:   "Query.DuplicateServiceMode",
+			Message: fmt.Sprintf("unknown duplicate service mode %q (must be clarify or all)", c.Query.DuplicateServiceMode),
+		})
+	}
+
+	listed := make(map[string]bool)
+	for _, name := range c.Query.PostProcessorOrder {
+		switch {
+		case !postProcessors[name]:
+			errors = append(errors, ValidationError{
+				Field:   "Query.PostProcessorOrder",
+				Message: fmt.Sprintf("unknown post-processor %q (must be aliases, safety or deprecated)", name),
+			})
+		case listed[name]:
+			errors = append(errors, ValidationError{
+				Field:   "Query.PostProcessorOrder",
+				Message: fmt.Sprintf("post-processor %q is listed twice", name),
+			})
+		}
+		listed[name] = true
+	}
+
+	if _, err := time.LoadLocation(c.Query.Timezone); err != nil {
+		errors = append(errors, ValidationError{
+			Field:   "Query.Timezone",
+			Message: fmt.Sprintf("unknown timezone %q", c.Query.Timezone),
+		})
+	}
+
 	if c.Query.MaxQueryLength <= 0 {
 		errors = append(errors, ValidationError{
 			Field:   "Query.MaxQueryLength",
@@ -2,6 +2,8 @@
 package errors
 
 import (
+	"context"
+	stderrors "errors"
 	"fmt"
 	"strings"
 )
@@ -16,6 +18,7 @@ const (
 	ErrCodePromptBuilding       ErrorCode = "PROMPT_BUILD_FAILED"
 	ErrCodeQueryGeneration      ErrorCode = "QUERY_GENERATION_FAILED"
 	ErrCodeSafetyValidation     ErrorCode = "SAFETY_VALIDATION_FAILED"
+	ErrCodePostProcessing       ErrorCode = "POST_PROCESSING_FAILED"
 
 	// Safety check errors
 	ErrCodeForbiddenMetric    ErrorCode = "FORBIDDEN_METRIC"
@@ -23,11 +26,14 @@ const (
 	ErrCodeHighCardinality    ErrorCode = "HIGH_CARDINALITY"
 	ErrCodeExpensiveOperation ErrorCode = "EXPENSIVE_OPERATION"
 	ErrCodeTooManyNested      ErrorCode = "TOO_MANY_NESTED_OPS"
+	ErrCodeDeprecatedMetric   ErrorCode = "DEPRECATED_METRIC"
+	ErrCodeExpensiveSubquery  ErrorCode = "EXPENSIVE_SUBQUERY"
 
 	// Database errors
 	ErrCodeDatabaseConnection ErrorCode = "DATABASE_CONNECTION_FAILED"
 	ErrCodeDatabaseQuery      ErrorCode = "DATABASE_QUERY_FAILED"
 	ErrCodeServiceNotFound    ErrorCode = "SERVICE_NOT_FOUND"
+	ErrCodeMetricNotFound     ErrorCode = "METRIC_NOT_FOUND"
 
 	// Authentication errors
 	ErrCodeInvalidCredentials ErrorCode = "INVALID_CREDENTIALS"
@@ -44,6 +50,27 @@ const (
 	// Cache errors
 	ErrCodeCacheRead  ErrorCode = "CACHE_READ_FAILED"
 	ErrCodeCacheWrite ErrorCode = "CACHE_WRITE_FAILED"
+
+	// Discovery errors
+	ErrCodeDiscovery ErrorCode = "DISCOVERY_FAILED"
+
+	// Capacity errors
+	ErrCodeOverloaded       ErrorCode = "SERVICE_OVERLOADED"
+	ErrCodeMaintenance      ErrorCode = "MAINTENANCE_MODE"
+	ErrCodeQueryRateLimited ErrorCode = "QUERY_RATE_LIMITED"
+
+	// Request lifecycle errors
+	ErrCodeRequestCancelled ErrorCode = "REQUEST_CANCELLED"
+	ErrCodeRequestTimeout   ErrorCode = "REQUEST_TIMEOUT"
+)
+
+// Dependencies reported in the "dependency" metadata of errors caused by a
+// failing external dependency
+const (
+	DependencyLLM      = "llm"
+	DependencyDatabase = "database"
+	DependencyCache    = "cache"
+	DependencyMimir    = "mimir"
 )
 
 // EnhancedError represents an error with additional context and helpful information
@@ -135,6 +162,22 @@ func (e *EnhancedError) WithMetadata(key string, value interface{}) *EnhancedErr
 	return e
 }
 
+// WithDependency records which external dependency caused the error
+func (e *EnhancedError) WithDependency(dependency string) *EnhancedError {
+	return e.WithMetadata("dependency", dependency)
+}
+
+// Dependency returns the failed dependency recorded on err, or an empty string
+// if err is not an EnhancedError or was not caused by a dependency
+func Dependency(err error) string {
+	enhancedErr, ok := err.(*EnhancedError)
+	if !ok {
+		return ""
+	}
+	dependency, _ := enhancedErr.Metadata["dependency"].(string)
+	return dependency
+}
+
 // Common error constructors with pre-configured messages
 
 // NewIntentClassificationError creates an error for intent classification failures
@@ -149,14 +192,25 @@ func NewEmbeddingGenerationError(err error) *EnhancedError {
 	return Wrap(err, ErrCodeEmbeddingGeneration, "Failed to generate query embedding").
 		WithDetails("The AI service was unable to process your query for semantic search").
 		WithSuggestion("This is typically a temporary issue. Please try your query again in a moment.").
-		WithMetadata("retryable", true)
+		WithMetadata("retryable", true).
+		WithDependency(DependencyLLM)
 }
 
 // NewQueryGenerationError creates an error for PromQL generation failures
 func NewQueryGenerationError(err error) *EnhancedError {
 	return Wrap(err, ErrCodeQueryGeneration, "Failed to generate PromQL query").
 		WithDetails("The AI was unable to convert your natural language query to PromQL").
-		WithSuggestion("Try simplifying your query or being more specific about the metrics you want to query.")
+		WithSuggestion("Try simplifying your query or being more specific about the metrics you want to query.").
+		WithDependency(DependencyLLM)
+}
+
+// NewPostProcessingError creates an error for a generated query rejected by
+// a post-processor
+func NewPostProcessingError(processor string, err error) *EnhancedError {
+	return Wrap(err, ErrCodePostProcessing, "Generated query was rejected").
+		WithDetails(fmt.Sprintf("The %s post-processor rejected the generated query: %v", processor, err)).
+		WithSuggestion("Try rephrasing your query or being more specific about the metrics you want to query.").
+		WithMetadata("post_processor", processor)
 }
 
 // NewForbiddenMetricError creates an error for forbidden metric access
@@ -180,6 +234,30 @@ func NewHighCardinalityError() *EnhancedError {
 		WithSuggestion("Add more specific label filters or use aggregation functions like sum(), avg(), or max(). Avoid queries that group by no labels or use 'without ()'.")
 }
 
+// NewUnscopedSelectorError creates an error for a metric selector without label matchers
+func NewUnscopedSelectorError(metric string) *EnhancedError {
+	return New(ErrCodeHighCardinality, "Query selects a metric without any label matcher").
+		WithDetails(fmt.Sprintf("The selector %s matches every series of the metric, which can return an enormous number of series", metric)).
+		WithSuggestion(fmt.Sprintf("Scope the metric to a service or job, e.g. %s{service=\"<name>\"}, or name the service in your question.", metric)).
+		WithMetadata("metric_name", metric)
+}
+
+// NewExpensiveSubqueryError creates an error for a subquery exceeding a safety limit
+func NewExpensiveSubqueryError(subquery, limit string) *EnhancedError {
+	return New(ErrCodeExpensiveSubquery, "Query contains an overly expensive subquery").
+		WithDetails(fmt.Sprintf("The subquery %s exceeds the %s; subqueries evaluate their inner expression at every step of their range", subquery, limit)).
+		WithSuggestion("Shorten the subquery range or coarsen its resolution (e.g. [1h:1m]), avoid nesting subqueries, or use a recording rule for long-range aggregations.").
+		WithMetadata("subquery", subquery)
+}
+
+// NewDeprecatedMetricError creates an error for a query selecting a deprecated metric
+func NewDeprecatedMetricError(metric string) *EnhancedError {
+	return New(ErrCodeDeprecatedMetric, "Query uses a deprecated metric").
+		WithDetails(fmt.Sprintf("The metric %s is deprecated and may no longer report data, so the query would likely return nothing", metric)).
+		WithSuggestion("Rephrase your question or name a current metric from the catalog.").
+		WithMetadata("metric_name", metric)
+}
+
 // NewExpensiveOperationError creates an error for expensive operations
 func NewExpensiveOperationError(operation string) *EnhancedError {
 	return New(ErrCodeExpensiveOperation, "Query contains potentially expensive operation").
@@ -195,6 +273,14 @@ func NewServiceNotFoundError(serviceName string) *EnhancedError {
 		WithMetadata("service_name", serviceName)
 }
 
+// NewMetricNotFoundError creates an error for a metric missing from the catalog
+func NewMetricNotFoundError(metricName string) *EnhancedError {
+	return New(ErrCodeMetricNotFound, "Metric not found").
+		WithDetails(fmt.Sprintf("No discovered service exposes a metric named: %s", metricName)).
+		WithSuggestion("Check the metric name for typos. Use the /api/v1/metrics endpoint to see all available metrics. Newly exported metrics appear after the next discovery cycle.").
+		WithMetadata("metric_name", metricName)
+}
+
 // NewInvalidCredentialsError creates an error for authentication failures
 func NewInvalidCredentialsError() *EnhancedError {
 	return New(ErrCodeInvalidCredentials, "Invalid username or password").
@@ -232,11 +318,90 @@ func NewInvalidInputError(field string, reason string) *EnhancedError {
 		WithSuggestion("Please check the API documentation for the expected format and try again.")
 }
 
+// unknownFieldPrefix starts the error encoding/json returns for a field the
+// request type does not have, when unknown fields are disallowed
+const unknownFieldPrefix = `json: unknown field "`
+
+// NewQueryNotAllowedError creates an error for a query rejected by the
+// server's query policy
+func NewQueryNotAllowedError(details string) *EnhancedError {
+	return New(ErrCodeInvalidInput, "Query is not allowed").
+		WithDetails(details).
+		WithSuggestion("Rephrase your question about your services' metrics, or contact your administrator if you believe it should be allowed.")
+}
+
+// NewRequestBodyError creates an error for a request body that could not be
+// decoded, naming the field when it is not recognized
+func NewRequestBodyError(err error) *EnhancedError {
+	message := err.Error()
+	if !strings.HasPrefix(message, unknownFieldPrefix) {
+		return NewInvalidInputError("request body", message)
+	}
+
+	field := strings.TrimSuffix(strings.TrimPrefix(message, unknownFieldPrefix), `"`)
+	return New(ErrCodeInvalidInput, "Invalid input").
+		WithDetails(fmt.Sprintf("Field '%s' is not recognized", field)).
+		WithSuggestion("Check the field name for typos; the API documentation lists the accepted fields.").
+		WithMetadata("field", field)
+}
+
 // NewDatabaseConnectionError creates an error for database connection failures
 func NewDatabaseConnectionError(err error) *EnhancedError {
 	return Wrap(err, ErrCodeDatabaseConnection, "Database connection failed").
 		WithDetails("Unable to connect to the database").
 		WithSuggestion("This is an internal server error. The service may be experiencing issues. Please try again in a moment.").
+		WithMetadata("retryable", true).
+		WithDependency(DependencyDatabase)
+}
+
+// NewDiscoveryError creates an error for service discovery failures
+func NewDiscoveryError(err error, operation string) *EnhancedError {
+	return Wrap(err, ErrCodeDiscovery, "Service discovery failed").
+		WithDetails(fmt.Sprintf("Failed to %s", operation)).
+		WithSuggestion("Check that the Prometheus/Mimir endpoint is reachable and try again.").
+		WithMetadata("retryable", true).
+		WithDependency(DependencyMimir)
+}
+
+// NewOverloadedError creates an error for requests rejected because the
+// service is at its concurrency limit
+func NewOverloadedError(retryAfterSeconds int) *EnhancedError {
+	return New(ErrCodeOverloaded, "Too many queries in progress").
+		WithDetails("The service is processing the maximum number of concurrent queries").
+		WithSuggestion(fmt.Sprintf("Please retry in %d second(s).", retryAfterSeconds)).
+		WithMetadata("retryable", true).
+		WithMetadata("retry_after_seconds", retryAfterSeconds)
+}
+
+// NewRequestCancelledError creates an error for a request abandoned because
+// its context was cancelled or its deadline passed
+func NewRequestCancelledError(err error) *EnhancedError {
+	if stderrors.Is(err, context.DeadlineExceeded) {
+		return Wrap(err, ErrCodeRequestTimeout, "Request timed out").
+			WithDetails("The request deadline passed before the query was processed").
+			WithSuggestion("Please retry, or allow the request more time.").
+			WithMetadata("retryable", true)
+	}
+	return Wrap(err, ErrCodeRequestCancelled, "Request cancelled").
+		WithDetails("The request was cancelled before the query was processed")
+}
+
+// NewQueryRateLimitedError creates an error for queries rejected because the
+// same query has been sent too often, by any client
+func NewQueryRateLimitedError(retryAfterSeconds int) *EnhancedError {
+	return New(ErrCodeQueryRateLimited, "This query has been requested too often").
+		WithDetails("The same query is being sent repeatedly and is temporarily throttled for all clients").
+		WithSuggestion(fmt.Sprintf("Please retry in %d second(s), or reuse the previous result.", retryAfterSeconds)).
+		WithMetadata("retryable", true).
+		WithMetadata("retry_after_seconds", retryAfterSeconds)
+}
+
+// NewMaintenanceError creates an error for queries rejected while the service
+// is in maintenance mode
+func NewMaintenanceError(message string) *EnhancedError {
+	return New(ErrCodeMaintenance, message).
+		WithDetails("Queries are temporarily disabled by an administrator").
+		WithSuggestion("Please try again later.").
 		WithMetadata("retryable", true)
 }
 
@@ -245,5 +410,6 @@ func NewDatabaseQueryError(err error, operation string) *EnhancedError {
 	return Wrap(err, ErrCodeDatabaseQuery, "Database query failed").
 		WithDetails(fmt.Sprintf("Failed to execute database operation: %s", operation)).
 		WithSuggestion("This is an internal server error. If the problem persists, contact support.").
-		WithMetadata("retryable", true)
+		WithMetadata("retryable", true).
+		WithDependency(DependencyDatabase)
 }
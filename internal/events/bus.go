@@ -0,0 +1,126 @@
+// internal/events/bus.go
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type identifies the kind of an event
+type Type string
+
+// Event types published by the subsystems
+const (
+	TypeQueryProcessed Type = "query_processed"
+	TypeAuthSuccess    Type = "auth_success"
+	TypeAuthFailure    Type = "auth_failure"
+	TypeDiscoveryRun   Type = "discovery_run"
+)
+
+// defaultSubscriberBuffer is the number of events buffered per subscriber
+// before further events are dropped for it
+const defaultSubscriberBuffer = 64
+
+// Event is a structured record of activity in one of the subsystems
+type Event struct {
+	Type      Type                   `json:"type"`
+	Timestamp time.Time              `json:"timestamp"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+// Subscription receives the events published after it was created
+type Subscription struct {
+	events chan Event
+	types  map[Type]bool
+}
+
+// Events returns the channel events are delivered on. It is closed when the
+// subscription is removed from the bus.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// wants reports whether the subscription accepts events of the given type
+func (s *Subscription) wants(eventType Type) bool {
+	return len(s.types) == 0 || s.types[eventType]
+}
+
+// Bus is an in-process publish/subscribe event bus. Publishing never blocks:
+// events are dropped for subscribers whose buffer is full. A nil *Bus is valid
+// and discards every event, so subsystems can publish unconditionally.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+// NewBus creates an event bus with no subscribers
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish delivers an event to every subscriber that accepts its type
+func (b *Bus) Publish(eventType Type, data map[string]interface{}) {
+	if b == nil {
+		return
+	}
+
+	event := Event{
+		Type:      eventType,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		if !sub.wants(eventType) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			// Subscriber is not keeping up, drop the event for it
+		}
+	}
+}
+
+// Subscribe registers a subscriber for the given event types, or for all
+// events when no types are given. Call Unsubscribe when done.
+func (b *Bus) Subscribe(types ...Type) *Subscription {
+	sub := &Subscription{
+		events: make(chan Event, defaultSubscriberBuffer),
+		types:  make(map[Type]bool, len(types)),
+	}
+	for _, t := range types {
+		sub.types[t] = true
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Unsubscribe removes a subscriber and closes its channel. It is safe to call
+// more than once.
+func (b *Bus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	close(sub.events)
+}
+
+// SubscriberCount returns the number of active subscribers
+func (b *Bus) SubscriberCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
@@ -0,0 +1,90 @@
+// internal/events/bus_test.go
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	select {
+	case event, ok := <-sub.Events():
+		require.True(t, ok, "subscription channel closed")
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+		return Event{}
+	}
+}
+
+func TestBusPublishSubscribe(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe()
+	defer bus.Unsubscribe(sub)
+
+	bus.Publish(TypeQueryProcessed, map[string]interface{}{"query": "error rate"})
+
+	event := receive(t, sub)
+	assert.Equal(t, TypeQueryProcessed, event.Type)
+	assert.Equal(t, "error rate", event.Data["query"])
+	assert.False(t, event.Timestamp.IsZero())
+}
+
+func TestBusTypeFilter(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(TypeAuthFailure)
+	defer bus.Unsubscribe(sub)
+
+	bus.Publish(TypeQueryProcessed, nil)
+	bus.Publish(TypeAuthFailure, map[string]interface{}{"method": "login"})
+
+	event := receive(t, sub)
+	assert.Equal(t, TypeAuthFailure, event.Type)
+	assert.Empty(t, sub.Events(), "filtered events should not be delivered")
+}
+
+func TestBusUnsubscribe(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe()
+	assert.Equal(t, 1, bus.SubscriberCount())
+
+	bus.Unsubscribe(sub)
+	bus.Unsubscribe(sub) // Safe to call twice
+	assert.Equal(t, 0, bus.SubscriberCount())
+
+	_, ok := <-sub.Events()
+	assert.False(t, ok, "channel should be closed on unsubscribe")
+
+	// Publishing with no subscribers must not block or panic
+	bus.Publish(TypeDiscoveryRun, nil)
+}
+
+func TestBusDropsEventsForSlowSubscribers(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe()
+	defer bus.Unsubscribe(sub)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < defaultSubscriberBuffer*2; i++ {
+			bus.Publish(TypeQueryProcessed, nil)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked on a full subscriber")
+	}
+	assert.Len(t, sub.Events(), defaultSubscriberBuffer)
+}
+
+func TestNilBusPublish(t *testing.T) {
+	var bus *Bus
+	assert.NotPanics(t, func() { bus.Publish(TypeAuthSuccess, nil) })
+}
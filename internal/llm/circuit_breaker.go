@@ -69,12 +69,36 @@ func (cb *CircuitBreakerClient) GenerateQuery(ctx context.Context, prompt string
 	return result.(*Response), nil
 }
 
-// GetEmbedding wraps the client's GetEmbedding with circuit breaker protection
+// GenerateQueryWithOptions wraps query generation with options with circuit breaker protection
+func (cb *CircuitBreakerClient) GenerateQueryWithOptions(ctx context.Context, prompt string, opts GenerateOptions) (*Response, error) {
+	result, err := cb.breaker.Execute(func() (interface{}, error) {
+		return GenerateQueryWithOptions(ctx, cb.client, prompt, opts)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("circuit breaker: %w", err)
+	}
+
+	return result.(*Response), nil
+}
+
+// GetEmbedding wraps the client's GetEmbedding with circuit breaker protection.
+// Rate limited embeddings only degrade queries, so they are returned without
+// counting towards tripping the breaker.
 func (cb *CircuitBreakerClient) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	var rateLimited error
 	result, err := cb.breaker.Execute(func() (interface{}, error) {
-		return cb.client.GetEmbedding(ctx, text)
+		embedding, err := cb.client.GetEmbedding(ctx, text)
+		if IsRateLimited(err) {
+			rateLimited = err
+			return nil, nil
+		}
+		return embedding, err
 	})
 
+	if rateLimited != nil {
+		return nil, rateLimited
+	}
 	if err != nil {
 		return nil, fmt.Errorf("circuit breaker: %w", err)
 	}
@@ -3,6 +3,7 @@ package llm
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -155,6 +156,32 @@ func TestCircuitBreakerClient_GetEmbedding(t *testing.T) {
 	mockClient.AssertExpectations(t)
 }
 
+func TestCircuitBreakerClient_RateLimitedEmbeddings(t *testing.T) {
+	// Rate limited embeddings are returned as such and do not trip the breaker
+	mockClient := new(MockClient)
+	mockClient.On("GetEmbedding", mock.Anything, "test text").Return(nil, fmt.Errorf("%w: embeddings", ErrRateLimited))
+	mockClient.On("GenerateQuery", mock.Anything, "test prompt").Return(nil, fmt.Errorf("%w: generation", ErrRateLimited))
+
+	config := DefaultCircuitBreakerConfig
+	config.ReadyToTrip = func(counts gobreaker.Counts) bool {
+		return counts.ConsecutiveFailures >= 3
+	}
+	cbClient := NewCircuitBreakerClient(mockClient, "test-cb", config)
+
+	for i := 0; i < 5; i++ {
+		_, err := cbClient.GetEmbedding(context.Background(), "test text")
+		assert.True(t, IsRateLimited(err))
+	}
+	assert.Equal(t, gobreaker.StateClosed, cbClient.State())
+
+	// Rate limited generation still counts as a failure
+	for i := 0; i < 3; i++ {
+		_, err := cbClient.GenerateQuery(context.Background(), "test prompt")
+		assert.True(t, IsRateLimited(err))
+	}
+	assert.Equal(t, gobreaker.StateOpen, cbClient.State())
+}
+
 func TestCircuitBreakerCounts(t *testing.T) {
 	// Create mock client
 	mockClient := new(MockClient)
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"regexp"
 	"strings"
@@ -20,6 +21,10 @@ const (
 	MaxTokens        = 1000
 	Temperature      = 0.1 // Low temperature for consistent PromQL generation
 
+	// SimpleEmbeddingModel names the local text feature embedding of the
+	// Claude client, which has no embedding API
+	SimpleEmbeddingModel = "simple-text-features-v1"
+
 	// Claude 3.5 Sonnet pricing (as of January 2025)
 	// Prices are per million tokens
 	InputTokenPrice  = 0.000003  // $3 per million input tokens
@@ -28,18 +33,35 @@ const (
 
 // ClaudeClient implements the Client interface using Anthropic's Claude API
 type ClaudeClient struct {
-	apiKey  string
-	model   string
-	baseURL string
-	client  *http.Client
+	apiKey            string
+	model             string
+	baseURL           string
+	client            *http.Client
+	defaultConfidence float64
+	structuredOutput  bool // Request answers through the response tool
 }
 
 // Claude API request structures
 type ClaudeRequest struct {
-	Model       string    `json:"model"`
-	MaxTokens   int       `json:"max_tokens"`
-	Temperature float64   `json:"temperature,omitempty"`
-	Messages    []Message `json:"messages"`
+	Model       string      `json:"model"`
+	MaxTokens   int         `json:"max_tokens"`
+	Temperature float64     `json:"temperature"` // Sent even when zero, which the API would otherwise default to 1
+	Messages    []Message   `json:"messages"`
+	Tools       []Tool      `json:"tools,omitempty"`
+	ToolChoice  *ToolChoice `json:"tool_choice,omitempty"`
+}
+
+// Tool is a tool Claude may call; its input follows InputSchema
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// ToolChoice selects how Claude uses the request's tools
+type ToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
 }
 
 type Message struct {
@@ -58,8 +80,10 @@ type ClaudeResponse struct {
 }
 
 type ContentBlock struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type  string          `json:"type"`
+	Text  string          `json:"text,omitempty"`
+	Name  string          `json:"name,omitempty"`  // Tool name, for tool_use blocks
+	Input json.RawMessage `json:"input,omitempty"` // Tool input, for tool_use blocks
 }
 
 type Usage struct {
@@ -94,18 +118,35 @@ func NewClaudeClient(apiKey, model string) (*ClaudeClient, error) {
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		defaultConfidence: DefaultConfidence,
+		structuredOutput:  true,
 	}, nil
 }
 
 // GenerateQuery sends a prompt to Claude and returns a PromQL query
 func (c *ClaudeClient) GenerateQuery(ctx context.Context, prompt string) (*Response, error) {
+	return c.GenerateQueryWithOptions(ctx, prompt, GenerateOptions{})
+}
+
+// GenerateQueryWithOptions sends a prompt to Claude using the model and
+// temperature of opts, falling back to the client's defaults where unset
+func (c *ClaudeClient) GenerateQueryWithOptions(ctx context.Context, prompt string, opts GenerateOptions) (*Response, error) {
 	start := time.Now()
 
+	model := opts.Model
+	if model == "" {
+		model = c.model
+	}
+	temperature := Temperature
+	if opts.Temperature != nil {
+		temperature = *opts.Temperature
+	}
+
 	// Prepare the request
 	request := ClaudeRequest{
-		Model:       c.model,
+		Model:       model,
 		MaxTokens:   MaxTokens,
-		Temperature: Temperature,
+		Temperature: temperature,
 		Messages: []Message{
 			{
 				Role:    "user",
@@ -113,6 +154,10 @@ func (c *ClaudeClient) GenerateQuery(ctx context.Context, prompt string) (*Respo
 			},
 		},
 	}
+	if c.structuredOutput {
+		request.Tools = []Tool{responseTool}
+		request.ToolChoice = &ToolChoice{Type: "tool", Name: responseTool.Name}
+	}
 
 	// Send request to Claude with retry logic
 	response, err := c.sendClaudeRequestWithRetry(ctx, request)
@@ -135,7 +180,13 @@ func (c *ClaudeClient) GenerateQuery(ctx context.Context, prompt string) (*Respo
 		return nil, fmt.Errorf("failed to send request to Claude: %w", err)
 	}
 
-	// Extract PromQL from response
+	// Prefer the structured answer, falling back to extracting PromQL from text
+	for _, block := range response.Content {
+		if block.Type == "tool_use" && block.Name == responseTool.Name {
+			return parseStructuredResponse(block.Input, c.defaultConfidence)
+		}
+	}
+
 	promql, explanation, confidence := c.parseClaudeResponse(response)
 	if promql == "" {
 		return nil, fmt.Errorf("Claude did not return a valid PromQL query")
@@ -148,6 +199,43 @@ func (c *ClaudeClient) GenerateQuery(ctx context.Context, prompt string) (*Respo
 	}, nil
 }
 
+// responseTool is the tool Claude answers through when structured output is
+// enabled. Its input is a StructuredResponse.
+var responseTool = Tool{
+	Name: "submit_query",
+	Description: "Submit the PromQL query answering the request. Report your confidence in the confidence field " +
+		"instead of a CONFIDENCE line. If no suitable metrics exist, submit status \"error\" with the reason in " +
+		"the error field instead of an ERROR line.",
+	InputSchema: ResponseSchema,
+}
+
+// SetStructuredOutput sets whether Claude is asked to answer in the
+// StructuredResponse schema. It is enabled by default; disable it for
+// endpoints that do not support tool use.
+func (c *ClaudeClient) SetStructuredOutput(enabled bool) {
+	c.structuredOutput = enabled
+}
+
+// StructuredOutput reports whether Claude is asked to answer in the
+// StructuredResponse schema
+func (c *ClaudeClient) StructuredOutput() bool {
+	return c.structuredOutput
+}
+
+// Ping sends Claude a minimal one-token request with the default model,
+// verifying the API key, the model and connectivity. It is not retried.
+func (c *ClaudeClient) Ping(ctx context.Context) error {
+	_, err := c.sendClaudeRequest(ctx, ClaudeRequest{
+		Model:     c.model,
+		MaxTokens: 1,
+		Messages:  []Message{{Role: "user", Content: "ping"}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reach Claude: %w", err)
+	}
+	return nil
+}
+
 // GetEmbedding implements simple text-based similarity using basic string features
 // Since Claude doesn't provide embeddings, we'll create a simple representation
 func (c *ClaudeClient) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
@@ -164,6 +252,30 @@ func (c *ClaudeClient) GetEmbedding(ctx context.Context, text string) ([]float32
 	return embedding, nil
 }
 
+// EmbeddingModel returns SimpleEmbeddingModel; bump its version whenever
+// createSimpleEmbedding changes, so cached embeddings are not reused
+func (c *ClaudeClient) EmbeddingModel() string {
+	return SimpleEmbeddingModel
+}
+
+// GetEmbeddings embeds multiple texts in a single call
+func (c *ClaudeClient) GetEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	start := time.Now()
+
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		embeddings[i] = c.createSimpleEmbedding(text)
+	}
+
+	duration := time.Since(start)
+	observability.RecordLLMMetrics("get_embeddings", duration, 0, 0.0, nil)
+
+	return embeddings, nil
+}
+
 // sendClaudeRequest handles the HTTP communication with Claude API
 func (c *ClaudeClient) sendClaudeRequest(ctx context.Context, request ClaudeRequest) (*ClaudeResponse, error) {
 	// Marshal request to JSON
@@ -210,20 +322,21 @@ func (c *ClaudeClient) sendClaudeRequest(ctx context.Context, request ClaudeRequ
 	return &claudeResponse, nil
 }
 
-// parseClaudeResponse extracts PromQL query, explanation, and confidence from Claude's response
+// parseClaudeResponse extracts PromQL query, explanation, and confidence from
+// Claude's response. See confidence.go for how confidence is derived.
 func (c *ClaudeClient) parseClaudeResponse(response *ClaudeResponse) (promql, explanation string, confidence float64) {
 	if len(response.Content) == 0 {
 		return "", "", 0.0
 	}
 
-	text := response.Content[0].Text
+	text, reported, found := splitConfidenceTail(response.Content[0].Text)
+	confidence = c.reportedConfidence(reported, found)
 
 	// Try to extract PromQL query from the response
 	// Look for code blocks first (most reliable)
 	codeBlockRegex := regexp.MustCompile("```(?:promql)?\n?(.*?)\n?```")
 	if matches := codeBlockRegex.FindStringSubmatch(text); len(matches) > 1 {
 		extractedPromQL := strings.TrimSpace(matches[1])
-		confidence := c.calculateConfidence(text, extractedPromQL)
 		explanation := c.cleanExplanation(text, extractedPromQL)
 		return extractedPromQL, explanation, confidence
 	}
@@ -251,7 +364,6 @@ func (c *ClaudeClient) parseClaudeResponse(response *ClaudeResponse) (promql, ex
 
 	if len(promqlLines) > 0 {
 		extractedPromQL := strings.Join(promqlLines, " ")
-		confidence := c.calculateConfidence(text, extractedPromQL)
 		explanation := c.cleanExplanation(text, extractedPromQL)
 		return extractedPromQL, explanation, confidence
 	}
@@ -267,7 +379,6 @@ func (c *ClaudeClient) parseClaudeResponse(response *ClaudeResponse) (promql, ex
 			}
 		}
 		extractedPromQL := strings.TrimSpace(longestMatch)
-		confidence := c.calculateConfidence(text, extractedPromQL)
 		explanation := c.cleanExplanation(text, extractedPromQL)
 		return extractedPromQL, explanation, confidence
 	}
@@ -278,7 +389,6 @@ func (c *ClaudeClient) parseClaudeResponse(response *ClaudeResponse) (promql, ex
 		// Try to expand to include surrounding context
 		expandedRegex := regexp.MustCompile(`(?:rate|sum|avg|histogram_quantile|increase|max|min)\([^)]*` + regexp.QuoteMeta(matches) + `[^)]*\)|` + regexp.QuoteMeta(matches))
 		if expandedMatch := expandedRegex.FindString(text); expandedMatch != "" {
-			confidence := c.calculateConfidence(text, expandedMatch)
 			explanation := c.cleanExplanation(text, expandedMatch)
 			return expandedMatch, explanation, confidence
 		}
@@ -288,16 +398,14 @@ func (c *ClaudeClient) parseClaudeResponse(response *ClaudeResponse) (promql, ex
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 		if len(line) > 10 && !strings.Contains(strings.ToLower(line), "here") && !strings.Contains(strings.ToLower(line), "query") {
-			confidence := 0.3 // Low confidence for fallback
 			explanation := c.cleanExplanation(text, line)
-			return line, explanation, confidence
+			return line, explanation, math.Min(confidence, fallbackLineConfidence)
 		}
 	}
 
 	// Final fallback - return the full text as PromQL with very low confidence
-	confidence = 0.1 // Very low confidence for this fallback
 	explanation = c.cleanExplanation(text, text)
-	return strings.TrimSpace(text), explanation, confidence
+	return strings.TrimSpace(text), explanation, math.Min(confidence, fallbackTextConfidence)
 }
 
 // looksLikePromQLLine checks if a line looks like valid PromQL
@@ -323,42 +431,6 @@ func (c *ClaudeClient) looksLikePromQLLine(line string) bool {
 	return hasMetricPattern || hasPromQLFunction || (hasArithmetic && len(line) > 5)
 }
 
-// calculateConfidence estimates how confident we are in the response
-func (c *ClaudeClient) calculateConfidence(fullText, promql string) float64 {
-	confidence := 0.5 // Base confidence
-
-	// Higher confidence if we found a PromQL query
-	if promql != "" {
-		confidence += 0.3
-	}
-
-	// Higher confidence if the response mentions PromQL concepts
-	promqlKeywords := []string{"rate(", "sum(", "avg(", "histogram_quantile(", "by (", "without ("}
-	for _, keyword := range promqlKeywords {
-		if strings.Contains(strings.ToLower(fullText), strings.ToLower(keyword)) {
-			confidence += 0.05
-		}
-	}
-
-	// Lower confidence if the response seems uncertain
-	uncertaintyPhrases := []string{"not sure", "might be", "could be", "I think", "perhaps"}
-	for _, phrase := range uncertaintyPhrases {
-		if strings.Contains(strings.ToLower(fullText), phrase) {
-			confidence -= 0.1
-		}
-	}
-
-	// Ensure confidence is between 0 and 1
-	if confidence > 1.0 {
-		confidence = 1.0
-	}
-	if confidence < 0.0 {
-		confidence = 0.0
-	}
-
-	return confidence
-}
-
 // cleanExplanation removes the PromQL query from the explanation to avoid duplication
 func (c *ClaudeClient) cleanExplanation(fullText, promql string) string {
 	explanation := fullText
@@ -377,7 +449,7 @@ func (c *ClaudeClient) cleanExplanation(fullText, promql string) string {
 
 	// If explanation is empty or too short, provide a default
 	if len(explanation) < 10 {
-		explanation = "PromQL query generated based on the natural language request."
+		explanation = defaultExplanation
 	}
 
 	return explanation
@@ -394,7 +466,7 @@ func (c *ClaudeClient) handleAPIError(statusCode int, body []byte) error {
 	case http.StatusUnauthorized:
 		return fmt.Errorf("invalid API key: %s", errorResponse.Error.Message)
 	case http.StatusTooManyRequests:
-		return fmt.Errorf("rate limit exceeded: %s", errorResponse.Error.Message)
+		return fmt.Errorf("%w: %s", ErrRateLimited, errorResponse.Error.Message)
 	case http.StatusBadRequest:
 		return fmt.Errorf("bad request: %s", errorResponse.Error.Message)
 	case http.StatusInternalServerError:
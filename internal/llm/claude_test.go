@@ -0,0 +1,130 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestClaudeClient_GenerateQueryWithOptions tests that the requested model is sent to the API
+func TestClaudeClient_GenerateQueryWithOptions(t *testing.T) {
+	var requestedModels []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ClaudeRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		requestedModels = append(requestedModels, request.Model)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ClaudeResponse{
+			Model:   request.Model,
+			Content: []ContentBlock{{Type: "text", Text: "```promql\nrate(http_requests_total[5m])\n```"}},
+		})
+	}))
+	defer server.Close()
+
+	client, err := NewClaudeClient("test-key", "default-model")
+	require.NoError(t, err)
+	client.baseURL = server.URL
+
+	_, err = client.GenerateQuery(context.Background(), "prompt")
+	require.NoError(t, err)
+	_, err = client.GenerateQueryWithOptions(context.Background(), "prompt", GenerateOptions{Model: "strong-model"})
+	require.NoError(t, err)
+	_, err = GenerateQueryWithOptions(context.Background(), client, "prompt", GenerateOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"default-model", "strong-model", "default-model"}, requestedModels)
+}
+
+// TestClaudeClient_Temperature tests that a temperature requested in the
+// options is sent to the API, including zero
+func TestClaudeClient_Temperature(t *testing.T) {
+	var temperatures []interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		temperatures = append(temperatures, request["temperature"])
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ClaudeResponse{
+			Content: []ContentBlock{{Type: "text", Text: "```promql\nrate(http_requests_total[5m])\n```"}},
+		})
+	}))
+	defer server.Close()
+
+	client, err := NewClaudeClient("test-key", "default-model")
+	require.NoError(t, err)
+	client.baseURL = server.URL
+
+	_, err = client.GenerateQuery(context.Background(), "prompt")
+	require.NoError(t, err)
+	zero, warm := 0.0, 0.7
+	_, err = client.GenerateQueryWithOptions(context.Background(), "prompt", GenerateOptions{Temperature: &zero})
+	require.NoError(t, err)
+	_, err = client.GenerateQueryWithOptions(context.Background(), "prompt", GenerateOptions{Model: "strong-model", Temperature: &warm})
+	require.NoError(t, err)
+
+	assert.Equal(t, []interface{}{Temperature, 0.0, 0.7}, temperatures)
+}
+
+// TestClaudeClient_Ping tests that a ping makes a minimal request to the API
+// and fails when the API rejects it
+func TestClaudeClient_Ping(t *testing.T) {
+	status := http.StatusOK
+	var requests []ClaudeRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ClaudeRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		requests = append(requests, request)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			json.NewEncoder(w).Encode(ClaudeErrorResponse{Error: ClaudeError{Type: "authentication_error", Message: "invalid x-api-key"}})
+			return
+		}
+		json.NewEncoder(w).Encode(ClaudeResponse{Model: request.Model, Content: []ContentBlock{{Type: "text", Text: "p"}}})
+	}))
+	defer server.Close()
+
+	client, err := NewClaudeClient("test-key", "default-model")
+	require.NoError(t, err)
+	client.baseURL = server.URL
+
+	require.NoError(t, client.Ping(context.Background()))
+	require.Len(t, requests, 1)
+	assert.Equal(t, "default-model", requests[0].Model)
+	assert.Equal(t, 1, requests[0].MaxTokens)
+
+	status = http.StatusUnauthorized
+	assert.Error(t, client.Ping(context.Background()))
+	assert.Len(t, requests, 2, "a failed ping is not retried")
+}
+
+// TestGenerateQueryWithOptions_Unsupported tests that model overrides fail on
+// clients without options, while temperatures fall back to their default
+func TestGenerateQueryWithOptions_Unsupported(t *testing.T) {
+	mockClient := new(MockClient)
+	expected := &Response{PromQL: "up"}
+	mockClient.On("GenerateQuery", mock.Anything, "prompt").Return(expected, nil)
+
+	resp, err := GenerateQueryWithOptions(context.Background(), mockClient, "prompt", GenerateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, expected, resp)
+
+	temperature := 0.0
+	resp, err = GenerateQueryWithOptions(context.Background(), mockClient, "prompt", GenerateOptions{Temperature: &temperature})
+	require.NoError(t, err)
+	assert.Equal(t, expected, resp)
+
+	_, err = GenerateQueryWithOptions(context.Background(), mockClient, "prompt", GenerateOptions{Model: "strong-model"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "strong-model")
+	mockClient.AssertNumberOfCalls(t, "GenerateQuery", 2)
+}
@@ -2,19 +2,116 @@ package llm
 
 import (
 	"context"
+	"errors"
+	"fmt"
 )
 
+// ErrRateLimited is wrapped by errors of calls the provider rejected for
+// exceeding its rate limit. Embedding and generation calls may be limited
+// independently.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// IsRateLimited reports whether err is a rate limit rejection
+func IsRateLimited(err error) bool {
+	return errors.Is(err, ErrRateLimited)
+}
+
 // Client interface for AI service integration
 type Client interface {
 	GenerateQuery(ctx context.Context, prompt string) (*Response, error)
 	GetEmbedding(ctx context.Context, text string) ([]float32, error)
 }
 
+// BatchEmbedder is implemented by clients that can embed several texts in one call
+type BatchEmbedder interface {
+	GetEmbeddings(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// GenerateOptions override a client's defaults for a single query generation
+type GenerateOptions struct {
+	Model       string   // Model to generate with; empty for the client's default
+	Temperature *float64 // Sampling temperature; nil for the client's default
+}
+
+// OptionsQueryGenerator is implemented by clients that can generate a query
+// with a model or sampling temperature other than their default
+type OptionsQueryGenerator interface {
+	GenerateQueryWithOptions(ctx context.Context, prompt string, opts GenerateOptions) (*Response, error)
+}
+
+// EmbeddingModeler is implemented by clients that name the model producing
+// their embeddings
+type EmbeddingModeler interface {
+	EmbeddingModel() string
+}
+
+// EmbeddingModel returns the name of the model producing client's embeddings,
+// or "" when the client does not name it
+func EmbeddingModel(client Client) string {
+	if modeler, ok := client.(EmbeddingModeler); ok {
+		return modeler.EmbeddingModel()
+	}
+	return ""
+}
+
+// StructuredOutputClient is implemented by clients that can answer in the
+// StructuredResponse schema
+type StructuredOutputClient interface {
+	StructuredOutput() bool
+}
+
+// AnswersStructured reports whether client answers in the StructuredResponse
+// schema, so prompts need not describe a free-form text answer
+func AnswersStructured(client Client) bool {
+	structured, ok := client.(StructuredOutputClient)
+	return ok && structured.StructuredOutput()
+}
+
+// GenerateQueryWithOptions generates a query with opts overriding the client's
+// defaults. It fails if a model is requested from a client that cannot select
+// one; clients that cannot set the temperature generate at their default.
+func GenerateQueryWithOptions(ctx context.Context, client Client, prompt string, opts GenerateOptions) (*Response, error) {
+	if generator, ok := client.(OptionsQueryGenerator); ok {
+		return generator.GenerateQueryWithOptions(ctx, prompt, opts)
+	}
+	if opts.Model != "" {
+		return nil, fmt.Errorf("LLM client does not support selecting model %q", opts.Model)
+	}
+	return client.GenerateQuery(ctx, prompt)
+}
+
+// GetEmbeddings embeds texts using the client's batch method when available,
+// falling back to one GetEmbedding call per text
+func GetEmbeddings(ctx context.Context, client Client, texts []string) ([][]float32, error) {
+	if batcher, ok := client.(BatchEmbedder); ok {
+		return batcher.GetEmbeddings(ctx, texts)
+	}
+
+	embeddings := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		embedding, err := client.GetEmbedding(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, embedding)
+	}
+	return embeddings, nil
+}
+
 // Response represents the response from the AI service
 type Response struct {
 	PromQL      string  `json:"promql"`
 	Explanation string  `json:"explanation"`
 	Confidence  float64 `json:"confidence"`
+
+	// Error is set instead of PromQL when the model reports that no query
+	// can answer the request
+	Error string `json:"error,omitempty"`
+
+	// For and Severity are suggested for alerting rule expressions by
+	// structured answers; empty otherwise
+	For      string `json:"for,omitempty"`
+	Severity string `json:"severity,omitempty"`
 }
 
 // Config holds configuration for LLM clients
@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Confidence derivation
+//
+// The prompt asks the model to end its response with a structured tail line
+// such as "CONFIDENCE: 0.9". When that line is present and its value can be
+// interpreted, the value normalized to 0..1 is the response confidence.
+// Otherwise, including when the value is malformed, the client's default
+// confidence is used. Queries recovered by the loosest extraction fallbacks
+// are capped at a low confidence regardless of what the model reported.
+
+// DefaultConfidence is the confidence reported when the model does not
+// self-report a usable confidence
+const DefaultConfidence = 0.8
+
+// Confidence caps for queries recovered by the extraction fallbacks
+const (
+	fallbackLineConfidence = 0.3 // First substantial line of the response
+	fallbackTextConfidence = 0.1 // Whole response text
+)
+
+// confidenceTailRegex matches the structured confidence line, tolerating
+// markdown emphasis around the label
+var confidenceTailRegex = regexp.MustCompile(`(?i)^[*_]*confidence[*_]*\s*[:=]\s*[*_]*(.*?)[*_]*$`)
+
+// confidenceLevels maps verbal confidence levels to values
+var confidenceLevels = map[string]float64{
+	"very high": 0.95,
+	"high":      0.9,
+	"medium":    0.6,
+	"moderate":  0.6,
+	"low":       0.3,
+	"very low":  0.1,
+}
+
+// splitConfidenceTail removes a trailing confidence line from text. It returns
+// the remaining text, the raw reported value and whether a line was found.
+func splitConfidenceTail(text string) (string, string, bool) {
+	lines := strings.Split(strings.TrimRight(text, " \t\r\n"), "\n")
+	matches := confidenceTailRegex.FindStringSubmatch(strings.TrimSpace(lines[len(lines)-1]))
+	if matches == nil {
+		return text, "", false
+	}
+	return strings.Join(lines[:len(lines)-1], "\n"), strings.TrimSpace(matches[1]), true
+}
+
+// parseConfidence normalizes a self-reported confidence to 0..1. It accepts
+// fractions ("0.9"), percentages ("90%", or whole numbers up to 100 such as
+// "90") and levels ("high"); ok is false when the value cannot be interpreted.
+// A value above 1 that is neither marked nor whole, such as "1.5", is malformed.
+func parseConfidence(value string) (confidence float64, ok bool) {
+	value = strings.TrimRight(strings.ToLower(strings.TrimSpace(value)), ".")
+	if level, found := confidenceLevels[value]; found {
+		return level, true
+	}
+
+	percent := strings.HasSuffix(value, "%")
+	value = strings.TrimSpace(strings.TrimSuffix(value, "%"))
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) || f < 0 {
+		return 0, false
+	}
+	if f > 1 && !percent && f != math.Trunc(f) {
+		return 0, false
+	}
+	if percent || f > 1 {
+		f /= 100
+	}
+	if f > 1 {
+		return 0, false
+	}
+	return f, true
+}
+
+// SetDefaultConfidence sets the confidence reported when the model does not
+// self-report a usable one; values outside 0..1 are ignored
+func (c *ClaudeClient) SetDefaultConfidence(confidence float64) {
+	if confidence >= 0 && confidence <= 1 {
+		c.defaultConfidence = confidence
+	}
+}
+
+// reportedConfidence returns the model's self-reported confidence when it
+// can be interpreted, and the default confidence otherwise
+func (c *ClaudeClient) reportedConfidence(value string, found bool) float64 {
+	if found {
+		if confidence, ok := parseConfidence(value); ok {
+			return confidence
+		}
+	}
+	return c.defaultConfidence
+}
@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseConfidence tests normalization of self-reported confidence formats
+func TestParseConfidence(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected float64
+		ok       bool
+	}{
+		{"0.9", 0.9, true},
+		{"1", 1.0, true},
+		{"0", 0.0, true},
+		{"90%", 0.9, true},
+		{"85 %", 0.85, true},
+		{"90", 0.9, true},
+		{"0.5%", 0.005, true},
+		{"high", 0.9, true},
+		{"High.", 0.9, true},
+		{"very low", 0.1, true},
+		{"medium", 0.6, true},
+		{"", 0, false},
+		{"banana", 0, false},
+		{"150%", 0, false},
+		{"-0.2", 0, false},
+		{"2", 0.02, true},
+		{"100", 1.0, true},
+		{"101", 0, false},
+		{"1.5", 0, false},
+		{"90.5", 0, false},
+		{"90.5%", 0.905, true},
+		{"NaN", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			confidence, ok := parseConfidence(tt.value)
+			assert.Equal(t, tt.ok, ok)
+			assert.InDelta(t, tt.expected, confidence, 1e-9)
+		})
+	}
+}
+
+// TestParseClaudeResponseConfidence tests that the confidence tail is parsed and stripped from the response
+func TestParseClaudeResponseConfidence(t *testing.T) {
+	client, err := NewClaudeClient("test-key", "")
+	require.NoError(t, err)
+	client.SetDefaultConfidence(0.7)
+
+	tests := []struct {
+		name       string
+		text       string
+		confidence float64
+	}{
+		{"fraction", "sum(rate(http_requests_total[5m]))\nCONFIDENCE: 0.9", 0.9},
+		{"percentage", "sum(rate(http_requests_total[5m]))\nConfidence: 75%", 0.75},
+		{"level", "sum(rate(http_requests_total[5m]))\nconfidence = high\n", 0.9},
+		{"emphasized", "```promql\nsum(rate(http_requests_total[5m]))\n```\n**Confidence:** 0.95", 0.95},
+		{"malformed uses default", "sum(rate(http_requests_total[5m]))\nCONFIDENCE: pretty sure", 0.7},
+		{"missing uses default", "sum(rate(http_requests_total[5m]))", 0.7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			promql, _, confidence := client.parseClaudeResponse(&ClaudeResponse{
+				Content: []ContentBlock{{Type: "text", Text: tt.text}},
+			})
+			assert.Equal(t, "sum(rate(http_requests_total[5m]))", promql)
+			assert.InDelta(t, tt.confidence, confidence, 1e-9)
+		})
+	}
+
+	t.Run("out of range default is ignored", func(t *testing.T) {
+		client.SetDefaultConfidence(1.5)
+		_, _, confidence := client.parseClaudeResponse(&ClaudeResponse{
+			Content: []ContentBlock{{Type: "text", Text: "up"}},
+		})
+		assert.InDelta(t, 0.7, confidence, 1e-9)
+	})
+}
@@ -0,0 +1,120 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Structured output
+//
+// Providers that support structured output are asked to answer in the
+// StructuredResponse schema, which is parsed directly. A response without a
+// structured answer, or a provider without structured output, falls back to
+// extracting the query from free-form text.
+
+// Structured response statuses
+const (
+	StatusOK    = "ok"
+	StatusError = "error" // No query can answer the request
+)
+
+// defaultExplanation is used when a response does not explain its query
+const defaultExplanation = "PromQL query generated based on the natural language request."
+
+// StructuredResponse is the schema of a structured query response
+type StructuredResponse struct {
+	Status      string   `json:"status"`
+	PromQL      string   `json:"promql,omitempty"`
+	Explanation string   `json:"explanation,omitempty"`
+	Confidence  *float64 `json:"confidence,omitempty"` // nil when not reported
+	Error       string   `json:"error,omitempty"`      // Why no query was generated, for StatusError
+
+	// For and Severity are suggested for alerting rule expressions
+	For      string `json:"for,omitempty"`
+	Severity string `json:"severity,omitempty"`
+}
+
+// ResponseSchema is the JSON schema of StructuredResponse sent to providers
+var ResponseSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"status": map[string]interface{}{
+			"type":        "string",
+			"enum":        []string{StatusOK, StatusError},
+			"description": `"ok" when promql answers the request, "error" when no suitable metrics exist`,
+		},
+		"promql": map[string]interface{}{
+			"type":        "string",
+			"description": "The PromQL query, without code fences",
+		},
+		"explanation": map[string]interface{}{
+			"type":        "string",
+			"description": "A short explanation of what the query computes",
+		},
+		"confidence": map[string]interface{}{
+			"type":        "number",
+			"minimum":     0,
+			"maximum":     1,
+			"description": "How confident you are that the query answers the request",
+		},
+		"error": map[string]interface{}{
+			"type":        "string",
+			"description": `Why no query can be generated, when status is "error"`,
+		},
+		"for": map[string]interface{}{
+			"type":        "string",
+			"pattern":     `^[0-9]+[smhdw]$`,
+			"description": "For alerting rules only: how long the condition must hold before the alert fires, e.g. 5m",
+		},
+		"severity": map[string]interface{}{
+			"type":        "string",
+			"enum":        []string{"info", "warning", "critical"},
+			"description": "For alerting rules only: how severe the alert is",
+		},
+	},
+	"required": []string{"status"},
+}
+
+// parseStructuredResponse converts a structured answer to a Response, using
+// defaultConfidence when the answer does not report a usable confidence
+func parseStructuredResponse(data []byte, defaultConfidence float64) (*Response, error) {
+	var structured StructuredResponse
+	if err := json.Unmarshal(data, &structured); err != nil {
+		return nil, fmt.Errorf("failed to parse structured response: %w", err)
+	}
+
+	switch structured.Status {
+	case StatusError:
+		message := strings.TrimSpace(structured.Error)
+		if message == "" {
+			message = strings.TrimSpace(structured.Explanation)
+		}
+		if message == "" {
+			message = "No suitable metrics found."
+		}
+		return &Response{Explanation: structured.Explanation, Error: message}, nil
+	case StatusOK, "":
+		promql := strings.TrimSpace(structured.PromQL)
+		if promql == "" {
+			return nil, fmt.Errorf("structured response has no PromQL query")
+		}
+		confidence := defaultConfidence
+		if c := structured.Confidence; c != nil && *c >= 0 && *c <= 1 {
+			confidence = *c
+		}
+		explanation := strings.TrimSpace(structured.Explanation)
+		if explanation == "" {
+			explanation = defaultExplanation
+		}
+		return &Response{
+			PromQL:      promql,
+			Explanation: explanation,
+			Confidence:  confidence,
+			For:         strings.TrimSpace(structured.For),
+			Severity:    strings.TrimSpace(structured.Severity),
+		}, nil
+	default:
+		return nil, fmt.Errorf("structured response has unknown status %q", structured.Status)
+	}
+}
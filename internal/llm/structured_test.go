@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClaudeClient returns a client talking to a server that answers every
+// request with content, recording the requests it receives
+func newTestClaudeClient(t *testing.T, content []ContentBlock) (*ClaudeClient, *[]ClaudeRequest) {
+	var requests []ClaudeRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ClaudeRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		requests = append(requests, request)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ClaudeResponse{Model: request.Model, Content: content})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClaudeClient("test-key", "default-model")
+	require.NoError(t, err)
+	client.baseURL = server.URL
+	return client, &requests
+}
+
+// toolUse returns a response tool call with the given input
+func toolUse(input string) ContentBlock {
+	return ContentBlock{Type: "tool_use", Name: responseTool.Name, Input: json.RawMessage(input)}
+}
+
+// TestClaudeClient_StructuredOutput tests that structured answers are requested and parsed directly
+func TestClaudeClient_StructuredOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  []ContentBlock
+		expected *Response
+		wantErr  bool
+	}{
+		{
+			name:    "query",
+			content: []ContentBlock{toolUse(`{"status":"ok","promql":"sum(rate(http_requests_total[5m]))","explanation":"Total request rate","confidence":0.7}`)},
+			expected: &Response{
+				PromQL:      "sum(rate(http_requests_total[5m]))",
+				Explanation: "Total request rate",
+				Confidence:  0.7,
+			},
+		},
+		{
+			name:    "text before the tool call",
+			content: []ContentBlock{{Type: "text", Text: "Let me build that."}, toolUse(`{"status":"ok","promql":"up"}`)},
+			expected: &Response{
+				PromQL:      "up",
+				Explanation: defaultExplanation,
+				Confidence:  DefaultConfidence,
+			},
+		},
+		{
+			name:     "confidence out of range uses the default",
+			content:  []ContentBlock{toolUse(`{"status":"ok","promql":"up","explanation":"Targets","confidence":7}`)},
+			expected: &Response{PromQL: "up", Explanation: "Targets", Confidence: DefaultConfidence},
+		},
+		{
+			name:    "alert suggestions",
+			content: []ContentBlock{toolUse(`{"status":"ok","promql":"up == 0","explanation":"Target down","confidence":0.8,"for":"5m","severity":"critical"}`)},
+			expected: &Response{
+				PromQL:      "up == 0",
+				Explanation: "Target down",
+				Confidence:  0.8,
+				For:         "5m",
+				Severity:    "critical",
+			},
+		},
+		{
+			name:     "error status",
+			content:  []ContentBlock{toolUse(`{"status":"error","error":"No suitable metrics found. No CPU metrics are discovered."}`)},
+			expected: &Response{Error: "No suitable metrics found. No CPU metrics are discovered."},
+		},
+		{
+			name:    "missing query",
+			content: []ContentBlock{toolUse(`{"status":"ok"}`)},
+			wantErr: true,
+		},
+		{
+			name:    "unknown status",
+			content: []ContentBlock{toolUse(`{"status":"maybe","promql":"up"}`)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, requests := newTestClaudeClient(t, tt.content)
+
+			resp, err := client.GenerateQuery(context.Background(), "prompt")
+
+			assert.True(t, AnswersStructured(client))
+			require.Len(t, *requests, 1)
+			request := (*requests)[0]
+			require.Len(t, request.Tools, 1)
+			assert.Equal(t, responseTool.Name, request.Tools[0].Name)
+			assert.Equal(t, &ToolChoice{Type: "tool", Name: responseTool.Name}, request.ToolChoice)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, resp)
+		})
+	}
+}
+
+// TestClaudeClient_StructuredOutputFallback tests that free-form text answers are still parsed
+func TestClaudeClient_StructuredOutputFallback(t *testing.T) {
+	content := []ContentBlock{{Type: "text", Text: "```promql\nrate(http_requests_total[5m])\n```\nCONFIDENCE: 0.6"}}
+
+	t.Run("no structured answer", func(t *testing.T) {
+		client, _ := newTestClaudeClient(t, content)
+
+		resp, err := client.GenerateQuery(context.Background(), "prompt")
+		require.NoError(t, err)
+		assert.Equal(t, "rate(http_requests_total[5m])", resp.PromQL)
+		assert.Equal(t, 0.6, resp.Confidence)
+	})
+
+	t.Run("structured output disabled", func(t *testing.T) {
+		client, requests := newTestClaudeClient(t, content)
+		client.SetStructuredOutput(false)
+
+		resp, err := client.GenerateQuery(context.Background(), "prompt")
+		require.NoError(t, err)
+		assert.Equal(t, "rate(http_requests_total[5m])", resp.PromQL)
+
+		require.Len(t, *requests, 1)
+		assert.Empty(t, (*requests)[0].Tools)
+		assert.Nil(t, (*requests)[0].ToolChoice)
+		assert.False(t, AnswersStructured(client))
+	})
+}
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MetricType is the Prometheus type of a metric
+type MetricType string
+
+const (
+	MetricTypeCounter   MetricType = "counter"
+	MetricTypeGauge     MetricType = "gauge"
+	MetricTypeHistogram MetricType = "histogram"
+	MetricTypeSummary   MetricType = "summary"
+	MetricTypeUnknown   MetricType = "unknown"
+)
+
+// MetricMetadata represents metadata for a metric as reported by Prometheus/Mimir
+type MetricMetadata struct {
+	Type string `json:"type"` // "counter", "gauge", "histogram", "summary"
+	Help string `json:"help"`
+	Unit string `json:"unit"`
+}
+
+// InferType determines a metric's type. Real metadata is preferred when it
+// carries a known type; otherwise the type is inferred from naming conventions.
+// MetricTypeUnknown is returned when the name gives no signal.
+func InferType(name string, metadata *MetricMetadata) MetricType {
+	if metricType, ok := metadataType(metadata); ok {
+		return metricType
+	}
+
+	return inferFromName(strings.ToLower(name))
+}
+
+// metadataType returns the type carried by real metadata, if it is known
+func metadataType(metadata *MetricMetadata) (MetricType, bool) {
+	if metadata == nil {
+		return "", false
+	}
+	switch MetricType(strings.ToLower(metadata.Type)) {
+	case MetricTypeCounter:
+		return MetricTypeCounter, true
+	case MetricTypeGauge:
+		return MetricTypeGauge, true
+	case MetricTypeHistogram, "gaugehistogram":
+		return MetricTypeHistogram, true
+	case MetricTypeSummary:
+		return MetricTypeSummary, true
+	}
+	return "", false
+}
+
+// inferFromName applies naming-convention heuristics to a lowercased metric name
+func inferFromName(name string) MetricType {
+	switch {
+	case strings.HasSuffix(name, "_total") || strings.HasSuffix(name, "_count"):
+		return MetricTypeCounter
+	case strings.Contains(name, "_bucket") || strings.Contains(name, "_histogram"):
+		return MetricTypeHistogram
+	case strings.Contains(name, "_summary"):
+		return MetricTypeSummary
+	case strings.Contains(name, "_active_") ||
+		strings.Contains(name, "_current_") ||
+		strings.Contains(name, "_size") ||
+		strings.Contains(name, "_gauge") ||
+		strings.HasSuffix(name, "_bytes") ||
+		strings.HasSuffix(name, "_ratio"):
+		return MetricTypeGauge
+	default:
+		return MetricTypeUnknown
+	}
+}
+
+// ParseType parses a configured metric type name
+func ParseType(name string) (MetricType, error) {
+	switch metricType := MetricType(strings.ToLower(strings.TrimSpace(name))); metricType {
+	case MetricTypeCounter, MetricTypeGauge, MetricTypeHistogram, MetricTypeSummary:
+		return metricType, nil
+	default:
+		return "", fmt.Errorf("unknown metric type %q (must be counter, gauge, histogram or summary)", name)
+	}
+}
+
+// metricNamePattern matches strings that are valid metric names rather than
+// regular expressions
+var metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// TypeOverrides assigns types to metrics that naming conventions misclassify,
+// such as a gauge named queue_depth_total. A nil TypeOverrides has no overrides.
+type TypeOverrides struct {
+	names    map[string]MetricType
+	patterns []typePattern
+}
+
+// typePattern is a regular expression override and the type it assigns
+type typePattern struct {
+	pattern    *regexp.Regexp
+	metricType MetricType
+}
+
+// NewTypeOverrides builds overrides from metric names and regular expressions
+// keyed by type. A metric name matches only that metric; any other entry is a
+// regular expression matching anywhere in the name unless anchored. Names take
+// precedence over patterns, which are tried in type then configuration order.
+func NewTypeOverrides(byType map[string][]string) (*TypeOverrides, error) {
+	types := make([]string, 0, len(byType))
+	for name := range byType {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+
+	overrides := &TypeOverrides{names: make(map[string]MetricType)}
+	for _, name := range types {
+		metricType, err := ParseType(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range byType[name] {
+			if metricNamePattern.MatchString(entry) {
+				overrides.names[entry] = metricType
+				continue
+			}
+			pattern, err := regexp.Compile(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s override pattern %q: %w", metricType, entry, err)
+			}
+			overrides.patterns = append(overrides.patterns, typePattern{pattern: pattern, metricType: metricType})
+		}
+	}
+	return overrides, nil
+}
+
+// Lookup returns the type assigned to the metric by an override, if any
+func (o *TypeOverrides) Lookup(name string) (MetricType, bool) {
+	if o == nil {
+		return "", false
+	}
+	if metricType, ok := o.names[name]; ok {
+		return metricType, true
+	}
+	for _, p := range o.patterns {
+		if p.pattern.MatchString(name) {
+			return p.metricType, true
+		}
+	}
+	return "", false
+}
+
+// InferType determines a metric's type like the package-level InferType, but
+// consults the overrides before the naming conventions. Real metadata still
+// takes precedence over overrides.
+func (o *TypeOverrides) InferType(name string, metadata *MetricMetadata) MetricType {
+	if metricType, ok := metadataType(metadata); ok {
+		return metricType
+	}
+	if metricType, ok := o.Lookup(name); ok {
+		return metricType
+	}
+	return inferFromName(strings.ToLower(name))
+}
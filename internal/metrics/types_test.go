@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestInferType tests type inference from metric names
+func TestInferType(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected MetricType
+	}{
+		// Counters
+		{"http_requests_total", MetricTypeCounter},
+		{"api_calls_count", MetricTypeCounter},
+		{"cache_hits_count", MetricTypeCounter},
+		{"HTTP_REQUESTS_TOTAL", MetricTypeCounter},
+
+		// Histograms
+		{"request_duration_seconds_bucket", MetricTypeHistogram},
+		{"request_duration_histogram", MetricTypeHistogram},
+		{"HTTP_DURATION_BUCKET", MetricTypeHistogram},
+
+		// Summaries
+		{"request_summary", MetricTypeSummary},
+
+		// Gauges
+		{"database_connections_active_now", MetricTypeGauge},
+		{"memory_usage_current_bytes", MetricTypeGauge},
+		{"Memory_Usage_CURRENT_Value", MetricTypeGauge},
+		{"disk_size", MetricTypeGauge},
+		{"cpu_gauge", MetricTypeGauge},
+		{"network_bytes", MetricTypeGauge},
+		{"memory_bytes", MetricTypeGauge},
+		{"cache_hit_ratio", MetricTypeGauge},
+		{"bucket_size", MetricTypeGauge},
+
+		// No naming signal
+		{"cpu_usage_percent", MetricTypeUnknown},
+		{"current_connections", MetricTypeUnknown},
+		{"some_other_metric", MetricTypeUnknown},
+		{"total_requests", MetricTypeUnknown},
+		{"count_operations", MetricTypeUnknown},
+		{"http_request_duration_seconds", MetricTypeUnknown},
+		{"response_time_milliseconds", MetricTypeUnknown},
+		{"api_latency_seconds", MetricTypeUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, InferType(tt.name, nil))
+		})
+	}
+}
+
+// TestInferTypePrefersMetadata tests that reported metadata overrides name heuristics
+func TestInferTypePrefersMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		metric   string
+		metadata *MetricMetadata
+		expected MetricType
+	}{
+		{"metadata overrides counter suffix", "jobs_total", &MetricMetadata{Type: "gauge"}, MetricTypeGauge},
+		{"metadata classifies unknown name", "cpu_usage_percent", &MetricMetadata{Type: "gauge"}, MetricTypeGauge},
+		{"gaugehistogram treated as histogram", "queue_wait", &MetricMetadata{Type: "gaugehistogram"}, MetricTypeHistogram},
+		{"unknown metadata falls back to name", "http_requests_total", &MetricMetadata{Type: "unknown"}, MetricTypeCounter},
+		{"empty metadata falls back to name", "request_summary", &MetricMetadata{}, MetricTypeSummary},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, InferType(tt.metric, tt.metadata))
+		})
+	}
+}
+
+// TestTypeOverrides tests that configured overrides are consulted before name heuristics
+func TestTypeOverrides(t *testing.T) {
+	overrides, err := NewTypeOverrides(map[string][]string{
+		"gauge":     {"queue_depth_total", "^jobs_inflight_"},
+		"counter":   {"http_hits", "_hits$"},
+		"Histogram": {"request_wait"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		metric   string
+		metadata *MetricMetadata
+		expected MetricType
+	}{
+		{"name override flips counter suffix", "queue_depth_total", nil, MetricTypeGauge},
+		{"pattern override", "jobs_inflight_total", nil, MetricTypeGauge},
+		{"name override of unknown name", "http_hits", nil, MetricTypeCounter},
+		{"type names are case insensitive", "request_wait", nil, MetricTypeHistogram},
+		{"name match is exact", "queue_depth_total_max", nil, MetricTypeUnknown},
+		{"unmatched metrics use heuristics", "http_requests_total", nil, MetricTypeCounter},
+		{"metadata takes precedence", "queue_depth_total", &MetricMetadata{Type: "counter"}, MetricTypeCounter},
+		{"unknown metadata uses override", "queue_depth_total", &MetricMetadata{Type: "unknown"}, MetricTypeGauge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, overrides.InferType(tt.metric, tt.metadata))
+		})
+	}
+
+	t.Run("nil overrides use heuristics", func(t *testing.T) {
+		var none *TypeOverrides
+		assert.Equal(t, MetricTypeCounter, none.InferType("queue_depth_total", nil))
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		_, err := NewTypeOverrides(map[string][]string{"timer": {"queue_depth_total"}})
+		assert.ErrorContains(t, err, `unknown metric type "timer"`)
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		_, err := NewTypeOverrides(map[string][]string{"gauge": {"queue_(depth"}})
+		assert.ErrorContains(t, err, "invalid gauge override pattern")
+	})
+}
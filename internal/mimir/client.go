@@ -3,22 +3,39 @@ package mimir
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/seanankenbruck/observability-ai/internal/metrics"
 )
 
 // AuthConfig holds authentication configuration for Mimir
 type AuthConfig struct {
-	Type        string // "basic", "bearer", "none"
+	Type        string // "basic", "bearer", "tls", "none"
 	Username    string
 	Password    string
 	BearerToken string
 	TenantID    string // Mimir tenant/org ID (X-Scope-OrgID header)
+
+	// TLS client authentication (mTLS). Certificates can be given as file
+	// paths or as inline PEM (e.g. loaded from a secret provider); inline
+	// PEM takes precedence over the corresponding file.
+	TLSCertFile string
+	TLSKeyFile  string
+	TLSCAFile   string
+	TLSCertPEM  string
+	TLSKeyPEM   string
+	TLSCAPEM    string
 }
 
 // QueryResponse represents the response from Mimir query endpoints
@@ -28,17 +45,40 @@ type QueryResponse struct {
 		ResultType string      `json:"resultType"`
 		Result     interface{} `json:"result"`
 	} `json:"data"`
-	Error     string `json:"error,omitempty"`
-	ErrorType string `json:"errorType,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	ErrorType string   `json:"errorType,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+	IsPartial bool     `json:"isPartial,omitempty"`
+
+	// PartialData is set when the backend reported that the result may be
+	// incomplete, e.g. truncated under load, so it is not presented as complete
+	PartialData bool `json:"partial_data,omitempty"`
 }
 
-// MetricMetadata represents metadata for a metric
-type MetricMetadata struct {
-	Type string `json:"type"` // "counter", "gauge", "histogram", "summary"
-	Help string `json:"help"`
-	Unit string `json:"unit"`
+// partialDataWarnings are warning fragments that indicate an incomplete result
+var partialDataWarnings = []string{"partial", "truncated", "incomplete", "limit", "exceeded"}
+
+// markPartialData sets PartialData when the response carries a partial-data
+// flag or a warning that the result is incomplete
+func markPartialData(resp *QueryResponse) {
+	if resp.IsPartial {
+		resp.PartialData = true
+		return
+	}
+	for _, warning := range resp.Warnings {
+		warning = strings.ToLower(warning)
+		for _, fragment := range partialDataWarnings {
+			if strings.Contains(warning, fragment) {
+				resp.PartialData = true
+				return
+			}
+		}
+	}
 }
 
+// MetricMetadata represents metadata for a metric
+type MetricMetadata = metrics.MetricMetadata
+
 // BackendType represents the type of Prometheus-compatible backend
 type BackendType string
 
@@ -55,6 +95,13 @@ type Client struct {
 	httpClient  *http.Client
 	backendType BackendType
 	apiPrefix   string // "/prometheus/api/v1" for Mimir, "/api/v1" for Prometheus
+
+	// queryTimeout bounds server-side evaluation of queries; zero leaves the
+	// backend's default
+	queryTimeout time.Duration
+
+	// typeOverrides correct inferred types of metrics without backend metadata
+	typeOverrides *metrics.TypeOverrides
 }
 
 // NewClient creates a new Mimir client with default backend type (auto-detect)
@@ -62,21 +109,154 @@ func NewClient(endpoint string, auth AuthConfig, timeout time.Duration) *Client
 	return NewClientWithBackend(endpoint, auth, timeout, BackendTypeAuto)
 }
 
-// NewClientWithBackend creates a new client with a specific backend type
+// NewClientWithBackend creates a new client with a specific backend type.
+// Invalid TLS material is logged; use NewClientWithAuth to surface it as an error.
 func NewClientWithBackend(endpoint string, auth AuthConfig, timeout time.Duration, backendType BackendType) *Client {
+	client, err := NewClientWithAuth(endpoint, auth, timeout, backendType)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return client
+}
+
+// NewClientWithAuth creates a new client with a specific backend type, validating
+// authentication material (such as mTLS certificates) up front
+func NewClientWithAuth(endpoint string, auth AuthConfig, timeout time.Duration, backendType BackendType) (*Client, error) {
+	httpClient := &http.Client{
+		Timeout: timeout,
+	}
+
+	tlsConfig, tlsErr := buildTLSConfig(auth)
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		httpClient.Transport = transport
+	}
+
 	client := &Client{
-		endpoint: strings.TrimSuffix(endpoint, "/"),
-		auth:     auth,
-		httpClient: &http.Client{
-			Timeout: timeout,
-		},
+		endpoint:    strings.TrimSuffix(endpoint, "/"),
+		auth:        auth,
+		httpClient:  httpClient,
 		backendType: backendType,
 	}
 
 	// Set the API prefix based on backend type
 	client.apiPrefix = client.determineAPIPrefix()
 
-	return client
+	return client, tlsErr
+}
+
+// buildTLSConfig builds the TLS configuration for mTLS authentication.
+// It returns nil when no TLS material is configured.
+func buildTLSConfig(auth AuthConfig) (*tls.Config, error) {
+	certPEM, err := readPEM(auth.TLSCertPEM, auth.TLSCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Mimir TLS client certificate: %w", err)
+	}
+	keyPEM, err := readPEM(auth.TLSKeyPEM, auth.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Mimir TLS client key: %w", err)
+	}
+	caPEM, err := readPEM(auth.TLSCAPEM, auth.TLSCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Mimir TLS CA certificate: %w", err)
+	}
+
+	if len(certPEM) == 0 && len(keyPEM) == 0 && len(caPEM) == 0 {
+		if auth.Type == "tls" {
+			return nil, fmt.Errorf("tls auth requires a client certificate and key")
+		}
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if len(certPEM) > 0 || len(keyPEM) > 0 {
+		if len(certPEM) == 0 || len(keyPEM) == 0 {
+			return nil, fmt.Errorf("Mimir TLS client authentication requires both a certificate and a key")
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Mimir TLS client certificate/key pair: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	} else if auth.Type == "tls" {
+		return nil, fmt.Errorf("tls auth requires a client certificate and key")
+	}
+
+	if len(caPEM) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("invalid Mimir TLS CA certificate: no certificates found")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// readPEM returns inline PEM content if set, otherwise the contents of the file
+func readPEM(inline, path string) ([]byte, error) {
+	if inline != "" {
+		return []byte(inline), nil
+	}
+	if path == "" {
+		return nil, nil
+	}
+	return os.ReadFile(path)
+}
+
+// SetQueryTimeout sets the timeout passed to the backend with every instant and
+// range query, bounding server-side evaluation so a runaway query cannot
+// consume backend resources after we stop waiting. Zero disables it.
+func (c *Client) SetQueryTimeout(timeout time.Duration) {
+	c.queryTimeout = timeout
+}
+
+// SetTypeOverrides sets the metric types used instead of the naming
+// conventions when the backend reports no metadata for a metric. Metadata
+// reported by the backend still takes precedence.
+func (c *Client) SetTypeOverrides(overrides *metrics.TypeOverrides) {
+	c.typeOverrides = overrides
+}
+
+// setQueryTimeout adds the timeout parameter to query parameters. The
+// timeout is shortened to the time left before the context deadline, since
+// the backend's work is wasted once we stop waiting.
+func (c *Client) setQueryTimeout(ctx context.Context, params url.Values) url.Values {
+	timeout := c.queryTimeout
+	if timeout <= 0 {
+		return params
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining > 0 && remaining < timeout {
+			timeout = remaining
+		}
+	}
+	params.Set("timeout", formatTimeout(timeout))
+	return params
+}
+
+// formatTimeout formats a timeout as a Prometheus duration, in whole seconds
+// when possible and milliseconds otherwise
+func formatTimeout(timeout time.Duration) string {
+	if timeout%time.Second == 0 {
+		return fmt.Sprintf("%ds", int64(timeout/time.Second))
+	}
+	if ms := timeout.Milliseconds(); ms > 0 {
+		return fmt.Sprintf("%dms", ms)
+	}
+	return "1ms"
+}
+
+// ForTenant returns a client for the same endpoint and credentials that sends
+// requests as the given tenant. The HTTP client and connection pool are shared.
+func (c *Client) ForTenant(tenantID string) *Client {
+	tenantClient := *c
+	tenantClient.auth.TenantID = tenantID
+	return &tenantClient
 }
 
 // determineAPIPrefix determines the correct API prefix based on backend type
@@ -172,6 +352,8 @@ func (c *Client) doRequest(ctx context.Context, method, path string, params url.
 		req.SetBasicAuth(c.auth.Username, c.auth.Password)
 	case "bearer":
 		req.Header.Set("Authorization", "Bearer "+c.auth.BearerToken)
+	case "tls":
+		// Client certificate is presented by the transport
 	case "none":
 		// No authentication
 	default:
@@ -193,15 +375,65 @@ func (c *Client) doRequest(ctx context.Context, method, path string, params url.
 	return resp, nil
 }
 
-// Query executes an instant PromQL query
-func (c *Client) Query(ctx context.Context, query string, timestamp time.Time) (*QueryResponse, error) {
+// RequestInfo describes a request issued to Mimir, with credentials removed
+type RequestInfo struct {
+	Method   string            `json:"method"`
+	Endpoint string            `json:"endpoint"`
+	Path     string            `json:"path"`
+	Params   map[string]string `json:"params"`
+	AuthType string            `json:"auth_type,omitempty"`
+}
+
+// sensitiveParamMarkers identify query parameters whose values must not be exposed
+var sensitiveParamMarkers = []string{"token", "password", "secret", "key", "auth"}
+
+// DescribeQuery returns the request Query would issue for the given query,
+// suitable for showing to users. Auth headers are never included, and
+// credentials embedded in the endpoint or parameters are redacted.
+func (c *Client) DescribeQuery(query string, timestamp time.Time) *RequestInfo {
+	return c.describeRequest("GET", c.apiPrefix+"/query", c.setQueryTimeout(context.Background(), queryParams(query, timestamp)))
+}
+
+func (c *Client) describeRequest(method, path string, params url.Values) *RequestInfo {
+	endpoint := c.endpoint
+	if u, err := url.Parse(c.endpoint); err == nil {
+		endpoint = u.Redacted()
+	}
+
+	info := &RequestInfo{
+		Method:   method,
+		Endpoint: endpoint,
+		Path:     path,
+		Params:   make(map[string]string, len(params)),
+		AuthType: c.auth.Type,
+	}
+	for name := range params {
+		value := params.Get(name)
+		for _, marker := range sensitiveParamMarkers {
+			if strings.Contains(strings.ToLower(name), marker) {
+				value = "REDACTED"
+				break
+			}
+		}
+		info.Params[name] = value
+	}
+
+	return info
+}
+
+// queryParams builds the parameters for an instant query
+func queryParams(query string, timestamp time.Time) url.Values {
 	params := url.Values{}
 	params.Set("query", query)
 	if !timestamp.IsZero() {
 		params.Set("time", fmt.Sprintf("%d", timestamp.Unix()))
 	}
+	return params
+}
 
-	resp, err := c.doRequest(ctx, "GET", c.apiPrefix+"/query", params)
+// Query executes an instant PromQL query
+func (c *Client) Query(ctx context.Context, query string, timestamp time.Time) (*QueryResponse, error) {
+	resp, err := c.doRequest(ctx, "GET", c.apiPrefix+"/query", c.setQueryTimeout(ctx, queryParams(query, timestamp)))
 	if err != nil {
 		return nil, err
 	}
@@ -224,19 +456,29 @@ func (c *Client) Query(ctx context.Context, query string, timestamp time.Time) (
 	if queryResp.Status != "success" {
 		return nil, fmt.Errorf("query error: %s - %s", queryResp.ErrorType, queryResp.Error)
 	}
+	markPartialData(&queryResp)
 
 	return &queryResp, nil
 }
 
-// QueryRange executes a range PromQL query
-func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (*QueryResponse, error) {
+// DescribeQueryRange returns the request QueryRange would issue, redacted like DescribeQuery
+func (c *Client) DescribeQueryRange(query string, start, end time.Time, step time.Duration) *RequestInfo {
+	return c.describeRequest("GET", c.apiPrefix+"/query_range", c.setQueryTimeout(context.Background(), queryRangeParams(query, start, end, step)))
+}
+
+// queryRangeParams builds the parameters for a range query
+func queryRangeParams(query string, start, end time.Time, step time.Duration) url.Values {
 	params := url.Values{}
 	params.Set("query", query)
 	params.Set("start", fmt.Sprintf("%d", start.Unix()))
 	params.Set("end", fmt.Sprintf("%d", end.Unix()))
 	params.Set("step", fmt.Sprintf("%d", int(step.Seconds())))
+	return params
+}
 
-	resp, err := c.doRequest(ctx, "GET", c.apiPrefix+"/query_range", params)
+// QueryRange executes a range PromQL query
+func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (*QueryResponse, error) {
+	resp, err := c.doRequest(ctx, "GET", c.apiPrefix+"/query_range", c.setQueryTimeout(ctx, queryRangeParams(query, start, end, step)))
 	if err != nil {
 		return nil, err
 	}
@@ -259,6 +501,7 @@ func (c *Client) QueryRange(ctx context.Context, query string, start, end time.T
 	if queryResp.Status != "success" {
 		return nil, fmt.Errorf("query_range error: %s - %s", queryResp.ErrorType, queryResp.Error)
 	}
+	markPartialData(&queryResp)
 
 	return &queryResp, nil
 }
@@ -297,10 +540,20 @@ func (c *Client) GetMetricNames(ctx context.Context) ([]string, error) {
 
 // GetLabelValues gets possible values for a specific label
 func (c *Client) GetLabelValues(ctx context.Context, labelName string, metricMatchers ...string) ([]string, error) {
+	return c.GetLabelValuesWithLimit(ctx, labelName, 0, metricMatchers...)
+}
+
+// GetLabelValuesWithLimit gets at most limit values for a specific label, so
+// the backend stops collecting values of high-cardinality labels early. A
+// limit of zero returns every value.
+func (c *Client) GetLabelValuesWithLimit(ctx context.Context, labelName string, limit int, metricMatchers ...string) ([]string, error) {
 	params := url.Values{}
 	if len(metricMatchers) > 0 {
 		params.Set("match[]", metricMatchers[0])
 	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
 
 	path := fmt.Sprintf("%s/label/%s/values", c.apiPrefix, url.PathEscape(labelName))
 	resp, err := c.doRequest(ctx, "GET", path, params)
@@ -344,7 +597,7 @@ func (c *Client) GetMetricMetadata(ctx context.Context, metricName string) (*Met
 	if err != nil {
 		// Fallback to inferring type from metric name
 		return &MetricMetadata{
-			Type: inferMetricType(metricName),
+			Type: inferMetricType(metricName, c.typeOverrides),
 			Help: "",
 			Unit: "",
 		}, nil
@@ -354,14 +607,14 @@ func (c *Client) GetMetricMetadata(ctx context.Context, metricName string) (*Met
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return &MetricMetadata{
-			Type: inferMetricType(metricName),
+			Type: inferMetricType(metricName, c.typeOverrides),
 		}, nil
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		// Fallback to inferring type
 		return &MetricMetadata{
-			Type: inferMetricType(metricName),
+			Type: inferMetricType(metricName, c.typeOverrides),
 		}, nil
 	}
 
@@ -371,20 +624,56 @@ func (c *Client) GetMetricMetadata(ctx context.Context, metricName string) (*Met
 	}
 	if err := json.Unmarshal(body, &result); err != nil {
 		return &MetricMetadata{
-			Type: inferMetricType(metricName),
+			Type: inferMetricType(metricName, c.typeOverrides),
 		}, nil
 	}
 
 	if result.Status == "success" && len(result.Data[metricName]) > 0 {
-		return &result.Data[metricName][0], nil
+		metadata := result.Data[metricName][0]
+		metadata.Type = string(c.typeOverrides.InferType(metricName, &metadata))
+		return &metadata, nil
 	}
 
 	// Fallback to inferring type
 	return &MetricMetadata{
-		Type: inferMetricType(metricName),
+		Type: inferMetricType(metricName, c.typeOverrides),
 	}, nil
 }
 
+// GetAllMetricMetadata retrieves the metadata of every metric in a single
+// request, keyed by metric name. Unlike GetMetricMetadata it reports failures
+// instead of inferring types, and types are as reported by Mimir.
+func (c *Client) GetAllMetricMetadata(ctx context.Context) (map[string]MetricMetadata, error) {
+	resp, err := c.doRequest(ctx, "GET", c.apiPrefix+"/metadata", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata request failed with status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Status string                      `json:"status"`
+		Data   map[string][]MetricMetadata `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata response: %w", err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("get metadata failed")
+	}
+
+	metadata := make(map[string]MetricMetadata, len(result.Data))
+	for name, entries := range result.Data {
+		if len(entries) > 0 {
+			metadata[name] = entries[0]
+		}
+	}
+	return metadata, nil
+}
+
 // TestConnection tests connectivity to Mimir
 func (c *Client) TestConnection(ctx context.Context) error {
 	// Execute a simple query to test connectivity
@@ -395,19 +684,8 @@ func (c *Client) TestConnection(ctx context.Context) error {
 	return nil
 }
 
-// inferMetricType infers metric type from naming conventions
-func inferMetricType(metricName string) string {
-	if strings.HasSuffix(metricName, "_total") || strings.HasSuffix(metricName, "_count") {
-		return "counter"
-	}
-	if strings.Contains(metricName, "_bucket") || strings.Contains(metricName, "_histogram") {
-		return "histogram"
-	}
-	if strings.Contains(metricName, "_duration") || strings.Contains(metricName, "_time") || strings.Contains(metricName, "_latency") {
-		return "histogram"
-	}
-	if strings.Contains(metricName, "_summary") {
-		return "summary"
-	}
-	return "gauge"
+// inferMetricType infers metric type from the overrides and naming
+// conventions. Metrics with no naming signal are reported as unknown.
+func inferMetricType(metricName string, overrides *metrics.TypeOverrides) string {
+	return string(overrides.InferType(metricName, nil))
 }
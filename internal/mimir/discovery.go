@@ -3,13 +3,22 @@ package mimir
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"path"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
+	appconfig "github.com/seanankenbruck/observability-ai/internal/config"
+	"github.com/seanankenbruck/observability-ai/internal/events"
+	"github.com/seanankenbruck/observability-ai/internal/notify"
+	"github.com/seanankenbruck/observability-ai/internal/observability"
 	"github.com/seanankenbruck/observability-ai/internal/semantic"
 )
 
@@ -20,26 +29,202 @@ type DiscoveryConfig struct {
 	Namespaces        []string
 	ServiceLabelNames []string
 	ExcludeMetrics    []string
+
+	// ExcludeNamespaces are regex patterns matched against the whole namespace
+	// name. Services in a matching namespace are skipped even if the namespace
+	// is in Namespaces.
+	ExcludeNamespaces []string
+
+	// ServiceExcludeMetrics maps service globs to metric exclude patterns that
+	// apply only to matching services, after the global ExcludeMetrics. A glob
+	// containing "/" matches "namespace/service"; otherwise it matches the
+	// service name in any namespace.
+	ServiceExcludeMetrics map[string][]string
+
+	// Retry behavior for failed discovery cycles. A failed cycle is retried
+	// immediately with exponential backoff (up to MaxRetries times) before
+	// falling back to the normal interval. Zero disables retries; a negative
+	// MaxRetries uses the default of 3.
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+
+	// FailureThreshold is the number of consecutive failed attempts after
+	// which discovery reports itself as unhealthy
+	FailureThreshold int
+
+	// MaxLabelValues caps the number of label values processed per metric and
+	// label, bounding memory use on high-cardinality clusters
+	MaxLabelValues int
+
+	// CallTimeout bounds each Mimir call made during discovery. A metric whose
+	// label lookup times out is skipped for the cycle instead of stalling it.
+	CallTimeout time.Duration
+
+	// DefaultNamespace is assigned to services whose metrics carry no
+	// namespace label
+	DefaultNamespace string
+
+	// ServiceSignalWeights enables multi-signal service identification. Keys
+	// are service label names, or ServiceSignalMetricName for the service
+	// name extracted from the metric name, and values are how much each
+	// signal is trusted. A metric is attributed to the candidates with the
+	// highest combined weight. Empty keeps single-signal identification: the
+	// first of ServiceLabelNames with values wins, falling back to the metric
+	// name.
+	ServiceSignalWeights map[string]float64
+
+	// MinServiceConfidence is the confidence below which a multi-signal
+	// identification is logged for review
+	MinServiceConfidence float64
+
+	// NormalizeServiceNames lowercases discovered service names and joins
+	// their words with ServiceNameSeparator, so variants such as
+	// "user-service", "UserService" and "user_service" collapse into one
+	// service. The original names are kept in the service's
+	// OriginalNamesLabel label.
+	NormalizeServiceNames bool
+	ServiceNameSeparator  string
+}
+
+// ServiceSignalMetricName is the ServiceSignalWeights key of the service name
+// extracted from the metric name
+const ServiceSignalMetricName = "metric_name"
+
+// OriginalNamesLabel is the label listing, comma-separated, the discovered
+// names a normalized service name was derived from
+const OriginalNamesLabel = "original_names"
+
+// errCallTimeout is returned when a single discovery call exceeds CallTimeout
+var errCallTimeout = errors.New("mimir call timed out")
+
+// DiscoveryStatus reports the health of the discovery loop
+type DiscoveryStatus struct {
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	FailureThreshold    int       `json:"failure_threshold"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastSuccess         time.Time `json:"last_success,omitempty"`
+	LastFailure         time.Time `json:"last_failure,omitempty"`
 }
 
 // DiscoveredService represents a service discovered from metrics
 type DiscoveredService struct {
-	Name      string
-	Namespace string
-	Labels    map[string]string
-	Metrics   []string
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Labels    map[string]string `json:"labels"`
+	Metrics   []string          `json:"metrics"`
+}
+
+// PreviewService is a discovered service annotated with the change discovery would make
+type PreviewService struct {
+	DiscoveredService
+	Action string `json:"action"` // "create" or "update"
+}
+
+// DiscoveryPreview is the result of a dry-run discovery cycle
+type DiscoveryPreview struct {
+	Services        []PreviewService `json:"services"`
+	TotalMetrics    int              `json:"total_metrics"`
+	FilteredMetrics int              `json:"filtered_metrics"`
+	ToCreate        int              `json:"to_create"`
+	ToUpdate        int              `json:"to_update"`
 }
 
 // DiscoveryService automatically discovers services and metrics from Mimir
 type DiscoveryService struct {
-	client         *Client
-	config         DiscoveryConfig
-	mapper         semantic.Mapper
-	stopChan       chan struct{}
-	ticker         *time.Ticker
-	running        bool
-	mu             sync.Mutex
-	excludePatterns []*regexp.Regexp
+	client                   *Client
+	config                   DiscoveryConfig
+	mapper                   semantic.Mapper
+	stopChan                 chan struct{}
+	ticker                   *time.Ticker
+	running                  bool
+	mu                       sync.Mutex
+	excludePatterns          []*regexp.Regexp
+	excludeNamespacePatterns []*regexp.Regexp
+	serviceExcludes          []serviceMetricExclude
+
+	statusMu sync.RWMutex
+	status   DiscoveryStatus
+
+	// labelValues memoizes label value lookups within a discovery cycle
+	labelValues labelValueCache
+
+	// events receives a discovery_run event for every discovery cycle
+	events *events.Bus
+
+	// notifier is told when discovery becomes unhealthy and when it recovers
+	notifier notify.Notifier
+}
+
+// serviceMetricExclude holds the metric exclude patterns for services
+// matching a glob
+type serviceMetricExclude struct {
+	glob     string
+	patterns []*regexp.Regexp
+}
+
+// labelValueCache memoizes label value lookups for a single discovery cycle,
+// so the same label of the same metric is fetched from Mimir only once
+type labelValueCache struct {
+	mu     sync.Mutex
+	values map[string][]string
+	hits   int
+	misses int
+}
+
+// reset clears the cache at the start of a cycle, so label changes since the
+// previous cycle are picked up
+func (c *labelValueCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values = make(map[string][]string)
+	c.hits, c.misses = 0, 0
+}
+
+// get returns the cached values for key, counting the lookup as a hit or miss
+func (c *labelValueCache) get(key string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	values, ok := c.values[key]
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	return values, ok
+}
+
+func (c *labelValueCache) put(key string, values []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.values == nil {
+		c.values = make(map[string][]string)
+	}
+	c.values[key] = values
+}
+
+// stats returns the lookups served from the cache and from Mimir this cycle
+func (c *labelValueCache) stats() (hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+type labelValueCacheKey struct{}
+
+// withLabelValueCache returns a context whose label value lookups use cache
+// instead of the discovery cycle's
+func withLabelValueCache(ctx context.Context, cache *labelValueCache) context.Context {
+	return context.WithValue(ctx, labelValueCacheKey{}, cache)
+}
+
+// labelValueCacheFor returns the label value cache of ctx, defaulting to the
+// discovery cycle's
+func (ds *DiscoveryService) labelValueCacheFor(ctx context.Context) *labelValueCache {
+	if cache, ok := ctx.Value(labelValueCacheKey{}).(*labelValueCache); ok {
+		return cache
+	}
+	return &ds.labelValues
 }
 
 // NewDiscoveryService creates a new discovery service
@@ -51,6 +236,33 @@ func NewDiscoveryService(client *Client, config DiscoveryConfig, mapper semantic
 	if len(config.ServiceLabelNames) == 0 {
 		config.ServiceLabelNames = []string{"service", "job", "app", "application"}
 	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 3
+	}
+	if config.RetryBaseDelay == 0 {
+		config.RetryBaseDelay = 1 * time.Second
+	}
+	if config.RetryMaxDelay == 0 {
+		config.RetryMaxDelay = 30 * time.Second
+	}
+	if config.FailureThreshold == 0 {
+		config.FailureThreshold = 3
+	}
+	if config.MaxLabelValues <= 0 {
+		config.MaxLabelValues = 1000
+	}
+	if config.CallTimeout <= 0 {
+		config.CallTimeout = 10 * time.Second
+	}
+	if config.DefaultNamespace == "" {
+		config.DefaultNamespace = appconfig.DefaultNamespace
+	}
+	if config.MinServiceConfidence <= 0 {
+		config.MinServiceConfidence = 0.5
+	}
+	if config.ServiceNameSeparator == "" {
+		config.ServiceNameSeparator = "-"
+	}
 
 	// Compile exclude patterns
 	var excludePatterns []*regexp.Regexp
@@ -62,15 +274,67 @@ func NewDiscoveryService(client *Client, config DiscoveryConfig, mapper semantic
 		}
 	}
 
+	// Compile namespace exclude patterns, anchored so plain names match exactly
+	var excludeNamespacePatterns []*regexp.Regexp
+	for _, pattern := range config.ExcludeNamespaces {
+		if re, err := regexp.Compile("^(?:" + pattern + ")$"); err == nil {
+			excludeNamespacePatterns = append(excludeNamespacePatterns, re)
+		} else {
+			log.Printf("Warning: Invalid namespace exclude pattern %s: %v", pattern, err)
+		}
+	}
+
+	// Compile per-service exclude patterns, in glob order for stable logs
+	globs := make([]string, 0, len(config.ServiceExcludeMetrics))
+	for glob := range config.ServiceExcludeMetrics {
+		globs = append(globs, glob)
+	}
+	sort.Strings(globs)
+	var serviceExcludes []serviceMetricExclude
+	for _, glob := range globs {
+		if _, err := path.Match(glob, ""); err != nil {
+			log.Printf("Warning: Invalid service glob %s: %v", glob, err)
+			continue
+		}
+		exclude := serviceMetricExclude{glob: glob}
+		for _, pattern := range config.ServiceExcludeMetrics[glob] {
+			if re, err := regexp.Compile(pattern); err == nil {
+				exclude.patterns = append(exclude.patterns, re)
+			} else {
+				log.Printf("Warning: Invalid exclude pattern %s for services %s: %v", pattern, glob, err)
+			}
+		}
+		if len(exclude.patterns) > 0 {
+			serviceExcludes = append(serviceExcludes, exclude)
+		}
+	}
+
 	return &DiscoveryService{
-		client:          client,
-		config:          config,
-		mapper:          mapper,
-		stopChan:        make(chan struct{}),
-		excludePatterns: excludePatterns,
+		client:                   client,
+		config:                   config,
+		mapper:                   mapper,
+		stopChan:                 make(chan struct{}),
+		excludePatterns:          excludePatterns,
+		excludeNamespacePatterns: excludeNamespacePatterns,
+		serviceExcludes:          serviceExcludes,
+		notifier:                 notify.Nop{},
+		status: DiscoveryStatus{
+			FailureThreshold: config.FailureThreshold,
+		},
 	}
 }
 
+// SetEventBus publishes the outcome of each discovery cycle to the bus
+func (ds *DiscoveryService) SetEventBus(bus *events.Bus) {
+	ds.events = bus
+}
+
+// SetNotifier notifies when consecutive failures reach FailureThreshold and
+// when discovery succeeds again afterwards
+func (ds *DiscoveryService) SetNotifier(notifier notify.Notifier) {
+	ds.notifier = notifier
+}
+
 // Start begins periodic service discovery
 func (ds *DiscoveryService) Start(ctx context.Context) error {
 	ds.mu.Lock()
@@ -94,7 +358,7 @@ func (ds *DiscoveryService) Start(ctx context.Context) error {
 
 	// Run initial discovery immediately
 	go func() {
-		if err := ds.runDiscovery(ctx); err != nil {
+		if err := ds.runDiscoveryWithRetry(ctx); err != nil {
 			log.Printf("Initial discovery error: %v", err)
 		}
 	}()
@@ -134,20 +398,141 @@ func (ds *DiscoveryService) discoveryLoop(ctx context.Context) {
 		case <-ds.stopChan:
 			return
 		case <-ds.ticker.C:
-			if err := ds.runDiscovery(ctx); err != nil {
+			if err := ds.runDiscoveryWithRetry(ctx); err != nil {
 				log.Printf("Discovery error: %v", err)
 			}
 		}
 	}
 }
 
+// runDiscoveryWithRetry runs a discovery cycle, retrying failures with
+// exponential backoff before giving up until the next scheduled interval
+func (ds *DiscoveryService) runDiscoveryWithRetry(ctx context.Context) error {
+	var err error
+	for attempt := 0; attempt <= ds.config.MaxRetries; attempt++ {
+		err = ds.runDiscovery(ctx)
+		if err == nil {
+			ds.recordSuccess()
+			return nil
+		}
+
+		ds.recordFailure(err)
+
+		// Last attempt - don't wait, fall back to the normal interval
+		if attempt == ds.config.MaxRetries {
+			break
+		}
+
+		delay := discoveryBackoff(attempt, ds.config.RetryBaseDelay, ds.config.RetryMaxDelay)
+		log.Printf("Discovery attempt %d failed, retrying in %v: %v", attempt+1, delay, err)
+
+		select {
+		case <-time.After(delay):
+			continue
+		case <-ds.stopChan:
+			return err
+		case <-ctx.Done():
+			return fmt.Errorf("discovery retry cancelled: %w", ctx.Err())
+		}
+	}
+
+	status := ds.Status()
+	log.Printf("Discovery failed after %d attempts (%d consecutive failures), waiting for next interval: %v",
+		ds.config.MaxRetries+1, status.ConsecutiveFailures, err)
+	return err
+}
+
+// discoveryBackoff calculates the delay before the next retry attempt
+func discoveryBackoff(attempt int, baseDelay, maxDelay time.Duration) time.Duration {
+	delay := baseDelay << uint(attempt)
+	if delay <= 0 || delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+// recordSuccess resets the consecutive failure count after a successful cycle
+func (ds *DiscoveryService) recordSuccess() {
+	ds.statusMu.Lock()
+	defer ds.statusMu.Unlock()
+
+	if ds.status.ConsecutiveFailures >= ds.status.FailureThreshold {
+		notify.Send(ds.notifier, notify.Event{
+			Type:     notify.TypeDiscoveryRecovered,
+			Severity: notify.SeverityInfo,
+			Message:  fmt.Sprintf("Service discovery recovered after %d consecutive failures", ds.status.ConsecutiveFailures),
+			Data: map[string]interface{}{
+				"consecutive_failures": ds.status.ConsecutiveFailures,
+			},
+		})
+	}
+
+	ds.status.ConsecutiveFailures = 0
+	ds.status.LastError = ""
+	ds.status.LastSuccess = time.Now()
+}
+
+// recordFailure increments the consecutive failure count for a failed attempt
+func (ds *DiscoveryService) recordFailure(err error) {
+	ds.statusMu.Lock()
+	defer ds.statusMu.Unlock()
+
+	ds.status.ConsecutiveFailures++
+	ds.status.LastError = err.Error()
+	ds.status.LastFailure = time.Now()
+
+	observability.GetGlobalMetrics().Inc(observability.MetricDiscoveryErrors, nil)
+
+	// Notify once, as discovery becomes unhealthy
+	if ds.status.ConsecutiveFailures == ds.status.FailureThreshold {
+		notify.Send(ds.notifier, notify.Event{
+			Type:     notify.TypeDiscoveryUnhealthy,
+			Severity: notify.SeverityWarning,
+			Message:  fmt.Sprintf("Service discovery is unhealthy after %d consecutive failures", ds.status.ConsecutiveFailures),
+			Data: map[string]interface{}{
+				"consecutive_failures": ds.status.ConsecutiveFailures,
+				"last_error":           ds.status.LastError,
+			},
+		})
+	}
+}
+
+// Status returns a snapshot of the discovery loop's health
+func (ds *DiscoveryService) Status() DiscoveryStatus {
+	ds.statusMu.RLock()
+	defer ds.statusMu.RUnlock()
+	return ds.status
+}
+
+// Healthy reports whether discovery is below the consecutive failure threshold
+func (ds *DiscoveryService) Healthy() bool {
+	status := ds.Status()
+	return status.ConsecutiveFailures < status.FailureThreshold
+}
+
 // runDiscovery performs a single discovery cycle
-func (ds *DiscoveryService) runDiscovery(ctx context.Context) error {
+func (ds *DiscoveryService) runDiscovery(ctx context.Context) (err error) {
 	log.Println("Starting service discovery cycle...")
 	startTime := time.Now()
+	ds.labelValues.reset()
+
+	var services []DiscoveredService
+	var updates int
+	defer func() {
+		data := map[string]interface{}{
+			"success":     err == nil,
+			"duration_ms": time.Since(startTime).Milliseconds(),
+			"services":    len(services),
+			"updates":     updates,
+		}
+		if err != nil {
+			data["error"] = err.Error()
+		}
+		ds.events.Publish(events.TypeDiscoveryRun, data)
+	}()
 
 	// Fetch all metric names
-	metricNames, err := ds.client.GetMetricNames(ctx)
+	metricNames, err := ds.getMetricNames(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to fetch metric names: %w", err)
 	}
@@ -159,7 +544,7 @@ func (ds *DiscoveryService) runDiscovery(ctx context.Context) error {
 	log.Printf("Filtered to %d metrics after applying exclusions", len(filteredMetrics))
 
 	// Discover services from metrics
-	services, err := ds.discoverServices(ctx, filteredMetrics)
+	services, err = ds.discoverServices(ctx, filteredMetrics)
 	if err != nil {
 		return fmt.Errorf("failed to discover services: %w", err)
 	}
@@ -167,19 +552,73 @@ func (ds *DiscoveryService) runDiscovery(ctx context.Context) error {
 	log.Printf("Discovered %d services", len(services))
 
 	// Update database with discovered services
-	updates, err := ds.updateDatabase(ctx, services)
+	updates, err = ds.updateDatabase(ctx, services)
 	if err != nil {
 		return fmt.Errorf("failed to update database: %w", err)
 	}
 
+	// Store the help text of the metrics for the prompt; discovery does not
+	// depend on it
+	ds.updateMetricHelp(ctx, filteredMetrics)
+
 	duration := time.Since(startTime)
 	log.Printf("Discovery cycle completed in %v: %d services, %d metrics, %d database updates",
 		duration, len(services), len(filteredMetrics), updates)
+	hits, misses := ds.labelValues.stats()
+	log.Printf("Label value lookups: %d fetched from Mimir, %d served from cycle cache", misses, hits)
 
 	return nil
 }
 
-// filterMetrics filters out metrics matching exclude patterns
+// Preview runs discovery against Mimir without writing to the database and
+// reports the services that would be created or updated. Its label value
+// lookups are cached apart from those of a concurrent discovery cycle.
+func (ds *DiscoveryService) Preview(ctx context.Context) (*DiscoveryPreview, error) {
+	ctx = withLabelValueCache(ctx, &labelValueCache{})
+
+	metricNames, err := ds.getMetricNames(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metric names: %w", err)
+	}
+
+	filteredMetrics := ds.filterMetrics(metricNames)
+
+	services, err := ds.discoverServices(ctx, filteredMetrics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover services: %w", err)
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Namespace != services[j].Namespace {
+			return services[i].Namespace < services[j].Namespace
+		}
+		return services[i].Name < services[j].Name
+	})
+
+	preview := &DiscoveryPreview{
+		Services:        make([]PreviewService, 0, len(services)),
+		TotalMetrics:    len(metricNames),
+		FilteredMetrics: len(filteredMetrics),
+	}
+	for _, discovered := range services {
+		action := "update"
+		if _, err := ds.mapper.GetServiceByName(ctx, discovered.Name, discovered.Namespace); err != nil {
+			action = "create"
+			preview.ToCreate++
+		} else {
+			preview.ToUpdate++
+		}
+		preview.Services = append(preview.Services, PreviewService{
+			DiscoveredService: discovered,
+			Action:            action,
+		})
+	}
+
+	return preview, nil
+}
+
+// filterMetrics filters out metrics matching the global exclude patterns.
+// Per-service patterns are applied once the metric's services are known.
 func (ds *DiscoveryService) filterMetrics(metricNames []string) []string {
 	if len(ds.excludePatterns) == 0 {
 		return metricNames
@@ -202,22 +641,62 @@ func (ds *DiscoveryService) filterMetrics(metricNames []string) []string {
 	return filtered
 }
 
+// metricExcludedForService reports whether metric matches an exclude pattern
+// configured for the service
+func (ds *DiscoveryService) metricExcludedForService(namespace, service, metric string) bool {
+	for _, exclude := range ds.serviceExcludes {
+		subject := service
+		if strings.Contains(exclude.glob, "/") {
+			subject = namespace + "/" + service
+		}
+		if matched, _ := path.Match(exclude.glob, subject); !matched {
+			continue
+		}
+		for _, pattern := range exclude.patterns {
+			if pattern.MatchString(metric) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// namespaceExcluded reports whether namespace matches an exclude pattern
+func (ds *DiscoveryService) namespaceExcluded(namespace string) bool {
+	for _, pattern := range ds.excludeNamespacePatterns {
+		if pattern.MatchString(namespace) {
+			return true
+		}
+	}
+	return false
+}
+
 // discoverServices discovers services from metric names
 func (ds *DiscoveryService) discoverServices(ctx context.Context, metricNames []string) ([]DiscoveredService, error) {
 	serviceMap := make(map[string]*DiscoveredService)
+	originalNames := make(map[string]map[string]bool)
 
 	for _, metricName := range metricNames {
 		// Extract all services that have this metric
-		serviceInfos := ds.extractAllServicesForMetric(ctx, metricName)
+		serviceInfos := ds.servicesForMetric(ctx, metricName)
 
 		for _, info := range serviceInfos {
 			serviceName := info.Name
 			namespace := info.Namespace
 
+			if ds.config.NormalizeServiceNames {
+				serviceName = normalizeServiceName(info.Name, ds.config.ServiceNameSeparator)
+			}
+
 			if serviceName == "" || serviceName == "unknown" {
 				continue
 			}
 
+			// Excluded namespaces are skipped even if they are also included
+			if ds.namespaceExcluded(namespace) {
+				continue
+			}
+
 			// Filter by configured namespaces if specified
 			if len(ds.config.Namespaces) > 0 {
 				found := false
@@ -232,9 +711,22 @@ func (ds *DiscoveryService) discoverServices(ctx context.Context, metricNames []
 				}
 			}
 
+			// Skip metrics excluded for this service only
+			if ds.metricExcludedForService(namespace, serviceName, metricName) {
+				continue
+			}
+
 			key := fmt.Sprintf("%s/%s", namespace, serviceName)
+			if originalNames[key] == nil {
+				originalNames[key] = make(map[string]bool)
+			}
+			originalNames[key][info.Name] = true
+
 			if service, exists := serviceMap[key]; exists {
-				service.Metrics = append(service.Metrics, metricName)
+				// Variants of a normalized name may expose the same metric
+				if last := len(service.Metrics) - 1; service.Metrics[last] != metricName {
+					service.Metrics = append(service.Metrics, metricName)
+				}
 			} else {
 				serviceMap[key] = &DiscoveredService{
 					Name:      serviceName,
@@ -250,19 +742,76 @@ func (ds *DiscoveryService) discoverServices(ctx context.Context, metricNames []
 
 	// Convert map to slice
 	services := make([]DiscoveredService, 0, len(serviceMap))
-	for _, service := range serviceMap {
+	for key, service := range serviceMap {
+		if ds.config.NormalizeServiceNames {
+			names := make([]string, 0, len(originalNames[key]))
+			for name := range originalNames[key] {
+				names = append(names, name)
+			}
+			if len(names) > 1 || names[0] != service.Name {
+				sort.Strings(names)
+				service.Labels[OriginalNamesLabel] = strings.Join(names, ",")
+			}
+		}
 		services = append(services, *service)
 	}
 
 	return services, nil
 }
 
+// normalizeServiceName lowercases a service name and joins its words with
+// separator. Words are split at "-", "_", ".", spaces and camelCase
+// boundaries, so "UserService", "user_service" and "user-service" all
+// become "user-service".
+func normalizeServiceName(name, separator string) string {
+	runes := []rune(name)
+	var words []string
+	var word []rune
+	for i, r := range runes {
+		switch {
+		case r == '-' || r == '_' || r == '.' || unicode.IsSpace(r):
+			if len(word) > 0 {
+				words = append(words, string(word))
+				word = nil
+			}
+			continue
+		case unicode.IsUpper(r) && len(word) > 0:
+			// Split "userService" before "S", and "HTTPServer" before the
+			// "S" starting "Server"
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				words = append(words, string(word))
+				word = nil
+			}
+		}
+		word = append(word, unicode.ToLower(r))
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+
+	if len(words) == 0 {
+		return name
+	}
+	return strings.Join(words, separator)
+}
+
 // ServiceInfo holds discovered service information
 type ServiceInfo struct {
 	Name      string
 	Namespace string
 }
 
+// servicesForMetric identifies the services exposing a metric, combining
+// weighted signals when ServiceSignalWeights is set
+func (ds *DiscoveryService) servicesForMetric(ctx context.Context, metricName string) []ServiceInfo {
+	if len(ds.config.ServiceSignalWeights) > 0 {
+		return ds.scoreServicesForMetric(ctx, metricName)
+	}
+	return ds.extractAllServicesForMetric(ctx, metricName)
+}
+
 // extractAllServicesForMetric extracts all services that have this metric
 func (ds *DiscoveryService) extractAllServicesForMetric(ctx context.Context, metricName string) []ServiceInfo {
 	var results []ServiceInfo
@@ -270,7 +819,12 @@ func (ds *DiscoveryService) extractAllServicesForMetric(ctx context.Context, met
 
 	// Try to get services from label values
 	for _, labelName := range ds.config.ServiceLabelNames {
-		values, err := ds.client.GetLabelValues(ctx, labelName, metricName)
+		values, err := ds.getLabelValues(ctx, labelName, metricName)
+		if errors.Is(err, errCallTimeout) {
+			log.Printf("Warning: skipping metric %s this cycle, label %s lookup failed: %v", metricName, labelName, err)
+			return nil
+		}
+		values = ds.capLabelValues(metricName, labelName, values)
 		if err == nil && len(values) > 0 {
 			// Found services with this label - add all of them
 			for _, serviceName := range values {
@@ -280,8 +834,12 @@ func (ds *DiscoveryService) extractAllServicesForMetric(ctx context.Context, met
 				serviceNames[serviceName] = true
 
 				// Get namespace for this service
-				namespace := "default"
-				namespaceValues, err := ds.client.GetLabelValues(ctx, "namespace", metricName)
+				namespace := ds.config.DefaultNamespace
+				namespaceValues, err := ds.getLabelValues(ctx, "namespace", metricName)
+				if errors.Is(err, errCallTimeout) {
+					log.Printf("Warning: skipping metric %s this cycle, namespace lookup failed: %v", metricName, err)
+					return nil
+				}
 				if err == nil && len(namespaceValues) > 0 {
 					namespace = namespaceValues[0]
 				}
@@ -304,7 +862,7 @@ func (ds *DiscoveryService) extractAllServicesForMetric(ctx context.Context, met
 		if serviceName != "" && serviceName != "unknown" {
 			results = append(results, ServiceInfo{
 				Name:      serviceName,
-				Namespace: "default",
+				Namespace: ds.config.DefaultNamespace,
 			})
 		}
 	}
@@ -312,13 +870,213 @@ func (ds *DiscoveryService) extractAllServicesForMetric(ctx context.Context, met
 	return results
 }
 
+// scoreServicesForMetric identifies the services exposing a metric from every
+// weighted signal rather than the first one with values. Each candidate scores
+// the combined weight of the signals naming it, and the candidates with the
+// highest score are returned, so a metric exposed by several services under
+// the same label keeps all of them. Values of "unknown" are not treated as
+// service names, letting other signals identify the service.
+//
+// Confidence is the winning score as a share of the weight of the signals
+// present, where a signal on its own is measured against the strongest
+// configured signal. Identifications below MinServiceConfidence are logged.
+func (ds *DiscoveryService) scoreServicesForMetric(ctx context.Context, metricName string) []ServiceInfo {
+	scores := make(map[string]float64)
+	var present, strongest float64
+
+	for _, labelName := range ds.signalLabels() {
+		weight := ds.config.ServiceSignalWeights[labelName]
+		strongest = math.Max(strongest, weight)
+
+		values, err := ds.getLabelValues(ctx, labelName, metricName)
+		if errors.Is(err, errCallTimeout) {
+			log.Printf("Warning: skipping metric %s this cycle, label %s lookup failed: %v", metricName, labelName, err)
+			return nil
+		}
+		if err != nil {
+			continue
+		}
+
+		named := make(map[string]bool)
+		for _, value := range ds.capLabelValues(metricName, labelName, values) {
+			if value == "" || value == "unknown" || named[value] {
+				continue
+			}
+			named[value] = true
+			scores[value] += weight
+		}
+		if len(named) > 0 {
+			present += weight
+		}
+	}
+
+	if weight := ds.config.ServiceSignalWeights[ServiceSignalMetricName]; weight > 0 {
+		strongest = math.Max(strongest, weight)
+		if serviceName := ds.extractServiceFromMetricName(metricName); serviceName != "unknown" {
+			scores[serviceName] += weight
+			present += weight
+		}
+	}
+
+	if len(scores) == 0 {
+		return nil
+	}
+
+	var best float64
+	for _, score := range scores {
+		best = math.Max(best, score)
+	}
+	var winners, others []string
+	for serviceName, score := range scores {
+		// Tolerate rounding from summing the same weights in another order
+		if best-score < 1e-9 {
+			winners = append(winners, serviceName)
+		} else {
+			others = append(others, serviceName)
+		}
+	}
+	sort.Strings(winners)
+
+	confidence := best / math.Max(present, strongest)
+	if confidence < ds.config.MinServiceConfidence {
+		sort.Strings(others)
+		log.Printf("Warning: low-confidence service identity for metric %s: %s (confidence %.2f, other candidates: %v)",
+			metricName, strings.Join(winners, ", "), confidence, others)
+	}
+
+	namespace := ds.config.DefaultNamespace
+	namespaceValues, err := ds.getLabelValues(ctx, "namespace", metricName)
+	if errors.Is(err, errCallTimeout) {
+		log.Printf("Warning: skipping metric %s this cycle, namespace lookup failed: %v", metricName, err)
+		return nil
+	}
+	if err == nil && len(namespaceValues) > 0 {
+		namespace = namespaceValues[0]
+	}
+
+	results := make([]ServiceInfo, 0, len(winners))
+	for _, serviceName := range winners {
+		results = append(results, ServiceInfo{Name: serviceName, Namespace: namespace})
+	}
+	return results
+}
+
+// signalLabels returns the weighted service labels in name order, skipping
+// the metric name signal and labels weighted zero
+func (ds *DiscoveryService) signalLabels() []string {
+	labels := make([]string, 0, len(ds.config.ServiceSignalWeights))
+	for labelName, weight := range ds.config.ServiceSignalWeights {
+		if labelName != ServiceSignalMetricName && weight > 0 {
+			labels = append(labels, labelName)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// capLabelValues truncates a metric's label values to MaxLabelValues,
+// recording each time the cap is hit. Values are fetched with a limit of one
+// more than the cap, so exceeding it is detected without fetching them all.
+func (ds *DiscoveryService) capLabelValues(metricName, labelName string, values []string) []string {
+	if len(values) <= ds.config.MaxLabelValues {
+		return values
+	}
+	log.Printf("Warning: metric %s has more than %d values for label %s, processing only the first %d; the catalog may be incomplete",
+		metricName, ds.config.MaxLabelValues, labelName, ds.config.MaxLabelValues)
+	observability.GetGlobalMetrics().Inc(observability.MetricDiscoveryLabelCapHits, map[string]string{
+		"label": labelName,
+	})
+	return values[:ds.config.MaxLabelValues]
+}
+
+// getLabelValues returns the values of a label for a metric, fetching them from
+// Mimir only on the first lookup in the current cycle. Failed lookups are not
+// cached.
+func (ds *DiscoveryService) getLabelValues(ctx context.Context, labelName, metricName string) ([]string, error) {
+	cache := ds.labelValueCacheFor(ctx)
+	key := labelName + "\x00" + metricName
+	if values, ok := cache.get(key); ok {
+		return values, nil
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, ds.config.CallTimeout)
+	defer cancel()
+
+	values, err := ds.client.GetLabelValuesWithLimit(callCtx, labelName, ds.config.MaxLabelValues+1, metricName)
+	if err != nil {
+		return nil, ds.callError(ctx, callCtx, err)
+	}
+	cache.put(key, values)
+	return values, nil
+}
+
+// getMetricNames fetches all metric names, bounded by the call timeout
+func (ds *DiscoveryService) getMetricNames(ctx context.Context) ([]string, error) {
+	callCtx, cancel := context.WithTimeout(ctx, ds.config.CallTimeout)
+	defer cancel()
+
+	metricNames, err := ds.client.GetMetricNames(callCtx)
+	if err != nil {
+		return nil, ds.callError(ctx, callCtx, err)
+	}
+	return metricNames, nil
+}
+
+// updateMetricHelp stores the help text Mimir reports for the metrics as
+// their catalog descriptions. Histogram and summary series, and counters
+// reported without their _total suffix, take the help of their family.
+func (ds *DiscoveryService) updateMetricHelp(ctx context.Context, metricNames []string) {
+	callCtx, cancel := context.WithTimeout(ctx, ds.config.CallTimeout)
+	defer cancel()
+
+	metadata, err := ds.client.GetAllMetricMetadata(callCtx)
+	if err != nil {
+		log.Printf("Failed to fetch metric help text: %v", ds.callError(ctx, callCtx, err))
+		return
+	}
+
+	help := make(map[string]string)
+	for _, name := range metricNames {
+		if text := metricHelp(metadata, name); text != "" {
+			help[name] = text
+		}
+	}
+	if err := ds.mapper.UpdateMetricDescriptions(ctx, help); err != nil {
+		log.Printf("Failed to store metric help text: %v", err)
+		return
+	}
+	log.Printf("Stored help text of %d metrics", len(help))
+}
+
+// metricHelp returns the help text of a metric, or of its metric family
+func metricHelp(metadata map[string]MetricMetadata, name string) string {
+	if text := strings.TrimSpace(metadata[name].Help); text != "" {
+		return text
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count", "_total"} {
+		if family, ok := strings.CutSuffix(name, suffix); ok {
+			return strings.TrimSpace(metadata[family].Help)
+		}
+	}
+	return ""
+}
+
+// callError wraps errors of calls abandoned because their own timeout
+// expired, as opposed to the cycle being cancelled, with errCallTimeout
+func (ds *DiscoveryService) callError(ctx, callCtx context.Context, err error) error {
+	if ctx.Err() == nil && callCtx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%w after %v: %v", errCallTimeout, ds.config.CallTimeout, err)
+	}
+	return err
+}
+
 // extractServiceInfo extracts service name and namespace from a metric (legacy, kept for compatibility)
 func (ds *DiscoveryService) extractServiceInfo(ctx context.Context, metricName string) (serviceName, namespace string) {
 	infos := ds.extractAllServicesForMetric(ctx, metricName)
 	if len(infos) > 0 {
 		return infos[0].Name, infos[0].Namespace
 	}
-	return "", "default"
+	return "", ds.config.DefaultNamespace
 }
 
 // extractServiceFromMetricName extracts service name from metric name using patterns
@@ -383,30 +1141,20 @@ func (ds *DiscoveryService) updateDatabase(ctx context.Context, services []Disco
 	updates := 0
 
 	for _, discovered := range services {
-		// Check if service exists
-		existing, err := ds.mapper.GetServiceByName(ctx, discovered.Name, discovered.Namespace)
+		// CreateService is an upsert, so concurrent discovery runs cannot
+		// create duplicates of the same service
+		service, err := ds.mapper.CreateService(ctx, discovered.Name, discovered.Namespace, discovered.Labels)
 		if err != nil {
-			// Service doesn't exist, create it
-			service, err := ds.mapper.CreateService(ctx, discovered.Name, discovered.Namespace, discovered.Labels)
-			if err != nil {
-				log.Printf("Failed to create service %s/%s: %v", discovered.Namespace, discovered.Name, err)
-				continue
-			}
-			log.Printf("Created new service: %s/%s with %d metrics", discovered.Namespace, discovered.Name, len(discovered.Metrics))
-			updates++
+			log.Printf("Failed to upsert service %s/%s: %v", discovered.Namespace, discovered.Name, err)
+			continue
+		}
 
-			// Update metrics for new service
-			if err := ds.mapper.UpdateServiceMetrics(ctx, service.ID, discovered.Metrics); err != nil {
-				log.Printf("Failed to update metrics for service %s: %v", service.ID, err)
-			}
-		} else {
-			// Service exists, check if we need to update metrics
-			if err := ds.mapper.UpdateServiceMetrics(ctx, existing.ID, discovered.Metrics); err != nil {
-				log.Printf("Failed to update metrics for service %s: %v", existing.ID, err)
-			} else {
-				updates++
-			}
+		if err := ds.mapper.UpdateServiceMetrics(ctx, service.ID, discovered.Metrics); err != nil {
+			log.Printf("Failed to update metrics for service %s: %v", service.ID, err)
+			continue
 		}
+		log.Printf("Updated service %s/%s with %d metrics", discovered.Namespace, discovered.Name, len(discovered.Metrics))
+		updates++
 	}
 
 	return updates, nil
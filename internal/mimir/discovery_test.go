@@ -2,16 +2,23 @@
 package mimir
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/seanankenbruck/observability-ai/internal/notify"
+	"github.com/seanankenbruck/observability-ai/internal/observability"
 	"github.com/seanankenbruck/observability-ai/internal/semantic"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -27,8 +34,11 @@ type MockMapper struct {
 	servicesByName         map[string]*semantic.Service
 	createServiceCallCount int
 	updateMetricsCallCount int
+	descriptions           map[string]string
 }
 
+var _ semantic.Mapper = (*MockMapper)(nil)
+
 func NewMockMapper() *MockMapper {
 	return &MockMapper{
 		services:       make(map[string]*semantic.Service),
@@ -72,6 +82,19 @@ func (m *MockMapper) CreateService(ctx context.Context, name, namespace string,
 		return nil, m.createServiceError
 	}
 
+	// Upsert like PostgresMapper: an existing service keeps its ID and metrics
+	key := fmt.Sprintf("%s/%s", namespace, name)
+	if existing, exists := m.servicesByName[key]; exists {
+		if existing.Labels == nil {
+			existing.Labels = make(map[string]string)
+		}
+		for k, v := range labels {
+			existing.Labels[k] = v
+		}
+		existing.UpdatedAt = time.Now().Format(time.RFC3339)
+		return existing, nil
+	}
+
 	service := &semantic.Service{
 		ID:        fmt.Sprintf("service-%d", len(m.services)+1),
 		Name:      name,
@@ -82,7 +105,6 @@ func (m *MockMapper) CreateService(ctx context.Context, name, namespace string,
 	}
 
 	m.services[service.ID] = service
-	key := fmt.Sprintf("%s/%s", namespace, name)
 	m.servicesByName[key] = service
 
 	return service, nil
@@ -118,22 +140,106 @@ func (m *MockMapper) SearchServices(ctx context.Context, searchTerm string) ([]s
 	return nil, nil
 }
 
+func (m *MockMapper) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *MockMapper) Close() error {
+	return nil
+}
+
+func (m *MockMapper) GetNamespaces(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (m *MockMapper) GetCatalogStats(ctx context.Context) (*semantic.CatalogStats, error) {
+	return &semantic.CatalogStats{}, nil
+}
+
 func (m *MockMapper) GetMetrics(ctx context.Context, serviceID string) ([]semantic.Metric, error) {
 	return nil, nil
 }
 
+func (m *MockMapper) GetMetricsByName(ctx context.Context, name string) ([]semantic.Metric, error) {
+	return nil, nil
+}
+
 func (m *MockMapper) CreateMetric(ctx context.Context, name, metricType, description, serviceID string, labels map[string]string) (*semantic.Metric, error) {
 	return nil, nil
 }
 
+func (m *MockMapper) UpdateMetricDescriptions(ctx context.Context, descriptions map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.descriptions = descriptions
+	return nil
+}
+
+func (m *MockMapper) GetMetricDescriptions(ctx context.Context, names []string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	descriptions := make(map[string]string)
+	for _, name := range names {
+		if description, ok := m.descriptions[name]; ok {
+			descriptions[name] = description
+		}
+	}
+	return descriptions, nil
+}
+
 func (m *MockMapper) FindSimilarQueries(ctx context.Context, embedding []float32) ([]semantic.SimilarQuery, error) {
 	return nil, nil
 }
 
+func (m *MockMapper) FindSimilarQueriesBatch(ctx context.Context, embeddings [][]float32) ([][]semantic.SimilarQuery, error) {
+	results := make([][]semantic.SimilarQuery, len(embeddings))
+	for i, embedding := range embeddings {
+		similar, err := m.FindSimilarQueries(ctx, embedding)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = similar
+	}
+	return results, nil
+}
+
 func (m *MockMapper) StoreQueryEmbedding(ctx context.Context, query string, embedding []float32, promql string) error {
 	return nil
 }
 
+func (m *MockMapper) ListStoredQueries(ctx context.Context, afterID string, limit int) ([]semantic.StoredQuery, error) {
+	return []semantic.StoredQuery{}, nil
+}
+
+func (m *MockMapper) GetRecentQueries(ctx context.Context, limit int, after *semantic.QueryCursor) ([]semantic.StoredQuery, error) {
+	return []semantic.StoredQuery{}, nil
+}
+
+func (m *MockMapper) UpdateQueryEmbedding(ctx context.Context, id string, embedding []float32) error {
+	return nil
+}
+
+func (m *MockMapper) RecordQueryExecution(ctx context.Context, query, promql string) error {
+	return nil
+}
+
+func (m *MockMapper) GetQueryExecutions(ctx context.Context, limit int) ([]semantic.QueryExecution, error) {
+	return nil, nil
+}
+
+func (m *MockMapper) StoreEvaluationSample(ctx context.Context, sample semantic.EvaluationSample) error {
+	return nil
+}
+
+func (m *MockMapper) GetOrStoreEmbedding(ctx context.Context, model, normalizedQuery string, embed semantic.EmbedFunc) ([]float32, bool, error) {
+	embedding, err := embed(ctx)
+	return embedding, false, err
+}
+
+func (m *MockMapper) PurgeEmbeddingCache(ctx context.Context, model string, dimension int) (int, error) {
+	return 0, nil
+}
+
 // TestNewDiscoveryService tests creation of discovery service
 func TestNewDiscoveryService(t *testing.T) {
 	tests := []struct {
@@ -469,7 +575,7 @@ func TestUpdateDatabase(t *testing.T) {
 		name                   string
 		discoveredServices     []DiscoveredService
 		existingServices       map[string]*semantic.Service
-		expectedCreates        int
+		expectedUpserts        int
 		expectedUpdates        int
 		createServiceError     error
 		updateMetricsError     error
@@ -491,7 +597,7 @@ func TestUpdateDatabase(t *testing.T) {
 				},
 			},
 			existingServices: map[string]*semantic.Service{},
-			expectedCreates:  2,
+			expectedUpserts:  2,
 			expectedUpdates:  2,
 		},
 		{
@@ -513,7 +619,7 @@ func TestUpdateDatabase(t *testing.T) {
 					MetricNames: []string{"http_requests_total"},
 				},
 			},
-			expectedCreates: 0,
+			expectedUpserts: 1, // Existing services are upserted too
 			expectedUpdates: 1,
 		},
 		{
@@ -540,7 +646,7 @@ func TestUpdateDatabase(t *testing.T) {
 					Labels:    map[string]string{"namespace": "production"},
 				},
 			},
-			expectedCreates: 1,
+			expectedUpserts: 2,
 			expectedUpdates: 2,
 		},
 		{
@@ -555,7 +661,7 @@ func TestUpdateDatabase(t *testing.T) {
 			},
 			existingServices:   map[string]*semantic.Service{},
 			createServiceError: errors.New("database error"),
-			expectedCreates:    1, // CreateService is called even if it fails
+			expectedUpserts:    1, // CreateService is called even if it fails
 			expectedUpdates:    0, // No updates because creation failed
 		},
 	}
@@ -588,11 +694,40 @@ func TestUpdateDatabase(t *testing.T) {
 				assert.Equal(t, tt.expectedUpdates, updates)
 			}
 
-			assert.Equal(t, tt.expectedCreates, mapper.createServiceCallCount)
+			assert.Equal(t, tt.expectedUpserts, mapper.createServiceCallCount)
 		})
 	}
 }
 
+// TestUpdateDatabaseConcurrent tests that concurrent discovery runs do not duplicate services
+func TestUpdateDatabaseConcurrent(t *testing.T) {
+	client := NewClientWithBackend("http://localhost:9009", AuthConfig{Type: "none"}, 5*time.Second, BackendTypeMimir)
+	mapper := NewMockMapper()
+	ds := NewDiscoveryService(client, DiscoveryConfig{Enabled: true}, mapper)
+
+	discovered := []DiscoveredService{
+		{Name: "api", Namespace: "production", Labels: map[string]string{"namespace": "production"}, Metrics: []string{"http_requests_total"}},
+		{Name: "worker", Namespace: "production", Labels: map[string]string{"namespace": "production"}, Metrics: []string{"jobs_total"}},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			updates, err := ds.updateDatabase(context.Background(), discovered)
+			assert.NoError(t, err)
+			assert.Equal(t, 2, updates)
+		}()
+	}
+	wg.Wait()
+
+	services, err := mapper.GetServices(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, services, 2, "each service should exist once")
+	assert.Equal(t, 4, mapper.createServiceCallCount)
+}
+
 // TestRunDiscovery tests full discovery cycle
 func TestRunDiscovery(t *testing.T) {
 	// Create mock Mimir server
@@ -629,6 +764,16 @@ func TestRunDiscovery(t *testing.T) {
 					"result":     []interface{}{},
 				},
 			})
+		} else if path == "/prometheus/api/v1/metadata" {
+			// Counters may be reported by their family name
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data": map[string]interface{}{
+					"http_requests":     []map[string]string{{"type": "counter", "help": "Total HTTP requests."}},
+					"http_errors_total": []map[string]string{{"type": "counter", "help": " Total HTTP errors. "}},
+					"go_goroutines":     []map[string]string{{"type": "gauge", "help": "Number of goroutines."}},
+				},
+			})
 		}
 	}))
 	defer server.Close()
@@ -651,6 +796,224 @@ func TestRunDiscovery(t *testing.T) {
 	// Verify services were created
 	assert.Greater(t, mapper.createServiceCallCount, 0)
 	assert.Greater(t, mapper.updateMetricsCallCount, 0)
+
+	// Help text is stored for the discovered metrics only
+	assert.Equal(t, map[string]string{
+		"http_requests_total": "Total HTTP requests.",
+		"http_errors_total":   "Total HTTP errors.",
+	}, mapper.descriptions)
+}
+
+// TestDiscoveryPreview tests that preview reports discovered services without writing to the mapper
+func TestDiscoveryPreview(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/prometheus/api/v1/label/__name__/values":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   []string{"http_requests_total", "http_errors_total", "go_goroutines"},
+			})
+		case "/prometheus/api/v1/label/service/values":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   []string{"api", "frontend"},
+			})
+		case "/prometheus/api/v1/label/namespace/values":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   []string{"production"},
+			})
+		}
+	}))
+	defer server.Close()
+
+	client := NewClientWithBackend(server.URL, AuthConfig{Type: "none"}, 5*time.Second, BackendTypeMimir)
+	mapper := NewMockMapper()
+	mapper.servicesByName["production/api"] = &semantic.Service{ID: "service-1", Name: "api", Namespace: "production"}
+
+	ds := NewDiscoveryService(client, DiscoveryConfig{
+		Enabled:        true,
+		ExcludeMetrics: []string{"^go_.*"},
+	}, mapper)
+
+	preview, err := ds.Preview(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, preview.TotalMetrics)
+	assert.Equal(t, 2, preview.FilteredMetrics)
+	require.Len(t, preview.Services, 2)
+
+	assert.Equal(t, "api", preview.Services[0].Name)
+	assert.Equal(t, "production", preview.Services[0].Namespace)
+	assert.Equal(t, "update", preview.Services[0].Action)
+	assert.ElementsMatch(t, []string{"http_requests_total", "http_errors_total"}, preview.Services[0].Metrics)
+
+	assert.Equal(t, "frontend", preview.Services[1].Name)
+	assert.Equal(t, "create", preview.Services[1].Action)
+
+	assert.Equal(t, 1, preview.ToCreate)
+	assert.Equal(t, 1, preview.ToUpdate)
+
+	// Preview must not modify the catalog
+	assert.Equal(t, 0, mapper.createServiceCallCount)
+	assert.Equal(t, 0, mapper.updateMetricsCallCount)
+}
+
+// TestDiscoveryLabelValueCap tests that label values beyond the cap are not
+// fetched from Mimir or processed
+func TestDiscoveryLabelValueCap(t *testing.T) {
+	manyServices := make([]string, 500)
+	for i := range manyServices {
+		manyServices[i] = fmt.Sprintf("service-%03d", i)
+	}
+
+	var mu sync.Mutex
+	var limits []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/prometheus/api/v1/label/service/values":
+			mu.Lock()
+			limits = append(limits, r.URL.Query().Get("limit"))
+			mu.Unlock()
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   manyServices[:limit],
+			})
+		case "/prometheus/api/v1/label/namespace/values":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   []string{"production"},
+			})
+		}
+	}))
+	defer server.Close()
+
+	client := NewClientWithBackend(server.URL, AuthConfig{Type: "none"}, 5*time.Second, BackendTypeMimir)
+	ds := NewDiscoveryService(client, DiscoveryConfig{
+		Enabled:        true,
+		MaxLabelValues: 25,
+	}, NewMockMapper())
+
+	services, err := ds.discoverServices(context.Background(), []string{"http_requests_total"})
+	require.NoError(t, err)
+
+	assert.Len(t, services, 25)
+	for _, service := range services {
+		assert.Less(t, service.Name, "service-025", "only the first 25 label values should be processed")
+	}
+	mu.Lock()
+	assert.Equal(t, []string{"26"}, limits, "the cap should be applied in the Mimir request")
+	mu.Unlock()
+}
+
+// TestDiscoveryLabelValueCache tests that repeated label lookups within a cycle make a single Mimir call
+func TestDiscoveryLabelValueCache(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path+"?"+r.URL.Query().Get("match[]")]++
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/prometheus/api/v1/label/__name__/values":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   []string{"http_requests_total"},
+			})
+		case "/prometheus/api/v1/label/service/values":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   []string{"api", "frontend", "worker"},
+			})
+		case "/prometheus/api/v1/label/namespace/values":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   []string{"production"},
+			})
+		}
+	}))
+	defer server.Close()
+
+	client := NewClientWithBackend(server.URL, AuthConfig{Type: "none"}, 5*time.Second, BackendTypeMimir)
+	ds := NewDiscoveryService(client, DiscoveryConfig{Enabled: true}, NewMockMapper())
+	namespaceLookup := "/prometheus/api/v1/label/namespace/values?http_requests_total"
+
+	require.NoError(t, ds.runDiscovery(context.Background()))
+	mu.Lock()
+	assert.Equal(t, 1, requests[namespaceLookup], "namespace lookups for the same metric should hit Mimir once per cycle")
+	mu.Unlock()
+	hits, misses := ds.labelValues.stats()
+	assert.Equal(t, 2, hits)
+	assert.Equal(t, 2, misses)
+
+	// The cache is cleared between cycles
+	require.NoError(t, ds.runDiscovery(context.Background()))
+	mu.Lock()
+	assert.Equal(t, 2, requests[namespaceLookup])
+	mu.Unlock()
+
+	// A preview caches its lookups apart from the cycle's
+	_, err := ds.Preview(context.Background())
+	require.NoError(t, err)
+	mu.Lock()
+	assert.Equal(t, 3, requests[namespaceLookup])
+	mu.Unlock()
+	hits, misses = ds.labelValues.stats()
+	assert.Equal(t, 2, hits)
+	assert.Equal(t, 2, misses)
+}
+
+// TestDiscoveryCallTimeout tests that a slow label lookup skips its metric without failing the cycle
+func TestDiscoveryCallTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := r.URL.Query().Get("match[]")
+		switch r.URL.Path {
+		case "/prometheus/api/v1/label/__name__/values":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   []string{"http_requests_total", "slow_requests_total"},
+			})
+		case "/prometheus/api/v1/label/service/values":
+			service := "api"
+			if strings.Contains(match, "slow_requests_total") {
+				// Hang until the discovery call is abandoned
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(5 * time.Second):
+				}
+				service = "slow"
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   []string{service},
+			})
+		case "/prometheus/api/v1/label/namespace/values":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   []string{"production"},
+			})
+		}
+	}))
+	defer server.Close()
+
+	client := NewClientWithBackend(server.URL, AuthConfig{Type: "none"}, 5*time.Second, BackendTypeMimir)
+	mapper := NewMockMapper()
+	ds := NewDiscoveryService(client, DiscoveryConfig{Enabled: true, CallTimeout: 50 * time.Millisecond}, mapper)
+
+	start := time.Now()
+	require.NoError(t, ds.runDiscovery(context.Background()))
+	assert.Less(t, time.Since(start), 2*time.Second, "the slow lookup should not stall the cycle")
+
+	mapper.mu.Lock()
+	defer mapper.mu.Unlock()
+	assert.Contains(t, mapper.servicesByName, "production/api")
+	assert.NotContains(t, mapper.servicesByName, "production/slow")
+	// The slow metric is skipped rather than attributed to a service derived from its name
+	assert.Len(t, mapper.servicesByName, 1)
 }
 
 // TestDiscoveryServiceStartStop tests starting and stopping the discovery service
@@ -797,3 +1160,589 @@ func TestDiscoverServicesWithNamespaceFilter(t *testing.T) {
 		assert.NotEqual(t, "development", service.Namespace)
 	}
 }
+
+// TestDiscoverServicesWithExcludedNamespaces tests namespace exclude patterns
+func TestDiscoverServicesWithExcludedNamespaces(t *testing.T) {
+	// Each metric belongs to a different namespace
+	namespaces := map[string]string{
+		"http_requests_total":   "production",
+		"apiserver_requests":    "kube-system",
+		"coredns_dns_requests":  "kube-public",
+		"sandbox_requests":      "sandbox",
+		"sandbox_kube_requests": "my-kube-system",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := r.URL.Query().Get("match[]")
+		switch r.URL.Path {
+		case "/prometheus/api/v1/label/service/values":
+			for metric := range namespaces {
+				if strings.Contains(match, metric) {
+					json.NewEncoder(w).Encode(map[string]interface{}{
+						"status": "success",
+						"data":   []string{metric + "_service"},
+					})
+					return
+				}
+			}
+		case "/prometheus/api/v1/label/namespace/values":
+			for metric, namespace := range namespaces {
+				if strings.Contains(match, metric) {
+					json.NewEncoder(w).Encode(map[string]interface{}{
+						"status": "success",
+						"data":   []string{namespace},
+					})
+					return
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   []string{},
+		})
+	}))
+	defer server.Close()
+
+	metrics := []string{"http_requests_total", "apiserver_requests", "coredns_dns_requests", "sandbox_requests", "sandbox_kube_requests"}
+
+	discover := func(t *testing.T, config DiscoveryConfig) []string {
+		config.Enabled = true
+		client := NewClientWithBackend(server.URL, AuthConfig{Type: "none"}, 5*time.Second, BackendTypeMimir)
+		ds := NewDiscoveryService(client, config, NewMockMapper())
+
+		services, err := ds.discoverServices(context.Background(), metrics)
+		require.NoError(t, err)
+
+		var found []string
+		for _, service := range services {
+			found = append(found, service.Namespace)
+		}
+		return found
+	}
+
+	t.Run("patterns match whole namespace names", func(t *testing.T) {
+		found := discover(t, DiscoveryConfig{ExcludeNamespaces: []string{"kube-.*"}})
+		assert.ElementsMatch(t, []string{"production", "sandbox", "my-kube-system"}, found)
+	})
+
+	t.Run("exact names exclude only that namespace", func(t *testing.T) {
+		found := discover(t, DiscoveryConfig{ExcludeNamespaces: []string{"sandbox", "kube-system"}})
+		assert.ElementsMatch(t, []string{"production", "kube-public", "my-kube-system"}, found)
+	})
+
+	t.Run("exclude wins over include", func(t *testing.T) {
+		found := discover(t, DiscoveryConfig{
+			Namespaces:        []string{"production", "kube-system"},
+			ExcludeNamespaces: []string{"kube-system"},
+		})
+		assert.ElementsMatch(t, []string{"production"}, found)
+	})
+
+	t.Run("invalid patterns are ignored", func(t *testing.T) {
+		found := discover(t, DiscoveryConfig{ExcludeNamespaces: []string{"kube-(", "sandbox"}})
+		assert.ElementsMatch(t, []string{"production", "kube-system", "kube-public", "my-kube-system"}, found)
+	})
+}
+
+func TestDiscoverServicesWithServiceExcludedMetrics(t *testing.T) {
+	// Services and namespace exposing each metric
+	exposedBy := map[string][]string{
+		"grpc_client_calls_total": {"payments", "checkout"},
+		"http_requests_total":     {"payments", "checkout"},
+		"debug_gc_runs_total":     {"payments"},
+	}
+	namespaces := map[string]string{
+		"grpc_client_calls_total": "production",
+		"http_requests_total":     "production",
+		"debug_gc_runs_total":     "staging",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := r.URL.Query().Get("match[]")
+		data := []string{}
+		for metric := range exposedBy {
+			if !strings.Contains(match, metric) {
+				continue
+			}
+			switch r.URL.Path {
+			case "/prometheus/api/v1/label/service/values":
+				data = exposedBy[metric]
+			case "/prometheus/api/v1/label/namespace/values":
+				data = []string{namespaces[metric]}
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   data,
+		})
+	}))
+	defer server.Close()
+
+	client := NewClientWithBackend(server.URL, AuthConfig{Type: "none"}, 5*time.Second, BackendTypeMimir)
+	ds := NewDiscoveryService(client, DiscoveryConfig{
+		Enabled: true,
+		ServiceExcludeMetrics: map[string][]string{
+			"payments":  {"^grpc_client_"},
+			"staging/*": {"^debug_"},
+			"[invalid":  {".*"},
+		},
+	}, NewMockMapper())
+
+	services, err := ds.discoverServices(context.Background(), []string{"grpc_client_calls_total", "http_requests_total", "debug_gc_runs_total"})
+	require.NoError(t, err)
+
+	metricsByService := make(map[string][]string)
+	for _, service := range services {
+		metricsByService[service.Namespace+"/"+service.Name] = service.Metrics
+	}
+
+	// The gRPC client metric is kept for checkout but excluded for payments
+	assert.ElementsMatch(t, []string{"grpc_client_calls_total", "http_requests_total"}, metricsByService["production/checkout"])
+	assert.ElementsMatch(t, []string{"http_requests_total"}, metricsByService["production/payments"])
+
+	// Namespace globs exclude the metric for every service in the namespace
+	assert.NotContains(t, metricsByService, "staging/payments")
+}
+
+// TestDiscoveryRetryBeforeNextInterval tests that a failed cycle is retried with
+// backoff instead of waiting for the next scheduled interval
+func TestDiscoveryRetryBeforeNextInterval(t *testing.T) {
+	var mu sync.Mutex
+	metricNameCalls := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/prometheus/api/v1/query":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data": map[string]interface{}{
+					"resultType": "vector",
+					"result":     []interface{}{},
+				},
+			})
+		case "/prometheus/api/v1/label/__name__/values":
+			mu.Lock()
+			metricNameCalls++
+			calls := metricNameCalls
+			mu.Unlock()
+
+			// Fail the first attempt, succeed afterwards
+			if calls == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte("Service Unavailable"))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   []string{"http_requests_total"},
+			})
+		case "/prometheus/api/v1/label/service/values":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   []string{"api"},
+			})
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   []string{},
+			})
+		}
+	}))
+	defer server.Close()
+
+	client := NewClientWithBackend(server.URL, AuthConfig{Type: "none"}, 5*time.Second, BackendTypeMimir)
+	mapper := NewMockMapper()
+
+	config := DiscoveryConfig{
+		Enabled:        true,
+		Interval:       1 * time.Hour, // Next scheduled run is far away
+		MaxRetries:     3,
+		RetryBaseDelay: 10 * time.Millisecond,
+		RetryMaxDelay:  50 * time.Millisecond,
+	}
+
+	ds := NewDiscoveryService(client, config, mapper)
+	require.NoError(t, ds.Start(context.Background()))
+	defer ds.Stop()
+
+	require.Eventually(t, func() bool {
+		mapper.mu.Lock()
+		defer mapper.mu.Unlock()
+		return mapper.createServiceCallCount > 0
+	}, 2*time.Second, 10*time.Millisecond, "discovery should retry before the next interval")
+
+	mu.Lock()
+	assert.Equal(t, 2, metricNameCalls)
+	mu.Unlock()
+
+	status := ds.Status()
+	assert.Equal(t, 0, status.ConsecutiveFailures)
+	assert.Empty(t, status.LastError)
+	assert.False(t, status.LastFailure.IsZero())
+	assert.True(t, ds.Healthy())
+}
+
+// TestDiscoveryConsecutiveFailures tests that sustained failures mark discovery unhealthy
+func TestDiscoveryConsecutiveFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("Service Unavailable"))
+	}))
+	defer server.Close()
+
+	client := NewClientWithBackend(server.URL, AuthConfig{Type: "none"}, 5*time.Second, BackendTypeMimir)
+	mapper := NewMockMapper()
+
+	config := DiscoveryConfig{
+		Enabled:          true,
+		MaxRetries:       2,
+		RetryBaseDelay:   time.Millisecond,
+		RetryMaxDelay:    5 * time.Millisecond,
+		FailureThreshold: 3,
+	}
+
+	ds := NewDiscoveryService(client, config, mapper)
+
+	err := ds.runDiscoveryWithRetry(context.Background())
+	require.Error(t, err)
+
+	status := ds.Status()
+	assert.Equal(t, 3, status.ConsecutiveFailures)
+	assert.Contains(t, status.LastError, "failed to fetch metric names")
+	assert.False(t, ds.Healthy())
+}
+
+// TestDiscoveryRetriesDisabled tests that zero retries runs a failed cycle
+// once, while a negative value uses the default
+func TestDiscoveryRetriesDisabled(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClientWithBackend(server.URL, AuthConfig{Type: "none"}, 5*time.Second, BackendTypeMimir)
+	ds := NewDiscoveryService(client, DiscoveryConfig{Enabled: true, MaxRetries: 0}, NewMockMapper())
+
+	require.Error(t, ds.runDiscoveryWithRetry(context.Background()))
+	mu.Lock()
+	assert.Equal(t, 1, attempts)
+	mu.Unlock()
+	assert.Equal(t, 1, ds.Status().ConsecutiveFailures)
+
+	ds = NewDiscoveryService(client, DiscoveryConfig{Enabled: true, MaxRetries: -1}, NewMockMapper())
+	assert.Equal(t, 3, ds.config.MaxRetries)
+}
+
+// notifierFunc adapts a function to notify.Notifier
+type notifierFunc func(event notify.Event)
+
+func (f notifierFunc) Notify(ctx context.Context, event notify.Event) error {
+	f(event)
+	return nil
+}
+
+// TestDiscoveryHealthNotifications tests that discovery notifies once when it
+// becomes unhealthy and again when it recovers
+func TestDiscoveryHealthNotifications(t *testing.T) {
+	notifications := make(chan notify.Event, 10)
+	ds := NewDiscoveryService(nil, DiscoveryConfig{FailureThreshold: 2}, NewMockMapper())
+	ds.SetNotifier(notifierFunc(func(event notify.Event) { notifications <- event }))
+
+	next := func() notify.Event {
+		select {
+		case event := <-notifications:
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for notification")
+			return notify.Event{}
+		}
+	}
+
+	ds.recordFailure(fmt.Errorf("mimir unavailable"))
+	ds.recordFailure(fmt.Errorf("mimir unavailable"))
+	ds.recordFailure(fmt.Errorf("mimir unavailable"))
+	unhealthy := next()
+	assert.Equal(t, notify.TypeDiscoveryUnhealthy, unhealthy.Type)
+	assert.Equal(t, "mimir unavailable", unhealthy.Data["last_error"])
+
+	ds.recordSuccess()
+	recovered := next()
+	assert.Equal(t, notify.TypeDiscoveryRecovered, recovered.Type)
+	assert.Equal(t, 3, recovered.Data["consecutive_failures"])
+
+	// A failure below the threshold is not worth a notification
+	ds.recordFailure(fmt.Errorf("mimir unavailable"))
+	ds.recordSuccess()
+	select {
+	case event := <-notifications:
+		t.Fatalf("unexpected notification %s", event.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestInitialDiscoveryReadiness tests that readiness flips only after the first successful discovery
+func TestInitialDiscoveryReadiness(t *testing.T) {
+	var mu sync.Mutex
+	available := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		up := available
+		mu.Unlock()
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/prometheus/api/v1/label/__name__/values":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   []string{"http_requests_total"},
+			})
+		case "/prometheus/api/v1/label/service/values":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   []string{"api"},
+			})
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"data":   []string{},
+			})
+		}
+	}))
+	defer server.Close()
+
+	client := NewClientWithBackend(server.URL, AuthConfig{Type: "none"}, 5*time.Second, BackendTypeMimir)
+	ds := NewDiscoveryService(client, DiscoveryConfig{
+		Enabled:          true,
+		MaxRetries:       1,
+		RetryBaseDelay:   time.Millisecond,
+		RetryMaxDelay:    time.Millisecond,
+		FailureThreshold: 10,
+	}, NewMockMapper())
+
+	checker := observability.NewHealthChecker()
+	checker.RegisterReadinessGate("initial_discovery", observability.InitialDiscoveryGate(func() time.Time {
+		return ds.Status().LastSuccess
+	}))
+	ctx := context.Background()
+
+	readiness := checker.GetReadinessResponse(ctx)
+	assert.False(t, readiness.Ready, "not ready before discovery has run")
+	assert.Contains(t, readiness.Pending, "initial_discovery")
+
+	require.Error(t, ds.runDiscoveryWithRetry(ctx))
+	assert.False(t, checker.GetReadinessResponse(ctx).Ready, "a failed discovery keeps the gate closed")
+
+	mu.Lock()
+	available = true
+	mu.Unlock()
+	require.NoError(t, ds.runDiscoveryWithRetry(ctx))
+
+	readiness = checker.GetReadinessResponse(ctx)
+	assert.True(t, readiness.Ready, "ready after the first successful discovery")
+	assert.Empty(t, readiness.Pending)
+}
+
+// TestDiscoveryBackoff tests exponential backoff capping
+func TestDiscoveryBackoff(t *testing.T) {
+	base := 100 * time.Millisecond
+	max := 1 * time.Second
+
+	assert.Equal(t, 100*time.Millisecond, discoveryBackoff(0, base, max))
+	assert.Equal(t, 200*time.Millisecond, discoveryBackoff(1, base, max))
+	assert.Equal(t, 400*time.Millisecond, discoveryBackoff(2, base, max))
+	assert.Equal(t, 1*time.Second, discoveryBackoff(5, base, max))
+	assert.Equal(t, 1*time.Second, discoveryBackoff(100, base, max))
+}
+
+// TestMultiSignalServiceIdentity compares multi-signal service identification
+// with the single-signal identification on metrics whose labels are ambiguous
+func TestMultiSignalServiceIdentity(t *testing.T) {
+	// Label values by label and metric
+	labelValues := map[string]map[string][]string{
+		"service": {
+			"checkout_queue_depth":   {"unknown"},
+			"http_requests_total":    {"api", "worker"},
+			"orders_processed_total": {"legacy-orders"},
+		},
+		"job": {
+			"checkout_queue_depth":   {"checkout"},
+			"http_requests_total":    {"api", "worker"},
+			"orders_processed_total": {"orders"},
+		},
+		"app": {
+			"orders_processed_total": {"orders"},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		labelName := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/prometheus/api/v1/label/"), "/values")
+		data := labelValues[labelName][r.URL.Query().Get("match[]")]
+		if data == nil {
+			data = []string{}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   data,
+		})
+	}))
+	defer server.Close()
+
+	client := NewClientWithBackend(server.URL, AuthConfig{Type: "none"}, 5*time.Second, BackendTypeMimir)
+	singleSignal := NewDiscoveryService(client, DiscoveryConfig{Enabled: true}, NewMockMapper())
+	multiSignal := NewDiscoveryService(client, DiscoveryConfig{
+		Enabled: true,
+		ServiceSignalWeights: map[string]float64{
+			"service":               1.0,
+			"app":                   0.8,
+			"job":                   0.6,
+			ServiceSignalMetricName: 0.3,
+		},
+	}, NewMockMapper())
+
+	namesFor := func(ds *DiscoveryService, metricName string) []string {
+		var names []string
+		for _, info := range ds.servicesForMetric(context.Background(), metricName) {
+			names = append(names, info.Name)
+		}
+		return names
+	}
+
+	tests := []struct {
+		metricName string
+		single     []string
+		multi      []string
+	}{
+		// The service label says "unknown", so the metric is dropped unless
+		// the job label is consulted
+		{"checkout_queue_depth", []string{"unknown"}, []string{"checkout"}},
+		// Agreeing labels keep every service exposing the metric
+		{"http_requests_total", []string{"api", "worker"}, []string{"api", "worker"}},
+		// The job and app labels together outweigh the service label
+		{"orders_processed_total", []string{"legacy-orders"}, []string{"orders"}},
+		// Only the metric name identifies the service
+		{"payments_latency_seconds", []string{"payments"}, []string{"payments"}},
+		// No signal identifies a service
+		{"process_cpu_seconds_total", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.metricName, func(t *testing.T) {
+			assert.Equal(t, tt.single, namesFor(singleSignal, tt.metricName), "single-signal result")
+			assert.Equal(t, tt.multi, namesFor(multiSignal, tt.metricName), "multi-signal result")
+		})
+	}
+
+	t.Run("fewer unknown assignments", func(t *testing.T) {
+		metricNames := []string{"checkout_queue_depth", "http_requests_total", "orders_processed_total"}
+
+		single, err := singleSignal.discoverServices(context.Background(), metricNames)
+		require.NoError(t, err)
+		multi, err := multiSignal.discoverServices(context.Background(), metricNames)
+		require.NoError(t, err)
+
+		serviceNames := func(services []DiscoveredService) []string {
+			names := make([]string, 0, len(services))
+			for _, service := range services {
+				names = append(names, service.Name)
+			}
+			return names
+		}
+		assert.ElementsMatch(t, []string{"api", "worker", "legacy-orders"}, serviceNames(single))
+		assert.ElementsMatch(t, []string{"api", "worker", "orders", "checkout"}, serviceNames(multi))
+	})
+
+	t.Run("low-confidence identities are logged", func(t *testing.T) {
+		var logs bytes.Buffer
+		log.SetOutput(&logs)
+		defer log.SetOutput(os.Stderr)
+
+		namesFor(multiSignal, "http_requests_total")
+		assert.NotContains(t, logs.String(), "low-confidence", "agreeing labels are confident")
+
+		// The metric name alone is a weak signal
+		namesFor(multiSignal, "payments_latency_seconds")
+		assert.Contains(t, logs.String(), "low-confidence service identity for metric payments_latency_seconds: payments (confidence 0.30")
+	})
+}
+
+// TestServiceNameNormalization tests that variants of a service name collapse
+// into one service when normalization is enabled
+func TestServiceNameNormalization(t *testing.T) {
+	// Service label values by metric
+	labelValues := map[string][]string{
+		"http_requests_total":       {"user-service", "UserService"},
+		"http_errors_total":         {"user_service"},
+		"process_cpu_seconds_total": {"HTTPGateway"},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data []string
+		if strings.HasSuffix(r.URL.Path, "/label/service/values") {
+			data = labelValues[r.URL.Query().Get("match[]")]
+		}
+		if data == nil {
+			data = []string{}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   data,
+		})
+	}))
+	defer server.Close()
+
+	client := NewClientWithBackend(server.URL, AuthConfig{Type: "none"}, 5*time.Second, BackendTypeMimir)
+	metricNames := []string{"http_requests_total", "http_errors_total", "process_cpu_seconds_total"}
+
+	t.Run("variants collapse into one service", func(t *testing.T) {
+		ds := NewDiscoveryService(client, DiscoveryConfig{Enabled: true, NormalizeServiceNames: true}, NewMockMapper())
+
+		services, err := ds.discoverServices(context.Background(), metricNames)
+		require.NoError(t, err)
+		require.Len(t, services, 2)
+
+		byName := make(map[string]DiscoveredService)
+		for _, service := range services {
+			byName[service.Name] = service
+		}
+		require.Contains(t, byName, "user-service")
+		user := byName["user-service"]
+		assert.Equal(t, []string{"http_requests_total", "http_errors_total"}, user.Metrics)
+		assert.Equal(t, "UserService,user-service,user_service", user.Labels[OriginalNamesLabel])
+
+		require.Contains(t, byName, "http-gateway")
+		assert.Equal(t, "HTTPGateway", byName["http-gateway"].Labels[OriginalNamesLabel])
+	})
+
+	t.Run("variants are kept apart by default", func(t *testing.T) {
+		ds := NewDiscoveryService(client, DiscoveryConfig{Enabled: true}, NewMockMapper())
+
+		services, err := ds.discoverServices(context.Background(), metricNames)
+		require.NoError(t, err)
+		assert.Len(t, services, 4)
+		for _, service := range services {
+			assert.NotContains(t, service.Labels, OriginalNamesLabel)
+		}
+	})
+
+	t.Run("separator", func(t *testing.T) {
+		for name, expected := range map[string]string{
+			"user-service":  "user_service",
+			"UserService":   "user_service",
+			"user.service":  "user_service",
+			"userService2":  "user_service2",
+			"API":           "api",
+			"--":            "--",
+			"checkout":      "checkout",
+			"HTTPServer v2": "http_server_v2",
+		} {
+			assert.Equal(t, expected, normalizeServiceName(name, "_"), name)
+		}
+	})
+}
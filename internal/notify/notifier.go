@@ -0,0 +1,120 @@
+// internal/notify/notifier.go
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Type identifies the kind of a notification
+type Type string
+
+// Notification types sent by the subsystems
+const (
+	TypeAPIKeyCreated      Type = "api_key_created"
+	TypeAPIKeyRevoked      Type = "api_key_revoked"
+	TypeDiscoveryUnhealthy Type = "discovery_unhealthy"
+	TypeDiscoveryRecovered Type = "discovery_recovered"
+)
+
+// Severity is how urgently a notification needs attention
+type Severity string
+
+// Notification severities
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+)
+
+// defaultWebhookTimeout bounds each webhook delivery
+const defaultWebhookTimeout = 5 * time.Second
+
+// Event is an occurrence worth telling an operator about
+type Event struct {
+	Type      Type                   `json:"type"`
+	Severity  Severity               `json:"severity"`
+	Message   string                 `json:"message"`
+	Timestamp time.Time              `json:"timestamp"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+// Notifier delivers notifications to operators
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// Nop discards every notification. It is the notifier of subsystems that are
+// not given one.
+type Nop struct{}
+
+// Notify discards the event
+func (Nop) Notify(ctx context.Context, event Event) error {
+	return nil
+}
+
+// WebhookNotifier posts each notification as JSON to a URL
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting to url, giving up on a
+// delivery after timeout; a non-positive timeout uses the default
+func NewWebhookNotifier(url string, timeout time.Duration) *WebhookNotifier {
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Notify posts the event to the webhook. Responses other than 2xx are errors.
+func (w *WebhookNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Send delivers an event in the background, so callers are not held up by a
+// slow receiver. Events without a timestamp are stamped with the current
+// time, and delivery failures are logged.
+func Send(notifier Notifier, event Event) {
+	if notifier == nil {
+		return
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	go func() {
+		if err := notifier.Notify(context.Background(), event); err != nil {
+			log.Printf("Warning: Failed to send %s notification: %v", event.Type, err)
+		}
+	}()
+}
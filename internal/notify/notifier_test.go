@@ -0,0 +1,86 @@
+// internal/notify/notifier_test.go
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	received := make(chan map[string]interface{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var payload map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received <- payload
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL, time.Second)
+	err := notifier.Notify(context.Background(), Event{
+		Type:      TypeDiscoveryUnhealthy,
+		Severity:  SeverityWarning,
+		Message:   "Service discovery is unhealthy after 3 consecutive failures",
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Data:      map[string]interface{}{"consecutive_failures": 3},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"type":      "discovery_unhealthy",
+		"severity":  "warning",
+		"message":   "Service discovery is unhealthy after 3 consecutive failures",
+		"timestamp": "2024-05-01T12:00:00Z",
+		"data":      map[string]interface{}{"consecutive_failures": float64(3)},
+	}, <-received)
+}
+
+func TestWebhookNotifierErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := NewWebhookNotifier(server.URL, time.Second).Notify(context.Background(), Event{Type: TypeAPIKeyCreated})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 502")
+}
+
+// recordingNotifier sends every event to a channel
+type recordingNotifier struct {
+	events chan Event
+	err    error
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, event Event) error {
+	r.events <- event
+	return r.err
+}
+
+func TestSend(t *testing.T) {
+	notifier := &recordingNotifier{events: make(chan Event, 1), err: errors.New("receiver down")}
+
+	Send(notifier, Event{Type: TypeAPIKeyRevoked})
+	select {
+	case event := <-notifier.events:
+		assert.Equal(t, TypeAPIKeyRevoked, event.Type)
+		assert.False(t, event.Timestamp.IsZero(), "events are stamped")
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for notification")
+	}
+
+	// Missing notifiers are ignored
+	Send(nil, Event{Type: TypeAPIKeyRevoked})
+	assert.NoError(t, Nop{}.Notify(context.Background(), Event{}))
+}
@@ -0,0 +1,123 @@
+package observability
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PrometheusContentType is the content type of the Prometheus text exposition format
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WritePrometheus writes all metrics in the Prometheus text exposition format.
+// Histograms recorded with Observe only track a count and sum, so they are
+// exposed as summaries without quantiles; those recorded with ObserveHistogram
+// are exposed with their buckets.
+func (mc *MetricsCollector) WritePrometheus(w io.Writer) error {
+	mc.mu.RLock()
+	byName := make(map[string][]Metric)
+	for _, metric := range mc.metrics {
+		m := *metric
+		if metric.Extra != nil {
+			m.Extra = make(map[string]interface{}, len(metric.Extra))
+			for k, v := range metric.Extra {
+				m.Extra[k] = v
+			}
+		}
+		byName[m.Name] = append(byName[m.Name], m)
+	}
+	mc.mu.RUnlock()
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		series := byName[name]
+		sort.Slice(series, func(i, j int) bool {
+			return formatLabels(series[i].Labels) < formatLabels(series[j].Labels)
+		})
+
+		switch _, bucketed := series[0].Extra["buckets"]; {
+		case series[0].Type == MetricTypeHistogram && bucketed:
+			fmt.Fprintf(&sb, "# TYPE %s histogram\n", name)
+			for _, m := range series {
+				buckets, _ := m.Extra["buckets"].([]float64)
+				counts, _ := m.Extra["bucket_counts"].([]float64)
+				sum, _ := m.Extra["sum"].(float64)
+				count, _ := m.Extra["count"].(float64)
+				for i, bound := range buckets {
+					if i < len(counts) {
+						fmt.Fprintf(&sb, "%s_bucket%s %s\n", name, formatLabels(withLabel(m.Labels, "le", formatValue(bound))), formatValue(counts[i]))
+					}
+				}
+				fmt.Fprintf(&sb, "%s_bucket%s %s\n", name, formatLabels(withLabel(m.Labels, "le", "+Inf")), formatValue(count))
+				labels := formatLabels(m.Labels)
+				fmt.Fprintf(&sb, "%s_sum%s %s\n", name, labels, formatValue(sum))
+				fmt.Fprintf(&sb, "%s_count%s %s\n", name, labels, formatValue(count))
+			}
+		case series[0].Type == MetricTypeHistogram:
+			fmt.Fprintf(&sb, "# TYPE %s summary\n", name)
+			for _, m := range series {
+				labels := formatLabels(m.Labels)
+				sum, _ := m.Extra["sum"].(float64)
+				count, _ := m.Extra["count"].(float64)
+				fmt.Fprintf(&sb, "%s_sum%s %s\n", name, labels, formatValue(sum))
+				fmt.Fprintf(&sb, "%s_count%s %s\n", name, labels, formatValue(count))
+			}
+		default:
+			fmt.Fprintf(&sb, "# TYPE %s %s\n", name, series[0].Type)
+			for _, m := range series {
+				fmt.Fprintf(&sb, "%s%s %s\n", name, formatLabels(m.Labels), formatValue(m.Value))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// formatLabels renders a label set as {a="1",b="2"} with sorted names
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, escapeLabelValue(labels[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// withLabel returns a copy of labels with name set to value
+func withLabel(labels map[string]string, name, value string) map[string]string {
+	result := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		result[k] = v
+	}
+	result[name] = value
+	return result
+}
+
+// escapeLabelValue escapes backslashes, quotes and newlines in label values
+func escapeLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
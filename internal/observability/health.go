@@ -30,10 +30,16 @@ type HealthCheck struct {
 type HealthChecker struct {
 	checks map[string]HealthCheckFunc
 	cache  map[string]*HealthCheck
+	gates  map[string]ReadinessGate
 	mu     sync.RWMutex
 	ttl    time.Duration
 }
 
+// ReadinessGate reports whether a component is ready for traffic, with the
+// reason when it is not. Gates hold traffic back without marking the service
+// unhealthy, e.g. while startup work is still running.
+type ReadinessGate func() (ready bool, reason string)
+
 // HealthCheckFunc is a function that performs a health check
 type HealthCheckFunc func(context.Context) *HealthCheck
 
@@ -42,6 +48,7 @@ func NewHealthChecker() *HealthChecker {
 	return &HealthChecker{
 		checks: make(map[string]HealthCheckFunc),
 		cache:  make(map[string]*HealthCheck),
+		gates:  make(map[string]ReadinessGate),
 		ttl:    5 * time.Second, // Cache health checks for 5 seconds
 	}
 }
@@ -130,6 +137,44 @@ func (hc *HealthChecker) GetHealthResponse(ctx context.Context) *HealthResponse
 	}
 }
 
+// RegisterReadinessGate registers a condition that must hold before the
+// service reports ready
+func (hc *HealthChecker) RegisterReadinessGate(name string, gate ReadinessGate) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.gates[name] = gate
+}
+
+// ReadinessResponse reports whether the service should receive traffic
+type ReadinessResponse struct {
+	Ready     bool              `json:"ready"`
+	Status    HealthStatus      `json:"status"`
+	Timestamp time.Time         `json:"timestamp"`
+	Pending   map[string]string `json:"pending,omitempty"` // Reasons of closed gates by name
+}
+
+// GetReadinessResponse reports the service ready once it is not unhealthy
+// and every readiness gate is open
+func (hc *HealthChecker) GetReadinessResponse(ctx context.Context) *ReadinessResponse {
+	status := hc.GetOverallStatus(ctx)
+
+	hc.mu.RLock()
+	pending := make(map[string]string)
+	for name, gate := range hc.gates {
+		if ready, reason := gate(); !ready {
+			pending[name] = reason
+		}
+	}
+	hc.mu.RUnlock()
+
+	return &ReadinessResponse{
+		Ready:     status != HealthStatusUnhealthy && len(pending) == 0,
+		Status:    status,
+		Timestamp: time.Now(),
+		Pending:   pending,
+	}
+}
+
 // Common health check functions
 
 // DatabaseHealthCheck creates a health check for database connectivity
@@ -320,3 +365,130 @@ func DiskHealthCheck(getDiskUsage func() (used, total uint64)) HealthCheckFunc {
 		}
 	}
 }
+
+// DiscoveryHealthCheck creates a health check for the service discovery loop.
+// Discovery is degraded after any failed attempt and unhealthy once consecutive
+// failures reach the configured threshold.
+func DiscoveryHealthCheck(statusFunc func() (consecutiveFailures, threshold int, lastError string)) HealthCheckFunc {
+	return func(ctx context.Context) *HealthCheck {
+		failures, threshold, lastError := statusFunc()
+
+		metadata := map[string]interface{}{
+			"consecutive_failures": failures,
+			"failure_threshold":    threshold,
+		}
+
+		switch {
+		case threshold > 0 && failures >= threshold:
+			return &HealthCheck{
+				Name:     "discovery",
+				Status:   HealthStatusUnhealthy,
+				Message:  fmt.Sprintf("Discovery failed %d consecutive times: %s", failures, lastError),
+				Metadata: metadata,
+			}
+		case failures > 0:
+			return &HealthCheck{
+				Name:     "discovery",
+				Status:   HealthStatusDegraded,
+				Message:  fmt.Sprintf("Discovery recently failed: %s", lastError),
+				Metadata: metadata,
+			}
+		default:
+			return &HealthCheck{
+				Name:     "discovery",
+				Status:   HealthStatusHealthy,
+				Message:  "Discovery running normally",
+				Metadata: metadata,
+			}
+		}
+	}
+}
+
+// EmbeddingSchema describes how the stored query embeddings fit the
+// configured embedding model
+type EmbeddingSchema struct {
+	ModelDimension  int // Dimension of the model's embeddings; zero if unknown
+	ColumnDimension int // Fixed dimension of the embedding column; -1 if it accepts any
+	StaleEmbeddings int // Stored embeddings of another dimension, skipped by similarity searches
+}
+
+// EmbeddingSchemaHealthCheck creates a health check verifying that the
+// database can store and search embeddings of the configured model. A
+// fixed-dimension column of another dimension is unhealthy, since storing
+// embeddings fails; stored embeddings of another dimension are degraded, since
+// similarity searches silently skip them.
+func EmbeddingSchemaHealthCheck(schemaFunc func(context.Context) (EmbeddingSchema, error)) HealthCheckFunc {
+	return func(ctx context.Context) *HealthCheck {
+		start := time.Now()
+
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+
+		schema, err := schemaFunc(ctx)
+		duration := time.Since(start)
+
+		if err != nil {
+			return &HealthCheck{
+				Name:     "embedding_schema",
+				Status:   HealthStatusUnhealthy,
+				Message:  fmt.Sprintf("Failed to read the embedding schema: %v", err),
+				Duration: duration,
+			}
+		}
+
+		metadata := map[string]interface{}{
+			"model_dimension":  schema.ModelDimension,
+			"column_dimension": schema.ColumnDimension,
+			"stale_embeddings": schema.StaleEmbeddings,
+		}
+
+		switch {
+		case schema.ModelDimension <= 0:
+			return &HealthCheck{
+				Name:     "embedding_schema",
+				Status:   HealthStatusDegraded,
+				Message:  "Embedding dimension unknown; the embedding model could not be probed at startup",
+				Duration: duration,
+				Metadata: metadata,
+			}
+		case schema.ColumnDimension > 0 && schema.ColumnDimension != schema.ModelDimension:
+			return &HealthCheck{
+				Name:   "embedding_schema",
+				Status: HealthStatusUnhealthy,
+				Message: fmt.Sprintf("query_embeddings.embedding is vector(%d) but the embedding model produces %d dimensions; "+
+					"apply migration 003_flexible_embedding_dimension (go run ./cmd/migrate)", schema.ColumnDimension, schema.ModelDimension),
+				Duration: duration,
+				Metadata: metadata,
+			}
+		case schema.StaleEmbeddings > 0:
+			return &HealthCheck{
+				Name:   "embedding_schema",
+				Status: HealthStatusDegraded,
+				Message: fmt.Sprintf("%d stored query embeddings do not have %d dimensions and are skipped by similarity searches; "+
+					"re-embed them (POST /api/v1/admin/reembed)", schema.StaleEmbeddings, schema.ModelDimension),
+				Duration: duration,
+				Metadata: metadata,
+			}
+		default:
+			return &HealthCheck{
+				Name:     "embedding_schema",
+				Status:   HealthStatusHealthy,
+				Message:  fmt.Sprintf("Stored embeddings match the %d-dimension embedding model", schema.ModelDimension),
+				Duration: duration,
+				Metadata: metadata,
+			}
+		}
+	}
+}
+
+// InitialDiscoveryGate creates a readiness gate that stays closed until the
+// first discovery cycle succeeds, so traffic is not routed to an instance
+// whose service catalog has not been populated
+func InitialDiscoveryGate(lastSuccess func() time.Time) ReadinessGate {
+	return func() (bool, string) {
+		if lastSuccess().IsZero() {
+			return false, "waiting for the first successful discovery cycle"
+		}
+		return true, ""
+	}
+}
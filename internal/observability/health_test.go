@@ -0,0 +1,79 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEmbeddingSchemaHealthCheck tests that the check fails when the database
+// cannot store or search embeddings of the configured model
+func TestEmbeddingSchemaHealthCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  EmbeddingSchema
+		err     error
+		status  HealthStatus
+		message string
+	}{
+		{
+			name:    "flexible column with matching embeddings",
+			schema:  EmbeddingSchema{ModelDimension: 1536, ColumnDimension: -1},
+			status:  HealthStatusHealthy,
+			message: "1536-dimension",
+		},
+		{
+			name:    "fixed column of the model dimension",
+			schema:  EmbeddingSchema{ModelDimension: 1536, ColumnDimension: 1536},
+			status:  HealthStatusHealthy,
+			message: "1536-dimension",
+		},
+		{
+			name:    "fixed column of another dimension",
+			schema:  EmbeddingSchema{ModelDimension: 1024, ColumnDimension: 1536},
+			status:  HealthStatusUnhealthy,
+			message: "query_embeddings.embedding is vector(1536) but the embedding model produces 1024 dimensions",
+		},
+		{
+			name:    "stored embeddings of another dimension",
+			schema:  EmbeddingSchema{ModelDimension: 1024, ColumnDimension: -1, StaleEmbeddings: 42},
+			status:  HealthStatusDegraded,
+			message: "42 stored query embeddings do not have 1024 dimensions",
+		},
+		{
+			name:    "unknown model dimension",
+			schema:  EmbeddingSchema{ColumnDimension: -1},
+			status:  HealthStatusDegraded,
+			message: "Embedding dimension unknown",
+		},
+		{
+			name:    "schema unreadable",
+			err:     fmt.Errorf("connection refused"),
+			status:  HealthStatusUnhealthy,
+			message: "connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := EmbeddingSchemaHealthCheck(func(ctx context.Context) (EmbeddingSchema, error) {
+				return tt.schema, tt.err
+			})
+
+			result := check(context.Background())
+			assert.Equal(t, "embedding_schema", result.Name)
+			assert.Equal(t, tt.status, result.Status)
+			assert.Contains(t, result.Message, tt.message)
+		})
+	}
+
+	t.Run("mismatch fails overall health", func(t *testing.T) {
+		checker := NewHealthChecker()
+		checker.Register("embedding_schema", EmbeddingSchemaHealthCheck(func(ctx context.Context) (EmbeddingSchema, error) {
+			return EmbeddingSchema{ModelDimension: 768, ColumnDimension: 1536}, nil
+		}))
+		assert.Equal(t, HealthStatusUnhealthy, checker.GetOverallStatus(context.Background()))
+	})
+}
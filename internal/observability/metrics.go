@@ -1,6 +1,8 @@
 package observability
 
 import (
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -37,13 +39,18 @@ func NewMetricsCollector() *MetricsCollector {
 	}
 }
 
-// metricKey generates a unique key for a metric
+// metricKey generates a unique key for a metric. Labels are sorted so the key
+// does not depend on map iteration order.
 func metricKey(name string, labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
 	key := name
-	if len(labels) > 0 {
-		for k, v := range labels {
-			key += "." + k + "=" + v
-		}
+	for _, k := range names {
+		key += "." + k + "=" + labels[k]
 	}
 	return key
 }
@@ -142,6 +149,51 @@ func (mc *MetricsCollector) Observe(name string, value float64, labels map[strin
 	}
 }
 
+// ObserveHistogram records an observation in a histogram with the given
+// cumulative bucket upper bounds, which must be sorted and the same for every
+// observation of the metric. Unlike Observe, the buckets are exposed to
+// Prometheus so quantiles can be computed.
+func (mc *MetricsCollector) ObserveHistogram(name string, value float64, buckets []float64, labels map[string]string) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	key := metricKey(name, labels)
+	metric, exists := mc.metrics[key]
+	if !exists {
+		metric = &Metric{
+			Name:   name,
+			Type:   MetricTypeHistogram,
+			Labels: labels,
+			Extra: map[string]interface{}{
+				"count":         0.0,
+				"sum":           0.0,
+				"buckets":       buckets,
+				"bucket_counts": make([]float64, len(buckets)),
+			},
+		}
+		mc.metrics[key] = metric
+	}
+
+	// Bucket counts are replaced rather than updated in place, so copies
+	// taken for exposition are not modified after the lock is released
+	previous, _ := metric.Extra["bucket_counts"].([]float64)
+	counts := make([]float64, len(previous))
+	copy(counts, previous)
+	for i, bound := range buckets {
+		if value <= bound && i < len(counts) {
+			counts[i]++
+		}
+	}
+
+	count, _ := metric.Extra["count"].(float64)
+	sum, _ := metric.Extra["sum"].(float64)
+	metric.Extra["count"] = count + 1
+	metric.Extra["sum"] = sum + value
+	metric.Extra["bucket_counts"] = counts
+	metric.Value = (sum + value) / (count + 1) // average
+	metric.Timestamp = time.Now()
+}
+
 // Get retrieves a metric by name and labels
 func (mc *MetricsCollector) Get(name string, labels map[string]string) (*Metric, bool) {
 	mc.mu.RLock()
@@ -182,7 +234,27 @@ const (
 	MetricQueryFailure         = "query_processor_queries_failure_total"
 	MetricQueryCacheHits       = "query_processor_cache_hits_total"
 	MetricQueryCacheMisses     = "query_processor_cache_misses_total"
+	MetricQueryCacheTimeouts   = "query_processor_cache_timeouts_total"
+	MetricQueryCacheHitRatio   = "query_processor_cache_hit_ratio"
 	MetricQuerySafetyViolation = "query_processor_safety_violations_total"
+	MetricQuerySlow            = "query_processor_slow_queries_total"
+	MetricQueryOverloaded      = "query_processor_overloaded_total"
+
+	// MetricQueryFingerprintThrottled counts queries rejected because the
+	// same query was sent too often, by any client
+	MetricQueryFingerprintThrottled = "query_processor_fingerprint_throttled_total"
+
+	// MetricSimilaritySearchConfigErrors counts similar-query searches that
+	// failed because of misconfiguration, labeled by reason
+	MetricSimilaritySearchConfigErrors = "query_processor_similarity_search_config_errors_total"
+
+	// MetricEvaluationSamples counts generated queries sampled for offline
+	// evaluation, labeled by outcome: stored, dropped or failed
+	MetricEvaluationSamples = "query_processor_evaluation_samples_total"
+
+	// MetricEmbeddingCacheLookups counts query embedding lookups in the durable
+	// embedding cache, labeled by outcome: hit, miss or error
+	MetricEmbeddingCacheLookups = "query_processor_embedding_cache_lookups_total"
 
 	// LLM metrics
 	MetricLLMRequests      = "llm_requests_total"
@@ -192,6 +264,11 @@ const (
 	MetricLLMErrors        = "llm_errors_total"
 	MetricEmbeddingRequest = "llm_embedding_requests_total"
 
+	// MetricEmbeddingDimensionMismatch counts similarity searches rejected
+	// because the query embedding has a different dimension than the stored
+	// embeddings, typically after the embedding model changed
+	MetricEmbeddingDimensionMismatch = "embedding_dimension_mismatches_total"
+
 	// Database metrics
 	MetricDBQueries        = "database_queries_total"
 	MetricDBDuration       = "database_query_duration_seconds"
@@ -213,14 +290,25 @@ const (
 	MetricHTTPErrors       = "http_errors_total"
 	MetricHTTPResponseSize = "http_response_size_bytes"
 
+	// MetricHTTPRouteRequests and MetricHTTPRouteDuration are labeled by the
+	// route template (e.g. /api/v1/services/:id) rather than the request path,
+	// so path parameters do not create a series per value
+	MetricHTTPRouteRequests = "http_route_requests_total"
+	MetricHTTPRouteDuration = "http_route_request_duration_seconds"
+
 	// Discovery metrics
-	MetricDiscoveryRuns       = "discovery_runs_total"
-	MetricDiscoveryDuration   = "discovery_duration_seconds"
-	MetricDiscoveryServices   = "discovery_services_found"
-	MetricDiscoveryMetrics    = "discovery_metrics_found"
-	MetricDiscoveryErrors     = "discovery_errors_total"
+	MetricDiscoveryRuns         = "discovery_runs_total"
+	MetricDiscoveryDuration     = "discovery_duration_seconds"
+	MetricDiscoveryServices     = "discovery_services_found"
+	MetricDiscoveryMetrics      = "discovery_metrics_found"
+	MetricDiscoveryErrors       = "discovery_errors_total"
+	MetricDiscoveryLabelCapHits = "discovery_label_value_cap_hits_total"
 )
 
+// HTTPLatencyBuckets are the histogram buckets, in seconds, of per-route
+// request latency
+var HTTPLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
 // Global metrics collector instance
 var globalMetrics = NewMetricsCollector()
 
@@ -254,11 +342,38 @@ func RecordQueryMetrics(duration time.Duration, success bool, cached bool, error
 	} else {
 		metrics.Inc(MetricQueryCacheMisses, nil)
 	}
+	metrics.updateCacheHitRatio()
 
 	// Duration
 	metrics.Observe(MetricQueryDuration, duration.Seconds(), nil)
 }
 
+// updateCacheHitRatio derives the cache hit ratio gauge from the cumulative
+// cache hit and miss counters, so it resets along with them
+func (mc *MetricsCollector) updateCacheHitRatio() {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	var hits, misses float64
+	if metric, exists := mc.metrics[metricKey(MetricQueryCacheHits, nil)]; exists {
+		hits = metric.Value
+	}
+	if metric, exists := mc.metrics[metricKey(MetricQueryCacheMisses, nil)]; exists {
+		misses = metric.Value
+	}
+	if hits+misses == 0 {
+		return
+	}
+
+	key := metricKey(MetricQueryCacheHitRatio, nil)
+	mc.metrics[key] = &Metric{
+		Name:      MetricQueryCacheHitRatio,
+		Type:      MetricTypeGauge,
+		Value:     hits / (hits + misses),
+		Timestamp: time.Now(),
+	}
+}
+
 // RecordLLMMetrics records metrics for LLM operations
 func RecordLLMMetrics(operation string, duration time.Duration, tokens int, cost float64, err error) {
 	metrics := GetGlobalMetrics()
@@ -344,3 +459,19 @@ func RecordHTTPMetrics(method, path string, statusCode int, duration time.Durati
 		metrics.Observe(MetricHTTPResponseSize, float64(responseSize), labels)
 	}
 }
+
+// RecordRouteMetrics records the request count and latency of a route. The
+// route is the registered route template, not the request path.
+func RecordRouteMetrics(method, route string, statusCode int, duration time.Duration) {
+	metrics := GetGlobalMetrics()
+
+	metrics.Inc(MetricHTTPRouteRequests, map[string]string{
+		"method": method,
+		"route":  route,
+		"status": strconv.Itoa(statusCode),
+	})
+	metrics.ObserveHistogram(MetricHTTPRouteDuration, duration.Seconds(), HTTPLatencyBuckets, map[string]string{
+		"method": method,
+		"route":  route,
+	})
+}
@@ -2,6 +2,9 @@ package observability
 
 import (
 	"bytes"
+	"net/url"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -36,11 +39,43 @@ func (w *responseWriter) WriteString(s string) (int, error) {
 	return size, err
 }
 
+// redactedValue replaces the values of redacted query string parameters in logs
+const redactedValue = "REDACTED"
+
+// RequestLogConfig controls which requests RequestLoggingMiddlewareWithConfig
+// logs and what it logs about them
+type RequestLogConfig struct {
+	// SampleRate logs 1 in SampleRate successful requests. Failed requests
+	// (status 400 or above, or with errors) are always logged. Values below 2
+	// log every request.
+	SampleRate int
+
+	// RedactParams are query string parameters whose values are replaced in
+	// the logged query, matched case-insensitively
+	RedactParams []string
+}
+
 // RequestLoggingMiddleware logs all HTTP requests with correlation IDs
 func RequestLoggingMiddleware(logger *Logger) gin.HandlerFunc {
+	return RequestLoggingMiddlewareWithConfig(logger, RequestLogConfig{})
+}
+
+// RequestLoggingMiddlewareWithConfig logs HTTP requests with correlation IDs,
+// sampling successful requests and redacting query parameters as configured.
+// Metrics are recorded for every request regardless of sampling.
+func RequestLoggingMiddlewareWithConfig(logger *Logger, config RequestLogConfig) gin.HandlerFunc {
+	redact := make(map[string]bool, len(config.RedactParams))
+	for _, param := range config.RedactParams {
+		redact[strings.ToLower(param)] = true
+	}
+	var requests uint64
+
 	return func(c *gin.Context) {
 		start := time.Now()
 
+		// Sampled out requests are still logged on completion if they fail
+		sampled := config.SampleRate < 2 || (atomic.AddUint64(&requests, 1)-1)%uint64(config.SampleRate) == 0
+
 		// Get or generate correlation ID
 		correlationID := c.GetHeader(RequestIDHeader)
 		if correlationID == "" {
@@ -69,13 +104,15 @@ func RequestLoggingMiddleware(logger *Logger) gin.HandlerFunc {
 		c.Writer = rw
 
 		// Log request start
-		logger.Info(ctx, "HTTP request started", map[string]interface{}{
-			"method":     c.Request.Method,
-			"path":       c.Request.URL.Path,
-			"query":      c.Request.URL.RawQuery,
-			"user_agent": c.Request.UserAgent(),
-			"ip":         c.ClientIP(),
-		})
+		if sampled {
+			logger.Info(ctx, "HTTP request started", map[string]interface{}{
+				"method":     c.Request.Method,
+				"path":       c.Request.URL.Path,
+				"query":      redactQuery(c.Request.URL.RawQuery, redact),
+				"user_agent": c.Request.UserAgent(),
+				"ip":         c.ClientIP(),
+			})
+		}
 
 		// Process request
 		c.Next()
@@ -93,13 +130,16 @@ func RequestLoggingMiddleware(logger *Logger) gin.HandlerFunc {
 			"ip":            c.ClientIP(),
 		}
 
-		// Log errors separately
+		// Log errors separately. Their start may have been sampled out, so
+		// they carry the query themselves.
 		if len(c.Errors) > 0 {
+			fields["query"] = redactQuery(c.Request.URL.RawQuery, redact)
 			fields["errors"] = c.Errors.String()
 			logger.Error(ctx, "HTTP request failed", c.Errors.Last().Err, fields)
 		} else if c.Writer.Status() >= 400 {
+			fields["query"] = redactQuery(c.Request.URL.RawQuery, redact)
 			logger.Warn(ctx, "HTTP request completed with error status", fields)
-		} else {
+		} else if sampled {
 			logger.Info(ctx, "HTTP request completed", fields)
 		}
 
@@ -114,6 +154,34 @@ func RequestLoggingMiddleware(logger *Logger) gin.HandlerFunc {
 	}
 }
 
+// redactQuery returns the raw query string with the values of the redact
+// parameters replaced
+func redactQuery(rawQuery string, redact map[string]bool) string {
+	if rawQuery == "" || len(redact) == 0 {
+		return rawQuery
+	}
+
+	// An unparseable query string may still hide a redacted value
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return redactedValue
+	}
+	changed := false
+	for key, params := range values {
+		if !redact[strings.ToLower(key)] {
+			continue
+		}
+		for i := range params {
+			params[i] = redactedValue
+		}
+		changed = true
+	}
+	if !changed {
+		return rawQuery
+	}
+	return values.Encode()
+}
+
 // MetricsMiddleware records metrics for HTTP requests
 func MetricsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -141,6 +209,28 @@ func MetricsMiddleware() gin.HandlerFunc {
 	}
 }
 
+// unmatchedRoute labels route metrics of requests that matched no route, such
+// as 404s, which have no route template
+const unmatchedRoute = "unmatched"
+
+// RouteMetricsMiddleware records request counts and latency histograms per
+// route and method. Requests are labeled with the route template, such as
+// /api/v1/services/:id, so path parameters do not inflate cardinality. It must
+// be installed before the routes it measures are registered.
+func RouteMetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		RecordRouteMetrics(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
 // RecoveryMiddleware recovers from panics and logs them
 func RecoveryMiddleware(logger *Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -194,11 +284,7 @@ func HealthCheckMiddleware(checker *HealthChecker) gin.HandlerFunc {
 func MetricsEndpointMiddleware(collector *MetricsCollector) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if c.Request.URL.Path == "/metrics" {
-			metrics := collector.GetAll()
-			c.JSON(200, gin.H{
-				"metrics":   metrics,
-				"timestamp": time.Now(),
-			})
+			serveMetrics(c, collector)
 			c.Abort()
 		} else {
 			c.Next()
@@ -206,6 +292,30 @@ func MetricsEndpointMiddleware(collector *MetricsCollector) gin.HandlerFunc {
 	}
 }
 
+// MetricsHandler serves collected metrics, negotiating the format from the
+// Accept header: Prometheus text exposition for text/plain (scrapers) and JSON
+// otherwise (the UI)
+func MetricsHandler(collector *MetricsCollector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serveMetrics(c, collector)
+	}
+}
+
+// serveMetrics renders metrics in the format requested by the client
+func serveMetrics(c *gin.Context, collector *MetricsCollector) {
+	switch c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) {
+	case gin.MIMEPlain:
+		var buf bytes.Buffer
+		collector.WritePrometheus(&buf)
+		c.Data(200, PrometheusContentType, buf.Bytes())
+	default:
+		c.JSON(200, gin.H{
+			"metrics":   collector.GetAll(),
+			"timestamp": time.Now(),
+		})
+	}
+}
+
 // CORSWithLogging adds CORS headers and logs cross-origin requests
 func CORSWithLogging(logger *Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
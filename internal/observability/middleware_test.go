@@ -0,0 +1,221 @@
+// internal/observability/middleware_test.go
+package observability
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMetricsHandlerContentNegotiation tests that /metrics serves Prometheus text or JSON based on Accept
+func TestMetricsHandlerContentNegotiation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	collector := NewMetricsCollector()
+	collector.Inc(MetricQueryTotal, nil)
+	collector.Inc(MetricQueryTotal, nil)
+	collector.Inc(MetricQueryFailure, map[string]string{"error_type": `bad "quote"`})
+	collector.Set(MetricAuthSessionsActive, 3, nil)
+	collector.Observe(MetricQueryDuration, 0.5, nil)
+	collector.Observe(MetricQueryDuration, 1.5, nil)
+
+	router := gin.New()
+	router.GET("/metrics", MetricsHandler(collector))
+
+	serve := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	prometheusTests := []struct {
+		name   string
+		accept string
+	}{
+		{name: "text/plain", accept: "text/plain"},
+		{name: "prometheus scraper", accept: "application/openmetrics-text;version=1.0.0;q=0.75,text/plain;version=0.0.4;q=0.5,*/*;q=0.1"},
+	}
+
+	for _, tt := range prometheusTests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.accept)
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, PrometheusContentType, w.Header().Get("Content-Type"))
+
+			body := w.Body.String()
+			assert.Contains(t, body, "# TYPE query_processor_queries_total counter\nquery_processor_queries_total 2\n")
+			assert.Contains(t, body, `query_processor_queries_failure_total{error_type="bad \"quote\""} 1`)
+			assert.Contains(t, body, "# TYPE auth_sessions_active gauge\nauth_sessions_active 3\n")
+			assert.Contains(t, body, "# TYPE query_processor_query_duration_seconds summary\n")
+			assert.Contains(t, body, "query_processor_query_duration_seconds_sum 2\n")
+			assert.Contains(t, body, "query_processor_query_duration_seconds_count 2\n")
+			assert.False(t, strings.HasPrefix(strings.TrimSpace(body), "{"))
+		})
+	}
+
+	jsonTests := []struct {
+		name   string
+		accept string
+	}{
+		{name: "application/json", accept: "application/json"},
+		{name: "no accept header", accept: ""},
+	}
+
+	for _, tt := range jsonTests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.accept)
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+			var response struct {
+				Metrics map[string]Metric `json:"metrics"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Contains(t, response.Metrics, MetricQueryTotal)
+			assert.Equal(t, 2.0, response.Metrics[MetricQueryTotal].Value)
+		})
+	}
+}
+
+// TestRouteMetricsMiddleware tests that route metrics are labeled with the
+// route template rather than the concrete request path
+func TestRouteMetricsMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	GetGlobalMetrics().Reset()
+	defer GetGlobalMetrics().Reset()
+
+	router := gin.New()
+	router.Use(RouteMetricsMiddleware())
+	router.GET("/api/v1/services/:id", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+	})
+
+	for _, path := range []string{"/api/v1/services/api", "/api/v1/services/web", "/missing"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	requests, ok := GetGlobalMetrics().Get(MetricHTTPRouteRequests, map[string]string{
+		"method": "GET", "route": "/api/v1/services/:id", "status": "200",
+	})
+	require.True(t, ok, "requests should be labeled with the route template")
+	assert.Equal(t, 2.0, requests.Value)
+
+	_, ok = GetGlobalMetrics().Get(MetricHTTPRouteRequests, map[string]string{
+		"method": "GET", "route": "unmatched", "status": "404",
+	})
+	assert.True(t, ok, "unmatched requests should share one label")
+
+	for _, metric := range GetGlobalMetrics().GetAll() {
+		assert.NotContains(t, metric.Labels["route"], "/api/v1/services/api")
+		assert.NotContains(t, metric.Labels["route"], "/api/v1/services/web")
+	}
+
+	var buf strings.Builder
+	require.NoError(t, GetGlobalMetrics().WritePrometheus(&buf))
+	body := buf.String()
+	assert.Contains(t, body, "# TYPE http_route_request_duration_seconds histogram\n")
+	assert.Contains(t, body, `http_route_request_duration_seconds_bucket{le="+Inf",method="GET",route="/api/v1/services/:id"} 2`)
+	assert.Contains(t, body, `http_route_request_duration_seconds_count{method="GET",route="/api/v1/services/:id"} 2`)
+}
+
+// TestObserveHistogramBuckets tests that observations are counted in every
+// bucket whose upper bound they do not exceed
+func TestObserveHistogramBuckets(t *testing.T) {
+	collector := NewMetricsCollector()
+	buckets := []float64{0.1, 1, 10}
+	for _, value := range []float64{0.05, 0.5, 5, 50} {
+		collector.ObserveHistogram("test_latency_seconds", value, buckets, nil)
+	}
+
+	var buf strings.Builder
+	require.NoError(t, collector.WritePrometheus(&buf))
+	body := buf.String()
+	assert.Contains(t, body, "test_latency_seconds_bucket{le=\"0.1\"} 1\n")
+	assert.Contains(t, body, "test_latency_seconds_bucket{le=\"1\"} 2\n")
+	assert.Contains(t, body, "test_latency_seconds_bucket{le=\"10\"} 3\n")
+	assert.Contains(t, body, "test_latency_seconds_bucket{le=\"+Inf\"} 4\n")
+	assert.Contains(t, body, "test_latency_seconds_sum 55.55\n")
+	assert.Contains(t, body, "test_latency_seconds_count 4\n")
+}
+
+// TestRequestLoggingSampling tests that successful requests are sampled while
+// failed requests are always logged, with sensitive query parameters redacted
+func TestRequestLoggingSampling(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var buf strings.Builder
+	logger := NewLogger("test").WithOutput(&buf)
+
+	router := gin.New()
+	router.Use(RequestLoggingMiddlewareWithConfig(logger, RequestLogConfig{
+		SampleRate:   5,
+		RedactParams: []string{"q"},
+	}))
+	router.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/fail", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+
+	serve := func(path string) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// entries returns the logged entries with the message, and resets the log
+	entries := func(message string) []LogEntry {
+		var matched []LogEntry
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var entry LogEntry
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			if entry.Message == message {
+				matched = append(matched, entry)
+			}
+		}
+		return matched
+	}
+
+	for i := 0; i < 10; i++ {
+		serve("/ok?q=secret+service&limit=5")
+	}
+	started := entries("HTTP request started")
+	assert.Len(t, started, 2, "1 in 5 successful requests is logged")
+	assert.Len(t, entries("HTTP request completed"), 2)
+	for _, entry := range started {
+		assert.Equal(t, "limit=5&q=REDACTED", entry.Fields["query"])
+	}
+	assert.NotContains(t, buf.String(), "secret")
+
+	buf.Reset()
+	for i := 0; i < 10; i++ {
+		serve("/fail?Q=secret")
+	}
+	failed := entries("HTTP request completed with error status")
+	assert.Len(t, failed, 10, "failed requests are always logged")
+	for _, entry := range failed {
+		assert.Equal(t, "Q=REDACTED", entry.Fields["query"])
+	}
+	assert.NotContains(t, buf.String(), "secret")
+}
+
+// TestRedactQuery tests query string redaction
+func TestRedactQuery(t *testing.T) {
+	redact := map[string]bool{"token": true}
+
+	assert.Equal(t, "", redactQuery("", redact))
+	assert.Equal(t, "limit=5", redactQuery("limit=5", redact))
+	assert.Equal(t, "a=1&token=REDACTED&token=REDACTED", redactQuery("token=x&a=1&token=y", redact))
+	assert.Equal(t, "REDACTED", redactQuery("token=%zz", redact), "unparseable query strings are redacted")
+	assert.Equal(t, "token=x", redactQuery("token=x", nil))
+}
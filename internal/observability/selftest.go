@@ -0,0 +1,110 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// SelfTestStep is one dependency verified by the startup self-test
+type SelfTestStep struct {
+	Name  string
+	Check HealthCheckFunc
+}
+
+// SelfTestReport is the outcome of a self-test, with results in step order
+type SelfTestReport struct {
+	Results []*HealthCheck
+	Passed  bool // No step was unhealthy
+}
+
+// FailedStep returns a step that always fails with the error, for
+// dependencies that could not be set up far enough to be checked
+func FailedStep(name string, err error) SelfTestStep {
+	return SelfTestStep{Name: name, Check: func(ctx context.Context) *HealthCheck {
+		return &HealthCheck{Status: HealthStatusUnhealthy, Message: err.Error()}
+	}}
+}
+
+// RequiredStep returns a step that fails the self-test when its check reports
+// the dependency degraded, for dependencies that only degrade readiness but
+// that a deployment must be able to reach, such as the LLM service
+func RequiredStep(name string, check HealthCheckFunc) SelfTestStep {
+	return SelfTestStep{Name: name, Check: func(ctx context.Context) *HealthCheck {
+		result := check(ctx)
+		if result.Status == HealthStatusDegraded {
+			result.Status = HealthStatusUnhealthy
+		}
+		return result
+	}}
+}
+
+// RunSelfTest runs each step in order, bypassing the health check cache.
+// Degraded steps are reported but do not fail the self-test, matching how
+// they affect readiness.
+func RunSelfTest(ctx context.Context, steps []SelfTestStep) *SelfTestReport {
+	report := &SelfTestReport{Passed: true}
+	for _, step := range steps {
+		start := time.Now()
+		result := step.Check(ctx)
+		result.Name = step.Name
+		result.LastChecked = time.Now()
+		if result.Duration == 0 {
+			result.Duration = time.Since(start)
+		}
+		if result.Status == HealthStatusUnhealthy {
+			report.Passed = false
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+// ExitCode returns the process exit code for the report: 0 when the
+// self-test passed, 1 otherwise
+func (r *SelfTestReport) ExitCode() int {
+	if r.Passed {
+		return 0
+	}
+	return 1
+}
+
+// WriteTable writes the report as a pass/fail table followed by a summary line
+func (r *SelfTestReport) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tRESULT\tDURATION\tMESSAGE")
+	for _, result := range r.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n",
+			result.Name, selfTestResult(result.Status), result.Duration.Round(time.Millisecond), oneLine(result.Message))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if r.Passed {
+		_, err := fmt.Fprintln(w, "\nSelf-test passed")
+		return err
+	}
+	_, err := fmt.Fprintln(w, "\nSelf-test FAILED")
+	return err
+}
+
+// selfTestResult labels a health status for the self-test table
+func selfTestResult(status HealthStatus) string {
+	switch status {
+	case HealthStatusHealthy:
+		return "PASS"
+	case HealthStatusDegraded:
+		return "WARN"
+	default:
+		return "FAIL"
+	}
+}
+
+// oneLine keeps multi-line error messages from breaking the table
+func oneLine(message string) string {
+	return strings.Join(strings.Fields(message), " ")
+}
@@ -0,0 +1,78 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRunSelfTest tests that the self-test reports every dependency in order
+// and fails only when a dependency is unhealthy
+func TestRunSelfTest(t *testing.T) {
+	healthy := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return fmt.Errorf("connection refused") }
+
+	t.Run("mixed dependencies", func(t *testing.T) {
+		report := RunSelfTest(context.Background(), []SelfTestStep{
+			{Name: "database", Check: DatabaseHealthCheck(healthy)},
+			{Name: "redis", Check: RedisHealthCheck(failing)},
+			{Name: "llm_service", Check: LLMHealthCheck(failing)},
+			FailedStep("mimir", fmt.Errorf("invalid endpoint:\n missing scheme")),
+		})
+
+		require.Len(t, report.Results, 4)
+		assert.False(t, report.Passed)
+		assert.Equal(t, 1, report.ExitCode())
+		assert.Equal(t, "database", report.Results[0].Name)
+		assert.Equal(t, HealthStatusHealthy, report.Results[0].Status)
+		assert.Equal(t, HealthStatusUnhealthy, report.Results[1].Status)
+		assert.Equal(t, HealthStatusDegraded, report.Results[2].Status)
+		assert.Equal(t, "mimir", report.Results[3].Name)
+
+		var out bytes.Buffer
+		require.NoError(t, report.WriteTable(&out))
+		table := out.String()
+		assert.Regexp(t, `(?m)^CHECK\s+RESULT\s+DURATION\s+MESSAGE$`, table)
+		assert.Regexp(t, `(?m)^database\s+PASS\s+`, table)
+		assert.Regexp(t, `(?m)^redis\s+FAIL\s+.*connection refused$`, table)
+		assert.Regexp(t, `(?m)^llm_service\s+WARN\s+`, table)
+		assert.Regexp(t, `(?m)^mimir\s+FAIL\s+.*invalid endpoint: missing scheme$`, table)
+		assert.Contains(t, table, "Self-test FAILED")
+	})
+
+	t.Run("degraded dependencies pass", func(t *testing.T) {
+		report := RunSelfTest(context.Background(), []SelfTestStep{
+			{Name: "database", Check: DatabaseHealthCheck(healthy)},
+			{Name: "llm_service", Check: LLMHealthCheck(failing)},
+		})
+
+		assert.True(t, report.Passed)
+		assert.Equal(t, 0, report.ExitCode())
+
+		var out bytes.Buffer
+		require.NoError(t, report.WriteTable(&out))
+		assert.Contains(t, out.String(), "Self-test passed")
+	})
+
+	t.Run("required dependencies fail when degraded", func(t *testing.T) {
+		report := RunSelfTest(context.Background(), []SelfTestStep{
+			{Name: "database", Check: DatabaseHealthCheck(healthy)},
+			RequiredStep("llm_service", LLMHealthCheck(failing)),
+		})
+
+		assert.False(t, report.Passed)
+		assert.Equal(t, 1, report.ExitCode())
+		assert.Equal(t, HealthStatusUnhealthy, report.Results[1].Status)
+
+		var out bytes.Buffer
+		require.NoError(t, report.WriteTable(&out))
+		assert.Regexp(t, `(?m)^llm_service\s+FAIL\s+.*connection refused$`, out.String())
+
+		report = RunSelfTest(context.Background(), []SelfTestStep{RequiredStep("llm_service", LLMHealthCheck(healthy))})
+		assert.True(t, report.Passed)
+	})
+}
@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+)
+
+// SafetyAdvisory is a safety problem a natural language query is likely to
+// run into, spotted before its PromQL is generated. Advisories never block a
+// query; the generated PromQL is still checked by ValidateQuery.
+type SafetyAdvisory struct {
+	Rule    errors.ErrorCode `json:"rule"` // Error code of the check likely to fail
+	Message string           `json:"message"`
+}
+
+// advisoryTimeRangePattern finds lookback windows in a question, including
+// the months and years the intent classifier does not extract
+var advisoryTimeRangePattern = regexp.MustCompile(`(?i)\b(?:last|past|over|in the)\s+(\d+\s*)?(minute|hour|day|week|month|year)s?\b`)
+
+// advisoryCardinalityPattern finds breakdowns by labels that usually have a
+// value per user or request
+var advisoryCardinalityPattern = regexp.MustCompile(`(?i)\b(?:per|by|each|every|for each|for every)\s+((?:user|customer|session|request|trace|ip|url|path)(?:\s*(?:id|address))?)s?\b`)
+
+// advisoryUnits are the lengths of the time units in questions
+var advisoryUnits = map[string]time.Duration{
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+	"month":  30 * 24 * time.Hour,
+	"year":   365 * 24 * time.Hour,
+}
+
+// forbiddenWordPattern finds the words of a forbidden metric pattern, such
+// as "secret" in .*_secret.*
+var forbiddenWordPattern = regexp.MustCompile(`[a-z]{3,}`)
+
+// advisoryWordPattern finds the words of a lowercased question
+var advisoryWordPattern = regexp.MustCompile(`[a-z]+`)
+
+// Advise returns the safety checks a natural language query is likely to
+// fail once translated: mentions of forbidden concepts, lookback windows
+// beyond MaxQueryRange and breakdowns by high cardinality labels. It is a
+// heuristic, and may both miss problems and flag harmless queries.
+func (sc *SafetyChecker) Advise(query string) []SafetyAdvisory {
+	var advisories []SafetyAdvisory
+
+	if concept := sc.forbiddenConcept(query); concept != "" {
+		advisories = append(advisories, SafetyAdvisory{
+			Rule:    errors.ErrCodeForbiddenMetric,
+			Message: fmt.Sprintf("The question mentions %q; metrics about %s are forbidden, so the query may be rejected", concept, concept),
+		})
+	}
+
+	if sc.MaxQueryRange > 0 {
+		for _, match := range advisoryTimeRangePattern.FindAllStringSubmatch(query, -1) {
+			count := 1
+			if n := strings.TrimSpace(match[1]); n != "" {
+				count, _ = strconv.Atoi(n)
+			}
+			window := time.Duration(count) * advisoryUnits[strings.ToLower(match[2])]
+			if window > sc.MaxQueryRange {
+				advisories = append(advisories, SafetyAdvisory{
+					Rule:    errors.ErrCodeExcessiveTimeRange,
+					Message: fmt.Sprintf("The question asks about %s, beyond the maximum range of %s; the query may be rejected or shortened", strings.TrimSpace(match[0]), sc.MaxQueryRange),
+				})
+				break
+			}
+		}
+	}
+
+	if match := advisoryCardinalityPattern.FindStringSubmatch(query); match != nil {
+		advisories = append(advisories, SafetyAdvisory{
+			Rule:    errors.ErrCodeHighCardinality,
+			Message: fmt.Sprintf("Breaking results down per %s can return a very large number of series; the query may be rejected or slow. Consider filtering to specific values or a top-k ranking.", strings.ToLower(match[1])),
+		})
+	}
+
+	return advisories
+}
+
+// forbiddenConcept returns the first word of the forbidden metric and
+// pattern lists that the query mentions, or ""
+func (sc *SafetyChecker) forbiddenConcept(query string) string {
+	words := make(map[string]bool)
+	for _, word := range advisoryWordPattern.FindAllString(strings.ToLower(query), -1) {
+		words[strings.TrimSuffix(word, "s")] = true
+		words[word] = true
+	}
+	for _, pattern := range append(append([]string{}, sc.ForbiddenMetrics...), sc.ForbiddenPatterns...) {
+		for _, concept := range forbiddenWordPattern.FindAllString(strings.ToLower(pattern), -1) {
+			if words[concept] {
+				return concept
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSafetyAdvise tests the safety problems spotted in questions before
+// their PromQL is generated
+func TestSafetyAdvise(t *testing.T) {
+	sc := NewSafetyChecker()
+
+	tests := []struct {
+		name  string
+		query string
+		rules []errors.ErrorCode
+	}{
+		{"forbidden concept", "show password reset failures for auth", []errors.ErrorCode{errors.ErrCodeForbiddenMetric}},
+		{"plural forbidden concept", "how many secrets were rotated", []errors.ErrorCode{errors.ErrCodeForbiddenMetric}},
+		{"long lookback", "error rate over the last 2 weeks", []errors.ErrorCode{errors.ErrCodeExcessiveTimeRange}},
+		{"lookback without a count", "availability in the past year", []errors.ErrorCode{errors.ErrCodeExcessiveTimeRange}},
+		{"lookback within the limit", "error rate over the last 3 days", nil},
+		{"high cardinality breakdown", "latency per user id for checkout", []errors.ErrorCode{errors.ErrCodeHighCardinality}},
+		{"several problems", "token usage by session in the last month", []errors.ErrorCode{errors.ErrCodeForbiddenMetric, errors.ErrCodeExcessiveTimeRange, errors.ErrCodeHighCardinality}},
+		{"neutral", "p95 latency of checkout by pod", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules []errors.ErrorCode
+			for _, advisory := range sc.Advise(tt.query) {
+				assert.NotEmpty(t, advisory.Message)
+				rules = append(rules, advisory.Rule)
+			}
+			assert.Equal(t, tt.rules, rules)
+		})
+	}
+}
+
+// TestQueryResponseSafetyWarnings tests that a question mentioning a
+// forbidden concept is answered with a warning rather than blocked
+func TestQueryResponseSafetyWarnings(t *testing.T) {
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(auth_resets_total{service="auth"}[5m]))`, Confidence: 0.9}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+
+	response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "password reset rate for service auth"})
+	require.NoError(t, err)
+	require.Len(t, response.Warnings, 1)
+	assert.Equal(t, errors.ErrCodeForbiddenMetric, response.Warnings[0].Rule)
+	assert.Contains(t, response.Warnings[0].Message, `"password"`)
+
+	// Cache hits carry the warnings too
+	response, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "password reset rate for service auth"})
+	require.NoError(t, err)
+	assert.True(t, response.CacheHit)
+	assert.Len(t, response.Warnings, 1)
+
+	response, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate for service auth"})
+	require.NoError(t, err)
+	assert.Empty(t, response.Warnings)
+}
+
+// TestQueryErrorSafetyWarnings tests that a rejected query reports the
+// warnings spotted in its question
+func TestQueryErrorSafetyWarnings(t *testing.T) {
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(user_password_resets_total{service="auth"}[5m]))`, Confidence: 0.9}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+
+	_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "password reset rate for service auth"})
+	require.Error(t, err)
+	enhancedErr, ok := err.(*errors.EnhancedError)
+	require.True(t, ok, "expected an enhanced error, got %T", err)
+	assert.Equal(t, errors.ErrCodeForbiddenMetric, enhancedErr.Code)
+	warnings, ok := enhancedErr.Metadata["warnings"].([]SafetyAdvisory)
+	require.True(t, ok)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, errors.ErrCodeForbiddenMetric, warnings[0].Rule)
+}
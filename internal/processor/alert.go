@@ -0,0 +1,328 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults for alert rule fields the LLM does not suggest
+const (
+	defaultAlertFor      = "5m"
+	defaultAlertSeverity = "warning"
+	alertRuleGroupName   = "observability-ai"
+)
+
+// alertSeverities are the severities a generated rule may carry
+var alertSeverities = map[string]bool{
+	"info":     true,
+	"warning":  true,
+	"critical": true,
+}
+
+var (
+	// alertThresholdPattern matches the condition of an alert, e.g. "exceeds 5%" or "< 0.5"
+	alertThresholdPattern = regexp.MustCompile(`(?i)(>=|<=|>|<|\b(?:above|exceeds?|exceeding|greater than|more than|higher than|below|under|less than|lower than|drops below|falls below))\s*(\d+(?:\.\d+)?)\s*(%|percent\b)?`)
+	// alertComparisonPattern matches an expression that already ends in a comparison with a number
+	alertComparisonPattern = regexp.MustCompile(`(>=|<=|==|!=|>|<)\s*(?:bool\s+)?-?\d+(?:\.\d+)?(?:e[-+]?\d+)?\s*$`)
+	// alertSuggestionPattern matches "# for: 10m" and "# severity: critical" comment lines in a text answer
+	alertSuggestionPattern = regexp.MustCompile(`(?im)^\s*#\s*(for|severity)\s*:\s*(\S+)\s*$`)
+	alertDurationPattern   = regexp.MustCompile(`^\d+[smhdw]$`)
+)
+
+// AlertRequest describes an alerting condition in natural language, e.g.
+// "alert when error rate exceeds 5%"
+type AlertRequest struct {
+	Query  string `json:"query" binding:"required"`
+	Name   string `json:"name,omitempty"`   // Optional alert name, derived from the query when empty
+	Model  string `json:"model,omitempty"`  // Optional LLM model override, must be allowlisted
+	Tenant string `json:"tenant,omitempty"` // Optional Mimir tenant, must be configured
+}
+
+// AlertThreshold is the comparison an alert fires on
+type AlertThreshold struct {
+	Operator string  `json:"operator"`
+	Value    float64 `json:"value"`
+}
+
+// AlertRule is a Prometheus alerting rule
+type AlertRule struct {
+	Alert       string            `json:"alert" yaml:"alert"`
+	Expr        string            `json:"expr" yaml:"expr"`
+	For         string            `json:"for,omitempty" yaml:"for,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+}
+
+// AlertResponse holds a generated alerting rule
+type AlertResponse struct {
+	Rule           AlertRule              `json:"rule"`
+	YAML           string                 `json:"yaml"` // The rule as a Prometheus rule file
+	Threshold      AlertThreshold         `json:"threshold"`
+	Explanation    string                 `json:"explanation"`
+	Suggestions    []string               `json:"suggestions,omitempty"` // Post-processor warnings, e.g. deprecated metrics
+	Confidence     float64                `json:"confidence"`
+	EstimatedCost  int                    `json:"estimated_cost"`
+	ProcessingTime time.Duration          `json:"processing_time"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// alertRuleFile is the Prometheus rule file layout
+type alertRuleFile struct {
+	Groups []alertRuleGroup `yaml:"groups"`
+}
+
+type alertRuleGroup struct {
+	Name  string      `yaml:"name"`
+	Rules []AlertRule `yaml:"rules"`
+}
+
+// ProcessAlert converts a natural language condition into a Prometheus
+// alerting rule. The condition is filtered and the rule expression is
+// post-processed, including the safety checks, like a normal query.
+func (qp *QueryProcessor) ProcessAlert(ctx context.Context, req *AlertRequest) (*AlertResponse, error) {
+	start := time.Now()
+
+	// Blocked conditions are rejected before any other work
+	if err := qp.queryFilter.check(req.Query); err != nil {
+		return nil, err
+	}
+	if err := qp.validateModel(req.Model); err != nil {
+		return nil, err
+	}
+	tenant, err := qp.resolveTenant(ctx, req.Tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold, ok := parseAlertThreshold(req.Query)
+	if !ok {
+		return nil, errors.NewInvalidInputError("query", "the alert condition must include a threshold").
+			WithSuggestion("State when the alert should fire, e.g. 'alert when error rate exceeds 5%' or 'alert when free disk space is below 10%'.")
+	}
+
+	// Alert rules are generated like queries, so they share the query slots
+	release, err := qp.acquireQuerySlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	intent, err := qp.intentClassifier.ClassifyIntent(req.Query)
+	if err != nil {
+		return nil, errors.NewIntentClassificationError(err, req.Query)
+	}
+
+	prompt, err := qp.buildAlertPrompt(ctx, &QueryRequest{Query: req.Query}, intent, threshold)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodePromptBuilding, "Failed to build prompt for alert generation").
+			WithDetails("An error occurred while constructing the prompt for the AI model").
+			WithSuggestion("This is an internal error. Please try your request again.").
+			WithMetadata("retryable", true).
+			WithDependency(errors.DependencyDatabase)
+	}
+
+	temperature := qp.temperature
+	llmResponse, err := llm.GenerateQueryWithOptions(ctx, qp.llmClient, prompt, llm.GenerateOptions{Model: req.Model, Temperature: &temperature})
+	if err != nil {
+		return nil, errors.NewQueryGenerationError(err)
+	}
+	if err := llmRefusal(llmResponse); err != nil {
+		return nil, err
+	}
+	if err := llmEmptyQuery(llmResponse); err != nil {
+		return nil, err
+	}
+
+	// The rule expression goes through the same post-processors as a query
+	generated := &GeneratedQuery{PromQL: withAlertThreshold(strings.TrimSpace(llmResponse.PromQL), threshold), Intent: intent}
+	if _, err := qp.postProcess(ctx, generated); err != nil {
+		return nil, err
+	}
+	expr := generated.PromQL
+
+	forDuration, severity, explanation := alertSuggestions(llmResponse)
+	name := req.Name
+	if name == "" {
+		name = alertName(intent, threshold)
+	}
+
+	rule := AlertRule{
+		Alert:  name,
+		Expr:   expr,
+		For:    forDuration,
+		Labels: map[string]string{"severity": severity},
+		Annotations: map[string]string{
+			"summary":     req.Query,
+			"description": explanation,
+		},
+	}
+	ruleYAML, err := yaml.Marshal(alertRuleFile{
+		Groups: []alertRuleGroup{{Name: alertRuleGroupName, Rules: []AlertRule{rule}}},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeQueryGeneration, "Failed to render alerting rule")
+	}
+
+	response := &AlertResponse{
+		Rule:           rule,
+		YAML:           string(ruleYAML),
+		Threshold:      threshold,
+		Explanation:    explanation,
+		Suggestions:    generated.Warnings,
+		Confidence:     adjustConfidence(llmResponse.Confidence, intent.Confidence),
+		EstimatedCost:  qp.estimateQueryCost(expr, 0),
+		ProcessingTime: time.Since(start),
+		Metadata: map[string]interface{}{
+			"intent":         intent,
+			"llm_confidence": llmResponse.Confidence,
+		},
+	}
+	if tenant != "" {
+		response.Metadata["tenant"] = tenant
+	}
+
+	qp.logger.Info(ctx, "Generated alerting rule", map[string]interface{}{
+		"query": req.Query,
+		"alert": name,
+	})
+	return response, nil
+}
+
+// buildAlertPrompt extends the query generation prompt to ask for an alert
+// expression firing on threshold, with suggested for and severity values
+func (qp *QueryProcessor) buildAlertPrompt(ctx context.Context, req *QueryRequest, intent *QueryIntent, threshold AlertThreshold) (string, error) {
+	prompt, err := qp.buildPrompt(ctx, req, intent, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var promptBuilder strings.Builder
+	promptBuilder.WriteString(strings.TrimSuffix(prompt, promptResponseInstruction))
+	promptBuilder.WriteString("\n=== ALERTING RULE ===\n")
+	promptBuilder.WriteString("The query is the expression of a Prometheus alerting rule. The alert fires while the expression returns a result.\n")
+	promptBuilder.WriteString(fmt.Sprintf("  - End the expression with the comparison: %s %s\n", threshold.Operator, formatThreshold(threshold.Value)))
+	promptBuilder.WriteString("  - Percentages are given as ratios (5% is 0.05), so compare ratios, not percentages\n")
+	if llm.AnswersStructured(qp.llmClient) {
+		promptBuilder.WriteString("  - Suggest how long the condition must hold in the for field and how severe it is in the severity field\n")
+		promptBuilder.WriteString("\nYour Response:")
+		return promptBuilder.String(), nil
+	}
+	promptBuilder.WriteString("  - After the expression, suggest how long the condition must hold and how severe it is, as comment lines:\n")
+	promptBuilder.WriteString("    # for: <duration, e.g. 5m>\n")
+	promptBuilder.WriteString("    # severity: <info|warning|critical>\n")
+	promptBuilder.WriteString("\nYour Response (alert expression with suggestions, or ERROR):")
+	return promptBuilder.String(), nil
+}
+
+// parseAlertThreshold extracts the comparison an alert fires on. Percentages
+// are converted to ratios.
+func parseAlertThreshold(query string) (AlertThreshold, bool) {
+	match := alertThresholdPattern.FindStringSubmatch(query)
+	if match == nil {
+		return AlertThreshold{}, false
+	}
+	value, err := strconv.ParseFloat(match[2], 64)
+	if err != nil {
+		return AlertThreshold{}, false
+	}
+	if match[3] != "" {
+		value /= 100
+	}
+
+	operator := ">"
+	switch condition := strings.ToLower(match[1]); {
+	case strings.HasPrefix(condition, "<") || strings.HasPrefix(condition, ">"):
+		operator = condition
+	case condition == "below" || condition == "under" || strings.HasPrefix(condition, "less") ||
+		strings.HasPrefix(condition, "lower") || strings.HasSuffix(condition, "below"):
+		operator = "<"
+	}
+	return AlertThreshold{Operator: operator, Value: value}, true
+}
+
+// withAlertThreshold appends the threshold comparison to expressions that do
+// not already end in one
+func withAlertThreshold(expr string, threshold AlertThreshold) string {
+	if alertComparisonPattern.MatchString(expr) {
+		return expr
+	}
+	return fmt.Sprintf("%s %s %s", expr, threshold.Operator, formatThreshold(threshold.Value))
+}
+
+// alertSuggestions returns the suggested for duration and severity of a rule,
+// falling back to defaults for missing or invalid suggestions, and the
+// explanation. Structured answers suggest them in their own fields; text
+// answers in "# for:" and "# severity:" comment lines, which are removed
+// from the explanation.
+func alertSuggestions(response *llm.Response) (forDuration, severity, explanation string) {
+	forDuration, severity = defaultAlertFor, defaultAlertSeverity
+	explanation = response.Explanation
+	suggested := map[string]string{"for": response.For, "severity": response.Severity}
+	if response.For == "" && response.Severity == "" {
+		for _, match := range alertSuggestionPattern.FindAllStringSubmatch(explanation, -1) {
+			suggested[strings.ToLower(match[1])] = match[2]
+		}
+		explanation = strings.TrimSpace(alertSuggestionPattern.ReplaceAllString(explanation, ""))
+	}
+
+	if value := strings.ToLower(suggested["for"]); alertDurationPattern.MatchString(value) {
+		forDuration = value
+	}
+	if value := strings.ToLower(suggested["severity"]); alertSeverities[value] {
+		severity = value
+	}
+	return forDuration, severity, explanation
+}
+
+// alertName derives an alert name such as "ApiErrorRateHigh" from the intent
+func alertName(intent *QueryIntent, threshold AlertThreshold) string {
+	var name strings.Builder
+	for _, part := range strings.FieldsFunc(intent.Service+"_"+intent.Metric, func(r rune) bool {
+		return r == '_' || r == '-' || r == '.' || r == ' '
+	}) {
+		name.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	if name.Len() == 0 {
+		name.WriteString("Generated")
+	}
+	if strings.HasPrefix(threshold.Operator, "<") {
+		name.WriteString("Low")
+	} else {
+		name.WriteString("High")
+	}
+	return name.String()
+}
+
+// formatThreshold renders a threshold value without trailing zeros
+func formatThreshold(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// handleGenerateAlert handles POST /api/v1/alert
+func (qp *QueryProcessor) handleGenerateAlert(c *gin.Context) {
+	var req AlertRequest
+	if err := qp.bindJSON(c, &req); err != nil {
+		enhancedErr := errors.NewRequestBodyError(err)
+		c.JSON(http.StatusBadRequest, formatErrorResponse(enhancedErr))
+		return
+	}
+
+	response, err := qp.ProcessAlert(c.Request.Context(), &req)
+	if err != nil {
+		setRetryAfter(c, err)
+		c.JSON(getErrorStatusCode(err), formatErrorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,234 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// TestProcessAlert tests that alert conditions become safe alerting rules with the threshold
+func TestProcessAlert(t *testing.T) {
+	t.Run("rule includes threshold and suggestions", func(t *testing.T) {
+		llmClient := &MockLLMClient{response: &llm.Response{
+			PromQL:      `rate(http_errors_total[5m]) / rate(http_requests_total[5m]) > 0.05`,
+			Explanation: "Fraction of requests that fail",
+			Confidence:  0.9,
+			For:         "10m",
+			Severity:    "critical",
+		}}
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+
+		response, err := qp.ProcessAlert(context.Background(), &AlertRequest{Query: "alert when error rate exceeds 5%"})
+		require.NoError(t, err)
+		assert.NoError(t, qp.safetyChecker.ValidateQuery(response.Rule.Expr))
+		assert.Contains(t, response.Rule.Expr, "> 0.05")
+		assert.Equal(t, AlertThreshold{Operator: ">", Value: 0.05}, response.Threshold)
+		assert.Equal(t, "ErrorRateHigh", response.Rule.Alert)
+		assert.Equal(t, "10m", response.Rule.For)
+		assert.Equal(t, "critical", response.Rule.Labels["severity"])
+		assert.Equal(t, "Fraction of requests that fail", response.Rule.Annotations["description"])
+
+		var file alertRuleFile
+		require.NoError(t, yaml.Unmarshal([]byte(response.YAML), &file))
+		require.Len(t, file.Groups, 1)
+		require.Len(t, file.Groups[0].Rules, 1)
+		assert.Equal(t, response.Rule, file.Groups[0].Rules[0])
+	})
+
+	t.Run("missing comparison is appended with defaults", func(t *testing.T) {
+		llmClient := &MockLLMClient{response: &llm.Response{
+			PromQL:     `avg(disk_free_ratio)`,
+			Confidence: 0.8,
+		}}
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+
+		response, err := qp.ProcessAlert(context.Background(), &AlertRequest{Query: "page me when free disk drops below 10 percent", Name: "DiskAlmostFull"})
+		require.NoError(t, err)
+		assert.Equal(t, `avg(disk_free_ratio) < 0.1`, response.Rule.Expr)
+		assert.Equal(t, "DiskAlmostFull", response.Rule.Alert)
+		assert.Equal(t, defaultAlertFor, response.Rule.For)
+		assert.Equal(t, defaultAlertSeverity, response.Rule.Labels["severity"])
+	})
+
+	t.Run("condition without threshold is rejected", func(t *testing.T) {
+		llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(up)`}}
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+
+		_, err := qp.ProcessAlert(context.Background(), &AlertRequest{Query: "alert when error rate is bad"})
+		require.Error(t, err)
+		assert.Equal(t, errors.ErrCodeInvalidInput, err.(*errors.EnhancedError).Code)
+	})
+
+	t.Run("unsafe expression is rejected", func(t *testing.T) {
+		llmClient := &MockLLMClient{response: &llm.Response{PromQL: `absent(up{job="api"}) > 0`}}
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+
+		_, err := qp.ProcessAlert(context.Background(), &AlertRequest{Query: "alert when api targets are above 0"})
+		require.Error(t, err)
+		assert.Equal(t, errors.ErrCodeExpensiveOperation, err.(*errors.EnhancedError).Code)
+	})
+}
+
+// TestAlertSuggestions tests reading the suggested for duration and severity
+// from structured fields, or from the comment lines of text answers
+func TestAlertSuggestions(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    *llm.Response
+		forDuration string
+		severity    string
+		explanation string
+	}{
+		{
+			name:        "structured fields",
+			response:    &llm.Response{Explanation: "Failing requests", For: "15m", Severity: "Warning"},
+			forDuration: "15m",
+			severity:    "warning",
+			explanation: "Failing requests",
+		},
+		{
+			name:        "text comment lines",
+			response:    &llm.Response{Explanation: "# for: 10m\n# severity: critical\nFailing requests"},
+			forDuration: "10m",
+			severity:    "critical",
+			explanation: "Failing requests",
+		},
+		{
+			name:        "invalid suggestions",
+			response:    &llm.Response{Explanation: "Failing requests", For: "a while", Severity: "page"},
+			forDuration: defaultAlertFor,
+			severity:    defaultAlertSeverity,
+			explanation: "Failing requests",
+		},
+		{
+			name:        "no suggestions",
+			response:    &llm.Response{Explanation: "Failing requests"},
+			forDuration: defaultAlertFor,
+			severity:    defaultAlertSeverity,
+			explanation: "Failing requests",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forDuration, severity, explanation := alertSuggestions(tt.response)
+			assert.Equal(t, tt.forDuration, forDuration)
+			assert.Equal(t, tt.severity, severity)
+			assert.Equal(t, tt.explanation, explanation)
+		})
+	}
+}
+
+// structuredLLMClient answers in the structured schema, recording its prompts
+type structuredLLMClient struct {
+	MockLLMClient
+	prompts []string
+}
+
+func (m *structuredLLMClient) GenerateQuery(ctx context.Context, prompt string) (*llm.Response, error) {
+	m.prompts = append(m.prompts, prompt)
+	return m.MockLLMClient.GenerateQuery(ctx, prompt)
+}
+
+func (m *structuredLLMClient) StructuredOutput() bool { return true }
+
+// TestProcessAlertStructured tests that structured answers suggest the for
+// duration and severity in their own fields, and that their prompt does not
+// ask for free-form suggestion or confidence lines
+func TestProcessAlertStructured(t *testing.T) {
+	llmClient := &structuredLLMClient{MockLLMClient: MockLLMClient{response: &llm.Response{
+		PromQL:      `rate(http_errors_total[5m]) / rate(http_requests_total[5m]) > 0.05`,
+		Explanation: "Fraction of requests that fail\n# for: 1h",
+		Confidence:  0.9,
+		For:         "10m",
+		Severity:    "critical",
+	}}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+
+	response, err := qp.ProcessAlert(context.Background(), &AlertRequest{Query: "alert when error rate exceeds 5%"})
+	require.NoError(t, err)
+	assert.Equal(t, "10m", response.Rule.For)
+	assert.Equal(t, "critical", response.Rule.Labels["severity"])
+	// Comment lines are not parsed from structured explanations
+	assert.Equal(t, "Fraction of requests that fail\n# for: 1h", response.Explanation)
+
+	require.Len(t, llmClient.prompts, 1)
+	assert.NotContains(t, llmClient.prompts[0], "# for:")
+	assert.NotContains(t, llmClient.prompts[0], "CONFIDENCE:")
+	assert.NotContains(t, llmClient.prompts[0], "ERROR:")
+	assert.Contains(t, llmClient.prompts[0], "severity field")
+
+	// Invalid suggestions fall back to the defaults
+	llmClient.response.For, llmClient.response.Severity = "soon", "page"
+	response, err = qp.ProcessAlert(context.Background(), &AlertRequest{Query: "alert when error rate exceeds 10%"})
+	require.NoError(t, err)
+	assert.Equal(t, defaultAlertFor, response.Rule.For)
+	assert.Equal(t, defaultAlertSeverity, response.Rule.Labels["severity"])
+}
+
+// TestParseAlertThreshold tests extraction of the alert comparison from natural language
+func TestParseAlertThreshold(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected AlertThreshold
+		found    bool
+	}{
+		{query: "alert when error rate exceeds 5%", expected: AlertThreshold{Operator: ">", Value: 0.05}, found: true},
+		{query: "latency above 0.5 seconds", expected: AlertThreshold{Operator: ">", Value: 0.5}, found: true},
+		{query: "memory usage greater than 80 percent", expected: AlertThreshold{Operator: ">", Value: 0.8}, found: true},
+		{query: "free disk below 10%", expected: AlertThreshold{Operator: "<", Value: 0.1}, found: true},
+		{query: "request rate falls below 100", expected: AlertThreshold{Operator: "<", Value: 100}, found: true},
+		{query: "queue depth >= 1000", expected: AlertThreshold{Operator: ">=", Value: 1000}, found: true},
+		{query: "error rate over the last 5 minutes", found: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			threshold, found := parseAlertThreshold(tt.query)
+			assert.Equal(t, tt.found, found)
+			if tt.found {
+				assert.Equal(t, tt.expected.Operator, threshold.Operator)
+				assert.InDelta(t, tt.expected.Value, threshold.Value, 1e-9)
+			}
+		})
+	}
+}
+
+// TestAlertEndpoint tests the alert generation route
+func TestAlertEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_errors_total[5m])) > 10`, Confidence: 0.9}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+	router := qp.SetupRoutes(nil)
+
+	body, err := json.Marshal(AlertRequest{Query: "alert when errors exceed 10 per second"})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/alert", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response AlertResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, `sum(rate(http_errors_total[5m])) > 10`, response.Rule.Expr)
+	assert.Contains(t, response.YAML, "alert: ")
+}
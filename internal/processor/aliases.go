@@ -0,0 +1,111 @@
+package processor
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Metric aliases map the friendly names users say ("requests") to canonical
+// metric names ("http_requests_total"). The intent classifier resolves aliases
+// in the query, the prompt tells the LLM the canonical names, and aliases the
+// LLM still uses as metric names are rewritten in the generated query.
+
+// metricAlias is an alias and the canonical metric it stands for
+type metricAlias struct {
+	alias     string
+	canonical string
+	pattern   *regexp.Regexp // matches the alias as a phrase in a query
+	selector  *regexp.Regexp // matches the alias used as a metric in PromQL; nil if not a valid metric name
+}
+
+// metricIdentifierPattern matches strings that are valid PromQL metric names
+var metricIdentifierPattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// SetMetricAliases sets the user-facing aliases for metric names, keyed by
+// alias. Aliases are matched case-insensitively as whole words.
+func (qp *QueryProcessor) SetMetricAliases(aliases map[string]string) {
+	qp.intentClassifier.SetMetricAliases(aliases)
+}
+
+// SetMetricAliases sets the aliases resolved to canonical metric names
+func (ic *IntentClassifier) SetMetricAliases(aliases map[string]string) {
+	ic.aliases = nil
+	for alias, canonical := range aliases {
+		alias = strings.ToLower(strings.TrimSpace(alias))
+		canonical = strings.TrimSpace(canonical)
+		if alias == "" || canonical == "" || alias == strings.ToLower(canonical) {
+			continue
+		}
+		quoted := regexp.QuoteMeta(alias)
+		a := metricAlias{
+			alias:     alias,
+			canonical: canonical,
+			pattern:   regexp.MustCompile(`(?i)\b` + quoted + `\b`),
+		}
+		if metricIdentifierPattern.MatchString(alias) {
+			// Not followed by "(" (a function) or a label matcher operator
+			a.selector = regexp.MustCompile(`(^|[^a-zA-Z0-9_:"])` + quoted + `(\s*[^a-zA-Z0-9_:"=!~(\s]|\s*$)`)
+		}
+		ic.aliases = append(ic.aliases, a)
+	}
+	// Longer aliases first, so "error requests" wins over "requests"
+	sort.Slice(ic.aliases, func(i, j int) bool {
+		if len(ic.aliases[i].alias) != len(ic.aliases[j].alias) {
+			return len(ic.aliases[i].alias) > len(ic.aliases[j].alias)
+		}
+		return ic.aliases[i].alias < ic.aliases[j].alias
+	})
+}
+
+// resolveAliases returns the canonical metric for each alias named in query,
+// keyed by alias, and the canonical metric of the first one found
+func (ic *IntentClassifier) resolveAliases(query string) (map[string]string, string) {
+	var resolved map[string]string
+	var matched [][]int
+	first, firstIndex := "", -1
+	for _, a := range ic.aliases {
+		loc := a.pattern.FindStringIndex(query)
+		if loc == nil || overlapsAny(loc, matched) {
+			continue
+		}
+		matched = append(matched, loc)
+		if resolved == nil {
+			resolved = make(map[string]string)
+		}
+		resolved[a.alias] = a.canonical
+		if firstIndex == -1 || loc[0] < firstIndex {
+			first, firstIndex = a.canonical, loc[0]
+		}
+	}
+	return resolved, first
+}
+
+// overlapsAny reports whether the span loc overlaps any of spans
+func overlapsAny(loc []int, spans [][]int) bool {
+	for _, span := range spans {
+		if loc[0] < span[1] && span[0] < loc[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalizeAliases rewrites aliases the generated query uses as metric
+// names to their canonical metric names
+func (ic *IntentClassifier) canonicalizeAliases(promql string) string {
+	for _, a := range ic.aliases {
+		if a.selector == nil {
+			continue
+		}
+		// Matches consume their delimiters, so repeat for adjacent uses
+		for {
+			rewritten := a.selector.ReplaceAllString(promql, "${1}"+a.canonical+"${2}")
+			if rewritten == promql {
+				break
+			}
+			promql = rewritten
+		}
+	}
+	return promql
+}
@@ -0,0 +1,137 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMetricAliases = map[string]string{
+	"requests":       "http_requests_total",
+	"error requests": "http_errors_total",
+	"Latency":        "http_request_duration_seconds_bucket",
+}
+
+// TestClassifyIntentMetricAliases tests that aliases in a query resolve to canonical metric names
+func TestClassifyIntentMetricAliases(t *testing.T) {
+	ic := NewIntentClassifier()
+	ic.SetMetricAliases(testMetricAliases)
+
+	tests := []struct {
+		name    string
+		query   string
+		metric  string
+		aliases map[string]string
+	}{
+		{
+			name:    "alias replaces inferred metric type",
+			query:   "show requests over the last 5 minutes",
+			metric:  "http_requests_total",
+			aliases: map[string]string{"requests": "http_requests_total"},
+		},
+		{
+			name:    "longest overlapping alias wins",
+			query:   "graph error requests",
+			metric:  "http_errors_total",
+			aliases: map[string]string{"error requests": "http_errors_total"},
+		},
+		{
+			name:    "aliases match case-insensitively",
+			query:   "Show LATENCY",
+			metric:  "http_request_duration_seconds_bucket",
+			aliases: map[string]string{"latency": "http_request_duration_seconds_bucket"},
+		},
+		{
+			name:    "explicit metric name takes precedence",
+			query:   "requests from grpc_server_handled_total",
+			metric:  "grpc_server_handled_total",
+			aliases: map[string]string{"requests": "http_requests_total"},
+		},
+		{
+			name:   "no alias",
+			query:  "show cpu usage",
+			metric: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			intent, err := ic.ClassifyIntent(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.metric, intent.Metric)
+			assert.Equal(t, tt.aliases, intent.Aliases)
+		})
+	}
+}
+
+// TestCanonicalizeAliases tests that aliases used as metric names in PromQL are rewritten
+func TestCanonicalizeAliases(t *testing.T) {
+	ic := NewIntentClassifier()
+	ic.SetMetricAliases(testMetricAliases)
+
+	tests := []struct {
+		promql   string
+		expected string
+	}{
+		{`sum(rate(requests[5m]))`, `sum(rate(http_requests_total[5m]))`},
+		{`requests{job="api"}`, `http_requests_total{job="api"}`},
+		{`requests`, `http_requests_total`},
+		{`requests / requests`, `http_requests_total / http_requests_total`},
+		{`sum by (job) (rate(requests[5m]))`, `sum by (job) (rate(http_requests_total[5m]))`},
+		{`rate(http_requests_total[5m])`, `rate(http_requests_total[5m])`},
+		{`up{requests="high"}`, `up{requests="high"}`},
+		{`up{job="requests"}`, `up{job="requests"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.promql, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ic.canonicalizeAliases(tt.promql))
+		})
+	}
+}
+
+// TestProcessQueryMetricAliases tests that an aliased term reaches the prompt and generated query as the canonical metric
+func TestProcessQueryMetricAliases(t *testing.T) {
+	t.Run("prompt and generated query", func(t *testing.T) {
+		llmClient := &promptRecordingLLMClient{MockLLMClient: MockLLMClient{
+			response: &llm.Response{PromQL: `sum by (service) (rate(requests[5m]))`, Confidence: 0.9},
+		}}
+		mapper := &MockSemanticMapper{services: []semantic.Service{
+			{ID: "svc-1", Name: "checkout", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+		}}
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, mapper, cache)
+		qp.SetMetricAliases(testMetricAliases)
+
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show requests for service checkout"})
+		require.NoError(t, err)
+
+		require.Len(t, llmClient.prompts, 1)
+		assert.Contains(t, llmClient.prompts[0], `"requests" means http_requests_total`)
+		assert.Equal(t, `sum by (service) (rate(http_requests_total[5m]))`, response.PromQL)
+	})
+
+	t.Run("direct query", func(t *testing.T) {
+		llmClient := &promptRecordingLLMClient{MockLLMClient: MockLLMClient{
+			response: &llm.Response{PromQL: `up`, Confidence: 0.9},
+		}}
+		mapper := &MockSemanticMapper{services: []semantic.Service{
+			{ID: "svc-1", Name: "checkout", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+		}}
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, mapper, cache)
+		qp.SetMetricAliases(testMetricAliases)
+
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show requests"})
+		require.NoError(t, err)
+
+		assert.Empty(t, llmClient.prompts, "an alias of a catalog metric is built without the LLM")
+		assert.Equal(t, `rate(http_requests_total[5m])`, response.PromQL)
+	})
+}
@@ -0,0 +1,194 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+)
+
+const (
+	defaultBatchQueryTimeout = 15 * time.Second
+	defaultBatchTimeout      = 30 * time.Second
+	maxBatchSize             = 20
+)
+
+// Batch item statuses
+const (
+	BatchStatusSuccess  = "success"
+	BatchStatusError    = "error"
+	BatchStatusTimedOut = "timed_out"
+)
+
+// BatchQueryRequest represents several natural language queries processed together
+type BatchQueryRequest struct {
+	Queries []QueryRequest `json:"queries" binding:"required"`
+}
+
+// BatchItemResult is the outcome of a single query within a batch
+type BatchItemResult struct {
+	Index      int            `json:"index"`
+	Query      string         `json:"query"`
+	Status     string         `json:"status"`
+	Response   *QueryResponse `json:"response,omitempty"`
+	Error      gin.H          `json:"error,omitempty"`
+	DurationMs int64          `json:"duration_ms"`
+
+	err error // The error behind Error, for redaction by the handler
+}
+
+// BatchQueryResponse holds the results of a batch, in request order
+type BatchQueryResponse struct {
+	Results    []BatchItemResult `json:"results"`
+	Partial    bool              `json:"partial"`
+	DurationMs int64             `json:"duration_ms"`
+}
+
+// SetBatchTimeouts configures the per-query and overall batch deadlines
+func (qp *QueryProcessor) SetBatchTimeouts(perQuery, overall time.Duration) {
+	qp.batchQueryTimeout = perQuery
+	qp.batchTimeout = overall
+}
+
+// ProcessBatch processes queries concurrently. Each query is bounded by the
+// per-query timeout; when the overall deadline is reached the results gathered so
+// far are returned and unfinished queries are marked as timed out.
+func (qp *QueryProcessor) ProcessBatch(ctx context.Context, req *BatchQueryRequest) *BatchQueryResponse {
+	start := time.Now()
+
+	perQuery := qp.batchQueryTimeout
+	if perQuery <= 0 {
+		perQuery = defaultBatchQueryTimeout
+	}
+	overall := qp.batchTimeout
+	if overall <= 0 {
+		overall = defaultBatchTimeout
+	}
+
+	batchCtx, cancel := context.WithTimeout(ctx, overall)
+	defer cancel()
+
+	results := make([]BatchItemResult, len(req.Queries))
+	for i := range req.Queries {
+		results[i] = BatchItemResult{
+			Index:  i,
+			Query:  req.Queries[i].Query,
+			Status: BatchStatusTimedOut,
+		}
+	}
+
+	done := make(chan BatchItemResult, len(req.Queries))
+	for i := range req.Queries {
+		go func(index int, query QueryRequest) {
+			done <- qp.processBatchItem(batchCtx, index, &query, perQuery)
+		}(i, req.Queries[i])
+	}
+
+	remaining := len(req.Queries)
+	for remaining > 0 {
+		select {
+		case result := <-done:
+			results[result.Index] = result
+			remaining--
+		case <-batchCtx.Done():
+			elapsed := time.Since(start).Milliseconds()
+			for i := range results {
+				if results[i].Status == BatchStatusTimedOut {
+					results[i].DurationMs = elapsed
+				}
+			}
+			qp.logger.Warn(ctx, "Batch deadline reached, returning partial results", map[string]interface{}{
+				"total":      len(req.Queries),
+				"unfinished": remaining,
+				"timeout_ms": overall.Milliseconds(),
+			})
+			return &BatchQueryResponse{
+				Results:    results,
+				Partial:    true,
+				DurationMs: elapsed,
+			}
+		}
+	}
+
+	return &BatchQueryResponse{
+		Results:    results,
+		Partial:    false,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+}
+
+// processBatchItem runs a single batch query, giving up once its timeout expires
+func (qp *QueryProcessor) processBatchItem(ctx context.Context, index int, req *QueryRequest, timeout time.Duration) BatchItemResult {
+	start := time.Now()
+	if err := qp.checkQueryFingerprint(req.Query); err != nil {
+		return BatchItemResult{
+			Index:  index,
+			Query:  req.Query,
+			Status: BatchStatusError,
+			Error:  formatErrorResponse(err)["error"].(gin.H),
+			err:    err,
+		}
+	}
+	itemCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		response *QueryResponse
+		err      error
+	}
+	finished := make(chan outcome, 1)
+	go func() {
+		response, err := qp.ProcessQuery(itemCtx, req)
+		finished <- outcome{response: response, err: err}
+	}()
+
+	result := BatchItemResult{Index: index, Query: req.Query}
+	select {
+	case out := <-finished:
+		if out.err != nil {
+			result.Status = BatchStatusError
+			if itemCtx.Err() == context.DeadlineExceeded {
+				result.Status = BatchStatusTimedOut
+			}
+			result.Error = formatErrorResponse(out.err)["error"].(gin.H)
+			result.err = out.err
+		} else {
+			result.Status = BatchStatusSuccess
+			result.Response = out.response
+		}
+	case <-itemCtx.Done():
+		result.Status = BatchStatusTimedOut
+	}
+
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result
+}
+
+// handleBatchQuery processes several natural language queries in one request
+func (qp *QueryProcessor) handleBatchQuery(c *gin.Context) {
+	var req BatchQueryRequest
+	if err := qp.bindJSON(c, &req); err != nil {
+		enhancedErr := errors.NewRequestBodyError(err)
+		c.JSON(http.StatusBadRequest, formatErrorResponse(enhancedErr))
+		return
+	}
+
+	if len(req.Queries) == 0 || len(req.Queries) > maxBatchSize {
+		enhancedErr := errors.NewInvalidInputError("queries", fmt.Sprintf("batch must contain between 1 and %d queries", maxBatchSize))
+		c.JSON(http.StatusBadRequest, formatErrorResponse(enhancedErr))
+		return
+	}
+
+	response := qp.ProcessBatch(c.Request.Context(), &req)
+	for i := range response.Results {
+		result := &response.Results[i]
+		result.Response = qp.redactResponse(c, result.Response)
+		if result.err != nil {
+			result.Error = formatErrorResponse(qp.redactError(c, result.err))["error"].(gin.H)
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,111 @@
+package processor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowLLMClient delays generation for prompts containing a marker, giving up
+// when the context ends so timed out queries do not outlive their test
+type slowLLMClient struct {
+	MockLLMClient
+	slowMarker string
+	delay      time.Duration
+}
+
+func (s *slowLLMClient) GenerateQuery(ctx context.Context, prompt string) (*llm.Response, error) {
+	if strings.Contains(prompt, s.slowMarker) {
+		select {
+		case <-time.After(s.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return s.MockLLMClient.GenerateQuery(ctx, prompt)
+}
+
+// TestProcessBatchPerQueryTimeout tests that a slow query times out without blocking the rest
+func TestProcessBatchPerQueryTimeout(t *testing.T) {
+	llmClient := &slowLLMClient{
+		MockLLMClient: MockLLMClient{response: &llm.Response{PromQL: `rate(http_requests_total[5m])`, Explanation: "Request rate", Confidence: 0.9}},
+		slowMarker:    "slow query",
+		delay:         2 * time.Second,
+	}
+	mapper := &MockSemanticMapper{services: []semantic.Service{
+		{ID: "svc-1", Name: "api", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+	}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, mapper, cache)
+	qp.SetBatchTimeouts(300*time.Millisecond, 5*time.Second)
+
+	start := time.Now()
+	response := qp.ProcessBatch(context.Background(), &BatchQueryRequest{
+		Queries: []QueryRequest{
+			{Query: "request rate for api"},
+			{Query: "slow query for api"},
+			{Query: "error rate for api"},
+			{Query: "latency for api"},
+		},
+	})
+	elapsed := time.Since(start)
+
+	require.Len(t, response.Results, 4)
+	assert.False(t, response.Partial)
+	assert.Less(t, elapsed, time.Second)
+
+	for i, result := range response.Results {
+		assert.Equal(t, i, result.Index)
+		if result.Query == "slow query for api" {
+			assert.Equal(t, BatchStatusTimedOut, result.Status)
+			assert.Nil(t, result.Response)
+			assert.GreaterOrEqual(t, result.DurationMs, int64(300))
+			continue
+		}
+		assert.Equal(t, BatchStatusSuccess, result.Status, "query %q", result.Query)
+		require.NotNil(t, result.Response)
+		assert.Equal(t, `rate(http_requests_total[5m])`, result.Response.PromQL)
+	}
+}
+
+// TestProcessBatchOverallDeadline tests that partial results are returned at the batch deadline
+func TestProcessBatchOverallDeadline(t *testing.T) {
+	llmClient := &slowLLMClient{
+		MockLLMClient: MockLLMClient{response: &llm.Response{PromQL: `rate(http_requests_total[5m])`, Explanation: "Request rate", Confidence: 0.9}},
+		slowMarker:    "slow query",
+		delay:         2 * time.Second,
+	}
+	mapper := &MockSemanticMapper{services: []semantic.Service{
+		{ID: "svc-1", Name: "api", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+	}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, mapper, cache)
+	qp.SetBatchTimeouts(5*time.Second, 400*time.Millisecond)
+
+	start := time.Now()
+	response := qp.ProcessBatch(context.Background(), &BatchQueryRequest{
+		Queries: []QueryRequest{
+			{Query: "request rate for api"},
+			{Query: "slow query for api"},
+			{Query: "error rate for api"},
+		},
+	})
+
+	assert.Less(t, time.Since(start), time.Second)
+	require.Len(t, response.Results, 3)
+	assert.True(t, response.Partial)
+
+	assert.Equal(t, BatchStatusSuccess, response.Results[0].Status)
+	assert.Equal(t, BatchStatusTimedOut, response.Results[1].Status)
+	assert.Equal(t, "slow query for api", response.Results[1].Query)
+	assert.Greater(t, response.Results[1].DurationMs, int64(0))
+	assert.Equal(t, BatchStatusSuccess, response.Results[2].Status)
+}
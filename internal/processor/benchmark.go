@@ -0,0 +1,172 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+)
+
+// maxBenchmarkCases bounds the cases of one benchmark run, each of which may
+// cost an LLM call
+const maxBenchmarkCases = 200
+
+// BenchmarkCase is a natural language query and the PromQL it should produce
+type BenchmarkCase struct {
+	Name           string            `json:"name,omitempty"`
+	Query          string            `json:"query"`
+	ExpectedPromQL string            `json:"expected_promql"`
+	Context        map[string]string `json:"context,omitempty"`
+}
+
+// BenchmarkRequest is an evaluation set run by the benchmark endpoint
+type BenchmarkRequest struct {
+	Cases []BenchmarkCase `json:"cases"`
+}
+
+// BenchmarkResult reports how a benchmark case fared. Expected and Actual are
+// canonicalized, so they differ only in ways that change the query.
+type BenchmarkResult struct {
+	Name     string `json:"name,omitempty"`
+	Query    string `json:"query"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual,omitempty"`
+	Passed   bool   `json:"passed"`
+	Error    string `json:"error,omitempty"` // Set when no query was generated
+	CacheHit bool   `json:"cache_hit,omitempty"`
+
+	// Missing and Unexpected are the PromQL tokens of a mismatch only found
+	// in the expected and the generated query respectively
+	Missing    []string `json:"missing,omitempty"`
+	Unexpected []string `json:"unexpected,omitempty"`
+}
+
+// BenchmarkReport tallies a benchmark run
+type BenchmarkReport struct {
+	Total    int               `json:"total"`
+	Passed   int               `json:"passed"`
+	Failed   int               `json:"failed"` // Mismatches and errors
+	Errors   int               `json:"errors"`
+	Accuracy float64           `json:"accuracy"` // Passed / Total
+	Results  []BenchmarkResult `json:"results"`
+}
+
+// RunBenchmark generates the query of each case at temperature 0 and compares
+// it with the expected PromQL after canonicalizing both, so differences in
+// whitespace or label order do not count as mismatches. Cases run one at a
+// time through ProcessQuery, so cached queries are reused.
+func (qp *QueryProcessor) RunBenchmark(ctx context.Context, cases []BenchmarkCase) *BenchmarkReport {
+	report := &BenchmarkReport{
+		Total:   len(cases),
+		Results: make([]BenchmarkResult, 0, len(cases)),
+	}
+
+	for _, tc := range cases {
+		result := BenchmarkResult{
+			Name:     tc.Name,
+			Query:    tc.Query,
+			Expected: canonicalizePromQL(tc.ExpectedPromQL),
+		}
+
+		temperature := 0.0
+		response, err := qp.ProcessQuery(ctx, &QueryRequest{Query: tc.Query, Context: tc.Context, Temperature: &temperature})
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+			report.Errors++
+		case response.PromQL == "":
+			result.Error = "no query was generated"
+			report.Errors++
+		default:
+			result.Actual = canonicalizePromQL(response.PromQL)
+			result.CacheHit = response.CacheHit
+			result.Passed = result.Actual == result.Expected
+			if !result.Passed {
+				result.Missing, result.Unexpected = promqlTokenDiff(result.Expected, result.Actual)
+			}
+		}
+
+		if result.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	if report.Total > 0 {
+		report.Accuracy = float64(report.Passed) / float64(report.Total)
+	}
+
+	qp.logger.Info(ctx, "Benchmark completed", map[string]interface{}{
+		"total":    report.Total,
+		"passed":   report.Passed,
+		"errors":   report.Errors,
+		"accuracy": report.Accuracy,
+	})
+	return report
+}
+
+// promqlTokenDiff returns the tokens of expected missing from actual and
+// those of actual not in expected, counting repeated tokens
+func promqlTokenDiff(expected, actual string) (missing, unexpected []string) {
+	counts := make(map[string]int)
+	for _, token := range promqlTokenTexts(actual) {
+		counts[token]++
+	}
+	for _, token := range promqlTokenTexts(expected) {
+		if counts[token] > 0 {
+			counts[token]--
+			continue
+		}
+		missing = append(missing, token)
+	}
+	for _, token := range promqlTokenTexts(actual) {
+		if counts[token] > 0 {
+			counts[token]--
+			unexpected = append(unexpected, token)
+		}
+	}
+	return missing, unexpected
+}
+
+// promqlTokenTexts returns the tokens of a query, or the whole query when it
+// cannot be tokenized
+func promqlTokenTexts(promql string) []string {
+	tokens, err := tokenizePromQL(promql)
+	if err != nil {
+		return []string{promql}
+	}
+	texts := make([]string, len(tokens))
+	for i, token := range tokens {
+		texts[i] = token.text
+	}
+	return texts
+}
+
+// handleBenchmark runs an evaluation set and reports its accuracy (admin only)
+func (qp *QueryProcessor) handleBenchmark(c *gin.Context) {
+	var req BenchmarkRequest
+	if err := qp.bindJSON(c, &req); err != nil {
+		enhancedErr := errors.NewRequestBodyError(err)
+		c.JSON(http.StatusBadRequest, formatErrorResponse(enhancedErr))
+		return
+	}
+
+	if len(req.Cases) == 0 || len(req.Cases) > maxBenchmarkCases {
+		enhancedErr := errors.NewInvalidInputError("cases", fmt.Sprintf("benchmark must contain between 1 and %d cases", maxBenchmarkCases))
+		c.JSON(http.StatusBadRequest, formatErrorResponse(enhancedErr))
+		return
+	}
+	for i, tc := range req.Cases {
+		if tc.Query == "" || tc.ExpectedPromQL == "" {
+			enhancedErr := errors.NewInvalidInputError(fmt.Sprintf("cases[%d]", i), "query and expected_promql are required")
+			c.JSON(http.StatusBadRequest, formatErrorResponse(enhancedErr))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, qp.RunBenchmark(c.Request.Context(), req.Cases))
+}
@@ -0,0 +1,99 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// benchmarkLLMClient answers prompts containing a query with its PromQL and
+// records the requested temperatures
+type benchmarkLLMClient struct {
+	MockLLMClient
+	answers      map[string]string
+	temperatures []float64
+}
+
+func (m *benchmarkLLMClient) GenerateQueryWithOptions(ctx context.Context, prompt string, opts llm.GenerateOptions) (*llm.Response, error) {
+	m.temperatures = append(m.temperatures, *opts.Temperature)
+	for query, promql := range m.answers {
+		if strings.Contains(prompt, query) {
+			return &llm.Response{PromQL: promql, Confidence: 0.9}, nil
+		}
+	}
+	return nil, fmt.Errorf("model unavailable")
+}
+
+// TestRunBenchmark tests that a benchmark tallies canonical matches,
+// mismatches and errors, and generates queries at temperature 0
+func TestRunBenchmark(t *testing.T) {
+	llmClient := &benchmarkLLMClient{answers: map[string]string{
+		"checkout request rate": `sum(rate(http_requests_total{service="checkout",code="200"}[5m]))`,
+		"checkout error rate":   `sum(rate(http_requests_total{service="checkout",status="500"}[5m]))`,
+	}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+
+	report := qp.RunBenchmark(context.Background(), []BenchmarkCase{
+		// Matches once label order and whitespace are canonicalized
+		{Name: "rate", Query: "checkout request rate", ExpectedPromQL: `sum(rate(http_requests_total{code="200", service="checkout"} [5m]))`},
+		{Name: "errors", Query: "checkout error rate", ExpectedPromQL: `sum(rate(http_requests_total{service="checkout",status=~"5.."}[5m]))`},
+		{Name: "disk", Query: "disk usage", ExpectedPromQL: `node_filesystem_avail_bytes`},
+	})
+
+	assert.Equal(t, 3, report.Total)
+	assert.Equal(t, 1, report.Passed)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, 1, report.Errors)
+	assert.InDelta(t, 1.0/3, report.Accuracy, 1e-9)
+	require.Len(t, report.Results, 3)
+
+	passed := report.Results[0]
+	assert.True(t, passed.Passed)
+	assert.Equal(t, passed.Expected, passed.Actual)
+	assert.Empty(t, passed.Missing)
+
+	mismatch := report.Results[1]
+	assert.False(t, mismatch.Passed)
+	assert.Equal(t, `sum(rate(http_requests_total{service="checkout", status="500"}[5m]))`, mismatch.Actual)
+	assert.Equal(t, []string{"=~", `"5.."`}, mismatch.Missing)
+	assert.Equal(t, []string{"=", `"500"`}, mismatch.Unexpected)
+	assert.Empty(t, mismatch.Error)
+
+	failed := report.Results[2]
+	assert.False(t, failed.Passed)
+	assert.NotEmpty(t, failed.Error)
+	assert.Empty(t, failed.Actual)
+
+	assert.Equal(t, []float64{0, 0, 0}, llmClient.temperatures)
+}
+
+// TestHandleBenchmarkValidation tests that empty cases and cases without a
+// query or expected PromQL are rejected
+func TestHandleBenchmarkValidation(t *testing.T) {
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(&benchmarkLLMClient{}, &MockSemanticMapper{}, cache)
+	r := gin.New()
+	r.POST("/benchmark", qp.handleBenchmark)
+
+	for _, body := range []string{
+		`{}`,
+		`{"cases": []}`,
+		`{"cases": [{"query": "request rate"}]}`,
+		`{"cases": [{"expected_promql": "up"}]}`,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/benchmark", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
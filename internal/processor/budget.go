@@ -0,0 +1,75 @@
+package processor
+
+import (
+	"path"
+	"sort"
+
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+)
+
+// defaultContextWindow is the context window, in tokens, assumed for models
+// without a configured one. It is deliberately small so prompts for unknown
+// models are trimmed rather than truncated.
+const defaultContextWindow = 16384
+
+// modelContextWindow is the context window of the models matching a glob
+type modelContextWindow struct {
+	glob   string
+	tokens int
+}
+
+// SetModelContextWindows sets the context window, in tokens, of the models
+// matching each glob, e.g. "claude-3-5-haiku-*". Exact model names win over
+// globs, and longer globs over shorter ones. Models matching no glob use
+// fallback; a non-positive fallback keeps the default. Invalid globs and
+// non-positive windows are ignored.
+func (qp *QueryProcessor) SetModelContextWindows(windows map[string]int, fallback int) {
+	qp.contextWindows = nil
+	for glob, tokens := range windows {
+		if _, err := path.Match(glob, ""); err != nil || tokens <= 0 {
+			continue
+		}
+		qp.contextWindows = append(qp.contextWindows, modelContextWindow{glob: glob, tokens: tokens})
+	}
+	sort.Slice(qp.contextWindows, func(i, j int) bool {
+		a, b := qp.contextWindows[i].glob, qp.contextWindows[j].glob
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return a < b
+	})
+
+	qp.defaultContextWindow = fallback
+}
+
+// contextWindow returns the context window of a model in tokens
+func (qp *QueryProcessor) contextWindow(model string) int {
+	for _, window := range qp.contextWindows {
+		if window.glob == model {
+			return window.tokens
+		}
+	}
+	for _, window := range qp.contextWindows {
+		if matched, _ := path.Match(window.glob, model); matched {
+			return window.tokens
+		}
+	}
+	if qp.defaultContextWindow > 0 {
+		return qp.defaultContextWindow
+	}
+	return defaultContextWindow
+}
+
+// promptBudget returns the prompt size, in estimated tokens, that fits the
+// model's context window alongside the longest answer requested of it
+func (qp *QueryProcessor) promptBudget(model string) int {
+	return qp.contextWindow(model) - llm.MaxTokens
+}
+
+// requestModel returns the model that generates the request's query
+func (qp *QueryProcessor) requestModel(req *QueryRequest) string {
+	if req.Model != "" {
+		return req.Model
+	}
+	return qp.defaultModel
+}
@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPromptBudget tests that the prompt budget follows the context window of
+// the model generating the query
+func TestPromptBudget(t *testing.T) {
+	services := make([]semantic.Service, 20)
+	for i := range services {
+		name := fmt.Sprintf("service-%02d", i)
+		services[i] = semantic.Service{
+			ID: name, Name: name, Namespace: "default",
+			MetricNames: []string{name + "_requests_total", name + "_errors_total", name + "_queue_size"},
+		}
+	}
+	mapper := &MockSemanticMapper{services: services}
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(service_07_requests_total{service="service-07"}[5m]))`, Confidence: 0.8}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, mapper, cache)
+	qp.SetModels("claude-3-5-haiku-20241022", []string{"small-model"})
+	qp.SetModelContextWindows(map[string]int{
+		"claude-*":                  200000,
+		"claude-3-5-haiku-20241022": 100000,
+		"small-model":               llm.MaxTokens + 500,
+	}, 8000)
+
+	t.Run("budget matches the configured context window", func(t *testing.T) {
+		assert.Equal(t, 200000-llm.MaxTokens, qp.promptBudget("claude-3-5-sonnet-20241022"))
+		assert.Equal(t, 100000-llm.MaxTokens, qp.promptBudget("claude-3-5-haiku-20241022"), "exact names win over globs")
+		assert.Equal(t, 8000-llm.MaxTokens, qp.promptBudget("other-model"), "unknown models use the fallback")
+
+		// The default model generates the query
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate for service-07", Debug: true})
+		require.NoError(t, err)
+		telemetry := response.Metadata["telemetry"].(*QueryTelemetry)
+		assert.Equal(t, 100000-llm.MaxTokens, telemetry.PromptTokenBudget)
+	})
+
+	t.Run("unknown models fall back to a conservative default", func(t *testing.T) {
+		fresh := NewQueryProcessor(llmClient, mapper, cache)
+		assert.Equal(t, defaultContextWindow-llm.MaxTokens, fresh.promptBudget("any-model"))
+	})
+
+	t.Run("catalog is trimmed to fit a small context window", func(t *testing.T) {
+		intent := &QueryIntent{Service: "service-07"}
+
+		large, err := qp.buildPrompt(context.Background(), &QueryRequest{Query: "request rate"}, intent, nil)
+		require.NoError(t, err)
+		assert.Equal(t, len(services), strings.Count(large, "\nService: "))
+
+		small, err := qp.buildPrompt(context.Background(), &QueryRequest{Query: "request rate", Model: "small-model"}, intent, nil)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, estimatePromptTokens(small), qp.promptBudget("small-model"))
+		assert.Less(t, strings.Count(small, "\nService: "), len(services))
+		assert.Contains(t, small, "\nService: service-07", "the targeted service is kept")
+		assert.Contains(t, small, "more services omitted as less relevant")
+	})
+}
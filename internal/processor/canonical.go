@@ -0,0 +1,227 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+)
+
+// promqlToken is a lexical token produced by tokenizePromQL
+type promqlToken struct {
+	text string
+	kind tokenKind
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota // identifiers, keywords, numbers and durations
+	tokenString
+	tokenOperator
+	tokenPunct // ( ) [ ] { } ,
+)
+
+// groupingKeywords introduce label lists whose order has no semantic meaning
+var groupingKeywords = map[string]bool{
+	"by":       true,
+	"without":  true,
+	"on":       true,
+	"ignoring": true,
+}
+
+// canonicalizePromQL returns a stable representation of a PromQL query so that
+// equivalent queries differing only in whitespace or label order compare equal.
+// Label matchers inside selectors and label lists in by/without/on/ignoring
+// clauses are sorted; string literals are preserved verbatim. Queries that
+// cannot be tokenized are returned unchanged.
+func canonicalizePromQL(promql string) string {
+	tokens, err := tokenizePromQL(promql)
+	if err != nil || len(tokens) == 0 {
+		return promql
+	}
+
+	items, _, err := renderTokens(tokens, "")
+	if err != nil || len(items) != 1 {
+		// A top-level comma is not valid PromQL
+		return promql
+	}
+
+	return items[0]
+}
+
+// dedupeSimilarQueries drops similar queries whose PromQL is equivalent to an
+// earlier (more similar) entry, so prompt examples are not repeated
+func dedupeSimilarQueries(queries []semantic.SimilarQuery) []semantic.SimilarQuery {
+	seen := make(map[string]bool, len(queries))
+	deduped := make([]semantic.SimilarQuery, 0, len(queries))
+	for _, sq := range queries {
+		key := canonicalizePromQL(sq.PromQL)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		deduped = append(deduped, sq)
+	}
+	return deduped
+}
+
+// tokenizePromQL splits a PromQL query into tokens, dropping whitespace
+func tokenizePromQL(promql string) ([]promqlToken, error) {
+	var tokens []promqlToken
+
+	for i := 0; i < len(promql); {
+		c := promql[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '"' || c == '\'' || c == '`':
+			end := i + 1
+			for end < len(promql) && promql[end] != c {
+				if promql[end] == '\\' && c != '`' {
+					end++
+				}
+				end++
+			}
+			if end >= len(promql) {
+				return nil, fmt.Errorf("unterminated string literal at position %d", i)
+			}
+			tokens = append(tokens, promqlToken{text: promql[i : end+1], kind: tokenString})
+			i = end + 1
+
+		case isWordChar(c):
+			end := i
+			for end < len(promql) && isWordChar(promql[end]) {
+				end++
+			}
+			tokens = append(tokens, promqlToken{text: promql[i:end], kind: tokenWord})
+			i = end
+
+		case strings.ContainsRune("(){}[],", rune(c)):
+			tokens = append(tokens, promqlToken{text: string(c), kind: tokenPunct})
+			i++
+
+		case c == '#':
+			// Comments run to the end of the line
+			for i < len(promql) && promql[i] != '\n' {
+				i++
+			}
+
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "=~", "!~", ">=", "<=", "+", "-", "*", "/", "%", "^", "=", ">", "<", "@"} {
+				if strings.HasPrefix(promql[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			tokens = append(tokens, promqlToken{text: op, kind: tokenOperator})
+			i += len(op)
+		}
+	}
+
+	return tokens, nil
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == ':' || c == '.' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// renderTokens renders tokens until the closing bracket matching open is found
+// (or the end of input when open is empty). The output is split into items at
+// top-level commas; the remaining tokens after the closing bracket are returned.
+func renderTokens(tokens []promqlToken, open string) ([]string, []promqlToken, error) {
+	var items []string
+	var sb strings.Builder
+	var prev *promqlToken
+
+	flush := func() {
+		if item := strings.TrimSpace(sb.String()); item != "" {
+			items = append(items, item)
+		}
+		sb.Reset()
+	}
+
+	for len(tokens) > 0 {
+		tok := tokens[0]
+		tokens = tokens[1:]
+
+		switch tok.text {
+		case ")", "]", "}":
+			if closingFor(open) != tok.text {
+				return nil, nil, fmt.Errorf("unbalanced %q", tok.text)
+			}
+			flush()
+			return items, tokens, nil
+
+		case "(", "[", "{":
+			inner, rest, err := renderTokens(tokens, tok.text)
+			if err != nil {
+				return nil, nil, err
+			}
+			tokens = rest
+
+			// Label matchers and grouping label lists are order-insensitive
+			grouping := tok.text == "(" && prev != nil && groupingKeywords[prev.text]
+			if tok.text == "{" || grouping {
+				sort.Strings(inner)
+			}
+			if grouping {
+				sb.WriteString(" ")
+			}
+			sb.WriteString(tok.text + strings.Join(inner, ", ") + closingFor(tok.text))
+
+			closing := promqlToken{text: closingFor(tok.text), kind: tokenPunct}
+			prev = &closing
+			continue
+
+		case ",":
+			flush()
+
+		default:
+			switch {
+			case tok.kind == tokenOperator && open == "{":
+				// Label matcher operators are rendered without spaces
+				sb.WriteString(tok.text)
+			case tok.kind == tokenOperator:
+				sb.WriteString(" " + tok.text + " ")
+			case prev != nil && (prev.kind == tokenWord || isClosing(prev.text)) && tok.kind == tokenWord:
+				sb.WriteString(" " + tok.text)
+			default:
+				sb.WriteString(tok.text)
+			}
+		}
+
+		t := tok
+		prev = &t
+	}
+
+	if open != "" {
+		return nil, nil, fmt.Errorf("unclosed %q", open)
+	}
+	flush()
+	return items, tokens, nil
+}
+
+func closingFor(open string) string {
+	switch open {
+	case "(":
+		return ")"
+	case "[":
+		return "]"
+	case "{":
+		return "}"
+	}
+	return ""
+}
+
+func isClosing(text string) bool {
+	return text == ")" || text == "]" || text == "}"
+}
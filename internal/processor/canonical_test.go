@@ -0,0 +1,98 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCanonicalizePromQL tests that equivalent queries share a canonical form
+func TestCanonicalizePromQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants []string
+		expected string
+	}{
+		{
+			name: "whitespace variants",
+			variants: []string{
+				`rate(http_requests_total{service="api"}[5m])`,
+				`rate( http_requests_total{ service = "api" } [5m] )`,
+				"rate(\n  http_requests_total{service=\"api\"}[5m]\n)",
+			},
+			expected: `rate(http_requests_total{service="api"}[5m])`,
+		},
+		{
+			name: "label matcher order",
+			variants: []string{
+				`http_requests_total{service="api",status=~"5.."}`,
+				`http_requests_total{status=~"5..", service="api"}`,
+				`http_requests_total{status=~"5..",service="api",}`,
+			},
+			expected: `http_requests_total{service="api", status=~"5.."}`,
+		},
+		{
+			name: "grouping label order",
+			variants: []string{
+				`sum(rate(http_requests_total[5m])) by (service, instance)`,
+				`sum(rate(http_requests_total[5m]))by(instance,service)`,
+			},
+			expected: `sum(rate(http_requests_total[5m])) by (instance, service)`,
+		},
+		{
+			name: "binary operators",
+			variants: []string{
+				`sum(rate(errors_total[5m]))/sum(rate(requests_total[5m]))`,
+				`sum(rate(errors_total[5m]))  /  sum(rate(requests_total[5m]))`,
+			},
+			expected: `sum(rate(errors_total[5m])) / sum(rate(requests_total[5m]))`,
+		},
+		{
+			name: "string literals preserved",
+			variants: []string{
+				`up{job="a, b",env="prod"}`,
+				`up{ env="prod" , job="a, b" }`,
+			},
+			expected: `up{env="prod", job="a, b"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, variant := range tt.variants {
+				assert.Equal(t, tt.expected, canonicalizePromQL(variant), "variant: %s", variant)
+			}
+		})
+	}
+}
+
+// TestCanonicalizePromQLInvalidFallsBack tests that unparseable queries are returned unchanged
+func TestCanonicalizePromQLInvalidFallsBack(t *testing.T) {
+	invalid := []string{
+		`rate(http_requests_total[5m]`,
+		`up{job="api}`,
+		`sum(x))`,
+		`up{job="api"} ; drop`,
+		``,
+	}
+
+	for _, query := range invalid {
+		assert.Equal(t, query, canonicalizePromQL(query))
+	}
+}
+
+// TestDedupeSimilarQueries tests that equivalent PromQL examples are collapsed
+func TestDedupeSimilarQueries(t *testing.T) {
+	queries := []semantic.SimilarQuery{
+		{ID: "1", Query: "api errors", PromQL: `rate(errors_total{service="api",code="500"}[5m])`},
+		{ID: "2", Query: "errors for api", PromQL: `rate( errors_total{code="500", service="api"}[5m] )`},
+		{ID: "3", Query: "api latency", PromQL: `histogram_quantile(0.95, rate(latency_bucket[5m]))`},
+	}
+
+	deduped := dedupeSimilarQueries(queries)
+
+	assert.Len(t, deduped, 2)
+	assert.Equal(t, "1", deduped[0].ID)
+	assert.Equal(t, "3", deduped[1].ID)
+}
@@ -0,0 +1,107 @@
+package processor
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+)
+
+// defaultMaxPromptServices bounds the services listed in the prompt catalog
+const defaultMaxPromptServices = 50
+
+// SetMaxPromptServices bounds the number of services listed in the prompt
+// catalog; non-positive values keep the default
+func (qp *QueryProcessor) SetMaxPromptServices(max int) {
+	if max > 0 {
+		qp.maxPromptServices = max
+	}
+}
+
+// selectPromptServices returns at most max services for the prompt catalog,
+// most relevant first, and the number omitted. The targeted service is always
+// included. Other services rank by how well their name and metrics match the
+// query, and by whether similar past queries used their metrics.
+func selectPromptServices(services []semantic.Service, query string, intent *QueryIntent, similarQueries []semantic.SimilarQuery, max int) ([]semantic.Service, int) {
+	if max <= 0 || len(services) <= max {
+		return services, 0
+	}
+
+	terms := queryTerms(query)
+	scores := make([]int, len(services))
+	for i, service := range services {
+		scores[i] = serviceRelevance(service, terms, intent, similarQueries)
+	}
+
+	order := make([]int, len(services))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+
+	selected := make([]semantic.Service, 0, max)
+	for _, i := range order[:max] {
+		selected = append(selected, services[i])
+	}
+	return selected, len(services) - max
+}
+
+// targetedServiceScore ranks the service named in the query above any other
+const targetedServiceScore = 1 << 20
+
+// serviceRelevance scores how relevant a service is to the query
+func serviceRelevance(service semantic.Service, terms []string, intent *QueryIntent, similarQueries []semantic.SimilarQuery) int {
+	if intent.Service != "" && strings.EqualFold(service.Name, intent.Service) {
+		return targetedServiceScore
+	}
+
+	score := 0
+	name := strings.ToLower(service.Name)
+	for _, term := range terms {
+		if strings.Contains(name, term) {
+			score += 3
+		}
+		for _, metric := range service.MetricNames {
+			if strings.Contains(metric, term) {
+				score++
+				break
+			}
+		}
+	}
+	for _, metric := range service.MetricNames {
+		if intent.Metric != "" && metric == intent.Metric {
+			score += 5
+		}
+		for _, sq := range similarQueries {
+			if strings.Contains(sq.PromQL, metric) {
+				score += 2
+				break
+			}
+		}
+	}
+	return score
+}
+
+// queryStopWords are common query words that say nothing about which service
+// is meant
+var queryStopWords = map[string]bool{
+	"the": true, "for": true, "and": true, "show": true, "what": true, "with": true,
+	"from": true, "over": true, "last": true, "per": true, "are": true, "how": true,
+	"many": true, "all": true, "get": true, "give": true, "whats": true,
+}
+
+// queryTerms returns the lowercased words of a query that can identify a
+// service or metric, dropping short and common words
+func queryTerms(query string) []string {
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-')
+	}) {
+		if len(word) >= 3 && !queryStopWords[word] {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
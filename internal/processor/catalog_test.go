@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBuildPromptMaxServices tests that large catalogs are capped to the most relevant services
+func TestBuildPromptMaxServices(t *testing.T) {
+	var services []semantic.Service
+	for i := 0; i < 30; i++ {
+		services = append(services, semantic.Service{
+			ID:          fmt.Sprintf("svc-%d", i),
+			Name:        fmt.Sprintf("filler-%02d", i),
+			Namespace:   "default",
+			MetricNames: []string{fmt.Sprintf("filler_%02d_up", i)},
+		})
+	}
+	services = append(services,
+		semantic.Service{ID: "svc-payments", Name: "payments", Namespace: "default", MetricNames: []string{"payment_failures_total"}},
+		semantic.Service{ID: "svc-ledger", Name: "ledger", Namespace: "default", MetricNames: []string{"ledger_entries_total"}},
+		semantic.Service{ID: "svc-checkout", Name: "checkout", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+	)
+
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(&MockLLMClient{}, &MockSemanticMapper{services: services}, cache)
+	qp.SetMaxPromptServices(3)
+
+	similarQueries := []semantic.SimilarQuery{
+		{Query: "ledger write rate", PromQL: "sum(rate(ledger_entries_total[5m]))"},
+	}
+	prompt, err := qp.buildPrompt(context.Background(),
+		&QueryRequest{Query: "checkout payment failures"},
+		&QueryIntent{Type: "errors", Service: "checkout"},
+		similarQueries)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, strings.Count(prompt, "\nService: "))
+	assert.Contains(t, prompt, "Service: checkout", "the targeted service is always included")
+	assert.Contains(t, prompt, "Service: payments", "services matching the query are included")
+	assert.Contains(t, prompt, "Service: ledger", "services used by similar queries are included")
+	assert.NotContains(t, prompt, "Service: filler-")
+	assert.Contains(t, prompt, "... and 30 more services omitted")
+
+	// The targeted service is included even when nothing else matches
+	prompt, err = qp.buildPrompt(context.Background(),
+		&QueryRequest{Query: "latency"},
+		&QueryIntent{Type: "performance", Service: "checkout"},
+		nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(prompt, "\nService: "))
+	assert.Contains(t, prompt, "Service: checkout")
+}
+
+// TestSelectPromptServicesUnderCap tests that small catalogs are listed unchanged
+func TestSelectPromptServicesUnderCap(t *testing.T) {
+	services := []semantic.Service{{Name: "b"}, {Name: "a"}}
+	selected, omitted := selectPromptServices(services, "anything", &QueryIntent{}, nil, 5)
+	assert.Equal(t, services, selected)
+	assert.Equal(t, 0, omitted)
+}
@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/observability"
+)
+
+// inFlightWait is how long a query waits for a free slot before it is
+// rejected as overloaded
+const inFlightWait = 250 * time.Millisecond
+
+// overloadRetryAfterSeconds is the Retry-After advertised to rejected queries
+const overloadRetryAfterSeconds = 1
+
+// SetMaxInFlightQueries bounds the number of queries processed concurrently;
+// zero removes the bound. It must be called before serving requests.
+func (qp *QueryProcessor) SetMaxInFlightQueries(max int) {
+	if max <= 0 {
+		qp.inFlight = nil
+		return
+	}
+	qp.inFlight = make(chan struct{}, max)
+}
+
+// acquireQuerySlot waits up to inFlightWait for a query slot and returns the
+// function that releases it. It fails with an overloaded error when no slot
+// frees up in time, or with a cancelled or timeout error if the context is
+// done first.
+func (qp *QueryProcessor) acquireQuerySlot(ctx context.Context) (func(), error) {
+	if qp.inFlight == nil {
+		return func() {}, nil
+	}
+
+	release := func() { <-qp.inFlight }
+	select {
+	case qp.inFlight <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	timer := time.NewTimer(inFlightWait)
+	defer timer.Stop()
+
+	select {
+	case qp.inFlight <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, errors.NewRequestCancelledError(ctx.Err())
+	case <-timer.C:
+		observability.GetGlobalMetrics().Inc(observability.MetricQueryOverloaded, nil)
+		return nil, errors.NewOverloadedError(overloadRetryAfterSeconds)
+	}
+}
+
+// setRetryAfter sets the Retry-After header for errors that carry a retry delay
+func setRetryAfter(c *gin.Context, err error) {
+	enhancedErr, ok := err.(*errors.EnhancedError)
+	if !ok {
+		return
+	}
+	if seconds, ok := enhancedErr.Metadata["retry_after_seconds"].(int); ok {
+		c.Header("Retry-After", strconv.Itoa(seconds))
+	}
+}
@@ -0,0 +1,183 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingLLMClient holds every query generation until release is closed
+type blockingLLMClient struct {
+	MockLLMClient
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingLLMClient) GenerateQuery(ctx context.Context, prompt string) (*llm.Response, error) {
+	c.started <- struct{}{}
+	<-c.release
+	return c.MockLLMClient.GenerateQuery(ctx, prompt)
+}
+
+// TestMaxInFlightQueries tests that queries beyond the concurrency cap are rejected with 503
+func TestMaxInFlightQueries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const maxInFlight = 2
+	const total = 5
+
+	llmClient := &blockingLLMClient{
+		MockLLMClient: MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_requests_total[5m]))`, Confidence: 0.9}},
+		started:       make(chan struct{}, total),
+		release:       make(chan struct{}),
+	}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+	qp.SetMaxInFlightQueries(maxInFlight)
+	router := qp.SetupRoutes(nil)
+
+	results := make(chan *httptest.ResponseRecorder, total)
+	for i := 0; i < total; i++ {
+		go func(i int) {
+			body, _ := json.Marshal(QueryRequest{Query: fmt.Sprintf("show error rate %d", i)})
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/query", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			results <- w
+		}(i)
+	}
+
+	// The admitted queries reach the LLM and hold their slots
+	for i := 0; i < maxInFlight; i++ {
+		select {
+		case <-llmClient.started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("admitted queries did not start")
+		}
+	}
+
+	// The excess queries are rejected while the admitted ones are still running
+	for i := 0; i < total-maxInFlight; i++ {
+		select {
+		case w := <-results:
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+			assert.Equal(t, "1", w.Header().Get("Retry-After"))
+			assert.Contains(t, w.Body.String(), string(errors.ErrCodeOverloaded))
+		case <-time.After(5 * time.Second):
+			t.Fatal("excess queries were not rejected")
+		}
+	}
+	select {
+	case <-llmClient.started:
+		t.Fatal("a rejected query reached the LLM")
+	default:
+	}
+
+	close(llmClient.release)
+	for i := 0; i < maxInFlight; i++ {
+		w := <-results
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// Released slots admit new queries
+	release, err := qp.acquireQuerySlot(context.Background())
+	require.NoError(t, err)
+	release()
+}
+
+// TestMaxInFlightAlerts tests that alert generation takes a query slot and is
+// rejected with 503 when none is free
+func TestMaxInFlightAlerts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_requests_total{status=~"5.."}[5m]))`, Confidence: 0.9}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+	qp.SetMaxInFlightQueries(1)
+	router := qp.SetupRoutes(nil)
+
+	postAlert := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(AlertRequest{Query: "alert when error rate exceeds 5%"})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/alert", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	release, err := qp.acquireQuerySlot(context.Background())
+	require.NoError(t, err)
+
+	w := postAlert()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), string(errors.ErrCodeOverloaded))
+
+	release()
+	w = postAlert()
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+// TestAcquireQuerySlotContextCancelled tests that waiting for a slot stops
+// when the context is done, with an error naming why
+func TestAcquireQuerySlotContextCancelled(t *testing.T) {
+	qp := &QueryProcessor{}
+	qp.SetMaxInFlightQueries(1)
+
+	release, err := qp.acquireQuerySlot(context.Background())
+	require.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = qp.acquireQuerySlot(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, statusClientClosedRequest, getErrorStatusCode(err))
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = qp.acquireQuerySlot(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, http.StatusGatewayTimeout, getErrorStatusCode(err))
+}
+
+// TestCacheHitWithoutQuerySlot tests that cached queries are served while
+// every query slot is taken
+func TestCacheHitWithoutQuerySlot(t *testing.T) {
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_requests_total[5m]))`, Confidence: 0.9}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+	qp.SetMaxInFlightQueries(1)
+
+	_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show error rate"})
+	require.NoError(t, err)
+
+	release, err := qp.acquireQuerySlot(context.Background())
+	require.NoError(t, err)
+	defer release()
+
+	response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show error rate"})
+	require.NoError(t, err)
+	assert.True(t, response.CacheHit)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = qp.ProcessQuery(ctx, &QueryRequest{Query: "show latency"})
+	var enhancedErr *errors.EnhancedError
+	require.ErrorAs(t, err, &enhancedErr)
+	assert.Equal(t, errors.ErrCodeRequestCancelled, enhancedErr.Code)
+}
@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+)
+
+// confirmationTTL is how long a confirmation token remains valid
+const confirmationTTL = 10 * time.Minute
+
+// pendingConfirmation is the stored state behind a confirmation token
+type pendingConfirmation struct {
+	Query    string         `json:"query"`
+	Tenant   string         `json:"tenant,omitempty"`
+	Response *QueryResponse `json:"response"`
+}
+
+// SetConfirmCostThreshold sets the estimated cost above which generated queries
+// require confirmation before being accepted; zero disables confirmation
+func (qp *QueryProcessor) SetConfirmCostThreshold(threshold int) {
+	qp.confirmCostThreshold = threshold
+}
+
+// needsConfirmation reports whether a generated query exceeds the soft cost threshold
+func (qp *QueryProcessor) needsConfirmation(response *QueryResponse) bool {
+	return qp.confirmCostThreshold > 0 && response.EstimatedCost > qp.confirmCostThreshold
+}
+
+// requireConfirmation stores the response behind a single-use token and marks
+// it as awaiting confirmation
+func (qp *QueryProcessor) requireConfirmation(ctx context.Context, req *QueryRequest, tenant string, response *QueryResponse) error {
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return fmt.Errorf("failed to generate confirmation token: %w", err)
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	data, err := json.Marshal(pendingConfirmation{Query: req.Query, Tenant: tenant, Response: response})
+	if err != nil {
+		return err
+	}
+
+	cacheCtx, cancel := qp.cacheContext(ctx)
+	defer cancel()
+	if err := cacheError(cacheCtx, qp.cache.Set(cacheCtx, confirmationKey(token), data, confirmationTTL).Err()); err != nil {
+		return err
+	}
+
+	response.RequiresConfirmation = true
+	response.ConfirmationToken = token
+	response.Metadata["confirmation_threshold"] = qp.confirmCostThreshold
+	return nil
+}
+
+// confirmQuery redeems a confirmation token, returning the previously generated
+// response for the same query and tenant. Tokens are single-use.
+func (qp *QueryProcessor) confirmQuery(ctx context.Context, req *QueryRequest, tenant string) (*QueryResponse, error) {
+	cacheCtx, cancel := qp.cacheContext(ctx)
+	defer cancel()
+
+	key := confirmationKey(req.ConfirmationToken)
+	data, err := qp.cache.Get(cacheCtx, key).Result()
+	if err == redis.Nil {
+		return nil, errors.NewInvalidInputError("confirmation_token", "unknown or expired confirmation token").
+			WithSuggestion("Submit the query again without a confirmation token to receive a new one.")
+	}
+	if err != nil {
+		return nil, errors.Wrap(cacheError(cacheCtx, err), errors.ErrCodeCacheRead, "Failed to look up confirmation token").
+			WithMetadata("retryable", true).
+			WithDependency(errors.DependencyCache)
+	}
+
+	var pending pendingConfirmation
+	if err := json.Unmarshal([]byte(data), &pending); err != nil || pending.Response == nil {
+		return nil, errors.NewInvalidInputError("confirmation_token", "confirmation token is corrupt")
+	}
+	if pending.Query != req.Query {
+		return nil, errors.NewInvalidInputError("confirmation_token", "confirmation token was issued for a different query")
+	}
+	if pending.Tenant != tenant {
+		return nil, errors.NewInvalidInputError("confirmation_token", "confirmation token was issued for a different tenant")
+	}
+
+	// Redeem the token; if another request already did, treat it as expired
+	deleted, err := qp.cache.Del(cacheCtx, key).Result()
+	if err != nil {
+		return nil, errors.Wrap(cacheError(cacheCtx, err), errors.ErrCodeCacheWrite, "Failed to redeem confirmation token").
+			WithMetadata("retryable", true).
+			WithDependency(errors.DependencyCache)
+	}
+	if deleted == 0 {
+		return nil, errors.NewInvalidInputError("confirmation_token", "unknown or expired confirmation token")
+	}
+
+	response := pending.Response
+	response.RequiresConfirmation = false
+	response.ConfirmationToken = ""
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata["confirmed"] = true
+	return response, nil
+}
+
+func confirmationKey(token string) string {
+	return "confirm:" + token
+}
@@ -0,0 +1,91 @@
+// internal/processor/confirm_test.go
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConfirmationFlow tests that soft-threshold queries require a token-bearing retry
+func TestConfirmationFlow(t *testing.T) {
+	ctx := context.Background()
+	expensive := `sum(rate(http_requests_total{path=~"/api.*"}[5m]))`
+	llmClient := &modelRecordingLLMClient{MockLLMClient: MockLLMClient{
+		response: &llm.Response{PromQL: expensive, Explanation: "test", Confidence: 0.9},
+	}}
+	mapper := &MockSemanticMapper{services: []semantic.Service{
+		{ID: "svc-1", Name: "api", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+	}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, mapper, cache)
+	qp.SetConfirmCostThreshold(8)
+
+	query := "request rate for all api paths"
+	first, err := qp.ProcessQuery(ctx, &QueryRequest{Query: query})
+	require.NoError(t, err)
+	assert.True(t, first.RequiresConfirmation)
+	assert.NotEmpty(t, first.ConfirmationToken)
+	assert.Equal(t, expensive, first.PromQL)
+	assert.Greater(t, first.EstimatedCost, 8)
+
+	t.Run("unconfirmed query is not cached", func(t *testing.T) {
+		again, err := qp.ProcessQuery(ctx, &QueryRequest{Query: query})
+		require.NoError(t, err)
+		assert.False(t, again.CacheHit)
+		assert.True(t, again.RequiresConfirmation)
+		assert.NotEqual(t, first.ConfirmationToken, again.ConfirmationToken)
+	})
+
+	t.Run("token for a different query is rejected", func(t *testing.T) {
+		_, err := qp.ProcessQuery(ctx, &QueryRequest{Query: "something else", ConfirmationToken: first.ConfirmationToken})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "different query")
+	})
+
+	t.Run("token-bearing retry proceeds", func(t *testing.T) {
+		llmClient.models = nil
+		confirmed, err := qp.ProcessQuery(ctx, &QueryRequest{Query: query, ConfirmationToken: first.ConfirmationToken})
+		require.NoError(t, err)
+		assert.False(t, confirmed.RequiresConfirmation)
+		assert.Empty(t, confirmed.ConfirmationToken)
+		assert.Equal(t, expensive, confirmed.PromQL)
+		assert.Equal(t, true, confirmed.Metadata["confirmed"])
+		assert.Empty(t, llmClient.models, "confirmation should reuse the generated query")
+	})
+
+	t.Run("token is single use", func(t *testing.T) {
+		_, err := qp.ProcessQuery(ctx, &QueryRequest{Query: query, ConfirmationToken: first.ConfirmationToken})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown or expired")
+	})
+}
+
+// TestConfirmationBelowThreshold tests that cheap queries are returned without confirmation
+func TestConfirmationBelowThreshold(t *testing.T) {
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `up{job="api"}`, Explanation: "test", Confidence: 0.9}}
+	mapper := &MockSemanticMapper{services: []semantic.Service{
+		{ID: "svc-1", Name: "api", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+	}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, mapper, cache)
+	qp.SetConfirmCostThreshold(8)
+
+	response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "is the api up"})
+	require.NoError(t, err)
+	assert.False(t, response.RequiresConfirmation)
+	assert.Empty(t, response.ConfirmationToken)
+
+	// Disabled threshold never requires confirmation
+	llmClient.response = &llm.Response{PromQL: `sum(rate(http_requests_total{path=~"/api.*"}[5m]))`, Explanation: "test", Confidence: 0.9}
+	qp.SetConfirmCostThreshold(0)
+	response, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate for all api paths"})
+	require.NoError(t, err)
+	assert.False(t, response.RequiresConfirmation)
+}
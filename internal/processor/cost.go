@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// estimateQueryCost provides a rough estimate of query execution cost. window
+// is the evaluation window of a range query, or zero for an instant query.
+func (qp *QueryProcessor) estimateQueryCost(promql string, window time.Duration) int {
+	cost := 1
+
+	// Add cost for aggregations
+	if strings.Contains(promql, "sum") || strings.Contains(promql, "avg") {
+		cost += 2
+	}
+
+	// Add cost for rate calculations
+	if strings.Contains(promql, "rate") || strings.Contains(promql, "increase") {
+		cost += 3
+	}
+
+	// Add cost for regex matching
+	if strings.Contains(promql, "=~") {
+		cost += 5
+	}
+
+	// Add cost for the data read by the longest range selector
+	cost += rangeCost(longestSelectorRange(promql))
+
+	// Add cost for range queries by window length and number of points
+	if window > 0 {
+		cost += rangeCost(window)
+		cost += int(window/rangeQueryStep(window)) / pointsPerCost
+	}
+
+	return cost
+}
+
+// rangeCostTiers add cost by the duration of data a query reads; the first
+// tier the duration reaches applies
+var rangeCostTiers = []struct {
+	atLeast time.Duration
+	cost    int
+}{
+	{7 * 24 * time.Hour, 10},
+	{24 * time.Hour, 6},
+	{6 * time.Hour, 4},
+	{time.Hour, 2},
+}
+
+// pointsPerCost is the number of range query points per series that add one
+// to the cost
+const pointsPerCost = 50
+
+// rangeCost returns the cost of reading a duration of data
+func rangeCost(duration time.Duration) int {
+	for _, tier := range rangeCostTiers {
+		if duration >= tier.atLeast {
+			return tier.cost
+		}
+	}
+	return 0
+}
+
+// selectorRangePattern matches range and subquery selectors such as [5m],
+// [1h30m] or [7d:1h], capturing the range
+var selectorRangePattern = regexp.MustCompile(`\[\s*((?:\d+(?:ms|[smhdwy]))+)\s*(?::[^\]]*)?\]`)
+
+// promQLDurationPartPattern matches one component of a PromQL duration
+var promQLDurationPartPattern = regexp.MustCompile(`(\d+)(ms|[smhdwy])`)
+
+// promQLDurationUnitValues are the lengths of PromQL duration units
+var promQLDurationUnitValues = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// longestSelectorRange returns the longest range selector duration in a
+// query, or zero if it has none
+func longestSelectorRange(promql string) time.Duration {
+	var longest time.Duration
+	for _, match := range selectorRangePattern.FindAllStringSubmatch(promql, -1) {
+		var duration time.Duration
+		for _, part := range promQLDurationPartPattern.FindAllStringSubmatch(match[1], -1) {
+			n, _ := strconv.Atoi(part[1])
+			duration += time.Duration(n) * promQLDurationUnitValues[part[2]]
+		}
+		if duration > longest {
+			longest = duration
+		}
+	}
+	return longest
+}
@@ -0,0 +1,62 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEstimateQueryCostRange tests that longer ranges cost more than identical short-range queries
+func TestEstimateQueryCostRange(t *testing.T) {
+	qp := &QueryProcessor{}
+
+	t.Run("range selectors", func(t *testing.T) {
+		short := qp.estimateQueryCost(`sum(rate(http_requests_total[5m]))`, 0)
+		hour := qp.estimateQueryCost(`sum(rate(http_requests_total[2h]))`, 0)
+		day := qp.estimateQueryCost(`sum(rate(http_requests_total[1d]))`, 0)
+		week := qp.estimateQueryCost(`sum(rate(http_requests_total[7d]))`, 0)
+
+		assert.Less(t, short, hour)
+		assert.Less(t, hour, day)
+		assert.Less(t, day, week)
+	})
+
+	t.Run("range query windows", func(t *testing.T) {
+		query := `sum(rate(http_requests_total[5m]))`
+		instant := qp.estimateQueryCost(query, 0)
+		fiveMinutes := qp.estimateQueryCost(query, 5*time.Minute)
+		day := qp.estimateQueryCost(query, 24*time.Hour)
+		week := qp.estimateQueryCost(query, 7*24*time.Hour)
+
+		assert.LessOrEqual(t, instant, fiveMinutes)
+		assert.Less(t, fiveMinutes, day)
+		assert.Less(t, day, week)
+	})
+
+	t.Run("subquery ranges count", func(t *testing.T) {
+		assert.Greater(t,
+			qp.estimateQueryCost(`max_over_time(rate(http_requests_total[5m])[7d:1h])`, 0),
+			qp.estimateQueryCost(`max_over_time(rate(http_requests_total[5m])[10m:1m])`, 0))
+	})
+}
+
+// TestLongestSelectorRange tests parsing of PromQL range selector durations
+func TestLongestSelectorRange(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected time.Duration
+	}{
+		{`up`, 0},
+		{`rate(http_requests_total[5m])`, 5 * time.Minute},
+		{`rate(a[5m]) / rate(b[1h30m])`, 90 * time.Minute},
+		{`max_over_time(rate(a[5m])[2d:1h])`, 48 * time.Hour},
+		{`increase(a[1w])`, 7 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			assert.Equal(t, tt.expected, longestSelectorRange(tt.query))
+		})
+	}
+}
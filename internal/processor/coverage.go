@@ -0,0 +1,241 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+)
+
+// metricCategory is a kind of metric a query can ask for, recognized by
+// keywords in the query and by conventional metric names in the catalog
+type metricCategory struct {
+	name    string
+	query   *regexp.Regexp // matches queries asking for the category
+	metrics *regexp.Regexp // matches metric names of the category
+}
+
+// metricCategories are checked in order, so coverage is reported consistently
+var metricCategories = []metricCategory{
+	{
+		name:    "latency",
+		query:   regexp.MustCompile(`(?i)\b(latency|response time|slow|duration|p\d+|percentile|median)\b`),
+		metrics: regexp.MustCompile(`(?i)(duration|latency|response_time)`),
+	},
+	{
+		name:    "error_rate",
+		query:   regexp.MustCompile(`(?i)\b(errors?|fail(ures?|ed)?|5xx|4xx)\b`),
+		metrics: regexp.MustCompile(`(?i)(error|fail|exception)`),
+	},
+	{
+		name:    "throughput",
+		query:   regexp.MustCompile(`(?i)\b(requests|throughput|qps|rps)\b`),
+		metrics: regexp.MustCompile(`(?i)requests?_(total|count)$`),
+	},
+	{
+		name:    "availability",
+		query:   regexp.MustCompile(`(?i)\b(uptime|availability|down)\b`),
+		metrics: regexp.MustCompile(`(?i)(^up$|uptime|health)`),
+	},
+	{
+		name:    "cpu",
+		query:   regexp.MustCompile(`(?i)\b(cpu|processor)\b`),
+		metrics: regexp.MustCompile(`(?i)cpu`),
+	},
+	{
+		name:    "memory",
+		query:   regexp.MustCompile(`(?i)\b(memory|ram|heap|rss)\b`),
+		metrics: regexp.MustCompile(`(?i)(memory|heap|resident)`),
+	},
+	{
+		name:    "disk",
+		query:   regexp.MustCompile(`(?i)\b(disk|storage|filesystem)\b`),
+		metrics: regexp.MustCompile(`(?i)(disk|filesystem|storage)`),
+	},
+	{
+		name:    "network",
+		query:   regexp.MustCompile(`(?i)\b(network|bandwidth|traffic)\b`),
+		metrics: regexp.MustCompile(`(?i)(network|receive_bytes|transmit_bytes)`),
+	},
+}
+
+// metricCategoryExplicit is the category reported for a metric named exactly
+// in the query
+const metricCategoryExplicit = "metric"
+
+// MetricCoverageRequest asks which of the metrics a query needs are in the catalog
+type MetricCoverageRequest struct {
+	Query     string `json:"query" binding:"required"`
+	Namespace string `json:"namespace,omitempty"` // Namespace of the service named in the query
+}
+
+// CategoryCoverage reports whether the catalog has metrics of a requested category
+type CategoryCoverage struct {
+	Category  string   `json:"category"`
+	Available bool     `json:"available"`
+	Metrics   []string `json:"metrics,omitempty"` // Catalog metrics of the category
+}
+
+// MetricCoverageResponse reports catalog coverage for a query before any
+// query is generated
+type MetricCoverageResponse struct {
+	Query     string             `json:"query"`
+	Intent    *QueryIntent       `json:"intent"`
+	Service   string             `json:"service,omitempty"`   // Targeted service; empty checks the whole catalog
+	Namespace string             `json:"namespace,omitempty"` // Namespace of the targeted service
+	Coverage  []CategoryCoverage `json:"coverage"`
+	Covered   bool               `json:"covered"` // Every requested category is available
+	Message   string             `json:"message"`
+}
+
+// CheckMetricCoverage classifies the query and reports which of the metric
+// categories it asks for are available in the targeted service's catalog, or
+// in the whole catalog when no service is named. The generation model is not
+// called.
+func (qp *QueryProcessor) CheckMetricCoverage(ctx context.Context, req *MetricCoverageRequest) (*MetricCoverageResponse, error) {
+	intent, err := qp.intentClassifier.ClassifyIntent(req.Query)
+	if err != nil {
+		return nil, errors.NewIntentClassificationError(err, req.Query)
+	}
+
+	response := &MetricCoverageResponse{Query: req.Query, Intent: intent, Coverage: []CategoryCoverage{}}
+
+	var metricNames []string
+	if intent.Service != "" {
+		namespace := req.Namespace
+		if namespace == "" {
+			namespace = qp.defaultNamespace
+		}
+		service, err := qp.semanticMapper.GetServiceByName(ctx, intent.Service, namespace)
+		if err != nil || service == nil {
+			return nil, errors.NewServiceNotFoundError(intent.Service).WithMetadata("namespace", namespace)
+		}
+		response.Service = service.Name
+		response.Namespace = service.Namespace
+		metricNames = service.MetricNames
+	} else {
+		services, err := qp.semanticMapper.GetServices(ctx)
+		if err != nil {
+			return nil, errors.NewDatabaseQueryError(err, "fetching services")
+		}
+		seen := make(map[string]bool)
+		for _, service := range services {
+			for _, name := range service.MetricNames {
+				if !seen[name] {
+					seen[name] = true
+					metricNames = append(metricNames, name)
+				}
+			}
+		}
+	}
+
+	response.Coverage = metricCoverage(req.Query, intent, metricNames)
+	response.Covered = true
+	for _, coverage := range response.Coverage {
+		if !coverage.Available {
+			response.Covered = false
+		}
+	}
+	response.Message = coverageMessage(response)
+	return response, nil
+}
+
+// metricCoverage reports, for each metric category the query asks for, the
+// catalog metrics of that category. A metric named exactly in the query is
+// reported on its own.
+func metricCoverage(query string, intent *QueryIntent, metricNames []string) []CategoryCoverage {
+	coverage := []CategoryCoverage{}
+
+	if isExplicitMetric(intent.Metric) {
+		explicit := CategoryCoverage{Category: metricCategoryExplicit}
+		if containsString(metricNames, intent.Metric) {
+			explicit.Available = true
+			explicit.Metrics = []string{intent.Metric}
+		}
+		coverage = append(coverage, explicit)
+	}
+
+	for _, category := range metricCategories {
+		if !category.query.MatchString(query) && intent.Metric != category.name {
+			continue
+		}
+		result := CategoryCoverage{Category: category.name}
+		for _, name := range metricNames {
+			if category.metrics.MatchString(name) {
+				result.Metrics = append(result.Metrics, name)
+			}
+		}
+		result.Available = len(result.Metrics) > 0
+		coverage = append(coverage, result)
+	}
+	return coverage
+}
+
+// isExplicitMetric reports whether the intent's metric is an exact metric
+// name rather than an inferred metric type
+func isExplicitMetric(metric string) bool {
+	if metric == "" {
+		return false
+	}
+	for _, category := range metricCategories {
+		if metric == category.name {
+			return false
+		}
+	}
+	return true
+}
+
+// coverageMessage summarizes the coverage, e.g. "Metrics for latency are
+// available for service api, but not for cpu"
+func coverageMessage(response *MetricCoverageResponse) string {
+	scope := "in the catalog"
+	if response.Service != "" {
+		scope = fmt.Sprintf("for service %s", response.Service)
+	}
+	if len(response.Coverage) == 0 {
+		return "The query does not ask for a specific kind of metric"
+	}
+
+	var available, missing []string
+	for _, coverage := range response.Coverage {
+		name := coverage.Category
+		if name == metricCategoryExplicit {
+			name = response.Intent.Metric
+		}
+		if coverage.Available {
+			available = append(available, name)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+
+	switch {
+	case len(missing) == 0:
+		return fmt.Sprintf("Metrics for %s are available %s", strings.Join(available, ", "), scope)
+	case len(available) == 0:
+		return fmt.Sprintf("No metrics for %s are available %s", strings.Join(missing, ", "), scope)
+	default:
+		return fmt.Sprintf("Metrics for %s are available %s, but not for %s", strings.Join(available, ", "), scope, strings.Join(missing, ", "))
+	}
+}
+
+// handleValidateMetrics reports catalog coverage for a query without generating it
+func (qp *QueryProcessor) handleValidateMetrics(c *gin.Context) {
+	var req MetricCoverageRequest
+	if err := qp.bindJSON(c, &req); err != nil {
+		enhancedErr := errors.NewRequestBodyError(err)
+		c.JSON(http.StatusBadRequest, formatErrorResponse(enhancedErr))
+		return
+	}
+
+	response, err := qp.CheckMetricCoverage(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(getErrorStatusCode(err), formatErrorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
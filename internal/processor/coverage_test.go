@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateMetricsHandler tests that catalog coverage is reported without calling the model
+func TestValidateMetricsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mapper := &MockSemanticMapper{services: []semantic.Service{
+		{
+			ID: "svc-1", Name: "api", Namespace: "default",
+			MetricNames: []string{"http_request_duration_seconds_bucket", "http_requests_total", "http_errors_total"},
+		},
+		{
+			ID: "svc-2", Name: "worker", Namespace: "default",
+			MetricNames: []string{"process_cpu_seconds_total", "process_resident_memory_bytes"},
+		},
+	}}
+	llmClient := &MockLLMClient{err: assert.AnError}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	router := NewQueryProcessor(llmClient, mapper, cache).SetupRoutes(nil)
+
+	validate := func(request MetricCoverageRequest) (int, MetricCoverageResponse) {
+		body, err := json.Marshal(request)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/query/validate-metrics", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response MetricCoverageResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return w.Code, response
+	}
+
+	coverageOf := func(response MetricCoverageResponse) map[string]CategoryCoverage {
+		coverage := make(map[string]CategoryCoverage)
+		for _, c := range response.Coverage {
+			coverage[c.Category] = c
+		}
+		return coverage
+	}
+
+	t.Run("service missing the requested metric type", func(t *testing.T) {
+		code, response := validate(MetricCoverageRequest{Query: "show latency and cpu usage for service api"})
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "api", response.Service)
+		assert.False(t, response.Covered)
+
+		coverage := coverageOf(response)
+		require.Len(t, coverage, 2)
+		assert.True(t, coverage["latency"].Available)
+		assert.Equal(t, []string{"http_request_duration_seconds_bucket"}, coverage["latency"].Metrics)
+		assert.False(t, coverage["cpu"].Available)
+		assert.Empty(t, coverage["cpu"].Metrics)
+		assert.Equal(t, "Metrics for latency are available for service api, but not for cpu", response.Message)
+	})
+
+	t.Run("service covering every requested type", func(t *testing.T) {
+		code, response := validate(MetricCoverageRequest{Query: "error rate for service api"})
+		require.Equal(t, http.StatusOK, code)
+		assert.True(t, response.Covered)
+		assert.Equal(t, "errors", response.Intent.Type)
+
+		coverage := coverageOf(response)
+		require.Len(t, coverage, 1)
+		assert.Equal(t, []string{"http_errors_total"}, coverage["error_rate"].Metrics)
+	})
+
+	t.Run("whole catalog when no service is named", func(t *testing.T) {
+		code, response := validate(MetricCoverageRequest{Query: "cpu usage"})
+		require.Equal(t, http.StatusOK, code)
+		assert.Empty(t, response.Service)
+		assert.True(t, response.Covered)
+		assert.Equal(t, []string{"process_cpu_seconds_total"}, coverageOf(response)["cpu"].Metrics)
+	})
+
+	t.Run("explicit metric name", func(t *testing.T) {
+		code, response := validate(MetricCoverageRequest{Query: "rate of grpc_server_handled_total for service api"})
+		require.Equal(t, http.StatusOK, code)
+		assert.False(t, response.Covered)
+		assert.False(t, coverageOf(response)[metricCategoryExplicit].Available)
+		assert.Equal(t, "No metrics for grpc_server_handled_total are available for service api", response.Message)
+	})
+
+	t.Run("no specific metric type", func(t *testing.T) {
+		code, response := validate(MetricCoverageRequest{Query: "show me everything"})
+		require.Equal(t, http.StatusOK, code)
+		assert.True(t, response.Covered)
+		assert.Empty(t, response.Coverage)
+	})
+
+	t.Run("unknown service", func(t *testing.T) {
+		code, _ := validate(MetricCoverageRequest{Query: "latency for service billing"})
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("missing query", func(t *testing.T) {
+		code, _ := validate(MetricCoverageRequest{})
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
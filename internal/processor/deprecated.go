@@ -0,0 +1,82 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+)
+
+// Deprecated metrics are left out of the prompt's catalog, and generated
+// queries that still select one are either answered with a warning in
+// Suggestions or rejected, since they would likely return nothing.
+
+// deprecatedMetrics holds the metrics queries should no longer use
+type deprecatedMetrics struct {
+	patterns []*regexp.Regexp // nil when no metric is deprecated
+	reject   bool             // Reject queries using them instead of warning
+}
+
+// SetDeprecatedMetrics sets the regular expressions, each matching whole
+// metric names, of deprecated metrics. With reject set, generated queries
+// selecting a deprecated metric fail; otherwise they are returned with a
+// suggestion to use a current metric. Invalid patterns are logged and ignored.
+func (qp *QueryProcessor) SetDeprecatedMetrics(patterns []string, reject bool) {
+	qp.deprecated = deprecatedMetrics{reject: reject}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			qp.logger.Warn(context.Background(), "Invalid deprecated metric pattern ignored", map[string]interface{}{
+				"pattern": pattern,
+				"error":   err.Error(),
+			})
+			continue
+		}
+		qp.deprecated.patterns = append(qp.deprecated.patterns, re)
+	}
+}
+
+// isDeprecated reports whether a metric is deprecated
+func (d deprecatedMetrics) isDeprecated(metric string) bool {
+	for _, pattern := range d.patterns {
+		if pattern.MatchString(metric) {
+			return true
+		}
+	}
+	return false
+}
+
+// current returns the metric names that are not deprecated
+func (d deprecatedMetrics) current(metricNames []string) []string {
+	if len(d.patterns) == 0 {
+		return metricNames
+	}
+	current := make([]string, 0, len(metricNames))
+	for _, name := range metricNames {
+		if !d.isDeprecated(name) {
+			current = append(current, name)
+		}
+	}
+	return current
+}
+
+// check returns a suggestion for each deprecated metric a query selects, or
+// an error for the first one when deprecated metrics are rejected
+func (d deprecatedMetrics) check(promql string) ([]string, error) {
+	if len(d.patterns) == 0 {
+		return nil, nil
+	}
+
+	var suggestions []string
+	for _, metric := range selectorMetrics(promql) {
+		if !d.isDeprecated(metric) {
+			continue
+		}
+		if d.reject {
+			return nil, errors.NewDeprecatedMetricError(metric)
+		}
+		suggestions = append(suggestions, fmt.Sprintf("The metric %s is deprecated and may no longer report data; consider a current metric from the catalog", metric))
+	}
+	return suggestions, nil
+}
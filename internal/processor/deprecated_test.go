@@ -0,0 +1,93 @@
+package processor
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeprecatedMetrics tests that generated queries selecting a deprecated
+// metric are warned about or rejected, and that the prompt leaves them out
+func TestDeprecatedMetrics(t *testing.T) {
+	mapper := &MockSemanticMapper{services: []semantic.Service{
+		{ID: "svc-1", Name: "api", Namespace: "default", MetricNames: []string{"http_requests_total", "legacy_http_requests_total"}},
+	}}
+	llmClient := &MockLLMClient{response: &llm.Response{
+		PromQL:     `sum(rate(legacy_http_requests_total{service="api"}[5m]))`,
+		Confidence: 0.8,
+	}}
+	newProcessor := func(t *testing.T, reject bool) *QueryProcessor {
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, mapper, cache)
+		qp.SetDeprecatedMetrics([]string{"legacy_.*"}, reject)
+		return qp
+	}
+
+	t.Run("warn mode", func(t *testing.T) {
+		qp := newProcessor(t, false)
+
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate for service api"})
+		require.NoError(t, err)
+		assert.Equal(t, llmClient.response.PromQL, response.PromQL)
+		require.Len(t, response.Suggestions, 1)
+		assert.Contains(t, response.Suggestions[0], "legacy_http_requests_total is deprecated")
+	})
+
+	t.Run("reject mode", func(t *testing.T) {
+		qp := newProcessor(t, true)
+
+		_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate for service api"})
+		require.Error(t, err)
+		enhanced, ok := err.(*errors.EnhancedError)
+		require.True(t, ok)
+		assert.Equal(t, errors.ErrCodeDeprecatedMetric, enhanced.Code)
+		assert.Equal(t, "legacy_http_requests_total", enhanced.Metadata["metric_name"])
+		assert.Equal(t, http.StatusBadRequest, getErrorStatusCode(err))
+	})
+
+	t.Run("current metrics pass", func(t *testing.T) {
+		qp := newProcessor(t, true)
+		qp.llmClient = &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_requests_total{service="api"}[5m]))`, Confidence: 0.8}}
+
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate for service api"})
+		require.NoError(t, err)
+		assert.Empty(t, response.Suggestions)
+	})
+
+	t.Run("prompt leaves deprecated metrics out", func(t *testing.T) {
+		qp := newProcessor(t, false)
+
+		prompt, err := qp.buildPrompt(context.Background(), &QueryRequest{Query: "request rate"}, &QueryIntent{}, nil)
+		require.NoError(t, err)
+		assert.Contains(t, prompt, "- http_requests_total\n")
+		assert.NotContains(t, prompt, "legacy_http_requests_total")
+	})
+}
+
+// TestSelectorMetrics tests metric name extraction from generated queries
+func TestSelectorMetrics(t *testing.T) {
+	tests := []struct {
+		promql   string
+		expected []string
+	}{
+		{`rate(http_requests_total{service="api"}[5m])`, []string{"http_requests_total"}},
+		{`sum by (service) (rate(errors_total[5m])) / sum by (service) (rate(requests_total[5m]))`, []string{"errors_total", "requests_total"}},
+		{`up{job="api"} or up`, []string{"up"}},
+		{`histogram_quantile(0.99, sum by (le) (rate(latency_bucket{le!=""}[5m])))`, []string{"latency_bucket"}},
+		{`rate({__name__="x"}[5m])`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.promql, func(t *testing.T) {
+			assert.Equal(t, tt.expected, selectorMetrics(tt.promql))
+		})
+	}
+}
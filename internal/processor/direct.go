@@ -0,0 +1,162 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/metrics"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+)
+
+// Direct queries are built deterministically when the user names an exact
+// catalog metric, so no LLM call is needed
+const (
+	defaultDirectWindow     = "5m"
+	directHistogramQuantile = 0.95
+	directQueryConfidence   = 0.95
+)
+
+// directCatalogTTL is how long the catalog metric names used to recognize
+// direct queries are reused before being reloaded from the mapper
+const directCatalogTTL = time.Minute
+
+// timeRangePattern matches time ranges as extracted by the intent classifier, e.g. "2hour"
+var timeRangePattern = regexp.MustCompile(`^(\d+)(minute|hour|day|week)$`)
+
+// promQLDurationUnits maps intent time range units to PromQL duration units
+var promQLDurationUnits = map[string]string{
+	"minute": "m",
+	"hour":   "h",
+	"day":    "d",
+	"week":   "w",
+}
+
+// directQuery returns a deterministically constructed query when the intent's
+// metric exactly matches a discovered metric whose type determines the PromQL
+// function to apply. It returns nil when the query should go to the LLM.
+func (qp *QueryProcessor) directQuery(ctx context.Context, intent *QueryIntent) *llm.Response {
+	// Service filters and comparisons need label knowledge only the LLM prompt has
+	if intent.Metric == "" || intent.Service != "" || intent.Type == "comparison" {
+		return nil
+	}
+
+	names, err := qp.directCatalog.metricNames(ctx, qp.semanticMapper)
+	if err != nil {
+		qp.logger.Warn(ctx, "Failed to load metric catalog for direct query", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil
+	}
+	if !names[intent.Metric] {
+		return nil
+	}
+
+	// Without a stored type the metric's type is inferred from its name
+	var storedType string
+	if catalogMetrics, err := qp.semanticMapper.GetMetricsByName(ctx, intent.Metric); err == nil {
+		for _, metric := range catalogMetrics {
+			if metric.Type != "" {
+				storedType = metric.Type
+				break
+			}
+		}
+	}
+	return buildDirectQuery(intent, storedType, qp.metricTypes)
+}
+
+// directCatalog caches the catalog metric names, so recognizing a direct
+// query does not load every service from the mapper
+type directCatalog struct {
+	mu       sync.Mutex
+	names    map[string]bool
+	loadedAt time.Time
+}
+
+// metricNames returns the metric names of all services, reloading them from
+// the mapper once they are older than directCatalogTTL
+func (dc *directCatalog) metricNames(ctx context.Context, mapper semantic.Mapper) (map[string]bool, error) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	if dc.names != nil && time.Since(dc.loadedAt) < directCatalogTTL {
+		return dc.names, nil
+	}
+
+	services, err := mapper.GetServices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for _, service := range services {
+		for _, name := range service.MetricNames {
+			names[name] = true
+		}
+	}
+	dc.names = names
+	dc.loadedAt = time.Now()
+	return names, nil
+}
+
+// buildDirectQuery constructs PromQL for the intent's metric based on its
+// stored or inferred type. Types that do not determine a single function
+// return nil.
+func buildDirectQuery(intent *QueryIntent, storedType string, overrides *metrics.TypeOverrides) *llm.Response {
+	window := promQLDuration(intent.TimeRange)
+	metricType := inferCatalogType(intent.Metric, storedType, overrides)
+
+	var promql, explanation string
+	switch {
+	case metricType == metrics.MetricTypeCounter:
+		promql = fmt.Sprintf("rate(%s[%s])", intent.Metric, window)
+		explanation = fmt.Sprintf("Per-second rate of counter %s over %s", intent.Metric, window)
+	case metricType == metrics.MetricTypeGauge:
+		promql = intent.Metric
+		explanation = fmt.Sprintf("Current value of gauge %s", intent.Metric)
+	case metricType == metrics.MetricTypeHistogram && strings.HasSuffix(intent.Metric, "_bucket"):
+		quantile := intent.Quantile
+		if quantile == 0 {
+			quantile = directHistogramQuantile
+		}
+		promql = fmt.Sprintf("histogram_quantile(%s, rate(%s[%s]))", formatQuantile(quantile), intent.Metric, window)
+		explanation = fmt.Sprintf("%gth percentile of histogram %s over %s", math.Round(quantile*1e6)/1e4, intent.Metric, window)
+	default:
+		return nil
+	}
+
+	if intent.Ranking != "" {
+		fn := "topk"
+		if intent.Ranking == "bottom" {
+			fn = "bottomk"
+		}
+		promql = fmt.Sprintf("%s(%d, %s)", fn, intent.Limit, promql)
+		explanation = fmt.Sprintf("%s, limited to the %s %d series", explanation, intent.Ranking, intent.Limit)
+	}
+
+	return &llm.Response{
+		PromQL:      promql,
+		Explanation: explanation,
+		Confidence:  directQueryConfidence,
+	}
+}
+
+// formatQuantile renders a quantile for PromQL, e.g. "0.99"
+func formatQuantile(quantile float64) string {
+	return strconv.FormatFloat(quantile, 'f', -1, 64)
+}
+
+// promQLDuration converts an intent time range such as "2hour" to a PromQL
+// duration, falling back to the default window
+func promQLDuration(timeRange string) string {
+	match := timeRangePattern.FindStringSubmatch(strings.ToLower(timeRange))
+	if match == nil {
+		return defaultDirectWindow
+	}
+	return match[1] + promQLDurationUnits[match[2]]
+}
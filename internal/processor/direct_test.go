@@ -0,0 +1,184 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDirectMetricQuery tests that exact catalog metrics are queried without the LLM
+func TestDirectMetricQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "counter uses rate",
+			query:    "rate of http_requests_total",
+			expected: "rate(http_requests_total[5m])",
+		},
+		{
+			name:     "counter with time range",
+			query:    "http_requests_total over the last 2 hours",
+			expected: "rate(http_requests_total[2h])",
+		},
+		{
+			name:     "gauge used directly",
+			query:    "show memory_usage_bytes",
+			expected: "memory_usage_bytes",
+		},
+		{
+			name:     "histogram uses histogram_quantile",
+			query:    "http_request_duration_seconds_bucket",
+			expected: "histogram_quantile(0.95, rate(http_request_duration_seconds_bucket[5m]))",
+		},
+		{
+			name:     "histogram uses requested percentile",
+			query:    "p99 of http_request_duration_seconds_bucket",
+			expected: "histogram_quantile(0.99, rate(http_request_duration_seconds_bucket[5m]))",
+		},
+		{
+			name:     "ranking wraps in topk",
+			query:    "top 5 http_requests_total",
+			expected: "topk(5, rate(http_requests_total[5m]))",
+		},
+	}
+
+	mapper := &MockSemanticMapper{services: []semantic.Service{
+		{ID: "svc-1", Name: "api", Namespace: "default", MetricNames: []string{"http_requests_total", "memory_usage_bytes", "http_request_duration_seconds_bucket"}},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llmClient := &modelRecordingLLMClient{MockLLMClient: MockLLMClient{response: &llm.Response{PromQL: `sum(up)`, Confidence: 0.8}}}
+			cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+			qp := NewQueryProcessor(llmClient, mapper, cache)
+
+			response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: tt.query})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, response.PromQL)
+			assert.Equal(t, directQueryConfidence, response.Confidence)
+			assert.NotEmpty(t, response.Explanation)
+			assert.NotContains(t, response.Metadata, "model")
+			assert.Empty(t, llmClient.models, "LLM should not be called for an exact catalog metric")
+		})
+	}
+}
+
+// TestDirectMetricQueryFallsBackToLLM tests that queries without a deterministic construction use the LLM
+func TestDirectMetricQueryFallsBackToLLM(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "metric not in catalog", query: "rate of grpc_requests_total"},
+		{name: "no metric name", query: "show me the request rate"},
+		{name: "type does not determine function", query: "request_latency"},
+		{name: "service filter needs labels", query: "http_requests_total for service api"},
+	}
+
+	mapper := &MockSemanticMapper{services: []semantic.Service{
+		{ID: "svc-1", Name: "api", Namespace: "default", MetricNames: []string{"http_requests_total", "request_latency"}},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llmClient := &modelRecordingLLMClient{MockLLMClient: MockLLMClient{response: &llm.Response{PromQL: `sum(up)`, Confidence: 0.8}}}
+			cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+			qp := NewQueryProcessor(llmClient, mapper, cache)
+
+			response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: tt.query})
+			require.NoError(t, err)
+			assert.Equal(t, "sum(up)", response.PromQL)
+			assert.Len(t, llmClient.models, 1)
+			assert.NotContains(t, response.Metadata, "direct_metric")
+		})
+	}
+}
+
+// TestDirectMetricQueryOtherLanguage tests that a query asking for a
+// non-English explanation uses the LLM, since direct explanations are English
+func TestDirectMetricQueryOtherLanguage(t *testing.T) {
+	llmClient := &modelRecordingLLMClient{MockLLMClient: MockLLMClient{response: &llm.Response{PromQL: `sum(up)`, Explanation: "from llm", Confidence: 0.8}}}
+	mapper := &MockSemanticMapper{services: []semantic.Service{
+		{ID: "svc-1", Name: "api", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+	}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, mapper, cache)
+	qp.SetSupportedLanguages([]string{"de"})
+
+	response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "rate of http_requests_total", Language: "de"})
+	require.NoError(t, err)
+	assert.Equal(t, "sum(up)", response.PromQL)
+	assert.Equal(t, "from llm", response.Explanation)
+	assert.Len(t, llmClient.models, 1)
+
+	response, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "rate of http_requests_total", Language: "en"})
+	require.NoError(t, err)
+	assert.Equal(t, "rate(http_requests_total[5m])", response.PromQL)
+	assert.Len(t, llmClient.models, 1)
+}
+
+// TestExtractMetricName tests that explicit metric names are extracted into the intent
+func TestExtractMetricName(t *testing.T) {
+	ic := NewIntentClassifier()
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedMetric string
+	}{
+		{name: "metric name", query: "rate of http_requests_total", expectedMetric: "http_requests_total"},
+		{name: "metric name overrides type", query: "latency from http_request_duration_seconds_bucket", expectedMetric: "http_request_duration_seconds_bucket"},
+		{name: "service name is not a metric", query: "service user_service latency", expectedMetric: "latency"},
+		{name: "no metric name", query: "show error rate", expectedMetric: "error_rate"},
+		{name: "snake case word is not a metric", query: "show latency of user_accounts", expectedMetric: "latency"},
+		{name: "three segment metric name", query: "show process_open_fds", expectedMetric: "process_open_fds"},
+		{name: "recording rule name", query: "show job:http_requests:rate5m", expectedMetric: "job:http_requests:rate5m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			intent, err := ic.ClassifyIntent(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedMetric, intent.Metric)
+		})
+	}
+}
+
+// countingServicesMapper counts the calls loading every service
+type countingServicesMapper struct {
+	MockSemanticMapper
+	calls int
+}
+
+func (m *countingServicesMapper) GetServices(ctx context.Context) ([]semantic.Service, error) {
+	m.calls++
+	return m.MockSemanticMapper.GetServices(ctx)
+}
+
+// TestDirectQueryReusesCatalog tests that direct queries do not load every
+// service from the mapper on each query
+func TestDirectQueryReusesCatalog(t *testing.T) {
+	mapper := &countingServicesMapper{MockSemanticMapper: MockSemanticMapper{
+		services: []semantic.Service{{Name: "api", Namespace: "default", MetricNames: []string{"http_requests_total"}}},
+	}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(&MockLLMClient{}, mapper, cache)
+
+	intent := &QueryIntent{Metric: "http_requests_total"}
+	for i := 0; i < 3; i++ {
+		response := qp.directQuery(context.Background(), intent)
+		require.NotNil(t, response)
+		assert.Equal(t, "rate(http_requests_total[5m])", response.PromQL)
+	}
+	assert.Nil(t, qp.directQuery(context.Background(), &QueryIntent{Metric: "grpc_requests_total"}))
+	assert.Equal(t, 1, mapper.calls)
+}
@@ -0,0 +1,80 @@
+package processor
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+)
+
+// Metric display prefixes shorten verbose metric names such as
+// namespace_app_http_requests_total to http_requests_total in catalog
+// responses. Names are only shortened for display: the prompt, generated
+// queries and every query-facing field keep the full name.
+
+// SetMetricDisplayPrefixes sets the prefixes stripped from metric names for
+// display. The longest prefix leaving a valid metric name is stripped, so a
+// name is never displayed empty or starting with a digit.
+func (qp *QueryProcessor) SetMetricDisplayPrefixes(prefixes []string) {
+	qp.displayPrefixes = nil
+	for _, prefix := range prefixes {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			qp.displayPrefixes = append(qp.displayPrefixes, prefix)
+		}
+	}
+	sort.Slice(qp.displayPrefixes, func(i, j int) bool {
+		return len(qp.displayPrefixes[i]) > len(qp.displayPrefixes[j])
+	})
+}
+
+// metricDisplayName returns the name a metric is displayed under
+func (qp *QueryProcessor) metricDisplayName(name string) string {
+	for _, prefix := range qp.displayPrefixes {
+		display := strings.TrimPrefix(name, prefix)
+		if display != name && display != "" && isMetricNameStart(display[0]) {
+			return display
+		}
+	}
+	return name
+}
+
+// withServiceDisplayNames returns copies of services carrying the display
+// names of their metrics that differ from the full names
+func (qp *QueryProcessor) withServiceDisplayNames(services []semantic.Service) []semantic.Service {
+	if len(qp.displayPrefixes) == 0 {
+		return services
+	}
+	displayed := make([]semantic.Service, len(services))
+	for i, service := range services {
+		displayed[i] = *qp.withServiceDisplayName(&service)
+	}
+	return displayed
+}
+
+// withServiceDisplayName returns a copy of service carrying the display names
+// of its metrics that differ from the full names
+func (qp *QueryProcessor) withServiceDisplayName(service *semantic.Service) *semantic.Service {
+	displayed := *service
+	for _, name := range service.MetricNames {
+		if display := qp.metricDisplayName(name); display != name {
+			if displayed.MetricDisplayNames == nil {
+				displayed.MetricDisplayNames = make(map[string]string)
+			}
+			displayed.MetricDisplayNames[name] = display
+		}
+	}
+	return &displayed
+}
+
+// withMetricDisplayNames returns copies of metrics carrying their display names
+func (qp *QueryProcessor) withMetricDisplayNames(metrics []semantic.Metric) []semantic.Metric {
+	if len(qp.displayPrefixes) == 0 {
+		return metrics
+	}
+	displayed := make([]semantic.Metric, len(metrics))
+	for i, metric := range metrics {
+		metric.DisplayName = qp.metricDisplayName(metric.Name)
+		displayed[i] = metric
+	}
+	return displayed
+}
@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMetricDisplayNames tests that catalog responses display metric names
+// without the configured prefixes, while name fields keep the full names
+func TestMetricDisplayNames(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const fullName = "namespace_app_http_requests_total"
+	mapper := &MockSemanticMapper{
+		services: []semantic.Service{
+			{ID: "svc-1", Name: "api", Namespace: "default", MetricNames: []string{fullName, "up", "namespace_app_2xx"}},
+		},
+		metrics: map[string][]semantic.Metric{
+			"svc-1": {{ID: "m-1", Name: fullName, Type: "counter", ServiceID: "svc-1"}},
+		},
+	}
+	llmClient := &promptRecordingLLMClient{
+		MockLLMClient: MockLLMClient{response: &llm.Response{PromQL: `rate(` + fullName + `[5m])`, Confidence: 0.9}},
+	}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, mapper, cache)
+	qp.SetMetricDisplayPrefixes([]string{"namespace_", "namespace_app_"})
+	router := qp.SetupRoutes(nil)
+
+	get := func(path string, response interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), response))
+	}
+
+	var services []semantic.Service
+	get("/api/v1/services", &services)
+	require.Len(t, services, 1)
+	assert.Equal(t, []string{fullName, "up", "namespace_app_2xx"}, services[0].MetricNames)
+	// The longest prefix leaving a valid name is stripped
+	assert.Equal(t, map[string]string{
+		fullName:            "http_requests_total",
+		"namespace_app_2xx": "app_2xx",
+	}, services[0].MetricDisplayNames)
+
+	var service semantic.Service
+	get("/api/v1/services/api", &service)
+	assert.Equal(t, "http_requests_total", service.MetricDisplayNames[fullName])
+
+	var metrics []semantic.Metric
+	get("/api/v1/services/svc-1/metrics", &metrics)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, fullName, metrics[0].Name)
+	assert.Equal(t, "http_requests_total", metrics[0].DisplayName)
+
+	get("/api/v1/metrics", &metrics)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "http_requests_total", metrics[0].DisplayName)
+
+	var detail MetricDetail
+	get("/api/v1/metrics/"+fullName, &detail)
+	assert.Equal(t, fullName, detail.Name)
+	assert.Equal(t, "http_requests_total", detail.DisplayName)
+
+	// The catalog is left unchanged, and queries use the full names
+	assert.Nil(t, mapper.services[0].MetricDisplayNames)
+	response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate for api"})
+	require.NoError(t, err)
+	assert.Contains(t, response.PromQL, fullName)
+	require.NotEmpty(t, llmClient.prompts)
+	assert.Contains(t, llmClient.prompts[0], fullName)
+}
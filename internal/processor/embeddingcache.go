@@ -0,0 +1,62 @@
+package processor
+
+import (
+	"context"
+	"strings"
+
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/observability"
+)
+
+// SetEmbeddingCache enables the durable embedding cache, so queries that only
+// differ in case and whitespace reuse the embedding stored by the mapper
+// instead of generating a new one. Embeddings are cached per embedding model,
+// so a model change never serves embeddings of the previous one.
+func (qp *QueryProcessor) SetEmbeddingCache(enabled bool) {
+	qp.embeddingCache = enabled
+}
+
+// normalizeEmbeddingQuery returns the embedding cache key of a query
+func normalizeEmbeddingQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// queryEmbedding returns the embedding of a query, from the embedding cache
+// when enabled. The cache is optional: if it fails, the embedding is
+// generated directly.
+func (qp *QueryProcessor) queryEmbedding(ctx context.Context, query string) ([]float32, error) {
+	if !qp.embeddingCache {
+		return qp.llmClient.GetEmbedding(ctx, query)
+	}
+
+	var embedErr error
+	embedding, hit, err := qp.semanticMapper.GetOrStoreEmbedding(ctx, llm.EmbeddingModel(qp.llmClient), normalizeEmbeddingQuery(query),
+		func(ctx context.Context) ([]float32, error) {
+			embedding, err := qp.llmClient.GetEmbedding(ctx, query)
+			embedErr = err
+			return embedding, err
+		})
+	if embedErr != nil {
+		return nil, embedErr
+	}
+
+	outcome := "miss"
+	if hit {
+		outcome = "hit"
+	}
+	if err != nil {
+		outcome = "error"
+		qp.logger.Warn(ctx, "Embedding cache failed, generating the embedding directly", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	observability.GetGlobalMetrics().Inc(observability.MetricEmbeddingCacheLookups, map[string]string{
+		"outcome": outcome,
+	})
+
+	// The embedding is returned even if it could not be stored
+	if embedding == nil {
+		return qp.llmClient.GetEmbedding(ctx, query)
+	}
+	return embedding, nil
+}
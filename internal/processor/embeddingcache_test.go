@@ -0,0 +1,144 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingEmbeddingLLMClient counts embedding requests
+type countingEmbeddingLLMClient struct {
+	MockLLMClient
+	embeddings int
+}
+
+func (m *countingEmbeddingLLMClient) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	m.embeddings++
+	return m.MockLLMClient.GetEmbedding(ctx, text)
+}
+
+// modelEmbeddingLLMClient names its embedding model
+type modelEmbeddingLLMClient struct {
+	countingEmbeddingLLMClient
+	model string
+}
+
+func (m *modelEmbeddingLLMClient) EmbeddingModel() string { return m.model }
+
+// failingPurgeMapper fails to purge the embedding cache
+type failingPurgeMapper struct {
+	MockSemanticMapper
+	purges int
+}
+
+func (m *failingPurgeMapper) PurgeEmbeddingCache(ctx context.Context, model string, dimension int) (int, error) {
+	m.purges++
+	return 0, fmt.Errorf("database unavailable")
+}
+
+// TestQueryEmbeddingCache tests that identical queries reuse the cached embedding
+func TestQueryEmbeddingCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reuses embeddings of normalized queries", func(t *testing.T) {
+		llmClient := &countingEmbeddingLLMClient{MockLLMClient: MockLLMClient{embeddingDim: 384}}
+		mapper := &MockSemanticMapper{}
+		qp := NewQueryProcessor(llmClient, mapper, nil)
+		qp.SetEmbeddingCache(true)
+
+		first, err := qp.queryEmbedding(ctx, "Show error rate")
+		require.NoError(t, err)
+		second, err := qp.queryEmbedding(ctx, "  show   ERROR rate ")
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, llmClient.embeddings)
+		assert.Equal(t, first, second)
+		assert.Contains(t, mapper.embeddings, embeddingKey{"", "show error rate"})
+	})
+
+	t.Run("embeddings are cached per model", func(t *testing.T) {
+		llmClient := &modelEmbeddingLLMClient{countingEmbeddingLLMClient: countingEmbeddingLLMClient{MockLLMClient: MockLLMClient{embeddingDim: 384}}, model: "embed-v1"}
+		mapper := &MockSemanticMapper{}
+		qp := NewQueryProcessor(llmClient, mapper, nil)
+		qp.SetEmbeddingCache(true)
+
+		_, err := qp.queryEmbedding(ctx, "show error rate")
+		require.NoError(t, err)
+		llmClient.model = "embed-v2"
+		_, err = qp.queryEmbedding(ctx, "show error rate")
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, llmClient.embeddings, "a new model must not reuse embeddings of the previous one")
+		assert.Contains(t, mapper.embeddings, embeddingKey{"embed-v1", "show error rate"})
+		assert.Contains(t, mapper.embeddings, embeddingKey{"embed-v2", "show error rate"})
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		llmClient := &countingEmbeddingLLMClient{MockLLMClient: MockLLMClient{embeddingDim: 384}}
+		mapper := &MockSemanticMapper{}
+		qp := NewQueryProcessor(llmClient, mapper, nil)
+
+		for i := 0; i < 2; i++ {
+			_, err := qp.queryEmbedding(ctx, "show error rate")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, llmClient.embeddings)
+		assert.Empty(t, mapper.embeddings)
+	})
+
+	t.Run("embedding errors are returned", func(t *testing.T) {
+		qp := NewQueryProcessor(&failingEmbeddingLLMClient{}, &MockSemanticMapper{}, nil)
+		qp.SetEmbeddingCache(true)
+
+		_, err := qp.queryEmbedding(ctx, "show error rate")
+		assert.Error(t, err)
+	})
+
+	t.Run("re-embedding purges embeddings of a previous model", func(t *testing.T) {
+		llmClient := &modelEmbeddingLLMClient{countingEmbeddingLLMClient: countingEmbeddingLLMClient{MockLLMClient: MockLLMClient{embeddingDim: 384}}, model: "embed-v2"}
+		mapper := &MockSemanticMapper{embeddings: map[embeddingKey][]float32{
+			{"embed-v1", "old model"}:     make([]float32, 384),
+			{"embed-v2", "old dimension"}: make([]float32, 1536),
+			{"embed-v2", "new model"}:     make([]float32, 384),
+		}}
+		qp := NewQueryProcessor(llmClient, mapper, nil)
+		qp.SetEmbeddingCache(true)
+
+		result, err := qp.ReembedQueries(ctx, ReembedRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.EmbeddingCachePurged)
+		assert.Equal(t, map[embeddingKey][]float32{{"embed-v2", "new model"}: make([]float32, 384)}, mapper.embeddings)
+
+		result, err = qp.ReembedQueries(ctx, ReembedRequest{Force: true})
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.EmbeddingCachePurged)
+		assert.Empty(t, mapper.embeddings)
+	})
+
+	t.Run("re-embedding leaves a disabled cache alone", func(t *testing.T) {
+		mapper := &failingPurgeMapper{MockSemanticMapper: MockSemanticMapper{embeddings: map[embeddingKey][]float32{
+			{"", "old model"}: make([]float32, 1536),
+		}}}
+		qp := NewQueryProcessor(&MockLLMClient{embeddingDim: 384}, mapper, nil)
+
+		result, err := qp.ReembedQueries(ctx, ReembedRequest{})
+		require.NoError(t, err)
+		assert.Zero(t, result.EmbeddingCachePurged)
+		assert.Len(t, mapper.embeddings, 1)
+		assert.Zero(t, mapper.purges)
+	})
+
+	t.Run("a failed purge does not fail re-embedding", func(t *testing.T) {
+		mapper := &failingPurgeMapper{}
+		qp := NewQueryProcessor(&MockLLMClient{embeddingDim: 384}, mapper, nil)
+		qp.SetEmbeddingCache(true)
+
+		result, err := qp.ReembedQueries(ctx, ReembedRequest{})
+		require.NoError(t, err)
+		assert.True(t, result.Completed)
+		assert.Equal(t, 1, mapper.purges)
+	})
+}
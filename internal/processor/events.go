@@ -0,0 +1,99 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/events"
+)
+
+// eventStreamKeepalive is how often an idle event stream sends a comment so
+// proxies do not close the connection
+const eventStreamKeepalive = 15 * time.Second
+
+// knownEventTypes are the event types that may be used to filter the stream
+var knownEventTypes = []events.Type{
+	events.TypeQueryProcessed,
+	events.TypeAuthSuccess,
+	events.TypeAuthFailure,
+	events.TypeDiscoveryRun,
+}
+
+// SetEventBus publishes processed queries to the bus and enables the admin
+// event stream
+func (qp *QueryProcessor) SetEventBus(bus *events.Bus) {
+	qp.events = bus
+}
+
+// parseEventTypes parses a comma-separated event type filter
+func parseEventTypes(filter string) ([]events.Type, error) {
+	var types []events.Type
+	for _, name := range strings.Split(filter, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		known := false
+		for _, t := range knownEventTypes {
+			if string(t) == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			valid := make([]string, len(knownEventTypes))
+			for i, t := range knownEventTypes {
+				valid[i] = string(t)
+			}
+			return nil, errors.NewInvalidInputError("types",
+				fmt.Sprintf("unknown event type %q; valid types: %s", name, strings.Join(valid, ", ")))
+		}
+		types = append(types, events.Type(name))
+	}
+	return types, nil
+}
+
+// handleEventStream streams events as Server-Sent Events until the client
+// disconnects (admin only). The optional types query parameter limits the
+// stream to a comma-separated list of event types.
+func (qp *QueryProcessor) handleEventStream(c *gin.Context) {
+	types, err := parseEventTypes(c.Query("types"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, formatErrorResponse(err))
+		return
+	}
+
+	sub := qp.events.Subscribe(types...)
+	defer qp.events.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	keepalive := time.NewTicker(eventStreamKeepalive)
+	defer keepalive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-sub.Events():
+			if !ok {
+				return false
+			}
+			c.SSEvent(string(event.Type), event)
+			return true
+		case <-keepalive.C:
+			_, err := io.WriteString(w, ": keepalive\n\n")
+			return err == nil
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package processor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/events"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// allowAllAuthorizer lets every request through, including admin routes
+type allowAllAuthorizer struct{}
+
+func (allowAllAuthorizer) Middleware() gin.HandlerFunc { return func(c *gin.Context) { c.Next() } }
+
+func (allowAllAuthorizer) RequireRole(...string) gin.HandlerFunc {
+	return func(c *gin.Context) { c.Next() }
+}
+
+func newEventStreamServer(t *testing.T) (*httptest.Server, *events.Bus) {
+	gin.SetMode(gin.TestMode)
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(&MockLLMClient{}, &MockSemanticMapper{}, cache)
+	bus := events.NewBus()
+	qp.SetEventBus(bus)
+
+	server := httptest.NewServer(qp.SetupRoutes(allowAllAuthorizer{}))
+	t.Cleanup(server.Close)
+	return server, bus
+}
+
+// TestEventStream tests that published events are streamed to admins as Server-Sent Events
+func TestEventStream(t *testing.T) {
+	server, bus := newEventStreamServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/admin/events?types=auth_failure", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	require.Eventually(t, func() bool { return bus.SubscriberCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	// Only the filtered type is streamed
+	bus.Publish(events.TypeQueryProcessed, map[string]interface{}{"query": "error rate"})
+	bus.Publish(events.TypeAuthFailure, map[string]interface{}{"username": "mallory"})
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	var eventName, data string
+	for data == "" {
+		select {
+		case line, ok := <-lines:
+			require.True(t, ok, "stream closed before an event was received")
+			if strings.HasPrefix(line, "event:") {
+				eventName = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			}
+			if strings.HasPrefix(line, "data:") {
+				data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+	}
+
+	assert.Equal(t, "auth_failure", eventName)
+	var event events.Event
+	require.NoError(t, json.Unmarshal([]byte(data), &event))
+	assert.Equal(t, events.TypeAuthFailure, event.Type)
+	assert.Equal(t, "mallory", event.Data["username"])
+
+	// The subscriber is removed when the client disconnects
+	cancel()
+	assert.Eventually(t, func() bool { return bus.SubscriberCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+// TestEventStreamUnknownType tests that unknown event type filters are rejected
+func TestEventStreamUnknownType(t *testing.T) {
+	server, bus := newEventStreamServer(t)
+
+	resp, err := http.Get(server.URL + "/api/v1/admin/events?types=query_processed,bogus")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, 0, bus.SubscriberCount())
+}
+
+// TestProcessQueryPublishesEvent tests that processed queries are published to the event bus
+func TestProcessQueryPublishesEvent(t *testing.T) {
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_requests_total[5m]))`, Confidence: 0.9}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+	bus := events.NewBus()
+	qp.SetEventBus(bus)
+	sub := bus.Subscribe(events.TypeQueryProcessed)
+	defer bus.Unsubscribe(sub)
+
+	_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show error rate"})
+	require.NoError(t, err)
+
+	select {
+	case event := <-sub.Events():
+		assert.Equal(t, "show error rate", event.Data["query"])
+		assert.Equal(t, true, event.Data["success"])
+	case <-time.After(time.Second):
+		t.Fatal("no query_processed event published")
+	}
+}
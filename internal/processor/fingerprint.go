@@ -0,0 +1,133 @@
+package processor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/observability"
+)
+
+// fingerprintWindow is the sliding window of the query fingerprint rate limit
+const fingerprintWindow = time.Minute
+
+// fingerprintLimiter limits how often the same natural language query is
+// processed, whichever client sends it. Queries are identified by the hash of
+// their normalized text, so case and whitespace variants share a limit.
+type fingerprintLimiter struct {
+	limit     int
+	mu        sync.Mutex
+	requests  map[string][]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// SetQueryFingerprintRateLimit limits each distinct query to limitPerMinute
+// requests across all clients, so a single expensive query sent repeatedly
+// cannot overload the LLM or Mimir. It complements the per-client rate
+// limit; zero removes the limit.
+func (qp *QueryProcessor) SetQueryFingerprintRateLimit(limitPerMinute int) {
+	if limitPerMinute <= 0 {
+		qp.fingerprints = nil
+		return
+	}
+	qp.fingerprints = &fingerprintLimiter{
+		limit:    limitPerMinute,
+		requests: make(map[string][]time.Time),
+		now:      time.Now,
+	}
+}
+
+// queryFingerprint returns the hash identifying a query for rate limiting
+func queryFingerprint(query string) string {
+	sum := sha256.Sum256([]byte(normalizeEmbeddingQuery(query)))
+	return hex.EncodeToString(sum[:])
+}
+
+// allow records a request for the query and reports whether it is within the
+// limit. When it is not, it also returns how long until the oldest request
+// leaves the window.
+func (fl *fingerprintLimiter) allow(query string) (bool, time.Duration) {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	now := fl.now()
+	windowStart := now.Add(-fingerprintWindow)
+	if now.Sub(fl.lastSweep) >= fingerprintWindow {
+		// Forget queries that have not been sent within the window
+		for fingerprint, requests := range fl.requests {
+			if !requests[len(requests)-1].After(windowStart) {
+				delete(fl.requests, fingerprint)
+			}
+		}
+		fl.lastSweep = now
+	}
+
+	fingerprint := queryFingerprint(query)
+	requests := fl.requests[fingerprint]
+	recent := requests[:0]
+	for _, at := range requests {
+		if at.After(windowStart) {
+			recent = append(recent, at)
+		}
+	}
+	if len(recent) >= fl.limit {
+		fl.requests[fingerprint] = recent
+		return false, recent[0].Sub(windowStart)
+	}
+	fl.requests[fingerprint] = append(recent, now)
+	return true, 0
+}
+
+// checkQueryFingerprint returns a rate limit error if the query has been
+// sent too often within the window
+func (qp *QueryProcessor) checkQueryFingerprint(query string) error {
+	if qp.fingerprints == nil || query == "" {
+		return nil
+	}
+	allowed, retryAfter := qp.fingerprints.allow(query)
+	if allowed {
+		return nil
+	}
+	observability.GetGlobalMetrics().Inc(observability.MetricQueryFingerprintThrottled, nil)
+	return errors.NewQueryRateLimitedError(int(math.Ceil(retryAfter.Seconds())))
+}
+
+// queryFingerprintGate rejects requests whose query has been sent too often
+// within the window. Only the query field of the body is read; the body is
+// restored for the handler, which reports malformed bodies.
+func (qp *QueryProcessor) queryFingerprintGate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if qp.fingerprints == nil {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Next()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var req struct {
+			Query string `json:"query"`
+		}
+		if json.Unmarshal(body, &req) != nil {
+			c.Next()
+			return
+		}
+		if err := qp.checkQueryFingerprint(req.Query); err != nil {
+			setRetryAfter(c, err)
+			c.AbortWithStatusJSON(getErrorStatusCode(err), formatErrorResponse(err))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestQueryFingerprintRateLimit tests that the same query sent repeatedly by
+// different clients is eventually throttled, while other queries still pass
+func TestQueryFingerprintRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const limit = 3
+
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_requests_total[5m]))`, Confidence: 0.9}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+	qp.SetQueryFingerprintRateLimit(limit)
+	router := qp.SetupRoutes(nil)
+
+	send := func(client int, query string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(QueryRequest{Query: query})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/query", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", fmt.Sprintf("client-key-%d", client))
+		req.RemoteAddr = fmt.Sprintf("10.0.0.%d:4000", client)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case and whitespace variants are the same query
+	variants := []string{"show error rate", "Show  error rate", "SHOW ERROR RATE "}
+	for client := 0; client < limit; client++ {
+		w := send(client, variants[client])
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w := send(limit, "show error rate")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), string(errors.ErrCodeQueryRateLimited))
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	w = send(limit, "show latency")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Batch items count against the same limit
+	response := qp.ProcessBatch(context.Background(), &BatchQueryRequest{Queries: []QueryRequest{{Query: "show error rate"}}})
+	require.Len(t, response.Results, 1)
+	assert.Equal(t, BatchStatusError, response.Results[0].Status)
+}
+
+// TestFingerprintLimiterWindow tests that a throttled query is allowed again
+// once its oldest request leaves the window
+func TestFingerprintLimiterWindow(t *testing.T) {
+	qp := &QueryProcessor{}
+	qp.SetQueryFingerprintRateLimit(2)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	qp.fingerprints.now = func() time.Time { return now }
+
+	allowed, _ := qp.fingerprints.allow("error rate")
+	assert.True(t, allowed)
+	now = now.Add(20 * time.Second)
+	allowed, _ = qp.fingerprints.allow("error rate")
+	assert.True(t, allowed)
+
+	allowed, retryAfter := qp.fingerprints.allow("error rate")
+	assert.False(t, allowed)
+	assert.Equal(t, 40*time.Second, retryAfter)
+
+	now = now.Add(41 * time.Second)
+	allowed, _ = qp.fingerprints.allow("error rate")
+	assert.True(t, allowed)
+
+	qp.SetQueryFingerprintRateLimit(0)
+	assert.NoError(t, qp.checkQueryFingerprint("error rate"))
+}
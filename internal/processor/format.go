@@ -0,0 +1,207 @@
+package processor
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Result value formats, selected by the unit of the queried metric
+const (
+	unitFormatDuration = "duration" // e.g. "52.3ms"; the unit must be a time unit
+	unitFormatPercent  = "percent"  // e.g. "5.23%"; ratios are scaled by 100
+	unitFormatBytes    = "bytes"    // e.g. "1.2 MB", in decimal multiples
+	unitFormatNumber   = "number"   // e.g. "21.5 celsius"
+	unitFormatNone     = "none"     // Values are left unformatted
+)
+
+// defaultUnitFormats are the formats of the Prometheus base units
+var defaultUnitFormats = map[string]string{
+	"nanoseconds":  unitFormatDuration,
+	"microseconds": unitFormatDuration,
+	"milliseconds": unitFormatDuration,
+	"seconds":      unitFormatDuration,
+	"bytes":        unitFormatBytes,
+	"ratio":        unitFormatPercent,
+	"percent":      unitFormatPercent,
+}
+
+// durationUnitSeconds are the lengths of the time units in seconds
+var durationUnitSeconds = map[string]float64{
+	"nanoseconds":  1e-9,
+	"microseconds": 1e-6,
+	"milliseconds": 1e-3,
+	"seconds":      1,
+	"minutes":      60,
+	"hours":        3600,
+	"days":         86400,
+}
+
+// durationSteps are the units durations are displayed in, largest first
+var durationSteps = []struct {
+	suffix  string
+	seconds float64
+}{
+	{"d", 86400}, {"h", 3600}, {"m", 60}, {"s", 1}, {"ms", 1e-3}, {"µs", 1e-6}, {"ns", 1e-9},
+}
+
+// byteSteps are the decimal multiples byte sizes are displayed in
+var byteSteps = []string{"B", "kB", "MB", "GB", "TB", "PB"}
+
+// SetResultUnitFormats sets the format of result values by metric unit, e.g.
+// "seconds": "duration", on top of the defaults for the Prometheus base
+// units. "none" disables formatting a unit. Duration formats of units
+// that are not time units, and unknown formats, are ignored.
+func (qp *QueryProcessor) SetResultUnitFormats(formats map[string]string) {
+	qp.unitFormats = make(map[string]string, len(defaultUnitFormats)+len(formats))
+	for unit, format := range defaultUnitFormats {
+		qp.unitFormats[unit] = format
+	}
+	for unit, format := range formats {
+		switch format {
+		case unitFormatDuration:
+			if _, ok := durationUnitSeconds[unit]; !ok {
+				continue
+			}
+		case unitFormatPercent, unitFormatBytes, unitFormatNumber, unitFormatNone:
+		default:
+			continue
+		}
+		qp.unitFormats[unit] = format
+	}
+}
+
+// resultUnitFormats returns the configured formats by unit
+func (qp *QueryProcessor) resultUnitFormats() map[string]string {
+	if qp.unitFormats == nil {
+		return defaultUnitFormats
+	}
+	return qp.unitFormats
+}
+
+// resultUnit returns the unit of a query's values, taken from the unit suffix
+// of its metric names by Prometheus conventions, and whether the values are
+// per second. Only units with a format are recognized. Rates of time
+// counters, such as CPU seconds, are dimensionless and have no unit.
+func resultUnit(promql string, intent *QueryIntent, formats map[string]string) (string, bool) {
+	// Error rates are generated as the ratio of failed to total requests
+	if intent != nil && intent.Type == "errors" && strings.Contains(promql, "/") {
+		return "ratio", false
+	}
+	perSecond := strings.Contains(promql, "rate(")
+	for _, name := range selectorMetrics(promql) {
+		counter := strings.HasSuffix(name, "_total")
+		for _, suffix := range []string{"_bucket", "_count", "_sum", "_total"} {
+			name = strings.TrimSuffix(name, suffix)
+		}
+		unit := name[strings.LastIndex(name, "_")+1:]
+		if _, ok := formats[unit]; !ok {
+			continue
+		}
+		if counter && perSecond {
+			if _, ok := durationUnitSeconds[unit]; ok {
+				return "", false
+			}
+			return unit, true
+		}
+		return unit, false
+	}
+	return "", false
+}
+
+// formatResultValue renders a value in the given unit and format, returning
+// "" when it is not formatted
+func formatResultValue(value float64, unit, format string, perSecond bool) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return ""
+	}
+
+	var formatted string
+	switch format {
+	case unitFormatDuration:
+		seconds, ok := durationUnitSeconds[unit]
+		if !ok {
+			return ""
+		}
+		formatted = formatDuration(value * seconds)
+	case unitFormatPercent:
+		if unit == "ratio" {
+			value *= 100
+		}
+		formatted = formatSignificant(value) + "%"
+	case unitFormatBytes:
+		formatted = formatBytes(value)
+	case unitFormatNumber:
+		formatted = formatSignificant(value) + " " + unit
+	default:
+		return ""
+	}
+	if perSecond {
+		formatted += "/s"
+	}
+	return formatted
+}
+
+// formatDuration renders seconds in the largest unit they fill, e.g. "52.3ms"
+func formatDuration(seconds float64) string {
+	if seconds == 0 {
+		return "0s"
+	}
+	for _, step := range durationSteps {
+		if math.Abs(seconds) >= step.seconds {
+			return formatSignificant(seconds/step.seconds) + step.suffix
+		}
+	}
+	last := durationSteps[len(durationSteps)-1]
+	return formatSignificant(seconds/last.seconds) + last.suffix
+}
+
+// formatBytes renders a byte size in decimal multiples, e.g. "1.2 MB"
+func formatBytes(bytes float64) string {
+	step := 0
+	for math.Abs(bytes) >= 1000 && step < len(byteSteps)-1 {
+		bytes /= 1000
+		step++
+	}
+	return formatSignificant(bytes) + " " + byteSteps[step]
+}
+
+// formatSignificant renders a value with at most three significant digits,
+// without trailing zeros or an exponent
+func formatSignificant(value float64) string {
+	if value == 0 {
+		return "0"
+	}
+	decimals := 2 - int(math.Floor(math.Log10(math.Abs(value))))
+	if decimals < 0 {
+		decimals = 0
+	}
+	formatted := strconv.FormatFloat(value, 'f', decimals, 64)
+	if strings.Contains(formatted, ".") {
+		formatted = strings.TrimRight(strings.TrimRight(formatted, "0"), ".")
+	}
+	return formatted
+}
+
+// formatThresholdResult adds the threshold result's value and threshold, formatted
+// by the unit of the query, to the response metadata under "formatted". The
+// raw values are left intact.
+func (qp *QueryProcessor) formatThresholdResult(response *QueryResponse) {
+	formats := qp.resultUnitFormats()
+	unit, perSecond := resultUnit(response.PromQL, response.Intent, formats)
+	if unit == "" {
+		return
+	}
+	value := formatResultValue(response.Threshold.Value, unit, formats[unit], perSecond)
+	if value == "" {
+		return
+	}
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata["formatted"] = map[string]string{
+		"unit":      unit,
+		"value":     value,
+		"threshold": formatResultValue(response.Threshold.Threshold, unit, formats[unit], perSecond),
+	}
+}
@@ -0,0 +1,85 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFormatResultValue tests rendering values by unit and format
+func TestFormatResultValue(t *testing.T) {
+	tests := []struct {
+		value     float64
+		unit      string
+		format    string
+		perSecond bool
+		expected  string
+	}{
+		{0.0523, "seconds", unitFormatDuration, false, "52.3ms"},
+		{0.0523, "ratio", unitFormatPercent, false, "5.23%"},
+		{1.2e6, "bytes", unitFormatBytes, false, "1.2 MB"},
+		{1.2e6, "bytes", unitFormatBytes, true, "1.2 MB/s"},
+		{250, "milliseconds", unitFormatDuration, false, "250ms"},
+		{5400, "seconds", unitFormatDuration, false, "1.5h"},
+		{0, "seconds", unitFormatDuration, false, "0s"},
+		{42.5, "percent", unitFormatPercent, false, "42.5%"},
+		{512, "bytes", unitFormatBytes, false, "512 B"},
+		{21.456, "celsius", unitFormatNumber, false, "21.5 celsius"},
+		{0.5, "seconds", unitFormatNone, false, ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, formatResultValue(tt.value, tt.unit, tt.format, tt.perSecond), "%v %s", tt.value, tt.unit)
+	}
+}
+
+// TestResultUnit tests that the unit is taken from the metric name suffix
+func TestResultUnit(t *testing.T) {
+	tests := []struct {
+		promql    string
+		intent    *QueryIntent
+		unit      string
+		perSecond bool
+	}{
+		{`histogram_quantile(0.95, sum by (le) (rate(http_request_duration_seconds_bucket[5m])))`, nil, "seconds", false},
+		{`sum(rate(node_network_receive_bytes_total[5m]))`, nil, "bytes", true},
+		{`process_resident_memory_bytes{job="api"}`, nil, "bytes", false},
+		{`sum(rate(errors_total[5m])) / sum(rate(requests_total[5m]))`, &QueryIntent{Type: "errors"}, "ratio", false},
+		// CPU seconds per second are dimensionless
+		{`rate(process_cpu_seconds_total[5m])`, nil, "", false},
+		{`sum(rate(http_requests_total[5m]))`, nil, "", false},
+	}
+	for _, tt := range tests {
+		unit, perSecond := resultUnit(tt.promql, tt.intent, defaultUnitFormats)
+		assert.Equal(t, tt.unit, unit, tt.promql)
+		assert.Equal(t, tt.perSecond, perSecond, tt.promql)
+	}
+}
+
+// TestThresholdResultFormatted tests that threshold results carry formatted
+// values in the metadata, alongside the raw ones, using configured formats
+func TestThresholdResultFormatted(t *testing.T) {
+	llmClient := &MockLLMClient{response: &llm.Response{
+		PromQL:     `histogram_quantile(0.95, rate(http_request_duration_seconds_bucket[5m]))`,
+		Confidence: 0.9,
+	}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+	qp.SetQueryExecutor(&mockQueryExecutor{result: `{"resultType": "vector", "result": [{"metric": {}, "value": [1700000000, "0.0523"]}]}`})
+
+	threshold := 0.1
+	response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "p95 latency", Threshold: &threshold})
+	require.NoError(t, err)
+	require.NotNil(t, response.Threshold)
+	assert.Equal(t, 0.0523, response.Threshold.Value)
+	assert.Equal(t, map[string]string{"unit": "seconds", "value": "52.3ms", "threshold": "100ms"}, response.Metadata["formatted"])
+
+	qp.SetResultUnitFormats(map[string]string{"seconds": unitFormatNone})
+	response, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "p95 latency", Threshold: &threshold})
+	require.NoError(t, err)
+	assert.NotContains(t, response.Metadata, "formatted")
+}
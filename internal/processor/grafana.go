@@ -0,0 +1,140 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+)
+
+// Response formats a query request may ask for in addition to the PromQL
+const (
+	responseFormatDefault = ""
+	responseFormatGrafana = "grafana"
+)
+
+// Grafana panel types generated for range and instant queries
+const (
+	grafanaPanelTimeSeries = "timeseries"
+	grafanaPanelStat       = "stat"
+)
+
+// grafanaMetricPattern matches metric names in a PromQL expression
+var grafanaMetricPattern = regexp.MustCompile(`[a-zA-Z_:][a-zA-Z0-9_:]*`)
+
+// GrafanaPanel is a minimal Grafana panel that can be pasted into a dashboard
+type GrafanaPanel struct {
+	Type        string              `json:"type"` // "timeseries" for range queries, "stat" for instant queries
+	Title       string              `json:"title"`
+	Datasource  GrafanaDatasource   `json:"datasource"`
+	Targets     []GrafanaTarget     `json:"targets"`
+	FieldConfig GrafanaFieldConfig  `json:"fieldConfig"`
+	Options     map[string]struct{} `json:"options"`
+}
+
+// GrafanaDatasource selects the panel data source by type
+type GrafanaDatasource struct {
+	Type string `json:"type"`
+}
+
+// GrafanaTarget is a Prometheus query of a panel
+type GrafanaTarget struct {
+	RefID   string `json:"refId"`
+	Expr    string `json:"expr"`
+	Range   bool   `json:"range"`
+	Instant bool   `json:"instant"`
+}
+
+// GrafanaFieldConfig holds the panel field defaults
+type GrafanaFieldConfig struct {
+	Defaults  GrafanaFieldDefaults `json:"defaults"`
+	Overrides []struct{}           `json:"overrides"`
+}
+
+// GrafanaFieldDefaults sets the unit values are displayed in
+type GrafanaFieldDefaults struct {
+	Unit string `json:"unit"`
+}
+
+// validateFormat checks a requested response format
+func validateFormat(format string) error {
+	switch format {
+	case responseFormatDefault, responseFormatGrafana:
+		return nil
+	}
+	return errors.NewInvalidInputError("format", fmt.Sprintf("unsupported format %q; supported formats: %s", format, responseFormatGrafana))
+}
+
+// withGrafanaPanel adds a Grafana panel for the generated query to the
+// response. Responses awaiting confirmation have no panel until confirmed.
+func (qp *QueryProcessor) withGrafanaPanel(req *QueryRequest, response *QueryResponse) *QueryResponse {
+	if response.RequiresConfirmation || response.PromQL == "" {
+		return response
+	}
+	// Cached responses do not carry a typed intent, so it is classified again
+	intent, err := qp.intentClassifier.ClassifyIntent(req.Query)
+	if err != nil {
+		intent = &QueryIntent{}
+	}
+	response.GrafanaPanel = grafanaPanel(req.Query, response.PromQL, intent)
+	return response
+}
+
+// grafanaPanel wraps a PromQL expression in a Grafana panel. Queries over a
+// time window are shown as a time series; point-in-time queries as a stat.
+func grafanaPanel(title, promql string, intent *QueryIntent) *GrafanaPanel {
+	isRange := intent.Start != nil || intent.TimeRange != ""
+	panelType := grafanaPanelStat
+	if isRange {
+		panelType = grafanaPanelTimeSeries
+	}
+
+	return &GrafanaPanel{
+		Type:       panelType,
+		Title:      title,
+		Datasource: GrafanaDatasource{Type: "prometheus"},
+		Targets: []GrafanaTarget{{
+			RefID:   "A",
+			Expr:    promql,
+			Range:   isRange,
+			Instant: !isRange,
+		}},
+		FieldConfig: GrafanaFieldConfig{
+			Defaults:  GrafanaFieldDefaults{Unit: grafanaUnit(promql, intent)},
+			Overrides: []struct{}{},
+		},
+		Options: map[string]struct{}{},
+	}
+}
+
+// grafanaUnit infers the Grafana display unit from the metric names in the
+// expression, following Prometheus unit suffix conventions
+func grafanaUnit(promql string, intent *QueryIntent) string {
+	// Error rates are generated as the ratio of failed to total requests
+	if intent.Type == "errors" && strings.Contains(promql, "/") {
+		return "percentunit"
+	}
+	for _, name := range grafanaMetricPattern.FindAllString(promql, -1) {
+		name = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(name, "_bucket"), "_sum"), "_total")
+		switch {
+		case strings.HasSuffix(name, "_seconds"):
+			return "s"
+		case strings.HasSuffix(name, "_milliseconds"):
+			return "ms"
+		case strings.HasSuffix(name, "_bytes"):
+			if strings.Contains(promql, "rate(") {
+				return "Bps"
+			}
+			return "bytes"
+		case strings.HasSuffix(name, "_ratio"):
+			return "percentunit"
+		case strings.HasSuffix(name, "_percent"):
+			return "percent"
+		}
+	}
+	if strings.Contains(promql, "rate(") {
+		return "reqps"
+	}
+	return "short"
+}
@@ -0,0 +1,132 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGrafanaPanel tests the panel type and unit chosen for generated queries
+func TestGrafanaPanel(t *testing.T) {
+	classifier := NewIntentClassifier()
+
+	tests := []struct {
+		name      string
+		query     string
+		promql    string
+		panelType string
+		unit      string
+	}{
+		{
+			name:      "range query",
+			query:     "p95 latency for api over the last 1 hour",
+			promql:    `histogram_quantile(0.95, sum by (le) (rate(http_request_duration_seconds_bucket[5m])))`,
+			panelType: grafanaPanelTimeSeries,
+			unit:      "s",
+		},
+		{
+			name:      "instant query",
+			query:     "current memory usage",
+			promql:    `sum(process_resident_memory_bytes)`,
+			panelType: grafanaPanelStat,
+			unit:      "bytes",
+		},
+		{
+			name:      "error ratio",
+			query:     "error rate in the last 5 minutes",
+			promql:    `sum(rate(http_requests_total{status=~"5.."}[5m])) / sum(rate(http_requests_total[5m]))`,
+			panelType: grafanaPanelTimeSeries,
+			unit:      "percentunit",
+		},
+		{
+			name:      "request rate",
+			query:     "requests per second",
+			promql:    `sum(rate(http_requests_total[5m]))`,
+			panelType: grafanaPanelStat,
+			unit:      "reqps",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			intent, err := classifier.ClassifyIntent(tt.query)
+			require.NoError(t, err)
+
+			panel := grafanaPanel(tt.query, tt.promql, intent)
+			assert.Equal(t, tt.panelType, panel.Type)
+			assert.Equal(t, tt.query, panel.Title)
+			assert.Equal(t, tt.unit, panel.FieldConfig.Defaults.Unit)
+			require.Len(t, panel.Targets, 1)
+			assert.Equal(t, tt.promql, panel.Targets[0].Expr)
+			assert.Equal(t, tt.panelType == grafanaPanelTimeSeries, panel.Targets[0].Range)
+			assert.NotEqual(t, panel.Targets[0].Range, panel.Targets[0].Instant)
+		})
+	}
+}
+
+// TestQueryHandlerGrafanaFormat tests the panel JSON returned for ?format=grafana
+func TestQueryHandlerGrafanaFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	promql := `sum(rate(http_requests_total[5m]))`
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: promql, Confidence: 0.9}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	router := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache).SetupRoutes(nil)
+
+	post := func(path string, request QueryRequest) *httptest.ResponseRecorder {
+		body, err := json.Marshal(request)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("range query panel", func(t *testing.T) {
+		w := post("/api/v1/query?format=grafana", QueryRequest{Query: "requests over the last 1 hour"})
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			GrafanaPanel map[string]interface{} `json:"grafana_panel"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.NotNil(t, response.GrafanaPanel)
+		assert.Equal(t, "timeseries", response.GrafanaPanel["type"])
+
+		targets := response.GrafanaPanel["targets"].([]interface{})
+		require.Len(t, targets, 1)
+		assert.Equal(t, promql, targets[0].(map[string]interface{})["expr"])
+		assert.Equal(t, true, targets[0].(map[string]interface{})["range"])
+	})
+
+	t.Run("format in request body on a cache hit", func(t *testing.T) {
+		w := post("/api/v1/query", QueryRequest{Query: "requests over the last 1 hour", Format: "grafana"})
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response QueryResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, response.CacheHit)
+		require.NotNil(t, response.GrafanaPanel)
+		assert.Equal(t, grafanaPanelTimeSeries, response.GrafanaPanel.Type)
+	})
+
+	t.Run("omitted by default", func(t *testing.T) {
+		w := post("/api/v1/query", QueryRequest{Query: "requests over the last 1 hour"})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "grafana_panel")
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		w := post("/api/v1/query?format=csv", QueryRequest{Query: "requests over the last 1 hour"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,67 @@
+package processor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHistoryHandler tests that the history lists the most recent queries
+// first, one page at a time
+func TestHistoryHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Now()
+	mapper := &MockSemanticMapper{storedQueries: []semantic.StoredQuery{
+		{ID: "q1", Query: "oldest", LastUsedAt: &now},
+		{ID: "q2", Query: "middle", LastUsedAt: &now},
+		{ID: "q3", Query: "newest", LastUsedAt: &now},
+	}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	router := NewQueryProcessor(&MockLLMClient{}, mapper, cache).SetupRoutes(nil)
+
+	history := func(query string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/history"+query, nil))
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return w.Code, response
+	}
+	queryTexts := func(response map[string]interface{}) []string {
+		var texts []string
+		for _, q := range response["queries"].([]interface{}) {
+			texts = append(texts, q.(map[string]interface{})["query"].(string))
+		}
+		return texts
+	}
+
+	code, response := history("")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"newest", "middle", "oldest"}, queryTexts(response))
+	assert.Equal(t, float64(defaultHistoryLimit), response["limit"])
+	assert.NotContains(t, response, "next_cursor")
+
+	code, response = history("?limit=2")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"newest", "middle"}, queryTexts(response))
+	assert.Equal(t, float64(2), response["count"])
+	require.IsType(t, "", response["next_cursor"])
+
+	code, response = history("?limit=2&cursor=" + response["next_cursor"].(string))
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"oldest"}, queryTexts(response))
+	assert.NotContains(t, response, "next_cursor")
+
+	for _, query := range []string{"?limit=0", "?limit=abc", "?cursor=bogus", "?limit=100000"} {
+		code, _ = history(query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
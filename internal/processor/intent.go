@@ -2,25 +2,71 @@ package processor
 
 import (
 	"fmt"
+	"math"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // QueryIntent represents the classified intent of a query
 type QueryIntent struct {
-	Type        string            `json:"type"`        // "metrics", "errors", "performance", "comparison"
-	Action      string            `json:"action"`      // "show", "compare", "analyze", "alert"
-	Service     string            `json:"service"`     // extracted service name
-	Metric      string            `json:"metric"`      // extracted metric type
-	TimeRange   string            `json:"time_range"`  // parsed time range
-	Aggregation string            `json:"aggregation"` // "rate", "sum", "avg", etc.
-	Filters     map[string]string `json:"filters"`     // additional filters
+	Type        string            `json:"type"`               // "metrics", "errors", "performance", "comparison"
+	Action      string            `json:"action"`             // "show", "compare", "analyze", "alert"
+	Service     string            `json:"service"`            // extracted service name
+	Metric      string            `json:"metric"`             // extracted metric type, or the exact metric name if given
+	TimeRange   string            `json:"time_range"`         // parsed time range
+	Aggregation string            `json:"aggregation"`        // "rate", "sum", "avg", etc.
+	Filters     map[string]string `json:"filters"`            // additional filters
+	Confidence  float64           `json:"confidence"`         // strength of the classification, 0-1
+	Ranking     string            `json:"ranking,omitempty"`  // "top" or "bottom" for top/bottom-N queries
+	Limit       int               `json:"limit,omitempty"`    // N for top/bottom-N queries
+	Start       *time.Time        `json:"start,omitempty"`    // start of an absolute time window
+	End         *time.Time        `json:"end,omitempty"`      // end of an absolute time window
+	Quantile    float64           `json:"quantile,omitempty"` // histogram_quantile value, e.g. 0.99 for "p99"
+	Aliases     map[string]string `json:"aliases,omitempty"`  // canonical metric names of aliases in the query
+
+	// quantileNamed is set when the query names the percentile, rather than
+	// Quantile holding the latency default
+	quantileNamed bool
 }
 
+// maxRankingLimit is the largest N accepted for top/bottom-N queries
+const maxRankingLimit = 100
+
+// defaultLatencyQuantile is the quantile of latency queries that name no percentile
+const defaultLatencyQuantile = 0.95
+
+// Intent confidence scoring. A single unambiguous keyword match is a strong
+// classification; no match means the type is a default guess, and each
+// additional competing match makes the classification more ambiguous.
+const (
+	unmatchedIntentConfidence = 0.3
+	matchedIntentConfidence   = 0.8
+	ambiguityPenalty          = 0.15
+	minMatchedConfidence      = 0.4
+	serviceConfidenceBonus    = 0.1
+	timeRangeConfidenceBonus  = 0.05
+)
+
+// intentTypePatterns are the patterns that indicate a query type
+var intentTypePatterns = []string{"error_rate", "latency", "throughput", "availability", "comparison"}
+
 // IntentClassifier classifies natural language queries
 type IntentClassifier struct {
 	patterns map[string]*regexp.Regexp
+	location *time.Location   // timezone for absolute times without an explicit zone
+	now      func() time.Time // current time, for resolving "today" and "yesterday"
+	aliases  []metricAlias    // metric aliases, longest first
 }
 
+// metricNamePattern matches words shaped like Prometheus metric names: snake
+// case ending in a conventional suffix (e.g. "http_requests_total"), with at
+// least three segments (e.g. "process_open_fds"), or a recording rule name
+// (e.g. "job:http_requests:rate5m"). Ordinary snake case words such as
+// "user_service" do not match.
+var metricNamePattern = regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9]*(?:(?:_[a-zA-Z0-9]+)*_(?:total|count|sum|bucket|seconds|bytes|ratio|info)|(?:_[a-zA-Z0-9]+){2,}|[a-zA-Z0-9_]*:[a-zA-Z0-9_:]+)\b`)
+
 // NewIntentClassifier creates a new intent classifier
 func NewIntentClassifier() *IntentClassifier {
 	patterns := map[string]*regexp.Regexp{
@@ -31,8 +77,18 @@ func NewIntentClassifier() *IntentClassifier {
 		"comparison":   regexp.MustCompile(`(?i)\b(compare|vs|versus|against)\b`),
 		"service_name": regexp.MustCompile(`(?i)\b(service|app|application)\s+(\w+[-\w]*)`),
 		"time_range":   regexp.MustCompile(`(?i)\b(last|past|in the)\s+(\d+)\s*(minute|hour|day|week)s?\b`),
+		"ranking":      regexp.MustCompile(`(?i)\b(top|bottom|highest|lowest)\s+(\d+)\b`),
+		"percentile":   regexp.MustCompile(`(?i)\b(?:p(\d+(?:\.\d+)?)|(\d+(?:\.\d+)?)(?:st|nd|rd|th)?\s*percentile|(median))\b`),
+		"metric_name":  metricNamePattern,
+		"clock_window": clockWindowPattern,
+		"date_window":  dateWindowPattern,
 	}
-	return &IntentClassifier{patterns: patterns}
+	return &IntentClassifier{patterns: patterns, location: time.UTC, now: time.Now}
+}
+
+// SetLocation sets the timezone used to interpret absolute times such as "2pm"
+func (ic *IntentClassifier) SetLocation(location *time.Location) {
+	ic.location = location
 }
 
 // ClassifyIntent analyzes the natural language query and extracts intent
@@ -51,6 +107,26 @@ func (ic *IntentClassifier) ClassifyIntent(query string) (*QueryIntent, error) {
 		intent.TimeRange = fmt.Sprintf("%s%s", match[2], match[3])
 	}
 
+	// Extract an absolute window, e.g. "between 2pm and 4pm yesterday"
+	ic.extractAbsoluteWindow(query, intent)
+
+	// Extract top/bottom-N ranking
+	if match := ic.patterns["ranking"].FindStringSubmatch(query); len(match) > 2 {
+		if n, err := strconv.Atoi(match[2]); err == nil && n > 0 && n <= maxRankingLimit {
+			intent.Limit = n
+			switch strings.ToLower(match[1]) {
+			case "bottom", "lowest":
+				intent.Ranking = "bottom"
+			default:
+				intent.Ranking = "top"
+			}
+		}
+	}
+
+	// Extract a requested percentile, e.g. "p99", "95th percentile" or "median"
+	intent.Quantile = ic.extractQuantile(query)
+	intent.quantileNamed = intent.Quantile != 0
+
 	// Classify query type
 	switch {
 	case ic.patterns["error_rate"].MatchString(query):
@@ -63,6 +139,9 @@ func (ic *IntentClassifier) ClassifyIntent(query string) (*QueryIntent, error) {
 		intent.Action = "show"
 		intent.Metric = "latency"
 		intent.Aggregation = "avg"
+		if intent.Quantile == 0 {
+			intent.Quantile = defaultLatencyQuantile
+		}
 	case ic.patterns["throughput"].MatchString(query):
 		intent.Type = "performance"
 		intent.Action = "show"
@@ -76,5 +155,89 @@ func (ic *IntentClassifier) ClassifyIntent(query string) (*QueryIntent, error) {
 		intent.Action = "show"
 	}
 
+	// An explicit metric name (e.g. "rate of http_requests_total") takes
+	// precedence over the inferred metric type
+	explicit := false
+	for _, name := range ic.patterns["metric_name"].FindAllString(query, -1) {
+		if name != intent.Service {
+			intent.Metric = name
+			explicit = true
+			break
+		}
+	}
+
+	// Aliases (e.g. "requests") resolve to canonical metric names, taking
+	// precedence over the inferred metric type but not an explicit metric name
+	aliases, aliasMetric := ic.resolveAliases(query)
+	intent.Aliases = aliases
+	if canonical, ok := aliases[strings.ToLower(intent.Metric)]; explicit && ok {
+		intent.Metric = canonical
+	} else if !explicit && aliasMetric != "" {
+		intent.Metric = aliasMetric
+	}
+
+	intent.Confidence = ic.scoreIntent(query, intent)
+
 	return intent, nil
 }
+
+// extractQuantile returns the quantile for a percentile named in query, or
+// zero if none is named or it is not strictly between 0 and 1. Shorthand such
+// as "p999" and "p9999" names the 99.9th and 99.99th percentiles.
+func (ic *IntentClassifier) extractQuantile(query string) float64 {
+	match := ic.patterns["percentile"].FindStringSubmatch(query)
+	if match == nil {
+		return 0
+	}
+	if match[3] != "" {
+		return 0.5
+	}
+
+	percentile := match[1]
+	if len(percentile) > 2 && strings.HasPrefix(percentile, "99") && !strings.Contains(percentile, ".") {
+		percentile = "99." + percentile[2:]
+	}
+	if percentile == "" {
+		percentile = match[2]
+	}
+	value, err := strconv.ParseFloat(percentile, 64)
+	if err != nil {
+		return 0
+	}
+	// Round away float error from the division, e.g. p99.9 is 0.999
+	quantile := math.Round(value*1e4) / 1e6
+	if quantile <= 0 || quantile >= 1 {
+		return 0
+	}
+	return quantile
+}
+
+// scoreIntent estimates how reliable the classification of query is
+func (ic *IntentClassifier) scoreIntent(query string, intent *QueryIntent) float64 {
+	matches := 0
+	for _, name := range intentTypePatterns {
+		if ic.patterns[name].MatchString(query) {
+			matches++
+		}
+	}
+
+	confidence := unmatchedIntentConfidence
+	if matches > 0 {
+		confidence = matchedIntentConfidence - ambiguityPenalty*float64(matches-1)
+		if confidence < minMatchedConfidence {
+			confidence = minMatchedConfidence
+		}
+	}
+
+	if intent.Service != "" {
+		confidence += serviceConfidenceBonus
+	}
+	if intent.TimeRange != "" || intent.Start != nil {
+		confidence += timeRangeConfidenceBonus
+	}
+
+	if confidence > 1.0 {
+		confidence = 1.0
+	}
+	return confidence
+}
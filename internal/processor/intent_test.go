@@ -2,8 +2,16 @@
 package processor
 
 import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -521,3 +529,165 @@ func BenchmarkClassifyComplexQuery(b *testing.B) {
 		_, _ = ic.ClassifyIntent(query)
 	}
 }
+
+// TestIntentConfidence tests that clear queries score higher than ambiguous ones
+func TestIntentConfidence(t *testing.T) {
+	ic := NewIntentClassifier()
+
+	score := func(query string) float64 {
+		intent, err := ic.ClassifyIntent(query)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, intent.Confidence, 0.0)
+		assert.LessOrEqual(t, intent.Confidence, 1.0)
+		return intent.Confidence
+	}
+
+	specific := score("Show me the latency of service checkout in the last 15 minutes")
+	single := score("Show me latency")
+	ambiguous := score("Compare latency versus throughput requests")
+	unmatched := score("what is going on")
+
+	assert.Greater(t, specific, single, "service and time range should strengthen the classification")
+	assert.Greater(t, single, ambiguous, "competing keywords should weaken the classification")
+	assert.Greater(t, ambiguous, unmatched, "a keyword match should beat a default guess")
+	assert.Less(t, unmatched, lowIntentConfidence)
+}
+
+// TestAdjustConfidence tests that weak intent classification lowers response confidence
+func TestAdjustConfidence(t *testing.T) {
+	assert.Equal(t, 0.9, adjustConfidence(0.9, 0.8))
+	assert.Equal(t, 0.9, adjustConfidence(0.9, lowIntentConfidence))
+	assert.InDelta(t, 0.72, adjustConfidence(0.9, 0.3), 1e-9)
+	assert.Less(t, adjustConfidence(0.9, 0.1), adjustConfidence(0.9, 0.3))
+}
+
+// TestExtractRanking tests detection of top/bottom-N phrasing
+func TestExtractRanking(t *testing.T) {
+	ic := NewIntentClassifier()
+
+	tests := []struct {
+		name            string
+		query           string
+		expectedRanking string
+		expectedLimit   int
+	}{
+		{
+			name:            "top N",
+			query:           "top 5 services by error rate",
+			expectedRanking: "top",
+			expectedLimit:   5,
+		},
+		{
+			name:            "bottom N",
+			query:           "Show the bottom 3 pods by throughput",
+			expectedRanking: "bottom",
+			expectedLimit:   3,
+		},
+		{
+			name:            "highest N",
+			query:           "Which are the highest 10 endpoints by latency?",
+			expectedRanking: "top",
+			expectedLimit:   10,
+		},
+		{
+			name:            "lowest N",
+			query:           "LOWEST 2 services by availability",
+			expectedRanking: "bottom",
+			expectedLimit:   2,
+		},
+		{
+			name:            "zero is rejected",
+			query:           "top 0 services by error rate",
+			expectedRanking: "",
+			expectedLimit:   0,
+		},
+		{
+			name:            "excessive N is rejected",
+			query:           "top 5000 services by error rate",
+			expectedRanking: "",
+			expectedLimit:   0,
+		},
+		{
+			name:            "no ranking",
+			query:           "error rate for the top service",
+			expectedRanking: "",
+			expectedLimit:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			intent, err := ic.ClassifyIntent(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedRanking, intent.Ranking)
+			assert.Equal(t, tt.expectedLimit, intent.Limit)
+		})
+	}
+}
+
+// TestExtractQuantile tests that requested percentiles are captured as quantiles
+func TestExtractQuantile(t *testing.T) {
+	ic := NewIntentClassifier()
+
+	tests := []struct {
+		name             string
+		query            string
+		expectedQuantile float64
+	}{
+		{name: "p50", query: "p50 latency for service api", expectedQuantile: 0.5},
+		{name: "median", query: "median response time", expectedQuantile: 0.5},
+		{name: "ordinal percentile", query: "99th percentile latency", expectedQuantile: 0.99},
+		{name: "p99", query: "show P99 duration of checkout", expectedQuantile: 0.99},
+		{name: "fractional percentile", query: "p99.9 latency", expectedQuantile: 0.999},
+		{name: "p999 shorthand", query: "p999 latency for service api", expectedQuantile: 0.999},
+		{name: "p9999 shorthand", query: "p9999 latency", expectedQuantile: 0.9999},
+		{name: "percentile without ordinal", query: "90 percentile response time", expectedQuantile: 0.9},
+		{name: "latency defaults to p95", query: "what is the latency of the api", expectedQuantile: defaultLatencyQuantile},
+		{name: "out of range is ignored", query: "p100 latency", expectedQuantile: defaultLatencyQuantile},
+		{name: "zero is ignored", query: "0th percentile latency", expectedQuantile: defaultLatencyQuantile},
+		{name: "non-latency query has none", query: "show error rate", expectedQuantile: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			intent, err := ic.ClassifyIntent(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedQuantile, intent.Quantile)
+		})
+	}
+}
+
+// TestQueryResponseIntent tests that the classified intent is returned as a
+// typed top-level field, and still in the metadata for older clients
+func TestQueryResponseIntent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_requests_total{service="checkout",status=~"5.."}[1h]))`, Confidence: 0.9}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	router := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache).SetupRoutes(nil)
+
+	body, _ := json.Marshal(QueryRequest{Query: "error rate for service checkout in the last 1 hour"})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/query", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response QueryResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.NotNil(t, response.Intent)
+	assert.Equal(t, "errors", response.Intent.Type)
+	assert.Equal(t, "checkout", response.Intent.Service)
+	assert.Equal(t, "1hour", response.Intent.TimeRange)
+	assert.Greater(t, response.Intent.Confidence, 0.0)
+
+	// The deprecated metadata copy carries the same intent
+	var metadata struct {
+		Intent QueryIntent `json:"intent"`
+	}
+	var raw struct {
+		Metadata json.RawMessage `json:"metadata"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &raw))
+	require.NoError(t, json.Unmarshal(raw.Metadata, &metadata))
+	assert.Equal(t, *response.Intent, metadata.Intent)
+}
@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+)
+
+// defaultLanguage is the language explanations are written in unless a
+// request asks for another one
+const defaultLanguage = "en"
+
+// SetSupportedLanguages sets the languages, e.g. "de" or "ja", requests may
+// ask explanations to be written in. Languages are matched
+// case-insensitively; English is always supported.
+func (qp *QueryProcessor) SetSupportedLanguages(languages []string) {
+	qp.supportedLanguages = make(map[string]bool, len(languages))
+	for _, language := range languages {
+		if language = normalizeLanguage(language); language != "" {
+			qp.supportedLanguages[language] = true
+		}
+	}
+}
+
+// normalizeLanguage returns the comparable form of a language
+func normalizeLanguage(language string) string {
+	return strings.ToLower(strings.TrimSpace(language))
+}
+
+// explanationLanguage returns the language the request's explanation is
+// written in
+func explanationLanguage(req *QueryRequest) string {
+	language := normalizeLanguage(req.Language)
+	if language == "" || language == "english" {
+		return defaultLanguage
+	}
+	return language
+}
+
+// validateLanguage checks a requested explanation language against the
+// supported languages
+func (qp *QueryProcessor) validateLanguage(req *QueryRequest) error {
+	language := explanationLanguage(req)
+	if language == defaultLanguage || qp.supportedLanguages[language] {
+		return nil
+	}
+
+	supported := []string{defaultLanguage}
+	for name := range qp.supportedLanguages {
+		if name != defaultLanguage {
+			supported = append(supported, name)
+		}
+	}
+	sort.Strings(supported[1:])
+	return errors.NewInvalidInputError("language",
+		fmt.Sprintf("language %q is not supported; supported languages: %s", req.Language, strings.Join(supported, ", ")))
+}
+
+// writeLanguagePrompt asks for the explanation in the requested language,
+// leaving the query itself untouched
+func writeLanguagePrompt(promptBuilder *strings.Builder, req *QueryRequest) {
+	language := explanationLanguage(req)
+	if language == defaultLanguage {
+		return
+	}
+	promptBuilder.WriteString(fmt.Sprintf("\nExplanation Language: %s\n", language))
+	promptBuilder.WriteString(fmt.Sprintf("Write any explanation in the language %q. The PromQL query, metric names, label names and label values stay exactly as they are, untranslated.\n", language))
+}
@@ -0,0 +1,64 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExplanationLanguage tests that requests can ask for the explanation in
+// a supported language without the query being translated
+func TestExplanationLanguage(t *testing.T) {
+	mapper := &MockSemanticMapper{services: []semantic.Service{
+		{ID: "svc-1", Name: "api", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+	}}
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_requests_total{service="api"}[5m]))`, Confidence: 0.8}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, mapper, cache)
+	qp.SetSupportedLanguages([]string{"de", "ja"})
+
+	t.Run("language instruction in the prompt", func(t *testing.T) {
+		prompt, err := qp.buildPrompt(context.Background(), &QueryRequest{Query: "request rate", Language: "DE"}, &QueryIntent{}, nil)
+		require.NoError(t, err)
+		assert.Contains(t, prompt, "Explanation Language: de\n")
+		assert.Contains(t, prompt, `Write any explanation in the language "de". The PromQL query, metric names, label names and label values stay exactly as they are, untranslated.`)
+	})
+
+	t.Run("no instruction for English", func(t *testing.T) {
+		for _, language := range []string{"", "en", "English"} {
+			prompt, err := qp.buildPrompt(context.Background(), &QueryRequest{Query: "request rate", Language: language}, &QueryIntent{}, nil)
+			require.NoError(t, err)
+			assert.NotContains(t, prompt, "Explanation Language", "language %q", language)
+		}
+	})
+
+	t.Run("unsupported language is rejected", func(t *testing.T) {
+		_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate", Language: "fr"})
+		require.Error(t, err)
+		enhanced, ok := err.(*errors.EnhancedError)
+		require.True(t, ok)
+		assert.Equal(t, errors.ErrCodeInvalidInput, enhanced.Code)
+		assert.Contains(t, enhanced.Error(), `language "fr" is not supported; supported languages: en, de, ja`)
+	})
+
+	t.Run("languages are cached separately", func(t *testing.T) {
+		english := &QueryRequest{Query: "request rate"}
+		german := &QueryRequest{Query: "request rate", Language: "de"}
+		assert.NotEqual(t, cacheQuery(english, ""), cacheQuery(german, ""))
+		assert.Equal(t, cacheQuery(english, ""), cacheQuery(&QueryRequest{Query: "request rate", Language: "en"}, ""))
+
+		_, err := qp.ProcessQuery(context.Background(), english)
+		require.NoError(t, err)
+		response, err := qp.ProcessQuery(context.Background(), german)
+		require.NoError(t, err)
+		assert.False(t, response.CacheHit)
+		assert.Equal(t, llmClient.response.PromQL, response.PromQL)
+	})
+}
@@ -0,0 +1,181 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+)
+
+// defaultMaintenanceMessage is returned to rejected queries when maintenance
+// mode is enabled without a message
+const defaultMaintenanceMessage = "The query service is temporarily unavailable for maintenance. Please try again later."
+
+// maintenanceKey holds the maintenance mode toggled at runtime, shared by
+// every instance using the cache
+const maintenanceKey = "maintenance"
+
+// maintenanceMode is the maintenance mode an instance starts with, used until
+// it is toggled at runtime. While enabled, new queries are rejected; health
+// and auth endpoints stay reachable so the deployment is not pulled from
+// service.
+type maintenanceMode struct {
+	mu      sync.RWMutex
+	enabled bool
+	message string
+	since   time.Time
+}
+
+// MaintenanceStatus reports whether maintenance mode is enabled
+type MaintenanceStatus struct {
+	Enabled bool       `json:"enabled"`
+	Message string     `json:"message,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+// MaintenanceRequest toggles maintenance mode
+type MaintenanceRequest struct {
+	Enabled *bool  `json:"enabled"`
+	Message string `json:"message,omitempty"` // Defaults to a generic message
+}
+
+// SetMaintenanceMode sets the maintenance mode of this instance until it is
+// toggled at runtime. While enabled, the query endpoints answer 503 with
+// message, or a default message when empty.
+func (qp *QueryProcessor) SetMaintenanceMode(enabled bool, message string) {
+	qp.maintenance.mu.Lock()
+	defer qp.maintenance.mu.Unlock()
+
+	if enabled && !qp.maintenance.enabled {
+		qp.maintenance.since = time.Now()
+	}
+	qp.maintenance.enabled = enabled
+	qp.maintenance.message = maintenanceMessage(message)
+}
+
+// maintenanceMessage returns the message of rejected queries, defaulting to a
+// generic one
+func maintenanceMessage(message string) string {
+	if message = strings.TrimSpace(message); message == "" {
+		return defaultMaintenanceMessage
+	}
+	return message
+}
+
+// MaintenanceStatus returns the current maintenance mode: the one toggled at
+// runtime if any, otherwise the one the instance started with. If the cache
+// cannot be read, the instance's own mode applies.
+func (qp *QueryProcessor) MaintenanceStatus(ctx context.Context) MaintenanceStatus {
+	cacheCtx, cancel := qp.cacheContext(ctx)
+	defer cancel()
+
+	data, err := qp.cache.Get(cacheCtx, maintenanceKey).Bytes()
+	if err == nil {
+		var status MaintenanceStatus
+		if err = json.Unmarshal(data, &status); err == nil {
+			return status
+		}
+	}
+	if err != redis.Nil {
+		qp.logger.Warn(ctx, "Failed to read maintenance mode, using the startup mode", map[string]interface{}{
+			"error": cacheError(cacheCtx, err).Error(),
+		})
+	}
+	return qp.startupMaintenanceStatus()
+}
+
+// startupMaintenanceStatus returns the maintenance mode set with
+// SetMaintenanceMode
+func (qp *QueryProcessor) startupMaintenanceStatus() MaintenanceStatus {
+	qp.maintenance.mu.RLock()
+	defer qp.maintenance.mu.RUnlock()
+
+	if !qp.maintenance.enabled {
+		return MaintenanceStatus{}
+	}
+	since := qp.maintenance.since
+	return MaintenanceStatus{Enabled: true, Message: qp.maintenance.message, Since: &since}
+}
+
+// storeMaintenanceMode toggles maintenance mode for every instance sharing
+// the cache
+func (qp *QueryProcessor) storeMaintenanceMode(ctx context.Context, enabled bool, message string) (MaintenanceStatus, error) {
+	status := MaintenanceStatus{}
+	if enabled {
+		since := time.Now()
+		if current := qp.MaintenanceStatus(ctx); current.Enabled && current.Since != nil {
+			since = *current.Since
+		}
+		status = MaintenanceStatus{Enabled: true, Message: maintenanceMessage(message), Since: &since}
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		return MaintenanceStatus{}, err
+	}
+
+	cacheCtx, cancel := qp.cacheContext(ctx)
+	defer cancel()
+	if err := cacheError(cacheCtx, qp.cache.Set(cacheCtx, maintenanceKey, data, 0).Err()); err != nil {
+		return MaintenanceStatus{}, err
+	}
+	return status, nil
+}
+
+// maintenanceGate rejects requests with the maintenance error while
+// maintenance mode is enabled
+func (qp *QueryProcessor) maintenanceGate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := qp.MaintenanceStatus(c.Request.Context())
+		if !status.Enabled {
+			c.Next()
+			return
+		}
+		err := errors.NewMaintenanceError(status.Message)
+		c.AbortWithStatusJSON(getErrorStatusCode(err), formatErrorResponse(err))
+	}
+}
+
+// handleGetMaintenance returns the maintenance mode (admin only)
+func (qp *QueryProcessor) handleGetMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, qp.MaintenanceStatus(c.Request.Context()))
+}
+
+// handleSetMaintenance enables or disables maintenance mode (admin only). The
+// change is stored in the cache, so it applies to every instance and outlasts
+// restarts.
+func (qp *QueryProcessor) handleSetMaintenance(c *gin.Context) {
+	var req MaintenanceRequest
+	if err := qp.bindJSON(c, &req); err != nil {
+		enhancedErr := errors.NewRequestBodyError(err)
+		c.JSON(http.StatusBadRequest, formatErrorResponse(enhancedErr))
+		return
+	}
+	if req.Enabled == nil {
+		enhancedErr := errors.NewInvalidInputError("enabled", "is required")
+		c.JSON(http.StatusBadRequest, formatErrorResponse(enhancedErr))
+		return
+	}
+
+	status, err := qp.storeMaintenanceMode(c.Request.Context(), *req.Enabled, req.Message)
+	if err != nil {
+		enhancedErr := errors.Wrap(err, errors.ErrCodeCacheWrite, "Failed to store maintenance mode").
+			WithMetadata("retryable", true).
+			WithDependency(errors.DependencyCache)
+		c.JSON(getErrorStatusCode(enhancedErr), formatErrorResponse(enhancedErr))
+		return
+	}
+
+	qp.logger.Warn(c.Request.Context(), "Maintenance mode changed", map[string]interface{}{
+		"enabled": *req.Enabled,
+		"message": req.Message,
+	})
+
+	c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMaintenanceMode tests that query endpoints are rejected in maintenance mode while health endpoints stay up
+func TestMaintenanceMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_requests_total{service="checkout"}[5m]))`, Confidence: 0.9}}
+	mapper := &MockSemanticMapper{services: []semantic.Service{
+		{ID: "svc-1", Name: "checkout", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+	}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, mapper, cache)
+	router := qp.SetupRoutes(allowAllAuthorizer{})
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	query := `{"query": "show requests for service checkout"}`
+
+	w := serve(http.MethodPost, "/api/v1/admin/maintenance", `{"enabled": true, "message": "Investigating an incident, back soon"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"enabled":true`)
+
+	for _, path := range []string{"/api/v1/query", "/api/v1/query/batch", "/api/v1/alert"} {
+		w := serve(http.MethodPost, path, query)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, path)
+		assert.Contains(t, w.Body.String(), string(errors.ErrCodeMaintenance), path)
+		assert.Contains(t, w.Body.String(), "Investigating an incident, back soon", path)
+	}
+
+	for _, path := range []string{"/livez", "/readyz", "/health", "/api/v1/health", "/api/v1/admin/maintenance"} {
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet, path, "").Code, path)
+	}
+
+	w = serve(http.MethodPost, "/api/v1/admin/maintenance", `{"enabled": false}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/v1/query", query).Code)
+}
+
+// TestMaintenanceModeShared tests that a runtime toggle applies to every
+// instance sharing the cache and overrides the mode instances started with
+func TestMaintenanceModeShared(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	first := NewQueryProcessor(&MockLLMClient{}, &MockSemanticMapper{}, redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	second := NewQueryProcessor(&MockLLMClient{}, &MockSemanticMapper{}, redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	second.SetMaintenanceMode(true, "started in maintenance")
+	assert.Equal(t, "started in maintenance", second.MaintenanceStatus(ctx).Message)
+
+	enabled, err := first.storeMaintenanceMode(ctx, true, "database migration")
+	require.NoError(t, err)
+	status := second.MaintenanceStatus(ctx)
+	assert.True(t, status.Enabled)
+	assert.Equal(t, "database migration", status.Message)
+	require.NotNil(t, status.Since)
+	assert.True(t, enabled.Since.Equal(*status.Since))
+
+	// Changing the message keeps the time maintenance started
+	_, err = second.storeMaintenanceMode(ctx, true, "")
+	require.NoError(t, err)
+	status = first.MaintenanceStatus(ctx)
+	assert.Equal(t, defaultMaintenanceMessage, status.Message)
+	assert.True(t, enabled.Since.Equal(*status.Since))
+
+	_, err = first.storeMaintenanceMode(ctx, false, "")
+	require.NoError(t, err)
+	assert.False(t, second.MaintenanceStatus(ctx).Enabled, "the runtime toggle overrides the startup mode")
+
+	// Without the cache, instances fall back to their startup mode
+	server.Close()
+	assert.True(t, second.MaintenanceStatus(ctx).Enabled)
+	assert.False(t, first.MaintenanceStatus(ctx).Enabled)
+}
+
+// TestMaintenanceModeRequest tests validation of maintenance toggles
+func TestMaintenanceModeRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	qp := NewQueryProcessor(&MockLLMClient{}, &MockSemanticMapper{}, redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}))
+	router := qp.SetupRoutes(allowAllAuthorizer{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/maintenance", bytes.NewBufferString(`{"message": "down"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.False(t, qp.MaintenanceStatus(context.Background()).Enabled)
+
+	qp.SetMaintenanceMode(true, "")
+	status := qp.MaintenanceStatus(context.Background())
+	assert.True(t, status.Enabled)
+	assert.Equal(t, defaultMaintenanceMessage, status.Message)
+	assert.NotNil(t, status.Since)
+}
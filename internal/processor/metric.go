@@ -0,0 +1,171 @@
+package processor
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/metrics"
+	"github.com/seanankenbruck/observability-ai/internal/mimir"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+)
+
+// MetadataFetcher retrieves live metric metadata from the metrics backend
+type MetadataFetcher interface {
+	GetMetricMetadata(ctx context.Context, metricName string) (*mimir.MetricMetadata, error)
+}
+
+// Sources of the metadata in a MetricDetail
+const (
+	metadataSourceCatalog = "catalog"
+	metadataSourceMimir   = "mimir"
+)
+
+// MetricService identifies a service that exposes a metric
+type MetricService struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// MetricDetail combines a metric's metadata with the services that expose it
+type MetricDetail struct {
+	Name           string            `json:"name"`
+	DisplayName    string            `json:"display_name"` // Name without a configured display prefix
+	Type           string            `json:"type"`
+	Help           string            `json:"help"`
+	Unit           string            `json:"unit"`
+	Labels         map[string]string `json:"labels"`
+	Services       []MetricService   `json:"services"`
+	MetadataSource string            `json:"metadata_source"` // "catalog" or "mimir"
+}
+
+// SetMetadataFetcher enables fetching live metadata for metrics whose type is
+// not recorded in the catalog
+func (qp *QueryProcessor) SetMetadataFetcher(fetcher MetadataFetcher) {
+	qp.metadataFetcher = fetcher
+}
+
+// SetMetricTypeOverrides sets the metric types consulted before the naming
+// conventions when the catalog is grouped by type for the prompt and when
+// direct queries pick a PromQL function
+func (qp *QueryProcessor) SetMetricTypeOverrides(overrides *metrics.TypeOverrides) {
+	qp.metricTypes = overrides
+}
+
+// storedMetricTypes returns the types recorded in the catalog for the metrics
+// of the services, keyed by metric name. Services whose metrics cannot be read
+// are left out, so their types are inferred from names.
+func (qp *QueryProcessor) storedMetricTypes(ctx context.Context, services []semantic.Service) map[string]string {
+	types := make(map[string]string)
+	for _, service := range services {
+		catalogMetrics, err := qp.semanticMapper.GetMetrics(ctx, service.ID)
+		if err != nil {
+			qp.logger.Warn(ctx, "Failed to get stored metric types for prompt", map[string]interface{}{
+				"service": service.Name,
+				"error":   err.Error(),
+			})
+			continue
+		}
+		for _, metric := range catalogMetrics {
+			if _, ok := types[metric.Name]; !ok && metric.Type != "" {
+				types[metric.Name] = metric.Type
+			}
+		}
+	}
+	return types
+}
+
+// inferCatalogType infers the type of a catalog metric from the type stored
+// for it. Overrides take precedence over the stored type, since discovery
+// stores the type naming conventions give without them.
+func inferCatalogType(name, storedType string, overrides *metrics.TypeOverrides) metrics.MetricType {
+	if metricType, ok := overrides.Lookup(name); ok {
+		return metricType
+	}
+	return overrides.InferType(name, &metrics.MetricMetadata{Type: storedType})
+}
+
+// GetMetricDetail returns the metadata of a discovered metric and the
+// services that expose it. Metadata recorded in the catalog is used when
+// available; otherwise it is fetched from Mimir.
+func (qp *QueryProcessor) GetMetricDetail(ctx context.Context, name string) (*MetricDetail, error) {
+	services, err := qp.semanticMapper.GetServices(ctx)
+	if err != nil {
+		return nil, errors.NewDatabaseQueryError(err, "fetching services for metric")
+	}
+
+	// Catalog metadata of every owning service comes from a single lookup; on
+	// failure the owning services are still reported without it
+	catalogMetrics := make(map[string][]semantic.Metric)
+	if metrics, err := qp.semanticMapper.GetMetricsByName(ctx, name); err == nil {
+		for _, metric := range metrics {
+			catalogMetrics[metric.ServiceID] = append(catalogMetrics[metric.ServiceID], metric)
+		}
+	}
+
+	detail := &MetricDetail{
+		Name:        name,
+		DisplayName: qp.metricDisplayName(name),
+		Labels:      make(map[string]string),
+		Services:    make([]MetricService, 0),
+	}
+	for _, service := range services {
+		if !containsString(service.MetricNames, name) {
+			continue
+		}
+		detail.Services = append(detail.Services, MetricService{
+			ID:        service.ID,
+			Name:      service.Name,
+			Namespace: service.Namespace,
+		})
+
+		for _, metric := range catalogMetrics[service.ID] {
+			if detail.Type == "" && metric.Type != "" {
+				detail.Type = metric.Type
+				detail.Help = metric.Description
+				detail.MetadataSource = metadataSourceCatalog
+			}
+			for key, value := range metric.Labels {
+				detail.Labels[key] = value
+			}
+		}
+	}
+
+	if len(detail.Services) == 0 {
+		return nil, errors.NewMetricNotFoundError(name)
+	}
+
+	if detail.Type == "" && qp.metadataFetcher != nil {
+		metadata, err := qp.metadataFetcher.GetMetricMetadata(ctx, name)
+		if err == nil && metadata != nil {
+			detail.Type = metadata.Type
+			detail.Help = metadata.Help
+			detail.Unit = metadata.Unit
+			detail.MetadataSource = metadataSourceMimir
+		}
+	}
+
+	return detail, nil
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// handleGetMetric handles GET /api/v1/metrics/:name
+func (qp *QueryProcessor) handleGetMetric(c *gin.Context) {
+	detail, err := qp.GetMetricDetail(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		c.JSON(getErrorStatusCode(err), formatErrorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, detail)
+}
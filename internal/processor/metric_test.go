@@ -0,0 +1,223 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/metrics"
+	"github.com/seanankenbruck/observability-ai/internal/mimir"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubMetadataFetcher returns fixed live metadata and counts lookups
+type stubMetadataFetcher struct {
+	metadata *mimir.MetricMetadata
+	calls    int
+}
+
+func (f *stubMetadataFetcher) GetMetricMetadata(ctx context.Context, metricName string) (*mimir.MetricMetadata, error) {
+	f.calls++
+	return f.metadata, nil
+}
+
+// TestGetMetricHandler tests that the metric endpoint combines metadata with the owning services
+func TestGetMetricHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mapper := &MockSemanticMapper{
+		services: []semantic.Service{
+			{ID: "svc-1", Name: "api", Namespace: "production", MetricNames: []string{"http_requests_total", "process_open_fds"}},
+			{ID: "svc-2", Name: "api", Namespace: "staging", MetricNames: []string{"http_requests_total"}},
+			{ID: "svc-3", Name: "worker", Namespace: "production", MetricNames: []string{"jobs_processed_total"}},
+		},
+		metrics: map[string][]semantic.Metric{
+			"svc-1": {{
+				Name:        "http_requests_total",
+				Type:        "counter",
+				Description: "Total HTTP requests",
+				Labels:      map[string]string{"method": "GET"},
+				ServiceID:   "svc-1",
+			}},
+		},
+	}
+	fetcher := &stubMetadataFetcher{metadata: &mimir.MetricMetadata{Type: "gauge", Help: "Open file descriptors", Unit: "files"}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(&MockLLMClient{}, mapper, cache)
+	qp.SetMetadataFetcher(fetcher)
+	router := qp.SetupRoutes(nil)
+
+	get := func(name string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/metrics/"+name, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("catalog metadata and owning services", func(t *testing.T) {
+		w := get("http_requests_total")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var detail MetricDetail
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+		assert.Equal(t, "http_requests_total", detail.Name)
+		assert.Equal(t, "counter", detail.Type)
+		assert.Equal(t, "Total HTTP requests", detail.Help)
+		assert.Equal(t, map[string]string{"method": "GET"}, detail.Labels)
+		assert.Equal(t, metadataSourceCatalog, detail.MetadataSource)
+		assert.Equal(t, []MetricService{
+			{ID: "svc-1", Name: "api", Namespace: "production"},
+			{ID: "svc-2", Name: "api", Namespace: "staging"},
+		}, detail.Services)
+		assert.Equal(t, 0, fetcher.calls, "catalog metadata should not be fetched from Mimir")
+	})
+
+	t.Run("live metadata when not in the catalog", func(t *testing.T) {
+		w := get("process_open_fds")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var detail MetricDetail
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+		assert.Equal(t, "gauge", detail.Type)
+		assert.Equal(t, "Open file descriptors", detail.Help)
+		assert.Equal(t, "files", detail.Unit)
+		assert.Equal(t, metadataSourceMimir, detail.MetadataSource)
+		assert.Equal(t, []MetricService{{ID: "svc-1", Name: "api", Namespace: "production"}}, detail.Services)
+		assert.Equal(t, 1, fetcher.calls)
+	})
+
+	t.Run("unknown metric is not found", func(t *testing.T) {
+		w := get("does_not_exist")
+		require.Equal(t, http.StatusNotFound, w.Code)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, string(errors.ErrCodeMetricNotFound), response["error"].(map[string]interface{})["code"])
+	})
+}
+
+// metricLookupMapper counts the catalog metric lookups
+type metricLookupMapper struct {
+	MockSemanticMapper
+	perService int
+	byName     int
+}
+
+func (m *metricLookupMapper) GetMetrics(ctx context.Context, serviceID string) ([]semantic.Metric, error) {
+	m.perService++
+	return m.MockSemanticMapper.GetMetrics(ctx, serviceID)
+}
+
+func (m *metricLookupMapper) GetMetricsByName(ctx context.Context, name string) ([]semantic.Metric, error) {
+	m.byName++
+	return m.MockSemanticMapper.GetMetricsByName(ctx, name)
+}
+
+// TestGetMetricDetailSingleLookup tests that the catalog metadata of every
+// owning service is read with one lookup rather than one per service
+func TestGetMetricDetailSingleLookup(t *testing.T) {
+	mapper := &metricLookupMapper{MockSemanticMapper: MockSemanticMapper{
+		services: []semantic.Service{
+			{ID: "svc-1", Name: "api", Namespace: "production", MetricNames: []string{"http_requests_total"}},
+			{ID: "svc-2", Name: "api", Namespace: "staging", MetricNames: []string{"http_requests_total"}},
+			{ID: "svc-3", Name: "web", Namespace: "production", MetricNames: []string{"http_requests_total"}},
+		},
+		metrics: map[string][]semantic.Metric{
+			"svc-2": {{Name: "http_requests_total", Labels: map[string]string{"method": "GET"}, ServiceID: "svc-2"}},
+			"svc-3": {{Name: "http_requests_total", Type: "counter", Labels: map[string]string{"code": "200"}, ServiceID: "svc-3"}},
+		},
+	}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(&MockLLMClient{}, mapper, cache)
+
+	detail, err := qp.GetMetricDetail(context.Background(), "http_requests_total")
+	require.NoError(t, err)
+	assert.Len(t, detail.Services, 3)
+	assert.Equal(t, "counter", detail.Type)
+	assert.Equal(t, map[string]string{"method": "GET", "code": "200"}, detail.Labels)
+	assert.Equal(t, 1, mapper.byName)
+	assert.Equal(t, 0, mapper.perService)
+}
+
+// TestMetricTypeOverrides tests that overrides change how the prompt groups a
+// metric and which function direct queries apply
+func TestMetricTypeOverrides(t *testing.T) {
+	mapper := &MockSemanticMapper{services: []semantic.Service{
+		{ID: "svc-1", Name: "worker", Namespace: "default", MetricNames: []string{"queue_depth_total", "jobs_processed_total"}},
+	}}
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: "sum(up)", Confidence: 0.8}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, mapper, cache)
+
+	categories := func() string {
+		prompt, err := qp.buildPrompt(context.Background(), &QueryRequest{Query: "queue depth"}, &QueryIntent{}, nil)
+		require.NoError(t, err)
+		start := strings.Index(prompt, "Service: worker")
+		end := strings.Index(prompt, "=== END CATALOG ===")
+		return prompt[start:end]
+	}
+
+	assert.Regexp(t, `Counters \(use rate/increase\):\n    - queue_depth_total\n`, categories())
+	response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show queue_depth_total"})
+	require.NoError(t, err)
+	assert.Equal(t, "rate(queue_depth_total[5m])", response.PromQL)
+
+	overrides, err := metrics.NewTypeOverrides(map[string][]string{"gauge": {"queue_depth_total"}})
+	require.NoError(t, err)
+	qp.SetMetricTypeOverrides(overrides)
+
+	catalog := categories()
+	assert.Regexp(t, `Counters \(use rate/increase\):\n    - jobs_processed_total\n`, catalog)
+	assert.Regexp(t, `Gauges \(use directly or aggregate\):\n    - queue_depth_total\n`, catalog)
+
+	response, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "current queue_depth_total"})
+	require.NoError(t, err)
+	assert.Equal(t, "queue_depth_total", response.PromQL)
+}
+
+// TestStoredMetricTypes tests that the types stored in the catalog group the
+// prompt and pick the function of direct queries, with overrides taking
+// precedence over them
+func TestStoredMetricTypes(t *testing.T) {
+	mapper := &MockSemanticMapper{
+		services: []semantic.Service{
+			{ID: "svc-1", Name: "worker", Namespace: "default", MetricNames: []string{"worker_queue_depth", "worker_jobs_done"}},
+		},
+		metrics: map[string][]semantic.Metric{
+			"svc-1": {
+				{Name: "worker_queue_depth", Type: "gauge", ServiceID: "svc-1"},
+				{Name: "worker_jobs_done", Type: "counter", ServiceID: "svc-1"},
+			},
+		},
+	}
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: "sum(up)", Confidence: 0.8}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, mapper, cache)
+
+	prompt, err := qp.buildPrompt(context.Background(), &QueryRequest{Query: "queue depth"}, &QueryIntent{}, nil)
+	require.NoError(t, err)
+	assert.Regexp(t, `Counters \(use rate/increase\):\n    - worker_jobs_done\n`, prompt)
+	assert.Regexp(t, `Gauges \(use directly or aggregate\):\n    - worker_queue_depth\n`, prompt)
+
+	response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "rate of worker_jobs_done"})
+	require.NoError(t, err)
+	assert.Equal(t, "rate(worker_jobs_done[5m])", response.PromQL)
+
+	overrides, err := metrics.NewTypeOverrides(map[string][]string{"gauge": {"worker_jobs_done"}})
+	require.NoError(t, err)
+	qp.SetMetricTypeOverrides(overrides)
+
+	response, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "current worker_jobs_done"})
+	require.NoError(t, err)
+	assert.Equal(t, "worker_jobs_done", response.PromQL)
+}
@@ -0,0 +1,59 @@
+package processor
+
+import (
+	"context"
+	"strings"
+
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+)
+
+// maxPromptHelpLength bounds the help text shown for a metric in the prompt
+// catalog, in characters
+const maxPromptHelpLength = 100
+
+// SetPromptMetricHelp includes the help text discovered for metrics in the
+// prompt catalog, which helps the LLM pick metrics at the cost of a larger
+// prompt
+func (qp *QueryProcessor) SetPromptMetricHelp(enabled bool) {
+	qp.promptMetricHelp = enabled
+}
+
+// metricHelp returns the help text of the metrics of the prompt's services,
+// or nil when it is disabled or cannot be read
+func (qp *QueryProcessor) metricHelp(ctx context.Context, services []semantic.Service) map[string]string {
+	if !qp.promptMetricHelp {
+		return nil
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, service := range services {
+		for _, name := range service.MetricNames {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	help, err := qp.semanticMapper.GetMetricDescriptions(ctx, names)
+	if err != nil {
+		// The prompt is still usable without help text
+		qp.logger.Warn(ctx, "Failed to get metric help text for prompt", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil
+	}
+	return help
+}
+
+// promptMetricLine renders a metric of the prompt catalog, followed by its
+// help text if it has any
+func promptMetricLine(metric string, help map[string]string) string {
+	text := strings.Join(strings.Fields(help[metric]), " ")
+	if text == "" {
+		return "    - " + metric + "\n"
+	}
+	if runes := []rune(text); len(runes) > maxPromptHelpLength {
+		text = strings.TrimSpace(string(runes[:maxPromptHelpLength])) + "…"
+	}
+	return "    - " + metric + ": " + text + "\n"
+}
@@ -0,0 +1,81 @@
+package processor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// descriptionLookupMapper records the metric names of description lookups
+type descriptionLookupMapper struct {
+	MockSemanticMapper
+	lookups [][]string
+}
+
+func (m *descriptionLookupMapper) GetMetricDescriptions(ctx context.Context, names []string) (map[string]string, error) {
+	m.lookups = append(m.lookups, names)
+	return m.MockSemanticMapper.GetMetricDescriptions(ctx, names)
+}
+
+// TestPromptMetricHelp tests that the help text of catalog metrics is
+// included, truncated, only when enabled, and only for metrics that have it
+func TestPromptMetricHelp(t *testing.T) {
+	longHelp := "Duration of HTTP requests handled by the service, " + strings.Repeat("partitioned by route and status code ", 5)
+	mapper := &MockSemanticMapper{
+		services: []semantic.Service{
+			{ID: "svc-1", Name: "checkout", Namespace: "default", MetricNames: []string{
+				"http_requests_total", "http_request_duration_seconds_bucket", "process_open_fds",
+			}},
+		},
+		descriptions: map[string]string{
+			"http_requests_total":                  "Total number of HTTP requests\nhandled.",
+			"http_request_duration_seconds_bucket": longHelp,
+		},
+	}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(&MockLLMClient{}, mapper, cache)
+	intent := &QueryIntent{Type: "latency", Service: "checkout"}
+	req := &QueryRequest{Query: "checkout latency"}
+
+	// Disabled by default
+	prompt, err := qp.buildPrompt(context.Background(), req, intent, nil)
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "    - http_requests_total\n")
+	assert.NotContains(t, prompt, "Total number of HTTP requests")
+
+	qp.SetPromptMetricHelp(true)
+	prompt, err = qp.buildPrompt(context.Background(), req, intent, nil)
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "    - http_requests_total: Total number of HTTP requests handled.\n")
+	assert.Contains(t, prompt, "    - process_open_fds\n", "metrics without help text are listed by name")
+
+	truncated := strings.TrimSpace(longHelp[:maxPromptHelpLength]) + "…"
+	assert.Contains(t, prompt, "    - http_request_duration_seconds_bucket: "+truncated+"\n")
+	assert.NotContains(t, prompt, longHelp)
+}
+
+// TestPromptMetricHelpLookup tests that help text is only looked up for the
+// metrics of the services selected for the prompt
+func TestPromptMetricHelpLookup(t *testing.T) {
+	mapper := &descriptionLookupMapper{MockSemanticMapper: MockSemanticMapper{
+		services: []semantic.Service{
+			{ID: "svc-1", Name: "checkout", Namespace: "default", MetricNames: []string{"http_requests_total", "up"}},
+			{ID: "svc-2", Name: "payments", Namespace: "default", MetricNames: []string{"payments_total", "up"}},
+		},
+		descriptions: map[string]string{"payments_total": "Payments processed"},
+	}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(&MockLLMClient{}, mapper, cache)
+	qp.SetPromptMetricHelp(true)
+	qp.SetMaxPromptServices(1)
+
+	_, err := qp.buildPrompt(context.Background(), &QueryRequest{Query: "checkout request rate"}, &QueryIntent{Service: "checkout"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"http_requests_total", "up"}}, mapper.lookups)
+}
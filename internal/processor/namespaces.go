@@ -0,0 +1,150 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+)
+
+// Duplicate service modes decide how a targeted service whose name exists in
+// several namespaces is queried when the request does not name a namespace
+const (
+	// duplicateServiceClarify has the LLM ask which namespace is meant
+	duplicateServiceClarify = "clarify"
+	// duplicateServiceAll queries every namespace, grouped by namespace
+	duplicateServiceAll = "all"
+)
+
+// SetDuplicateServiceMode sets how a targeted service name found in several
+// namespaces is handled when the request names none of them: "clarify"
+// (the default) answers with an ERROR asking for the namespace, "all"
+// queries every namespace. Unknown modes keep the default.
+func (qp *QueryProcessor) SetDuplicateServiceMode(mode string) {
+	switch mode {
+	case duplicateServiceAll:
+		qp.duplicateServices = duplicateServiceAll
+	default:
+		qp.duplicateServices = duplicateServiceClarify
+	}
+}
+
+// duplicateServiceNames returns the service names listed in more than one
+// namespace, sorted
+func duplicateServiceNames(services []semantic.Service) []string {
+	namespaces := make(map[string]map[string]bool)
+	for _, service := range services {
+		if namespaces[service.Name] == nil {
+			namespaces[service.Name] = make(map[string]bool)
+		}
+		namespaces[service.Name][service.Namespace] = true
+	}
+	var names []string
+	for name, seen := range namespaces {
+		if len(seen) > 1 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// targetNamespaces returns the sorted namespaces of the services named by the
+// intent's target service
+func targetNamespaces(services []semantic.Service, intent *QueryIntent) []string {
+	if intent.Service == "" {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var namespaces []string
+	for _, service := range services {
+		if strings.EqualFold(service.Name, intent.Service) && !seen[service.Namespace] {
+			seen[service.Namespace] = true
+			namespaces = append(namespaces, service.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// requestedNamespace returns the namespace the request names among the
+// candidates: the "namespace" context entry, "service@namespace" in the
+// query, or a candidate namespace given as a word of the query. A context
+// entry that is not a candidate is ignored, so only catalog namespaces reach
+// the prompt. It returns "" when the request names none or several.
+func requestedNamespace(req *QueryRequest, service string, candidates []string) string {
+	if requested := req.Context["namespace"]; requested != "" {
+		for _, namespace := range candidates {
+			if strings.EqualFold(namespace, requested) {
+				return namespace
+			}
+		}
+	}
+	query := strings.ToLower(req.Query)
+	var named []string
+	for _, namespace := range candidates {
+		lower := strings.ToLower(namespace)
+		if strings.Contains(query, strings.ToLower(service)+"@"+lower) {
+			return namespace
+		}
+		if containsWord(query, lower) {
+			named = append(named, namespace)
+		}
+	}
+	if len(named) == 1 {
+		return named[0]
+	}
+	return ""
+}
+
+// containsWord reports whether word occurs in text delimited by characters
+// that cannot be part of a namespace name
+func containsWord(text, word string) bool {
+	if word == "" {
+		return false
+	}
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], word)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(word)
+		if (start == 0 || !isNamespaceChar(text[start-1])) && (end == len(text) || !isNamespaceChar(text[end])) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+// isNamespaceChar reports whether c can be part of a lowercase namespace name
+func isNamespaceChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// writeNamespacePrompt tells the LLM how to tell apart services sharing a
+// name across the namespaces of the catalog
+func (qp *QueryProcessor) writeNamespacePrompt(promptBuilder *strings.Builder, req *QueryRequest, intent *QueryIntent, services []semantic.Service) {
+	duplicates := duplicateServiceNames(services)
+	if len(duplicates) == 0 {
+		return
+	}
+	promptBuilder.WriteString(fmt.Sprintf("\nNamespace Guidance: %s exist in several namespaces. Services are listed as name@namespace; when selecting their metrics, always add a namespace matcher, e.g. namespace=\"<namespace>\".\n",
+		strings.Join(duplicates, ", ")))
+
+	namespaces := targetNamespaces(services, intent)
+	if len(namespaces) < 2 {
+		return
+	}
+	if namespace := requestedNamespace(req, intent.Service, namespaces); namespace != "" {
+		promptBuilder.WriteString(fmt.Sprintf("Target Namespace: %s (select %s@%s with namespace=\"%s\")\n", namespace, intent.Service, namespace, namespace))
+		return
+	}
+	if qp.duplicateServices == duplicateServiceAll {
+		promptBuilder.WriteString(fmt.Sprintf("The request does not name a namespace: cover %s in all of its namespaces (%s), keeping namespace in the grouping labels.\n",
+			intent.Service, strings.Join(namespaces, ", ")))
+		return
+	}
+	promptBuilder.WriteString(fmt.Sprintf("The request does not say which %s is meant. Do not guess: respond with ERROR: Service \"%s\" exists in several namespaces (%s); please specify the namespace.\n",
+		intent.Service, intent.Service, strings.Join(namespaces, ", ")))
+}
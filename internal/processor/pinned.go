@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"gopkg.in/yaml.v3"
+)
+
+// maxPromptExamples is the number of examples given in a prompt, pinned
+// examples first and similar past queries after them
+const maxPromptExamples = 3
+
+// PromptExample is a natural language query and the PromQL answering it
+type PromptExample struct {
+	Query  string `yaml:"query" json:"query"`
+	PromQL string `yaml:"promql" json:"promql"`
+}
+
+// LoadPinnedExamples reads pinned examples from a YAML file holding a list of
+// query and promql pairs
+func LoadPinnedExamples(path string) ([]PromptExample, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pinned examples: %w", err)
+	}
+	var examples []PromptExample
+	if err := yaml.Unmarshal(data, &examples); err != nil {
+		return nil, fmt.Errorf("failed to parse pinned examples: %w", err)
+	}
+	for i, example := range examples {
+		if strings.TrimSpace(example.Query) == "" || strings.TrimSpace(example.PromQL) == "" {
+			return nil, fmt.Errorf("pinned example %d must have a query and promql", i+1)
+		}
+	}
+	return examples, nil
+}
+
+// SetPinnedExamples sets examples included in every prompt ahead of similar
+// past queries, giving prompts a stable baseline of good examples. Only the
+// first examples within the example budget are used; examples without a
+// query or PromQL are ignored.
+func (qp *QueryProcessor) SetPinnedExamples(examples []PromptExample) {
+	qp.pinnedExamples = nil
+	for _, example := range examples {
+		example.Query = strings.TrimSpace(example.Query)
+		example.PromQL = strings.TrimSpace(example.PromQL)
+		if example.Query != "" && example.PromQL != "" {
+			qp.pinnedExamples = append(qp.pinnedExamples, example)
+		}
+	}
+}
+
+// promptExamples returns the pinned examples and the similar past queries to
+// include in a prompt. Past queries fill the budget left by the pinned
+// examples, skipping those repeating a pinned example.
+func (qp *QueryProcessor) promptExamples(similarQueries []semantic.SimilarQuery) ([]PromptExample, []semantic.SimilarQuery) {
+	pinned := qp.pinnedExamples[:min(maxPromptExamples, len(qp.pinnedExamples))]
+
+	var past []semantic.SimilarQuery
+	for _, sq := range similarQueries {
+		if len(pinned)+len(past) >= maxPromptExamples {
+			break
+		}
+		if !repeatsPinnedExample(sq, pinned) {
+			past = append(past, sq)
+		}
+	}
+	return pinned, past
+}
+
+// repeatsPinnedExample reports whether a past query asks or answers the same
+// as one of the pinned examples
+func repeatsPinnedExample(sq semantic.SimilarQuery, pinned []PromptExample) bool {
+	for _, example := range pinned {
+		if strings.EqualFold(strings.TrimSpace(sq.Query), example.Query) ||
+			canonicalizePromQL(sq.PromQL) == canonicalizePromQL(example.PromQL) {
+			return true
+		}
+	}
+	return false
+}
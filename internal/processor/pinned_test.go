@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPinnedExamplesInPrompt tests that pinned examples are included ahead of
+// similar past queries, even when the similarity search returns unrelated
+// queries, and that both stay within the example budget
+func TestPinnedExamplesInPrompt(t *testing.T) {
+	mapper := &MockSemanticMapper{services: []semantic.Service{
+		{ID: "svc-1", Name: "checkout", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+	}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(&MockLLMClient{}, mapper, cache)
+	qp.SetPinnedExamples([]PromptExample{
+		{Query: "error rate for checkout", PromQL: `sum(rate(http_requests_total{service="checkout",status=~"5.."}[5m]))`},
+		{Query: "  ", PromQL: "up"},
+		{Query: "request rate for checkout", PromQL: `sum(rate(http_requests_total{service="checkout"}[5m]))`},
+	})
+
+	unrelated := []semantic.SimilarQuery{
+		{Query: "disk usage of the database", PromQL: `node_filesystem_avail_bytes{job="db"}`},
+		// Repeats a pinned example
+		{Query: "Error rate for checkout", PromQL: `sum(rate(http_requests_total{status=~"5.."}[5m]))`},
+		{Query: "memory of the cache", PromQL: `process_resident_memory_bytes{job="cache"}`},
+	}
+	intent := &QueryIntent{Type: "errors", Service: "checkout"}
+	prompt, err := qp.buildPrompt(context.Background(), &QueryRequest{Query: "checkout errors"}, intent, unrelated)
+	require.NoError(t, err)
+
+	pinnedAt := strings.Index(prompt, "=== REFERENCE EXAMPLES ===")
+	pastAt := strings.Index(prompt, "=== EXAMPLES FROM PAST QUERIES ===")
+	require.GreaterOrEqual(t, pinnedAt, 0)
+	require.Greater(t, pastAt, pinnedAt)
+	assert.Contains(t, prompt, "Q: error rate for checkout\n")
+	assert.Contains(t, prompt, "Q: request rate for checkout\n")
+	assert.NotContains(t, prompt, "Q: Error rate for checkout\n")
+	// Two pinned examples leave room for one past query
+	assert.Contains(t, prompt, "disk usage of the database")
+	assert.NotContains(t, prompt, "memory of the cache")
+	assert.Equal(t, maxPromptExamples, strings.Count(prompt, "\nQ: "))
+
+	// Pinned examples are kept when past queries are not used as examples
+	useExamples := false
+	prompt, err = qp.buildPrompt(context.Background(), &QueryRequest{Query: "checkout errors", UseExamples: &useExamples}, intent, unrelated)
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "Q: error rate for checkout\n")
+	assert.NotContains(t, prompt, "EXAMPLES FROM PAST QUERIES")
+}
+
+// TestLoadPinnedExamples tests reading pinned examples from a YAML file
+func TestLoadPinnedExamples(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	examples, err := LoadPinnedExamples(write("valid.yaml", `
+- query: p99 latency of the api
+  promql: histogram_quantile(0.99, sum by (le) (rate(http_request_duration_seconds_bucket{service="api"}[5m])))
+`))
+	require.NoError(t, err)
+	require.Len(t, examples, 1)
+	assert.Equal(t, "p99 latency of the api", examples[0].Query)
+
+	_, err = LoadPinnedExamples(write("missing.yaml", "- query: no promql\n"))
+	assert.Error(t, err)
+	_, err = LoadPinnedExamples(filepath.Join(dir, "absent.yaml"))
+	assert.Error(t, err)
+}
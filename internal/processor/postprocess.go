@@ -0,0 +1,168 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/observability"
+)
+
+// Names of the built-in post-processors, run in this order by default
+const (
+	PostProcessorAliases    = "aliases"    // Rewrites metric aliases to canonical names
+	PostProcessorSafety     = "safety"     // Rejects unsafe or expensive queries
+	PostProcessorDeprecated = "deprecated" // Warns about or rejects deprecated metrics
+)
+
+// GeneratedQuery is a generated query passed through the post-processors
+type GeneratedQuery struct {
+	PromQL   string
+	Intent   *QueryIntent
+	Warnings []string // Returned with the query in Suggestions
+}
+
+// Metrics returns the distinct metric names the query selects, in order of
+// first use, or nil when it cannot be tokenized
+func (q *GeneratedQuery) Metrics() []string {
+	return selectorMetrics(q.PromQL)
+}
+
+// PromQLPostProcessor rewrites or rejects generated PromQL before it is
+// returned. Process may change the query in place; an error aborts the
+// pipeline and fails the request. Errors that are not an
+// *errors.EnhancedError are reported as POST_PROCESSING_FAILED. The query is
+// passed as text, so processors tokenize it themselves as needed.
+type PromQLPostProcessor interface {
+	Name() string
+	Process(ctx context.Context, query *GeneratedQuery) error
+}
+
+// postProcessorFunc adapts a function to a PromQLPostProcessor
+type postProcessorFunc struct {
+	name    string
+	process func(ctx context.Context, query *GeneratedQuery) error
+}
+
+func (p postProcessorFunc) Name() string { return p.name }
+
+func (p postProcessorFunc) Process(ctx context.Context, query *GeneratedQuery) error {
+	return p.process(ctx, query)
+}
+
+// builtinPostProcessors returns the built-in post-processors in their
+// default order. They read the processor's settings when run, so later
+// setters still apply.
+func (qp *QueryProcessor) builtinPostProcessors() []PromQLPostProcessor {
+	return []PromQLPostProcessor{
+		postProcessorFunc{PostProcessorAliases, func(_ context.Context, query *GeneratedQuery) error {
+			// Generated queries must use canonical metric names, not their aliases
+			query.PromQL = qp.intentClassifier.canonicalizeAliases(query.PromQL)
+			return nil
+		}},
+		postProcessorFunc{PostProcessorSafety, func(_ context.Context, query *GeneratedQuery) error {
+			return qp.validateGenerated(query.PromQL)
+		}},
+		postProcessorFunc{PostProcessorDeprecated, func(_ context.Context, query *GeneratedQuery) error {
+			// Queries selecting deprecated metrics would likely return nothing
+			warnings, err := qp.deprecated.check(query.PromQL)
+			query.Warnings = append(query.Warnings, warnings...)
+			return err
+		}},
+	}
+}
+
+// postProcessors returns the pipeline, defaulting to the built-in processors
+func (qp *QueryProcessor) postProcessors() []PromQLPostProcessor {
+	if qp.pipeline == nil {
+		return qp.builtinPostProcessors()
+	}
+	return qp.pipeline
+}
+
+// AddPostProcessor appends a post-processor to the pipeline, after the
+// built-in ones unless SetPostProcessorOrder moves it
+func (qp *QueryProcessor) AddPostProcessor(processor PromQLPostProcessor) {
+	qp.pipeline = append(qp.postProcessors(), processor)
+}
+
+// SetPostProcessorOrder moves the named post-processors to the front of the
+// pipeline in the given order; the others keep their relative order after
+// them, so no processor is dropped. Unknown or repeated names are an error.
+func (qp *QueryProcessor) SetPostProcessorOrder(names []string) error {
+	byName := make(map[string]PromQLPostProcessor)
+	for _, processor := range qp.postProcessors() {
+		byName[processor.Name()] = processor
+	}
+
+	ordered := make([]PromQLPostProcessor, 0, len(byName))
+	listed := make(map[string]bool)
+	for _, name := range names {
+		processor, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("unknown post-processor %q", name)
+		}
+		if listed[name] {
+			return fmt.Errorf("post-processor %q listed twice", name)
+		}
+		listed[name] = true
+		ordered = append(ordered, processor)
+	}
+	for _, processor := range qp.postProcessors() {
+		if !listed[processor.Name()] {
+			ordered = append(ordered, processor)
+		}
+	}
+	qp.pipeline = ordered
+	return nil
+}
+
+// postProcess runs a generated query through the pipeline, returning the
+// name of the processor that rejected it along with its error. Processors
+// ordered after safety may rewrite the query, so a query changed since the
+// safety processor passed it is validated again before it is returned.
+func (qp *QueryProcessor) postProcess(ctx context.Context, query *GeneratedQuery) (string, error) {
+	validated, checked := "", false
+	for _, processor := range qp.postProcessors() {
+		if err := processor.Process(ctx, query); err != nil {
+			if _, ok := err.(*errors.EnhancedError); !ok {
+				err = errors.NewPostProcessingError(processor.Name(), err)
+			}
+			return processor.Name(), err
+		}
+		if processor.Name() == PostProcessorSafety {
+			validated, checked = query.PromQL, true
+		}
+	}
+	if !checked || query.PromQL != validated {
+		if err := qp.validateGenerated(query.PromQL); err != nil {
+			return PostProcessorSafety, err
+		}
+	}
+	return "", nil
+}
+
+// validateGenerated runs the safety checks on a generated query, counting
+// violations
+func (qp *QueryProcessor) validateGenerated(promql string) error {
+	if err := qp.safetyChecker.ValidateQuery(promql); err != nil {
+		observability.GetGlobalMetrics().Inc(observability.MetricQuerySafetyViolation, map[string]string{
+			"error_type": "safety_validation",
+		})
+		return err
+	}
+	return nil
+}
+
+// postProcessErrorType returns the error type recorded in metrics for a
+// query rejected by the named post-processor
+func postProcessErrorType(name string) string {
+	switch name {
+	case PostProcessorSafety:
+		return "safety_validation"
+	case PostProcessorDeprecated:
+		return "deprecated_metric"
+	default:
+		return "post_processing"
+	}
+}
@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPostProcessorPipeline tests a pipeline where one post-processor
+// rewrites the query and a later one rejects it, depending on the rewrite
+func TestPostProcessorPipeline(t *testing.T) {
+	var seen []string
+	rewrite := postProcessorFunc{"scope", func(_ context.Context, query *GeneratedQuery) error {
+		seen = append(seen, "scope")
+		query.PromQL = strings.ReplaceAll(query.PromQL, "http_requests_total", `http_requests_total{env="prod"}`)
+		return nil
+	}}
+	reject := postProcessorFunc{"no-prod", func(_ context.Context, query *GeneratedQuery) error {
+		seen = append(seen, "no-prod")
+		if strings.Contains(query.PromQL, `env="prod"`) {
+			return fmt.Errorf("production metrics are not allowed")
+		}
+		return nil
+	}}
+
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_requests_total[5m]))`, Confidence: 0.9}}
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+	qp.AddPostProcessor(reject)
+	qp.AddPostProcessor(rewrite)
+
+	// Appended in this order, the check runs before the rewrite and passes
+	response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate"})
+	require.NoError(t, err)
+	assert.Equal(t, `sum(rate(http_requests_total{env="prod"}[5m]))`, response.PromQL)
+	assert.Equal(t, []string{"no-prod", "scope"}, seen)
+
+	// Reordered, the check sees the rewritten query and aborts with a typed error
+	require.NoError(t, qp.SetPostProcessorOrder([]string{"scope", "no-prod"}))
+	seen = nil
+	_, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request throughput"})
+	require.Error(t, err)
+	assert.Equal(t, []string{"scope", "no-prod"}, seen)
+	enhanced, ok := err.(*errors.EnhancedError)
+	require.True(t, ok)
+	assert.Equal(t, errors.ErrCodePostProcessing, enhanced.Code)
+	assert.Equal(t, "no-prod", enhanced.Metadata["post_processor"])
+
+	// The built-in processors still run after the reordered ones
+	names := make([]string, 0)
+	for _, processor := range qp.postProcessors() {
+		names = append(names, processor.Name())
+	}
+	assert.Equal(t, []string{"scope", "no-prod", PostProcessorAliases, PostProcessorSafety, PostProcessorDeprecated}, names)
+
+	assert.Error(t, qp.SetPostProcessorOrder([]string{"unknown"}))
+	assert.Error(t, qp.SetPostProcessorOrder([]string{"scope", "scope"}))
+}
+
+// TestPostProcessorAfterSafety tests that a query rewritten after the safety
+// post-processor ran, by reordering or by an appended processor, is
+// validated again, on both the query and the alert path
+func TestPostProcessorAfterSafety(t *testing.T) {
+	unsafe := postProcessorFunc{"unsafe", func(_ context.Context, query *GeneratedQuery) error {
+		query.PromQL = strings.ReplaceAll(query.PromQL, "http_requests_total", "app_secret_key")
+		return nil
+	}}
+
+	for name, setup := range map[string]func(qp *QueryProcessor){
+		"appended": func(qp *QueryProcessor) { qp.AddPostProcessor(unsafe) },
+		"reordered": func(qp *QueryProcessor) {
+			qp.AddPostProcessor(unsafe)
+			require.NoError(t, qp.SetPostProcessorOrder([]string{PostProcessorSafety, "unsafe"}))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+			llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_requests_total[5m]))`, Confidence: 0.9}}
+			qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+			setup(qp)
+
+			_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate"})
+			require.Error(t, err)
+			enhanced, ok := err.(*errors.EnhancedError)
+			require.True(t, ok)
+			assert.Equal(t, errors.ErrCodeForbiddenMetric, enhanced.Code)
+
+			_, err = qp.ProcessAlert(context.Background(), &AlertRequest{Query: "alert when requests exceed 10 per second"})
+			require.Error(t, err)
+			enhanced, ok = err.(*errors.EnhancedError)
+			require.True(t, ok)
+			assert.Equal(t, errors.ErrCodeForbiddenMetric, enhanced.Code)
+		})
+	}
+}
@@ -4,14 +4,24 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/config"
 	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/events"
 	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/metrics"
+	"github.com/seanankenbruck/observability-ai/internal/mimir"
 	"github.com/seanankenbruck/observability-ai/internal/observability"
 	"github.com/seanankenbruck/observability-ai/internal/semantic"
 )
@@ -22,6 +32,54 @@ type QueryRequest struct {
 	TimeRange string            `json:"time_range,omitempty"`
 	Context   map[string]string `json:"context,omitempty"`
 	UserID    string            `json:"user_id,omitempty"`
+	Model     string            `json:"model,omitempty"`  // Optional LLM model override, must be allowlisted
+	Tenant    string            `json:"tenant,omitempty"` // Optional Mimir tenant, must be configured
+
+	// Language of the explanation, e.g. "de" (default English); must be a
+	// supported language. The generated PromQL is never translated.
+	Language string `json:"language,omitempty"`
+
+	// ConfirmationToken confirms a query previously returned with RequiresConfirmation
+	ConfirmationToken string `json:"confirmation_token,omitempty"`
+
+	// Debug returns a breakdown of how the query was built in the response
+	// metadata under "telemetry"
+	Debug bool `json:"debug,omitempty"`
+
+	// UseExamples includes similar past queries as examples in the prompt
+	// (default true). Setting it to false skips the similarity search, e.g.
+	// to compare prompt strategies.
+	UseExamples *bool `json:"use_examples,omitempty"`
+
+	// PreviousQuery and PreviousPromQL describe a query this request refines,
+	// e.g. "now just the 5xx ones" after "show error rate for user-service"
+	PreviousQuery  string `json:"previous_query,omitempty"`
+	PreviousPromQL string `json:"previous_promql,omitempty"`
+
+	// SessionID remembers the last query of a conversation server-side, so
+	// follow-up requests refine it without carrying the previous query
+	SessionID string `json:"session_id,omitempty"`
+
+	// Format "grafana" adds a Grafana panel for the generated query to the
+	// response; it may also be given as ?format=grafana
+	Format string `json:"format,omitempty"`
+
+	// Threshold, if set, is compared against the latest value of the
+	// generated query's result using Comparison: ">" (default), ">=", "<"
+	// or "<="
+	Threshold  *float64 `json:"threshold,omitempty"`
+	Comparison string   `json:"comparison,omitempty"`
+
+	// Temperature, if set, is the LLM sampling temperature: 0 for
+	// reproducible queries, higher for more varied ones. It is clamped to
+	// the configured range.
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+// examplesEnabled reports whether similar past queries should be used as
+// prompt examples for the request
+func (r *QueryRequest) examplesEnabled() bool {
+	return r.UseExamples == nil || *r.UseExamples
 }
 
 // QueryResponse represents the processed query result
@@ -30,33 +88,153 @@ type QueryResponse struct {
 	Explanation    string                 `json:"explanation"`
 	Confidence     float64                `json:"confidence"`
 	Suggestions    []string               `json:"suggestions,omitempty"`
+	Warnings       []SafetyAdvisory       `json:"warnings,omitempty"` // Likely safety problems spotted in the question
 	EstimatedCost  int                    `json:"estimated_cost"`
 	CacheHit       bool                   `json:"cache_hit"`
 	ProcessingTime time.Duration          `json:"processing_time"`
 	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+
+	// Intent is the classified intent of the query. Metadata["intent"] holds
+	// the same value and is deprecated; it will be removed in a later release.
+	Intent *QueryIntent `json:"intent,omitempty"`
+
+	// RequiresConfirmation is set when the query exceeds the soft cost threshold;
+	// resubmit the query with ConfirmationToken to accept it
+	RequiresConfirmation bool   `json:"requires_confirmation,omitempty"`
+	ConfirmationToken    string `json:"confirmation_token,omitempty"`
+
+	// GrafanaPanel is set when the request asked for the grafana format
+	GrafanaPanel *GrafanaPanel `json:"grafana_panel,omitempty"`
+
+	// Threshold is set when the request gave a threshold and the query result
+	// could be compared against it
+	Threshold *ThresholdResult `json:"threshold,omitempty"`
 }
 
 // QueryProcessor is the main service struct
 type QueryProcessor struct {
-	llmClient        llm.Client
-	semanticMapper   semantic.Mapper
-	safetyChecker    *SafetyChecker
-	cache            *redis.Client
-	intentClassifier *IntentClassifier
-	logger           *observability.Logger
-	healthChecker    *observability.HealthChecker
+	llmClient            llm.Client
+	semanticMapper       semantic.Mapper
+	safetyChecker        *SafetyChecker
+	cache                *redis.Client
+	intentClassifier     *IntentClassifier
+	logger               *observability.Logger
+	healthChecker        *observability.HealthChecker
+	cacheTimeout         time.Duration
+	batchQueryTimeout    time.Duration
+	batchTimeout         time.Duration
+	discovery            DiscoveryPreviewer
+	requestDescriber     RequestDescriber
+	slowQueryThreshold   time.Duration
+	defaultModel         string
+	allowedModels        map[string]bool
+	temperature          float64 // LLM temperature of requests without one
+	minTemperature       float64
+	maxTemperature       float64
+	maxContextEntries    int
+	maxContextLength     int
+	contextLabelKeys     map[string]bool // Context keys accepted as labels; nil accepts any unreserved key
+	confirmCostThreshold int
+	defaultTenant        string
+	tenantDescribers     map[string]RequestDescriber
+	metadataFetcher      MetadataFetcher
+	queryExecutor        QueryExecutor
+	trustedProxies       []string
+	maxPromptServices    int
+	events               *events.Bus
+	defaultNamespace     string
+	inFlight             chan struct{}       // Query slots; nil when unbounded
+	fingerprints         *fingerprintLimiter // Per-query rate limit; nil when disabled
+	unitFormats          map[string]string   // Result value formats by unit; nil uses the defaults
+	pinnedExamples       []PromptExample     // Examples included in every prompt
+	duplicateServices    string              // Handling of a targeted name found in several namespaces; "" clarifies
+	promptMetricHelp     bool                // Include metric help text in the prompt catalog
+	evaluation           *evaluationSampler  // nil when sampling is disabled
+	maintenance          maintenanceMode
+	directCatalog        directCatalog
+	executions           chan semantic.QueryExecution
+	// Post-processors of generated queries; nil runs the built-in ones
+	pipeline             []PromQLPostProcessor
+	embeddingCache       bool
+	adminOnlyMetadata    map[string]bool // Metadata fields hidden from non-admins
+	metricTypes          *metrics.TypeOverrides
+	deprecated           deprecatedMetrics
+	queryFilter          queryFilter
+	displayPrefixes      []string             // Longest first
+	contextWindows       []modelContextWindow // Most specific first
+	defaultContextWindow int                  // Tokens for models without a configured window; zero uses the default
+	supportedLanguages   map[string]bool      // Explanation languages besides English
+	strictJSON           bool
+}
+
+// DiscoveryPreviewer runs service discovery without persisting the results
+type DiscoveryPreviewer interface {
+	Preview(ctx context.Context) (*mimir.DiscoveryPreview, error)
+}
+
+// RequestDescriber describes the backend request that would execute a PromQL
+// query, with credentials redacted
+type RequestDescriber interface {
+	DescribeQuery(query string, timestamp time.Time) *mimir.RequestInfo
+	DescribeQueryRange(query string, start, end time.Time, step time.Duration) *mimir.RequestInfo
 }
 
+// Page sizes of the query history
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+// defaultCacheTimeout bounds each cache operation so a slow Redis degrades to a
+// cache miss instead of consuming the query's time budget
+const defaultCacheTimeout = 200 * time.Millisecond
+
+// defaultSlowQueryThreshold is the processing time above which a query is
+// logged as slow
+const defaultSlowQueryThreshold = 5 * time.Second
+
+// defaultMaxTemperature is the highest LLM temperature requests may select
+const defaultMaxTemperature = 1.0
+
+// Default limits on QueryRequest.Context, which is caller-controlled and would
+// otherwise be unbounded
+const (
+	defaultMaxContextEntries = 20
+	defaultMaxContextLength  = 1024
+)
+
+// labelNamePattern matches valid Prometheus label names
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// statusClientClosedRequest is the nginx convention for a request the client
+// abandoned; the client never reads it, but logs and metrics do
+const statusClientClosedRequest = 499
+
+// errCacheTimeout is returned when a cache operation exceeds its timeout
+var errCacheTimeout = fmt.Errorf("cache operation timed out")
+
 // NewQueryProcessor creates a new query processor instance
 func NewQueryProcessor(llmClient llm.Client, semanticMapper semantic.Mapper, cache *redis.Client) *QueryProcessor {
-	return &QueryProcessor{
-		llmClient:        llmClient,
-		semanticMapper:   semanticMapper,
-		cache:            cache,
-		safetyChecker:    NewSafetyChecker(),
-		intentClassifier: NewIntentClassifier(),
-		logger:           observability.NewLogger("query-processor"),
+	qp := &QueryProcessor{
+		llmClient:          llmClient,
+		semanticMapper:     semanticMapper,
+		cache:              cache,
+		safetyChecker:      NewSafetyChecker(),
+		intentClassifier:   NewIntentClassifier(),
+		logger:             observability.NewLogger("query-processor"),
+		cacheTimeout:       defaultCacheTimeout,
+		slowQueryThreshold: defaultSlowQueryThreshold,
+		temperature:        llm.Temperature,
+		maxTemperature:     defaultMaxTemperature,
+		maxContextEntries:  defaultMaxContextEntries,
+		maxContextLength:   defaultMaxContextLength,
+		maxPromptServices:  defaultMaxPromptServices,
+		defaultNamespace:   config.DefaultNamespace,
+		adminOnlyMetadata:  fieldSet(defaultAdminOnlyMetadata),
+		executions:         make(chan semantic.QueryExecution, executionQueueSize),
 	}
+	go qp.recordExecutions()
+	return qp
 }
 
 // SetHealthChecker sets the health checker for the processor
@@ -64,8 +242,233 @@ func (qp *QueryProcessor) SetHealthChecker(healthChecker *observability.HealthCh
 	qp.healthChecker = healthChecker
 }
 
+// SetDiscoveryPreviewer enables the discovery preview admin endpoint
+func (qp *QueryProcessor) SetDiscoveryPreviewer(discovery DiscoveryPreviewer) {
+	qp.discovery = discovery
+}
+
+// SetTrustedProxies sets the CIDRs or IPs of reverse proxies allowed to report
+// the client IP in X-Forwarded-For. Requests from any other peer are
+// identified by their remote address, so rate limiting and request logs cannot
+// be spoofed with forwarding headers.
+func (qp *QueryProcessor) SetTrustedProxies(proxies []string) {
+	qp.trustedProxies = proxies
+}
+
+// SetDefaultNamespace sets the namespace used for service lookups that do not
+// name one; an empty namespace keeps the default
+func (qp *QueryProcessor) SetDefaultNamespace(namespace string) {
+	if namespace != "" {
+		qp.defaultNamespace = namespace
+	}
+}
+
+// SetRequestDescriber includes the resolved Mimir request in query response metadata
+func (qp *QueryProcessor) SetRequestDescriber(describer RequestDescriber) {
+	qp.requestDescriber = describer
+}
+
+// SetSlowQueryThreshold sets the processing time above which queries are logged
+// as slow; zero disables slow query logging
+func (qp *QueryProcessor) SetSlowQueryThreshold(threshold time.Duration) {
+	qp.slowQueryThreshold = threshold
+}
+
+// SetTimezone sets the timezone used to interpret absolute times in queries
+// that do not name one, such as "between 2pm and 4pm"
+func (qp *QueryProcessor) SetTimezone(location *time.Location) {
+	qp.intentClassifier.SetLocation(location)
+}
+
+// SetModels records the default LLM model and the models requests may select
+// instead. The default model is always allowed.
+func (qp *QueryProcessor) SetModels(defaultModel string, allowed []string) {
+	qp.defaultModel = defaultModel
+	qp.allowedModels = make(map[string]bool, len(allowed)+1)
+	for _, model := range allowed {
+		qp.allowedModels[model] = true
+	}
+	if defaultModel != "" {
+		qp.allowedModels[defaultModel] = true
+	}
+}
+
+// validateModel checks a requested model override against the allowlist
+func (qp *QueryProcessor) validateModel(model string) error {
+	if model == "" || qp.allowedModels[model] {
+		return nil
+	}
+
+	allowed := make([]string, 0, len(qp.allowedModels))
+	for name := range qp.allowedModels {
+		allowed = append(allowed, name)
+	}
+	sort.Strings(allowed)
+
+	reason := "model overrides are not enabled"
+	if len(allowed) > 0 {
+		reason = fmt.Sprintf("model %q is not allowed; allowed models: %s", model, strings.Join(allowed, ", "))
+	}
+	return errors.NewInvalidInputError("model", reason)
+}
+
+// SetTemperature sets the LLM temperature of requests that do not select one
+// and the range selected temperatures are clamped to
+func (qp *QueryProcessor) SetTemperature(temperature, min, max float64) {
+	qp.minTemperature = min
+	qp.maxTemperature = max
+	qp.temperature = qp.clampTemperature(temperature)
+}
+
+// requestTemperature returns the LLM temperature of a request
+func (qp *QueryProcessor) requestTemperature(req *QueryRequest) float64 {
+	if req.Temperature == nil {
+		return qp.temperature
+	}
+	return qp.clampTemperature(*req.Temperature)
+}
+
+// clampTemperature limits a temperature to the configured range
+func (qp *QueryProcessor) clampTemperature(temperature float64) float64 {
+	return math.Max(qp.minTemperature, math.Min(qp.maxTemperature, temperature))
+}
+
+// SetContextLimits bounds the number of request context entries and the length
+// of each key and value; non-positive values keep the defaults
+func (qp *QueryProcessor) SetContextLimits(maxEntries, maxLength int) {
+	if maxEntries > 0 {
+		qp.maxContextEntries = maxEntries
+	}
+	if maxLength > 0 {
+		qp.maxContextLength = maxLength
+	}
+}
+
+// SetContextLabelKeys restricts request context keys to the given label
+// names, so the context can be injected as label matchers without letting
+// callers subvert metric selection. An empty list lifts the restriction;
+// reserved keys are rejected either way.
+func (qp *QueryProcessor) SetContextLabelKeys(keys []string) {
+	qp.contextLabelKeys = nil
+	if len(keys) > 0 {
+		qp.contextLabelKeys = fieldSet(keys)
+	}
+}
+
+// validateContext enforces the request context limits and label keys
+func (qp *QueryProcessor) validateContext(requestContext map[string]string) error {
+	if len(requestContext) > qp.maxContextEntries {
+		return errors.NewInvalidInputError("context",
+			fmt.Sprintf("%d entries exceeds the maximum of %d", len(requestContext), qp.maxContextEntries))
+	}
+
+	for key, value := range requestContext {
+		if len(key) > qp.maxContextLength {
+			return errors.NewInvalidInputError("context",
+				fmt.Sprintf("key of length %d exceeds the maximum of %d", len(key), qp.maxContextLength))
+		}
+		if len(value) > qp.maxContextLength {
+			return errors.NewInvalidInputError("context",
+				fmt.Sprintf("value for %q of length %d exceeds the maximum of %d", key, len(value), qp.maxContextLength)).
+				WithMetadata("context_key", key)
+		}
+		if err := qp.validateContextKey(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateContextKey rejects context keys that are reserved label names, such
+// as __name__, and keys outside the configured label allowlist
+func (qp *QueryProcessor) validateContextKey(key string) error {
+	if strings.HasPrefix(key, "__") {
+		return errors.NewInvalidInputError("context",
+			fmt.Sprintf("key %q is reserved; label names starting with __ are internal to Prometheus", key)).
+			WithMetadata("context_key", key)
+	}
+	if qp.contextLabelKeys == nil {
+		return nil
+	}
+
+	if !labelNamePattern.MatchString(key) || !qp.contextLabelKeys[key] {
+		allowed := make([]string, 0, len(qp.contextLabelKeys))
+		for name := range qp.contextLabelKeys {
+			allowed = append(allowed, name)
+		}
+		sort.Strings(allowed)
+		return errors.NewInvalidInputError("context",
+			fmt.Sprintf("key %q is not an allowed label; allowed labels: %s", key, strings.Join(allowed, ", "))).
+			WithMetadata("context_key", key)
+	}
+	return nil
+}
+
+// cacheQuery returns the cache identity of a request. Every entry is scoped
+// to the request's Mimir tenant, so tenants never share results; within a
+// tenant, model overrides, explanation languages, requests without examples,
+// temperature overrides and refinements are cached separately from the
+// default results.
+func cacheQuery(req *QueryRequest, tenant string) string {
+	query := req.Query
+	if req.Model != "" {
+		query = req.Model + ":" + query
+	}
+	if language := explanationLanguage(req); language != defaultLanguage {
+		query = "lang=" + language + ":" + query
+	}
+	if !req.examplesEnabled() {
+		query = "no-examples:" + query
+	}
+	if req.Temperature != nil {
+		query = "temperature=" + strconv.FormatFloat(*req.Temperature, 'f', -1, 64) + ":" + query
+	}
+	// The requested namespace selects among services sharing a name
+	if namespace := req.Context["namespace"]; namespace != "" {
+		query = "namespace=" + url.PathEscape(namespace) + ":" + query
+	}
+	if req.refining() {
+		query = "refine:" + req.PreviousQuery + "\n" + req.PreviousPromQL + "\n" + query
+	}
+	return cacheTenantScope(tenant) + "/" + query
+}
+
+// cacheTenantScope returns the cache key prefix of a tenant's results. The
+// tenant is escaped, so query text cannot name another tenant's scope; the
+// empty tenant is the default one.
+func cacheTenantScope(tenant string) string {
+	return "tenant:" + url.PathEscape(tenant)
+}
+
 // ProcessQuery handles the main query processing logic
 func (qp *QueryProcessor) ProcessQuery(ctx context.Context, req *QueryRequest) (*QueryResponse, error) {
+	// Advisories are not cached, so they follow the current safety settings,
+	// and are worked out first so they can explain a failed query too
+	warnings := qp.safetyChecker.Advise(req.Query)
+	response, err := qp.processQuery(ctx, req)
+	if err != nil {
+		if enhancedErr, ok := err.(*errors.EnhancedError); ok && len(warnings) > 0 {
+			enhancedErr.WithMetadata("warnings", warnings)
+		}
+		return response, err
+	}
+	response.Warnings = warnings
+	if !response.RequiresConfirmation {
+		qp.countExecution(req.Query, response.PromQL)
+	}
+	if req.Threshold == nil || response.RequiresConfirmation {
+		return response, err
+	}
+
+	// Thresholds are evaluated against live data, so after the response is
+	// cached and on every cache hit
+	qp.evaluateThreshold(ctx, req, response)
+	return response, nil
+}
+
+// processQuery generates the query for a request
+func (qp *QueryProcessor) processQuery(ctx context.Context, req *QueryRequest) (*QueryResponse, error) {
 	start := time.Now()
 
 	// Log query start
@@ -78,7 +481,21 @@ func (qp *QueryProcessor) ProcessQuery(ctx context.Context, req *QueryRequest) (
 	var response *QueryResponse
 	var processingErr error
 
+	// Per-stage durations, reported in slow query logs and response metadata
+	timings := make(map[string]int64)
+	stageStart := start
+	endStage := func(stage string) {
+		now := time.Now()
+		timings[stage] = now.Sub(stageStart).Milliseconds()
+		stageStart = now
+	}
+	telemetry := &QueryTelemetry{CacheLookup: cacheLookupSkipped, SafetyOutcome: safetyOutcomeNotRun, StageTimingsMs: timings}
+
 	defer func() {
+		if processingErr != nil {
+			withErrorTelemetry(req, processingErr, telemetry)
+		}
+
 		// Record metrics at the end
 		duration := time.Since(start)
 		success := processingErr == nil
@@ -90,6 +507,7 @@ func (qp *QueryProcessor) ProcessQuery(ctx context.Context, req *QueryRequest) (
 				"query":       req.Query,
 				"duration_ms": duration.Milliseconds(),
 				"error_type":  errorType,
+				"dependency":  errors.Dependency(processingErr),
 			})
 		} else {
 			qp.logger.Info(ctx, "Query processed successfully", map[string]interface{}{
@@ -99,137 +517,427 @@ func (qp *QueryProcessor) ProcessQuery(ctx context.Context, req *QueryRequest) (
 				"confidence":  response.Confidence,
 			})
 		}
+
+		eventData := map[string]interface{}{
+			"query":       req.Query,
+			"duration_ms": duration.Milliseconds(),
+			"success":     success,
+			"cache_hit":   cached,
+		}
+		if processingErr != nil {
+			eventData["error_type"] = errorType
+		}
+		qp.events.Publish(events.TypeQueryProcessed, eventData)
+
+		if processingErr == nil && !response.RequiresConfirmation {
+			qp.saveSessionRefinement(ctx, req, response.PromQL)
+		}
+
+		if qp.slowQueryThreshold > 0 && duration > qp.slowQueryThreshold {
+			qp.logger.Warn(ctx, "Slow query", map[string]interface{}{
+				"query":         req.Query,
+				"duration_ms":   duration.Milliseconds(),
+				"threshold_ms":  qp.slowQueryThreshold.Milliseconds(),
+				"stage_timings": timings,
+				"cache_hit":     cached,
+				"success":       success,
+			})
+			observability.GetGlobalMetrics().Inc(observability.MetricQuerySlow, map[string]string{
+				"cached": fmt.Sprintf("%t", cached),
+			})
+		}
 	}()
 
+	// Blocked queries are rejected before any other work
+	if err := qp.queryFilter.check(req.Query); err != nil {
+		errorType = "query_not_allowed"
+		processingErr = err
+		return nil, processingErr
+	}
+
+	// Reject oversized request context and model overrides that are not allowlisted
+	if err := qp.validateContext(req.Context); err != nil {
+		errorType = "invalid_context"
+		processingErr = err
+		return nil, processingErr
+	}
+	if err := qp.validateModel(req.Model); err != nil {
+		errorType = "invalid_model"
+		processingErr = err
+		return nil, processingErr
+	}
+	if err := qp.validateLanguage(req); err != nil {
+		errorType = "invalid_language"
+		processingErr = err
+		return nil, processingErr
+	}
+	if err := validateRefinement(req); err != nil {
+		errorType = "invalid_refinement"
+		processingErr = err
+		return nil, processingErr
+	}
+	if err := validateThreshold(req); err != nil {
+		errorType = "invalid_threshold"
+		processingErr = err
+		return nil, processingErr
+	}
+	req = qp.withSessionRefinement(ctx, req)
+
+	// Select the Mimir tenant, enforcing the caller's tenant binding
+	tenant, err := qp.resolveTenant(ctx, req.Tenant)
+	if err != nil {
+		errorType = "tenant"
+		processingErr = err
+		return nil, processingErr
+	}
+
+	// Redeem a confirmation for a previously generated expensive query
+	if req.ConfirmationToken != "" {
+		response, processingErr = qp.confirmQuery(ctx, req, tenant)
+		if processingErr != nil {
+			errorType = "confirmation"
+			return nil, processingErr
+		}
+		response.ProcessingTime = time.Since(start)
+		return withTelemetry(req, response, telemetry), nil
+	}
+
 	// Check cache first
-	if cachedResult, err := qp.getCachedResult(ctx, req.Query); err == nil {
+	cachedResult, err := qp.getCachedResult(ctx, cacheQuery(req, tenant))
+	endStage("cache_lookup_ms")
+	if err == nil {
 		qp.logger.Debug(ctx, "Cache hit for query", map[string]interface{}{
 			"query": req.Query,
 		})
 		cachedResult.CacheHit = true
 		cachedResult.ProcessingTime = time.Since(start)
+		if cachedResult.Metadata == nil {
+			cachedResult.Metadata = make(map[string]interface{})
+		}
+		cachedResult.Metadata["stage_timings_ms"] = timings
 		response = cachedResult
-		return cachedResult, nil
+		telemetry.CacheLookup = cacheLookupHit
+		return withTelemetry(req, cachedResult, telemetry), nil
+	}
+	telemetry.CacheLookup = cacheLookupMiss
+	if err == errCacheTimeout {
+		telemetry.CacheLookup = cacheLookupTimeout
+		qp.logger.Warn(ctx, "Cache lookup timed out, treating as cache miss", map[string]interface{}{
+			"query":      req.Query,
+			"timeout_ms": qp.cacheTimeout.Milliseconds(),
+		})
+	}
+
+	// Wait briefly for a query slot rather than piling up under load. Cache
+	// hits are cheap, so only queries that are generated take a slot.
+	release, err := qp.acquireQuerySlot(ctx)
+	if err != nil {
+		errorType = "overloaded"
+		if ctx.Err() != nil {
+			errorType = "cancelled"
+		}
+		processingErr = err
+		return nil, processingErr
 	}
+	defer release()
 
 	// Classify intent
 	intent, err := qp.intentClassifier.ClassifyIntent(req.Query)
+	endStage("intent_classification_ms")
 	if err != nil {
 		errorType = "intent_classification"
 		processingErr = errors.NewIntentClassificationError(err, req.Query)
 		return nil, processingErr
 	}
+	telemetry.IntentType = intent.Type
+	telemetry.IntentConfidence = intent.Confidence
+	if intent.Start != nil {
+		if err := qp.safetyChecker.ValidateWindow(*intent.Start, *intent.End); err != nil {
+			errorType = "time_window"
+			processingErr = err
+			return nil, processingErr
+		}
+	}
 
-	// Generate embeddings for semantic search
-	embedding, err := qp.llmClient.GetEmbedding(ctx, req.Query)
-	if err != nil {
-		errorType = "embedding_generation"
-		processingErr = errors.NewEmbeddingGenerationError(err)
-		return nil, processingErr
+	// Queries naming an exact catalog metric are built without the LLM, unless
+	// they refine a previous query or ask for a non-English explanation
+	var llmResponse *llm.Response
+	if !req.refining() && explanationLanguage(req) == defaultLanguage {
+		llmResponse = qp.directQuery(ctx, intent)
 	}
+	endStage("direct_query_ms")
+	direct := llmResponse != nil
+	telemetry.DirectQuery = direct
+
+	var similarQueries []semantic.SimilarQuery
+	var prompt string
+	embeddingRateLimited := false
+	if !direct {
+		// Find similar past queries to use as examples, unless the request
+		// opted out of them
+		if req.examplesEnabled() {
+			// Generate embeddings for semantic search
+			embedding, err := qp.queryEmbedding(ctx, req.Query)
+			endStage("embedding_ms")
+			switch {
+			case llm.IsRateLimited(err):
+				// Embeddings are limited separately from generation, so the
+				// query is still generated, without examples
+				embeddingRateLimited = true
+				qp.logger.Warn(ctx, "Embedding rate limited; continuing without similar query examples", map[string]interface{}{
+					"query": req.Query,
+					"error": err.Error(),
+				})
+			case err != nil:
+				errorType = "embedding_generation"
+				processingErr = errors.NewEmbeddingGenerationError(err)
+				return nil, processingErr
+			default:
+				// Find similar queries
+				similarQueries, err = qp.semanticMapper.FindSimilarQueries(ctx, embedding)
+				endStage("similarity_search_ms")
+				if err != nil {
+					// Don't fail - similar queries are optional
+					qp.reportSimilarityError(ctx, err)
+				}
+				similarQueries = dedupeSimilarQueries(similarQueries)
+				telemetry.SimilarQueries = len(similarQueries)
+			}
+		}
 
-	// Find similar queries
-	similarQueries, err := qp.semanticMapper.FindSimilarQueries(ctx, embedding)
-	if err != nil {
-		// Log warning but don't fail - similar queries are optional
-		qp.logger.Warn(ctx, "Failed to find similar queries", map[string]interface{}{
-			"error": err.Error(),
+		// Build enhanced prompt
+		prompt, err = qp.buildPrompt(ctx, req, intent, similarQueries)
+		endStage("prompt_building_ms")
+		if err != nil {
+			errorType = "prompt_building"
+			processingErr = errors.Wrap(err, errors.ErrCodePromptBuilding, "Failed to build prompt for query generation").
+				WithDetails("An error occurred while constructing the prompt for the AI model").
+				WithSuggestion("This is an internal error. Please try your query again.").
+				WithMetadata("retryable", true).
+				WithDependency(errors.DependencyDatabase)
+			return nil, processingErr
+		}
+
+		// Log the prompt for debugging
+		qp.logger.Debug(ctx, "Generated prompt for LLM", map[string]interface{}{
+			"prompt": prompt,
 		})
+		telemetry.PromptTokensEstimate = estimatePromptTokens(prompt)
+		telemetry.PromptTokenBudget = qp.promptBudget(qp.requestModel(req))
+
+		// Generate PromQL using LLM
+		temperature := qp.requestTemperature(req)
+		llmResponse, err = llm.GenerateQueryWithOptions(ctx, qp.llmClient, prompt, llm.GenerateOptions{Model: req.Model, Temperature: &temperature})
+		endStage("llm_ms")
+		telemetry.LLMLatencyMs = timings["llm_ms"]
+		if err != nil {
+			errorType = "query_generation"
+			processingErr = errors.NewQueryGenerationError(err)
+			return nil, processingErr
+		}
 	}
 
-	// Build enhanced prompt
-	prompt, err := qp.buildPrompt(ctx, req, intent, similarQueries)
-	if err != nil {
-		errorType = "prompt_building"
-		processingErr = errors.Wrap(err, errors.ErrCodePromptBuilding, "Failed to build prompt for query generation").
-			WithDetails("An error occurred while constructing the prompt for the AI model").
-			WithSuggestion("This is an internal error. Please try your query again.").
-			WithMetadata("retryable", true)
+	// Check if LLM returned an error message (no suitable metrics found)
+	if err := llmRefusal(llmResponse); err != nil {
+		errorType = "no_suitable_metrics"
+		processingErr = err
+		return nil, processingErr
+	}
+	if err := llmEmptyQuery(llmResponse); err != nil {
+		errorType = "empty_query"
+		processingErr = err
 		return nil, processingErr
 	}
 
-	// Log the prompt for debugging
-	qp.logger.Debug(ctx, "Generated prompt for LLM", map[string]interface{}{
-		"prompt": prompt,
-	})
-
-	// Generate PromQL using LLM
-	llmResponse, err := qp.llmClient.GenerateQuery(ctx, prompt)
+	// Rewrite and validate the query through the post-processors, which
+	// include the safety checks
+	generated := &GeneratedQuery{PromQL: llmResponse.PromQL, Intent: intent}
+	rejectedBy, err := qp.postProcess(ctx, generated)
+	endStage("safety_validation_ms")
 	if err != nil {
-		errorType = "query_generation"
-		processingErr = errors.NewQueryGenerationError(err)
+		errorType = postProcessErrorType(rejectedBy)
+		telemetry.SafetyOutcome = safetyOutcomeRejected
+		processingErr = err
 		return nil, processingErr
 	}
+	telemetry.SafetyOutcome = safetyOutcomePassed
+	if generated.PromQL != llmResponse.PromQL {
+		processed := *llmResponse
+		processed.PromQL = generated.PromQL
+		llmResponse = &processed
+	}
+	if !direct {
+		qp.sampleForEvaluation(req, prompt, intent, llmResponse)
+	}
 
-	// Check if LLM returned an error message (no suitable metrics found)
-	if strings.HasPrefix(strings.TrimSpace(llmResponse.PromQL), "ERROR:") {
-		errorType = "no_suitable_metrics"
-		processingErr = errors.Wrap(nil, errors.ErrCodeQueryGeneration, strings.TrimPrefix(strings.TrimSpace(llmResponse.PromQL), "ERROR:")).
-			WithDetails("The requested query cannot be fulfilled with the currently discovered metrics").
-			WithSuggestion("Check available services and metrics, or wait for service discovery to complete").
-			WithMetadata("retryable", true).
-			WithMetadata("llm_message", llmResponse.PromQL)
-		return nil, processingErr
+	// Direct queries do not depend on the intent type classification
+	confidence := llmResponse.Confidence
+	if !direct {
+		confidence = adjustConfidence(confidence, intent.Confidence)
 	}
 
-	// Validate query safety
-	if err := qp.safetyChecker.ValidateQuery(llmResponse.PromQL); err != nil {
-		errorType = "safety_validation"
-		processingErr = err // Already an enhanced error from SafetyChecker
-		observability.GetGlobalMetrics().Inc(observability.MetricQuerySafetyViolation, map[string]string{
-			"error_type": errorType,
-		})
-		return nil, processingErr
+	// Absolute windows are executed as range queries
+	var window time.Duration
+	if intent.Start != nil {
+		window = intent.End.Sub(*intent.Start)
 	}
 
 	// Build response
 	response = &QueryResponse{
 		PromQL:         llmResponse.PromQL,
 		Explanation:    llmResponse.Explanation,
-		Confidence:     llmResponse.Confidence,
-		EstimatedCost:  qp.estimateQueryCost(llmResponse.PromQL),
+		Confidence:     confidence,
+		EstimatedCost:  qp.estimateQueryCost(llmResponse.PromQL, window),
 		CacheHit:       false,
 		ProcessingTime: time.Since(start),
+		Suggestions:    generated.Warnings,
+		Intent:         intent,
 		Metadata: map[string]interface{}{
-			"intent":          intent,
-			"similar_queries": len(similarQueries),
+			// Deprecated: kept until clients have moved to the Intent field
+			"intent":            intent,
+			"intent_confidence": intent.Confidence,
+			"llm_confidence":    llmResponse.Confidence,
+			"similar_queries":   len(similarQueries),
+			"canonical_promql":  canonicalizePromQL(llmResponse.PromQL),
+			"stage_timings_ms":  timings,
 		},
 	}
+	if !req.examplesEnabled() {
+		response.Metadata["examples_disabled"] = true
+	}
+	if embeddingRateLimited {
+		response.Metadata["degraded"] = "embedding_rate_limited"
+	}
+	if req.PreviousPromQL != "" {
+		response.Metadata["refined_from"] = req.PreviousPromQL
+	}
+	if direct {
+		response.Metadata["direct_metric"] = intent.Metric
+	} else {
+		response.Metadata["temperature"] = qp.requestTemperature(req)
+		if model := qp.requestModel(req); model != "" {
+			response.Metadata["model"] = model
+		}
+	}
+	if tenant != "" {
+		response.Metadata["tenant"] = tenant
+	}
+	if describer := qp.describerFor(tenant); describer != nil {
+		if intent.Start != nil {
+			step := rangeQueryStep(intent.End.Sub(*intent.Start))
+			response.Metadata["mimir_request"] = describer.DescribeQueryRange(llmResponse.PromQL, *intent.Start, *intent.End, step)
+		} else {
+			response.Metadata["mimir_request"] = describer.DescribeQuery(llmResponse.PromQL, time.Time{})
+		}
+	}
+
+	// Queries above the soft cost threshold must be confirmed, and are not cached
+	// so a later request cannot bypass confirmation
+	if qp.needsConfirmation(response) {
+		if err := qp.requireConfirmation(ctx, req, tenant, response); err != nil {
+			errorType = "confirmation"
+			processingErr = errors.Wrap(err, errors.ErrCodeCacheWrite, "Failed to store confirmation token").
+				WithDetails("The query exceeds the cost confirmation threshold but could not be held for confirmation").
+				WithMetadata("retryable", true).
+				WithDependency(errors.DependencyCache)
+			return nil, processingErr
+		}
+		return withTelemetry(req, response, telemetry), nil
+	}
+
+	// Absolute windows may be relative to the current day ("yesterday"), so the
+	// same query text can resolve differently later and is not cached
+	if intent.Start != nil {
+		return withTelemetry(req, response, telemetry), nil
+	}
+
+	// Queries generated without examples because embeddings were rate limited
+	// are not cached, so the next request gets a query built with them
+	if embeddingRateLimited {
+		return withTelemetry(req, response, telemetry), nil
+	}
 
 	// Cache the result
-	if err := qp.cacheResult(ctx, req.Query, response); err != nil {
+	if err := qp.cacheResult(ctx, cacheQuery(req, tenant), response); err == errCacheTimeout {
+		qp.logger.Warn(ctx, "Cache write timed out, result not cached", map[string]interface{}{
+			"query":      req.Query,
+			"timeout_ms": qp.cacheTimeout.Milliseconds(),
+		})
+	} else if err != nil {
 		qp.logger.Warn(ctx, "Failed to cache query result", map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
 
-	return response, nil
+	// Telemetry describes this request only, so it is added after caching
+	return withTelemetry(req, response, telemetry), nil
 }
 
-// buildPrompt creates an enhanced prompt for the LLM
+// promptResponseInstruction ends every query generation prompt
+const promptResponseInstruction = "\nYour Response (PromQL query or ERROR):"
+
+// buildPrompt creates an enhanced prompt for the LLM. The least relevant
+// services are left out of the catalog until the prompt fits the context
+// window of the model generating the query.
 func (qp *QueryProcessor) buildPrompt(ctx context.Context, req *QueryRequest, intent *QueryIntent, similarQueries []semantic.SimilarQuery) (string, error) {
+	if !req.examplesEnabled() {
+		similarQueries = nil
+	}
+
+	// Add the discovered services most relevant to the query and their metrics
+	allServices, err := qp.semanticMapper.GetServices(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get services for prompt: %w", err)
+	}
+	services, omittedServices := selectPromptServices(allServices, req.Query, intent, similarQueries, qp.maxPromptServices)
+
+	// The catalog only shrinks from here, so help and types for these
+	// services cover it
+	help := qp.metricHelp(ctx, services)
+	storedTypes := qp.storedMetricTypes(ctx, services)
+
+	budget := qp.promptBudget(qp.requestModel(req))
+	prompt := qp.writePrompt(req, intent, similarQueries, services, omittedServices, help, storedTypes)
+	for estimatePromptTokens(prompt) > budget && len(services) > 1 {
+		services, omittedServices = selectPromptServices(allServices, req.Query, intent, similarQueries, len(services)-1)
+		prompt = qp.writePrompt(req, intent, similarQueries, services, omittedServices, help, storedTypes)
+	}
+
+	// Log the number of services discovered
+	fmt.Printf("DEBUG: Building prompt with %d discovered services\n", len(services))
+
+	return prompt, nil
+}
+
+// writePrompt renders the prompt with the given catalog services and, when
+// help is not nil, the help text of their metrics. Metrics are grouped by
+// their stored types.
+func (qp *QueryProcessor) writePrompt(req *QueryRequest, intent *QueryIntent, similarQueries []semantic.SimilarQuery, services []semantic.Service, omittedServices int, help, storedTypes map[string]string) string {
 	var promptBuilder strings.Builder
 
 	promptBuilder.WriteString("You are a PromQL expert assistant. Your task is to convert natural language queries into accurate PromQL queries.\n\n")
 
+	// Structured answers carry the confidence and any error in their own
+	// fields, so the free-form answer format is only described for text
+	structured := llm.AnswersStructured(qp.llmClient)
 	promptBuilder.WriteString("=== CRITICAL RULES ===\n")
 	promptBuilder.WriteString("1. ONLY use metrics from the Available Metrics Catalog below - no exceptions\n")
-	promptBuilder.WriteString("2. If the requested metric type doesn't exist, respond with: ERROR: No suitable metrics found. [explanation]\n")
-	promptBuilder.WriteString("3. Return ONLY the PromQL query or ERROR message - no markdown, explanations, or code blocks\n")
-	promptBuilder.WriteString("4. Apply correct PromQL functions based on metric types:\n")
+	if structured {
+		promptBuilder.WriteString("2. If the requested metric type doesn't exist, answer with status \"error\" and explain why\n")
+		promptBuilder.WriteString("3. Apply correct PromQL functions based on metric types:\n")
+	} else {
+		promptBuilder.WriteString("2. If the requested metric type doesn't exist, respond with: ERROR: No suitable metrics found. [explanation]\n")
+		promptBuilder.WriteString("3. Return ONLY the PromQL query or ERROR message and the confidence line from rule 5 - no markdown, explanations, or code blocks\n")
+		promptBuilder.WriteString("4. Apply correct PromQL functions based on metric types:\n")
+	}
 	promptBuilder.WriteString("   - Counters (e.g., *_total, *_count): Use rate() or increase()\n")
 	promptBuilder.WriteString("   - Gauges (e.g., *_active_*, *_current_*, *_size_): Use directly or with aggregations\n")
 	promptBuilder.WriteString("   - Histograms (*_bucket): Use histogram_quantile() for percentiles\n")
-	promptBuilder.WriteString("   - Summaries (*_sum, *_count): Calculate averages using sum/count\n\n")
-
-	// Add ALL discovered services and their metrics
-	services, err := qp.semanticMapper.GetServices(ctx)
-	if err != nil {
-		return "", fmt.Errorf("failed to get services for prompt: %w", err)
+	promptBuilder.WriteString("   - Summaries (*_sum, *_count): Calculate averages using sum/count\n")
+	if !structured {
+		promptBuilder.WriteString("5. End with a final line rating how confident you are that the query answers the request: CONFIDENCE: <number between 0 and 1>\n")
 	}
-
-	// Log the number of services discovered
-	fmt.Printf("DEBUG: Building prompt with %d discovered services\n", len(services))
+	promptBuilder.WriteString("\n")
 
 	if len(services) > 0 {
 		promptBuilder.WriteString("=== AVAILABLE METRICS CATALOG ===\n")
@@ -239,10 +947,12 @@ func (qp *QueryProcessor) buildPrompt(ctx context.Context, req *QueryRequest, in
 		const maxMetricsPerService = 50 // Limit to avoid token limits
 
 		for _, service := range services {
-			promptBuilder.WriteString(fmt.Sprintf("Service: %s (namespace: %s)\n", service.Name, service.Namespace))
-			if len(service.MetricNames) > 0 {
+			promptBuilder.WriteString(fmt.Sprintf("Service: %s@%s\n", service.Name, service.Namespace))
+			// Deprecated metrics are left out so the LLM does not pick them
+			metricNames := qp.deprecated.current(service.MetricNames)
+			if len(metricNames) > 0 {
 				// Categorize metrics by type for better context
-				counters, gauges, histograms, others := categorizeMetrics(service.MetricNames)
+				counters, gauges, histograms, others := categorizeMetrics(metricNames, storedTypes, qp.metricTypes)
 
 				// Filter to relevant metrics if service is targeted or limit if too many
 				var filteredCounters, filteredGauges, filteredHistograms, filteredOthers []string
@@ -253,7 +963,7 @@ func (qp *QueryProcessor) buildPrompt(ctx context.Context, req *QueryRequest, in
 					filteredGauges = gauges
 					filteredHistograms = histograms
 					filteredOthers = others
-				} else if len(service.MetricNames) > maxMetricsPerService {
+				} else if len(metricNames) > maxMetricsPerService {
 					// For large services, show a sample with metric count
 					filteredCounters = limitSlice(counters, 10)
 					filteredGauges = limitSlice(gauges, 10)
@@ -266,31 +976,31 @@ func (qp *QueryProcessor) buildPrompt(ctx context.Context, req *QueryRequest, in
 					filteredOthers = others
 				}
 
-				totalMetrics := len(service.MetricNames)
+				totalMetrics := len(metricNames)
 				shownMetrics := len(filteredCounters) + len(filteredGauges) + len(filteredHistograms) + len(filteredOthers)
 
 				if len(filteredCounters) > 0 {
 					promptBuilder.WriteString("  Counters (use rate/increase):\n")
 					for _, metric := range filteredCounters {
-						promptBuilder.WriteString(fmt.Sprintf("    - %s\n", metric))
+						promptBuilder.WriteString(promptMetricLine(metric, help))
 					}
 				}
 				if len(filteredGauges) > 0 {
 					promptBuilder.WriteString("  Gauges (use directly or aggregate):\n")
 					for _, metric := range filteredGauges {
-						promptBuilder.WriteString(fmt.Sprintf("    - %s\n", metric))
+						promptBuilder.WriteString(promptMetricLine(metric, help))
 					}
 				}
 				if len(filteredHistograms) > 0 {
 					promptBuilder.WriteString("  Histograms (use histogram_quantile):\n")
 					for _, metric := range filteredHistograms {
-						promptBuilder.WriteString(fmt.Sprintf("    - %s\n", metric))
+						promptBuilder.WriteString(promptMetricLine(metric, help))
 					}
 				}
 				if len(filteredOthers) > 0 {
 					promptBuilder.WriteString("  Other metrics:\n")
 					for _, metric := range filteredOthers {
-						promptBuilder.WriteString(fmt.Sprintf("    - %s\n", metric))
+						promptBuilder.WriteString(promptMetricLine(metric, help))
 					}
 				}
 
@@ -303,25 +1013,38 @@ func (qp *QueryProcessor) buildPrompt(ctx context.Context, req *QueryRequest, in
 			}
 			promptBuilder.WriteString("\n")
 		}
+		if omittedServices > 0 {
+			promptBuilder.WriteString(fmt.Sprintf("... and %d more services omitted as less relevant to this query (the user can name a service to include it)\n\n", omittedServices))
+		}
 		promptBuilder.WriteString("=== END CATALOG ===\n\n")
 	} else {
 		promptBuilder.WriteString("WARNING: No services have been discovered yet. Return ERROR.\n\n")
 	}
 
-	// Add similar queries as examples
-	if len(similarQueries) > 0 {
+	// Add pinned examples, then similar queries, as examples
+	pinned, past := qp.promptExamples(similarQueries)
+	if len(pinned) > 0 {
+		promptBuilder.WriteString("=== REFERENCE EXAMPLES ===\n")
+		for _, example := range pinned {
+			promptBuilder.WriteString(fmt.Sprintf("Q: %s\nA: %s\n\n", example.Query, example.PromQL))
+		}
+	}
+	if len(past) > 0 {
 		promptBuilder.WriteString("=== EXAMPLES FROM PAST QUERIES ===\n")
-		for _, sq := range similarQueries[:min(3, len(similarQueries))] {
+		for _, sq := range past {
 			promptBuilder.WriteString(fmt.Sprintf("Q: %s\nA: %s\n\n", sq.Query, sq.PromQL))
 		}
 	}
 
+	// Add the query being refined, if any
+	writeRefinementPrompt(&promptBuilder, req)
+
 	// Add the main query with context
 	promptBuilder.WriteString("=== YOUR TASK ===\n")
 	promptBuilder.WriteString(fmt.Sprintf("User Query: \"%s\"\n", req.Query))
 
 	// Add extracted intent for context
-	if intent.Type != "" || intent.Service != "" || intent.TimeRange != "" {
+	if intent.Type != "" || intent.Service != "" || intent.TimeRange != "" || intent.Start != nil || intent.Ranking != "" || intent.quantileNamed {
 		promptBuilder.WriteString("\nDetected Context:\n")
 		if intent.Type != "" {
 			promptBuilder.WriteString(fmt.Sprintf("  - Intent: %s\n", intent.Type))
@@ -332,28 +1055,88 @@ func (qp *QueryProcessor) buildPrompt(ctx context.Context, req *QueryRequest, in
 		if intent.TimeRange != "" {
 			promptBuilder.WriteString(fmt.Sprintf("  - Time Range: %s\n", intent.TimeRange))
 		}
+		if len(intent.Aliases) > 0 {
+			aliases := make([]string, 0, len(intent.Aliases))
+			for alias := range intent.Aliases {
+				aliases = append(aliases, alias)
+			}
+			sort.Strings(aliases)
+			promptBuilder.WriteString("  - Metric Aliases (use the canonical metric names in the query):\n")
+			for _, alias := range aliases {
+				promptBuilder.WriteString(fmt.Sprintf("    - \"%s\" means %s\n", alias, intent.Aliases[alias]))
+			}
+		}
+		if intent.Start != nil {
+			promptBuilder.WriteString(fmt.Sprintf("  - Absolute Window: %s (applied by the range query API; do not encode the window in the query)\n", formatWindow(*intent.Start, *intent.End)))
+		}
+		// The latency default is left to the LLM, which may know a better
+		// percentile for the metric
+		if intent.quantileNamed {
+			promptBuilder.WriteString(fmt.Sprintf("  - Percentile: %s quantile (use histogram_quantile(%s, ...) on the *_bucket histogram)\n", formatQuantile(intent.Quantile), formatQuantile(intent.Quantile)))
+		}
+		if intent.Ranking != "" {
+			fn := "topk"
+			if intent.Ranking == "bottom" {
+				fn = "bottomk"
+			}
+			promptBuilder.WriteString(fmt.Sprintf("  - Ranking: %s %d\n", intent.Ranking, intent.Limit))
+			promptBuilder.WriteString(fmt.Sprintf("\nRanking Guidance: wrap the aggregated expression in %s(%d, ...), e.g. %s(%d, sum by (service) (rate(metric_total[5m]))). Aggregate by the dimension being ranked before applying %s.\n", fn, intent.Limit, fn, intent.Limit, fn))
+		}
 	}
 
-	promptBuilder.WriteString("\nYour Response (PromQL query or ERROR):")
+	// Tell apart services sharing a name across namespaces
+	qp.writeNamespacePrompt(&promptBuilder, req, intent, services)
+
+	// Ask for the explanation in the requested language
+	writeLanguagePrompt(&promptBuilder, req)
+
+	promptBuilder.WriteString(promptResponseInstruction)
 
-	return promptBuilder.String(), nil
+	return promptBuilder.String()
 }
 
-// categorizeMetrics categorizes metrics by type based on naming conventions
-func categorizeMetrics(metrics []string) (counters, gauges, histograms, others []string) {
-	for _, metric := range metrics {
-		metricLower := strings.ToLower(metric)
-		switch {
-		case strings.HasSuffix(metricLower, "_total") || strings.HasSuffix(metricLower, "_count"):
+// llmRefusal returns an error if the LLM answered with an ERROR message
+// instead of a query, as the prompt instructs when no suitable metrics exist
+func llmRefusal(llmResponse *llm.Response) error {
+	message := llmResponse.Error
+	if message == "" {
+		if !strings.HasPrefix(strings.TrimSpace(llmResponse.PromQL), "ERROR:") {
+			return nil
+		}
+		message = llmResponse.PromQL
+	}
+	return errors.Wrap(nil, errors.ErrCodeQueryGeneration, strings.TrimPrefix(strings.TrimSpace(message), "ERROR:")).
+		WithDetails("The requested query cannot be fulfilled with the currently discovered metrics").
+		WithSuggestion("Check available services and metrics, or wait for service discovery to complete").
+		WithMetadata("retryable", true).
+		WithMetadata("llm_message", message).
+		WithDependency(errors.DependencyLLM)
+}
+
+// llmEmptyQuery returns an error if the LLM answered with neither a query nor
+// an ERROR message. An empty query would otherwise pass safety validation.
+func llmEmptyQuery(llmResponse *llm.Response) error {
+	if strings.TrimSpace(llmResponse.PromQL) != "" {
+		return nil
+	}
+	return errors.New(errors.ErrCodeQueryGeneration, "AI model returned an empty query").
+		WithDetails("The AI model responded without a PromQL query or an explanation of why none could be generated").
+		WithSuggestion("This is usually transient. Please try your request again, or rephrase the query to name the service and metric.").
+		WithMetadata("retryable", true).
+		WithDependency(errors.DependencyLLM)
+}
+
+// categorizeMetrics categorizes metrics by type based on the configured
+// overrides, their stored types and naming conventions. Metrics of unknown
+// type, and summaries, are returned in others.
+func categorizeMetrics(metricNames []string, storedTypes map[string]string, overrides *metrics.TypeOverrides) (counters, gauges, histograms, others []string) {
+	for _, metric := range metricNames {
+		switch inferCatalogType(metric, storedTypes[metric], overrides) {
+		case metrics.MetricTypeCounter:
 			counters = append(counters, metric)
-		case strings.HasSuffix(metricLower, "_bucket"):
+		case metrics.MetricTypeHistogram:
 			histograms = append(histograms, metric)
-		case strings.Contains(metricLower, "_active_") ||
-		     strings.Contains(metricLower, "_current_") ||
-		     strings.Contains(metricLower, "_size") ||
-		     strings.Contains(metricLower, "_gauge") ||
-		     strings.HasSuffix(metricLower, "_bytes") ||
-		     strings.HasSuffix(metricLower, "_ratio"):
+		case metrics.MetricTypeGauge:
 			gauges = append(gauges, metric)
 		default:
 			others = append(others, metric)
@@ -370,34 +1153,58 @@ func limitSlice(slice []string, n int) []string {
 	return slice[:n]
 }
 
-// estimateQueryCost provides a rough estimate of query execution cost
-func (qp *QueryProcessor) estimateQueryCost(promql string) int {
-	cost := 1
+// lowIntentConfidence is the intent confidence below which the response
+// confidence is reduced
+const lowIntentConfidence = 0.5
 
-	// Add cost for aggregations
-	if strings.Contains(promql, "sum") || strings.Contains(promql, "avg") {
-		cost += 2
+// adjustConfidence lowers the LLM's confidence when the intent classification
+// was weak, scaling it by how far the intent confidence falls below the threshold
+func adjustConfidence(llmConfidence, intentConfidence float64) float64 {
+	if intentConfidence >= lowIntentConfidence {
+		return llmConfidence
 	}
+	return llmConfidence * (0.5 + intentConfidence)
+}
 
-	// Add cost for rate calculations
-	if strings.Contains(promql, "rate") || strings.Contains(promql, "increase") {
-		cost += 3
+// cacheContext derives a context for a single cache operation. It is bounded by
+// the cache timeout and by a quarter of the parent's remaining deadline.
+func (qp *QueryProcessor) cacheContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := qp.cacheTimeout
+	if timeout <= 0 {
+		timeout = defaultCacheTimeout
 	}
-
-	// Add cost for regex matching
-	if strings.Contains(promql, "=~") {
-		cost += 5
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline) / 4; remaining < timeout {
+			timeout = remaining
+		}
 	}
+	return context.WithTimeout(ctx, timeout)
+}
 
-	return cost
+// cacheError maps errors from a timed-out cache context to errCacheTimeout
+func cacheError(cacheCtx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	// The connection deadline derived from the context can fire just before the
+	// context itself reports expiry
+	netErr, isNetErr := err.(net.Error)
+	if cacheCtx.Err() == context.DeadlineExceeded || (isNetErr && netErr.Timeout()) {
+		observability.GetGlobalMetrics().Inc(observability.MetricQueryCacheTimeouts, nil)
+		return errCacheTimeout
+	}
+	return err
 }
 
 // getCachedResult retrieves cached query results
 func (qp *QueryProcessor) getCachedResult(ctx context.Context, query string) (*QueryResponse, error) {
+	cacheCtx, cancel := qp.cacheContext(ctx)
+	defer cancel()
+
 	key := fmt.Sprintf("query:%s", query)
-	cached, err := qp.cache.Get(ctx, key).Result()
+	cached, err := qp.cache.Get(cacheCtx, key).Result()
 	if err != nil {
-		return nil, err
+		return nil, cacheError(cacheCtx, err)
 	}
 
 	var response QueryResponse
@@ -408,16 +1215,23 @@ func (qp *QueryProcessor) getCachedResult(ctx context.Context, query string) (*Q
 	return &response, nil
 }
 
+// requestOnlyMetadata are the response metadata fields that describe how a
+// single request was served, and are not cached
+var requestOnlyMetadata = fieldSet([]string{"stage_timings_ms"})
+
 // cacheResult stores query results in cache
 func (qp *QueryProcessor) cacheResult(ctx context.Context, query string, response *QueryResponse) error {
 	key := fmt.Sprintf("query:%s", query)
 
-	data, err := json.Marshal(response)
+	data, err := json.Marshal(redactMetadata(response, requestOnlyMetadata))
 	if err != nil {
 		return err
 	}
 
-	return qp.cache.Set(ctx, key, data, 5*time.Minute).Err()
+	cacheCtx, cancel := qp.cacheContext(ctx)
+	defer cancel()
+
+	return cacheError(cacheCtx, qp.cache.Set(cacheCtx, key, data, 5*time.Minute).Err())
 }
 
 // AuthMiddleware is an interface for authentication middleware
@@ -425,10 +1239,29 @@ type AuthMiddleware interface {
 	Middleware() gin.HandlerFunc
 }
 
+// RoleAuthorizer is implemented by auth middleware that can restrict routes by role.
+// Admin routes are only registered when the middleware supports it.
+type RoleAuthorizer interface {
+	RequireRole(requiredRoles ...string) gin.HandlerFunc
+}
+
 // SetupRoutes configures HTTP routes with optional authentication
 func (qp *QueryProcessor) SetupRoutes(authMiddleware AuthMiddleware) *gin.Engine {
 	r := gin.Default()
 
+	// Resolve client IPs from forwarding headers only for trusted proxies.
+	// Gin trusts every peer unless told otherwise.
+	if err := r.SetTrustedProxies(qp.trustedProxies); err != nil {
+		qp.logger.Warn(context.Background(), "Invalid trusted proxies, forwarding headers are ignored", map[string]interface{}{
+			"error": err.Error(),
+		})
+		r.SetTrustedProxies(nil)
+	}
+
+	// Record per-route request counts and latency; installed before any route
+	// is registered so every route is measured
+	r.Use(observability.RouteMetricsMiddleware())
+
 	// Add CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
@@ -462,6 +1295,26 @@ func (qp *QueryProcessor) SetupRoutes(authMiddleware AuthMiddleware) *gin.Engine
 		}
 	})
 
+	// Liveness endpoint; reports only that the process is serving requests
+	r.GET("/livez", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"alive": true})
+	})
+
+	// Readiness endpoint for load balancers; not ready while unhealthy or
+	// while a readiness gate (such as initial discovery) is still closed
+	r.GET("/readyz", func(c *gin.Context) {
+		if qp.healthChecker == nil {
+			c.JSON(http.StatusOK, gin.H{"ready": true})
+			return
+		}
+		response := qp.healthChecker.GetReadinessResponse(c.Request.Context())
+		statusCode := http.StatusOK
+		if !response.Ready {
+			statusCode = http.StatusServiceUnavailable
+		}
+		c.JSON(statusCode, response)
+	})
+
 	// Public API v1 health endpoint
 	publicAPI := r.Group("/api/v1")
 	{
@@ -478,34 +1331,69 @@ func (qp *QueryProcessor) SetupRoutes(authMiddleware AuthMiddleware) *gin.Engine
 	api := r.Group("/api/v1")
 	if authMiddleware != nil {
 		api.Use(authMiddleware.Middleware())
+		if resolver, ok := authMiddleware.(TenantResolver); ok {
+			api.Use(tenantBindingMiddleware(resolver))
+		}
+		if resolver, ok := authMiddleware.(UserResolver); ok {
+			api.Use(callerMiddleware(resolver))
+		}
+		api.Use(metadataAccessMiddleware(authMiddleware))
 	}
 	{
 		// Main query endpoint
-		api.POST("/query", func(c *gin.Context) {
+		api.POST("/query", qp.maintenanceGate(), qp.queryFingerprintGate(), func(c *gin.Context) {
 			var req QueryRequest
-			if err := c.ShouldBindJSON(&req); err != nil {
-				enhancedErr := errors.NewInvalidInputError("request body", err.Error())
+			if err := qp.bindJSON(c, &req); err != nil {
+				enhancedErr := errors.NewRequestBodyError(err)
 				c.JSON(http.StatusBadRequest, formatErrorResponse(enhancedErr))
 				return
 			}
+			if c.Query("debug") == "true" {
+				req.Debug = true
+			}
+			if format := c.Query("format"); format != "" {
+				req.Format = format
+			}
+			if err := validateFormat(req.Format); err != nil {
+				c.JSON(http.StatusBadRequest, formatErrorResponse(err))
+				return
+			}
 
 			response, err := qp.ProcessQuery(c.Request.Context(), &req)
 			if err != nil {
-				c.JSON(getErrorStatusCode(err), formatErrorResponse(err))
+				setRetryAfter(c, err)
+				c.JSON(getErrorStatusCode(err), formatErrorResponse(qp.redactError(c, err)))
 				return
 			}
+			if req.Format == responseFormatGrafana {
+				response = qp.withGrafanaPanel(&req, response)
+			}
 
-			c.JSON(http.StatusOK, response)
+			c.JSON(http.StatusOK, qp.redactResponse(c, response))
 		})
 
+		// Batch query endpoint
+		api.POST("/query/batch", qp.maintenanceGate(), qp.handleBatchQuery)
+
+		// Catalog coverage pre-flight; classifies the query without generating it
+		api.POST("/query/validate-metrics", qp.handleValidateMetrics)
+
+		// Alerting rule generation
+		api.POST("/alert", qp.maintenanceGate(), qp.queryFingerprintGate(), qp.handleGenerateAlert)
+
 		// Services endpoints
 		api.GET("/services", qp.handleGetServices)
 		api.GET("/services/:id", qp.handleGetService)
 		api.GET("/services/search", qp.handleSearchServices)
 		api.GET("/services/:id/metrics", qp.handleGetServiceMetrics)
+		api.GET("/namespaces", qp.handleGetNamespaces)
+
+		// Aggregate catalog statistics for overview dashboards
+		api.GET("/stats", qp.handleGetStats)
 
 		// Metrics endpoints
 		api.GET("/metrics", qp.handleGetAllMetrics)
+		api.GET("/metrics/:name", qp.handleGetMetric)
 
 		// Query history endpoint
 		api.GET("/history", qp.handleGetHistory)
@@ -514,6 +1402,23 @@ func (qp *QueryProcessor) SetupRoutes(authMiddleware AuthMiddleware) *gin.Engine
 		api.GET("/suggestions", qp.handleGetSuggestions)
 	}
 
+	// Admin API routes (require admin role)
+	if authorizer, ok := authMiddleware.(RoleAuthorizer); ok {
+		admin := api.Group("/admin")
+		admin.Use(authorizer.RequireRole("admin"))
+		{
+			admin.POST("/reembed", qp.handleReembed)
+			admin.GET("/discovery/preview", qp.handleDiscoveryPreview)
+			admin.GET("/recording-rules", qp.handleGetRecordingRules)
+			admin.GET("/maintenance", qp.handleGetMaintenance)
+			admin.POST("/maintenance", qp.handleSetMaintenance)
+			admin.POST("/benchmark", qp.handleBenchmark)
+			if qp.events != nil {
+				admin.GET("/events", qp.handleEventStream)
+			}
+		}
+	}
+
 	// Serve static files for the web interface
 	r.Static("/assets", "./web/dist/assets")
 	r.StaticFile("/", "./web/dist/index.html")
@@ -529,19 +1434,20 @@ func (qp *QueryProcessor) handleGetServices(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, formatErrorResponse(enhancedErr))
 		return
 	}
-	c.JSON(http.StatusOK, services)
+	c.JSON(http.StatusOK, qp.withServiceDisplayNames(services))
 }
 
 func (qp *QueryProcessor) handleGetService(c *gin.Context) {
 	serviceID := c.Param("id")
+	namespace := c.DefaultQuery("namespace", qp.defaultNamespace)
 	// For now, we'll search by name since that's what we have
-	service, err := qp.semanticMapper.GetServiceByName(c.Request.Context(), serviceID, "default")
-	if err != nil {
+	service, err := qp.semanticMapper.GetServiceByName(c.Request.Context(), serviceID, namespace)
+	if err != nil || service == nil {
 		enhancedErr := errors.NewServiceNotFoundError(serviceID)
 		c.JSON(http.StatusNotFound, formatErrorResponse(enhancedErr))
 		return
 	}
-	c.JSON(http.StatusOK, service)
+	c.JSON(http.StatusOK, qp.withServiceDisplayName(service))
 }
 
 func (qp *QueryProcessor) handleSearchServices(c *gin.Context) {
@@ -557,7 +1463,7 @@ func (qp *QueryProcessor) handleSearchServices(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, formatErrorResponse(enhancedErr))
 		return
 	}
-	c.JSON(http.StatusOK, services)
+	c.JSON(http.StatusOK, qp.withServiceDisplayNames(services))
 }
 
 func (qp *QueryProcessor) handleGetServiceMetrics(c *gin.Context) {
@@ -568,7 +1474,28 @@ func (qp *QueryProcessor) handleGetServiceMetrics(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, formatErrorResponse(enhancedErr))
 		return
 	}
-	c.JSON(http.StatusOK, metrics)
+	c.JSON(http.StatusOK, qp.withMetricDisplayNames(metrics))
+}
+
+func (qp *QueryProcessor) handleGetNamespaces(c *gin.Context) {
+	namespaces, err := qp.semanticMapper.GetNamespaces(c.Request.Context())
+	if err != nil {
+		enhancedErr := errors.NewDatabaseQueryError(err, "fetching namespaces")
+		c.JSON(http.StatusInternalServerError, formatErrorResponse(enhancedErr))
+		return
+	}
+	c.JSON(http.StatusOK, namespaces)
+}
+
+// handleGetStats handles GET /api/v1/stats
+func (qp *QueryProcessor) handleGetStats(c *gin.Context) {
+	stats, err := qp.semanticMapper.GetCatalogStats(c.Request.Context())
+	if err != nil {
+		enhancedErr := errors.NewDatabaseQueryError(err, "fetching catalog statistics")
+		c.JSON(http.StatusInternalServerError, formatErrorResponse(enhancedErr))
+		return
+	}
+	c.JSON(http.StatusOK, stats)
 }
 
 func (qp *QueryProcessor) handleGetAllMetrics(c *gin.Context) {
@@ -587,7 +1514,7 @@ func (qp *QueryProcessor) handleGetAllMetrics(c *gin.Context) {
 		if err != nil {
 			continue // Skip services with metric errors
 		}
-		for _, metric := range metrics {
+		for _, metric := range qp.withMetricDisplayNames(metrics) {
 			allMetrics = append(allMetrics, metric)
 		}
 	}
@@ -610,23 +1537,81 @@ func (qp *QueryProcessor) handleGetSuggestions(c *gin.Context) {
 	c.JSON(http.StatusOK, suggestions)
 }
 
+// handleGetHistory lists stored queries, most recently used first, paged by
+// the limit and cursor query parameters. Each full page returns the cursor
+// of the next one.
 func (qp *QueryProcessor) handleGetHistory(c *gin.Context) {
-	// For now, we'll use an empty embedding to get all queries
-	// In a real implementation, you might want to add a GetRecentQueries method
-	// or filter by user ID from the auth context
-	emptyEmbedding := make([]float32, 1536) // Claude embedding size
+	limit, err := historyParam(c, "limit", defaultHistoryLimit)
+	if err == nil && (limit <= 0 || limit > maxHistoryLimit) {
+		err = errors.NewInvalidInputError("limit", fmt.Sprintf("limit must be between 1 and %d", maxHistoryLimit))
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, formatErrorResponse(err))
+		return
+	}
+	var after *semantic.QueryCursor
+	if cursor := c.Query("cursor"); cursor != "" {
+		if after, err = semantic.ParseQueryCursor(cursor); err != nil {
+			c.JSON(http.StatusBadRequest, formatErrorResponse(errors.NewInvalidInputError("cursor", "cursor must be a next_cursor returned by the history")))
+			return
+		}
+	}
 
-	queries, err := qp.semanticMapper.FindSimilarQueries(c.Request.Context(), emptyEmbedding)
+	queries, err := qp.semanticMapper.GetRecentQueries(c.Request.Context(), limit, after)
 	if err != nil {
 		enhancedErr := errors.NewDatabaseQueryError(err, "fetching query history")
 		c.JSON(http.StatusInternalServerError, formatErrorResponse(enhancedErr))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"queries": queries,
 		"count":   len(queries),
-	})
+		"limit":   limit,
+	}
+	// A short page is the last one
+	if len(queries) == limit {
+		if next := semantic.CursorAfter(queries[len(queries)-1]); next != nil {
+			response["next_cursor"] = next.Encode()
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// historyParam returns an integer query parameter of the history endpoint,
+// or fallback if it is not given
+func historyParam(c *gin.Context, name string, fallback int) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.NewInvalidInputError(name, fmt.Sprintf("%s must be an integer", name))
+	}
+	return n, nil
+}
+
+// handleDiscoveryPreview returns what discovery would create or update, without writing (admin only)
+func (qp *QueryProcessor) handleDiscoveryPreview(c *gin.Context) {
+	if qp.discovery == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": gin.H{
+				"code":    errors.ErrCodeDiscovery,
+				"message": "Service discovery is not enabled",
+			},
+		})
+		return
+	}
+
+	preview, err := qp.discovery.Preview(c.Request.Context())
+	if err != nil {
+		enhancedErr := errors.NewDiscoveryError(err, "preview discovery results")
+		c.JSON(http.StatusBadGateway, formatErrorResponse(enhancedErr))
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
 }
 
 // Utility function
@@ -686,11 +1671,21 @@ func getErrorStatusCode(err error) int {
 			return http.StatusUnauthorized
 		case errors.ErrCodeInsufficientPerms:
 			return http.StatusForbidden
-		case errors.ErrCodeServiceNotFound:
+		case errors.ErrCodeServiceNotFound, errors.ErrCodeMetricNotFound:
 			return http.StatusNotFound
+		case errors.ErrCodeOverloaded, errors.ErrCodeMaintenance:
+			return http.StatusServiceUnavailable
+		case errors.ErrCodeQueryRateLimited:
+			return http.StatusTooManyRequests
+		case errors.ErrCodeRequestTimeout:
+			return http.StatusGatewayTimeout
+		case errors.ErrCodeRequestCancelled:
+			return statusClientClosedRequest
 		case errors.ErrCodeSafetyValidation, errors.ErrCodeForbiddenMetric,
 			errors.ErrCodeExcessiveTimeRange, errors.ErrCodeHighCardinality,
-			errors.ErrCodeExpensiveOperation, errors.ErrCodeTooManyNested:
+			errors.ErrCodeExpensiveOperation, errors.ErrCodeTooManyNested,
+			errors.ErrCodeDeprecatedMetric, errors.ErrCodeExpensiveSubquery,
+			errors.ErrCodePostProcessing:
 			return http.StatusBadRequest
 		default:
 			return http.StatusInternalServerError
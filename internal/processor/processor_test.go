@@ -2,12 +2,24 @@
 package processor
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
 	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/observability"
 	"github.com/seanankenbruck/observability-ai/internal/semantic"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -114,7 +126,7 @@ func TestCategorizeMetrics(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			counters, gauges, histograms, others := categorizeMetrics(tt.metrics)
+			counters, gauges, histograms, others := categorizeMetrics(tt.metrics, nil, nil)
 
 			assert.Equal(t, tt.expectedCounters, counters, "Counters mismatch")
 			assert.Equal(t, tt.expectedGauges, gauges, "Gauges mismatch")
@@ -233,7 +245,7 @@ func TestBuildPrompt(t *testing.T) {
 			validateFunc: func(t *testing.T, prompt string) {
 				assert.Contains(t, prompt, "AVAILABLE METRICS CATALOG")
 				assert.Contains(t, prompt, "Service: api-gateway")
-				assert.Contains(t, prompt, "namespace: production")
+				assert.Contains(t, prompt, "Service: api-gateway@production")
 				assert.Contains(t, prompt, "Counters (use rate/increase)")
 				assert.Contains(t, prompt, "http_requests_total")
 				assert.Contains(t, prompt, "Histograms (use histogram_quantile)")
@@ -380,6 +392,75 @@ func TestBuildPrompt(t *testing.T) {
 			validateFunc: func(t *testing.T, prompt string) {
 				assert.Contains(t, prompt, "Time Range: 5m")
 				assert.Contains(t, prompt, "Detected Context")
+				assert.NotContains(t, prompt, "Ranking Guidance")
+			},
+		},
+		{
+			name: "with ranking in intent",
+			services: []semantic.Service{
+				{
+					ID:          "svc-1",
+					Name:        "api",
+					Namespace:   "default",
+					MetricNames: []string{"http_errors_total"},
+				},
+			},
+			intent: &QueryIntent{
+				Type:    "errors",
+				Action:  "show",
+				Ranking: "bottom",
+				Limit:   3,
+			},
+			similarQueries: []semantic.SimilarQuery{},
+			validateFunc: func(t *testing.T, prompt string) {
+				assert.Contains(t, prompt, "Ranking: bottom 3")
+				assert.Contains(t, prompt, "Ranking Guidance")
+				assert.Contains(t, prompt, "bottomk(3, ...)")
+				assert.NotContains(t, prompt, "topk(")
+			},
+		},
+		{
+			name: "with quantile in intent",
+			services: []semantic.Service{
+				{
+					ID:          "svc-1",
+					Name:        "api",
+					Namespace:   "default",
+					MetricNames: []string{"http_request_duration_seconds_bucket"},
+				},
+			},
+			intent: &QueryIntent{
+				Type:     "performance",
+				Action:   "show",
+				Metric:        "latency",
+				Quantile:      0.99,
+				quantileNamed: true,
+			},
+			similarQueries: []semantic.SimilarQuery{},
+			validateFunc: func(t *testing.T, prompt string) {
+				assert.Contains(t, prompt, "Percentile: 0.99 quantile")
+				assert.Contains(t, prompt, "histogram_quantile(0.99, ...)")
+			},
+		},
+		{
+			name: "no quantile hint without a requested percentile",
+			services: []semantic.Service{
+				{
+					ID:          "svc-1",
+					Name:        "api",
+					Namespace:   "default",
+					MetricNames: []string{"http_request_duration_seconds_bucket"},
+				},
+			},
+			intent: &QueryIntent{
+				Type:     "performance",
+				Action:   "show",
+				Metric:   "latency",
+				Quantile: defaultLatencyQuantile,
+			},
+			similarQueries: []semantic.SimilarQuery{},
+			validateFunc: func(t *testing.T, prompt string) {
+				assert.NotContains(t, prompt, "Percentile:")
 			},
 		},
 		{
@@ -438,6 +519,16 @@ func TestBuildPrompt(t *testing.T) {
 	}
 }
 
+// TestLLMRefusal_StructuredError tests that structured error answers are refusals
+func TestLLMRefusal_StructuredError(t *testing.T) {
+	err := llmRefusal(&llm.Response{Error: "No suitable metrics found. No CPU metrics are discovered."})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "No CPU metrics are discovered")
+	assert.Equal(t, errors.ErrCodeQueryGeneration, err.(*errors.EnhancedError).Code)
+
+	assert.NoError(t, llmRefusal(&llm.Response{PromQL: "up"}))
+}
+
 // TestProcessQuery_ErrorHandling tests ERROR response from LLM
 func TestProcessQuery_ErrorHandling(t *testing.T) {
 	ctx := context.Background()
@@ -525,6 +616,80 @@ func TestProcessQuery_ErrorHandling(t *testing.T) {
 	}
 }
 
+// TestProcessQuery_EmptyPromQL tests that an empty LLM answer is a retryable generation error
+func TestProcessQuery_EmptyPromQL(t *testing.T) {
+	for _, promql := range []string{"", "  \n\t"} {
+		t.Run(fmt.Sprintf("%q", promql), func(t *testing.T) {
+			mockLLM := &MockLLMClient{
+				response: &llm.Response{PromQL: promql, Confidence: 0.9},
+			}
+			cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+			qp := NewQueryProcessor(mockLLM, &MockSemanticMapper{}, cache)
+
+			response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show request rate"})
+			require.Error(t, err)
+			assert.Nil(t, response)
+
+			enhanced, ok := err.(*errors.EnhancedError)
+			require.True(t, ok)
+			assert.Equal(t, errors.ErrCodeQueryGeneration, enhanced.Code)
+			assert.Equal(t, true, enhanced.Metadata["retryable"])
+			assert.Contains(t, enhanced.Suggestion, "try your request again")
+		})
+	}
+}
+
+// TestProcessQuery_UseExamples tests that requests can opt out of similar-query examples
+func TestProcessQuery_UseExamples(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		name             string
+		useExamples      *bool
+		expectedSearches int
+		expectExamples   bool
+	}{
+		{name: "enabled by default", useExamples: nil, expectedSearches: 1, expectExamples: true},
+		{name: "disabled", useExamples: &disabled, expectedSearches: 0, expectExamples: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llmClient := &promptRecordingLLMClient{
+				MockLLMClient: MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_requests_total[5m]))`, Confidence: 0.9}},
+			}
+			mapper := &similarQueriesMapper{
+				similar: []semantic.SimilarQuery{
+					{ID: "q-1", Query: "past request rate query", PromQL: `rate(http_requests_total[1m])`, Similarity: 0.95},
+				},
+			}
+			cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+			qp := NewQueryProcessor(llmClient, mapper, cache)
+
+			response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show request rate", UseExamples: tt.useExamples})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedSearches, mapper.searches)
+			assert.Equal(t, tt.expectedSearches, llmClient.embeddings)
+			require.Len(t, llmClient.prompts, 1)
+			assert.Equal(t, tt.expectExamples, strings.Contains(llmClient.prompts[0], "EXAMPLES FROM PAST QUERIES"))
+			assert.Equal(t, tt.expectExamples, strings.Contains(llmClient.prompts[0], "past request rate query"))
+			assert.Equal(t, !tt.expectExamples, response.Metadata["examples_disabled"] == true)
+		})
+	}
+
+	t.Run("cached separately", func(t *testing.T) {
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(&MockLLMClient{response: &llm.Response{PromQL: `up`, Confidence: 0.9}}, &MockSemanticMapper{}, cache)
+
+		_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show request rate"})
+		require.NoError(t, err)
+
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show request rate", UseExamples: &disabled})
+		require.NoError(t, err)
+		assert.False(t, response.CacheHit)
+	})
+}
+
 // TestEstimateQueryCost tests query cost estimation
 func TestEstimateQueryCost(t *testing.T) {
 	tests := []struct {
@@ -572,7 +737,7 @@ func TestEstimateQueryCost(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			qp := &QueryProcessor{}
-			cost := qp.estimateQueryCost(tt.query)
+			cost := qp.estimateQueryCost(tt.query, 0)
 			assert.Equal(t, tt.expectedCost, cost)
 		})
 	}
@@ -618,10 +783,750 @@ func TestCacheOperations(t *testing.T) {
 	})
 }
 
+// TestReembedQueries tests re-embedding stored queries after a dimension change
+func TestReembedQueries(t *testing.T) {
+	ctx := context.Background()
+
+	mockMapper := &MockSemanticMapper{}
+	for i := 1; i <= 5; i++ {
+		mockMapper.storedQueries = append(mockMapper.storedQueries, semantic.StoredQuery{
+			ID:         fmt.Sprintf("q-%d", i),
+			Query:      fmt.Sprintf("query %d", i),
+			PromQL:     "up",
+			Dimensions: 1536,
+		})
+	}
+
+	qp := NewQueryProcessor(&MockLLMClient{embeddingDim: 384}, mockMapper, nil)
+
+	t.Run("updates embeddings to the new dimension", func(t *testing.T) {
+		result, err := qp.ReembedQueries(ctx, ReembedRequest{BatchSize: 2})
+		require.NoError(t, err)
+
+		assert.True(t, result.Completed)
+		assert.Equal(t, 384, result.Dimension)
+		assert.Equal(t, 5, result.Processed)
+		assert.Equal(t, 5, result.Updated)
+		assert.Equal(t, 0, result.Skipped)
+		assert.Empty(t, result.NextCursor)
+		for _, sq := range mockMapper.storedQueries {
+			assert.Equal(t, 384, sq.Dimensions, "query %s", sq.ID)
+		}
+	})
+
+	t.Run("rerun skips already migrated queries", func(t *testing.T) {
+		result, err := qp.ReembedQueries(ctx, ReembedRequest{BatchSize: 2})
+		require.NoError(t, err)
+
+		assert.True(t, result.Completed)
+		assert.Equal(t, 0, result.Updated)
+		assert.Equal(t, 5, result.Skipped)
+	})
+
+	t.Run("resumes from cursor", func(t *testing.T) {
+		result, err := qp.ReembedQueries(ctx, ReembedRequest{AfterID: "q-3", Force: true})
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, result.Processed)
+		assert.Equal(t, 2, result.Updated)
+	})
+}
+
+// TestCacheTimeoutDegradesToMiss tests that a slow cache is treated as a miss
+func TestCacheTimeoutDegradesToMiss(t *testing.T) {
+	// A server that accepts connections but never responds simulates a stalled Redis
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	slowRedis := redis.NewClient(&redis.Options{
+		Addr:        listener.Addr().String(),
+		ReadTimeout: 10 * time.Second,
+		MaxRetries:  -1,
+	})
+	defer slowRedis.Close()
+
+	qp := NewQueryProcessor(&MockLLMClient{
+		response: &llm.Response{
+			PromQL:      `rate(http_requests_total[5m])`,
+			Explanation: "Request rate",
+			Confidence:  0.9,
+		},
+	}, &MockSemanticMapper{
+		services: []semantic.Service{
+			{ID: "svc-1", Name: "api", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+		},
+	}, slowRedis)
+	qp.cacheTimeout = 50 * time.Millisecond
+
+	t.Run("lookup times out", func(t *testing.T) {
+		start := time.Now()
+		result, err := qp.getCachedResult(context.Background(), "request rate")
+		assert.Nil(t, result)
+		assert.Equal(t, errCacheTimeout, err)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("timeout bounded by remaining deadline", func(t *testing.T) {
+		qp.cacheTimeout = 5 * time.Second
+		defer func() { qp.cacheTimeout = 50 * time.Millisecond }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := qp.getCachedResult(ctx, "request rate")
+		assert.Equal(t, errCacheTimeout, err)
+		assert.Less(t, time.Since(start), 300*time.Millisecond)
+	})
+
+	t.Run("query succeeds as cache miss", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		response, err := qp.ProcessQuery(ctx, &QueryRequest{Query: "request rate for api"})
+		require.NoError(t, err)
+		assert.False(t, response.CacheHit)
+		assert.Equal(t, `rate(http_requests_total[5m])`, response.PromQL)
+	})
+}
+
+// TestSlowQueryLogging tests that queries exceeding the threshold are logged with a stage breakdown
+func TestSlowQueryLogging(t *testing.T) {
+	llmClient := &slowLLMClient{
+		MockLLMClient: MockLLMClient{response: &llm.Response{PromQL: `rate(http_requests_total[5m])`, Explanation: "Request rate", Confidence: 0.9}},
+		slowMarker:    "slow query",
+		delay:         300 * time.Millisecond,
+	}
+	mapper := &MockSemanticMapper{services: []semantic.Service{
+		{ID: "svc-1", Name: "api", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+	}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, mapper, cache)
+	qp.SetSlowQueryThreshold(200 * time.Millisecond)
+
+	var logs bytes.Buffer
+	qp.logger = observability.NewLogger("query-processor").WithOutput(&logs)
+
+	metrics := observability.GetGlobalMetrics()
+	slowCount := func() float64 {
+		if metric, ok := metrics.Get(observability.MetricQuerySlow, map[string]string{"cached": "false"}); ok {
+			return metric.Value
+		}
+		return 0
+	}
+	before := slowCount()
+
+	t.Run("fast query is not logged", func(t *testing.T) {
+		logs.Reset()
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate for api"})
+		require.NoError(t, err)
+
+		timings, ok := response.Metadata["stage_timings_ms"].(map[string]int64)
+		require.True(t, ok)
+		assert.Contains(t, timings, "embedding_ms")
+		assert.Contains(t, timings, "llm_ms")
+		assert.NotContains(t, logs.String(), `"message":"Slow query"`)
+		assert.Equal(t, before, slowCount())
+	})
+
+	t.Run("slow query is logged", func(t *testing.T) {
+		logs.Reset()
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "slow query for api"})
+		require.NoError(t, err)
+
+		timings := response.Metadata["stage_timings_ms"].(map[string]int64)
+		assert.GreaterOrEqual(t, timings["llm_ms"], int64(300))
+
+		var slowLog map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+			var entry map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			if entry["message"] == "Slow query" {
+				slowLog = entry
+			}
+		}
+		require.NotNil(t, slowLog, "expected a slow query log entry")
+		assert.Equal(t, "warn", slowLog["level"])
+
+		fields, ok := slowLog["fields"].(map[string]interface{})
+		require.True(t, ok)
+		assert.Equal(t, "slow query for api", fields["query"])
+		assert.Equal(t, float64(200), fields["threshold_ms"])
+		stages, ok := fields["stage_timings"].(map[string]interface{})
+		require.True(t, ok)
+		assert.Contains(t, stages, "llm_ms")
+		assert.Contains(t, stages, "embedding_ms")
+
+		assert.Equal(t, before+1, slowCount())
+	})
+
+	t.Run("cache hit reports its own timings", func(t *testing.T) {
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "slow query for api"})
+		require.NoError(t, err)
+		require.True(t, response.CacheHit)
+
+		timings, ok := response.Metadata["stage_timings_ms"].(map[string]int64)
+		require.True(t, ok)
+		assert.Contains(t, timings, "cache_lookup_ms")
+		assert.NotContains(t, timings, "llm_ms", "the generating request's timings are not cached")
+	})
+}
+
+// TestModelOverride tests per-query model selection against the allowlist
+func TestModelOverride(t *testing.T) {
+	llmClient := &modelRecordingLLMClient{
+		MockLLMClient: MockLLMClient{
+			response: &llm.Response{
+				PromQL:      `rate(http_requests_total[5m])`,
+				Explanation: "Request rate",
+				Confidence:  0.9,
+			},
+		},
+	}
+	mapper := &MockSemanticMapper{
+		services: []semantic.Service{
+			{ID: "svc-1", Name: "api", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+		},
+	}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, mapper, cache)
+	qp.SetModels("cheap-model", []string{"strong-model"})
+
+	t.Run("default model", func(t *testing.T) {
+		llmClient.models = nil
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate for api"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{""}, llmClient.models)
+		assert.Equal(t, "cheap-model", response.Metadata["model"])
+	})
+
+	t.Run("allowlisted override is used", func(t *testing.T) {
+		llmClient.models = nil
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate for api", Model: "strong-model"})
+		require.NoError(t, err)
+		assert.False(t, response.CacheHit, "override must not reuse the default model's cached result")
+		assert.Equal(t, []string{"strong-model"}, llmClient.models)
+		assert.Equal(t, "strong-model", response.Metadata["model"])
+	})
+
+	t.Run("unknown model is rejected", func(t *testing.T) {
+		llmClient.models = nil
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate for api", Model: "unknown-model"})
+		require.Error(t, err)
+		assert.Nil(t, response)
+		assert.Empty(t, llmClient.models)
+		assert.Equal(t, http.StatusBadRequest, getErrorStatusCode(err))
+		assert.Contains(t, err.Error(), "unknown-model")
+	})
+
+	t.Run("overrides rejected when none configured", func(t *testing.T) {
+		qp := NewQueryProcessor(llmClient, mapper, cache)
+		_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate for api", Model: "strong-model"})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, getErrorStatusCode(err))
+	})
+}
+
+// TestQueryHandlerContextLimits tests that oversized request context is rejected
+func TestQueryHandlerContextLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	llmClient := &modelRecordingLLMClient{
+		MockLLMClient: MockLLMClient{
+			response: &llm.Response{PromQL: `rate(http_requests_total[5m])`, Confidence: 0.9},
+		},
+	}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+	qp.SetContextLimits(3, 16)
+	router := qp.SetupRoutes(nil)
+
+	tooMany := map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"}
+
+	tests := []struct {
+		name           string
+		context        map[string]string
+		expectedStatus int
+		detailContains string
+	}{
+		{
+			name:           "within limits",
+			context:        map[string]string{"env": "prod", "team": "payments"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "too many entries",
+			context:        tooMany,
+			expectedStatus: http.StatusBadRequest,
+			detailContains: "4 entries exceeds the maximum of 3",
+		},
+		{
+			name:           "value too long",
+			context:        map[string]string{"env": strings.Repeat("x", 17)},
+			expectedStatus: http.StatusBadRequest,
+			detailContains: `value for "env" of length 17`,
+		},
+		{
+			name:           "key too long",
+			context:        map[string]string{strings.Repeat("k", 17): "prod"},
+			expectedStatus: http.StatusBadRequest,
+			detailContains: "key of length 17",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llmClient.models = nil
+			body, err := json.Marshal(QueryRequest{Query: "request rate " + tt.name, Context: tt.context})
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/query", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			if tt.expectedStatus != http.StatusBadRequest {
+				return
+			}
+
+			var response map[string]map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "INVALID_INPUT", response["error"]["code"])
+			assert.Contains(t, response["error"]["details"], tt.detailContains)
+			assert.Empty(t, llmClient.models, "invalid requests must not reach the LLM")
+		})
+	}
+}
+
+// TestQueryContextLabelKeys tests that reserved context keys are always
+// rejected and that configured label keys restrict the context
+func TestQueryContextLabelKeys(t *testing.T) {
+	llmClient := &MockLLMClient{
+		response: &llm.Response{PromQL: `rate(http_requests_total[5m])`, Confidence: 0.9},
+	}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+
+	process := func(requestContext map[string]string) error {
+		_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate", Context: requestContext})
+		return err
+	}
+
+	err := process(map[string]string{"__name__": "secrets_total"})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, getErrorStatusCode(err))
+	assert.Contains(t, err.(*errors.EnhancedError).Details, `key "__name__" is reserved`)
+	assert.NoError(t, process(map[string]string{"anything": "goes"}))
+
+	qp.SetContextLabelKeys([]string{"env", "region"})
+	assert.NoError(t, process(map[string]string{"env": "prod", "region": "eu-west-1"}))
+
+	err = process(map[string]string{"env": "prod", "job": "billing"})
+	require.Error(t, err)
+	enhancedErr := err.(*errors.EnhancedError)
+	assert.Contains(t, enhancedErr.Details, `key "job" is not an allowed label; allowed labels: env, region`)
+	assert.Equal(t, "job", enhancedErr.Metadata["context_key"])
+
+	err = process(map[string]string{"__address__": "10.0.0.1:9090"})
+	require.Error(t, err)
+	assert.Contains(t, err.(*errors.EnhancedError).Details, "reserved")
+
+	qp.SetContextLabelKeys(nil)
+	assert.NoError(t, process(map[string]string{"job": "billing"}))
+}
+
+// TestQueryTemperature tests that the requested temperature is forwarded to
+// the LLM client, clamped to the configured range
+func TestQueryTemperature(t *testing.T) {
+	llmClient := &temperatureRecordingLLMClient{
+		MockLLMClient: MockLLMClient{
+			response: &llm.Response{PromQL: `rate(http_requests_total[5m])`, Confidence: 0.9},
+		},
+	}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+	qp.SetTemperature(0.2, 0, 0.8)
+
+	temperature := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name     string
+		request  *float64
+		expected float64
+	}{
+		{name: "default", expected: 0.2},
+		{name: "requested", request: temperature(0), expected: 0},
+		{name: "above range", request: temperature(1.5), expected: 0.8},
+		{name: "below range", request: temperature(-1), expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llmClient.temperatures = nil
+			response, err := qp.ProcessQuery(context.Background(), &QueryRequest{
+				Query:       "request rate " + tt.name,
+				Temperature: tt.request,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, []float64{tt.expected}, llmClient.temperatures)
+			assert.Equal(t, tt.expected, response.Metadata["temperature"])
+		})
+	}
+
+	// Temperature overrides are cached separately from the default results
+	llmClient.temperatures = nil
+	_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate default", Temperature: temperature(0.5)})
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.5}, llmClient.temperatures)
+}
+
+// TestEmbeddingRateLimitDegradesQuery tests that a rate limited embedding
+// skips the similar query examples instead of failing the query, while a rate
+// limited generation still fails it
+func TestEmbeddingRateLimitDegradesQuery(t *testing.T) {
+	llmClient := &rateLimitedEmbeddingLLMClient{
+		MockLLMClient: MockLLMClient{
+			response: &llm.Response{PromQL: `rate(http_requests_total[5m])`, Confidence: 0.9},
+		},
+	}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+
+	response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate"})
+	require.NoError(t, err)
+	assert.Equal(t, `rate(http_requests_total[5m])`, response.PromQL)
+	assert.Equal(t, "embedding_rate_limited", response.Metadata["degraded"])
+	assert.Equal(t, 0, response.Metadata["similar_queries"])
+
+	// Degraded results are not cached
+	response, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate"})
+	require.NoError(t, err)
+	assert.False(t, response.CacheHit)
+	assert.Equal(t, 2, llmClient.embeddings)
+
+	llmClient.err = fmt.Errorf("%w: generation requests", llm.ErrRateLimited)
+	_, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "error rate"})
+	require.Error(t, err)
+	assert.Equal(t, errors.ErrCodeQueryGeneration, err.(*errors.EnhancedError).Code)
+}
+
+// TestCacheHitRatioGauge tests that the cache hit ratio gauge tracks cached and uncached queries
+func TestCacheHitRatioGauge(t *testing.T) {
+	metrics := observability.GetGlobalMetrics()
+	metrics.Reset()
+	defer metrics.Reset()
+
+	llmClient := &MockLLMClient{
+		response: &llm.Response{PromQL: `rate(http_requests_total[5m])`, Confidence: 0.9},
+	}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+
+	ratio := func() float64 {
+		metric, ok := metrics.Get(observability.MetricQueryCacheHitRatio, nil)
+		require.True(t, ok, "cache hit ratio gauge should be set")
+		assert.Equal(t, observability.MetricTypeGauge, metric.Type)
+		return metric.Value
+	}
+
+	queries := []struct {
+		query    string
+		cacheHit bool
+		ratio    float64
+	}{
+		{query: "request rate for api", cacheHit: false, ratio: 0},
+		{query: "request rate for api", cacheHit: true, ratio: 0.5},
+		{query: "request rate for web", cacheHit: false, ratio: 1.0 / 3},
+		{query: "request rate for web", cacheHit: true, ratio: 0.5},
+		{query: "request rate for api", cacheHit: true, ratio: 0.6},
+	}
+
+	for _, q := range queries {
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: q.query})
+		require.NoError(t, err)
+		require.Equal(t, q.cacheHit, response.CacheHit, q.query)
+		assert.InDelta(t, q.ratio, ratio(), 1e-9)
+	}
+}
+
+// TestProcessQueryErrorDependency tests that each failure path attributes the failing dependency
+func TestProcessQueryErrorDependency(t *testing.T) {
+	services := []semantic.Service{
+		{ID: "svc-1", Name: "api", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+	}
+	valid := &llm.Response{PromQL: `sum(rate(http_requests_total[5m]))`, Confidence: 0.9}
+
+	tests := []struct {
+		name               string
+		llmClient          llm.Client
+		mapper             semantic.Mapper
+		cacheDown          bool
+		confirmThreshold   int
+		req                *QueryRequest
+		expectedDependency string
+	}{
+		{
+			name:               "embedding failure",
+			llmClient:          &failingEmbeddingLLMClient{MockLLMClient: MockLLMClient{response: valid}},
+			mapper:             &MockSemanticMapper{services: services},
+			expectedDependency: errors.DependencyLLM,
+		},
+		{
+			name:               "query generation failure",
+			llmClient:          &MockLLMClient{err: fmt.Errorf("llm unavailable")},
+			mapper:             &MockSemanticMapper{services: services},
+			expectedDependency: errors.DependencyLLM,
+		},
+		{
+			name:               "no suitable metrics",
+			llmClient:          &MockLLMClient{response: &llm.Response{PromQL: "ERROR: No suitable metrics found."}},
+			mapper:             &MockSemanticMapper{services: services},
+			expectedDependency: errors.DependencyLLM,
+		},
+		{
+			name:               "catalog lookup failure",
+			llmClient:          &MockLLMClient{response: valid},
+			mapper:             &failingServicesMapper{err: fmt.Errorf("connection refused")},
+			expectedDependency: errors.DependencyDatabase,
+		},
+		{
+			name:               "confirmation store failure",
+			llmClient:          &MockLLMClient{response: valid},
+			mapper:             &MockSemanticMapper{services: services},
+			cacheDown:          true,
+			confirmThreshold:   1,
+			expectedDependency: errors.DependencyCache,
+		},
+		{
+			name:               "confirmation lookup failure",
+			llmClient:          &MockLLMClient{response: valid},
+			mapper:             &MockSemanticMapper{services: services},
+			cacheDown:          true,
+			req:                &QueryRequest{Query: "request rate", ConfirmationToken: "token"},
+			expectedDependency: errors.DependencyCache,
+		},
+		{
+			name:      "safety violation has no dependency",
+			llmClient: &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(api_secret_total[5m]))`}},
+			mapper:    &MockSemanticMapper{services: services},
+		},
+		{
+			name:      "invalid input has no dependency",
+			llmClient: &MockLLMClient{response: valid},
+			mapper:    &MockSemanticMapper{services: services},
+			req:       &QueryRequest{Query: "request rate", Model: "not-allowed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			cache := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+			if tt.cacheDown {
+				mr.Close()
+			}
+			qp := NewQueryProcessor(tt.llmClient, tt.mapper, cache)
+			qp.SetConfirmCostThreshold(tt.confirmThreshold)
+
+			req := tt.req
+			if req == nil {
+				req = &QueryRequest{Query: "request rate"}
+			}
+			_, err := qp.ProcessQuery(context.Background(), req)
+			require.Error(t, err)
+			assert.Equal(t, tt.expectedDependency, errors.Dependency(err))
+
+			if tt.expectedDependency != "" {
+				response := formatErrorResponse(err)["error"].(gin.H)
+				assert.Equal(t, tt.expectedDependency, response["metadata"].(map[string]interface{})["dependency"])
+			}
+		})
+	}
+}
+
+// TestGetNamespacesHandler tests that the distinct catalog namespaces are listed
+func TestGetNamespacesHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mapper := &MockSemanticMapper{
+		services: []semantic.Service{
+			{ID: "svc-1", Name: "api", Namespace: "production"},
+			{ID: "svc-2", Name: "worker", Namespace: "production"},
+			{ID: "svc-3", Name: "api", Namespace: "staging"},
+			{ID: "svc-4", Name: "exporter", Namespace: "monitoring"},
+		},
+	}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(&MockLLMClient{}, mapper, cache)
+	router := qp.SetupRoutes(nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var namespaces []string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &namespaces))
+	assert.Equal(t, []string{"monitoring", "production", "staging"}, namespaces)
+}
+
+// TestGetStatsHandler tests that catalog statistics are served from the mapper's aggregates
+func TestGetStatsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mapper := &MockSemanticMapper{
+		services: []semantic.Service{
+			{ID: "svc-1", Name: "api", Namespace: "production", MetricNames: []string{"http_requests_total", "up"}},
+			{ID: "svc-2", Name: "worker", Namespace: "production", MetricNames: []string{"up"}},
+			{ID: "svc-3", Name: "api", Namespace: "staging"},
+		},
+	}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	router := NewQueryProcessor(&MockLLMClient{}, mapper, cache).SetupRoutes(nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var stats semantic.CatalogStats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, 3, stats.TotalServices)
+	assert.Equal(t, 2, stats.TotalMetrics)
+	assert.Equal(t, map[string]int{"production": 2, "staging": 1}, stats.ServicesByNamespace)
+}
+
+// TestTrustedProxies tests that forwarding headers identify the client only when sent by a trusted proxy
+func TestTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   string
+		expectedIP     string
+	}{
+		{
+			name:         "no trusted proxies ignores forwarding headers",
+			remoteAddr:   "10.1.2.3:4567",
+			forwardedFor: "203.0.113.7",
+			expectedIP:   "10.1.2.3",
+		},
+		{
+			name:           "trusted proxy reports the client",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.1.2.3:4567",
+			forwardedFor:   "203.0.113.7",
+			expectedIP:     "203.0.113.7",
+		},
+		{
+			name:           "trusted proxy chain is skipped",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.1.2.3:4567",
+			forwardedFor:   "203.0.113.7, 10.0.0.5",
+			expectedIP:     "203.0.113.7",
+		},
+		{
+			name:           "untrusted peer cannot spoof its address",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "192.0.2.10:4567",
+			forwardedFor:   "203.0.113.7",
+			expectedIP:     "192.0.2.10",
+		},
+		{
+			name:           "single trusted IP",
+			trustedProxies: []string{"192.0.2.10"},
+			remoteAddr:     "192.0.2.10:4567",
+			forwardedFor:   "203.0.113.7",
+			expectedIP:     "203.0.113.7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+			qp := NewQueryProcessor(&MockLLMClient{}, &MockSemanticMapper{}, cache)
+			qp.SetTrustedProxies(tt.trustedProxies)
+			router := qp.SetupRoutes(nil)
+			router.GET("/client-ip", func(c *gin.Context) {
+				c.String(http.StatusOK, c.ClientIP())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/client-ip", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.expectedIP, w.Body.String())
+		})
+	}
+}
+
+// TestGetServiceDefaultNamespace tests that service lookups without a namespace use the configured default
+func TestGetServiceDefaultNamespace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mapper := &MockSemanticMapper{
+		services: []semantic.Service{
+			{ID: "svc-1", Name: "api", Namespace: "default"},
+			{ID: "svc-2", Name: "api", Namespace: "observability"},
+		},
+	}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(&MockLLMClient{}, mapper, cache)
+	qp.SetDefaultNamespace("observability")
+	router := qp.SetupRoutes(nil)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/api/v1/services/api")
+	require.Equal(t, http.StatusOK, w.Code)
+	var service semantic.Service
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &service))
+	assert.Equal(t, "svc-2", service.ID)
+
+	w = get("/api/v1/services/api?namespace=default")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &service))
+	assert.Equal(t, "svc-1", service.ID)
+
+	w = get("/api/v1/services/api?namespace=staging")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestMockSemanticMapperLifecycle tests that the mock satisfies the full Mapper interface
+func TestMockSemanticMapperLifecycle(t *testing.T) {
+	var mapper semantic.Mapper = &MockSemanticMapper{}
+	assert.NoError(t, mapper.Ping(context.Background()))
+	assert.NoError(t, mapper.Close())
+}
+
 // Mock implementations
 
 type MockSemanticMapper struct {
-	services []semantic.Service
+	services      []semantic.Service
+	storedQueries []semantic.StoredQuery
+	executions    []semantic.QueryExecution    // Most recently executed first
+	metrics       map[string][]semantic.Metric // Catalog metrics by service ID
+	embeddings    map[embeddingKey][]float32   // Embedding cache by model and normalized query
+	descriptions  map[string]string            // Metric descriptions by name
 }
 
 func (m *MockSemanticMapper) GetServices(ctx context.Context) ([]semantic.Service, error) {
@@ -653,25 +1558,191 @@ func (m *MockSemanticMapper) SearchServices(ctx context.Context, searchTerm stri
 	return m.services, nil
 }
 
+func (m *MockSemanticMapper) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *MockSemanticMapper) Close() error {
+	return nil
+}
+
+func (m *MockSemanticMapper) GetNamespaces(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	namespaces := []string{}
+	for _, svc := range m.services {
+		if !seen[svc.Namespace] {
+			seen[svc.Namespace] = true
+			namespaces = append(namespaces, svc.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+func (m *MockSemanticMapper) GetCatalogStats(ctx context.Context) (*semantic.CatalogStats, error) {
+	stats := &semantic.CatalogStats{
+		TotalServices:       len(m.services),
+		MetricsByType:       make(map[string]int),
+		ServicesByNamespace: make(map[string]int),
+	}
+	names := make(map[string]bool)
+	for _, svc := range m.services {
+		stats.ServicesByNamespace[svc.Namespace]++
+		for _, name := range svc.MetricNames {
+			names[name] = true
+		}
+	}
+	stats.TotalMetrics = len(names)
+	return stats, nil
+}
+
 func (m *MockSemanticMapper) GetMetrics(ctx context.Context, serviceID string) ([]semantic.Metric, error) {
+	if metrics, ok := m.metrics[serviceID]; ok {
+		return metrics, nil
+	}
 	return []semantic.Metric{}, nil
 }
 
+func (m *MockSemanticMapper) GetMetricsByName(ctx context.Context, name string) ([]semantic.Metric, error) {
+	var found []semantic.Metric
+	for _, metrics := range m.metrics {
+		for _, metric := range metrics {
+			if metric.Name == name {
+				found = append(found, metric)
+			}
+		}
+	}
+	return found, nil
+}
+
 func (m *MockSemanticMapper) CreateMetric(ctx context.Context, name, metricType, description, serviceID string, labels map[string]string) (*semantic.Metric, error) {
 	return nil, nil
 }
 
+func (m *MockSemanticMapper) UpdateMetricDescriptions(ctx context.Context, descriptions map[string]string) error {
+	m.descriptions = descriptions
+	return nil
+}
+
+func (m *MockSemanticMapper) GetMetricDescriptions(ctx context.Context, names []string) (map[string]string, error) {
+	descriptions := make(map[string]string)
+	for _, name := range names {
+		if description, ok := m.descriptions[name]; ok {
+			descriptions[name] = description
+		}
+	}
+	return descriptions, nil
+}
+
 func (m *MockSemanticMapper) FindSimilarQueries(ctx context.Context, embedding []float32) ([]semantic.SimilarQuery, error) {
 	return []semantic.SimilarQuery{}, nil
 }
 
+func (m *MockSemanticMapper) FindSimilarQueriesBatch(ctx context.Context, embeddings [][]float32) ([][]semantic.SimilarQuery, error) {
+	results := make([][]semantic.SimilarQuery, len(embeddings))
+	for i, embedding := range embeddings {
+		similar, err := m.FindSimilarQueries(ctx, embedding)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = similar
+	}
+	return results, nil
+}
+
 func (m *MockSemanticMapper) StoreQueryEmbedding(ctx context.Context, query string, embedding []float32, promql string) error {
 	return nil
 }
 
+func (m *MockSemanticMapper) ListStoredQueries(ctx context.Context, afterID string, limit int) ([]semantic.StoredQuery, error) {
+	var result []semantic.StoredQuery
+	for _, sq := range m.storedQueries {
+		if sq.ID > afterID && len(result) < limit {
+			result = append(result, sq)
+		}
+	}
+	return result, nil
+}
+
+// GetRecentQueries lists the stored queries newest first, taking them to be
+// stored in order and starting after the query with the cursor's ID
+func (m *MockSemanticMapper) GetRecentQueries(ctx context.Context, limit int, after *semantic.QueryCursor) ([]semantic.StoredQuery, error) {
+	start := len(m.storedQueries) - 1
+	if after != nil {
+		for i, sq := range m.storedQueries {
+			if sq.ID == after.ID {
+				start = i - 1
+			}
+		}
+	}
+	result := []semantic.StoredQuery{}
+	for i := start; i >= 0 && len(result) < limit; i-- {
+		result = append(result, m.storedQueries[i])
+	}
+	return result, nil
+}
+
+func (m *MockSemanticMapper) UpdateQueryEmbedding(ctx context.Context, id string, embedding []float32) error {
+	for i := range m.storedQueries {
+		if m.storedQueries[i].ID == id {
+			m.storedQueries[i].Dimensions = len(embedding)
+			return nil
+		}
+	}
+	return fmt.Errorf("stored query not found: %s", id)
+}
+
+func (m *MockSemanticMapper) RecordQueryExecution(ctx context.Context, query, promql string) error {
+	return nil
+}
+
+func (m *MockSemanticMapper) GetQueryExecutions(ctx context.Context, limit int) ([]semantic.QueryExecution, error) {
+	if len(m.executions) > limit {
+		return m.executions[:limit], nil
+	}
+	return m.executions, nil
+}
+
+func (m *MockSemanticMapper) StoreEvaluationSample(ctx context.Context, sample semantic.EvaluationSample) error {
+	return nil
+}
+
+// embeddingKey identifies a cached embedding of the mock mapper
+type embeddingKey struct {
+	model, query string
+}
+
+func (m *MockSemanticMapper) GetOrStoreEmbedding(ctx context.Context, model, normalizedQuery string, embed semantic.EmbedFunc) ([]float32, bool, error) {
+	key := embeddingKey{model, normalizedQuery}
+	if embedding, ok := m.embeddings[key]; ok {
+		return embedding, true, nil
+	}
+	embedding, err := embed(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if m.embeddings == nil {
+		m.embeddings = make(map[embeddingKey][]float32)
+	}
+	m.embeddings[key] = embedding
+	return embedding, false, nil
+}
+
+func (m *MockSemanticMapper) PurgeEmbeddingCache(ctx context.Context, model string, dimension int) (int, error) {
+	purged := 0
+	for key, embedding := range m.embeddings {
+		if dimension == 0 || key.model != model || len(embedding) != dimension {
+			delete(m.embeddings, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
 type MockLLMClient struct {
-	response *llm.Response
-	err      error
+	response     *llm.Response
+	err          error
+	embeddingDim int
 }
 
 func (m *MockLLMClient) GenerateQuery(ctx context.Context, prompt string) (*llm.Response, error) {
@@ -683,9 +1754,98 @@ func (m *MockLLMClient) GenerateQuery(ctx context.Context, prompt string) (*llm.
 
 func (m *MockLLMClient) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
 	// Return a simple embedding
+	if m.embeddingDim > 0 {
+		return make([]float32, m.embeddingDim), nil
+	}
 	return make([]float32, 1536), nil
 }
 
+// failingEmbeddingLLMClient fails every embedding request
+type failingEmbeddingLLMClient struct {
+	MockLLMClient
+}
+
+func (m *failingEmbeddingLLMClient) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return nil, fmt.Errorf("embedding service unavailable")
+}
+
+// rateLimitedEmbeddingLLMClient has every embedding request rate limited
+type rateLimitedEmbeddingLLMClient struct {
+	MockLLMClient
+	embeddings int
+}
+
+func (m *rateLimitedEmbeddingLLMClient) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	m.embeddings++
+	return nil, fmt.Errorf("%w: embedding requests", llm.ErrRateLimited)
+}
+
+// failingServicesMapper fails to list services
+type failingServicesMapper struct {
+	MockSemanticMapper
+	err error
+}
+
+func (m *failingServicesMapper) GetServices(ctx context.Context) ([]semantic.Service, error) {
+	return nil, m.err
+}
+
+// modelRecordingLLMClient records the model requested for each generation
+type modelRecordingLLMClient struct {
+	MockLLMClient
+	models []string
+}
+
+func (m *modelRecordingLLMClient) GenerateQuery(ctx context.Context, prompt string) (*llm.Response, error) {
+	m.models = append(m.models, "")
+	return m.MockLLMClient.GenerateQuery(ctx, prompt)
+}
+
+func (m *modelRecordingLLMClient) GenerateQueryWithOptions(ctx context.Context, prompt string, opts llm.GenerateOptions) (*llm.Response, error) {
+	m.models = append(m.models, opts.Model)
+	return m.MockLLMClient.GenerateQuery(ctx, prompt)
+}
+
+// temperatureRecordingLLMClient records the temperature requested for each generation
+type temperatureRecordingLLMClient struct {
+	MockLLMClient
+	temperatures []float64
+}
+
+func (m *temperatureRecordingLLMClient) GenerateQueryWithOptions(ctx context.Context, prompt string, opts llm.GenerateOptions) (*llm.Response, error) {
+	m.temperatures = append(m.temperatures, *opts.Temperature)
+	return m.MockLLMClient.GenerateQuery(ctx, prompt)
+}
+
+// promptRecordingLLMClient records generation prompts and counts embedding requests
+type promptRecordingLLMClient struct {
+	MockLLMClient
+	prompts    []string
+	embeddings int
+}
+
+func (m *promptRecordingLLMClient) GenerateQuery(ctx context.Context, prompt string) (*llm.Response, error) {
+	m.prompts = append(m.prompts, prompt)
+	return m.MockLLMClient.GenerateQuery(ctx, prompt)
+}
+
+func (m *promptRecordingLLMClient) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	m.embeddings++
+	return m.MockLLMClient.GetEmbedding(ctx, text)
+}
+
+// similarQueriesMapper returns fixed similar queries and counts searches
+type similarQueriesMapper struct {
+	MockSemanticMapper
+	similar  []semantic.SimilarQuery
+	searches int
+}
+
+func (m *similarQueriesMapper) FindSimilarQueries(ctx context.Context, embedding []float32) ([]semantic.SimilarQuery, error) {
+	m.searches++
+	return m.similar, nil
+}
+
 // Helper functions
 
 func generateManyMetrics(count int) []string {
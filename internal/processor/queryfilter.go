@@ -0,0 +1,59 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+)
+
+// queryFilter blocks natural language queries by regular expression before
+// any processing, so unwanted queries cost no cache, embedding or LLM calls
+type queryFilter struct {
+	deny  []*regexp.Regexp
+	allow []*regexp.Regexp // nil permits any query not denied
+}
+
+// SetQueryFilter sets regular expressions matched, case-insensitively and
+// anywhere in the text, against natural language queries. Queries matching
+// a deny pattern are rejected; when allow patterns are set, queries must also
+// match one of them. Invalid patterns are an error and leave the filter
+// unchanged.
+func (qp *QueryProcessor) SetQueryFilter(deny, allow []string) error {
+	var filter queryFilter
+	for _, pattern := range deny {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return fmt.Errorf("invalid deny pattern %q: %w", pattern, err)
+		}
+		filter.deny = append(filter.deny, re)
+	}
+	for _, pattern := range allow {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return fmt.Errorf("invalid allow pattern %q: %w", pattern, err)
+		}
+		filter.allow = append(filter.allow, re)
+	}
+	qp.queryFilter = filter
+	return nil
+}
+
+// check returns an invalid input error if the query is denied or, with an
+// allow list, not allowed. The patterns are not revealed to the caller.
+func (f queryFilter) check(query string) error {
+	for _, re := range f.deny {
+		if re.MatchString(query) {
+			return errors.NewQueryNotAllowedError("The query contains content that is blocked by this server's query policy")
+		}
+	}
+	if f.allow == nil {
+		return nil
+	}
+	for _, re := range f.allow {
+		if re.MatchString(query) {
+			return nil
+		}
+	}
+	return errors.NewQueryNotAllowedError("The query is not among the kinds of queries this server accepts")
+}
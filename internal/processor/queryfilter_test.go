@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestQueryFilter tests that denied queries, and queries outside a set allow
+// list, are rejected before reaching the LLM, while other queries pass
+func TestQueryFilter(t *testing.T) {
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_requests_total{service="api"}[5m]))`, Confidence: 0.9}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+	require.NoError(t, qp.SetQueryFilter([]string{`password`, `\bsecrets?\b`}, nil))
+
+	tests := []struct {
+		name    string
+		query   string
+		allowed bool
+	}{
+		{"denied", "show the PASSWORD reset rate", false},
+		{"denied by word", "list secrets in the api", false},
+		{"neutral", "request rate of api", true},
+		{"partial word is not denied", "secretary service latency", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: tt.query})
+			if tt.allowed {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			enhanced, ok := err.(*errors.EnhancedError)
+			require.True(t, ok)
+			assert.Equal(t, errors.ErrCodeInvalidInput, enhanced.Code)
+			assert.Equal(t, "Query is not allowed", enhanced.Message)
+			assert.NotContains(t, enhanced.Details, "password", "patterns are not revealed")
+		})
+	}
+
+	// With an allow list, only matching queries are accepted, and deny
+	// patterns still apply
+	require.NoError(t, qp.SetQueryFilter([]string{`password`}, []string{`latency`, `errors?`}))
+	_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "api latency"})
+	assert.NoError(t, err)
+	_, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "api throughput"})
+	assert.Error(t, err)
+	_, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "password errors"})
+	assert.Error(t, err)
+
+	assert.Error(t, qp.SetQueryFilter([]string{`secret(s`}, nil))
+	_, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "password errors"})
+	assert.Error(t, err, "an invalid pattern leaves the filter unchanged")
+}
+
+// TestQueryFilterAlert tests that alert conditions are filtered like queries,
+// on the alert endpoint as well as through ProcessAlert
+func TestQueryFilterAlert(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(auth_password_resets_total[5m]))`, Confidence: 0.9}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+	require.NoError(t, qp.SetQueryFilter([]string{`password`}, nil))
+
+	_, err := qp.ProcessAlert(context.Background(), &AlertRequest{Query: "alert when password resets exceed 10 per second"})
+	require.Error(t, err)
+	enhanced, ok := err.(*errors.EnhancedError)
+	require.True(t, ok)
+	assert.Equal(t, "Query is not allowed", enhanced.Message)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/alert", strings.NewReader(`{"query": "alert when password resets exceed 10 per second"}`))
+	req.Header.Set("Content-Type", "application/json")
+	qp.SetupRoutes(nil).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Query is not allowed")
+}
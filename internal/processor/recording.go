@@ -0,0 +1,320 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// defaultRecordingRuleMinCount is how often a query must have been
+	// executed before a recording rule is suggested for it
+	defaultRecordingRuleMinCount = 5
+	// recordingRuleHistoryLimit bounds how many of the most recently executed
+	// queries are analyzed
+	recordingRuleHistoryLimit = 5000
+	recordingRuleGroupName    = "observability-ai-recording"
+
+	// executionQueueSize is how many executions may wait to be counted before
+	// new ones are dropped
+	executionQueueSize = 100
+	// executionStoreTimeout bounds each execution count write
+	executionStoreTimeout = 5 * time.Second
+)
+
+// rangeFunctions are the functions over a range vector whose range belongs in
+// a recording rule name, as in "rate5m"
+var rangeFunctions = map[string]bool{
+	"rate": true, "irate": true, "increase": true, "delta": true, "idelta": true,
+	"deriv": true, "changes": true, "resets": true, "predict_linear": true,
+}
+
+// RecordingRule is a Prometheus recording rule
+type RecordingRule struct {
+	Record string `json:"record" yaml:"record"`
+	Expr   string `json:"expr" yaml:"expr"`
+}
+
+// RecordingRuleSuggestion is a recording rule for a frequently executed query
+type RecordingRuleSuggestion struct {
+	Rule    RecordingRule `json:"rule"`
+	Count   int           `json:"count"`   // Times the expression was executed
+	Queries []string      `json:"queries"` // Natural language queries executing the expression
+}
+
+// RecordingRulesResponse holds the recording rules suggested from query executions
+type RecordingRulesResponse struct {
+	Suggestions []RecordingRuleSuggestion `json:"suggestions"`
+	YAML        string                    `json:"yaml,omitempty"` // The rules as a Prometheus rule file
+	MinCount    int                       `json:"min_count"`
+	Analyzed    int                       `json:"analyzed"` // Executed queries analyzed
+}
+
+// recordingRuleFile is the Prometheus rule file layout
+type recordingRuleFile struct {
+	Groups []recordingRuleGroup `yaml:"groups"`
+}
+
+type recordingRuleGroup struct {
+	Name  string          `yaml:"name"`
+	Rules []RecordingRule `yaml:"rules"`
+}
+
+// recordingCandidate is a PromQL expression and how often it was executed
+type recordingCandidate struct {
+	expr    string
+	count   int
+	queries []string
+}
+
+// countExecution queues an execution of a query to be counted towards
+// recording rule suggestions, dropping it if the queue is full. Queries are
+// redacted like evaluation samples before they are stored.
+func (qp *QueryProcessor) countExecution(query, promql string) {
+	if strings.TrimSpace(promql) == "" {
+		return
+	}
+	select {
+	case qp.executions <- semantic.QueryExecution{Query: redactPII(query), PromQL: promql}:
+	default:
+		qp.logger.Warn(context.Background(), "Query execution queue full, dropping execution count", nil)
+	}
+}
+
+// recordExecutions counts queued executions until the queue is closed
+func (qp *QueryProcessor) recordExecutions() {
+	for execution := range qp.executions {
+		ctx, cancel := context.WithTimeout(context.Background(), executionStoreTimeout)
+		err := qp.semanticMapper.RecordQueryExecution(ctx, execution.Query, execution.PromQL)
+		cancel()
+		if err != nil {
+			qp.logger.Warn(context.Background(), "Failed to record query execution", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+// SuggestRecordingRules analyzes the executed queries and suggests a
+// recording rule for each expression executed at least minCount times,
+// counting responses served from the cache. Expressions are compared in
+// canonical form, so the same query phrased differently counts towards one
+// rule. Plain selectors are not worth recording and are skipped.
+func (qp *QueryProcessor) SuggestRecordingRules(ctx context.Context, minCount int) (*RecordingRulesResponse, error) {
+	executions, err := qp.semanticMapper.GetQueryExecutions(ctx, recordingRuleHistoryLimit)
+	if err != nil {
+		return nil, errors.NewDatabaseQueryError(err, "fetching query executions")
+	}
+
+	candidates := make(map[string]*recordingCandidate)
+	for _, execution := range executions {
+		expr := canonicalizePromQL(strings.TrimSpace(execution.PromQL))
+		if expr == "" {
+			continue
+		}
+		candidate, ok := candidates[expr]
+		if !ok {
+			candidate = &recordingCandidate{expr: expr}
+			candidates[expr] = candidate
+		}
+		candidate.count += execution.Count
+		if !containsString(candidate.queries, execution.Query) {
+			candidate.queries = append(candidate.queries, execution.Query)
+		}
+	}
+
+	frequent := make([]*recordingCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.count >= minCount {
+			frequent = append(frequent, candidate)
+		}
+	}
+	sort.Slice(frequent, func(i, j int) bool {
+		if frequent[i].count != frequent[j].count {
+			return frequent[i].count > frequent[j].count
+		}
+		return frequent[i].expr < frequent[j].expr
+	})
+
+	response := &RecordingRulesResponse{
+		Suggestions: []RecordingRuleSuggestion{},
+		MinCount:    minCount,
+		Analyzed:    len(executions),
+	}
+	names := make(map[string]int)
+	var rules []RecordingRule
+	for _, candidate := range frequent {
+		name := recordingRuleName(candidate.expr)
+		if name == "" {
+			continue
+		}
+		// Different expressions may derive the same name
+		names[name]++
+		if n := names[name]; n > 1 {
+			name = fmt.Sprintf("%s_%d", name, n)
+		}
+
+		rule := RecordingRule{Record: name, Expr: candidate.expr}
+		rules = append(rules, rule)
+		response.Suggestions = append(response.Suggestions, RecordingRuleSuggestion{
+			Rule:    rule,
+			Count:   candidate.count,
+			Queries: candidate.queries,
+		})
+	}
+
+	if len(rules) > 0 {
+		ruleYAML, err := yaml.Marshal(recordingRuleFile{
+			Groups: []recordingRuleGroup{{Name: recordingRuleGroupName, Rules: rules}},
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrCodeQueryGeneration, "Failed to render recording rules")
+		}
+		response.YAML = string(ruleYAML)
+	}
+
+	return response, nil
+}
+
+// recordingRuleName derives a name following the level:metric:operations
+// convention, e.g. "service:http_requests:rate5m" for
+// sum by (service) (rate(http_requests_total[5m])). The level is the
+// outermost by clause and is left out when there is none. Expressions
+// without a function or aggregation return "".
+func recordingRuleName(promql string) string {
+	tokens, err := tokenizePromQL(promql)
+	if err != nil {
+		return ""
+	}
+
+	var level []string
+	var operations []string
+	aggregated, ratio := false, false
+	for i, tok := range tokens {
+		if tok.kind == tokenOperator && tok.text == "/" {
+			ratio = true
+			continue
+		}
+		if tok.kind != tokenWord || i+1 >= len(tokens) {
+			continue
+		}
+		next := tokens[i+1].text
+
+		switch {
+		case tok.text == "by" && next == "(" && level == nil:
+			for j := i + 2; j < len(tokens) && tokens[j].text != ")"; j++ {
+				if tokens[j].kind == tokenWord && tokens[j].text != "le" {
+					level = append(level, tokens[j].text)
+				}
+			}
+			if level == nil {
+				level = []string{}
+			}
+
+		case promqlAggregations[tok.text] && (next == "(" || next == "by" || next == "without"):
+			aggregated = true
+			if tok.text != "sum" {
+				// Summing is implied by the level
+				operations = append(operations, tok.text)
+			}
+
+		case next != "(" || promqlKeywords[tok.text] || !isMetricNameStart(tok.text[0]):
+			continue
+
+		case tok.text == "histogram_quantile":
+			// histogram_quantile(0.95, ...) is the p95
+			operation := "quantile"
+			if i+2 < len(tokens) {
+				if q, err := strconv.ParseFloat(tokens[i+2].text, 64); err == nil {
+					operation = "p" + strings.ReplaceAll(formatThreshold(q*100), ".", "")
+				}
+			}
+			operations = append(operations, operation)
+
+		case rangeFunctions[tok.text] || strings.HasSuffix(tok.text, "_over_time"):
+			operation := tok.text
+			if open := findToken(tokens, i, "["); open >= 0 && open+1 < len(tokens) {
+				operation += tokens[open+1].text
+			}
+			operations = append(operations, operation)
+
+		default:
+			operations = append(operations, tok.text)
+		}
+	}
+	if len(operations) == 0 && !aggregated {
+		return ""
+	}
+	if len(operations) == 0 {
+		operations = []string{"sum"}
+	}
+
+	metrics := selectorMetrics(promql)
+	if len(metrics) == 0 {
+		return ""
+	}
+	for i, metric := range metrics {
+		metric = strings.TrimSuffix(metric, "_bucket")
+		metric = strings.TrimSuffix(metric, "_total")
+		metrics[i] = strings.ReplaceAll(metric, ":", "_")
+	}
+	metric := metrics[0]
+	if ratio && len(metrics) > 1 {
+		metric = metrics[0] + "_per_" + metrics[1]
+		operations = append(operations, "ratio")
+	}
+
+	// Operations read innermost first, and both sides of a ratio usually
+	// apply the same ones
+	var ordered []string
+	for i := len(operations) - 1; i >= 0; i-- {
+		if !containsString(ordered, operations[i]) {
+			ordered = append(ordered, operations[i])
+		}
+	}
+	name := metric + ":" + strings.Join(ordered, "_")
+	if len(level) > 0 {
+		name = strings.Join(level, "_") + ":" + name
+	}
+	return name
+}
+
+// findToken returns the index of the first token after start with the given
+// text, or -1
+func findToken(tokens []promqlToken, start int, text string) int {
+	for i := start + 1; i < len(tokens); i++ {
+		if tokens[i].text == text {
+			return i
+		}
+	}
+	return -1
+}
+
+// handleGetRecordingRules handles GET /api/v1/admin/recording-rules (admin only)
+func (qp *QueryProcessor) handleGetRecordingRules(c *gin.Context) {
+	minCount, err := historyParam(c, "min_count", defaultRecordingRuleMinCount)
+	if err == nil && minCount <= 0 {
+		err = errors.NewInvalidInputError("min_count", "min_count must be positive")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, formatErrorResponse(err))
+		return
+	}
+
+	response, err := qp.SuggestRecordingRules(c.Request.Context(), minCount)
+	if err != nil {
+		c.JSON(getErrorStatusCode(err), formatErrorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,152 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// TestSuggestRecordingRules tests that recording rules are suggested only for
+// expressions generated at least the minimum number of times
+func TestSuggestRecordingRules(t *testing.T) {
+	mapper := &MockSemanticMapper{executions: []semantic.QueryExecution{
+		{Query: "error rate by service", PromQL: `sum by (service) (rate(http_requests_total{status=~"5.."}[5m]))`, Count: 3},
+		// Equivalent to the first once canonicalized
+		{Query: "5xx rate per service", PromQL: `sum by(service)(rate(http_requests_total{status=~"5.."}[5m]))`, Count: 2},
+		{Query: "p95 latency", PromQL: `histogram_quantile(0.95, sum by (le, service) (rate(http_request_duration_seconds_bucket[5m])))`, Count: 6},
+		{Query: "memory usage", PromQL: `avg by (instance) (process_resident_memory_bytes)`, Count: 4},
+		// Plain selectors are not worth recording however often they are used
+		{Query: "is the api up", PromQL: `up{job="api"}`, Count: 10},
+	}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(&MockLLMClient{}, mapper, cache)
+
+	response, err := qp.SuggestRecordingRules(context.Background(), 5)
+	require.NoError(t, err)
+	assert.Equal(t, 5, response.Analyzed)
+	require.Len(t, response.Suggestions, 2)
+
+	assert.Equal(t, "service:http_request_duration_seconds:rate5m_p95", response.Suggestions[0].Rule.Record)
+	assert.Equal(t, 6, response.Suggestions[0].Count)
+
+	assert.Equal(t, "service:http_requests:rate5m", response.Suggestions[1].Rule.Record)
+	assert.Equal(t, `sum by (service)(rate(http_requests_total{status=~"5.."}[5m]))`, response.Suggestions[1].Rule.Expr)
+	assert.Equal(t, 5, response.Suggestions[1].Count)
+	assert.ElementsMatch(t, []string{"error rate by service", "5xx rate per service"}, response.Suggestions[1].Queries)
+
+	var file recordingRuleFile
+	require.NoError(t, yaml.Unmarshal([]byte(response.YAML), &file))
+	require.Len(t, file.Groups, 1)
+	assert.Equal(t, recordingRuleGroupName, file.Groups[0].Name)
+	assert.Equal(t, []RecordingRule{response.Suggestions[0].Rule, response.Suggestions[1].Rule}, file.Groups[0].Rules)
+
+	response, err = qp.SuggestRecordingRules(context.Background(), 100)
+	require.NoError(t, err)
+	assert.Empty(t, response.Suggestions)
+	assert.Empty(t, response.YAML)
+}
+
+// executionMapper counts recorded query executions
+type executionMapper struct {
+	MockSemanticMapper
+	mu     sync.Mutex
+	counts map[semantic.QueryExecution]int
+}
+
+func (m *executionMapper) RecordQueryExecution(ctx context.Context, query, promql string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[semantic.QueryExecution{Query: query, PromQL: promql}]++
+	return nil
+}
+
+func (m *executionMapper) GetQueryExecutions(ctx context.Context, limit int) ([]semantic.QueryExecution, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var executions []semantic.QueryExecution
+	for execution, count := range m.counts {
+		execution.Count = count
+		executions = append(executions, execution)
+	}
+	return executions, nil
+}
+
+// TestRecordingRulesCountExecutions tests that every query processed counts
+// towards recording rule suggestions, including cache hits
+func TestRecordingRulesCountExecutions(t *testing.T) {
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum by (service) (rate(http_requests_total[5m]))`, Confidence: 0.9}}
+	mapper := &executionMapper{counts: make(map[semantic.QueryExecution]int)}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, mapper, cache)
+
+	for i := 0; i < 2; i++ {
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate by service"})
+		require.NoError(t, err)
+		assert.Equal(t, i == 1, response.CacheHit)
+	}
+
+	assert.Eventually(t, func() bool {
+		response, err := qp.SuggestRecordingRules(context.Background(), 2)
+		return err == nil && len(response.Suggestions) == 1 && response.Suggestions[0].Count == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+// TestRecordingRuleName tests rule names following the level:metric:operations convention
+func TestRecordingRuleName(t *testing.T) {
+	tests := map[string]string{
+		`sum by (service) (rate(http_requests_total[5m]))`:                      "service:http_requests:rate5m",
+		`sum(rate(errors_total[5m])) / sum(rate(requests_total[5m]))`:           "errors_per_requests:ratio_rate5m",
+		`histogram_quantile(0.99, sum by (le) (rate(rpc_duration_bucket[1m])))`: "rpc_duration:rate1m_p99",
+		`max_over_time(queue_depth[1h])`:                                        "queue_depth:max_over_time1h",
+		`sum by (namespace) (kube_pod_info)`:                                    "namespace:kube_pod_info:sum",
+		`up{job="api"}`:                                                         "",
+	}
+	for promql, want := range tests {
+		assert.Equal(t, want, recordingRuleName(promql), promql)
+	}
+}
+
+// TestRecordingRulesHandler tests the min_count parameter of the recording rules endpoint
+func TestRecordingRulesHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mapper := &MockSemanticMapper{executions: []semantic.QueryExecution{
+		{Query: "request rate", PromQL: `sum(rate(http_requests_total[5m]))`, Count: 2},
+	}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	router := NewQueryProcessor(&MockLLMClient{}, mapper, cache).SetupRoutes(allowAllAuthorizer{})
+
+	get := func(query string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/recording-rules"+query, nil))
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return w.Code, response
+	}
+
+	code, response := get("")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(defaultRecordingRuleMinCount), response["min_count"])
+	assert.Empty(t, response["suggestions"])
+
+	code, response = get("?min_count=2")
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, response["suggestions"], 1)
+
+	for _, query := range []string{"?min_count=0", "?min_count=abc"} {
+		code, _ = get(query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
@@ -0,0 +1,112 @@
+package processor
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+)
+
+// RoleChecker is implemented by auth middleware that can report whether the
+// authenticated caller holds a role. Admin-only response metadata is shown to
+// callers holding the admin role.
+type RoleChecker interface {
+	HasRole(c *gin.Context, role string) bool
+}
+
+// defaultAdminOnlyMetadata are the response metadata fields hidden from
+// non-admin callers unless configured otherwise: backend request details,
+// build telemetry and cost internals
+var defaultAdminOnlyMetadata = []string{
+	"mimir_request",
+	"telemetry",
+	"stage_timings_ms",
+	"confirmation_threshold",
+}
+
+// redactMetadataKey is the gin context key set when the caller may not see
+// admin-only response metadata
+const redactMetadataKey = "redact_metadata"
+
+// SetAdminOnlyMetadata sets the response metadata fields hidden from non-admin
+// callers. An empty list shows all metadata to every caller.
+func (qp *QueryProcessor) SetAdminOnlyMetadata(fields []string) {
+	qp.adminOnlyMetadata = fieldSet(fields)
+}
+
+// fieldSet converts a list of metadata field names to a set
+func fieldSet(fields []string) map[string]bool {
+	set := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		set[field] = true
+	}
+	return set
+}
+
+// metadataAccessMiddleware marks requests from callers without the admin role
+// so their responses are redacted. Without a role checker no caller is
+// treated as an admin.
+func metadataAccessMiddleware(authMiddleware AuthMiddleware) gin.HandlerFunc {
+	checker, _ := authMiddleware.(RoleChecker)
+	return func(c *gin.Context) {
+		if checker == nil || !checker.HasRole(c, "admin") {
+			c.Set(redactMetadataKey, true)
+		}
+		c.Next()
+	}
+}
+
+// redactResponse returns the response to send to the caller, with admin-only
+// metadata removed if the caller is not an admin. Requests served without
+// authentication are not redacted.
+func (qp *QueryProcessor) redactResponse(c *gin.Context, response *QueryResponse) *QueryResponse {
+	if !c.GetBool(redactMetadataKey) {
+		return response
+	}
+	return redactMetadata(response, qp.adminOnlyMetadata)
+}
+
+// redactMetadata returns a copy of the response without the given metadata
+// fields. The response itself is left untouched since it may be shared with
+// the cache or other callers.
+func redactMetadata(response *QueryResponse, fields map[string]bool) *QueryResponse {
+	if response == nil || len(fields) == 0 {
+		return response
+	}
+
+	redacted := *response
+	// The intent is also returned as a top-level field
+	if fields["intent"] {
+		redacted.Intent = nil
+	}
+	if len(response.Metadata) == 0 {
+		return &redacted
+	}
+	redacted.Metadata = make(map[string]interface{}, len(response.Metadata))
+	for key, value := range response.Metadata {
+		if !fields[key] {
+			redacted.Metadata[key] = value
+		}
+	}
+	if len(redacted.Metadata) == 0 {
+		redacted.Metadata = nil
+	}
+	return &redacted
+}
+
+// redactError returns the error to send to the caller, with admin-only
+// metadata such as the telemetry of a failed query removed if the caller is
+// not an admin
+func (qp *QueryProcessor) redactError(c *gin.Context, err error) error {
+	enhancedErr, ok := err.(*errors.EnhancedError)
+	if !ok || !c.GetBool(redactMetadataKey) || len(enhancedErr.Metadata) == 0 || len(qp.adminOnlyMetadata) == 0 {
+		return err
+	}
+
+	redacted := *enhancedErr
+	redacted.Metadata = make(map[string]interface{}, len(enhancedErr.Metadata))
+	for key, value := range enhancedErr.Metadata {
+		if !qp.adminOnlyMetadata[key] {
+			redacted.Metadata[key] = value
+		}
+	}
+	return &redacted
+}
@@ -0,0 +1,158 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/auth"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRedactMetadata tests that admin-only fields are removed from a copy of the response
+func TestRedactMetadata(t *testing.T) {
+	response := &QueryResponse{
+		PromQL: "up",
+		Metadata: map[string]interface{}{
+			"intent":        "status",
+			"mimir_request": "GET /api/v1/query",
+			"telemetry":     &QueryTelemetry{},
+		},
+	}
+
+	redacted := redactMetadata(response, fieldSet(defaultAdminOnlyMetadata))
+	assert.Equal(t, map[string]interface{}{"intent": "status"}, redacted.Metadata)
+	assert.Equal(t, "up", redacted.PromQL)
+	assert.Len(t, response.Metadata, 3, "original response must not be modified")
+
+	onlyAdmin := redactMetadata(&QueryResponse{Metadata: map[string]interface{}{"telemetry": 1}}, fieldSet(defaultAdminOnlyMetadata))
+	assert.Nil(t, onlyAdmin.Metadata)
+
+	// Hiding the intent also hides the top-level field
+	withIntent := &QueryResponse{Intent: &QueryIntent{Type: "errors"}}
+	assert.Nil(t, redactMetadata(withIntent, fieldSet([]string{"intent"})).Intent)
+	assert.NotNil(t, withIntent.Intent)
+
+	assert.Same(t, response, redactMetadata(response, nil))
+	assert.Nil(t, redactMetadata(nil, fieldSet(defaultAdminOnlyMetadata)))
+}
+
+// TestRedactError tests that admin-only fields are removed from a copy of a
+// failed query's error for non-admin callers
+func TestRedactError(t *testing.T) {
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(&MockLLMClient{}, &MockSemanticMapper{}, cache)
+	err := errors.NewInvalidInputError("query", "rejected").
+		WithMetadata("telemetry", &QueryTelemetry{}).
+		WithMetadata("field", "query")
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	assert.Same(t, err, qp.redactError(c, err), "callers that are not marked keep the error")
+
+	c.Set(redactMetadataKey, true)
+	redacted, ok := qp.redactError(c, err).(*errors.EnhancedError)
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"field": "query"}, redacted.Metadata)
+	assert.Len(t, err.Metadata, 2, "original error must not be modified")
+}
+
+// TestQueryHandlerRedactsMetadata tests that admin-only metadata is returned to admins only
+func TestQueryHandlerRedactsMetadata(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	am := auth.NewTestAuthManager(auth.AuthConfig{JWTSecret: "test-secret", RateLimit: 100})
+	admin, err := am.CreateUser("admin-user", "admin@example.com", []string{"admin"})
+	require.NoError(t, err)
+	adminKey, err := am.CreateAPIKey(admin.ID, "admin-key", []string{"read"}, 100, time.Hour)
+	require.NoError(t, err)
+	user, err := am.CreateUser("regular-user", "user@example.com", []string{"user"})
+	require.NoError(t, err)
+	userKey, err := am.CreateAPIKey(user.ID, "user-key", []string{"read"}, 100, time.Hour)
+	require.NoError(t, err)
+
+	llmClient := &MockLLMClient{
+		response: &llm.Response{PromQL: `sum(rate(http_requests_total[5m]))`, Confidence: 0.9},
+	}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+	qp.SetRequestDescriber(tenantDescriber{tenant: "default"})
+	router := qp.SetupRoutes(am)
+
+	post := func(path, key string, body interface{}) (int, map[string]interface{}) {
+		payload, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return w.Code, response
+	}
+
+	t.Run("admin sees all metadata", func(t *testing.T) {
+		code, response := post("/api/v1/query?debug=true", adminKey.Key, QueryRequest{Query: "request rate"})
+		require.Equal(t, http.StatusOK, code)
+		metadata := response["metadata"].(map[string]interface{})
+		assert.Contains(t, metadata, "mimir_request")
+		assert.Contains(t, metadata, "telemetry")
+		assert.Contains(t, metadata, "stage_timings_ms")
+	})
+
+	t.Run("regular user does not see admin-only metadata", func(t *testing.T) {
+		code, response := post("/api/v1/query?debug=true", userKey.Key, QueryRequest{Query: "request rate"})
+		require.Equal(t, http.StatusOK, code)
+		metadata := response["metadata"].(map[string]interface{})
+		assert.NotContains(t, metadata, "mimir_request")
+		assert.NotContains(t, metadata, "telemetry")
+		assert.NotContains(t, metadata, "stage_timings_ms")
+	})
+
+	t.Run("batch results are redacted for regular users", func(t *testing.T) {
+		batch := BatchQueryRequest{Queries: []QueryRequest{{Query: "request rate", Debug: true}}}
+
+		code, response := post("/api/v1/query/batch", userKey.Key, batch)
+		require.Equal(t, http.StatusOK, code)
+		result := response["results"].([]interface{})[0].(map[string]interface{})
+		metadata, _ := result["response"].(map[string]interface{})["metadata"].(map[string]interface{})
+		assert.NotContains(t, metadata, "mimir_request")
+		assert.NotContains(t, metadata, "telemetry")
+
+		code, response = post("/api/v1/query/batch", adminKey.Key, batch)
+		require.Equal(t, http.StatusOK, code)
+		result = response["results"].([]interface{})[0].(map[string]interface{})
+		metadata = result["response"].(map[string]interface{})["metadata"].(map[string]interface{})
+		assert.Contains(t, metadata, "mimir_request")
+		assert.Contains(t, metadata, "telemetry")
+	})
+
+	t.Run("batch errors are redacted for regular users", func(t *testing.T) {
+		llmClient.err = fmt.Errorf("model unavailable")
+		defer func() { llmClient.err = nil }()
+		batch := BatchQueryRequest{Queries: []QueryRequest{{Query: "error rate", Debug: true}}}
+
+		errorMetadata := func(key string) map[string]interface{} {
+			code, response := post("/api/v1/query/batch", key, batch)
+			require.Equal(t, http.StatusOK, code)
+			result := response["results"].([]interface{})[0].(map[string]interface{})
+			require.Equal(t, BatchStatusError, result["status"])
+			metadata, _ := result["error"].(map[string]interface{})["metadata"].(map[string]interface{})
+			return metadata
+		}
+
+		assert.NotContains(t, errorMetadata(userKey.Key), "telemetry")
+		assert.Contains(t, errorMetadata(adminKey.Key), "telemetry")
+	})
+}
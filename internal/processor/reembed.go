@@ -0,0 +1,169 @@
+package processor
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+)
+
+const defaultReembedBatchSize = 100
+
+// ReembedRequest controls a re-embedding run over stored queries
+type ReembedRequest struct {
+	// AfterID resumes a previous run from its NextCursor
+	AfterID string `json:"after_id,omitempty"`
+	// BatchSize is the number of stored queries embedded per batch
+	BatchSize int `json:"batch_size,omitempty"`
+	// Force re-embeds queries that already have the current dimension
+	Force bool `json:"force,omitempty"`
+}
+
+// ReembedResult reports the progress of a re-embedding run
+type ReembedResult struct {
+	Processed  int    `json:"processed"`
+	Updated    int    `json:"updated"`
+	Skipped    int    `json:"skipped"`
+	Failed     int    `json:"failed"`
+	Dimension  int    `json:"dimension"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Completed  bool   `json:"completed"`
+
+	// EmbeddingCachePurged is the number of cached embeddings removed because
+	// they were produced by a previous model; zero when the cache is disabled
+	EmbeddingCachePurged int `json:"embedding_cache_purged"`
+}
+
+// ReembedQueries regenerates embeddings for all stored queries using the current
+// embedding model. Queries whose embedding already has the current dimension are
+// skipped unless Force is set, so the run is idempotent. When the embedding
+// cache is enabled, cached embeddings from a previous model are purged first;
+// the cache is optional, so a failed purge is only logged. If the context is
+// cancelled the partial result is returned along with a cursor to resume from.
+func (qp *QueryProcessor) ReembedQueries(ctx context.Context, req ReembedRequest) (*ReembedResult, error) {
+	batchSize := req.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultReembedBatchSize
+	}
+
+	// Determine the dimension produced by the current model
+	probe, err := qp.llmClient.GetEmbedding(ctx, "dimension probe")
+	if err != nil {
+		return nil, errors.NewEmbeddingGenerationError(err)
+	}
+
+	result := &ReembedResult{
+		Dimension:  len(probe),
+		NextCursor: req.AfterID,
+	}
+
+	if qp.embeddingCache {
+		qp.purgeEmbeddingCache(ctx, result, req.Force)
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return result, nil
+		}
+
+		stored, err := qp.semanticMapper.ListStoredQueries(ctx, result.NextCursor, batchSize)
+		if err != nil {
+			return result, errors.NewDatabaseQueryError(err, "listing stored queries")
+		}
+		if len(stored) == 0 {
+			result.Completed = true
+			result.NextCursor = ""
+			return result, nil
+		}
+
+		var ids, texts []string
+		for _, sq := range stored {
+			if sq.Dimensions == result.Dimension && !req.Force {
+				result.Skipped++
+				continue
+			}
+			ids = append(ids, sq.ID)
+			texts = append(texts, sq.Query)
+		}
+
+		if len(texts) > 0 {
+			embeddings, err := llm.GetEmbeddings(ctx, qp.llmClient, texts)
+			if err != nil {
+				return result, errors.NewEmbeddingGenerationError(err)
+			}
+
+			for i, id := range ids {
+				if err := qp.semanticMapper.UpdateQueryEmbedding(ctx, id, embeddings[i]); err != nil {
+					qp.logger.Warn(ctx, "Failed to update query embedding", map[string]interface{}{
+						"id":    id,
+						"error": err.Error(),
+					})
+					result.Failed++
+					continue
+				}
+				result.Updated++
+			}
+		}
+
+		result.Processed += len(stored)
+		result.NextCursor = stored[len(stored)-1].ID
+
+		qp.logger.Info(ctx, "Re-embedded stored query batch", map[string]interface{}{
+			"processed": result.Processed,
+			"updated":   result.Updated,
+			"skipped":   result.Skipped,
+			"failed":    result.Failed,
+			"dimension": result.Dimension,
+		})
+
+		if len(stored) < batchSize {
+			result.Completed = true
+			result.NextCursor = ""
+			return result, nil
+		}
+	}
+}
+
+// handleReembed re-embeds all stored queries (admin only)
+func (qp *QueryProcessor) handleReembed(c *gin.Context) {
+	var req ReembedRequest
+	if c.Request.ContentLength > 0 {
+		if err := qp.bindJSON(c, &req); err != nil {
+			enhancedErr := errors.NewRequestBodyError(err)
+			c.JSON(http.StatusBadRequest, formatErrorResponse(enhancedErr))
+			return
+		}
+	}
+
+	result, err := qp.ReembedQueries(c.Request.Context(), req)
+	if err != nil {
+		response := formatErrorResponse(err)
+		if result != nil {
+			response["progress"] = result
+		}
+		c.JSON(getErrorStatusCode(err), response)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// purgeEmbeddingCache removes cached embeddings of another model or dimension
+// than the current one; a forced run assumes the model changed without a new
+// name and purges all of them
+func (qp *QueryProcessor) purgeEmbeddingCache(ctx context.Context, result *ReembedResult, force bool) {
+	dimension := result.Dimension
+	if force {
+		dimension = 0
+	}
+	purged, err := qp.semanticMapper.PurgeEmbeddingCache(ctx, llm.EmbeddingModel(qp.llmClient), dimension)
+	if err != nil {
+		qp.logger.Warn(ctx, "Failed to purge the embedding cache", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	result.EmbeddingCachePurged = purged
+}
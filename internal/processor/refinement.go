@@ -0,0 +1,145 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+)
+
+// Follow-up queries such as "now just the 5xx ones" refine a previous query.
+// The previous request and PromQL come from the request itself or, for
+// requests with a session ID, from the last query processed in that session.
+// Refined queries are safety checked like any other generated query.
+
+// refinementTTL is how long a session's last query is kept for refinement
+const refinementTTL = 30 * time.Minute
+
+// maxRefinementLength bounds the previous query and PromQL a request may carry
+const maxRefinementLength = 2000
+
+// refinementContext is the last query processed in a session
+type refinementContext struct {
+	Query  string `json:"query"`
+	PromQL string `json:"promql"`
+}
+
+// refining reports whether the request refines a previous query
+func (r *QueryRequest) refining() bool {
+	return r.PreviousQuery != "" || r.PreviousPromQL != ""
+}
+
+// UserResolver is implemented by auth middleware that can identify the
+// authenticated caller. Session IDs are chosen by clients, so a session's
+// last query is only visible to the user who made it.
+type UserResolver interface {
+	CurrentUserID(c *gin.Context) (string, bool)
+}
+
+// callerKey is the context key holding the authenticated caller's user ID
+type callerKey struct{}
+
+// withCaller returns a context carrying the authenticated caller's user ID
+func withCaller(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, callerKey{}, userID)
+}
+
+// caller returns the authenticated caller's user ID, or "" if unknown
+func caller(ctx context.Context) string {
+	userID, _ := ctx.Value(callerKey{}).(string)
+	return userID
+}
+
+// callerMiddleware copies the authenticated caller's user ID into the request
+// context
+func callerMiddleware(resolver UserResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if userID, ok := resolver.CurrentUserID(c); ok {
+			c.Request = c.Request.WithContext(withCaller(c.Request.Context(), userID))
+		}
+		c.Next()
+	}
+}
+
+// refinementKey is the cache key of a session's last query, scoped to the
+// caller and their tenant binding
+func refinementKey(ctx context.Context, sessionID string) string {
+	return fmt.Sprintf("refinement:%s/%s/%s", tenantBinding(ctx), caller(ctx), sessionID)
+}
+
+// validateRefinement rejects oversized previous queries
+func validateRefinement(req *QueryRequest) error {
+	if len(req.PreviousQuery) > maxRefinementLength {
+		return errors.NewInvalidInputError("previous_query", fmt.Sprintf("must be at most %d characters", maxRefinementLength))
+	}
+	if len(req.PreviousPromQL) > maxRefinementLength {
+		return errors.NewInvalidInputError("previous_promql", fmt.Sprintf("must be at most %d characters", maxRefinementLength))
+	}
+	return nil
+}
+
+// withSessionRefinement returns the request with the previous query of its
+// session filled in, unless it carries one itself. Failures to read the
+// session degrade to processing the request on its own.
+func (qp *QueryProcessor) withSessionRefinement(ctx context.Context, req *QueryRequest) *QueryRequest {
+	if req.SessionID == "" || req.refining() || qp.cache == nil {
+		return req
+	}
+
+	cacheCtx, cancel := context.WithTimeout(ctx, qp.cacheTimeout)
+	defer cancel()
+	data, err := qp.cache.Get(cacheCtx, refinementKey(ctx, req.SessionID)).Bytes()
+	if err != nil {
+		return req
+	}
+	var previous refinementContext
+	if err := json.Unmarshal(data, &previous); err != nil {
+		return req
+	}
+
+	refined := *req
+	refined.PreviousQuery = previous.Query
+	refined.PreviousPromQL = previous.PromQL
+	return &refined
+}
+
+// saveSessionRefinement records the query as the last one processed in the
+// request's session
+func (qp *QueryProcessor) saveSessionRefinement(ctx context.Context, req *QueryRequest, promql string) {
+	if req.SessionID == "" || qp.cache == nil {
+		return
+	}
+
+	data, err := json.Marshal(refinementContext{Query: req.Query, PromQL: promql})
+	if err != nil {
+		return
+	}
+	cacheCtx, cancel := context.WithTimeout(ctx, qp.cacheTimeout)
+	defer cancel()
+	if err := qp.cache.Set(cacheCtx, refinementKey(ctx, req.SessionID), data, refinementTTL).Err(); err != nil {
+		qp.logger.Warn(ctx, "Failed to save session query for refinement", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+// writeRefinementPrompt adds the previous query to a prompt so the LLM
+// refines it instead of starting over
+func writeRefinementPrompt(promptBuilder *strings.Builder, req *QueryRequest) {
+	if !req.refining() {
+		return
+	}
+	promptBuilder.WriteString("=== PREVIOUS QUERY ===\n")
+	promptBuilder.WriteString("The user is refining their previous query. Modify the previous PromQL to satisfy the new request instead of starting over, keeping everything the new request does not change.\n")
+	if req.PreviousQuery != "" {
+		promptBuilder.WriteString(fmt.Sprintf("Previous Request: \"%s\"\n", req.PreviousQuery))
+	}
+	if req.PreviousPromQL != "" {
+		promptBuilder.WriteString(fmt.Sprintf("Previous PromQL: %s\n", req.PreviousPromQL))
+	}
+	promptBuilder.WriteString("\n")
+}
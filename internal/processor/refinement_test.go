@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestProcessQueryRefinement tests that a follow-up query includes the previous PromQL in the prompt
+func TestProcessQueryRefinement(t *testing.T) {
+	previous := `sum(rate(http_requests_total{service="user-service",status=~"5..|4.."}[5m]))`
+	mapper := &MockSemanticMapper{services: []semantic.Service{
+		{ID: "svc-1", Name: "user-service", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+	}}
+
+	t.Run("previous query in request", func(t *testing.T) {
+		llmClient := &promptRecordingLLMClient{MockLLMClient: MockLLMClient{
+			response: &llm.Response{PromQL: `sum(rate(http_requests_total{service="user-service",status=~"5.."}[5m]))`, Confidence: 0.9},
+		}}
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, mapper, cache)
+
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{
+			Query:          "now just the 5xx ones",
+			PreviousQuery:  "show error rate for user-service",
+			PreviousPromQL: previous,
+		})
+		require.NoError(t, err)
+
+		require.Len(t, llmClient.prompts, 1)
+		assert.Contains(t, llmClient.prompts[0], "=== PREVIOUS QUERY ===")
+		assert.Contains(t, llmClient.prompts[0], `Previous Request: "show error rate for user-service"`)
+		assert.Contains(t, llmClient.prompts[0], "Previous PromQL: "+previous)
+		assert.Equal(t, previous, response.Metadata["refined_from"])
+	})
+
+	t.Run("previous query from session", func(t *testing.T) {
+		llmClient := &promptRecordingLLMClient{MockLLMClient: MockLLMClient{
+			response: &llm.Response{PromQL: previous, Confidence: 0.9},
+		}}
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, mapper, cache)
+
+		_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show error rate for user-service", SessionID: "s1"})
+		require.NoError(t, err)
+		_, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "now just the 5xx ones", SessionID: "s1"})
+		require.NoError(t, err)
+		_, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show latency for user-service", SessionID: "s2"})
+		require.NoError(t, err)
+
+		require.Len(t, llmClient.prompts, 3)
+		assert.NotContains(t, llmClient.prompts[0], "=== PREVIOUS QUERY ===")
+		assert.Contains(t, llmClient.prompts[1], "Previous PromQL: "+previous)
+		assert.NotContains(t, llmClient.prompts[2], "=== PREVIOUS QUERY ===", "sessions do not share queries")
+	})
+
+	t.Run("session of another user", func(t *testing.T) {
+		llmClient := &promptRecordingLLMClient{MockLLMClient: MockLLMClient{
+			response: &llm.Response{PromQL: previous, Confidence: 0.9},
+		}}
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, mapper, cache)
+		alice := withCaller(context.Background(), "alice")
+		mallory := withCaller(context.Background(), "mallory")
+
+		_, err := qp.ProcessQuery(alice, &QueryRequest{Query: "show error rate for user-service", SessionID: "shared"})
+		require.NoError(t, err)
+		response, err := qp.ProcessQuery(mallory, &QueryRequest{Query: "now just the 5xx ones", SessionID: "shared"})
+		require.NoError(t, err)
+
+		require.Len(t, llmClient.prompts, 2)
+		assert.NotContains(t, llmClient.prompts[1], "=== PREVIOUS QUERY ===")
+		assert.NotContains(t, response.Metadata, "refined_from")
+
+		// The session still refines the first user's own follow-ups
+		response, err = qp.ProcessQuery(alice, &QueryRequest{Query: "now just the 4xx ones", SessionID: "shared"})
+		require.NoError(t, err)
+		assert.Equal(t, previous, response.Metadata["refined_from"])
+	})
+
+	t.Run("refined query is safety checked", func(t *testing.T) {
+		llmClient := &promptRecordingLLMClient{MockLLMClient: MockLLMClient{
+			response: &llm.Response{PromQL: `sum(rate(api_secret_total[5m]))`, Confidence: 0.9},
+		}}
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, mapper, cache)
+
+		_, err := qp.ProcessQuery(context.Background(), &QueryRequest{
+			Query:          "now for the secret api",
+			PreviousPromQL: previous,
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("oversized previous query", func(t *testing.T) {
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(&MockLLMClient{}, mapper, cache)
+
+		_, err := qp.ProcessQuery(context.Background(), &QueryRequest{
+			Query:          "now just the 5xx ones",
+			PreviousPromQL: strings.Repeat("a", maxRefinementLength+1),
+		})
+		require.Error(t, err)
+		assert.Equal(t, errors.ErrCodeInvalidInput, err.(*errors.EnhancedError).Code)
+	})
+}
@@ -0,0 +1,32 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// SetStrictJSON rejects JSON request bodies with fields the endpoint does not
+// accept, such as misspelled ones, instead of ignoring them
+func (qp *QueryProcessor) SetStrictJSON(strict bool) {
+	qp.strictJSON = strict
+}
+
+// bindJSON decodes and validates the JSON request body into obj, rejecting
+// unknown fields in strict mode
+func (qp *QueryProcessor) bindJSON(c *gin.Context, obj interface{}) error {
+	if !qp.strictJSON {
+		return c.ShouldBindJSON(obj)
+	}
+	if c.Request == nil || c.Request.Body == nil {
+		return fmt.Errorf("invalid request")
+	}
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStrictJSONRequests tests that unknown request body fields are ignored by
+// default and rejected, by name, in strict mode
+func TestStrictJSONRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mapper := &MockSemanticMapper{services: []semantic.Service{
+		{ID: "svc-1", Name: "api", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+	}}
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_requests_total{service="api"}[5m]))`, Confidence: 0.8}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	lenient := NewQueryProcessor(llmClient, mapper, cache).SetupRoutes(nil)
+	strictProcessor := NewQueryProcessor(llmClient, mapper, cache)
+	strictProcessor.SetStrictJSON(true)
+	strict := strictProcessor.SetupRoutes(nil)
+
+	query := func(router *gin.Engine, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/query", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	const typo = `{"query": "request rate for api", "time_rang": "1h"}`
+
+	t.Run("lenient by default", func(t *testing.T) {
+		w := query(lenient, typo)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("strict mode rejects unknown fields", func(t *testing.T) {
+		w := query(strict, typo)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		var response struct {
+			Error struct {
+				Code     string                 `json:"code"`
+				Details  string                 `json:"details"`
+				Metadata map[string]interface{} `json:"metadata"`
+			} `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "INVALID_INPUT", response.Error.Code)
+		assert.Equal(t, "Field 'time_rang' is not recognized", response.Error.Details)
+		assert.Equal(t, "time_rang", response.Error.Metadata["field"])
+
+		w = query(strict, `{"time_range": "1h"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "required fields are still validated")
+
+		w = query(strict, `{"query": "request rate for api", "time_range": "1h"}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+}
@@ -11,21 +11,34 @@ import (
 
 // SafetyChecker validates queries for safety
 type SafetyChecker struct {
-	MaxQueryRange    time.Duration
-	MaxCardinality   int
-	TimeoutSeconds   int
-	ForbiddenMetrics []string
-	MaxQueryLength   int // Maximum query length in characters
+	MaxQueryRange     time.Duration
+	MaxCardinality    int
+	TimeoutSeconds    int
+	ForbiddenMetrics  []string
+	MaxQueryLength    int      // Maximum query length in characters
 	ForbiddenPatterns []string // Additional forbidden patterns (case-insensitive)
+
+	// RequireLabelMatchers rejects metric selectors without any label matcher,
+	// such as a bare http_requests_total, which select every series of the metric
+	RequireLabelMatchers bool
+
+	// Subquery limits: the longest range, the finest resolution and the
+	// deepest nesting of subqueries such as [1h:1m]; zero disables a limit
+	MaxSubqueryRange time.Duration
+	MinSubqueryStep  time.Duration
+	MaxSubqueryDepth int
 }
 
 // NewSafetyChecker creates a new safety checker with default settings
 func NewSafetyChecker() *SafetyChecker {
 	return &SafetyChecker{
-		MaxQueryRange:  7 * 24 * time.Hour, // 7 days
-		MaxCardinality: 10000,
-		TimeoutSeconds: 30,
-		MaxQueryLength: 500, // Maximum 500 characters
+		MaxQueryRange:    7 * 24 * time.Hour, // 7 days
+		MaxCardinality:   10000,
+		TimeoutSeconds:   30,
+		MaxQueryLength:   500, // Maximum 500 characters
+		MaxSubqueryRange: defaultMaxSubqueryRange,
+		MinSubqueryStep:  defaultMinSubqueryStep,
+		MaxSubqueryDepth: defaultMaxSubqueryDepth,
 		ForbiddenMetrics: []string{
 			".*_secret.*",
 			".*_password.*",
@@ -86,6 +99,18 @@ func (sc *SafetyChecker) ValidateQuery(promql string) error {
 		return errors.NewHighCardinalityError()
 	}
 
+	// Check for selectors that match every series of a metric
+	if sc.RequireLabelMatchers {
+		if unscoped := unscopedSelectors(promql); len(unscoped) > 0 {
+			return errors.NewUnscopedSelectorError(unscoped[0])
+		}
+	}
+
+	// Check for subqueries evaluated over long ranges, at fine resolutions or nested
+	if err := sc.validateSubqueries(promql); err != nil {
+		return err
+	}
+
 	// Check for potentially expensive operations
 	expensiveOps := []string{
 		"group_left",
@@ -109,6 +134,12 @@ func (sc *SafetyChecker) ValidateQuery(promql string) error {
 	return nil
 }
 
+// SetRequireLabelMatchers enables rejecting generated queries with metric
+// selectors that have no label matcher
+func (qp *QueryProcessor) SetRequireLabelMatchers(required bool) {
+	qp.safetyChecker.RequireLabelMatchers = required
+}
+
 // ValidateTimeRange checks if a time range is within safe limits
 func (sc *SafetyChecker) ValidateTimeRange(timeRange string) error {
 	// Validate time range format first
@@ -143,6 +174,21 @@ func (sc *SafetyChecker) ValidateTimeRange(timeRange string) error {
 	return nil
 }
 
+// ValidateWindow checks that an absolute time window is well-formed and within safe limits
+func (sc *SafetyChecker) ValidateWindow(start, end time.Time) error {
+	if !end.After(start) {
+		return errors.New(errors.ErrCodeInvalidInput, "Invalid time window").
+			WithDetails(fmt.Sprintf("Window end %s is not after its start %s", end.Format(time.RFC3339), start.Format(time.RFC3339))).
+			WithSuggestion("Specify a window whose end is after its start, e.g. 'between 2pm and 4pm'.")
+	}
+
+	if window := end.Sub(start); window > sc.MaxQueryRange {
+		return errors.NewExcessiveTimeRangeError(window.String(), sc.MaxQueryRange.String())
+	}
+
+	return nil
+}
+
 // isValidTimeRangeFormat validates the format of a time range string
 func isValidTimeRangeFormat(timeRange string) bool {
 	// Valid formats: 5m, 1h, 24h, 7d, 1w, etc.
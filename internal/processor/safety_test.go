@@ -743,3 +743,55 @@ func TestCustomForbiddenPatterns(t *testing.T) {
 		})
 	}
 }
+
+// TestRequireLabelMatchers tests the optional rule rejecting metric selectors without label matchers
+func TestRequireLabelMatchers(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		unscoped []string
+	}{
+		{name: "bare selector", query: `http_requests_total`, unscoped: []string{"http_requests_total"}},
+		{name: "empty matchers", query: `http_requests_total{}`, unscoped: []string{"http_requests_total"}},
+		{name: "bare selector in function", query: `rate(http_requests_total[5m])`, unscoped: []string{"http_requests_total"}},
+		{name: "scoped selector", query: `rate(http_requests_total{service="api"}[5m])`},
+		{name: "matchers without metric name", query: `{job="api"}`},
+		{
+			name:  "aggregation with grouping labels",
+			query: `sum by (service, le) (rate(http_request_duration_seconds_bucket{job="api"}[5m]))`,
+		},
+		{
+			name:  "grouping clause after the aggregation",
+			query: `histogram_quantile(0.95, sum(rate(latency_bucket{service="api"}[5m])) by (le))`,
+		},
+		{
+			name:     "one side of a binary operation unscoped",
+			query:    `sum(rate(errors_total{service="api"}[5m])) / sum(rate(requests_total[5m]))`,
+			unscoped: []string{"requests_total"},
+		},
+		{name: "offset and numbers", query: `up{job="api"} offset 1h > 0.5`},
+		{name: "string arguments", query: `label_replace(up{job="api"}, "dst", "$1", "instance", "(.*)")`},
+		{name: "vector matching labels", query: `a{job="x"} / on (instance) group_left (version) b{job="x"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.unscoped, unscopedSelectors(tt.query))
+		})
+	}
+
+	t.Run("bare selector rejected when enabled", func(t *testing.T) {
+		sc := NewSafetyChecker()
+		sc.RequireLabelMatchers = true
+
+		err := sc.ValidateQuery(`sum(rate(http_requests_total[5m]))`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "without any label matcher")
+
+		assert.NoError(t, sc.ValidateQuery(`sum(rate(http_requests_total{service="api"}[5m]))`))
+	})
+
+	t.Run("bare selector allowed by default", func(t *testing.T) {
+		assert.NoError(t, NewSafetyChecker().ValidateQuery(`sum(rate(http_requests_total[5m]))`))
+	})
+}
@@ -0,0 +1,151 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/observability"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+)
+
+// A configurable fraction of generated queries is stored through the mapper
+// to build an offline evaluation set for prompt regression testing. Samples
+// are redacted and queued by ProcessQuery, and written by a background worker
+// so storage never adds request latency.
+
+// evaluationQueueSize is how many samples may wait to be stored before new
+// ones are dropped
+const evaluationQueueSize = 100
+
+// evaluationStoreTimeout bounds each sample write
+const evaluationStoreTimeout = 5 * time.Second
+
+// Personal data redacted from samples before they are stored
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	ipv4Pattern  = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+)
+
+// redactPII replaces email addresses and IP addresses in s
+func redactPII(s string) string {
+	s = emailPattern.ReplaceAllString(s, "[REDACTED_EMAIL]")
+	return ipv4Pattern.ReplaceAllString(s, "[REDACTED_IP]")
+}
+
+// evaluationSampler decides which queries are sampled and stores them
+type evaluationSampler struct {
+	mapper     semantic.Mapper
+	logger     *observability.Logger
+	rate       float64
+	maxPerHour int
+	random     func() float64
+	now        func() time.Time
+	samples    chan semantic.EvaluationSample
+
+	mu          sync.Mutex
+	windowStart time.Time
+	windowCount int
+}
+
+func newEvaluationSampler(mapper semantic.Mapper, logger *observability.Logger, rate float64, maxPerHour int) *evaluationSampler {
+	return &evaluationSampler{
+		mapper:     mapper,
+		logger:     logger,
+		rate:       rate,
+		maxPerHour: maxPerHour,
+		random:     rand.Float64,
+		now:        time.Now,
+		samples:    make(chan semantic.EvaluationSample, evaluationQueueSize),
+	}
+}
+
+// SetEvaluationSampling stores the given fraction of generated queries, at
+// most maxPerHour each hour, for offline evaluation. A zero rate or cap
+// disables sampling. It must be called at most once, before serving requests.
+func (qp *QueryProcessor) SetEvaluationSampling(rate float64, maxPerHour int) {
+	if rate <= 0 || maxPerHour <= 0 {
+		qp.evaluation = nil
+		return
+	}
+	qp.evaluation = newEvaluationSampler(qp.semanticMapper, qp.logger, rate, maxPerHour)
+	go qp.evaluation.run()
+}
+
+// take reports whether the next query is sampled, counting it against the
+// hourly cap if so
+func (s *evaluationSampler) take() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.windowStart) >= time.Hour {
+		s.windowStart = now
+		s.windowCount = 0
+	}
+	if s.windowCount >= s.maxPerHour || s.random() >= s.rate {
+		return false
+	}
+	s.windowCount++
+	return true
+}
+
+// enqueue queues a sample for storage, dropping it if the queue is full
+func (s *evaluationSampler) enqueue(sample semantic.EvaluationSample) {
+	select {
+	case s.samples <- sample:
+	default:
+		observability.GetGlobalMetrics().Inc(observability.MetricEvaluationSamples, map[string]string{
+			"outcome": "dropped",
+		})
+	}
+}
+
+// run stores queued samples until the queue is closed
+func (s *evaluationSampler) run() {
+	for sample := range s.samples {
+		ctx, cancel := context.WithTimeout(context.Background(), evaluationStoreTimeout)
+		err := s.mapper.StoreEvaluationSample(ctx, sample)
+		cancel()
+
+		outcome := "stored"
+		if err != nil {
+			outcome = "failed"
+			s.logger.Warn(context.Background(), "Failed to store evaluation sample", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		observability.GetGlobalMetrics().Inc(observability.MetricEvaluationSamples, map[string]string{
+			"outcome": outcome,
+		})
+	}
+}
+
+// sampleForEvaluation queues a generated query for evaluation storage if it
+// is sampled
+func (qp *QueryProcessor) sampleForEvaluation(req *QueryRequest, prompt string, intent *QueryIntent, response *llm.Response) {
+	if qp.evaluation == nil || !qp.evaluation.take() {
+		return
+	}
+
+	intentJSON, err := json.Marshal(intent)
+	if err != nil {
+		return
+	}
+	model := req.Model
+	if model == "" {
+		model = qp.defaultModel
+	}
+	qp.evaluation.enqueue(semantic.EvaluationSample{
+		Query:      redactPII(req.Query),
+		Prompt:     redactPII(prompt),
+		PromQL:     redactPII(response.PromQL),
+		Intent:     json.RawMessage(redactPII(string(intentJSON))),
+		Confidence: response.Confidence,
+		Model:      model,
+	})
+}
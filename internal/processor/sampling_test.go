@@ -0,0 +1,95 @@
+package processor
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sampleRecordingMapper records stored evaluation samples
+type sampleRecordingMapper struct {
+	MockSemanticMapper
+	mu      sync.Mutex
+	samples []semantic.EvaluationSample
+}
+
+func (m *sampleRecordingMapper) StoreEvaluationSample(ctx context.Context, sample semantic.EvaluationSample) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.samples = append(m.samples, sample)
+	return nil
+}
+
+func (m *sampleRecordingMapper) stored() []semantic.EvaluationSample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]semantic.EvaluationSample(nil), m.samples...)
+}
+
+// TestEvaluationSamplerRate tests that roughly the configured fraction of queries is sampled
+func TestEvaluationSamplerRate(t *testing.T) {
+	const total = 10000
+	s := newEvaluationSampler(nil, nil, 0.25, total)
+	s.random = rand.New(rand.NewSource(1)).Float64
+
+	sampled := 0
+	for i := 0; i < total; i++ {
+		if s.take() {
+			sampled++
+		}
+	}
+	assert.InDelta(t, total/4, sampled, total*0.02)
+}
+
+// TestEvaluationSamplerCap tests that no more than the hourly cap is sampled
+func TestEvaluationSamplerCap(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	s := newEvaluationSampler(nil, nil, 1, 10)
+	s.now = func() time.Time { return now }
+
+	sampled := 0
+	for i := 0; i < 50; i++ {
+		if s.take() {
+			sampled++
+		}
+	}
+	assert.Equal(t, 10, sampled)
+
+	now = now.Add(time.Hour)
+	assert.True(t, s.take(), "the cap resets each hour")
+}
+
+// TestProcessQueryEvaluationSampling tests that sampled queries are stored redacted
+func TestProcessQueryEvaluationSampling(t *testing.T) {
+	llmClient := &MockLLMClient{response: &llm.Response{
+		PromQL:     `sum(rate(http_requests_total{service="checkout",instance="10.0.0.12:8080"}[5m]))`,
+		Confidence: 0.9,
+	}}
+	mapper := &sampleRecordingMapper{MockSemanticMapper: MockSemanticMapper{services: []semantic.Service{
+		{ID: "svc-1", Name: "checkout", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+	}}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, mapper, cache)
+	qp.SetEvaluationSampling(1, 100)
+
+	_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show requests for service checkout from jane@example.com"})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return len(mapper.stored()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	sample := mapper.stored()[0]
+	assert.Equal(t, "show requests for service checkout from [REDACTED_EMAIL]", sample.Query)
+	assert.Contains(t, sample.Prompt, "checkout")
+	assert.NotContains(t, sample.Prompt, "jane@example.com")
+	assert.Equal(t, `sum(rate(http_requests_total{service="checkout",instance="[REDACTED_IP]:8080"}[5m]))`, sample.PromQL)
+	assert.Contains(t, string(sample.Intent), `"service":"checkout"`)
+	assert.Equal(t, 0.9, sample.Confidence)
+}
@@ -0,0 +1,133 @@
+package processor
+
+// promqlAggregations are aggregation operators, which may be followed by a
+// by/without clause instead of their parenthesized arguments
+var promqlAggregations = map[string]bool{
+	"sum": true, "avg": true, "min": true, "max": true, "count": true,
+	"group": true, "stddev": true, "stdvar": true, "topk": true, "bottomk": true,
+	"quantile": true, "count_values": true, "limitk": true, "limit_ratio": true,
+}
+
+// promqlKeywords are words that are never metric names
+var promqlKeywords = map[string]bool{
+	"by": true, "without": true, "on": true, "ignoring": true,
+	"group_left": true, "group_right": true, "bool": true, "offset": true,
+	"and": true, "or": true, "unless": true, "atan2": true,
+	"inf": true, "nan": true,
+}
+
+// vectorSelector is a vector selector of a query
+type vectorSelector struct {
+	metric string
+	scoped bool // Has at least one label matcher
+}
+
+// unscopedSelectors returns the metric names of vector selectors in a query
+// that have no label matchers, such as http_requests_total or
+// http_requests_total{}. Queries that cannot be tokenized return nil.
+func unscopedSelectors(promql string) []string {
+	var unscoped []string
+	for _, selector := range vectorSelectors(promql) {
+		if !selector.scoped {
+			unscoped = append(unscoped, selector.metric)
+		}
+	}
+	return unscoped
+}
+
+// selectorMetrics returns the distinct metric names selected by a query, in
+// order of first use. Queries that cannot be tokenized return nil.
+func selectorMetrics(promql string) []string {
+	var metrics []string
+	seen := make(map[string]bool)
+	for _, selector := range vectorSelectors(promql) {
+		if !seen[selector.metric] {
+			seen[selector.metric] = true
+			metrics = append(metrics, selector.metric)
+		}
+	}
+	return metrics
+}
+
+// vectorSelectors returns the vector selectors of a query that name a metric.
+// It works on the token stream, so function names, keywords, label names,
+// durations and grouping labels are not mistaken for selectors. Queries that
+// cannot be tokenized return nil.
+func vectorSelectors(promql string) []vectorSelector {
+	tokens, err := tokenizePromQL(promql)
+	if err != nil {
+		return nil
+	}
+
+	var selectors []vectorSelector
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		switch {
+		case tok.text == "{" || tok.text == "[":
+			// Label matchers of a selector without a metric name, and range
+			// or subquery durations, contain no selectors
+			i = skipBracketed(tokens, i)
+			continue
+
+		case tok.kind != tokenWord || promqlAggregations[tok.text]:
+			continue
+
+		case promqlKeywords[tok.text]:
+			// Grouping and matching clauses list label names, not metrics
+			if i+1 < len(tokens) && tokens[i+1].text == "(" && groupingClause(tok.text) {
+				i = skipBracketed(tokens, i+1)
+			}
+			continue
+
+		case !isMetricNameStart(tok.text[0]):
+			// Numbers and durations
+			continue
+		}
+
+		next := ""
+		if i+1 < len(tokens) {
+			next = tokens[i+1].text
+		}
+		switch next {
+		case "(":
+			// Function call
+			continue
+		case "{":
+			end := skipBracketed(tokens, i+1)
+			selectors = append(selectors, vectorSelector{metric: tok.text, scoped: end != i+2})
+			i = end
+		default:
+			selectors = append(selectors, vectorSelector{metric: tok.text})
+		}
+	}
+
+	return selectors
+}
+
+// groupingClause reports whether keyword is followed by a label list
+func groupingClause(keyword string) bool {
+	return groupingKeywords[keyword] || keyword == "group_left" || keyword == "group_right"
+}
+
+// skipBracketed returns the index of the token closing the bracket at open,
+// or the last index if it is not closed
+func skipBracketed(tokens []promqlToken, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch tokens[i].text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+func isMetricNameStart(c byte) bool {
+	return c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
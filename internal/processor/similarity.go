@@ -0,0 +1,56 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+
+	"github.com/seanankenbruck/observability-ai/internal/observability"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+)
+
+// Similar-query search failures are never fatal: the query continues without
+// examples. They are classified so that misconfiguration is not mistaken for
+// a transient failure.
+const (
+	similarityErrorNoResults     = "no_results"
+	similarityErrorConfiguration = "configuration"
+	similarityErrorTransient     = "transient"
+)
+
+// classifySimilarityError returns the class of a similar-query search failure
+// and, for configuration errors, the reason
+func classifySimilarityError(err error) (class, reason string) {
+	var dimensionErr *semantic.EmbeddingDimensionError
+	switch {
+	case stderrors.Is(err, sql.ErrNoRows):
+		return similarityErrorNoResults, ""
+	case stderrors.As(err, &dimensionErr):
+		return similarityErrorConfiguration, "embedding_dimension"
+	default:
+		return similarityErrorTransient, ""
+	}
+}
+
+// reportSimilarityError logs a similar-query search failure at a level that
+// matches its class. Configuration errors are logged as errors and counted,
+// since they persist until an operator fixes them.
+func (qp *QueryProcessor) reportSimilarityError(ctx context.Context, err error) {
+	switch class, reason := classifySimilarityError(err); class {
+	case similarityErrorNoResults:
+		qp.logger.Debug(ctx, "No similar queries found", map[string]interface{}{
+			"error": err.Error(),
+		})
+	case similarityErrorConfiguration:
+		observability.GetGlobalMetrics().Inc(observability.MetricSimilaritySearchConfigErrors, map[string]string{
+			"reason": reason,
+		})
+		qp.logger.Error(ctx, "Similar query search is misconfigured; continuing without examples", err, map[string]interface{}{
+			"reason": reason,
+		})
+	default:
+		qp.logger.Warn(ctx, "Failed to find similar queries", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
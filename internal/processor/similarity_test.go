@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/observability"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingSimilarityMapper fails every similar-query search with err
+type failingSimilarityMapper struct {
+	MockSemanticMapper
+	err error
+}
+
+func (m *failingSimilarityMapper) FindSimilarQueries(ctx context.Context, embedding []float32) ([]semantic.SimilarQuery, error) {
+	return nil, m.err
+}
+
+// similarityConfigErrors returns the count of similarity search configuration errors for reason
+func similarityConfigErrors(reason string) float64 {
+	metric, ok := observability.GetGlobalMetrics().Get(observability.MetricSimilaritySearchConfigErrors, map[string]string{"reason": reason})
+	if !ok {
+		return 0
+	}
+	return metric.Value
+}
+
+// TestProcessQuerySimilarityDimensionError tests that a dimension error is reported but does not fail the query
+func TestProcessQuerySimilarityDimensionError(t *testing.T) {
+	mapper := &failingSimilarityMapper{err: fmt.Errorf("similarity search: %w", &semantic.EmbeddingDimensionError{Expected: 384, Actual: 1536})}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(&MockLLMClient{
+		response: &llm.Response{PromQL: `sum(rate(http_requests_total[5m]))`, Confidence: 0.9},
+	}, mapper, cache)
+
+	before := similarityConfigErrors("embedding_dimension")
+
+	response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show error rate for service checkout"})
+	require.NoError(t, err)
+	assert.Equal(t, `sum(rate(http_requests_total[5m]))`, response.PromQL)
+	assert.Equal(t, 0, response.Metadata["similar_queries"])
+
+	assert.Equal(t, before+1, similarityConfigErrors("embedding_dimension"))
+}
+
+// TestClassifySimilarityError tests classification of similar-query search failures
+func TestClassifySimilarityError(t *testing.T) {
+	class, reason := classifySimilarityError(&semantic.EmbeddingDimensionError{Expected: 384, Actual: 1536})
+	assert.Equal(t, similarityErrorConfiguration, class)
+	assert.Equal(t, "embedding_dimension", reason)
+
+	class, _ = classifySimilarityError(fmt.Errorf("lookup: %w", sql.ErrNoRows))
+	assert.Equal(t, similarityErrorNoResults, class)
+
+	class, _ = classifySimilarityError(fmt.Errorf("connection refused"))
+	assert.Equal(t, similarityErrorTransient, class)
+}
@@ -0,0 +1,183 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+)
+
+// Default subquery limits. A subquery evaluates its inner expression once per
+// step over its whole range, so long ranges, fine steps and nested subqueries
+// multiply the cost of a query.
+const (
+	defaultMaxSubqueryRange = 24 * time.Hour
+	defaultMinSubqueryStep  = time.Minute
+	defaultMaxSubqueryDepth = 1
+)
+
+// subquery is a subquery of a query, e.g. the [1h:1m] of
+// max_over_time(rate(x[5m])[1h:1m])
+type subquery struct {
+	text  string        // The bracketed range and step, e.g. "[1h:1m]"
+	rng   time.Duration // Range
+	step  time.Duration // Resolution; zero uses the evaluation interval
+	depth int           // 1, plus the depth of the deepest subquery inside it
+}
+
+// promqlDurationPattern matches one unit of a PromQL duration, e.g. "1h" in
+// "1h30m", and promqlDurationFormat a whole duration
+var (
+	promqlDurationPattern = regexp.MustCompile(`(\d+)(ms|s|m|h|d|w|y)`)
+	promqlDurationFormat  = regexp.MustCompile(`^(\d+(ms|s|m|h|d|w|y))+$`)
+)
+
+// promqlDurationUnits are the units of PromQL durations
+var promqlDurationUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// parsePromQLDuration parses a PromQL duration such as "5m" or "1h30m"
+func parsePromQLDuration(s string) (time.Duration, error) {
+	if !promqlDurationFormat.MatchString(s) {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	var d time.Duration
+	for _, match := range promqlDurationPattern.FindAllStringSubmatch(s, -1) {
+		n, err := strconv.Atoi(match[1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+		}
+		d += time.Duration(n) * promqlDurationUnits[match[2]]
+	}
+	return d, nil
+}
+
+// subqueries returns the subqueries of a query, in order of their closing
+// bracket. Queries that cannot be tokenized return nil.
+func subqueries(promql string) []subquery {
+	tokens, err := tokenizePromQL(promql)
+	if err != nil {
+		return nil
+	}
+
+	type span struct {
+		start, open int // First token of the inner expression, and the "["
+		depth       int
+	}
+	var spans []span
+	var found []subquery
+
+	for i := 1; i+2 < len(tokens); i++ {
+		// The tokenizer keeps "1h:1m" (and "1h:") together, as ":" may be
+		// part of a metric name
+		if tokens[i].text != "[" || tokens[i+2].text != "]" || !strings.Contains(tokens[i+1].text, ":") {
+			continue
+		}
+		rangeText, stepText, _ := strings.Cut(tokens[i+1].text, ":")
+		rng, err := parsePromQLDuration(rangeText)
+		if err != nil {
+			continue
+		}
+		var step time.Duration
+		if stepText != "" {
+			if step, err = parsePromQLDuration(stepText); err != nil {
+				continue
+			}
+		}
+
+		start := innerExpressionStart(tokens, i)
+		depth := 1
+		for _, inner := range spans {
+			if inner.start >= start && inner.open < i && inner.depth+1 > depth {
+				depth = inner.depth + 1
+			}
+		}
+		spans = append(spans, span{start: start, open: i, depth: depth})
+		found = append(found, subquery{
+			text:  "[" + tokens[i+1].text + "]",
+			rng:   rng,
+			step:  step,
+			depth: depth,
+		})
+	}
+
+	return found
+}
+
+// innerExpressionStart returns the index of the first token of the expression
+// a subquery at open applies to
+func innerExpressionStart(tokens []promqlToken, open int) int {
+	i := open - 1
+	switch tokens[i].text {
+	case ")", "}":
+		i = matchingOpen(tokens, i)
+	}
+	// Include the function or metric name before the brackets
+	if i > 0 && tokens[i-1].kind == tokenWord {
+		i--
+	}
+	return i
+}
+
+// matchingOpen returns the index of the bracket opening the one closed at
+// close, or 0 if it is not opened
+func matchingOpen(tokens []promqlToken, close int) int {
+	depth := 0
+	for i := close; i >= 0; i-- {
+		switch tokens[i].text {
+		case ")", "]", "}":
+			depth++
+		case "(", "[", "{":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return 0
+}
+
+// validateSubqueries rejects subqueries whose range, resolution or nesting
+// exceeds the checker's limits
+func (sc *SafetyChecker) validateSubqueries(promql string) error {
+	for _, sq := range subqueries(promql) {
+		if sc.MaxSubqueryRange > 0 && sq.rng > sc.MaxSubqueryRange {
+			return errors.NewExpensiveSubqueryError(sq.text,
+				fmt.Sprintf("maximum subquery range of %s", sc.MaxSubqueryRange))
+		}
+		if sc.MinSubqueryStep > 0 && sq.step > 0 && sq.step < sc.MinSubqueryStep {
+			return errors.NewExpensiveSubqueryError(sq.text,
+				fmt.Sprintf("minimum subquery resolution of %s", sc.MinSubqueryStep))
+		}
+		if sc.MaxSubqueryDepth > 0 && sq.depth > sc.MaxSubqueryDepth {
+			return errors.NewExpensiveSubqueryError(sq.text,
+				fmt.Sprintf("maximum subquery nesting depth of %d", sc.MaxSubqueryDepth))
+		}
+	}
+	return nil
+}
+
+// SetSubqueryLimits bounds the range, resolution and nesting depth of
+// subqueries in generated queries; non-positive values keep the defaults
+func (qp *QueryProcessor) SetSubqueryLimits(maxRange, minStep time.Duration, maxDepth int) {
+	if maxRange > 0 {
+		qp.safetyChecker.MaxSubqueryRange = maxRange
+	}
+	if minStep > 0 {
+		qp.safetyChecker.MinSubqueryStep = minStep
+	}
+	if maxDepth > 0 {
+		qp.safetyChecker.MaxSubqueryDepth = maxDepth
+	}
+}
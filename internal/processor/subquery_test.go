@@ -0,0 +1,102 @@
+package processor
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSubqueryLimits tests that subqueries with long ranges, fine resolutions
+// or nesting are rejected while modest ones are allowed
+func TestSubqueryLimits(t *testing.T) {
+	checker := NewSafetyChecker()
+
+	tests := []struct {
+		name     string
+		promql   string
+		subquery string // Rejected subquery; empty if allowed
+		limit    string
+	}{
+		{
+			name:   "modest subquery",
+			promql: `max_over_time(rate(x[5m])[1h:1m])`,
+		},
+		{
+			name:   "default resolution",
+			promql: `max_over_time(rate(x[5m])[6h:])`,
+		},
+		{
+			name:   "range vector is not a subquery",
+			promql: `rate(job:requests:rate5m[5m])`,
+		},
+		{
+			name:     "range too long",
+			promql:   `max_over_time(rate(x[5m])[30d:1h])`,
+			subquery: "[30d:1h]",
+			limit:    "maximum subquery range of 24h0m0s",
+		},
+		{
+			name:     "resolution too fine",
+			promql:   `max_over_time(rate(x[5m])[1h:1s])`,
+			subquery: "[1h:1s]",
+			limit:    "minimum subquery resolution of 1m0s",
+		},
+		{
+			name:     "nested subqueries",
+			promql:   `max_over_time(deriv(x[1h:5m])[1h:5m])`,
+			subquery: "[1h:5m]",
+			limit:    "maximum subquery nesting depth of 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checker.ValidateQuery(tt.promql)
+			if tt.subquery == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			enhanced, ok := err.(*errors.EnhancedError)
+			require.True(t, ok)
+			assert.Equal(t, errors.ErrCodeExpensiveSubquery, enhanced.Code)
+			assert.Equal(t, tt.subquery, enhanced.Metadata["subquery"])
+			assert.Contains(t, enhanced.Details, tt.limit)
+			assert.NotEmpty(t, enhanced.Suggestion)
+			assert.Equal(t, http.StatusBadRequest, getErrorStatusCode(err))
+		})
+	}
+
+	t.Run("configured limits", func(t *testing.T) {
+		qp := NewQueryProcessor(nil, nil, nil)
+		qp.SetSubqueryLimits(7*24*time.Hour, 10*time.Second, 2)
+
+		assert.NoError(t, qp.safetyChecker.ValidateQuery(`max_over_time(rate(x[5m])[3d:30s])`))
+		assert.NoError(t, qp.safetyChecker.ValidateQuery(`max_over_time(deriv(x[1h:5m])[1h:5m])`))
+	})
+}
+
+// TestParsePromQLDuration tests parsing of PromQL durations
+func TestParsePromQLDuration(t *testing.T) {
+	for text, expected := range map[string]time.Duration{
+		"5m":    5 * time.Minute,
+		"1h30m": 90 * time.Minute,
+		"500ms": 500 * time.Millisecond,
+		"2d":    48 * time.Hour,
+		"1w":    7 * 24 * time.Hour,
+	} {
+		d, err := parsePromQLDuration(text)
+		require.NoError(t, err, text)
+		assert.Equal(t, expected, d, text)
+	}
+
+	for _, invalid := range []string{"", "5", "m", "5x", "1h 30m"} {
+		_, err := parsePromQLDuration(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
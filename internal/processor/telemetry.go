@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+)
+
+// Cache lookup outcomes reported in query telemetry
+const (
+	cacheLookupHit     = "hit"
+	cacheLookupMiss    = "miss"
+	cacheLookupTimeout = "timeout"
+	cacheLookupSkipped = "skipped"
+)
+
+// Safety validation outcomes reported in query telemetry
+const (
+	safetyOutcomePassed   = "passed"
+	safetyOutcomeRejected = "rejected"
+	safetyOutcomeNotRun   = "not_run"
+)
+
+// QueryTelemetry breaks down how a single query was built. It is returned in
+// the response metadata under "telemetry" when the request sets Debug, or in
+// the error metadata when the query fails.
+type QueryTelemetry struct {
+	CacheLookup          string           `json:"cache_lookup"` // "hit", "miss", "timeout" or "skipped"
+	IntentType           string           `json:"intent_type,omitempty"`
+	IntentConfidence     float64          `json:"intent_confidence,omitempty"`
+	DirectQuery          bool             `json:"direct_query"`
+	SimilarQueries       int              `json:"similar_queries"`
+	PromptTokensEstimate int              `json:"prompt_tokens_estimate"`
+	PromptTokenBudget    int              `json:"prompt_token_budget,omitempty"` // Fits the model's context window
+	LLMLatencyMs         int64            `json:"llm_latency_ms"`
+	SafetyOutcome        string           `json:"safety_outcome"` // "passed", "rejected" or "not_run"
+	StageTimingsMs       map[string]int64 `json:"stage_timings_ms"`
+}
+
+// estimatePromptTokens approximates the token count of a prompt, assuming
+// about four characters per token for English text
+func estimatePromptTokens(prompt string) int {
+	return (len(prompt) + 3) / 4
+}
+
+// withTelemetry adds the telemetry to the response metadata if the request
+// asked for it
+func withTelemetry(req *QueryRequest, response *QueryResponse, telemetry *QueryTelemetry) *QueryResponse {
+	if !req.Debug {
+		return response
+	}
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata["telemetry"] = telemetry
+	return response
+}
+
+// withErrorTelemetry adds the telemetry to the metadata of a failed query's
+// error if the request asked for it
+func withErrorTelemetry(req *QueryRequest, err error, telemetry *QueryTelemetry) {
+	if !req.Debug {
+		return
+	}
+	if enhancedErr, ok := err.(*errors.EnhancedError); ok {
+		enhancedErr.WithMetadata("telemetry", telemetry)
+	}
+}
@@ -0,0 +1,143 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestQueryTelemetry tests that debug requests return a breakdown of how the query was built
+func TestQueryTelemetry(t *testing.T) {
+	t.Run("present when debug is set", func(t *testing.T) {
+		llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_requests_total[5m]))`, Confidence: 0.9}}
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show error rate", Debug: true})
+		require.NoError(t, err)
+
+		telemetry, ok := response.Metadata["telemetry"].(*QueryTelemetry)
+		require.True(t, ok, "telemetry should be in the response metadata")
+		assert.Equal(t, cacheLookupMiss, telemetry.CacheLookup)
+		assert.Equal(t, "errors", telemetry.IntentType)
+		assert.Greater(t, telemetry.IntentConfidence, 0.0)
+		assert.False(t, telemetry.DirectQuery)
+		assert.Greater(t, telemetry.PromptTokensEstimate, 0)
+		assert.Equal(t, "passed", telemetry.SafetyOutcome)
+		assert.Contains(t, telemetry.StageTimingsMs, "llm_ms")
+
+		encoded, err := json.Marshal(telemetry)
+		require.NoError(t, err)
+		var keys map[string]interface{}
+		require.NoError(t, json.Unmarshal(encoded, &keys))
+		for _, key := range []string{
+			"cache_lookup", "intent_type", "intent_confidence", "direct_query", "similar_queries",
+			"prompt_tokens_estimate", "llm_latency_ms", "safety_outcome", "stage_timings_ms",
+		} {
+			assert.Contains(t, keys, key)
+		}
+	})
+
+	t.Run("absent by default and not cached", func(t *testing.T) {
+		llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_requests_total[5m]))`, Confidence: 0.9}}
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show error rate", Debug: true})
+		require.NoError(t, err)
+		assert.Contains(t, response.Metadata, "telemetry")
+
+		// The cached result was stored without the first request's telemetry
+		response, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show error rate"})
+		require.NoError(t, err)
+		require.True(t, response.CacheHit)
+		assert.NotContains(t, response.Metadata, "telemetry")
+
+		response, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show error rate", Debug: true})
+		require.NoError(t, err)
+		require.True(t, response.CacheHit)
+		assert.Equal(t, cacheLookupHit, response.Metadata["telemetry"].(*QueryTelemetry).CacheLookup)
+	})
+
+	t.Run("debug query parameter", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(http_requests_total[5m]))`, Confidence: 0.9}}
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+		router := qp.SetupRoutes(nil)
+
+		body, err := json.Marshal(QueryRequest{Query: "show error rate"})
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/query?debug=true", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Metadata map[string]interface{} `json:"metadata"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Contains(t, response.Metadata, "telemetry")
+	})
+}
+
+// TestQueryTelemetryOnError tests that failed debug requests return the
+// telemetry in the error metadata with the safety outcome reached
+func TestQueryTelemetryOnError(t *testing.T) {
+	t.Run("safety rejection", func(t *testing.T) {
+		llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum(rate(user_password_resets_total[5m]))`, Confidence: 0.9}}
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+
+		_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show error rate", Debug: true})
+		require.Error(t, err)
+		enhancedErr, ok := err.(*errors.EnhancedError)
+		require.True(t, ok, "expected an enhanced error, got %T", err)
+		telemetry, ok := enhancedErr.Metadata["telemetry"].(*QueryTelemetry)
+		require.True(t, ok, "telemetry should be in the error metadata")
+		assert.Equal(t, cacheLookupMiss, telemetry.CacheLookup)
+		assert.Equal(t, safetyOutcomeRejected, telemetry.SafetyOutcome)
+		assert.Contains(t, telemetry.StageTimingsMs, "safety_validation_ms")
+	})
+
+	t.Run("generation failure", func(t *testing.T) {
+		llmClient := &MockLLMClient{err: fmt.Errorf("model unavailable")}
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+
+		_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show error rate", Debug: true})
+		require.Error(t, err)
+		enhancedErr, ok := err.(*errors.EnhancedError)
+		require.True(t, ok, "expected an enhanced error, got %T", err)
+		telemetry, ok := enhancedErr.Metadata["telemetry"].(*QueryTelemetry)
+		require.True(t, ok, "telemetry should be in the error metadata")
+		assert.Equal(t, safetyOutcomeNotRun, telemetry.SafetyOutcome)
+		assert.Greater(t, telemetry.PromptTokensEstimate, 0)
+		assert.Contains(t, telemetry.StageTimingsMs, "llm_ms")
+	})
+
+	t.Run("absent without debug", func(t *testing.T) {
+		llmClient := &MockLLMClient{err: fmt.Errorf("model unavailable")}
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+
+		_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "show error rate"})
+		require.Error(t, err)
+		enhancedErr, ok := err.(*errors.EnhancedError)
+		require.True(t, ok, "expected an enhanced error, got %T", err)
+		assert.NotContains(t, enhancedErr.Metadata, "telemetry")
+	})
+}
@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+)
+
+// TenantResolver is implemented by auth middleware that can bind a request's
+// credentials to a single Mimir tenant. Bindings are enforced when the tenant
+// is selected for a query.
+type TenantResolver interface {
+	BoundTenant(c *gin.Context) (string, bool)
+}
+
+// tenantBindingKey is the context key holding the caller's bound Mimir tenant
+type tenantBindingKey struct{}
+
+// withTenantBinding returns a context carrying the caller's bound Mimir tenant
+func withTenantBinding(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantBindingKey{}, tenant)
+}
+
+// tenantBinding returns the Mimir tenant the caller is bound to, if any
+func tenantBinding(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantBindingKey{}).(string)
+	return tenant
+}
+
+// tenantBindingMiddleware copies the authenticated caller's tenant binding into
+// the request context so it applies to every query the request processes
+func tenantBindingMiddleware(resolver TenantResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tenant, ok := resolver.BoundTenant(c); ok {
+			c.Request = c.Request.WithContext(withTenantBinding(c.Request.Context(), tenant))
+		}
+		c.Next()
+	}
+}
+
+// SetTenantDescribers registers request describers for Mimir tenants other than
+// the default one, which uses the describer from SetRequestDescriber. Requests
+// may only select a registered tenant.
+func (qp *QueryProcessor) SetTenantDescribers(defaultTenant string, describers map[string]RequestDescriber) {
+	qp.defaultTenant = defaultTenant
+	qp.tenantDescribers = describers
+}
+
+// resolveTenant determines the Mimir tenant a request targets. A caller bound
+// to a tenant may only target that tenant. The empty string denotes the
+// default tenant.
+func (qp *QueryProcessor) resolveTenant(ctx context.Context, requested string) (string, error) {
+	bound := tenantBinding(ctx)
+	if bound != "" && requested != "" && requested != bound {
+		return "", errors.New(errors.ErrCodeInsufficientPerms, "Mimir tenant not permitted").
+			WithDetails(fmt.Sprintf("These credentials are bound to a different tenant and cannot query tenant %q", requested)).
+			WithSuggestion("Omit the tenant field or use credentials bound to the requested tenant.").
+			WithMetadata("tenant", requested)
+	}
+
+	tenant := requested
+	if tenant == "" {
+		tenant = bound
+	}
+	if tenant == "" || tenant == qp.defaultTenant {
+		return "", nil
+	}
+
+	if _, ok := qp.tenantDescribers[tenant]; !ok {
+		if bound != "" {
+			return "", errors.New(errors.ErrCodeInsufficientPerms, "Mimir tenant not configured").
+				WithDetails(fmt.Sprintf("These credentials are bound to tenant %q, which is not configured", tenant)).
+				WithSuggestion("Contact your administrator to configure the tenant or rebind the credentials.")
+		}
+		return "", errors.NewInvalidInputError("tenant", fmt.Sprintf("unknown tenant %q; configured tenants: %s", tenant, strings.Join(qp.tenantNames(), ", ")))
+	}
+	return tenant, nil
+}
+
+// describerFor returns the request describer for a resolved tenant
+func (qp *QueryProcessor) describerFor(tenant string) RequestDescriber {
+	if tenant == "" {
+		return qp.requestDescriber
+	}
+	return qp.tenantDescribers[tenant]
+}
+
+// tenantNames returns the sorted names of all selectable tenants
+func (qp *QueryProcessor) tenantNames() []string {
+	names := make([]string, 0, len(qp.tenantDescribers)+1)
+	if qp.defaultTenant != "" {
+		names = append(names, qp.defaultTenant)
+	}
+	for name := range qp.tenantDescribers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
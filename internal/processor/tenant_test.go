@@ -0,0 +1,171 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/auth"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/mimir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tenantDescriber describes requests as targeting a fixed tenant
+type tenantDescriber struct {
+	tenant string
+}
+
+func (d tenantDescriber) DescribeQuery(query string, timestamp time.Time) *mimir.RequestInfo {
+	return &mimir.RequestInfo{Method: "GET", Endpoint: d.tenant, Params: map[string]string{"query": query}}
+}
+
+func (d tenantDescriber) DescribeQueryRange(query string, start, end time.Time, step time.Duration) *mimir.RequestInfo {
+	return &mimir.RequestInfo{Method: "GET", Endpoint: d.tenant, Params: map[string]string{"query": query}}
+}
+
+// TestTenantBinding tests that API keys bound to a Mimir tenant cannot target another tenant
+func TestTenantBinding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	am := auth.NewTestAuthManager(auth.AuthConfig{JWTSecret: "test-secret", RateLimit: 100})
+	user, err := am.CreateUser("tenant-user", "tenant@example.com", []string{"user"})
+	require.NoError(t, err)
+	boundKey, err := am.CreateAPIKey(user.ID, "tenant-a-key", []string{"read"}, 100, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, am.BindAPIKeyTenant(boundKey.ID, "tenant-a"))
+	unboundKey, err := am.CreateAPIKey(user.ID, "unbound-key", []string{"read"}, 100, time.Hour)
+	require.NoError(t, err)
+
+	llmClient := &MockLLMClient{
+		response: &llm.Response{PromQL: `sum(rate(http_requests_total[5m]))`, Confidence: 0.9},
+	}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+	qp.SetRequestDescriber(tenantDescriber{tenant: "default"})
+	qp.SetTenantDescribers("default", map[string]RequestDescriber{
+		"tenant-a": tenantDescriber{tenant: "tenant-a"},
+		"tenant-b": tenantDescriber{tenant: "tenant-b"},
+	})
+	// The test user is not an admin; show it the Mimir request metadata
+	qp.SetAdminOnlyMetadata(nil)
+	router := qp.SetupRoutes(am)
+
+	query := func(key, tenant string) (int, map[string]interface{}) {
+		body, err := json.Marshal(QueryRequest{Query: "request rate", Tenant: tenant})
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/query", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return w.Code, response
+	}
+
+	t.Run("bound key cannot target another tenant", func(t *testing.T) {
+		code, response := query(boundKey.Key, "tenant-b")
+		assert.Equal(t, http.StatusForbidden, code)
+		assert.Equal(t, "INSUFFICIENT_PERMISSIONS", response["error"].(map[string]interface{})["code"])
+	})
+
+	t.Run("bound key cannot target the default tenant", func(t *testing.T) {
+		code, _ := query(boundKey.Key, "default")
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+
+	t.Run("bound key uses its tenant by default", func(t *testing.T) {
+		code, response := query(boundKey.Key, "")
+		require.Equal(t, http.StatusOK, code)
+		metadata := response["metadata"].(map[string]interface{})
+		assert.Equal(t, "tenant-a", metadata["tenant"])
+		assert.Equal(t, "tenant-a", metadata["mimir_request"].(map[string]interface{})["endpoint"])
+	})
+
+	t.Run("bound key may name its own tenant", func(t *testing.T) {
+		code, response := query(boundKey.Key, "tenant-a")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "tenant-a", response["metadata"].(map[string]interface{})["tenant"])
+	})
+
+	t.Run("unbound key may select a configured tenant", func(t *testing.T) {
+		code, response := query(unboundKey.Key, "tenant-b")
+		require.Equal(t, http.StatusOK, code)
+		metadata := response["metadata"].(map[string]interface{})
+		assert.Equal(t, "tenant-b", metadata["tenant"])
+		assert.Equal(t, "tenant-b", metadata["mimir_request"].(map[string]interface{})["endpoint"])
+	})
+
+	t.Run("unbound key uses the default tenant without sharing cached results", func(t *testing.T) {
+		code, response := query(unboundKey.Key, "")
+		require.Equal(t, http.StatusOK, code)
+		metadata := response["metadata"].(map[string]interface{})
+		assert.NotContains(t, metadata, "tenant")
+		assert.Equal(t, "default", metadata["mimir_request"].(map[string]interface{})["endpoint"])
+	})
+
+	t.Run("unknown tenant is rejected", func(t *testing.T) {
+		code, response := query(unboundKey.Key, "tenant-c")
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "INVALID_INPUT", response["error"].(map[string]interface{})["code"])
+	})
+}
+
+// TestTenantCacheIsolation tests that identical queries for different tenants
+// are cached separately and never served from another tenant's entry
+func TestTenantCacheIsolation(t *testing.T) {
+	llmClient := &MockLLMClient{
+		response: &llm.Response{PromQL: `sum(rate(tenant_a_requests_total[5m]))`, Confidence: 0.9},
+	}
+	mr := miniredis.RunT(t)
+	qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	qp.SetTenantDescribers("default", map[string]RequestDescriber{
+		"tenant-a": tenantDescriber{tenant: "tenant-a"},
+		"tenant-b": tenantDescriber{tenant: "tenant-b"},
+	})
+	ctx := context.Background()
+
+	first, err := qp.ProcessQuery(ctx, &QueryRequest{Query: "request rate", Tenant: "tenant-a"})
+	require.NoError(t, err)
+	assert.False(t, first.CacheHit)
+
+	llmClient.response = &llm.Response{PromQL: `sum(rate(tenant_b_requests_total[5m]))`, Confidence: 0.9}
+	second, err := qp.ProcessQuery(ctx, &QueryRequest{Query: "request rate", Tenant: "tenant-b"})
+	require.NoError(t, err)
+	assert.False(t, second.CacheHit, "tenant-b must not be served tenant-a's result")
+	assert.Equal(t, `sum(rate(tenant_b_requests_total[5m]))`, second.PromQL)
+
+	assert.Len(t, mr.Keys(), 2, "each tenant has its own cache entry")
+
+	cached, err := qp.ProcessQuery(ctx, &QueryRequest{Query: "request rate", Tenant: "tenant-a"})
+	require.NoError(t, err)
+	assert.True(t, cached.CacheHit)
+	assert.Equal(t, `sum(rate(tenant_a_requests_total[5m]))`, cached.PromQL)
+
+	// Query text naming a tenant cannot reach that tenant's entries
+	llmClient.response = &llm.Response{PromQL: `sum(rate(default_requests_total[5m]))`, Confidence: 0.9}
+	forged, err := qp.ProcessQuery(ctx, &QueryRequest{Query: "tenant-a/request rate"})
+	require.NoError(t, err)
+	assert.False(t, forged.CacheHit)
+	assert.Equal(t, `sum(rate(default_requests_total[5m]))`, forged.PromQL)
+}
+
+// TestCacheQueryTenantScope tests that cache identities are scoped by tenant
+func TestCacheQueryTenantScope(t *testing.T) {
+	req := &QueryRequest{Query: "request rate"}
+
+	assert.NotEqual(t, cacheQuery(req, "tenant-a"), cacheQuery(req, "tenant-b"))
+	assert.NotEqual(t, cacheQuery(req, "tenant-a"), cacheQuery(req, ""))
+	assert.NotEqual(t, cacheQuery(&QueryRequest{Query: "b/request rate"}, "a"), cacheQuery(req, "a/b"))
+	assert.Equal(t, cacheQuery(req, "tenant-a"), cacheQuery(&QueryRequest{Query: "request rate"}, "tenant-a"))
+}
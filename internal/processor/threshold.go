@@ -0,0 +1,231 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/mimir"
+)
+
+// QueryExecutor executes instant PromQL queries against the metrics backend
+type QueryExecutor interface {
+	Query(ctx context.Context, query string, timestamp time.Time) (*mimir.QueryResponse, error)
+}
+
+// defaultComparison is the comparison of thresholds given without one
+const defaultComparison = ">"
+
+// thresholdComparisons are the supported threshold comparisons
+var thresholdComparisons = map[string]func(value, threshold float64) bool{
+	">":  func(value, threshold float64) bool { return value > threshold },
+	">=": func(value, threshold float64) bool { return value >= threshold },
+	"<":  func(value, threshold float64) bool { return value < threshold },
+	"<=": func(value, threshold float64) bool { return value <= threshold },
+}
+
+// ThresholdResult is a request's threshold evaluated against the latest value
+// of the generated query's result
+type ThresholdResult struct {
+	Threshold  float64 `json:"threshold"`
+	Comparison string  `json:"comparison"`
+	Breached   bool    `json:"threshold_breached"`
+
+	// Value is the latest value closest to breaching the threshold: the
+	// highest for ">" and ">=", the lowest for "<" and "<=". Labels are
+	// those of its series.
+	Value  float64           `json:"value"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Series int               `json:"series"` // Series evaluated
+}
+
+// SetQueryExecutor enables evaluating request thresholds by executing the
+// generated query. Tenants other than the default are queried through their
+// describers when those can execute queries, as *mimir.Client can.
+func (qp *QueryProcessor) SetQueryExecutor(executor QueryExecutor) {
+	qp.queryExecutor = executor
+}
+
+// executorFor returns the executor of the tenant's queries, or nil if the
+// tenant's queries cannot be executed
+func (qp *QueryProcessor) executorFor(tenant string) QueryExecutor {
+	if tenant == "" {
+		return qp.queryExecutor
+	}
+	executor, _ := qp.tenantDescribers[tenant].(QueryExecutor)
+	return executor
+}
+
+// thresholdComparison returns the request's threshold comparison
+func thresholdComparison(req *QueryRequest) string {
+	if req.Comparison == "" {
+		return defaultComparison
+	}
+	return req.Comparison
+}
+
+// validateThreshold checks the request's threshold and comparison
+func validateThreshold(req *QueryRequest) error {
+	if req.Threshold == nil {
+		if req.Comparison != "" {
+			return errors.NewInvalidInputError("comparison", "comparison requires a threshold")
+		}
+		return nil
+	}
+	if _, ok := thresholdComparisons[thresholdComparison(req)]; !ok {
+		return errors.NewInvalidInputError("comparison",
+			fmt.Sprintf("unknown comparison %q; supported comparisons: >, >=, <, <=", req.Comparison))
+	}
+	return nil
+}
+
+// evaluateThreshold executes the response's query and compares its latest
+// value against the request's threshold. The generated query stands on its
+// own, so failures are reported in the response metadata under
+// "threshold_error" rather than failing the request.
+func (qp *QueryProcessor) evaluateThreshold(ctx context.Context, req *QueryRequest, response *QueryResponse) {
+	result, err := qp.thresholdResult(ctx, req, response.PromQL)
+	if err != nil {
+		qp.logger.Warn(ctx, "Threshold evaluation failed", map[string]interface{}{
+			"query": req.Query,
+			"error": err.Error(),
+		})
+		if response.Metadata == nil {
+			response.Metadata = make(map[string]interface{})
+		}
+		response.Metadata["threshold_error"] = err.Error()
+		return
+	}
+	response.Threshold = result
+	qp.formatThresholdResult(response)
+}
+
+// thresholdResult evaluates the request's threshold against the latest value
+// of promql
+func (qp *QueryProcessor) thresholdResult(ctx context.Context, req *QueryRequest, promql string) (*ThresholdResult, error) {
+	tenant, err := qp.resolveTenant(ctx, req.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	executor := qp.executorFor(tenant)
+	if executor == nil {
+		return nil, fmt.Errorf("query execution is not configured")
+	}
+
+	resp, err := executor.Query(ctx, promql, time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	samples, err := latestSamples(resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("the query returned no data")
+	}
+
+	comparison := thresholdComparison(req)
+	compare := thresholdComparisons[comparison]
+	closest := samples[0]
+	for _, sample := range samples[1:] {
+		// A sample closer to breaching compares true against the closest so far
+		if compare(sample.value, closest.value) {
+			closest = sample
+		}
+	}
+
+	return &ThresholdResult{
+		Threshold:  *req.Threshold,
+		Comparison: comparison,
+		Breached:   compare(closest.value, *req.Threshold),
+		Value:      closest.value,
+		Labels:     closest.labels,
+		Series:     len(samples),
+	}, nil
+}
+
+// sample is the latest value of a series
+type sample struct {
+	labels map[string]string
+	value  float64
+}
+
+// latestSamples returns the latest value of each series of a query result,
+// skipping NaN values. Series are ordered by their labels.
+func latestSamples(resp *mimir.QueryResponse) ([]sample, error) {
+	var samples []sample
+	add := func(labels map[string]string, point interface{}) error {
+		value, err := pointValue(point)
+		if err != nil {
+			return err
+		}
+		if !math.IsNaN(value) {
+			samples = append(samples, sample{labels: labels, value: value})
+		}
+		return nil
+	}
+
+	switch resp.Data.ResultType {
+	case "scalar":
+		if err := add(nil, resp.Data.Result); err != nil {
+			return nil, err
+		}
+	case "vector", "matrix":
+		series, ok := resp.Data.Result.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected %s result", resp.Data.ResultType)
+		}
+		for _, s := range series {
+			entry, ok := s.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("unexpected %s result", resp.Data.ResultType)
+			}
+			labels := make(map[string]string)
+			if metric, ok := entry["metric"].(map[string]interface{}); ok {
+				for name, value := range metric {
+					labels[name] = fmt.Sprint(value)
+				}
+			}
+
+			point := entry["value"]
+			if resp.Data.ResultType == "matrix" {
+				values, _ := entry["values"].([]interface{})
+				if len(values) == 0 {
+					continue
+				}
+				point = values[len(values)-1]
+			}
+			if err := add(labels, point); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("cannot compare a %s result against a threshold", resp.Data.ResultType)
+	}
+
+	sort.Slice(samples, func(i, j int) bool {
+		return fmt.Sprint(samples[i].labels) < fmt.Sprint(samples[j].labels)
+	})
+	return samples, nil
+}
+
+// pointValue returns the value of a [timestamp, "value"] point
+func pointValue(point interface{}) (float64, error) {
+	pair, ok := point.([]interface{})
+	if !ok || len(pair) != 2 {
+		return 0, fmt.Errorf("unexpected sample %v", point)
+	}
+	text, ok := pair[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected sample value %v", pair[1])
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sample value %q: %w", text, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,133 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/mimir"
+	"github.com/seanankenbruck/observability-ai/internal/semantic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockQueryExecutor returns a fixed Mimir result
+type mockQueryExecutor struct {
+	result string // JSON of the response's data
+	err    error
+	calls  int
+}
+
+func (m *mockQueryExecutor) Query(ctx context.Context, query string, timestamp time.Time) (*mimir.QueryResponse, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	resp := &mimir.QueryResponse{Status: "success"}
+	if err := json.Unmarshal([]byte(m.result), &resp.Data); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// TestThresholdEvaluation tests that the latest result value is compared
+// against the request's threshold
+func TestThresholdEvaluation(t *testing.T) {
+	mapper := &MockSemanticMapper{services: []semantic.Service{
+		{ID: "svc-1", Name: "api", Namespace: "default", MetricNames: []string{"http_requests_total"}},
+	}}
+	llmClient := &MockLLMClient{response: &llm.Response{PromQL: `sum by (service) (rate(http_requests_total{status=~"5.."}[5m]))`, Confidence: 0.8}}
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	executor := &mockQueryExecutor{}
+	qp := NewQueryProcessor(llmClient, mapper, cache)
+	qp.SetQueryExecutor(executor)
+
+	threshold := func(v float64) *float64 { return &v }
+	vector := `{"resultType": "vector", "result": [
+		{"metric": {"service": "api"}, "value": [1700000000, "0.08"]},
+		{"metric": {"service": "worker"}, "value": [1700000000, "0.01"]}
+	]}`
+
+	tests := []struct {
+		name       string
+		result     string
+		threshold  float64
+		comparison string
+		breached   bool
+		value      float64
+		labels     map[string]string
+	}{
+		{"above the threshold", vector, 0.05, "", true, 0.08, map[string]string{"service": "api"}},
+		{"below the threshold", vector, 0.1, ">", false, 0.08, map[string]string{"service": "api"}},
+		{"lowest value for less than", vector, 0.02, "<", true, 0.01, map[string]string{"service": "worker"}},
+		{"scalar", `{"resultType": "scalar", "result": [1700000000, "3"]}`, 3, ">=", true, 3, nil},
+		{"latest value of a matrix", `{"resultType": "matrix", "result": [
+			{"metric": {"service": "api"}, "values": [[1700000000, "0.5"], [1700000060, "0.02"]]}
+		]}`, 0.05, ">", false, 0.02, map[string]string{"service": "api"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor.result = tt.result
+			response, err := qp.ProcessQuery(context.Background(), &QueryRequest{
+				Query:      "error rate by service",
+				Threshold:  threshold(tt.threshold),
+				Comparison: tt.comparison,
+			})
+			require.NoError(t, err)
+			require.NotNil(t, response.Threshold, "metadata: %v", response.Metadata)
+			assert.Equal(t, tt.breached, response.Threshold.Breached)
+			assert.Equal(t, tt.value, response.Threshold.Value)
+			assert.Equal(t, tt.labels, response.Threshold.Labels)
+		})
+	}
+
+	t.Run("evaluated on cache hits", func(t *testing.T) {
+		executor.result = vector
+		calls := executor.calls
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "error rate by service", Threshold: threshold(0.05)})
+		require.NoError(t, err)
+		assert.True(t, response.CacheHit)
+		assert.Equal(t, calls+1, executor.calls)
+		require.NotNil(t, response.Threshold)
+		assert.True(t, response.Threshold.Breached)
+	})
+
+	t.Run("not evaluated without a threshold", func(t *testing.T) {
+		calls := executor.calls
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "error rate by service"})
+		require.NoError(t, err)
+		assert.Nil(t, response.Threshold)
+		assert.Equal(t, calls, executor.calls)
+	})
+
+	t.Run("execution failures keep the query", func(t *testing.T) {
+		executor.err = fmt.Errorf("connection refused")
+		defer func() { executor.err = nil }()
+
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "error rate by service", Threshold: threshold(0.05)})
+		require.NoError(t, err)
+		assert.Equal(t, llmClient.response.PromQL, response.PromQL)
+		assert.Nil(t, response.Threshold)
+		assert.Contains(t, response.Metadata["threshold_error"], "connection refused")
+	})
+
+	t.Run("invalid comparisons are rejected", func(t *testing.T) {
+		for _, req := range []*QueryRequest{
+			{Query: "error rate by service", Threshold: threshold(0.05), Comparison: "above"},
+			{Query: "error rate by service", Comparison: ">"},
+		} {
+			_, err := qp.ProcessQuery(context.Background(), req)
+			require.Error(t, err)
+			enhanced, ok := err.(*errors.EnhancedError)
+			require.True(t, ok)
+			assert.Equal(t, errors.ErrCodeInvalidInput, enhanced.Code)
+		}
+	})
+}
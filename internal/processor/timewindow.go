@@ -0,0 +1,189 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Absolute time windows such as "between 2pm and 4pm yesterday" or
+// "from 2024-01-01 to 2024-01-03" are resolved to concrete start and end
+// timestamps in the classifier's location, for execution as range queries.
+
+// clockPattern matches a time of day: "2pm", "2:30 pm", "14:00", "noon", "midnight"
+const clockPattern = `(\d{1,2}(?::\d{2})?\s*(?:am|pm)?|noon|midnight)`
+
+// Patterns for absolute windows, registered with the intent classifier
+var (
+	clockWindowPattern = regexp.MustCompile(`(?i)\b(?:between|from)\s+` + clockPattern + `\s+(?:and|to|until)\s+` + clockPattern +
+		`(?:\s+(?:on\s+)?(today|yesterday|\d{4}-\d{2}-\d{2}))?`)
+	dateWindowPattern = regexp.MustCompile(`(?i)\b(?:between|from)\s+(\d{4}-\d{2}-\d{2})\s+(?:and|to|until)\s+(\d{4}-\d{2}-\d{2})\b`)
+	clockPartsPattern = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+)
+
+// rangeQuerySteps are the candidate range query resolutions, smallest first
+var rangeQuerySteps = []time.Duration{
+	15 * time.Second, 30 * time.Second,
+	time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 2 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+}
+
+// maxRangeQueryPoints bounds the number of points a range query returns per series
+const maxRangeQueryPoints = 250
+
+// extractAbsoluteWindow sets the intent's start and end from an absolute time
+// phrase in query. Phrases that do not describe a valid window are ignored.
+func (ic *IntentClassifier) extractAbsoluteWindow(query string, intent *QueryIntent) {
+	now := ic.now().In(ic.location)
+
+	if match := ic.patterns["date_window"].FindStringSubmatch(query); match != nil {
+		first, err1 := time.ParseInLocation("2006-01-02", match[1], ic.location)
+		last, err2 := time.ParseInLocation("2006-01-02", match[2], ic.location)
+		if err1 != nil || err2 != nil || last.Before(first) {
+			return
+		}
+		// The end date is inclusive
+		end := last.AddDate(0, 0, 1)
+		intent.Start, intent.End = &first, &end
+		return
+	}
+
+	match := ic.patterns["clock_window"].FindStringSubmatch(query)
+	if match == nil {
+		return
+	}
+
+	startText, endText := strings.ToLower(strings.TrimSpace(match[1])), strings.ToLower(strings.TrimSpace(match[2]))
+	// Bare hours are only times when the other end qualifies them ("between 2 and 4pm")
+	startMeridiem, endMeridiem := meridiem(startText), meridiem(endText)
+	if startMeridiem == "" && endMeridiem == "" && !isClockQualified(startText) && !isClockQualified(endText) {
+		return
+	}
+	inheritedStart := startMeridiem == ""
+	if inheritedStart {
+		startMeridiem = endMeridiem
+	}
+	if endMeridiem == "" {
+		endMeridiem = startMeridiem
+	}
+
+	startHour, startMinute, ok := parseClock(startText, startMeridiem)
+	if !ok {
+		return
+	}
+	endHour, endMinute, ok := parseClock(endText, endMeridiem)
+	if !ok {
+		return
+	}
+	if inheritedStart && startMeridiem == "pm" && startHour > endHour {
+		// "between 10 and 2pm" means 10am, not a window crossing midnight
+		startHour -= 12
+	}
+
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, ic.location)
+	explicitDay := true
+	switch dayText := strings.ToLower(match[3]); dayText {
+	case "", "today":
+		explicitDay = dayText != ""
+	case "yesterday":
+		day = day.AddDate(0, 0, -1)
+	default:
+		parsed, err := time.ParseInLocation("2006-01-02", dayText, ic.location)
+		if err != nil {
+			return
+		}
+		day = parsed
+	}
+
+	// Clock times are wall times of the day, which need not be 24 hours long
+	start := time.Date(day.Year(), day.Month(), day.Day(), startHour, startMinute, 0, 0, ic.location)
+	end := time.Date(day.Year(), day.Month(), day.Day(), endHour, endMinute, 0, 0, ic.location)
+	if !end.After(start) {
+		// The window crosses midnight, e.g. "between 10pm and 2am"
+		end = end.AddDate(0, 0, 1)
+	}
+	if !explicitDay && start.After(now) {
+		// Without a day, a window that has not started yet refers to yesterday
+		start, end = start.AddDate(0, 0, -1), end.AddDate(0, 0, -1)
+	}
+
+	intent.Start, intent.End = &start, &end
+}
+
+// meridiem returns "am" or "pm" if the clock text carries one
+func meridiem(text string) string {
+	switch {
+	case strings.HasSuffix(text, "am"):
+		return "am"
+	case strings.HasSuffix(text, "pm"):
+		return "pm"
+	}
+	return ""
+}
+
+// isClockQualified reports whether clock text is unambiguously a time of day
+// without a meridiem, e.g. "14:00" or "noon"
+func isClockQualified(text string) bool {
+	return strings.Contains(text, ":") || text == "noon" || text == "midnight"
+}
+
+// parseClock converts clock text to a 24-hour time, using the given meridiem
+// for text that has none
+func parseClock(text, defaultMeridiem string) (hour, minute int, ok bool) {
+	switch text {
+	case "noon":
+		return 12, 0, true
+	case "midnight":
+		return 0, 0, true
+	}
+
+	parts := clockPartsPattern.FindStringSubmatch(text)
+	if parts == nil {
+		return 0, 0, false
+	}
+	hour, _ = strconv.Atoi(parts[1])
+	if parts[2] != "" {
+		minute, _ = strconv.Atoi(parts[2])
+	}
+	if minute > 59 {
+		return 0, 0, false
+	}
+
+	suffix := parts[3]
+	if suffix == "" && hour >= 1 && hour <= 12 {
+		suffix = defaultMeridiem
+	}
+	switch suffix {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		hour %= 12
+		if suffix == "pm" {
+			hour += 12
+		}
+	default:
+		if hour > 23 {
+			return 0, 0, false
+		}
+	}
+	return hour, minute, true
+}
+
+// rangeQueryStep returns the range query resolution for a window, keeping the
+// number of points per series at or below maxRangeQueryPoints
+func rangeQueryStep(window time.Duration) time.Duration {
+	for _, step := range rangeQuerySteps {
+		if window/step <= maxRangeQueryPoints {
+			return step
+		}
+	}
+	return rangeQuerySteps[len(rangeQuerySteps)-1]
+}
+
+// formatWindow renders an absolute window for prompts and logs
+func formatWindow(start, end time.Time) string {
+	return fmt.Sprintf("%s to %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+}
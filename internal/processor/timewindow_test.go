@@ -0,0 +1,226 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/seanankenbruck/observability-ai/internal/errors"
+	"github.com/seanankenbruck/observability-ai/internal/llm"
+	"github.com/seanankenbruck/observability-ai/internal/mimir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rangeDescriber records the window of described range queries
+type rangeDescriber struct {
+	start, end time.Time
+	step       time.Duration
+}
+
+func (d *rangeDescriber) DescribeQuery(query string, timestamp time.Time) *mimir.RequestInfo {
+	return &mimir.RequestInfo{Method: "GET", Endpoint: "/api/v1/query", Params: map[string]string{"query": query}}
+}
+
+func (d *rangeDescriber) DescribeQueryRange(query string, start, end time.Time, step time.Duration) *mimir.RequestInfo {
+	d.start, d.end, d.step = start, end, step
+	return &mimir.RequestInfo{Method: "GET", Endpoint: "/api/v1/query_range", Params: map[string]string{"query": query}}
+}
+
+// TestExtractAbsoluteWindow tests that absolute time phrases resolve to start and end times
+func TestExtractAbsoluteWindow(t *testing.T) {
+	location := time.FixedZone("EST", -5*60*60)
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, location)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 3, day, hour, minute, 0, 0, location)
+	}
+
+	ic := NewIntentClassifier()
+	ic.SetLocation(location)
+	ic.now = func() time.Time { return now }
+
+	tests := []struct {
+		name          string
+		query         string
+		expectedStart time.Time
+		expectedEnd   time.Time
+	}{
+		{
+			name:          "meridiem times yesterday",
+			query:         "error rate between 2pm and 4pm yesterday",
+			expectedStart: at(9, 14, 0),
+			expectedEnd:   at(9, 16, 0),
+		},
+		{
+			name:          "24-hour times today",
+			query:         "latency from 14:00 to 16:00 today",
+			expectedStart: at(10, 14, 0),
+			expectedEnd:   at(10, 16, 0),
+		},
+		{
+			name:          "start inherits end meridiem",
+			query:         "requests between 2 and 4pm",
+			expectedStart: at(10, 14, 0),
+			expectedEnd:   at(10, 16, 0),
+		},
+		{
+			name:          "inherited meridiem does not cross midnight",
+			query:         "requests between 10 and 2pm",
+			expectedStart: at(10, 10, 0),
+			expectedEnd:   at(10, 14, 0),
+		},
+		{
+			name:          "noon and minutes on a date",
+			query:         "cpu usage from noon to 1:30pm on 2024-03-01",
+			expectedStart: at(1, 12, 0),
+			expectedEnd:   at(1, 13, 30),
+		},
+		{
+			name:          "window not yet started refers to yesterday",
+			query:         "errors between 4pm and 5pm",
+			expectedStart: at(9, 16, 0),
+			expectedEnd:   at(9, 17, 0),
+		},
+		{
+			name:          "window crossing midnight",
+			query:         "errors between 10pm and 2am",
+			expectedStart: at(9, 22, 0),
+			expectedEnd:   at(10, 2, 0),
+		},
+		{
+			name:          "date range includes the end date",
+			query:         "memory usage from 2024-01-01 to 2024-01-03",
+			expectedStart: time.Date(2024, 1, 1, 0, 0, 0, 0, location),
+			expectedEnd:   time.Date(2024, 1, 4, 0, 0, 0, 0, location),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			intent, err := ic.ClassifyIntent(tt.query)
+			require.NoError(t, err)
+			require.NotNil(t, intent.Start)
+			require.NotNil(t, intent.End)
+			assert.True(t, tt.expectedStart.Equal(*intent.Start), "start: expected %s, got %s", tt.expectedStart, intent.Start)
+			assert.True(t, tt.expectedEnd.Equal(*intent.End), "end: expected %s, got %s", tt.expectedEnd, intent.End)
+		})
+	}
+
+	for _, query := range []string{
+		"between 2 and 4 errors per second",
+		"error rate over the last 2 hours",
+		"from 2024-01-03 to 2024-01-01",
+	} {
+		t.Run("no window: "+query, func(t *testing.T) {
+			intent, err := ic.ClassifyIntent(query)
+			require.NoError(t, err)
+			assert.Nil(t, intent.Start)
+			assert.Nil(t, intent.End)
+		})
+	}
+}
+
+// TestExtractAbsoluteWindowDST tests that clock times keep their wall time on
+// days when daylight saving time starts or ends
+func TestExtractAbsoluteWindowDST(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+
+	ic := NewIntentClassifier()
+	ic.SetLocation(location)
+
+	tests := []struct {
+		name  string
+		now   time.Time
+		query string
+		start time.Time
+		end   time.Time
+	}{
+		{
+			name:  "clocks spring forward",
+			now:   time.Date(2024, 3, 10, 18, 0, 0, 0, location),
+			query: "error rate between 2pm and 4pm today",
+			start: time.Date(2024, 3, 10, 14, 0, 0, 0, location),
+			end:   time.Date(2024, 3, 10, 16, 0, 0, 0, location),
+		},
+		{
+			name:  "clocks fall back",
+			now:   time.Date(2024, 11, 3, 18, 0, 0, 0, location),
+			query: "error rate between 2pm and 4pm today",
+			start: time.Date(2024, 11, 3, 14, 0, 0, 0, location),
+			end:   time.Date(2024, 11, 3, 16, 0, 0, 0, location),
+		},
+		{
+			name:  "window crossing midnight into the change",
+			now:   time.Date(2024, 3, 10, 18, 0, 0, 0, location),
+			query: "errors between 10pm and 4am on 2024-03-09",
+			start: time.Date(2024, 3, 9, 22, 0, 0, 0, location),
+			end:   time.Date(2024, 3, 10, 4, 0, 0, 0, location),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ic.now = func() time.Time { return tt.now }
+			intent, err := ic.ClassifyIntent(tt.query)
+			require.NoError(t, err)
+			require.NotNil(t, intent.Start)
+			require.NotNil(t, intent.End)
+			assert.True(t, tt.start.Equal(*intent.Start), "start: expected %s, got %s", tt.start, intent.Start)
+			assert.True(t, tt.end.Equal(*intent.End), "end: expected %s, got %s", tt.end, intent.End)
+		})
+	}
+}
+
+// TestRangeQueryStep tests that range query steps bound the number of points
+func TestRangeQueryStep(t *testing.T) {
+	assert.Equal(t, 15*time.Second, rangeQueryStep(time.Hour))
+	assert.Equal(t, 30*time.Second, rangeQueryStep(2*time.Hour))
+	assert.Equal(t, 10*time.Minute, rangeQueryStep(24*time.Hour))
+	assert.Equal(t, time.Hour, rangeQueryStep(7*24*time.Hour))
+}
+
+// TestProcessQueryAbsoluteWindow tests that absolute windows drive range queries and respect the range limit
+func TestProcessQueryAbsoluteWindow(t *testing.T) {
+	newProcessor := func(t *testing.T) (*QueryProcessor, *rangeDescriber) {
+		llmClient := &MockLLMClient{
+			response: &llm.Response{PromQL: `sum(rate(http_requests_total[5m]))`, Confidence: 0.9},
+		}
+		cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		qp := NewQueryProcessor(llmClient, &MockSemanticMapper{}, cache)
+		describer := &rangeDescriber{}
+		qp.SetRequestDescriber(describer)
+		return qp, describer
+	}
+
+	t.Run("window is executed as a range query", func(t *testing.T) {
+		qp, describer := newProcessor(t)
+
+		response, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate from 2024-01-01 to 2024-01-02"})
+		require.NoError(t, err)
+		assert.Equal(t, "/api/v1/query_range", response.Metadata["mimir_request"].(*mimir.RequestInfo).Endpoint)
+		assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), describer.start)
+		assert.Equal(t, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), describer.end)
+		assert.Equal(t, 15*time.Minute, describer.step)
+		assert.False(t, response.CacheHit)
+
+		// Absolute windows are not cached
+		response, err = qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate from 2024-01-01 to 2024-01-02"})
+		require.NoError(t, err)
+		assert.False(t, response.CacheHit)
+	})
+
+	t.Run("window beyond the maximum range is rejected", func(t *testing.T) {
+		qp, _ := newProcessor(t)
+
+		_, err := qp.ProcessQuery(context.Background(), &QueryRequest{Query: "request rate from 2024-01-01 to 2024-01-20"})
+		require.Error(t, err)
+		enhanced, ok := err.(*errors.EnhancedError)
+		require.True(t, ok)
+		assert.Equal(t, errors.ErrCodeExcessiveTimeRange, enhanced.Code)
+	})
+}
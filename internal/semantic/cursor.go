@@ -0,0 +1,49 @@
+package semantic
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QueryCursor is a position in the query history: the last used time and ID
+// of the last stored query of a page. Paging by cursor rather than offset is
+// stable while queries are stored, since newer queries sort before the cursor
+// and do not shift the following pages.
+type QueryCursor struct {
+	LastUsedAt time.Time
+	ID         string
+}
+
+// CursorAfter returns the cursor of the page following sq, or nil if the
+// query has no last used time
+func CursorAfter(sq StoredQuery) *QueryCursor {
+	if sq.LastUsedAt == nil {
+		return nil
+	}
+	return &QueryCursor{LastUsedAt: *sq.LastUsedAt, ID: sq.ID}
+}
+
+// Encode returns the cursor as an opaque URL-safe string
+func (c QueryCursor) Encode() string {
+	raw := c.LastUsedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseQueryCursor decodes a cursor returned by Encode
+func ParseQueryCursor(encoded string) (*QueryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor encoding: %w", err)
+	}
+	timestamp, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, fmt.Errorf("invalid cursor: missing query ID")
+	}
+	lastUsedAt, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor timestamp: %w", err)
+	}
+	return &QueryCursor{LastUsedAt: lastUsedAt, ID: id}, nil
+}
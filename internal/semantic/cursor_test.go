@@ -0,0 +1,31 @@
+package semantic
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestQueryCursor tests that cursors survive encoding with full precision
+func TestQueryCursor(t *testing.T) {
+	lastUsedAt := time.Date(2026, 10, 16, 9, 30, 0, 123456000, time.FixedZone("CEST", 2*60*60))
+	cursor := CursorAfter(StoredQuery{ID: "2c1f4a8e-q", LastUsedAt: &lastUsedAt})
+	require.NotNil(t, cursor)
+
+	parsed, err := ParseQueryCursor(cursor.Encode())
+	require.NoError(t, err)
+	assert.True(t, lastUsedAt.Equal(parsed.LastUsedAt))
+	assert.Equal(t, "2c1f4a8e-q", parsed.ID)
+
+	assert.Nil(t, CursorAfter(StoredQuery{ID: "q"}))
+
+	for _, raw := range []string{"no-separator", "yesterday|q", "2026-10-16T09:30:00Z|"} {
+		_, err := ParseQueryCursor(base64.RawURLEncoding.EncodeToString([]byte(raw)))
+		assert.Error(t, err, raw)
+	}
+	_, err = ParseQueryCursor("not base64!")
+	assert.Error(t, err)
+}
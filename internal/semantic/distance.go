@@ -0,0 +1,177 @@
+package semantic
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DistanceMetric is the pgvector distance used to compare query embeddings
+type DistanceMetric string
+
+const (
+	// DistanceCosine compares embedding directions and suits embeddings that are not normalized
+	DistanceCosine DistanceMetric = "cosine"
+	// DistanceL2 is the Euclidean distance between embeddings
+	DistanceL2 DistanceMetric = "l2"
+	// DistanceInnerProduct is the fastest metric and equals cosine similarity for normalized embeddings
+	DistanceInnerProduct DistanceMetric = "inner_product"
+)
+
+// similarityThreshold is the minimum similarity of queries returned by FindSimilarQueries
+const similarityThreshold = 0.8
+
+// similarQueriesLimit is the number of queries returned by FindSimilarQueries
+const similarQueriesLimit = 5
+
+// recencyCandidates is the number of most similar queries re-ranked by
+// recency, so an old query is only displaced by a similar enough recent one
+const recencyCandidates = 4 * similarQueriesLimit
+
+// ParseDistanceMetric parses a distance metric name. An empty name selects cosine.
+func ParseDistanceMetric(name string) (DistanceMetric, error) {
+	switch metric := DistanceMetric(strings.ToLower(strings.TrimSpace(name))); metric {
+	case "":
+		return DistanceCosine, nil
+	case DistanceCosine, DistanceL2, DistanceInnerProduct:
+		return metric, nil
+	default:
+		return "", fmt.Errorf("unknown distance metric %q: must be one of cosine, l2, inner_product", name)
+	}
+}
+
+// operator returns the pgvector distance operator
+func (m DistanceMetric) operator() string {
+	switch m {
+	case DistanceL2:
+		return "<->"
+	case DistanceInnerProduct:
+		return "<#>"
+	default:
+		return "<=>"
+	}
+}
+
+// opsClass returns the operator class an index must use to serve the operator
+func (m DistanceMetric) opsClass() string {
+	switch m {
+	case DistanceL2:
+		return "vector_l2_ops"
+	case DistanceInnerProduct:
+		return "vector_ip_ops"
+	default:
+		return "vector_cosine_ops"
+	}
+}
+
+// similarity returns an SQL expression scoring how similar two embeddings
+// are, where 1 is identical. For normalized embeddings all metrics give the
+// cosine similarity, so the same threshold applies to each.
+func (m DistanceMetric) similarity(column, param string) string {
+	distance := fmt.Sprintf("(%s %s %s)", column, m.operator(), param)
+	switch m {
+	case DistanceL2:
+		// |a-b|^2 = 2 - 2cos(a,b) for unit vectors
+		return fmt.Sprintf("1 - power(%s, 2) / 2", distance)
+	case DistanceInnerProduct:
+		// <#> returns the negative inner product
+		return fmt.Sprintf("-1 * %s", distance)
+	default:
+		return fmt.Sprintf("1 - %s", distance)
+	}
+}
+
+// embeddingColumn returns the embedding column expression. With a known
+// dimension the column is cast to a fixed-dimension vector, which indexes
+// require; the column itself accepts any dimension.
+func embeddingColumn(dimension int) string {
+	if dimension > 0 {
+		return fmt.Sprintf("(embedding::vector(%d))", dimension)
+	}
+	return "embedding"
+}
+
+// similarQueriesQuery builds the similarity search for FindSimilarQueries.
+// Ordering by the distance lets the index from vectorIndexDefinition serve
+// the search; embeddings of other dimensions are skipped. With a positive
+// half-life, results are ranked by their score: the similarity decayed by
+// half for every half-life since the query was last used.
+func similarQueriesQuery(metric DistanceMetric, dimension int, halfLife time.Duration) string {
+	return similarQueriesSelect(metric, dimension, "$1", halfLife)
+}
+
+// similarQueriesSelect builds the similarity search for the target embedding
+// expression
+func similarQueriesSelect(metric DistanceMetric, dimension int, target string, halfLife time.Duration) string {
+	column := embeddingColumn(dimension)
+	similarity := metric.similarity(column, target)
+
+	var where strings.Builder
+	where.WriteString(fmt.Sprintf("%s > %g", similarity, similarityThreshold))
+	if dimension > 0 {
+		where.WriteString(fmt.Sprintf(" AND vector_dims(embedding) = %d", dimension))
+	}
+
+	if halfLife <= 0 {
+		return fmt.Sprintf(`
+		SELECT id, query_text, promql_template,
+		       %s as similarity,
+		       created_at,
+		       %s as score
+		FROM query_embeddings
+		WHERE %s
+		ORDER BY %s %s %s
+		LIMIT %d
+	`, similarity, similarity, where.String(), column, metric.operator(), target, similarQueriesLimit)
+	}
+
+	// The inner search is served by the index; only its candidates are re-ranked
+	return fmt.Sprintf(`
+		SELECT id, query_text, promql_template, similarity, created_at,
+		       similarity * power(0.5, EXTRACT(EPOCH FROM (NOW() - last_used_at)) / %g) as score
+		FROM (
+			SELECT id, query_text, promql_template,
+			       %s as similarity,
+			       created_at, last_used_at
+			FROM query_embeddings
+			WHERE %s
+			ORDER BY %s %s %s
+			LIMIT %d
+		) AS candidates
+		ORDER BY score DESC
+		LIMIT %d
+	`, halfLife.Seconds(), similarity, where.String(), column, metric.operator(), target, recencyCandidates, similarQueriesLimit)
+}
+
+// similarQueriesBatchQuery builds the similarity search for
+// FindSimilarQueriesBatch. $1 is a text array of vector literals; each is
+// searched exactly as similarQueriesQuery would, in a lateral join, and its
+// results are tagged with its 1-based position in the array.
+func similarQueriesBatchQuery(metric DistanceMetric, dimension int, halfLife time.Duration) string {
+	return fmt.Sprintf(`
+		SELECT targets.ordinal, similar.id, similar.query_text, similar.promql_template,
+		       similar.similarity, similar.created_at, similar.score
+		FROM unnest($1::text[]) WITH ORDINALITY AS targets(literal, ordinal)
+		CROSS JOIN LATERAL (%s) AS similar
+		ORDER BY targets.ordinal, similar.score DESC
+	`, similarQueriesSelect(metric, dimension, "targets.literal::vector", halfLife))
+}
+
+// vectorIndexName returns the name of the index serving similarity searches
+// with the metric over embeddings of the dimension
+func vectorIndexName(metric DistanceMetric, dimension int) string {
+	return fmt.Sprintf("idx_query_embeddings_%s_%d", metric, dimension)
+}
+
+// vectorIndexDefinition returns the HNSW index serving similarity searches
+// with the metric. The index covers embeddings of one dimension, and its name
+// includes the metric and dimension, so changing either builds a new index.
+// It is built concurrently, so stored queries can be written meanwhile.
+func vectorIndexDefinition(metric DistanceMetric, dimension int) string {
+	return fmt.Sprintf(`
+		CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON query_embeddings
+		USING hnsw (%s %s)
+		WITH (m = 16, ef_construction = 64)
+		WHERE vector_dims(embedding) = %d
+	`, vectorIndexName(metric, dimension), embeddingColumn(dimension), metric.opsClass(), dimension)
+}
@@ -0,0 +1,120 @@
+package semantic
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseDistanceMetric tests distance metric names
+func TestParseDistanceMetric(t *testing.T) {
+	for name, expected := range map[string]DistanceMetric{
+		"":              DistanceCosine,
+		"cosine":        DistanceCosine,
+		"L2":            DistanceL2,
+		"inner_product": DistanceInnerProduct,
+	} {
+		metric, err := ParseDistanceMetric(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, metric, name)
+	}
+
+	_, err := ParseDistanceMetric("manhattan")
+	assert.Error(t, err)
+}
+
+// TestSimilarQueriesQuery tests that the similarity search and index use the configured operator
+func TestSimilarQueriesQuery(t *testing.T) {
+	tests := []struct {
+		metric   DistanceMetric
+		operator string
+		opsClass string
+	}{
+		{metric: DistanceCosine, operator: "<=>", opsClass: "vector_cosine_ops"},
+		{metric: DistanceL2, operator: "<->", opsClass: "vector_l2_ops"},
+		{metric: DistanceInnerProduct, operator: "<#>", opsClass: "vector_ip_ops"},
+	}
+
+	operators := []string{"<=>", "<->", "<#>"}
+	for _, tt := range tests {
+		t.Run(string(tt.metric), func(t *testing.T) {
+			query := similarQueriesQuery(tt.metric, 384, 0)
+			assert.Contains(t, query, "(embedding::vector(384)) "+tt.operator+" $1")
+			assert.Contains(t, query, "ORDER BY (embedding::vector(384)) "+tt.operator+" $1")
+			assert.Contains(t, query, "vector_dims(embedding) = 384")
+			for _, operator := range operators {
+				if operator != tt.operator {
+					assert.NotContains(t, query, operator)
+				}
+			}
+
+			index := vectorIndexDefinition(tt.metric, 384)
+			assert.Contains(t, index, "CREATE INDEX CONCURRENTLY IF NOT EXISTS "+vectorIndexName(tt.metric, 384))
+			assert.Equal(t, "idx_query_embeddings_"+string(tt.metric)+"_384", vectorIndexName(tt.metric, 384))
+			assert.Contains(t, index, "hnsw ((embedding::vector(384)) "+tt.opsClass+")")
+			assert.Contains(t, index, "WHERE vector_dims(embedding) = 384")
+		})
+	}
+
+	t.Run("unknown dimension uses the column as is", func(t *testing.T) {
+		query := similarQueriesQuery(DistanceCosine, 0, 0)
+		assert.Contains(t, query, "ORDER BY embedding <=> $1")
+		assert.NotContains(t, query, "vector_dims")
+	})
+}
+
+// TestSimilarQueriesBatchQuery tests that the batched search runs the single search per embedding
+func TestSimilarQueriesBatchQuery(t *testing.T) {
+	for _, metric := range []DistanceMetric{DistanceCosine, DistanceL2, DistanceInnerProduct} {
+		t.Run(string(metric), func(t *testing.T) {
+			query := similarQueriesBatchQuery(metric, 384, 0)
+			single := strings.ReplaceAll(similarQueriesQuery(metric, 384, 0), "$1", "targets.literal::vector")
+
+			assert.Contains(t, query, "unnest($1::text[]) WITH ORDINALITY AS targets(literal, ordinal)")
+			assert.Contains(t, query, "CROSS JOIN LATERAL ("+single+") AS similar")
+			assert.Contains(t, query, "ORDER BY targets.ordinal")
+		})
+	}
+}
+
+// TestDistanceSimilarityAgreement tests that for normalized embeddings every
+// metric's similarity expression yields the cosine similarity
+func TestDistanceSimilarityAgreement(t *testing.T) {
+	a := []float64{0.6, 0.8, 0}
+	b := []float64{0.8, 0.6, 0}
+
+	var dot, l2 float64
+	for i := range a {
+		dot += a[i] * b[i]
+		l2 += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	l2 = math.Sqrt(l2)
+
+	cosine := 1 - (1 - dot)         // 1 - (a <=> b)
+	fromL2 := 1 - math.Pow(l2, 2)/2 // 1 - power(a <-> b, 2) / 2
+	fromIP := -1 * -dot             // -1 * (a <#> b)
+
+	assert.InDelta(t, 0.96, cosine, 1e-9)
+	assert.InDelta(t, cosine, fromL2, 1e-9)
+	assert.InDelta(t, cosine, fromIP, 1e-9)
+}
+
+// TestSimilarQueriesRecencyQuery tests that a recency half-life re-ranks the
+// most similar candidates by their decayed score
+func TestSimilarQueriesRecencyQuery(t *testing.T) {
+	query := similarQueriesQuery(DistanceCosine, 384, 24*time.Hour)
+	assert.Contains(t, query, "ORDER BY (embedding::vector(384)) <=> $1")
+	assert.Contains(t, query, fmt.Sprintf("LIMIT %d", recencyCandidates))
+	assert.Contains(t, query, "power(0.5, EXTRACT(EPOCH FROM (NOW() - last_used_at)) / 86400)")
+	assert.Contains(t, query, "ORDER BY score DESC")
+
+	batch := similarQueriesBatchQuery(DistanceCosine, 384, 24*time.Hour)
+	assert.Contains(t, batch, "ORDER BY targets.ordinal, similar.score DESC")
+
+	assert.NotContains(t, similarQueriesQuery(DistanceCosine, 384, 0), "last_used_at")
+}
@@ -2,6 +2,8 @@ package semantic
 
 import (
 	"context"
+	"encoding/json"
+	"time"
 )
 
 // Mapper handles service and metric mapping
@@ -13,16 +15,61 @@ type Mapper interface {
 	UpdateServiceMetrics(ctx context.Context, serviceID string, metrics []string) error
 	DeleteService(ctx context.Context, serviceID string) error
 	SearchServices(ctx context.Context, searchTerm string) ([]Service, error)
+	GetNamespaces(ctx context.Context) ([]string, error)
+	// GetCatalogStats returns aggregate counts of the catalog
+	GetCatalogStats(ctx context.Context) (*CatalogStats, error)
 
 	// Metric operations
 	GetMetrics(ctx context.Context, serviceID string) ([]Metric, error)
+	// GetMetricsByName returns the catalog metrics with the name, one for
+	// each service reporting it
+	GetMetricsByName(ctx context.Context, name string) ([]Metric, error)
 	CreateMetric(ctx context.Context, name, metricType, description, serviceID string, labels map[string]string) (*Metric, error)
+	// UpdateMetricDescriptions sets the descriptions of catalog metrics by
+	// name, for every service reporting them
+	UpdateMetricDescriptions(ctx context.Context, descriptions map[string]string) error
+	// GetMetricDescriptions returns the non-empty descriptions of the named
+	// catalog metrics by name
+	GetMetricDescriptions(ctx context.Context, names []string) (map[string]string, error)
 
 	// Query embedding operations
 	FindSimilarQueries(ctx context.Context, embedding []float32) ([]SimilarQuery, error)
+	// FindSimilarQueriesBatch runs FindSimilarQueries for each embedding,
+	// returning the results in input order
+	FindSimilarQueriesBatch(ctx context.Context, embeddings [][]float32) ([][]SimilarQuery, error)
 	StoreQueryEmbedding(ctx context.Context, query string, embedding []float32, promql string) error
+	ListStoredQueries(ctx context.Context, afterID string, limit int) ([]StoredQuery, error)
+	// GetRecentQueries returns stored queries, most recently used first,
+	// starting after the cursor, or with the most recent when it is nil
+	GetRecentQueries(ctx context.Context, limit int, after *QueryCursor) ([]StoredQuery, error)
+	UpdateQueryEmbedding(ctx context.Context, id string, embedding []float32) error
+
+	// Query execution operations
+	RecordQueryExecution(ctx context.Context, query, promql string) error
+	// GetQueryExecutions returns at most limit execution counts, most
+	// recently executed first
+	GetQueryExecutions(ctx context.Context, limit int) ([]QueryExecution, error)
+
+	// Embedding cache operations
+	// GetOrStoreEmbedding returns the embedding of a normalized query cached
+	// for an embedding model, reporting true on a hit. On a miss the embedding
+	// is generated with embed and stored for later requests.
+	GetOrStoreEmbedding(ctx context.Context, model, normalizedQuery string, embed EmbedFunc) ([]float32, bool, error)
+	// PurgeEmbeddingCache removes cached embeddings of another model or
+	// dimension, or all of them when dimension is zero, returning the number removed
+	PurgeEmbeddingCache(ctx context.Context, model string, dimension int) (int, error)
+
+	// Evaluation operations
+	StoreEvaluationSample(ctx context.Context, sample EvaluationSample) error
+
+	// Lifecycle operations
+	Ping(ctx context.Context) error
+	Close() error
 }
 
+// EmbedFunc generates the embedding of a query on an embedding cache miss
+type EmbedFunc func(ctx context.Context) ([]float32, error)
+
 // Service represents a monitored service
 type Service struct {
 	ID          string            `json:"id"`
@@ -32,6 +79,22 @@ type Service struct {
 	MetricNames []string          `json:"metric_names"`
 	CreatedAt   string            `json:"created_at"`
 	UpdatedAt   string            `json:"updated_at"`
+
+	// MetricDisplayNames maps metric names to shorter names for display,
+	// for metrics with a configured display prefix; set by the API
+	MetricDisplayNames map[string]string `json:"metric_display_names,omitempty"`
+}
+
+// CatalogStats summarizes the catalog of discovered services and metrics
+type CatalogStats struct {
+	TotalServices       int            `json:"total_services"`
+	TotalMetrics        int            `json:"total_metrics"`   // Distinct metric names across services
+	MetricsByType       map[string]int `json:"metrics_by_type"` // Distinct metric names by type
+	ServicesByNamespace map[string]int `json:"services_by_namespace"`
+
+	// LastDiscovery is the most recent service update, written by each
+	// discovery cycle; nil when the catalog is empty
+	LastDiscovery *time.Time `json:"last_discovery,omitempty"`
 }
 
 // Metric represents a metric definition
@@ -44,6 +107,41 @@ type Metric struct {
 	ServiceID   string            `json:"service_id"`
 	CreatedAt   string            `json:"created_at"`
 	UpdatedAt   string            `json:"updated_at"`
+
+	// DisplayName is Name without a configured display prefix; set by the
+	// API when display prefixes are configured
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+// StoredQuery represents a stored query embedding, without the vector itself
+type StoredQuery struct {
+	ID         string `json:"id"`
+	Query      string `json:"query"`
+	PromQL     string `json:"promql"`
+	Dimensions int    `json:"dimensions"` // 0 when no embedding is stored
+
+	// LastUsedAt is when the query was last stored or regenerated; set by
+	// GetRecentQueries
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// QueryExecution counts the executions of a query and the PromQL it ran,
+// including those served from the response cache
+type QueryExecution struct {
+	Query          string    `json:"query"`
+	PromQL         string    `json:"promql"`
+	Count          int       `json:"count"`
+	LastExecutedAt time.Time `json:"last_executed_at"`
+}
+
+// EvaluationSample is a generated query sampled for offline evaluation
+type EvaluationSample struct {
+	Query      string          `json:"query"`
+	Prompt     string          `json:"prompt"`
+	PromQL     string          `json:"promql"`
+	Intent     json.RawMessage `json:"intent"`
+	Confidence float64         `json:"confidence"`
+	Model      string          `json:"model"`
 }
 
 // SimilarQuery represents a cached similar query
@@ -53,4 +151,8 @@ type SimilarQuery struct {
 	PromQL     string  `json:"promql"`
 	Similarity float64 `json:"similarity"`
 	CreatedAt  string  `json:"created_at"`
+
+	// Score ranks the query in search results: its similarity, weighted by
+	// recency when a recency half-life is configured
+	Score float64 `json:"score"`
 }
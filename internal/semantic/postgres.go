@@ -5,13 +5,17 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 	_ "github.com/lib/pq"
 	"github.com/pgvector/pgvector-go"
+	"github.com/seanankenbruck/observability-ai/internal/metrics"
+	"github.com/seanankenbruck/observability-ai/internal/observability"
 )
 
 // PostgresConfig holds PostgreSQL connection configuration
@@ -22,18 +26,51 @@ type PostgresConfig struct {
 	Username string
 	Password string
 	SSLMode  string
+
+	// EmbeddingDimension is the dimension of query embeddings, checked against
+	// the embedding column at startup. Zero skips the check.
+	EmbeddingDimension int
+
+	// DistanceMetric compares query embeddings in similarity searches.
+	// Empty selects cosine.
+	DistanceMetric DistanceMetric
+
+	// RecencyHalfLife ranks recently used queries higher in similarity
+	// searches: a query's score halves for every half-life since it was last
+	// stored. Zero ranks by similarity alone.
+	RecencyHalfLife time.Duration
 }
 
 // PostgresMapper implements the Mapper interface using PostgreSQL
 type PostgresMapper struct {
-	db *sql.DB
+	db        *sql.DB
+	metric    DistanceMetric
+	dimension int
+	halfLife  time.Duration
+
+	// Stale embeddings can only be counted with a full table scan, so the
+	// count reported by EmbeddingSchema is reused for staleEmbeddingsTTL
+	staleMu        sync.Mutex
+	staleCount     int
+	staleCheckedAt time.Time
 }
 
+// staleEmbeddingsTTL is how long EmbeddingSchema reuses its count of stored
+// embeddings of another dimension, so frequent health probes do not scan the
+// query_embeddings table each time
+const staleEmbeddingsTTL = 5 * time.Minute
+
+var _ Mapper = (*PostgresMapper)(nil)
+
 // NewPostgresMapper creates a new PostgreSQL-based semantic mapper
 func NewPostgresMapper(config PostgresConfig) (*PostgresMapper, error) {
 	if config.SSLMode == "" {
 		config.SSLMode = "disable"
 	}
+	metric, err := ParseDistanceMetric(string(config.DistanceMetric))
+	if err != nil {
+		return nil, err
+	}
 
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		config.Host, config.Port, config.Username, config.Password, config.Database, config.SSLMode)
@@ -48,12 +85,70 @@ func NewPostgresMapper(config PostgresConfig) (*PostgresMapper, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
+	// Fail fast with an actionable error if similarity search cannot work
+	if err := checkVectorSupport(context.Background(), pgVectorCatalog{db: db}, config.EmbeddingDimension); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// The embedding column accepts any dimension, so similarity searches are
+	// indexed per metric and dimension once the dimension is known
+	if config.EmbeddingDimension > 0 {
+		go buildVectorIndex(db, metric, config.EmbeddingDimension)
+
+		// Embeddings stored by a previous model are skipped by similarity searches
+		var stale int
+		err := db.QueryRow(`SELECT COUNT(*) FROM query_embeddings WHERE vector_dims(embedding) <> $1`, config.EmbeddingDimension).Scan(&stale)
+		if err == nil && stale > 0 {
+			log.Printf("Warning: %d stored query embeddings do not have %d dimensions and are skipped by similarity searches; "+
+				"re-embed them (POST /api/v1/admin/reembed)", stale, config.EmbeddingDimension)
+		}
+	}
+
 	// Configure connection pool
 	db.SetMaxOpenConns(25)
 	db.SetMaxIdleConns(25)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
-	return &PostgresMapper{db: db}, nil
+	return &PostgresMapper{db: db, metric: metric, dimension: config.EmbeddingDimension, halfLife: config.RecencyHalfLife}, nil
+}
+
+// buildVectorIndex creates the index serving similarity searches with the
+// metric over embeddings of the dimension, unless a valid one exists. It runs
+// in the background, since building the index on a large table can take a
+// while; similarity searches scan the table until it is ready. An invalid
+// index left by an interrupted build is dropped and rebuilt.
+func buildVectorIndex(db *sql.DB, metric DistanceMetric, dimension int) {
+	ctx := context.Background()
+	name := vectorIndexName(metric, dimension)
+
+	var valid bool
+	err := db.QueryRowContext(ctx, `
+		SELECT i.indisvalid
+		FROM pg_index i
+		JOIN pg_class c ON c.oid = i.indexrelid
+		WHERE c.relname = $1
+	`, name).Scan(&valid)
+	switch {
+	case err == nil && valid:
+		return
+	case err == nil:
+		if _, err := db.ExecContext(ctx, "DROP INDEX CONCURRENTLY IF EXISTS "+name); err != nil {
+			log.Printf("Warning: failed to drop the invalid query embedding index %s, similarity searches will scan the table: %v", name, err)
+			return
+		}
+	case err != sql.ErrNoRows:
+		log.Printf("Warning: failed to check the query embedding index %s: %v", name, err)
+		return
+	}
+
+	log.Printf("Building query embedding index %s in the background", name)
+	start := time.Now()
+	if _, err := db.ExecContext(ctx, vectorIndexDefinition(metric, dimension)); err != nil {
+		log.Printf("Warning: failed to create the query embedding index %s, similarity searches will scan the table: %v", name, err)
+		return
+	}
+	log.Printf("Built query embedding index %s in %s", name, time.Since(start).Round(time.Millisecond))
 }
 
 // Ping tests the database connection
@@ -127,6 +222,96 @@ func (pm *PostgresMapper) GetServices(ctx context.Context) ([]Service, error) {
 	return services, nil
 }
 
+// GetNamespaces retrieves the distinct namespaces of all services
+func (pm *PostgresMapper) GetNamespaces(ctx context.Context) ([]string, error) {
+	query := `
+		SELECT DISTINCT namespace
+		FROM services
+		ORDER BY namespace
+	`
+
+	rows, err := pm.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query namespaces: %w", err)
+	}
+	defer rows.Close()
+
+	namespaces := []string{}
+	for rows.Next() {
+		var namespace string
+		if err := rows.Scan(&namespace); err != nil {
+			return nil, fmt.Errorf("failed to scan namespace row: %w", err)
+		}
+		namespaces = append(namespaces, namespace)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating namespace rows: %w", err)
+	}
+
+	return namespaces, nil
+}
+
+// GetCatalogStats aggregates the catalog in the database rather than loading
+// every service and metric
+func (pm *PostgresMapper) GetCatalogStats(ctx context.Context) (*CatalogStats, error) {
+	stats := &CatalogStats{
+		MetricsByType:       make(map[string]int),
+		ServicesByNamespace: make(map[string]int),
+	}
+
+	var lastDiscovery sql.NullTime
+	err := pm.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM services),
+			(SELECT COUNT(DISTINCT name) FROM metrics),
+			(SELECT MAX(updated_at) FROM services)
+	`).Scan(&stats.TotalServices, &stats.TotalMetrics, &lastDiscovery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query catalog totals: %w", err)
+	}
+	if lastDiscovery.Valid {
+		stats.LastDiscovery = &lastDiscovery.Time
+	}
+
+	if err := pm.scanCounts(ctx, stats.MetricsByType, `
+		SELECT COALESCE(NULLIF(type, ''), 'unknown'), COUNT(DISTINCT name)
+		FROM metrics
+		GROUP BY 1
+	`); err != nil {
+		return nil, fmt.Errorf("failed to count metrics by type: %w", err)
+	}
+
+	if err := pm.scanCounts(ctx, stats.ServicesByNamespace, `
+		SELECT namespace, COUNT(*)
+		FROM services
+		GROUP BY namespace
+	`); err != nil {
+		return nil, fmt.Errorf("failed to count services by namespace: %w", err)
+	}
+
+	return stats, nil
+}
+
+// scanCounts reads (key, count) rows into counts
+func (pm *PostgresMapper) scanCounts(ctx context.Context, counts map[string]int, query string) error {
+	rows, err := pm.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var count int
+		if err := rows.Scan(&key, &count); err != nil {
+			return err
+		}
+		counts[key] = count
+	}
+	return rows.Err()
+}
+
 // GetMetrics retrieves metrics for a specific service
 func (pm *PostgresMapper) GetMetrics(ctx context.Context, serviceID string) ([]Metric, error) {
 	query := `
@@ -142,6 +327,29 @@ func (pm *PostgresMapper) GetMetrics(ctx context.Context, serviceID string) ([]M
 	}
 	defer rows.Close()
 
+	return scanMetrics(rows)
+}
+
+// GetMetricsByName retrieves the metrics with the name across all services
+func (pm *PostgresMapper) GetMetricsByName(ctx context.Context, name string) ([]Metric, error) {
+	query := `
+		SELECT id, name, type, description, labels, service_id, created_at, updated_at
+		FROM metrics
+		WHERE name = $1
+		ORDER BY service_id
+	`
+
+	rows, err := pm.db.QueryContext(ctx, query, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metrics by name: %w", err)
+	}
+	defer rows.Close()
+
+	return scanMetrics(rows)
+}
+
+// scanMetrics reads metric rows selected in the column order of GetMetrics
+func scanMetrics(rows *sql.Rows) ([]Metric, error) {
 	var metrics []Metric
 	for rows.Next() {
 		var metric Metric
@@ -187,20 +395,62 @@ func (pm *PostgresMapper) GetMetrics(ctx context.Context, serviceID string) ([]M
 	return metrics, nil
 }
 
-// FindSimilarQueries finds queries similar to the given embedding using cosine similarity
+// EmbeddingSchema reports how the embedding column and stored embeddings fit
+// the configured embedding dimension, for the embedding schema health check
+func (pm *PostgresMapper) EmbeddingSchema(ctx context.Context) (observability.EmbeddingSchema, error) {
+	schema := observability.EmbeddingSchema{ModelDimension: pm.dimension}
+
+	columnDimension, found, err := pgVectorCatalog{db: pm.db}.embeddingDimension(ctx)
+	if err != nil {
+		return schema, fmt.Errorf("failed to check the query embedding column: %w", err)
+	}
+	if !found {
+		return schema, fmt.Errorf("the query_embeddings.embedding column does not exist; run the migrations (go run ./cmd/migrate)")
+	}
+	schema.ColumnDimension = columnDimension
+
+	if pm.dimension > 0 {
+		schema.StaleEmbeddings, err = pm.staleEmbeddings(ctx)
+		if err != nil {
+			return schema, err
+		}
+	}
+	return schema, nil
+}
+
+// staleEmbeddings counts the stored embeddings whose dimension differs from
+// the configured one, reusing the last count for staleEmbeddingsTTL
+func (pm *PostgresMapper) staleEmbeddings(ctx context.Context) (int, error) {
+	pm.staleMu.Lock()
+	defer pm.staleMu.Unlock()
+
+	if !pm.staleCheckedAt.IsZero() && time.Since(pm.staleCheckedAt) < staleEmbeddingsTTL {
+		return pm.staleCount, nil
+	}
+
+	var count int
+	err := pm.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM query_embeddings WHERE vector_dims(embedding) <> $1`, pm.dimension).
+		Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count stale query embeddings: %w", err)
+	}
+	pm.staleCount = count
+	pm.staleCheckedAt = time.Now()
+	return count, nil
+}
+
+// FindSimilarQueries finds queries similar to the given embedding using the
+// configured distance metric. An embedding whose dimension differs from the
+// configured one fails with an *EmbeddingDimensionError.
 func (pm *PostgresMapper) FindSimilarQueries(ctx context.Context, embedding []float32) ([]SimilarQuery, error) {
+	if err := checkEmbeddingDimension(pm.dimension, embedding); err != nil {
+		return nil, err
+	}
+
 	// Convert float32 slice to pgvector.Vector
 	vector := pgvector.NewVector(embedding)
 
-	query := `
-		SELECT id, query_text, promql_template,
-		       1 - (embedding <=> $1) as similarity,
-		       created_at
-		FROM query_embeddings
-		WHERE 1 - (embedding <=> $1) > 0.8
-		ORDER BY similarity DESC
-		LIMIT 5
-	`
+	query := similarQueriesQuery(pm.metric, pm.dimension, pm.halfLife)
 
 	rows, err := pm.db.QueryContext(ctx, query, vector)
 	if err != nil {
@@ -217,6 +467,7 @@ func (pm *PostgresMapper) FindSimilarQueries(ctx context.Context, embedding []fl
 			&sq.PromQL,
 			&sq.Similarity,
 			&sq.CreatedAt,
+			&sq.Score,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan similar query row: %w", err)
@@ -232,6 +483,60 @@ func (pm *PostgresMapper) FindSimilarQueries(ctx context.Context, embedding []fl
 	return similarQueries, nil
 }
 
+// FindSimilarQueriesBatch finds queries similar to each of the embeddings in
+// a single round trip. The results are in input order and match what
+// FindSimilarQueries returns for each embedding.
+func (pm *PostgresMapper) FindSimilarQueriesBatch(ctx context.Context, embeddings [][]float32) ([][]SimilarQuery, error) {
+	results := make([][]SimilarQuery, len(embeddings))
+	if len(embeddings) == 0 {
+		return results, nil
+	}
+
+	literals := make([]string, len(embeddings))
+	for i, embedding := range embeddings {
+		if err := checkEmbeddingDimension(pm.dimension, embedding); err != nil {
+			return nil, err
+		}
+		literals[i] = pgvector.NewVector(embedding).String()
+	}
+
+	query := similarQueriesBatchQuery(pm.metric, pm.dimension, pm.halfLife)
+
+	rows, err := pm.db.QueryContext(ctx, query, pq.Array(literals))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query similar queries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ordinal int
+		var sq SimilarQuery
+		err := rows.Scan(
+			&ordinal,
+			&sq.ID,
+			&sq.Query,
+			&sq.PromQL,
+			&sq.Similarity,
+			&sq.CreatedAt,
+			&sq.Score,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan similar query row: %w", err)
+		}
+		if ordinal < 1 || ordinal > len(results) {
+			return nil, fmt.Errorf("similar query row for unknown embedding %d", ordinal)
+		}
+
+		results[ordinal-1] = append(results[ordinal-1], sq)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating similar query rows: %w", err)
+	}
+
+	return results, nil
+}
+
 // GetServiceByName retrieves a service by name
 func (pm *PostgresMapper) GetServiceByName(ctx context.Context, name, namespace string) (*Service, error) {
 	query := `
@@ -289,12 +594,13 @@ func (pm *PostgresMapper) StoreQueryEmbedding(ctx context.Context, query string,
 	vector := pgvector.NewVector(embedding)
 
 	insertQuery := `
-		INSERT INTO query_embeddings (id, query_text, embedding, promql_template, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO query_embeddings (id, query_text, embedding, promql_template, created_at, last_used_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
 		ON CONFLICT (query_text) DO UPDATE SET
 			embedding = $3,
 			promql_template = $4,
-			updated_at = $5
+			updated_at = $5,
+			last_used_at = $5
 	`
 
 	id := uuid.New().String()
@@ -308,9 +614,229 @@ func (pm *PostgresMapper) StoreQueryEmbedding(ctx context.Context, query string,
 	return nil
 }
 
+// ListStoredQueries returns stored queries ordered by ID, starting after afterID.
+// An empty afterID starts from the beginning.
+func (pm *PostgresMapper) ListStoredQueries(ctx context.Context, afterID string, limit int) ([]StoredQuery, error) {
+	query := `
+		SELECT id, query_text, promql_template, COALESCE(vector_dims(embedding), 0)
+		FROM query_embeddings
+		WHERE $1 = '' OR id > $1::uuid
+		ORDER BY id
+		LIMIT $2
+	`
+
+	rows, err := pm.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stored queries: %w", err)
+	}
+	defer rows.Close()
+
+	var queries []StoredQuery
+	for rows.Next() {
+		var sq StoredQuery
+		if err := rows.Scan(&sq.ID, &sq.Query, &sq.PromQL, &sq.Dimensions); err != nil {
+			return nil, fmt.Errorf("failed to scan stored query row: %w", err)
+		}
+		queries = append(queries, sq)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stored query rows: %w", err)
+	}
+
+	return queries, nil
+}
+
+// GetRecentQueries returns stored queries ordered by when they were last
+// used, most recent first. Pages are keyed by the last used time and ID of
+// the previous page's last query, so queries stored between page fetches
+// neither repeat nor skip rows of the following pages.
+func (pm *PostgresMapper) GetRecentQueries(ctx context.Context, limit int, after *QueryCursor) ([]StoredQuery, error) {
+	query := `
+		SELECT id, query_text, promql_template, COALESCE(vector_dims(embedding), 0), last_used_at
+		FROM query_embeddings
+		ORDER BY last_used_at DESC, id DESC
+		LIMIT $1
+	`
+	args := []interface{}{limit}
+	if after != nil {
+		query = `
+			SELECT id, query_text, promql_template, COALESCE(vector_dims(embedding), 0), last_used_at
+			FROM query_embeddings
+			WHERE (last_used_at, id) < ($2, $3)
+			ORDER BY last_used_at DESC, id DESC
+			LIMIT $1
+		`
+		args = append(args, after.LastUsedAt, after.ID)
+	}
+
+	rows, err := pm.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent queries: %w", err)
+	}
+	defer rows.Close()
+
+	queries := []StoredQuery{}
+	for rows.Next() {
+		var sq StoredQuery
+		var lastUsedAt time.Time
+		if err := rows.Scan(&sq.ID, &sq.Query, &sq.PromQL, &sq.Dimensions, &lastUsedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan recent query row: %w", err)
+		}
+		sq.LastUsedAt = &lastUsedAt
+		queries = append(queries, sq)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating recent query rows: %w", err)
+	}
+
+	return queries, nil
+}
+
+// RecordQueryExecution counts an execution of a query and the PromQL it ran
+func (pm *PostgresMapper) RecordQueryExecution(ctx context.Context, query, promql string) error {
+	_, err := pm.db.ExecContext(ctx, `
+		INSERT INTO query_executions (query_text, promql, execution_count, last_executed_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (query_text, promql) DO UPDATE SET
+			execution_count = query_executions.execution_count + 1,
+			last_executed_at = $3
+	`, query, promql, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to record query execution: %w", err)
+	}
+
+	return nil
+}
+
+// GetQueryExecutions returns execution counts, most recently executed first
+func (pm *PostgresMapper) GetQueryExecutions(ctx context.Context, limit int) ([]QueryExecution, error) {
+	rows, err := pm.db.QueryContext(ctx, `
+		SELECT query_text, promql, execution_count, last_executed_at
+		FROM query_executions
+		ORDER BY last_executed_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get query executions: %w", err)
+	}
+	defer rows.Close()
+
+	executions := []QueryExecution{}
+	for rows.Next() {
+		var execution QueryExecution
+		if err := rows.Scan(&execution.Query, &execution.PromQL, &execution.Count, &execution.LastExecutedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan query execution row: %w", err)
+		}
+		executions = append(executions, execution)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating query execution rows: %w", err)
+	}
+
+	return executions, nil
+}
+
+// UpdateQueryEmbedding replaces the embedding of a stored query
+func (pm *PostgresMapper) UpdateQueryEmbedding(ctx context.Context, id string, embedding []float32) error {
+	vector := pgvector.NewVector(embedding)
+
+	result, err := pm.db.ExecContext(ctx, `UPDATE query_embeddings SET embedding = $2 WHERE id = $1`, id, vector)
+	if err != nil {
+		return fmt.Errorf("failed to update query embedding: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("stored query not found: %s", id)
+	}
+
+	return nil
+}
+
+// GetOrStoreEmbedding returns the embedding of a normalized query cached for
+// model, generating and storing it on a miss. Cached embeddings of another
+// dimension than the configured one are replaced.
+func (pm *PostgresMapper) GetOrStoreEmbedding(ctx context.Context, model, normalizedQuery string, embed EmbedFunc) ([]float32, bool, error) {
+	var cached pgvector.Vector
+	err := pm.db.QueryRowContext(ctx, `
+		SELECT embedding FROM embedding_cache
+		WHERE model = $1 AND normalized_query = $2 AND ($3 = 0 OR dimensions = $3)
+	`, model, normalizedQuery, pm.dimension).Scan(&cached)
+	if err == nil {
+		return cached.Slice(), true, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, fmt.Errorf("failed to look up cached embedding: %w", err)
+	}
+
+	embedding, err := embed(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	insertQuery := `
+		INSERT INTO embedding_cache (model, normalized_query, embedding, dimensions, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (model, normalized_query) DO UPDATE SET
+			embedding = $3,
+			dimensions = $4,
+			created_at = $5
+	`
+	_, err = pm.db.ExecContext(ctx, insertQuery, model, normalizedQuery, pgvector.NewVector(embedding), len(embedding), time.Now())
+	if err != nil {
+		return embedding, false, fmt.Errorf("failed to store cached embedding: %w", err)
+	}
+
+	return embedding, false, nil
+}
+
+// PurgeEmbeddingCache removes cached embeddings of another model than model or
+// another dimension than dimension, or all cached embeddings when dimension
+// is zero
+func (pm *PostgresMapper) PurgeEmbeddingCache(ctx context.Context, model string, dimension int) (int, error) {
+	result, err := pm.db.ExecContext(ctx, `DELETE FROM embedding_cache WHERE $2 = 0 OR model <> $1 OR dimensions <> $2`, model, dimension)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge embedding cache: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(rowsAffected), nil
+}
+
+// StoreEvaluationSample stores a generated query sampled for offline evaluation
+func (pm *PostgresMapper) StoreEvaluationSample(ctx context.Context, sample EvaluationSample) error {
+	intent := sample.Intent
+	if len(intent) == 0 {
+		intent = json.RawMessage("{}")
+	}
+
+	insertQuery := `
+		INSERT INTO evaluation_samples (id, natural_query, prompt, generated_promql, intent, confidence_score, model, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := pm.db.ExecContext(ctx, insertQuery, uuid.New().String(), sample.Query, sample.Prompt,
+		sample.PromQL, []byte(intent), sample.Confidence, sample.Model, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to store evaluation sample: %w", err)
+	}
+
+	return nil
+}
+
 // UpdateServiceMetrics updates the metric names for a service
-func (pm *PostgresMapper) UpdateServiceMetrics(ctx context.Context, serviceID string, metrics []string) error {
-	metricNamesJSON, err := json.Marshal(metrics)
+func (pm *PostgresMapper) UpdateServiceMetrics(ctx context.Context, serviceID string, metricNames []string) error {
+	metricNamesJSON, err := json.Marshal(metricNames)
 	if err != nil {
 		return fmt.Errorf("failed to marshal metric names: %w", err)
 	}
@@ -338,16 +864,11 @@ func (pm *PostgresMapper) UpdateServiceMetrics(ctx context.Context, serviceID st
 
 	// Insert/update individual metric rows in the metrics table
 	// Use INSERT ... ON CONFLICT to handle duplicates
-	for _, metricName := range metrics {
+	for _, metricName := range metricNames {
 		metricID := uuid.New().String()
 
-		// Determine metric type from name (simple heuristic)
-		metricType := "gauge"
-		if strings.HasSuffix(metricName, "_total") || strings.HasSuffix(metricName, "_count") {
-			metricType = "counter"
-		} else if strings.HasSuffix(metricName, "_bucket") {
-			metricType = "histogram"
-		}
+		// Determine metric type from naming conventions
+		metricType := string(metrics.InferType(metricName, nil))
 
 		metricQuery := `
 			INSERT INTO metrics (id, name, type, service_id, created_at, updated_at)
@@ -367,7 +888,71 @@ func (pm *PostgresMapper) UpdateServiceMetrics(ctx context.Context, serviceID st
 	return nil
 }
 
-// CreateService creates a new service
+// UpdateMetricDescriptions sets the descriptions of catalog metrics by name in
+// a single statement; rows whose description is unchanged are not written
+func (pm *PostgresMapper) UpdateMetricDescriptions(ctx context.Context, descriptions map[string]string) error {
+	if len(descriptions) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(descriptions))
+	texts := make([]string, 0, len(descriptions))
+	for name, description := range descriptions {
+		names = append(names, name)
+		texts = append(texts, description)
+	}
+
+	query := `
+		UPDATE metrics AS m
+		SET description = d.description, updated_at = $3
+		FROM unnest($1::text[], $2::text[]) AS d(name, description)
+		WHERE m.name = d.name AND m.description IS DISTINCT FROM d.description
+	`
+	if _, err := pm.db.ExecContext(ctx, query, pq.Array(names), pq.Array(texts), time.Now()); err != nil {
+		return fmt.Errorf("failed to update metric descriptions: %w", err)
+	}
+	return nil
+}
+
+// GetMetricDescriptions returns the non-empty descriptions of catalog metrics
+// by name. A metric reported by several services has the same description
+// for all of them, as descriptions are set by name.
+func (pm *PostgresMapper) GetMetricDescriptions(ctx context.Context, names []string) (map[string]string, error) {
+	descriptions := make(map[string]string)
+	if len(names) == 0 {
+		return descriptions, nil
+	}
+
+	query := `
+		SELECT DISTINCT ON (name) name, description
+		FROM metrics
+		WHERE name = ANY($1) AND description IS NOT NULL AND description <> ''
+		ORDER BY name, updated_at DESC
+	`
+
+	rows, err := pm.db.QueryContext(ctx, query, pq.Array(names))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metric descriptions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, description string
+		if err := rows.Scan(&name, &description); err != nil {
+			return nil, fmt.Errorf("failed to scan metric description row: %w", err)
+		}
+		descriptions[name] = description
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating metric description rows: %w", err)
+	}
+
+	return descriptions, nil
+}
+
+// CreateService creates a service, or returns the existing service with the
+// same name and namespace with the labels merged in. It is idempotent, so
+// concurrent creates of the same service yield a single row.
 func (pm *PostgresMapper) CreateService(ctx context.Context, name, namespace string, labels map[string]string) (*Service, error) {
 	labelsJSON, err := json.Marshal(labels)
 	if err != nil {
@@ -382,9 +967,13 @@ func (pm *PostgresMapper) CreateService(ctx context.Context, name, namespace str
 	id := uuid.New().String()
 	now := time.Now()
 
+	// Existing services keep their ID and metric names
 	query := `
 		INSERT INTO services (id, name, namespace, labels, metric_names, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (name, namespace) DO UPDATE
+		SET labels = COALESCE(services.labels, '{}'::jsonb) || EXCLUDED.labels,
+		    updated_at = EXCLUDED.updated_at
 		RETURNING id, name, namespace, labels, metric_names, created_at, updated_at
 	`
 
@@ -402,9 +991,6 @@ func (pm *PostgresMapper) CreateService(ctx context.Context, name, namespace str
 	)
 
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique violation
-			return nil, fmt.Errorf("service already exists: %s", name)
-		}
 		return nil, fmt.Errorf("failed to create service: %w", err)
 	}
 
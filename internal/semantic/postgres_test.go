@@ -0,0 +1,527 @@
+package semantic
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPostgresMapper connects to the database named by the TEST_POSTGRES_*
+// environment variables, skipping the test when TEST_POSTGRES_HOST is unset.
+// The database must have the migrations applied.
+func newTestPostgresMapper(t *testing.T, dimension int) *PostgresMapper {
+	t.Helper()
+	host := os.Getenv("TEST_POSTGRES_HOST")
+	if host == "" {
+		t.Skip("TEST_POSTGRES_HOST not set, skipping PostgreSQL test")
+	}
+
+	mapper, err := NewPostgresMapper(PostgresConfig{
+		Host:               host,
+		Port:               envOr("TEST_POSTGRES_PORT", "5432"),
+		Database:           envOr("TEST_POSTGRES_DB", "observability_ai_test"),
+		Username:           envOr("TEST_POSTGRES_USER", "obs_ai"),
+		Password:           os.Getenv("TEST_POSTGRES_PASSWORD"),
+		EmbeddingDimension: dimension,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { mapper.Close() })
+	return mapper
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// unitEmbedding returns a normalized embedding pointing mostly along axis
+func unitEmbedding(dimension, axis int, spread float64) []float32 {
+	norm := math.Sqrt(1 + spread*spread)
+	embedding := make([]float32, dimension)
+	embedding[axis] = float32(1 / norm)
+	embedding[(axis+1)%dimension] = float32(spread / norm)
+	return embedding
+}
+
+// TestFindSimilarQueriesBatch tests that batched searches match individual lookups, in input order
+func TestFindSimilarQueriesBatch(t *testing.T) {
+	const dimension = 8
+	mapper := newTestPostgresMapper(t, dimension)
+	ctx := context.Background()
+
+	prefix := fmt.Sprintf("batch test %s", t.Name())
+	for axis := 0; axis < 3; axis++ {
+		for i, spread := range []float64{0, 0.1, 0.2} {
+			query := fmt.Sprintf("%s axis %d variant %d", prefix, axis, i)
+			require.NoError(t, mapper.StoreQueryEmbedding(ctx, query, unitEmbedding(dimension, axis, spread), "up"))
+		}
+	}
+	t.Cleanup(func() {
+		mapper.db.Exec("DELETE FROM query_embeddings WHERE query_text LIKE $1", prefix+"%")
+	})
+
+	embeddings := [][]float32{
+		unitEmbedding(dimension, 2, 0.05),
+		unitEmbedding(dimension, 0, 0.05),
+		unitEmbedding(dimension, 5, 0), // Matches nothing
+		unitEmbedding(dimension, 1, 0.05),
+	}
+
+	batch, err := mapper.FindSimilarQueriesBatch(ctx, embeddings)
+	require.NoError(t, err)
+	require.Len(t, batch, len(embeddings))
+
+	for i, embedding := range embeddings {
+		individual, err := mapper.FindSimilarQueries(ctx, embedding)
+		require.NoError(t, err)
+		require.Len(t, batch[i], len(individual), "embedding %d", i)
+		for j := range individual {
+			assert.Equal(t, individual[j].ID, batch[i][j].ID, "embedding %d result %d", i, j)
+			assert.InDelta(t, individual[j].Similarity, batch[i][j].Similarity, 1e-9)
+		}
+	}
+	assert.Empty(t, batch[2])
+
+	empty, err := mapper.FindSimilarQueriesBatch(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+// TestGetOrStoreEmbedding tests that a cached embedding is generated once and
+// then reused by its model only, and that a purge removes embeddings of a
+// previous model
+func TestGetOrStoreEmbedding(t *testing.T) {
+	const dimension = 8
+	mapper := newTestPostgresMapper(t, dimension)
+	ctx := context.Background()
+
+	const model = "embed-v2"
+	query := fmt.Sprintf("embedding cache test %s", t.Name())
+	t.Cleanup(func() {
+		mapper.db.Exec("DELETE FROM embedding_cache WHERE normalized_query LIKE $1", query+"%")
+	})
+
+	calls := 0
+	embed := func(ctx context.Context) ([]float32, error) {
+		calls++
+		return unitEmbedding(dimension, calls%dimension, 0), nil
+	}
+
+	first, hit, err := mapper.GetOrStoreEmbedding(ctx, model, query, embed)
+	require.NoError(t, err)
+	assert.False(t, hit)
+	assert.Equal(t, 1, calls)
+
+	second, hit, err := mapper.GetOrStoreEmbedding(ctx, model, query, embed)
+	require.NoError(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, 1, calls, "a hit must not generate the embedding")
+	assert.Equal(t, first, second)
+
+	// Another model does not reuse the embedding
+	_, hit, err = mapper.GetOrStoreEmbedding(ctx, "embed-v1", query, embed)
+	require.NoError(t, err)
+	assert.False(t, hit)
+	assert.Equal(t, 2, calls)
+
+	embedErr := fmt.Errorf("embedding unavailable")
+	_, _, err = mapper.GetOrStoreEmbedding(ctx, model, query+" missing", func(ctx context.Context) ([]float32, error) {
+		return nil, embedErr
+	})
+	assert.ErrorIs(t, err, embedErr)
+
+	// Entries of the current model and dimension survive a purge; those of
+	// the previous model do not, and a full purge removes all
+	_, err = mapper.PurgeEmbeddingCache(ctx, model, dimension)
+	require.NoError(t, err)
+	_, hit, err = mapper.GetOrStoreEmbedding(ctx, model, query, embed)
+	require.NoError(t, err)
+	assert.True(t, hit)
+	var previous int
+	require.NoError(t, mapper.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM embedding_cache WHERE model = 'embed-v1' AND normalized_query = $1", query).Scan(&previous))
+	assert.Zero(t, previous)
+
+	purged, err := mapper.PurgeEmbeddingCache(ctx, model, 0)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, purged, 1)
+	_, hit, err = mapper.GetOrStoreEmbedding(ctx, model, query, embed)
+	require.NoError(t, err)
+	assert.False(t, hit)
+	assert.Equal(t, 3, calls)
+}
+
+// TestFindSimilarQueriesRecency tests that a recently used query outranks an
+// older one of equal similarity when a recency half-life is configured
+func TestFindSimilarQueriesRecency(t *testing.T) {
+	const dimension = 8
+	mapper := newTestPostgresMapper(t, dimension)
+	mapper.halfLife = 30 * 24 * time.Hour
+	ctx := context.Background()
+
+	prefix := fmt.Sprintf("recency test %s", t.Name())
+	embedding := unitEmbedding(dimension, 6, 0)
+	older, recent := prefix+" older", prefix+" recent"
+	require.NoError(t, mapper.StoreQueryEmbedding(ctx, older, embedding, "up"))
+	require.NoError(t, mapper.StoreQueryEmbedding(ctx, recent, embedding, "up"))
+	t.Cleanup(func() {
+		mapper.db.Exec("DELETE FROM query_embeddings WHERE query_text LIKE $1", prefix+"%")
+	})
+	_, err := mapper.db.ExecContext(ctx, "UPDATE query_embeddings SET last_used_at = NOW() - INTERVAL '90 days' WHERE query_text = $1", older)
+	require.NoError(t, err)
+
+	// rank returns the positions of the older and recent queries in the results
+	rank := func(results []SimilarQuery) (int, int) {
+		olderRank, recentRank := -1, -1
+		for i, sq := range results {
+			switch sq.Query {
+			case older:
+				olderRank = i
+				assert.InDelta(t, 1, sq.Similarity, 1e-6)
+				assert.InDelta(t, 0.125, sq.Score, 1e-3, "three half-lives old")
+			case recent:
+				recentRank = i
+				assert.InDelta(t, 1, sq.Similarity, 1e-6)
+			}
+		}
+		return olderRank, recentRank
+	}
+
+	results, err := mapper.FindSimilarQueries(ctx, embedding)
+	require.NoError(t, err)
+	olderRank, recentRank := rank(results)
+	require.GreaterOrEqual(t, recentRank, 0)
+	if olderRank >= 0 {
+		assert.Less(t, recentRank, olderRank)
+	}
+
+	batch, err := mapper.FindSimilarQueriesBatch(ctx, [][]float32{embedding})
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	olderRank, recentRank = rank(batch[0])
+	require.GreaterOrEqual(t, recentRank, 0)
+	if olderRank >= 0 {
+		assert.Less(t, recentRank, olderRank)
+	}
+}
+
+// TestGetRecentQueries tests that stored queries are listed most recently
+// used first, one page at a time. The seeded queries are dated in the future
+// so they come first even in a shared database.
+func TestGetRecentQueries(t *testing.T) {
+	const dimension = 8
+	mapper := newTestPostgresMapper(t, dimension)
+	ctx := context.Background()
+
+	prefix := fmt.Sprintf("recent test %s", t.Name())
+	t.Cleanup(func() {
+		mapper.db.Exec("DELETE FROM query_embeddings WHERE query_text LIKE $1", prefix+"%")
+	})
+	// Stored oldest first; the embeddings would rank them the other way
+	names := []string{prefix + " oldest", prefix + " middle", prefix + " newest"}
+	for i, name := range names {
+		require.NoError(t, mapper.StoreQueryEmbedding(ctx, name, unitEmbedding(dimension, 2-i, 0), "up"))
+		_, err := mapper.db.ExecContext(ctx, "UPDATE query_embeddings SET last_used_at = NOW() + make_interval(days => $2) WHERE query_text = $1", name, 365+i)
+		require.NoError(t, err)
+	}
+
+	first, err := mapper.GetRecentQueries(ctx, 2, nil)
+	require.NoError(t, err)
+	require.Len(t, first, 2)
+	assert.Equal(t, names[2], first[0].Query)
+	assert.Equal(t, names[1], first[1].Query)
+	assert.Equal(t, dimension, first[0].Dimensions)
+	require.NotNil(t, first[0].LastUsedAt)
+	assert.True(t, first[0].LastUsedAt.After(*first[1].LastUsedAt))
+
+	second, err := mapper.GetRecentQueries(ctx, 2, CursorAfter(first[1]))
+	require.NoError(t, err)
+	require.NotEmpty(t, second)
+	assert.Equal(t, names[0], second[0].Query)
+}
+
+// TestRecordQueryExecution tests that executions are counted per query and
+// expression, most recently executed first
+func TestRecordQueryExecution(t *testing.T) {
+	mapper := newTestPostgresMapper(t, 8)
+	ctx := context.Background()
+
+	prefix := fmt.Sprintf("execution test %s", t.Name())
+	t.Cleanup(func() {
+		mapper.db.Exec("DELETE FROM query_executions WHERE query_text LIKE $1", prefix+"%")
+	})
+	require.NoError(t, mapper.RecordQueryExecution(ctx, prefix+" rate", "sum(rate(up[5m]))"))
+	require.NoError(t, mapper.RecordQueryExecution(ctx, prefix+" up", "up"))
+	require.NoError(t, mapper.RecordQueryExecution(ctx, prefix+" up", "up"))
+
+	executions, err := mapper.GetQueryExecutions(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, executions, 2)
+	assert.Equal(t, prefix+" up", executions[0].Query)
+	assert.Equal(t, "up", executions[0].PromQL)
+	assert.Equal(t, 2, executions[0].Count)
+	assert.Equal(t, prefix+" rate", executions[1].Query)
+	assert.Equal(t, 1, executions[1].Count)
+}
+
+// TestGetRecentQueriesCursorStability tests that paging by cursor neither
+// repeats nor skips queries while new queries are stored between page
+// fetches. The seeded queries share last used times, so the cursor's ID
+// breaks ties.
+func TestGetRecentQueriesCursorStability(t *testing.T) {
+	const dimension = 8
+	mapper := newTestPostgresMapper(t, dimension)
+	ctx := context.Background()
+
+	prefix := fmt.Sprintf("cursor test %s", t.Name())
+	t.Cleanup(func() {
+		mapper.db.Exec("DELETE FROM query_embeddings WHERE query_text LIKE $1", prefix+"%")
+	})
+	seeded := make(map[string]bool)
+	for i := 0; i < 7; i++ {
+		name := fmt.Sprintf("%s seeded %d", prefix, i)
+		require.NoError(t, mapper.StoreQueryEmbedding(ctx, name, unitEmbedding(dimension, i%dimension, 0), "up"))
+		seeded[name] = true
+	}
+	// Dated in the future so they come first even in a shared database, with
+	// pairs of queries sharing a timestamp
+	_, err := mapper.db.ExecContext(ctx, `
+		UPDATE query_embeddings
+		SET last_used_at = '2100-01-01'::timestamptz - make_interval(secs => substring(query_text from '[0-9]+$')::int / 2)
+		WHERE query_text LIKE $1`, prefix+" seeded%")
+	require.NoError(t, err)
+
+	seen := make(map[string]int)
+	var after *QueryCursor
+	for page := 0; ; page++ {
+		queries, err := mapper.GetRecentQueries(ctx, 3, after)
+		require.NoError(t, err)
+		for _, sq := range queries {
+			seen[sq.Query]++
+		}
+		if len(queries) < 3 || !seeded[queries[len(queries)-1].Query] {
+			break
+		}
+		after = CursorAfter(queries[len(queries)-1])
+
+		// Queries stored between fetches are newer than the cursor
+		name := fmt.Sprintf("%s inserted %d", prefix, page)
+		require.NoError(t, mapper.StoreQueryEmbedding(ctx, name, unitEmbedding(dimension, page%dimension, 0), "up"))
+		_, err = mapper.db.ExecContext(ctx, "UPDATE query_embeddings SET last_used_at = '2100-01-02' WHERE query_text = $1", name)
+		require.NoError(t, err)
+	}
+
+	for name := range seeded {
+		assert.Equal(t, 1, seen[name], name)
+	}
+	for name, count := range seen {
+		if seeded[name] {
+			continue
+		}
+		assert.False(t, strings.HasPrefix(name, prefix), "query stored after paging began was listed: %s", name)
+		assert.Equal(t, 1, count, name)
+	}
+}
+
+// TestGetCatalogStats tests that the aggregate counts reflect a seeded catalog.
+// Counts are compared before and after seeding since the database may be shared.
+func TestGetCatalogStats(t *testing.T) {
+	mapper := newTestPostgresMapper(t, 0)
+	ctx := context.Background()
+
+	before, err := mapper.GetCatalogStats(ctx)
+	require.NoError(t, err)
+
+	namespace := fmt.Sprintf("stats-%d", time.Now().UnixNano())
+	prefix := fmt.Sprintf("stats_%d_", time.Now().UnixNano())
+	seed := func(name string, metrics ...string) {
+		service, err := mapper.CreateService(ctx, name, namespace, nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { mapper.DeleteService(context.Background(), service.ID) })
+		require.NoError(t, mapper.UpdateServiceMetrics(ctx, service.ID, metrics))
+	}
+	seed("api", prefix+"requests_total", prefix+"request_duration_seconds_bucket", prefix+"memory_bytes")
+	seed("worker", prefix+"requests_total", prefix+"jobs_total")
+	seed("cache", prefix+"items")
+
+	after, err := mapper.GetCatalogStats(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, before.TotalServices+3, after.TotalServices)
+	assert.Equal(t, before.TotalMetrics+5, after.TotalMetrics, "a metric exposed by two services is counted once")
+	assert.Equal(t, before.MetricsByType["counter"]+2, after.MetricsByType["counter"])
+	assert.Equal(t, before.MetricsByType["histogram"]+1, after.MetricsByType["histogram"])
+	assert.Equal(t, before.MetricsByType["gauge"]+1, after.MetricsByType["gauge"])
+	assert.Equal(t, before.MetricsByType["unknown"]+1, after.MetricsByType["unknown"], "names with no naming signal are not typed")
+	assert.Equal(t, 3, after.ServicesByNamespace[namespace])
+	require.NotNil(t, after.LastDiscovery)
+	assert.WithinDuration(t, time.Now(), *after.LastDiscovery, time.Minute)
+}
+
+// TestGetNamespaces tests that each namespace of the catalog is listed once,
+// in order, however many services it holds
+func TestGetNamespaces(t *testing.T) {
+	mapper := newTestPostgresMapper(t, 0)
+	ctx := context.Background()
+
+	prefix := fmt.Sprintf("namespaces-%d", time.Now().UnixNano())
+	seed := func(name, namespace string) {
+		service, err := mapper.CreateService(ctx, name, namespace, nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { mapper.DeleteService(context.Background(), service.ID) })
+	}
+	seed("api", prefix+"-staging")
+	seed("api", prefix+"-production")
+	seed("worker", prefix+"-production")
+
+	namespaces, err := mapper.GetNamespaces(ctx)
+	require.NoError(t, err)
+	assert.True(t, sort.StringsAreSorted(namespaces), "namespaces are ordered")
+
+	seeded := []string{}
+	for _, namespace := range namespaces {
+		if strings.HasPrefix(namespace, prefix) {
+			seeded = append(seeded, namespace)
+		}
+	}
+	assert.Equal(t, []string{prefix + "-production", prefix + "-staging"}, seeded)
+}
+
+// TestGetMetricDescriptions tests that only the descriptions of the named
+// metrics are returned
+func TestGetMetricDescriptions(t *testing.T) {
+	mapper := newTestPostgresMapper(t, 0)
+	ctx := context.Background()
+
+	prefix := fmt.Sprintf("descriptions_%d_", time.Now().UnixNano())
+	service, err := mapper.CreateService(ctx, "api", prefix+"ns", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { mapper.DeleteService(context.Background(), service.ID) })
+	require.NoError(t, mapper.UpdateServiceMetrics(ctx, service.ID, []string{prefix + "requests_total", prefix + "jobs_total", prefix + "up"}))
+	require.NoError(t, mapper.UpdateMetricDescriptions(ctx, map[string]string{
+		prefix + "requests_total": "Requests handled",
+		prefix + "jobs_total":     "Jobs run",
+	}))
+
+	descriptions, err := mapper.GetMetricDescriptions(ctx, []string{prefix + "requests_total", prefix + "up"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{prefix + "requests_total": "Requests handled"}, descriptions)
+
+	descriptions, err = mapper.GetMetricDescriptions(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, descriptions)
+}
+
+// TestGetMetricsByName tests that a metric is returned once for each service
+// reporting it
+func TestGetMetricsByName(t *testing.T) {
+	mapper := newTestPostgresMapper(t, 0)
+	ctx := context.Background()
+
+	prefix := fmt.Sprintf("by_name_%d_", time.Now().UnixNano())
+	var serviceIDs []string
+	for _, namespace := range []string{"a", "b"} {
+		service, err := mapper.CreateService(ctx, "api", prefix+namespace, nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { mapper.DeleteService(context.Background(), service.ID) })
+		require.NoError(t, mapper.UpdateServiceMetrics(ctx, service.ID, []string{prefix + "requests_total"}))
+		serviceIDs = append(serviceIDs, service.ID)
+	}
+
+	metrics, err := mapper.GetMetricsByName(ctx, prefix+"requests_total")
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+	var found []string
+	for _, metric := range metrics {
+		assert.Equal(t, prefix+"requests_total", metric.Name)
+		found = append(found, metric.ServiceID)
+	}
+	assert.ElementsMatch(t, serviceIDs, found)
+
+	metrics, err = mapper.GetMetricsByName(ctx, prefix+"missing")
+	require.NoError(t, err)
+	assert.Empty(t, metrics)
+}
+
+// TestCreateServiceConcurrent tests that concurrent creates of the same
+// service upsert a single row and return its ID
+func TestCreateServiceConcurrent(t *testing.T) {
+	mapper := newTestPostgresMapper(t, 0)
+	ctx := context.Background()
+	namespace := fmt.Sprintf("upsert-%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		mapper.db.Exec("DELETE FROM services WHERE namespace = $1", namespace)
+	})
+
+	const creates = 10
+	ids := make(chan string, creates)
+	errs := make(chan error, creates)
+	var wg sync.WaitGroup
+	for i := 0; i < creates; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			service, err := mapper.CreateService(ctx, "checkout", namespace, map[string]string{fmt.Sprintf("label_%d", i): "x"})
+			if err != nil {
+				errs <- err
+				return
+			}
+			ids <- service.ID
+		}(i)
+	}
+	wg.Wait()
+	close(ids)
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	first := ""
+	for id := range ids {
+		if first == "" {
+			first = id
+		}
+		assert.Equal(t, first, id, "every create returns the same service")
+	}
+
+	var rows int
+	require.NoError(t, mapper.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM services WHERE name = 'checkout' AND namespace = $1", namespace).Scan(&rows))
+	assert.Equal(t, 1, rows)
+}
+
+// TestEmbeddingSchemaStaleCountReused tests that the stale embedding count is
+// reused between health probes until it expires
+func TestEmbeddingSchemaStaleCountReused(t *testing.T) {
+	const dimension = 8
+	mapper := newTestPostgresMapper(t, dimension)
+	other := newTestPostgresMapper(t, 4)
+	ctx := context.Background()
+
+	before, err := mapper.EmbeddingSchema(ctx)
+	require.NoError(t, err)
+
+	query := fmt.Sprintf("stale test %d", time.Now().UnixNano())
+	require.NoError(t, other.StoreQueryEmbedding(ctx, query, unitEmbedding(4, 0, 0), "up"))
+	t.Cleanup(func() {
+		mapper.db.Exec("DELETE FROM query_embeddings WHERE query_text = $1", query)
+	})
+
+	cached, err := mapper.EmbeddingSchema(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, before.StaleEmbeddings, cached.StaleEmbeddings)
+
+	mapper.staleCheckedAt = time.Time{}
+	refreshed, err := mapper.EmbeddingSchema(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, before.StaleEmbeddings+1, refreshed.StaleEmbeddings)
+}
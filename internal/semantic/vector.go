@@ -0,0 +1,117 @@
+package semantic
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/seanankenbruck/observability-ai/internal/observability"
+)
+
+// ErrVectorUnsupported is returned when the database cannot store or search
+// query embeddings, e.g. because the pgvector extension is not installed
+var ErrVectorUnsupported = errors.New("database does not support query embeddings")
+
+// EmbeddingDimensionError is returned when a query embedding's dimension does
+// not match the stored embeddings, typically because the embedding model
+// changed. Similarity searches with it would fail or silently match nothing.
+type EmbeddingDimensionError struct {
+	Expected int // Dimension of the stored embeddings
+	Actual   int // Dimension of the query embedding
+}
+
+func (e *EmbeddingDimensionError) Error() string {
+	return fmt.Sprintf("query embedding has %d dimensions but stored embeddings have %d; "+
+		"if the embedding model changed, re-embed stored queries (POST /api/v1/admin/reembed)",
+		e.Actual, e.Expected)
+}
+
+// checkEmbeddingDimension verifies that a query embedding has the expected
+// dimension, counting and logging mismatches. A zero expected dimension
+// skips the check.
+func checkEmbeddingDimension(expected int, embedding []float32) error {
+	if expected <= 0 || len(embedding) == expected {
+		return nil
+	}
+
+	err := &EmbeddingDimensionError{Expected: expected, Actual: len(embedding)}
+	observability.GetGlobalMetrics().Inc(observability.MetricEmbeddingDimensionMismatch, nil)
+	log.Printf("Warning: skipping similarity search: %v", err)
+	return err
+}
+
+// vectorCatalog reports the database's pgvector setup
+type vectorCatalog interface {
+	// extensionInstalled reports whether the vector extension is enabled
+	extensionInstalled(ctx context.Context) (bool, error)
+	// embeddingDimension returns the fixed dimension of the query embedding
+	// column, or -1 if it accepts any dimension. found is false if the column
+	// does not exist.
+	embeddingDimension(ctx context.Context) (dimension int, found bool, err error)
+}
+
+// checkVectorSupport verifies that the vector extension is installed and that
+// the query embedding column accepts embeddings of the given dimension. A zero
+// dimension skips the dimension check. Failures wrap ErrVectorUnsupported and
+// tell the operator how to fix the database.
+func checkVectorSupport(ctx context.Context, catalog vectorCatalog, dimension int) error {
+	installed, err := catalog.extensionInstalled(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check for the vector extension: %w", err)
+	}
+	if !installed {
+		return fmt.Errorf("%w: the pgvector extension is not installed; "+
+			"enable it by running the migrations (go run ./cmd/migrate) or \"CREATE EXTENSION vector;\" as a database superuser",
+			ErrVectorUnsupported)
+	}
+
+	columnDimension, found, err := catalog.embeddingDimension(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check the query embedding column: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("%w: the query_embeddings.embedding column does not exist; run the migrations (go run ./cmd/migrate)",
+			ErrVectorUnsupported)
+	}
+	if dimension > 0 && columnDimension > 0 && columnDimension != dimension {
+		return fmt.Errorf("%w: the query_embeddings.embedding column is vector(%d) but embeddings have %d dimensions; "+
+			"apply migration 003_flexible_embedding_dimension (go run ./cmd/migrate)",
+			ErrVectorUnsupported, columnDimension, dimension)
+	}
+
+	return nil
+}
+
+// pgVectorCatalog reads the pgvector setup from the PostgreSQL system catalogs
+type pgVectorCatalog struct {
+	db *sql.DB
+}
+
+func (c pgVectorCatalog) extensionInstalled(ctx context.Context) (bool, error) {
+	var installed bool
+	err := c.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'vector')`).Scan(&installed)
+	return installed, err
+}
+
+func (c pgVectorCatalog) embeddingDimension(ctx context.Context) (int, bool, error) {
+	// For vector columns the type modifier is the dimension, or -1 if unconstrained
+	query := `
+		SELECT atttypmod
+		FROM pg_attribute
+		WHERE attrelid = to_regclass('query_embeddings')
+		  AND attname = 'embedding'
+		  AND NOT attisdropped
+	`
+
+	var dimension int
+	err := c.db.QueryRowContext(ctx, query).Scan(&dimension)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return dimension, true, nil
+}
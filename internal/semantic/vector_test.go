@@ -0,0 +1,122 @@
+package semantic
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/seanankenbruck/observability-ai/internal/observability"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubVectorCatalog reports a fixed pgvector setup
+type stubVectorCatalog struct {
+	installed    bool
+	dimension    int
+	columnExists bool
+	err          error
+}
+
+func (c stubVectorCatalog) extensionInstalled(ctx context.Context) (bool, error) {
+	return c.installed, c.err
+}
+
+func (c stubVectorCatalog) embeddingDimension(ctx context.Context) (int, bool, error) {
+	return c.dimension, c.columnExists, nil
+}
+
+// TestCheckVectorSupport tests that missing pgvector support fails with an actionable error
+func TestCheckVectorSupport(t *testing.T) {
+	tests := []struct {
+		name        string
+		catalog     stubVectorCatalog
+		dimension   int
+		errContains string
+	}{
+		{
+			name:      "flexible column",
+			catalog:   stubVectorCatalog{installed: true, dimension: -1, columnExists: true},
+			dimension: 384,
+		},
+		{
+			name:      "matching fixed dimension",
+			catalog:   stubVectorCatalog{installed: true, dimension: 1536, columnExists: true},
+			dimension: 1536,
+		},
+		{
+			name:      "unknown dimension skips the check",
+			catalog:   stubVectorCatalog{installed: true, dimension: 1536, columnExists: true},
+			dimension: 0,
+		},
+		{
+			name:        "extension not installed",
+			catalog:     stubVectorCatalog{installed: false},
+			dimension:   384,
+			errContains: "CREATE EXTENSION vector",
+		},
+		{
+			name:        "embedding column missing",
+			catalog:     stubVectorCatalog{installed: true, columnExists: false},
+			dimension:   384,
+			errContains: "run the migrations",
+		},
+		{
+			name:        "mismatched fixed dimension",
+			catalog:     stubVectorCatalog{installed: true, dimension: 1536, columnExists: true},
+			dimension:   384,
+			errContains: "vector(1536) but embeddings have 384 dimensions",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkVectorSupport(context.Background(), tt.catalog, tt.dimension)
+			if tt.errContains == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrVectorUnsupported))
+			assert.Contains(t, err.Error(), tt.errContains)
+		})
+	}
+
+	t.Run("catalog query failure is not reported as unsupported", func(t *testing.T) {
+		err := checkVectorSupport(context.Background(), stubVectorCatalog{err: errors.New("connection reset")}, 384)
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, ErrVectorUnsupported))
+		assert.Contains(t, err.Error(), "connection reset")
+	})
+}
+
+// TestFindSimilarQueriesDimensionMismatch tests that wrong-dimension embeddings
+// are rejected with a typed error before querying the database
+func TestFindSimilarQueriesDimensionMismatch(t *testing.T) {
+	metrics := observability.GetGlobalMetrics()
+	mismatches := func() float64 {
+		if metric, ok := metrics.Get(observability.MetricEmbeddingDimensionMismatch, nil); ok {
+			return metric.Value
+		}
+		return 0
+	}
+
+	// No database is needed: the embedding is rejected before any query
+	mapper := &PostgresMapper{metric: DistanceCosine, dimension: 384}
+	before := mismatches()
+
+	_, err := mapper.FindSimilarQueries(context.Background(), make([]float32, 1536))
+	var dimensionErr *EmbeddingDimensionError
+	require.True(t, errors.As(err, &dimensionErr))
+	assert.Equal(t, 384, dimensionErr.Expected)
+	assert.Equal(t, 1536, dimensionErr.Actual)
+	assert.Contains(t, err.Error(), "/api/v1/admin/reembed")
+	assert.Equal(t, before+1, mismatches())
+
+	_, err = mapper.FindSimilarQueriesBatch(context.Background(), [][]float32{make([]float32, 384), make([]float32, 768)})
+	require.True(t, errors.As(err, &dimensionErr))
+	assert.Equal(t, 768, dimensionErr.Actual)
+	assert.Equal(t, before+2, mismatches())
+
+	assert.NoError(t, checkEmbeddingDimension(0, make([]float32, 768)), "unknown dimension skips the check")
+}
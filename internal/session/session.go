@@ -24,6 +24,12 @@ type Session struct {
 	Token     string    `json:"token"`
 	CreatedAt time.Time `json:"created_at"`
 	ExpiresAt time.Time `json:"expires_at"`
+
+	// Provider and ExternalID identify a user of an external identity
+	// provider, such as GitHub, who is only known to the replica that
+	// handled the login; the other replicas rebuild the user from them
+	Provider   string `json:"provider,omitempty"`
+	ExternalID string `json:"external_id,omitempty"`
 }
 
 // Manager handles session storage and retrieval
@@ -42,21 +48,37 @@ func NewManager(redisClient *redis.Client, expiry time.Duration) *Manager {
 
 // Create creates a new session and returns the session ID
 func (m *Manager) Create(ctx context.Context, userID, username, token string, roles []string) (string, error) {
+	return m.store(ctx, Session{
+		UserID:   userID,
+		Username: username,
+		Roles:    roles,
+		Token:    token,
+	})
+}
+
+// CreateExternal creates a new session for a user of an external identity
+// provider and returns the session ID
+func (m *Manager) CreateExternal(ctx context.Context, userID, username, token string, roles []string, provider, externalID string) (string, error) {
+	return m.store(ctx, Session{
+		UserID:     userID,
+		Username:   username,
+		Roles:      roles,
+		Token:      token,
+		Provider:   provider,
+		ExternalID: externalID,
+	})
+}
+
+// store stores a new session under a generated ID
+func (m *Manager) store(ctx context.Context, session Session) (string, error) {
 	// Generate session ID
 	sessionID, err := generateSessionID()
 	if err != nil {
 		return "", fmt.Errorf("failed to generate session ID: %w", err)
 	}
 
-	// Create session data
-	session := Session{
-		UserID:    userID,
-		Username:  username,
-		Roles:     roles,
-		Token:     token,
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(m.expiry),
-	}
+	session.CreatedAt = time.Now()
+	session.ExpiresAt = session.CreatedAt.Add(m.expiry)
 
 	// Serialize session
 	data, err := json.Marshal(session)
@@ -111,6 +133,41 @@ func (m *Manager) Refresh(ctx context.Context, sessionID string) error {
 	return m.redis.Expire(ctx, key, m.expiry).Err()
 }
 
+// CleanupExpired deletes sessions whose expiry has passed but which are still
+// stored, e.g. because a refresh extended the Redis TTL. Sessions that cannot be
+// decoded are removed as well. It returns the number of sessions deleted.
+func (m *Manager) CleanupExpired(ctx context.Context) (int, error) {
+	now := time.Now()
+	removed := 0
+
+	iter := m.redis.Scan(ctx, 0, sessionPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		data, err := m.redis.Get(ctx, key).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return removed, fmt.Errorf("failed to get session: %w", err)
+		}
+
+		var session Session
+		if err := json.Unmarshal([]byte(data), &session); err == nil && !now.After(session.ExpiresAt) {
+			continue
+		}
+
+		if err := m.redis.Del(ctx, key).Err(); err != nil {
+			return removed, fmt.Errorf("failed to delete session: %w", err)
+		}
+		removed++
+	}
+	if err := iter.Err(); err != nil {
+		return removed, fmt.Errorf("failed to scan sessions: %w", err)
+	}
+
+	return removed, nil
+}
+
 // generateSessionID generates a cryptographically secure random session ID
 func generateSessionID() (string, error) {
 	b := make([]byte, sessionIDLen)
@@ -0,0 +1,12 @@
+-- Rollback migration: Restore fixed 1536-dimension query embeddings
+
+DROP INDEX IF EXISTS idx_query_embeddings_cosine_1536;
+
+-- Embeddings of any other dimension cannot be cast back and must be regenerated
+UPDATE query_embeddings SET embedding = NULL WHERE vector_dims(embedding) <> 1536;
+
+ALTER TABLE query_embeddings ALTER COLUMN embedding TYPE vector(1536) USING embedding::vector(1536);
+
+CREATE INDEX IF NOT EXISTS idx_query_embeddings_vector ON query_embeddings
+USING hnsw (embedding vector_cosine_ops)
+WITH (m = 16, ef_construction = 64);
@@ -0,0 +1,21 @@
+-- Migration: Allow query embeddings of any dimension
+-- Created: 2026-10-16
+
+-- The embedding column was created as vector(1536), which rejects embeddings
+-- produced by models with a different dimension. Switching embedding models
+-- requires re-embedding stored queries (POST /api/v1/admin/reembed), so the
+-- column must be able to hold the old and new dimensions side by side.
+
+-- HNSW indexes require a fixed dimension
+DROP INDEX IF EXISTS idx_query_embeddings_vector;
+
+ALTER TABLE query_embeddings ALTER COLUMN embedding TYPE vector USING embedding::vector;
+
+-- Recreate the index for the default 1536-dimension embeddings as a partial
+-- expression index, so similarity searches keep using it instead of scanning
+-- the table. Embeddings of other dimensions are indexed separately once the
+-- processor runs with that dimension.
+CREATE INDEX IF NOT EXISTS idx_query_embeddings_cosine_1536 ON query_embeddings
+USING hnsw ((embedding::vector(1536)) vector_cosine_ops)
+WITH (m = 16, ef_construction = 64)
+WHERE vector_dims(embedding) = 1536;
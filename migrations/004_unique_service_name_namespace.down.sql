@@ -0,0 +1,8 @@
+-- Rollback migration: Set aside duplicate services
+
+-- Restore the duplicate services and their metrics
+INSERT INTO services SELECT * FROM services_duplicates;
+INSERT INTO metrics SELECT * FROM metrics_duplicates;
+
+DROP TABLE IF EXISTS metrics_duplicates;
+DROP TABLE IF EXISTS services_duplicates;
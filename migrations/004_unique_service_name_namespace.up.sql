@@ -0,0 +1,30 @@
+-- Migration: Set aside duplicate services
+-- Created: 2026-10-16
+
+-- Service creation is an upsert on (name, namespace), relying on the
+-- services_name_namespace_unique constraint of the initial schema. Databases
+-- that lost the constraint may hold duplicate services created by concurrent
+-- discovery runs, which the upsert cannot resolve. All but the oldest service
+-- of each name and namespace are moved, with their metrics, to
+-- services_duplicates and metrics_duplicates; the down migration restores
+-- them. Drop both tables once the duplicates are known to be unneeded.
+CREATE TABLE services_duplicates (LIKE services INCLUDING DEFAULTS);
+CREATE TABLE metrics_duplicates (LIKE metrics INCLUDING DEFAULTS);
+
+INSERT INTO services_duplicates
+SELECT dup.*
+FROM services dup
+WHERE EXISTS (
+    SELECT 1 FROM services keep
+    WHERE keep.name = dup.name
+      AND keep.namespace = dup.namespace
+      AND (keep.created_at, keep.id) < (dup.created_at, dup.id)
+);
+
+INSERT INTO metrics_duplicates
+SELECT m.*
+FROM metrics m
+JOIN services_duplicates d ON d.id = m.service_id;
+
+-- Deleting the services cascades to their metrics
+DELETE FROM services WHERE id IN (SELECT id FROM services_duplicates);
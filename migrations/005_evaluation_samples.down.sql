@@ -0,0 +1,3 @@
+-- Rollback migration: Remove evaluation samples
+
+DROP TABLE IF EXISTS evaluation_samples;
@@ -0,0 +1,17 @@
+-- Migration: Store sampled prompts and generated queries for offline evaluation
+-- Created: 2026-10-16
+
+-- Samples are written with personal data already redacted, and back prompt
+-- regression testing.
+CREATE TABLE IF NOT EXISTS evaluation_samples (
+    id UUID PRIMARY KEY,
+    natural_query TEXT NOT NULL,
+    prompt TEXT NOT NULL,
+    generated_promql TEXT NOT NULL,
+    intent JSONB DEFAULT '{}',
+    confidence_score FLOAT,
+    model VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_evaluation_samples_created_at ON evaluation_samples USING btree (created_at);
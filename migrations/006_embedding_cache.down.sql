@@ -0,0 +1,3 @@
+-- Rollback migration: Remove the embedding cache
+
+DROP TABLE IF EXISTS embedding_cache;
@@ -0,0 +1,16 @@
+-- Migration: Durable cache of query embeddings keyed by embedding model and
+-- normalized query text
+-- Created: 2026-10-16
+
+-- Identical queries reuse their stored embedding across restarts, so
+-- re-embedding them is free and deterministic. Entries are only served to the
+-- model that produced them; those of a previous model are purged by a
+-- re-embedding run.
+CREATE TABLE IF NOT EXISTS embedding_cache (
+    model TEXT NOT NULL,
+    normalized_query TEXT NOT NULL,
+    embedding vector NOT NULL,
+    dimensions INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (model, normalized_query)
+);
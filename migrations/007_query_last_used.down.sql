@@ -0,0 +1,3 @@
+-- Rollback migration: Stop tracking when stored queries were last used
+
+ALTER TABLE query_embeddings DROP COLUMN IF EXISTS last_used_at;
@@ -0,0 +1,12 @@
+-- Migration: Track when stored queries were last used
+-- Created: 2026-10-16
+
+-- Recency-weighted similarity search ranks stored queries by when they were
+-- last stored or regenerated. updated_at cannot serve this since re-embedding
+-- touches every row.
+ALTER TABLE query_embeddings ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE query_embeddings SET last_used_at = created_at WHERE last_used_at IS NULL;
+
+ALTER TABLE query_embeddings ALTER COLUMN last_used_at SET DEFAULT NOW();
+ALTER TABLE query_embeddings ALTER COLUMN last_used_at SET NOT NULL;
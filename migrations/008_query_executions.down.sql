@@ -0,0 +1,3 @@
+-- Rollback migration: Stop counting query executions
+
+DROP TABLE IF EXISTS query_executions;
@@ -0,0 +1,15 @@
+-- Migration: Count how often queries are executed
+-- Created: 2026-10-16
+
+-- Recording rule suggestions rank generated expressions by how often they
+-- are executed. Each query and expression pair is counted once per executed
+-- request, whether it was generated or served from the cache.
+CREATE TABLE IF NOT EXISTS query_executions (
+    query_text TEXT NOT NULL,
+    promql TEXT NOT NULL,
+    execution_count BIGINT NOT NULL DEFAULT 1,
+    last_executed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (query_text, promql)
+);
+
+CREATE INDEX IF NOT EXISTS idx_query_executions_last_executed_at ON query_executions (last_executed_at DESC);
@@ -0,0 +1,3 @@
+-- Rollback migration: Drop the query history index
+
+DROP INDEX IF EXISTS idx_query_embeddings_history;
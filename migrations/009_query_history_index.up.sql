@@ -0,0 +1,6 @@
+-- Migration: Index the query history order
+-- Created: 2026-10-16
+
+-- The query history is paged by (last_used_at, id) cursors, most recent
+-- first. The index serves each page without sorting the table.
+CREATE INDEX IF NOT EXISTS idx_query_embeddings_history ON query_embeddings (last_used_at DESC, id DESC);
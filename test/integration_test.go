@@ -664,6 +664,53 @@ func (m *MockSemanticMapper) SearchServices(ctx context.Context, searchTerm stri
 	return m.GetServices(ctx)
 }
 
+func (m *MockSemanticMapper) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *MockSemanticMapper) Close() error {
+	return nil
+}
+
+func (m *MockSemanticMapper) GetNamespaces(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	namespaces := make([]string, 0)
+	for _, svc := range m.services {
+		if !seen[svc.Namespace] {
+			seen[svc.Namespace] = true
+			namespaces = append(namespaces, svc.Namespace)
+		}
+	}
+	return namespaces, nil
+}
+
+func (m *MockSemanticMapper) GetCatalogStats(ctx context.Context) (*semantic.CatalogStats, error) {
+	stats := &semantic.CatalogStats{
+		TotalServices:       len(m.services),
+		MetricsByType:       make(map[string]int),
+		ServicesByNamespace: make(map[string]int),
+	}
+	for _, svc := range m.services {
+		stats.ServicesByNamespace[svc.Namespace]++
+	}
+	names := make(map[string]map[string]bool)
+	for _, metric := range m.metrics {
+		if names[metric.Type] == nil {
+			names[metric.Type] = make(map[string]bool)
+		}
+		names[metric.Type][metric.Name] = true
+	}
+	all := make(map[string]bool)
+	for metricType, byName := range names {
+		stats.MetricsByType[metricType] = len(byName)
+		for name := range byName {
+			all[name] = true
+		}
+	}
+	stats.TotalMetrics = len(all)
+	return stats, nil
+}
+
 func (m *MockSemanticMapper) GetMetrics(ctx context.Context, serviceID string) ([]semantic.Metric, error) {
 	metrics := make([]semantic.Metric, 0)
 	for _, metric := range m.metrics {
@@ -674,6 +721,16 @@ func (m *MockSemanticMapper) GetMetrics(ctx context.Context, serviceID string) (
 	return metrics, nil
 }
 
+func (m *MockSemanticMapper) GetMetricsByName(ctx context.Context, name string) ([]semantic.Metric, error) {
+	metrics := make([]semantic.Metric, 0)
+	for _, metric := range m.metrics {
+		if metric.Name == name {
+			metrics = append(metrics, *metric)
+		}
+	}
+	return metrics, nil
+}
+
 func (m *MockSemanticMapper) CreateMetric(ctx context.Context, name, metricType, description, serviceID string, labels map[string]string) (*semantic.Metric, error) {
 	metric := &semantic.Metric{
 		ID:          "metric-" + name,
@@ -687,14 +744,82 @@ func (m *MockSemanticMapper) CreateMetric(ctx context.Context, name, metricType,
 	return metric, nil
 }
 
+func (m *MockSemanticMapper) UpdateMetricDescriptions(ctx context.Context, descriptions map[string]string) error {
+	for _, metric := range m.metrics {
+		if description, ok := descriptions[metric.Name]; ok {
+			metric.Description = description
+		}
+	}
+	return nil
+}
+
+func (m *MockSemanticMapper) GetMetricDescriptions(ctx context.Context, names []string) (map[string]string, error) {
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+	descriptions := make(map[string]string)
+	for _, metric := range m.metrics {
+		if metric.Description != "" && wanted[metric.Name] {
+			descriptions[metric.Name] = metric.Description
+		}
+	}
+	return descriptions, nil
+}
+
 func (m *MockSemanticMapper) FindSimilarQueries(ctx context.Context, embedding []float32) ([]semantic.SimilarQuery, error) {
 	return []semantic.SimilarQuery{}, nil
 }
 
+func (m *MockSemanticMapper) FindSimilarQueriesBatch(ctx context.Context, embeddings [][]float32) ([][]semantic.SimilarQuery, error) {
+	results := make([][]semantic.SimilarQuery, len(embeddings))
+	for i, embedding := range embeddings {
+		similar, err := m.FindSimilarQueries(ctx, embedding)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = similar
+	}
+	return results, nil
+}
+
 func (m *MockSemanticMapper) StoreQueryEmbedding(ctx context.Context, query string, embedding []float32, promql string) error {
 	return nil
 }
 
+func (m *MockSemanticMapper) ListStoredQueries(ctx context.Context, afterID string, limit int) ([]semantic.StoredQuery, error) {
+	return []semantic.StoredQuery{}, nil
+}
+
+func (m *MockSemanticMapper) GetRecentQueries(ctx context.Context, limit int, after *semantic.QueryCursor) ([]semantic.StoredQuery, error) {
+	return []semantic.StoredQuery{}, nil
+}
+
+func (m *MockSemanticMapper) UpdateQueryEmbedding(ctx context.Context, id string, embedding []float32) error {
+	return nil
+}
+
+func (m *MockSemanticMapper) RecordQueryExecution(ctx context.Context, query, promql string) error {
+	return nil
+}
+
+func (m *MockSemanticMapper) GetQueryExecutions(ctx context.Context, limit int) ([]semantic.QueryExecution, error) {
+	return nil, nil
+}
+
+func (m *MockSemanticMapper) StoreEvaluationSample(ctx context.Context, sample semantic.EvaluationSample) error {
+	return nil
+}
+
+func (m *MockSemanticMapper) GetOrStoreEmbedding(ctx context.Context, model, normalizedQuery string, embed semantic.EmbedFunc) ([]float32, bool, error) {
+	embedding, err := embed(ctx)
+	return embedding, false, err
+}
+
+func (m *MockSemanticMapper) PurgeEmbeddingCache(ctx context.Context, model string, dimension int) (int, error) {
+	return 0, nil
+}
+
 func (m *MockSemanticMapper) GetAllServices() []semantic.Service {
 	services := make([]semantic.Service, 0, len(m.services))
 	for _, svc := range m.services {